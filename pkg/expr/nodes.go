@@ -13,6 +13,7 @@ import (
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins/adapters"
+	"github.com/grafana/grafana/pkg/services/query/resultlimits"
 	"github.com/grafana/grafana/pkg/util/errutil"
 
 	"gonum.org/v1/gonum/graph/simple"
@@ -221,14 +222,26 @@ func (dn *DSNode) Execute(ctx context.Context, vars mathexp.Vars, s *Service) (m
 		},
 	}
 
+	// The queries of expressions get the same result limits as the queries
+	// sent to data sources directly.
+	headers := make(map[string]string, len(dn.request.Headers))
+	for k, v := range dn.request.Headers {
+		headers[k] = v
+	}
+	limits := resultlimits.ForDataSource(s.cfg, dn.datasource)
+	limits.SetHeaders(headers)
+
 	resp, err := s.dataService.QueryData(ctx, &backend.QueryDataRequest{
 		PluginContext: pc,
 		Queries:       q,
-		Headers:       dn.request.Headers,
+		Headers:       headers,
 	})
 	if err != nil {
 		return mathexp.Results{}, err
 	}
+	if limits.Enabled() {
+		limits.Truncate(resp, q)
+	}
 
 	vals := make([]mathexp.Value, 0)
 	for refID, qr := range resp.Responses {
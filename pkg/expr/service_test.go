@@ -12,7 +12,9 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/expr/mathexp"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/query/resultlimits"
 	"github.com/grafana/grafana/pkg/services/secrets/fakes"
 	secretsManager "github.com/grafana/grafana/pkg/services/secrets/manager"
 	"github.com/grafana/grafana/pkg/setting"
@@ -98,15 +100,48 @@ func TestService(t *testing.T) {
 	}
 }
 
+func TestService_ResultLimits(t *testing.T) {
+	me := &mockEndpoint{
+		Frames: []*data.Frame{data.NewFrame("test",
+			data.NewField("time", nil, []time.Time{time.Unix(1, 0), time.Unix(2, 0), time.Unix(3, 0)}),
+			data.NewField("value", nil, []*float64{fp(1), fp(2), fp(3)}))},
+	}
+
+	cfg := setting.NewCfg()
+	cfg.DataProxyResultCellsLimit = 4
+
+	s := Service{
+		cfg:            cfg,
+		dataService:    me,
+		secretsService: secretsManager.SetupTestService(t, fakes.NewFakeSecretsStore()),
+	}
+
+	node := &DSNode{
+		baseNode:   baseNode{refID: "A"},
+		datasource: &models.DataSource{OrgId: 1, Uid: "test", Type: "test", JsonData: simplejson.New()},
+		orgID:      1,
+		request:    Request{Headers: map[string]string{"Authorization": "Bearer token"}},
+	}
+
+	res, err := node.Execute(context.Background(), nil, &s)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"Authorization": "Bearer token", resultlimits.CellsHeader: "4"}, me.Headers)
+	require.Len(t, node.request.Headers, 1)
+	require.Equal(t, 2, res.Values[0].(mathexp.Series).Len())
+}
+
 func fp(f float64) *float64 {
 	return &f
 }
 
 type mockEndpoint struct {
-	Frames data.Frames
+	Frames  data.Frames
+	Headers map[string]string
 }
 
 func (me *mockEndpoint) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	me.Headers = req.Headers
 	resp := backend.NewQueryDataResponse()
 	resp.Responses["A"] = backend.DataResponse{
 		Frames: me.Frames,
@@ -2,9 +2,12 @@
 package adapters
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/models"
 )
 
@@ -48,3 +51,24 @@ func BackendUserFromSignedInUser(su *models.SignedInUser) *backend.User {
 		Role:  string(su.OrgRole),
 	}
 }
+
+// SignedInUserFromBackendUser returns the SignedInUser of the backend plugin's
+// user in the organization, looked up by login.
+func SignedInUserFromBackendUser(ctx context.Context, orgID int64, user *backend.User) (*models.SignedInUser, error) {
+	if user == nil || user.Login == "" {
+		return nil, fmt.Errorf("the request has no user")
+	}
+
+	userQuery := &models.GetUserByLoginQuery{LoginOrEmail: user.Login}
+	if err := bus.Dispatch(ctx, userQuery); err != nil {
+		return nil, err
+	}
+	signedInQuery := &models.GetSignedInUserQuery{UserId: userQuery.Result.Id, OrgId: orgID}
+	if err := bus.Dispatch(ctx, signedInQuery); err != nil {
+		return nil, err
+	}
+	if signedInQuery.Result.OrgId != orgID {
+		return nil, fmt.Errorf("user %q is not a member of organization %d", user.Login, orgID)
+	}
+	return signedInQuery.Result, nil
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/grafana/grafana/pkg/tsdb/elasticsearch"
 	"github.com/grafana/grafana/pkg/tsdb/grafanads"
 	"github.com/grafana/grafana/pkg/tsdb/graphite"
+	"github.com/grafana/grafana/pkg/tsdb/httpdata"
 	"github.com/grafana/grafana/pkg/tsdb/influxdb"
+	"github.com/grafana/grafana/pkg/tsdb/jaeger"
 	"github.com/grafana/grafana/pkg/tsdb/loki"
 	"github.com/grafana/grafana/pkg/tsdb/mssql"
 	"github.com/grafana/grafana/pkg/tsdb/mysql"
@@ -19,6 +21,7 @@ import (
 	"github.com/grafana/grafana/pkg/tsdb/prometheus"
 	"github.com/grafana/grafana/pkg/tsdb/tempo"
 	"github.com/grafana/grafana/pkg/tsdb/testdatasource"
+	"github.com/grafana/grafana/pkg/tsdb/zipkin"
 )
 
 const (
@@ -28,6 +31,7 @@ const (
 	Elasticsearch   = "elasticsearch"
 	Graphite        = "graphite"
 	InfluxDB        = "influxdb"
+	Jaeger          = "jaeger"
 	Loki            = "loki"
 	OpenTSDB        = "opentsdb"
 	Prometheus      = "prometheus"
@@ -37,6 +41,8 @@ const (
 	MySQL           = "mysql"
 	MSSQL           = "mssql"
 	Grafana         = "grafana"
+	Zipkin          = "zipkin"
+	HTTPData        = "httpdata"
 )
 
 type Registry struct {
@@ -52,7 +58,8 @@ func NewRegistry(store map[string]backendplugin.PluginFactoryFunc) *Registry {
 func ProvideCoreRegistry(am *azuremonitor.Service, cw *cloudwatch.CloudWatchService, cm *cloudmonitoring.Service,
 	es *elasticsearch.Service, grap *graphite.Service, idb *influxdb.Service, lk *loki.Service, otsdb *opentsdb.Service,
 	pr *prometheus.Service, t *tempo.Service, td *testdatasource.Service, pg *postgres.Service, my *mysql.Service,
-	ms *mssql.Service, graf *grafanads.Service) *Registry {
+	ms *mssql.Service, graf *grafanads.Service, jgr *jaeger.Service,
+	zpk *zipkin.Service, hd *httpdata.Service) *Registry {
 	return NewRegistry(map[string]backendplugin.PluginFactoryFunc{
 		CloudWatch:      asBackendPlugin(cw.Executor),
 		CloudMonitoring: asBackendPlugin(cm),
@@ -60,6 +67,7 @@ func ProvideCoreRegistry(am *azuremonitor.Service, cw *cloudwatch.CloudWatchServ
 		Elasticsearch:   asBackendPlugin(es),
 		Graphite:        asBackendPlugin(grap),
 		InfluxDB:        asBackendPlugin(idb),
+		Jaeger:          asBackendPlugin(jgr),
 		Loki:            asBackendPlugin(lk),
 		OpenTSDB:        asBackendPlugin(otsdb),
 		Prometheus:      asBackendPlugin(pr),
@@ -69,6 +77,8 @@ func ProvideCoreRegistry(am *azuremonitor.Service, cw *cloudwatch.CloudWatchServ
 		MySQL:           asBackendPlugin(my),
 		MSSQL:           asBackendPlugin(ms),
 		Grafana:         asBackendPlugin(graf),
+		Zipkin:          asBackendPlugin(zpk),
+		HTTPData:        asBackendPlugin(hd),
 	})
 }
 
@@ -0,0 +1,104 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// dataSourceQueries are the queries of a request using the same data source.
+type dataSourceQueries struct {
+	datasource *models.DataSource
+	queries    []backend.DataQuery
+}
+
+// groupByDataSource returns the queries grouped by data source, in the order
+// the data sources first appear in the request.
+func (r *parsedRequest) groupByDataSource() []dataSourceQueries {
+	var groups []dataSourceQueries
+	indices := map[string]int{}
+	for _, pq := range r.parsedQueries {
+		i, ok := indices[pq.datasource.Uid]
+		if !ok {
+			i = len(groups)
+			indices[pq.datasource.Uid] = i
+			groups = append(groups, dataSourceQueries{datasource: pq.datasource})
+		}
+		groups[i].queries = append(groups[i].queries, pq.query)
+	}
+	return groups
+}
+
+// handleMixedQueryData queries the data sources of a mixed request
+// concurrently and merges their responses. Every data source gets the
+// deadline of its own timeout, see mixedQueryTimeout, so that a slow data
+// source doesn't hold up the response of the others. The queries of a data
+// source failing get its error instead of failing the whole request.
+func (s *Service) handleMixedQueryData(ctx context.Context, user *models.SignedInUser, groups []dataSourceQueries) (*backend.QueryDataResponse, error) {
+	responses := make([]*backend.QueryDataResponse, len(groups))
+	errs := make([]error, len(groups))
+
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		i, group := i, group
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := ctx
+			timeout := s.mixedQueryTimeout(group.datasource)
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+			responses[i], errs[i] = s.queryDataSource(ctx, user, group.datasource, group.queries)
+			if timeout > 0 && errors.Is(errs[i], context.DeadlineExceeded) {
+				errs[i] = fmt.Errorf("query timed out after %s: %w", timeout, errs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	merged := backend.NewQueryDataResponse()
+	for i, group := range groups {
+		if err := errs[i]; err != nil {
+			s.log.Warn("Mixed query of data source failed", "datasource", group.datasource.Uid, "error", err)
+			for _, q := range group.queries {
+				merged.Responses[q.RefID] = backend.DataResponse{Error: fmt.Errorf("%s: %w", group.datasource.Name, err)}
+			}
+			continue
+		}
+		if responses[i] == nil {
+			continue
+		}
+		for refID, res := range responses[i].Responses {
+			merged.Responses[refID] = res
+		}
+	}
+	return merged, nil
+}
+
+// mixedQueryTimeout returns the timeout of the queries of a data source in a
+// mixed request: the HTTP timeout of the data source, or the data proxy
+// timeout when it has none. Zero means no timeout.
+func (s *Service) mixedQueryTimeout(ds *models.DataSource) time.Duration {
+	if ds.JsonData != nil {
+		timeout := ds.JsonData.Get("timeout").MustInt()
+		if timeout <= 0 {
+			timeout, _ = strconv.Atoi(ds.JsonData.Get("timeout").MustString())
+		}
+		if timeout > 0 {
+			return time.Duration(timeout) * time.Second
+		}
+	}
+	if s.cfg != nil && s.cfg.DataProxyTimeout > 0 {
+		return time.Duration(s.cfg.DataProxyTimeout) * time.Second
+	}
+	return 0
+}
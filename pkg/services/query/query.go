@@ -15,6 +15,7 @@ import (
 	"github.com/grafana/grafana/pkg/plugins/adapters"
 	"github.com/grafana/grafana/pkg/services/datasources"
 	"github.com/grafana/grafana/pkg/services/oauthtoken"
+	"github.com/grafana/grafana/pkg/services/query/resultlimits"
 	"github.com/grafana/grafana/pkg/services/secrets"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/tsdb/grafanads"
@@ -114,7 +115,15 @@ func (s *Service) handleExpressions(ctx context.Context, user *models.SignedInUs
 }
 
 func (s *Service) handleQueryData(ctx context.Context, user *models.SignedInUser, parsedReq *parsedRequest) (*backend.QueryDataResponse, error) {
-	ds := parsedReq.parsedQueries[0].datasource
+	groups := parsedReq.groupByDataSource()
+	if len(groups) > 1 {
+		return s.handleMixedQueryData(ctx, user, groups)
+	}
+	return s.queryDataSource(ctx, user, groups[0].datasource, groups[0].queries)
+}
+
+// queryDataSource sends the queries to the plugin of the data source.
+func (s *Service) queryDataSource(ctx context.Context, user *models.SignedInUser, ds *models.DataSource, queries []backend.DataQuery) (*backend.QueryDataResponse, error) {
 	if err := s.pluginRequestValidator.Validate(ds.Url, nil); err != nil {
 		return nil, models.ErrDataSourceAccessDenied
 	}
@@ -150,11 +159,19 @@ func (s *Service) handleQueryData(ctx context.Context, user *models.SignedInUser
 		req.Headers[k] = v
 	}
 
-	for _, q := range parsedReq.parsedQueries {
-		req.Queries = append(req.Queries, q.query)
-	}
+	limits := resultlimits.ForDataSource(s.cfg, ds)
+	limits.SetHeaders(req.Headers)
 
-	return s.pluginClient.QueryData(ctx, req)
+	req.Queries = append(req.Queries, queries...)
+
+	res, err := s.pluginClient.QueryData(ctx, req)
+	if err != nil || res == nil {
+		return res, err
+	}
+	if limits.Enabled() {
+		limits.Truncate(res, queries)
+	}
+	return res, nil
 }
 
 type parsedQuery struct {
@@ -237,10 +254,15 @@ func (s *Service) parseMetricRequest(ctx context.Context, user *models.SignedInU
 		})
 	}
 
-	if !req.hasExpression {
-		if len(datasourcesByUid) > 1 {
-			// We do not (yet) support mixed query type
-			return nil, NewErrBadQuery("all queries must use the same datasource")
+	if !req.hasExpression && len(datasourcesByUid) > 1 {
+		// The responses of the data sources of mixed requests are merged by
+		// refId.
+		refIDs := map[string]bool{}
+		for _, pq := range req.parsedQueries {
+			if refIDs[pq.query.RefID] {
+				return nil, NewErrBadQuery(fmt.Sprintf("duplicate refId %q in queries of different data sources", pq.query.RefID))
+			}
+			refIDs[pq.query.RefID] = true
 		}
 	}
 
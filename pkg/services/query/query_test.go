@@ -2,18 +2,23 @@ package query_test
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/oauth2"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/api/dtos"
 	"github.com/grafana/grafana/pkg/components/simplejson"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/services/query"
 	"github.com/grafana/grafana/pkg/services/secrets"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/stretchr/testify/require"
 )
 
@@ -49,6 +54,110 @@ func TestQueryData(t *testing.T) {
 		}
 		require.Equal(t, expected, tc.pluginContext.req.Headers)
 	})
+
+	t.Run("it leaves the timeout of a single data source to its plugin", func(t *testing.T) {
+		tc := setup()
+		tc.dataSourceCache.ds.Type = "prometheus"
+		tc.queryService = query.ProvideService(&setting.Cfg{DataProxyTimeout: 1}, tc.dataSourceCache, nil, tc.pluginRequestValidator,
+			tc.secretService, tc.pluginContext, tc.oauthTokenService)
+
+		_, err := tc.queryService.QueryData(context.Background(), nil, true, metricRequest(), false)
+		require.NoError(t, err)
+		require.Empty(t, tc.pluginContext.deadlines)
+	})
+}
+
+func TestQueryData_Mixed(t *testing.T) {
+	setupMixed := func(cfg *setting.Cfg) *testContext {
+		tc := setup()
+		tc.dataSourceCache.byUID = map[string]*models.DataSource{
+			"prom": {Uid: "prom", Name: "Prometheus", Type: "prometheus"},
+			"loki": {Uid: "loki", Name: "Loki", Type: "loki"},
+		}
+		tc.queryService = query.ProvideService(cfg, tc.dataSourceCache, nil, tc.pluginRequestValidator,
+			tc.secretService, tc.pluginContext, tc.oauthTokenService)
+		return tc
+	}
+
+	mixedRequest := func(refIDs ...string) dtos.MetricRequest {
+		uids := []string{"prom", "loki", "prom"}
+		queries := make([]*simplejson.Json, len(refIDs))
+		for i, refID := range refIDs {
+			queries[i] = simplejson.NewFromAny(map[string]interface{}{
+				"refId":      refID,
+				"datasource": map[string]interface{}{"uid": uids[i]},
+			})
+		}
+		return dtos.MetricRequest{Queries: queries}
+	}
+
+	t.Run("it queries each data source once and merges the responses", func(t *testing.T) {
+		tc := setupMixed(nil)
+
+		res, err := tc.queryService.QueryData(context.Background(), &models.SignedInUser{}, true, mixedRequest("A", "B", "C"), false)
+		require.NoError(t, err)
+
+		require.Len(t, tc.pluginContext.reqs, 2)
+		for _, req := range tc.pluginContext.reqs {
+			switch req.PluginContext.PluginID {
+			case "prometheus":
+				require.Len(t, req.Queries, 2)
+				require.Equal(t, "A", req.Queries[0].RefID)
+				require.Equal(t, "C", req.Queries[1].RefID)
+			case "loki":
+				require.Len(t, req.Queries, 1)
+			}
+		}
+
+		require.Len(t, res.Responses, 3)
+		require.Equal(t, "prometheus", res.Responses["A"].Frames[0].Name)
+		require.Equal(t, "loki", res.Responses["B"].Frames[0].Name)
+		require.Equal(t, "prometheus", res.Responses["C"].Frames[0].Name)
+	})
+
+	t.Run("it returns the errors of a data source in its queries", func(t *testing.T) {
+		tc := setupMixed(nil)
+		tc.pluginContext.errs = map[string]error{"loki": errors.New("boom")}
+
+		res, err := tc.queryService.QueryData(context.Background(), &models.SignedInUser{}, true, mixedRequest("A", "B"), false)
+		require.NoError(t, err)
+
+		require.NoError(t, res.Responses["A"].Error)
+		require.EqualError(t, res.Responses["B"].Error, "Loki: boom")
+	})
+
+	t.Run("it cancels the data sources at the data proxy timeout", func(t *testing.T) {
+		tc := setupMixed(&setting.Cfg{DataProxyTimeout: 1})
+		tc.pluginContext.blocked = map[string]bool{"loki": true}
+
+		res, err := tc.queryService.QueryData(context.Background(), &models.SignedInUser{}, true, mixedRequest("A", "B"), false)
+		require.NoError(t, err)
+
+		require.NoError(t, res.Responses["A"].Error)
+		require.ErrorIs(t, res.Responses["B"].Error, context.DeadlineExceeded)
+		require.Contains(t, res.Responses["B"].Error.Error(), "timed out after 1s")
+	})
+
+	t.Run("it gives every data source the deadline of its own timeout", func(t *testing.T) {
+		tc := setupMixed(&setting.Cfg{DataProxyTimeout: 30})
+		tc.dataSourceCache.byUID["loki"].JsonData = simplejson.NewFromAny(map[string]interface{}{"timeout": "120"})
+
+		start := time.Now()
+		_, err := tc.queryService.QueryData(context.Background(), &models.SignedInUser{}, true, mixedRequest("A", "B"), false)
+		require.NoError(t, err)
+
+		require.WithinDuration(t, start.Add(30*time.Second), tc.pluginContext.deadlines["prometheus"], 5*time.Second)
+		require.WithinDuration(t, start.Add(120*time.Second), tc.pluginContext.deadlines["loki"], 5*time.Second)
+	})
+
+	t.Run("it rejects duplicate refIds", func(t *testing.T) {
+		tc := setupMixed(nil)
+
+		_, err := tc.queryService.QueryData(context.Background(), &models.SignedInUser{}, true, mixedRequest("A", "A"), false)
+		var badQuery *query.ErrBadQuery
+		require.ErrorAs(t, err, &badQuery)
+		require.Empty(t, tc.pluginContext.reqs)
+	})
 }
 
 func setup() *testContext {
@@ -120,6 +229,8 @@ func (s *fakeSecretsService) DecryptJsonData(ctx context.Context, sjd map[string
 
 type fakeDataSourceCache struct {
 	ds *models.DataSource
+	// byUID are the data sources returned by UID, instead of ds.
+	byUID map[string]*models.DataSource
 }
 
 func (c *fakeDataSourceCache) GetDatasource(ctx context.Context, datasourceID int64, user *models.SignedInUser, skipCache bool) (*models.DataSource, error) {
@@ -127,16 +238,50 @@ func (c *fakeDataSourceCache) GetDatasource(ctx context.Context, datasourceID in
 }
 
 func (c *fakeDataSourceCache) GetDatasourceByUID(ctx context.Context, datasourceUID string, user *models.SignedInUser, skipCache bool) (*models.DataSource, error) {
+	if c.byUID != nil {
+		return c.byUID[datasourceUID], nil
+	}
 	return c.ds, nil
 }
 
 type fakePluginClient struct {
 	plugins.Client
 
-	req *backend.QueryDataRequest
+	mu   sync.Mutex
+	req  *backend.QueryDataRequest
+	reqs []*backend.QueryDataRequest
+	// errs are the errors of the plugins by ID.
+	errs map[string]error
+	// blocked are the plugins waiting for the context to be done.
+	blocked map[string]bool
+	// deadlines are the deadlines of the contexts of the requests by plugin ID.
+	deadlines map[string]time.Time
 }
 
 func (c *fakePluginClient) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	c.mu.Lock()
 	c.req = req
-	return nil, nil
+	c.reqs = append(c.reqs, req)
+	if deadline, ok := ctx.Deadline(); ok {
+		if c.deadlines == nil {
+			c.deadlines = map[string]time.Time{}
+		}
+		c.deadlines[req.PluginContext.PluginID] = deadline
+	}
+	c.mu.Unlock()
+
+	pluginID := req.PluginContext.PluginID
+	if c.blocked[pluginID] {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if err := c.errs[pluginID]; err != nil {
+		return nil, err
+	}
+
+	res := backend.NewQueryDataResponse()
+	for _, q := range req.Queries {
+		res.Responses[q.RefID] = backend.DataResponse{Frames: data.Frames{data.NewFrame(pluginID)}}
+	}
+	return res, nil
 }
@@ -0,0 +1,153 @@
+// Package resultlimits limits the size of the results of data source queries.
+package resultlimits
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+const (
+	// BytesHeader is the header of the query requests with the bytes limit,
+	// which lets plugins stop reading results beyond it.
+	BytesHeader = "X-Grafana-Result-Bytes-Limit"
+	// CellsHeader is the header of the query requests with the cells limit.
+	CellsHeader = "X-Grafana-Result-Cells-Limit"
+)
+
+// Limits are the budget of the frames of the response of a data source, in
+// estimated bytes and in cells. 0 disables a limit.
+type Limits struct {
+	Bytes int64
+	Cells int64
+}
+
+// Enabled returns whether any limit is set.
+func (l Limits) Enabled() bool {
+	return l.Bytes > 0 || l.Cells > 0
+}
+
+// ForDataSource returns the limits of the data source, the lowest of the
+// server limits, the limits of its organization and the resultBytesLimit and
+// resultCellsLimit of its JSON data, so that organizations and data sources
+// can only lower the limits of the server.
+func ForDataSource(cfg *setting.Cfg, ds *models.DataSource) Limits {
+	var limits Limits
+	if cfg != nil {
+		limits = Limits{Bytes: cfg.DataProxyResultBytesLimit, Cells: cfg.DataProxyResultCellsLimit}
+		limits.Bytes = lowestLimit(limits.Bytes, cfg.DataProxyOrgResultBytesLimits[ds.OrgId])
+		limits.Cells = lowestLimit(limits.Cells, cfg.DataProxyOrgResultCellsLimits[ds.OrgId])
+	}
+	if ds.JsonData != nil {
+		limits.Bytes = lowestLimit(limits.Bytes, ds.JsonData.Get("resultBytesLimit").MustInt64(0))
+		limits.Cells = lowestLimit(limits.Cells, ds.JsonData.Get("resultCellsLimit").MustInt64(0))
+	}
+	return limits
+}
+
+// SetHeaders adds the limits to the headers of a query request.
+func (l Limits) SetHeaders(headers map[string]string) {
+	if l.Bytes > 0 {
+		headers[BytesHeader] = strconv.FormatInt(l.Bytes, 10)
+	}
+	if l.Cells > 0 {
+		headers[CellsHeader] = strconv.FormatInt(l.Cells, 10)
+	}
+}
+
+// FromHeaders returns the limits of the headers of a query request.
+func FromHeaders(headers map[string]string) Limits {
+	var limits Limits
+	limits.Bytes, _ = strconv.ParseInt(headers[BytesHeader], 10, 64)
+	limits.Cells, _ = strconv.ParseInt(headers[CellsHeader], 10, 64)
+	return limits
+}
+
+func lowestLimit(a, b int64) int64 {
+	if a <= 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// Truncate cuts the rows of the frames of the response beyond the limits, in
+// the order of the queries, and adds a warning notice to the frames it cuts.
+func (l Limits) Truncate(res *backend.QueryDataResponse, queries []backend.DataQuery) {
+	var bytes, cells int64
+	// reason is the limit reached, after which the frames get no rows.
+	var reason string
+	for _, q := range queries {
+		for _, frame := range res.Responses[q.RefID].Frames {
+			rows := frame.Rows()
+			kept := 0
+			for ; reason == "" && kept < rows; kept++ {
+				rowCells := int64(len(frame.Fields))
+				if l.Cells > 0 && cells+rowCells > l.Cells {
+					reason = fmt.Sprintf("%v cells", l.Cells)
+					break
+				}
+				rowBytes := rowSize(frame, kept)
+				if l.Bytes > 0 && bytes+rowBytes > l.Bytes {
+					reason = fmt.Sprintf("%v bytes", l.Bytes)
+					break
+				}
+				cells += rowCells
+				bytes += rowBytes
+			}
+			if kept == rows {
+				continue
+			}
+
+			truncateFrame(frame, kept)
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text: fmt.Sprintf("Results have been limited to %v rows because the query result limit of %s was reached",
+					kept, reason),
+			})
+		}
+	}
+}
+
+func truncateFrame(frame *data.Frame, rows int) {
+	for i, field := range frame.Fields {
+		truncated := data.NewFieldFromFieldType(field.Type(), rows)
+		truncated.Name = field.Name
+		truncated.Labels = field.Labels
+		truncated.Config = field.Config
+		for row := 0; row < rows; row++ {
+			truncated.Set(row, field.At(row))
+		}
+		frame.Fields[i] = truncated
+	}
+}
+
+// rowSize estimates the size in bytes of the values of a row.
+func rowSize(frame *data.Frame, row int) int64 {
+	var size int64
+	for _, field := range frame.Fields {
+		v, ok := field.ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			size += int64(len(v))
+		case json.RawMessage:
+			size += int64(len(v))
+		default:
+			// Times are 8 bytes, like the other fixed size values.
+			if n := binary.Size(v); n > 0 {
+				size += int64(n)
+			} else {
+				size += 8
+			}
+		}
+	}
+	return size
+}
@@ -0,0 +1,113 @@
+package resultlimits
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultLimits(t *testing.T) {
+	t.Run("data sources can only lower the limits of the server", func(t *testing.T) {
+		cfg := &setting.Cfg{DataProxyResultBytesLimit: 1000}
+
+		ds := &models.DataSource{JsonData: simplejson.NewFromAny(map[string]interface{}{
+			"resultBytesLimit": 2000,
+			"resultCellsLimit": 10,
+		})}
+		require.Equal(t, Limits{Bytes: 1000, Cells: 10}, ForDataSource(cfg, ds))
+
+		ds.JsonData.Set("resultBytesLimit", 500)
+		require.Equal(t, Limits{Bytes: 500, Cells: 10}, ForDataSource(cfg, ds))
+
+		require.Equal(t, Limits{}, ForDataSource(nil, &models.DataSource{}))
+	})
+
+	t.Run("organizations can only lower the limits of the server", func(t *testing.T) {
+		cfg := &setting.Cfg{
+			DataProxyResultBytesLimit:     1000,
+			DataProxyOrgResultBytesLimits: map[int64]int64{2: 2000, 3: 300},
+			DataProxyOrgResultCellsLimits: map[int64]int64{3: 20},
+		}
+
+		require.Equal(t, Limits{Bytes: 1000}, ForDataSource(cfg, &models.DataSource{OrgId: 1}))
+		require.Equal(t, Limits{Bytes: 1000}, ForDataSource(cfg, &models.DataSource{OrgId: 2}))
+		require.Equal(t, Limits{Bytes: 300, Cells: 20}, ForDataSource(cfg, &models.DataSource{OrgId: 3}))
+
+		ds := &models.DataSource{OrgId: 3, JsonData: simplejson.NewFromAny(map[string]interface{}{
+			"resultCellsLimit": 10,
+		})}
+		require.Equal(t, Limits{Bytes: 300, Cells: 10}, ForDataSource(cfg, ds))
+	})
+
+	t.Run("sends the limits in headers", func(t *testing.T) {
+		headers := map[string]string{}
+		Limits{Cells: 10}.SetHeaders(headers)
+		require.Equal(t, map[string]string{CellsHeader: "10"}, headers)
+		require.Equal(t, Limits{Cells: 10}, FromHeaders(headers))
+
+		Limits{Bytes: 100, Cells: 10}.SetHeaders(headers)
+		require.Equal(t, Limits{Bytes: 100, Cells: 10}, FromHeaders(headers))
+		require.Equal(t, Limits{}, FromHeaders(nil))
+	})
+
+	newResponse := func() *backend.QueryDataResponse {
+		res := backend.NewQueryDataResponse()
+		res.Responses["A"] = backend.DataResponse{Frames: data.Frames{
+			data.NewFrame("a",
+				data.NewField("value", data.Labels{"host": "a"}, []float64{1, 2, 3}),
+				data.NewField("name", nil, []*string{strPtr("abcd"), nil, strPtr("ef")}),
+			),
+		}}
+		res.Responses["B"] = backend.DataResponse{Frames: data.Frames{
+			data.NewFrame("b", data.NewField("value", nil, []int32{1, 2})),
+		}}
+		return res
+	}
+	queries := []backend.DataQuery{{RefID: "A"}, {RefID: "B"}}
+
+	t.Run("keeps the frames within the limits", func(t *testing.T) {
+		res := newResponse()
+		Limits{Bytes: 1000, Cells: 8}.Truncate(res, queries)
+
+		require.Equal(t, 3, res.Responses["A"].Frames[0].Rows())
+		require.Equal(t, 2, res.Responses["B"].Frames[0].Rows())
+		require.Nil(t, res.Responses["A"].Frames[0].Meta)
+	})
+
+	t.Run("truncates the frames beyond the cells limit", func(t *testing.T) {
+		res := newResponse()
+		Limits{Cells: 5}.Truncate(res, queries)
+
+		a := res.Responses["A"].Frames[0]
+		require.Equal(t, 2, a.Rows())
+		require.Equal(t, data.Labels{"host": "a"}, a.Fields[0].Labels)
+		require.Equal(t, "abcd", *a.Fields[1].At(0).(*string))
+		require.Equal(t, "Results have been limited to 2 rows because the query result limit of 5 cells was reached",
+			a.Meta.Notices[0].Text)
+
+		b := res.Responses["B"].Frames[0]
+		require.Equal(t, 0, b.Rows())
+		require.Len(t, b.Meta.Notices, 1)
+	})
+
+	t.Run("truncates the frames beyond the bytes limit", func(t *testing.T) {
+		res := newResponse()
+		// The rows of A are 12, 8 and 10 bytes, and the ones of B 4 bytes.
+		Limits{Bytes: 34}.Truncate(res, queries)
+
+		require.Equal(t, 3, res.Responses["A"].Frames[0].Rows())
+		b := res.Responses["B"].Frames[0]
+		require.Equal(t, 1, b.Rows())
+		require.Equal(t, data.NoticeSeverityWarning, b.Meta.Notices[0].Severity)
+		require.Contains(t, b.Meta.Notices[0].Text, "limit of 34 bytes")
+	})
+}
+
+func strPtr(s string) *string {
+	return &s
+}
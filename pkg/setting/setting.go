@@ -292,6 +292,7 @@ type Cfg struct {
 	AWSAllowedAuthProviders []string
 	AWSAssumeRoleEnabled    bool
 	AWSListMetricsPageLimit int
+	AWSLogsQueryConcurrency int
 
 	// Azure Cloud settings
 	Azure AzureSettings
@@ -334,6 +335,11 @@ type Cfg struct {
 	DataProxyIdleConnTimeout       int
 	ResponseLimit                  int64
 	DataProxyRowLimit              int64
+	DataProxyRowBytesLimit         int64
+	DataProxyResultBytesLimit      int64
+	DataProxyResultCellsLimit      int64
+	DataProxyOrgResultBytesLimits  map[int64]int64
+	DataProxyOrgResultCellsLimits  map[int64]int64
 
 	// DistributedCache
 	RemoteCacheOptions *RemoteCacheOptions
@@ -368,6 +374,8 @@ type Cfg struct {
 
 	// Data sources
 	DataSourceLimit int
+	// DataSourceTLSFilesPath is the directory data sources may read TLS files from.
+	DataSourceTLSFilesPath string
 
 	// Snapshots
 	SnapshotPublicMode bool
@@ -1058,6 +1066,7 @@ func (cfg *Cfg) handleAWSConfig() {
 		}
 	}
 	cfg.AWSListMetricsPageLimit = awsPluginSec.Key("list_metrics_page_limit").MustInt(500)
+	cfg.AWSLogsQueryConcurrency = awsPluginSec.Key("logs_query_concurrency").MustInt(30)
 	// Also set environment variables that can be used by core plugins
 	err := os.Setenv(awsds.AssumeRoleEnabledEnvVarKeyName, strconv.FormatBool(cfg.AWSAssumeRoleEnabled))
 	if err != nil {
@@ -1496,6 +1505,7 @@ func (cfg *Cfg) GetContentDeliveryURL(prefix string) string {
 func (cfg *Cfg) readDataSourcesSettings() {
 	datasources := cfg.Raw.Section("datasources")
 	cfg.DataSourceLimit = datasources.Key("datasource_limit").MustInt(5000)
+	cfg.DataSourceTLSFilesPath = datasources.Key("tls_files_path").MustString("")
 }
 
 func GetAllowedOriginGlobs(originPatterns []string) ([]glob.Glob, error) {
@@ -1,6 +1,12 @@
 package setting
 
-import "gopkg.in/ini.v1"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
 
 const defaultDataProxyRowLimit = int64(1000000)
 
@@ -22,6 +28,17 @@ func readDataProxySettings(iniFile *ini.File, cfg *Cfg) error {
 	if cfg.DataProxyRowLimit <= 0 {
 		cfg.DataProxyRowLimit = defaultDataProxyRowLimit
 	}
+	cfg.DataProxyRowBytesLimit = dataproxy.Key("row_bytes_limit").MustInt64(0)
+	cfg.DataProxyResultBytesLimit = dataproxy.Key("result_bytes_limit").MustInt64(0)
+	cfg.DataProxyResultCellsLimit = dataproxy.Key("result_cells_limit").MustInt64(0)
+
+	var err error
+	if cfg.DataProxyOrgResultBytesLimits, err = readOrgLimits(dataproxy.Key("org_result_bytes_limits").String()); err != nil {
+		return fmt.Errorf("invalid org_result_bytes_limits: %w", err)
+	}
+	if cfg.DataProxyOrgResultCellsLimits, err = readOrgLimits(dataproxy.Key("org_result_cells_limits").String()); err != nil {
+		return fmt.Errorf("invalid org_result_cells_limits: %w", err)
+	}
 
 	if val, err := dataproxy.Key("max_idle_connections_per_host").Int(); err == nil {
 		cfg.Logger.Warn("[Deprecated] the configuration setting 'max_idle_connections_per_host' is deprecated, please use 'max_idle_connections' instead")
@@ -30,3 +47,29 @@ func readDataProxySettings(iniFile *ini.File, cfg *Cfg) error {
 
 	return nil
 }
+
+// readOrgLimits reads limits of organizations, a comma separated list of
+// <org id>:<limit>, e.g. 1:1000,2:500.
+func readOrgLimits(value string) (map[int64]int64, error) {
+	limits := map[int64]int64{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not <org id>:<limit>", entry)
+		}
+		orgID, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid org id in %q", entry)
+		}
+		limit, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit in %q", entry)
+		}
+		limits[orgID] = limit
+	}
+	return limits, nil
+}
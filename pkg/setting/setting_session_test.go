@@ -26,6 +26,7 @@ func (stub *testLogger) Info(testMessage string, ctx ...interface{}) {
 
 func TestSessionSettings(t *testing.T) {
 	skipStaticRootValidation = true
+	t.Setenv("GF_PATHS_LOGS", t.TempDir())
 
 	t.Run("Reading session should log error ", func(t *testing.T) {
 		cfg := NewCfg()
@@ -24,6 +24,8 @@ const (
 
 func TestLoadingSettings(t *testing.T) {
 	skipStaticRootValidation = true
+	logsPath := t.TempDir()
+	t.Setenv("GF_PATHS_LOGS", logsPath)
 
 	t.Run("Given the default ini files", func(t *testing.T) {
 		cfg := NewCfg()
@@ -73,7 +75,7 @@ func TestLoadingSettings(t *testing.T) {
 
 		require.Equal(t, "superduper", cfg.AdminUser)
 		require.Equal(t, filepath.Join(HomePath, "data"), cfg.DataPath)
-		require.Equal(t, filepath.Join(cfg.DataPath, "log"), cfg.LogsPath)
+		require.Equal(t, logsPath, cfg.LogsPath)
 	})
 
 	t.Run("Should replace password when defined in environment", func(t *testing.T) {
@@ -10,6 +10,7 @@ import (
 )
 
 func TestCfg_ReadUnifiedAlertingSettings(t *testing.T) {
+	t.Setenv("GF_PATHS_LOGS", t.TempDir())
 	cfg := NewCfg()
 	err := cfg.Load(CommandLineArgs{HomePath: "../../", Config: "../../conf/defaults.ini"})
 	require.NoError(t, err)
@@ -0,0 +1,182 @@
+package azuremonitor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// crossResourceColumn is the column cross-resource queries add to the rows of
+// every resource, holding the resource they come from.
+const crossResourceColumn = "SourceResource"
+
+// crossResourceLabel is the label of the fields of time series with the
+// resource they come from.
+const crossResourceLabel = "resource"
+
+// crossResourceTableExpr matches the table a tabular expression starts with,
+// e.g. Perf in "Perf | where CounterName == 'x'".
+var crossResourceTableExpr = regexp.MustCompile(`^(\s*)([A-Za-z_][A-Za-z_0-9]*)(\s*(\|[\s\S]*)?)$`)
+
+// crossResourceQuery rewrites the KQL query so that it runs against all of the
+// resources in a single request: the table the query starts with is replaced
+// by the union of that table in every resource, tagged with the resource, e.g.
+//
+//	union (workspace("ws1").Perf | extend SourceResource = "ws1"), (workspace("ws2").Perf | extend SourceResource = "ws2") | where ...
+//
+// The results are split by the SourceResource column, see
+// splitCrossResourceFrame. Queries aggregating over resources without keeping
+// the column, e.g. with "summarize count()" instead of
+// "summarize count() by SourceResource", return the merged results.
+func crossResourceQuery(query string, resources []string) (string, error) {
+	lets, expr := splitLastStatement(query)
+	match := crossResourceTableExpr.FindStringSubmatch(expr)
+	if match == nil {
+		return "", fmt.Errorf("queries of multiple resources must start with a table name, e.g. \"Perf | where ...\"")
+	}
+
+	tables := make([]string, 0, len(resources))
+	for _, resource := range resources {
+		tables = append(tables, fmt.Sprintf("(%s(%s).%s | extend %s = %s)",
+			crossResourceFunction(resource), strconv.Quote(resource), match[2], crossResourceColumn, strconv.Quote(resource)))
+	}
+	return lets + match[1] + "union " + strings.Join(tables, ", ") + match[3], nil
+}
+
+// crossResourceFunction returns the KQL function that references the tables of
+// a resource in another workspace, application or resource.
+func crossResourceFunction(resource string) string {
+	resource = strings.ToLower(resource)
+	switch {
+	case !strings.HasPrefix(resource, "/subscriptions/"),
+		strings.Contains(resource, "/providers/microsoft.operationalinsights/workspaces/"):
+		return "workspace"
+	case strings.Contains(resource, "/providers/microsoft.insights/components/"):
+		return "app"
+	default:
+		return "resource"
+	}
+}
+
+// splitLastStatement splits the query after the last ";" outside of string
+// literals, so that let statements are kept in front of the tabular expression.
+func splitLastStatement(query string) (string, string) {
+	last := -1
+	var quote rune
+	escaped := false
+	for i, r := range query {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ';':
+			last = i
+		}
+	}
+	return query[:last+1], query[last+1:]
+}
+
+// splitCrossResourceFrame splits the frame of a cross-resource query into a
+// frame per resource, in the order the resources first appear, without the
+// SourceResource column. The resource of a frame is set in its metadata. The
+// frame is returned as it is when it has no SourceResource column.
+func splitCrossResourceFrame(frame *data.Frame) data.Frames {
+	idx := -1
+	for i, field := range frame.Fields {
+		if field.Name == crossResourceColumn && field.Type() == data.FieldTypeNullableString {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return data.Frames{frame}
+	}
+
+	rows := map[string][]int{}
+	resources := []string{}
+	for i := 0; i < frame.Rows(); i++ {
+		resource := ""
+		if v := frame.Fields[idx].At(i).(*string); v != nil {
+			resource = *v
+		}
+		if _, ok := rows[resource]; !ok {
+			resources = append(resources, resource)
+		}
+		rows[resource] = append(rows[resource], i)
+	}
+
+	frames := make(data.Frames, 0, len(resources))
+	for _, resource := range resources {
+		split := data.NewFrame(frame.Name)
+		split.RefID = frame.RefID
+		for i, field := range frame.Fields {
+			if i == idx {
+				continue
+			}
+			f := data.NewFieldFromFieldType(field.Type(), 0)
+			f.Name = field.Name
+			f.Labels = field.Labels
+			f.Config = field.Config
+			for _, row := range rows[resource] {
+				f.Append(field.At(row))
+			}
+			split.Fields = append(split.Fields, f)
+		}
+		split.Meta = crossResourceMeta(frame.Meta, idx, resource)
+		frames = append(frames, split)
+	}
+	return frames
+}
+
+// crossResourceMeta returns a copy of the metadata of a cross-resource frame
+// for the frame of the resource, without the column at idx.
+func crossResourceMeta(meta *data.FrameMeta, idx int, resource string) *data.FrameMeta {
+	if meta == nil {
+		return nil
+	}
+	copied := *meta
+	if la, ok := meta.Custom.(*LogAnalyticsMeta); ok {
+		laCopy := *la
+		laCopy.ColumnTypes = make([]string, 0, len(la.ColumnTypes))
+		for i, t := range la.ColumnTypes {
+			if i != idx {
+				laCopy.ColumnTypes = append(laCopy.ColumnTypes, t)
+			}
+		}
+		laCopy.Resource = resource
+		copied.Custom = &laCopy
+	}
+	return &copied
+}
+
+// labelCrossResourceFields adds the resource of a frame of a cross-resource
+// query to the labels of its value fields.
+func labelCrossResourceFields(frame *data.Frame) {
+	if frame.Meta == nil {
+		return
+	}
+	la, ok := frame.Meta.Custom.(*LogAnalyticsMeta)
+	if !ok || la.Resource == "" {
+		return
+	}
+	for _, field := range frame.Fields {
+		if t := field.Type(); t == data.FieldTypeTime || t == data.FieldTypeNullableTime {
+			continue
+		}
+		labels := data.Labels{}
+		for k, v := range field.Labels {
+			labels[k] = v
+		}
+		labels[crossResourceLabel] = la.Resource
+		field.Labels = labels
+	}
+}
@@ -37,6 +37,8 @@ type AzureLogAnalyticsQuery struct {
 	Params       url.Values
 	Target       string
 	TimeRange    backend.TimeRange
+	// CrossResource is set when the query runs against multiple resources.
+	CrossResource bool
 }
 
 func (e *AzureLogAnalyticsDatasource) resourceRequest(rw http.ResponseWriter, req *http.Request, cli *http.Client) {
@@ -73,6 +75,8 @@ func getApiURL(queryJSONModel logJSONQuery) string {
 
 	if azureLogAnalyticsTarget.Resource != "" {
 		resourceOrWorkspace = azureLogAnalyticsTarget.Resource
+	} else if len(azureLogAnalyticsTarget.Resources) > 0 {
+		resourceOrWorkspace = azureLogAnalyticsTarget.Resources[0]
 	} else {
 		resourceOrWorkspace = azureLogAnalyticsTarget.Workspace
 	}
@@ -111,6 +115,12 @@ func (e *AzureLogAnalyticsDatasource) buildQueries(queries []backend.DataQuery,
 		if err != nil {
 			return nil, err
 		}
+		if len(azureLogAnalyticsTarget.Resources) > 1 {
+			rawQuery, err = crossResourceQuery(rawQuery, azureLogAnalyticsTarget.Resources)
+			if err != nil {
+				return nil, err
+			}
+		}
 		params.Add("query", rawQuery)
 
 		azureLogAnalyticsQueries = append(azureLogAnalyticsQueries, &AzureLogAnalyticsQuery{
@@ -121,6 +131,8 @@ func (e *AzureLogAnalyticsDatasource) buildQueries(queries []backend.DataQuery,
 			Params:       params,
 			Target:       params.Encode(),
 			TimeRange:    query.TimeRange,
+
+			CrossResource: len(azureLogAnalyticsTarget.Resources) > 1,
 		})
 	}
 
@@ -204,19 +216,30 @@ func (e *AzureLogAnalyticsDatasource) executeQuery(ctx context.Context, query *A
 		azlog.Warn("failed to add custom metadata to azure log analytics response", err)
 	}
 
-	if query.ResultFormat == timeSeries {
-		tsSchema := frame.TimeSeriesSchema()
-		if tsSchema.Type == data.TimeSeriesTypeLong {
-			wideFrame, err := data.LongToWide(frame, nil)
-			if err == nil {
-				frame = wideFrame
-			} else {
-				frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: "could not convert frame to time series, returning raw table: " + err.Error()})
+	frames := data.Frames{frame}
+	if query.CrossResource {
+		frames = splitCrossResourceFrame(frame)
+	}
+
+	for i, frame := range frames {
+		if query.ResultFormat == timeSeries {
+			tsSchema := frame.TimeSeriesSchema()
+			if tsSchema.Type == data.TimeSeriesTypeLong {
+				wideFrame, err := data.LongToWide(frame, nil)
+				if err == nil {
+					frame = wideFrame
+				} else {
+					frame.AppendNotices(data.Notice{Severity: data.NoticeSeverityWarning, Text: "could not convert frame to time series, returning raw table: " + err.Error()})
+				}
+			}
+			if query.CrossResource {
+				labelCrossResourceFields(frame)
 			}
 		}
+		frames[i] = frame
 	}
 
-	dataResponse.Frames = data.Frames{frame}
+	dataResponse.Frames = frames
 	return dataResponse
 }
 
@@ -276,7 +299,9 @@ type LogAnalyticsMeta struct {
 	ColumnTypes  []string `json:"azureColumnTypes"`
 	Subscription string   `json:"subscription"`
 	Workspace    string   `json:"workspace"`
-	EncodedQuery []byte   `json:"encodedQuery"` // EncodedQuery is used for deep links.
+	// Resource is the resource of the rows of a frame of a cross-resource query.
+	Resource     string `json:"resource,omitempty"`
+	EncodedQuery []byte `json:"encodedQuery"` // EncodedQuery is used for deep links.
 }
 
 func setAdditionalFrameMeta(frame *data.Frame, query, subscriptionID, workspace string) error {
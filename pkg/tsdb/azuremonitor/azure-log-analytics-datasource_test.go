@@ -11,6 +11,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/stretchr/testify/require"
 )
@@ -180,6 +181,134 @@ func TestBuildingAzureLogAnalyticsQueries(t *testing.T) {
 	}
 }
 
+func TestBuildingCrossResourceAzureLogAnalyticsQueries(t *testing.T) {
+	datasource := &AzureLogAnalyticsDatasource{}
+	queries, err := datasource.buildQueries([]backend.DataQuery{
+		{
+			RefID: "A",
+			JSON: []byte(`{
+				"queryType": "Azure Log Analytics",
+				"azureLogAnalytics": {
+					"resources": ["/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws1", "ws2-guid"],
+					"query":     "Perf | summarize count() by SourceResource"
+				}
+			}`),
+		},
+		{
+			RefID: "B",
+			JSON: []byte(`{
+				"queryType": "Azure Log Analytics",
+				"azureLogAnalytics": {
+					"resources": ["/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws1"],
+					"query":     "Perf"
+				}
+			}`),
+		},
+	}, datasourceInfo{})
+	require.NoError(t, err)
+
+	require.Len(t, queries, 2)
+	require.Equal(t, "v1/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws1/query", queries[0].URL)
+	require.Equal(t, `union (workspace("/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws1").Perf | extend SourceResource = "/subscriptions/sub1/resourceGroups/rg/providers/Microsoft.OperationalInsights/workspaces/ws1"), (workspace("ws2-guid").Perf | extend SourceResource = "ws2-guid") | summarize count() by SourceResource`, queries[0].Params.Get("query"))
+	require.Equal(t, timeSeries, queries[0].ResultFormat)
+	require.True(t, queries[0].CrossResource)
+	require.Equal(t, queries[0].URL, queries[1].URL)
+	require.Equal(t, "Perf", queries[1].Params.Get("query"))
+	require.False(t, queries[1].CrossResource)
+
+	_, err = datasource.buildQueries([]backend.DataQuery{{
+		RefID: "A",
+		JSON:  []byte(`{"azureLogAnalytics": {"resources": ["ws1", "ws2"], "query": "print 1"}}`),
+	}}, datasourceInfo{})
+	require.EqualError(t, err, `queries of multiple resources must start with a table name, e.g. "Perf | where ..."`)
+}
+
+func TestCrossResourceQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		resources []string
+		expected  string
+	}{
+		{
+			name:      "table",
+			query:     "Heartbeat",
+			resources: []string{"ws1", "ws2"},
+			expected:  `union (workspace("ws1").Heartbeat | extend SourceResource = "ws1"), (workspace("ws2").Heartbeat | extend SourceResource = "ws2")`,
+		},
+		{
+			name:      "resources and applications",
+			query:     "requests\n| take 10",
+			resources: []string{"/subscriptions/s/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm", "/subscriptions/s/resourceGroups/rg/providers/microsoft.insights/components/app"},
+			expected: `union (resource("/subscriptions/s/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm").requests | extend SourceResource = "/subscriptions/s/resourceGroups/rg/providers/Microsoft.Compute/virtualMachines/vm"), ` +
+				`(app("/subscriptions/s/resourceGroups/rg/providers/microsoft.insights/components/app").requests | extend SourceResource = "/subscriptions/s/resourceGroups/rg/providers/microsoft.insights/components/app")` + "\n| take 10",
+		},
+		{
+			name:      "let statements",
+			query:     "let name = 'a;b';\nPerf | where Computer == name",
+			resources: []string{"ws1", "ws2"},
+			expected:  "let name = 'a;b';\n" + `union (workspace("ws1").Perf | extend SourceResource = "ws1"), (workspace("ws2").Perf | extend SourceResource = "ws2") | where Computer == name`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := crossResourceQuery(tt.query, tt.resources)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, query)
+		})
+	}
+}
+
+func TestSplitCrossResourceFrame(t *testing.T) {
+	t1 := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Minute)
+	str := func(s string) *string { return &s }
+	num := func(f float64) *float64 { return &f }
+	tm := func(t time.Time) *time.Time { return &t }
+
+	frame := data.NewFrame("",
+		data.NewField("TimeGenerated", nil, []*time.Time{tm(t1), tm(t1), tm(t2), tm(t2)}),
+		data.NewField("SourceResource", nil, []*string{str("ws2"), str("ws1"), str("ws2"), str("ws1")}),
+		data.NewField("count_", nil, []*float64{num(1), num(2), num(3), num(4)}),
+	)
+	frame.RefID = "A"
+	frame.Meta = &data.FrameMeta{Custom: &LogAnalyticsMeta{ColumnTypes: []string{"datetime", "string", "long"}, Workspace: "ws1"}}
+
+	t.Run("splits the rows by resource", func(t *testing.T) {
+		frames := splitCrossResourceFrame(frame)
+		require.Len(t, frames, 2)
+		for i, resource := range []string{"ws2", "ws1"} {
+			require.Equal(t, "A", frames[i].RefID)
+			require.Len(t, frames[i].Fields, 2)
+			require.Equal(t, "TimeGenerated", frames[i].Fields[0].Name)
+			require.Equal(t, "count_", frames[i].Fields[1].Name)
+			meta := frames[i].Meta.Custom.(*LogAnalyticsMeta)
+			require.Equal(t, resource, meta.Resource)
+			require.Equal(t, []string{"datetime", "long"}, meta.ColumnTypes)
+		}
+		require.Equal(t, 3.0, *frames[0].Fields[1].At(1).(*float64))
+		require.Equal(t, 4.0, *frames[1].Fields[1].At(1).(*float64))
+		require.Equal(t, "", frame.Meta.Custom.(*LogAnalyticsMeta).Resource)
+		require.Len(t, frame.Fields, 3)
+	})
+
+	t.Run("labels the time series with the resource", func(t *testing.T) {
+		frames := splitCrossResourceFrame(frame)
+		for i, resource := range []string{"ws2", "ws1"} {
+			labelCrossResourceFields(frames[i])
+			require.Nil(t, frames[i].Fields[0].Labels)
+			require.Equal(t, data.Labels{"resource": resource}, frames[i].Fields[1].Labels)
+		}
+	})
+
+	t.Run("keeps frames without the resource column", func(t *testing.T) {
+		merged := data.NewFrame("", data.NewField("count_", nil, []*float64{num(10)}))
+		frames := splitCrossResourceFrame(merged)
+		require.Len(t, frames, 1)
+		require.Same(t, merged, frames[0])
+	})
+}
+
 func TestLogAnalyticsCreateRequest(t *testing.T) {
 	ctx := context.Background()
 	url := "http://ds"
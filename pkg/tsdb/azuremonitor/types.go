@@ -142,6 +142,8 @@ type logJSONQuery struct {
 		Query        string `json:"query"`
 		ResultFormat string `json:"resultFormat"`
 		Resource     string `json:"resource"`
+		// Resources are the resources of a cross-resource query.
+		Resources []string `json:"resources"`
 
 		// Deprecated: Queries should be migrated to use Resource instead
 		Workspace string `json:"workspace"`
@@ -63,6 +63,7 @@ const (
 	metricQueryType           string = "metrics"
 	sloQueryType              string = "slo"
 	mqlEditorMode             string = "mql"
+	promQLEditorMode          string = "promql"
 	crossSeriesReducerDefault string = "REDUCE_NONE"
 	perSeriesAlignerDefault   string = "ALIGN_MEAN"
 )
@@ -322,7 +323,16 @@ func (s *Service) buildQueryExecutors(req *backend.QueryDataRequest) ([]cloudMon
 		}
 		switch q.QueryType {
 		case metricQueryType:
-			if q.MetricQuery.EditorMode == mqlEditorMode {
+			if q.MetricQuery.EditorMode == promQLEditorMode {
+				queryInterface = &cloudMonitoringPromQLQuery{
+					RefID:       query.RefID,
+					ProjectName: q.MetricQuery.ProjectName,
+					Query:       q.MetricQuery.Query,
+					IntervalMS:  query.Interval.Milliseconds(),
+					AliasBy:     q.MetricQuery.AliasBy,
+					timeRange:   req.Queries[0].TimeRange,
+				}
+			} else if q.MetricQuery.EditorMode == mqlEditorMode {
 				queryInterface = &cloudMonitoringTimeSeriesQuery{
 					RefID:       query.RefID,
 					ProjectName: q.MetricQuery.ProjectName,
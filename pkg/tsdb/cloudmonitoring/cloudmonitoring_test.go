@@ -533,6 +533,27 @@ func TestCloudMonitoring(t *testing.T) {
 			assert.Equal(t, "test-proj", tqueries[0].ProjectName)
 			assert.Equal(t, "test-query", tqueries[0].Query)
 			assert.Equal(t, "test-alias", tqueries[0].AliasBy)
+
+			req.Queries[0].JSON = json.RawMessage(`{
+				"queryType": "metrics",
+				 "metricQuery": {
+					"editorMode":  "promql",
+					"projectName": "test-proj",
+					"query":       "rate(test_metric[5m])",
+					"aliasBy":     "test-alias"
+				},
+				"sloQuery": {}
+			}`)
+
+			qes, err = service.buildQueryExecutors(req)
+			require.NoError(t, err)
+			require.Len(t, qes, 1)
+			pq, ok := qes[0].(*cloudMonitoringPromQLQuery)
+			require.True(t, ok)
+			assert.Equal(t, "A", pq.RefID)
+			assert.Equal(t, "test-proj", pq.ProjectName)
+			assert.Equal(t, "rate(test_metric[5m])", pq.Query)
+			assert.Equal(t, "test-alias", pq.AliasBy)
 		})
 
 		t.Run("and query type is SLOs", func(t *testing.T) {
@@ -0,0 +1,260 @@
+package cloudmonitoring
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+)
+
+const promQLMetricNameLabel = "__name__"
+
+func (promQLQuery cloudMonitoringPromQLQuery) run(ctx context.Context, req *backend.QueryDataRequest,
+	s *Service, dsInfo datasourceInfo, tracer tracing.Tracer) (*backend.DataResponse, cloudMonitoringResponse, string, error) {
+	dr := &backend.DataResponse{}
+	projectName := promQLQuery.ProjectName
+
+	if projectName == "" {
+		var err error
+		projectName, err = s.getDefaultProject(ctx, dsInfo)
+		if err != nil {
+			dr.Error = err
+			return dr, cloudMonitoringResponse{}, "", nil
+		}
+		slog.Info("No project name set on query, using project name from datasource", "projectName", projectName)
+	}
+
+	intervalCalculator := intervalv2.NewCalculator(intervalv2.CalculatorOptions{})
+	interval := intervalCalculator.Calculate(promQLQuery.timeRange, time.Duration(promQLQuery.IntervalMS/1000)*time.Second, req.Queries[0].MaxDataPoints)
+	step := math.Max(interval.Value.Seconds(), 1)
+
+	r, err := s.createRequest(ctx, &dsInfo, path.Join("/v1/projects", projectName, "location/global/prometheus/api/v1/query_range"), nil)
+	if err != nil {
+		dr.Error = err
+		return dr, cloudMonitoringResponse{}, "", nil
+	}
+	params := url.Values{}
+	params.Set("query", promQLQuery.Query)
+	params.Set("start", strconv.FormatInt(promQLQuery.timeRange.From.Unix(), 10))
+	params.Set("end", strconv.FormatInt(promQLQuery.timeRange.To.Unix(), 10))
+	params.Set("step", strconv.FormatFloat(step, 'f', -1, 64))
+	r.URL.RawQuery = params.Encode()
+
+	ctx, span := tracer.Start(ctx, "cloudMonitoring PromQL query")
+	span.SetAttributes("query", promQLQuery.Query, attribute.Key("query").String(promQLQuery.Query))
+	span.SetAttributes("from", promQLQuery.timeRange.From, attribute.Key("from").String(promQLQuery.timeRange.From.String()))
+	span.SetAttributes("until", promQLQuery.timeRange.To, attribute.Key("until").String(promQLQuery.timeRange.To.String()))
+
+	defer span.End()
+	tracer.Inject(ctx, r.Header, span)
+
+	r = r.WithContext(ctx)
+	res, err := dsInfo.services[cloudMonitor].client.Do(r)
+	if err != nil {
+		dr.Error = err
+		return dr, cloudMonitoringResponse{}, "", nil
+	}
+
+	d, err := unmarshalResponse(res)
+	if err != nil {
+		dr.Error = err
+		return dr, cloudMonitoringResponse{}, "", nil
+	}
+
+	return dr, d, promQLQuery.Query, nil
+}
+
+func (promQLQuery cloudMonitoringPromQLQuery) parseResponse(queryRes *backend.DataResponse,
+	response cloudMonitoringResponse, executedQueryString string) error {
+	if response.PromQLData.ResultType != "" && response.PromQLData.ResultType != "matrix" {
+		return fmt.Errorf("unexpected PromQL result type %q", response.PromQLData.ResultType)
+	}
+
+	frames := data.Frames{}
+	for _, series := range response.PromQLData.Result {
+		metricName := series.Metric[promQLMetricNameLabel]
+		seriesLabels := make(map[string]string, len(series.Metric))
+		for name, value := range series.Metric {
+			if name != promQLMetricNameLabel {
+				seriesLabels[name] = value
+			}
+		}
+
+		frame := data.NewFrameOfFieldTypes("", 0, data.FieldTypeTime, data.FieldTypeFloat64)
+		frame.RefID = promQLQuery.RefID
+		frame.Meta = &data.FrameMeta{
+			ExecutedQueryString: executedQueryString,
+			Custom: map[string]interface{}{
+				"labels": seriesLabels,
+			},
+		}
+		for _, sample := range series.Values {
+			t, value, err := sample.parse()
+			if err != nil {
+				return err
+			}
+			frame.AppendRow(t, value)
+		}
+
+		legendLabels := make(map[string]string, len(seriesLabels)+1)
+		for name, value := range seriesLabels {
+			legendLabels[name] = value
+		}
+		legendLabels["metric.name"] = metricName
+
+		dataField := frame.Fields[1]
+		dataField.Name = formatLegendKeys(metricName, promQLSeriesName(metricName, seriesLabels), legendLabels, nil,
+			&cloudMonitoringTimeSeriesFilter{
+				ProjectName: promQLQuery.ProjectName, AliasBy: promQLQuery.AliasBy,
+			})
+		dataField.Labels = seriesLabels
+		setDisplayNameAsFieldName(dataField)
+
+		frames = append(frames, frame)
+	}
+	if len(frames) > 0 {
+		dl := promQLQuery.buildDeepLink()
+		frames = addConfigData(frames, dl, response.Unit)
+	}
+
+	queryRes.Frames = frames
+
+	return nil
+}
+
+func (promQLQuery cloudMonitoringPromQLQuery) parseToAnnotations(queryRes *backend.DataResponse,
+	response cloudMonitoringResponse, title, text string) error {
+	frames := data.Frames{}
+	for _, series := range response.PromQLData.Result {
+		if len(series.Values) == 0 {
+			continue
+		}
+		metricName := series.Metric[promQLMetricNameLabel]
+		annotation := make(map[string][]string)
+		for _, sample := range series.Values {
+			t, value, err := sample.parse()
+			if err != nil {
+				return err
+			}
+			pointValue := strconv.FormatFloat(value, 'f', 6, 64)
+			annotation["time"] = append(annotation["time"], t.Format(time.RFC3339))
+			annotation["title"] = append(annotation["title"], formatAnnotationText(title, pointValue, metricName, series.Metric, nil))
+			annotation["tags"] = append(annotation["tags"], "")
+			annotation["text"] = append(annotation["text"], formatAnnotationText(text, pointValue, metricName, series.Metric, nil))
+		}
+		frames = append(frames, data.NewFrame(promQLQuery.getRefID(),
+			data.NewField("time", nil, annotation["time"]),
+			data.NewField("title", nil, annotation["title"]),
+			data.NewField("tags", nil, annotation["tags"]),
+			data.NewField("text", nil, annotation["text"]),
+		))
+	}
+	queryRes.Frames = frames
+
+	return nil
+}
+
+func (promQLQuery cloudMonitoringPromQLQuery) buildDeepLink() string {
+	u, err := url.Parse("https://console.cloud.google.com/monitoring/metrics-explorer")
+	if err != nil {
+		slog.Error("Failed to generate deep link: unable to parse metrics explorer URL", "projectName", promQLQuery.ProjectName, "query", promQLQuery.RefID)
+		return ""
+	}
+
+	q := u.Query()
+	q.Set("project", promQLQuery.ProjectName)
+	q.Set("Grafana_deeplink", "true")
+
+	pageState := map[string]interface{}{
+		"xyChart": map[string]interface{}{
+			"constantLines": []string{},
+			"dataSets": []map[string]interface{}{
+				{
+					"prometheusQuery": promQLQuery.Query,
+					"targetAxis":      "Y1",
+					"plotType":        "LINE",
+				},
+			},
+			"timeshiftDuration": "0s",
+			"y1Axis": map[string]string{
+				"label": "y1Axis",
+				"scale": "LINEAR",
+			},
+		},
+		"timeSelection": map[string]string{
+			"timeRange": "custom",
+			"start":     promQLQuery.timeRange.From.Format(time.RFC3339Nano),
+			"end":       promQLQuery.timeRange.To.Format(time.RFC3339Nano),
+		},
+	}
+
+	blob, err := json.Marshal(pageState)
+	if err != nil {
+		slog.Error("Failed to generate deep link", "pageState", pageState, "ProjectName", promQLQuery.ProjectName, "query", promQLQuery.RefID)
+		return ""
+	}
+
+	q.Set("pageState", string(blob))
+	u.RawQuery = q.Encode()
+
+	accountChooserURL, err := url.Parse("https://accounts.google.com/AccountChooser")
+	if err != nil {
+		slog.Error("Failed to generate deep link: unable to parse account chooser URL", "ProjectName", promQLQuery.ProjectName, "query", promQLQuery.RefID)
+		return ""
+	}
+	accountChooserQuery := accountChooserURL.Query()
+	accountChooserQuery.Set("continue", u.String())
+	accountChooserURL.RawQuery = accountChooserQuery.Encode()
+
+	return accountChooserURL.String()
+}
+
+func (promQLQuery cloudMonitoringPromQLQuery) getRefID() string {
+	return promQLQuery.RefID
+}
+
+// promQLSeriesName names a series without alias the way Prometheus does, by
+// its metric name followed by its labels.
+func promQLSeriesName(metricName string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", name, labels[name]))
+	}
+	return metricName + "{" + strings.Join(pairs, ", ") + "}"
+}
+
+func (s promQLSample) parse() (time.Time, float64, error) {
+	var ts float64
+	var value string
+	if err := json.Unmarshal(s[0], &ts); err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid PromQL sample timestamp: %w", err)
+	}
+	if err := json.Unmarshal(s[1], &value); err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid PromQL sample value: %w", err)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("invalid PromQL sample value: %w", err)
+	}
+	return time.UnixMilli(int64(math.Round(ts * 1000))).UTC(), v, nil
+}
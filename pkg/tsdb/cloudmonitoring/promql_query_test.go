@@ -0,0 +1,80 @@
+package cloudmonitoring
+
+import (
+	"encoding/json"
+	"math"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromQLQuery(t *testing.T) {
+	response, err := loadTestFile("./test-data/9-series-response-promql.json")
+	require.NoError(t, err)
+	require.Len(t, response.PromQLData.Result, 2)
+
+	fromStart := time.Date(2021, 11, 4, 7, 40, 0, 0, time.UTC)
+	query := &cloudMonitoringPromQLQuery{
+		RefID:       "A",
+		ProjectName: "test-proj",
+		Query:       "compute_googleapis_com:instance_cpu_utilization",
+		timeRange: backend.TimeRange{
+			From: fromStart,
+			To:   fromStart.Add(34 * time.Minute),
+		},
+	}
+
+	t.Run("series are named after their metric and labels", func(t *testing.T) {
+		res := &backend.DataResponse{}
+		require.NoError(t, query.parseResponse(res, response, query.Query))
+
+		require.Len(t, res.Frames, 2)
+		frame := res.Frames[0]
+		assert.Equal(t, "A", frame.RefID)
+		assert.Equal(t, query.Query, frame.Meta.ExecutedQueryString)
+		require.Equal(t, 3, frame.Rows())
+		assert.Equal(t, time.Unix(1636011600, 0).UTC(), frame.Fields[0].At(0))
+		assert.Equal(t, 0.0124, frame.Fields[1].At(0))
+		assert.True(t, math.IsNaN(frame.Fields[1].At(2).(float64)))
+		assert.Equal(t, `compute_googleapis_com:instance_cpu_utilization{instance_id="6724404429462225363", project_id="test-proj", zone="asia-northeast1-c"}`, frame.Fields[1].Name)
+		assert.Equal(t, data.Labels{"instance_id": "6724404429462225363", "project_id": "test-proj", "zone": "asia-northeast1-c"}, frame.Fields[1].Labels)
+
+		assert.Equal(t, time.UnixMilli(1636011600500).UTC(), res.Frames[1].Fields[0].At(0))
+
+		require.Len(t, frame.Fields[1].Config.Links, 1)
+		link, err := url.Parse(frame.Fields[1].Config.Links[0].URL)
+		require.NoError(t, err)
+		explorerURL, err := url.Parse(link.Query().Get("continue"))
+		require.NoError(t, err)
+		var pageState map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(explorerURL.Query().Get("pageState")), &pageState))
+		dataSets := pageState["xyChart"].(map[string]interface{})["dataSets"].([]interface{})
+		assert.Equal(t, query.Query, dataSets[0].(map[string]interface{})["prometheusQuery"])
+	})
+
+	t.Run("alias is expanded as in the metric builder", func(t *testing.T) {
+		res := &backend.DataResponse{}
+		aliasQuery := *query
+		aliasQuery.AliasBy = "{{project}} - {{zone}} - {{instance_id}} - {{metric.name}}"
+		require.NoError(t, aliasQuery.parseResponse(res, response, aliasQuery.Query))
+
+		require.Len(t, res.Frames, 2)
+		assert.Equal(t, "test-proj - asia-northeast1-c - 6724404429462225363 - compute_googleapis_com:instance_cpu_utilization", res.Frames[0].Fields[1].Name)
+		assert.Equal(t, "test-proj - us-central1-a - 2254320129312738591 - compute_googleapis_com:instance_cpu_utilization", res.Frames[1].Fields[1].Name)
+	})
+
+	t.Run("annotations", func(t *testing.T) {
+		res := &backend.DataResponse{}
+		require.NoError(t, query.parseToAnnotations(res, response, "atitle {{metric.label.zone}} {{metric.value}}", "atext {{metric.type}}"))
+
+		require.Len(t, res.Frames, 2)
+		require.Equal(t, 3, res.Frames[0].Rows())
+		assert.Equal(t, "atitle asia-northeast1-c 0.012400", res.Frames[0].Fields[1].At(0))
+		assert.Equal(t, "atext compute_googleapis_com:instance_cpu_utilization", res.Frames[0].Fields[3].At(0))
+	})
+}
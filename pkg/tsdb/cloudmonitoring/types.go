@@ -2,6 +2,7 @@ package cloudmonitoring
 
 import (
 	"context"
+	"encoding/json"
 	"net/url"
 	"time"
 
@@ -42,6 +43,16 @@ type (
 		timeRange   backend.TimeRange
 	}
 
+	// Used to build PromQL queries
+	cloudMonitoringPromQLQuery struct {
+		RefID       string
+		ProjectName string
+		Query       string
+		IntervalMS  int64
+		AliasBy     string
+		timeRange   backend.TimeRange
+	}
+
 	metricQuery struct {
 		ProjectName        string
 		MetricType         string
@@ -97,9 +108,23 @@ type (
 		TimeSeriesDescriptor timeSeriesDescriptor `json:"timeSeriesDescriptor"`
 		TimeSeriesData       timeSeriesData       `json:"timeSeriesData"`
 		Unit                 string               `json:"unit"`
+		PromQLData           promQLData           `json:"data"`
 	}
 )
 
+// promQLData is the data of a response of the Cloud Monitoring PromQL API,
+// in the format of the Prometheus HTTP API.
+type promQLData struct {
+	ResultType string `json:"resultType"`
+	Result     []struct {
+		Metric map[string]string `json:"metric"`
+		Values []promQLSample    `json:"values"`
+	} `json:"result"`
+}
+
+// promQLSample is a [<unix time>, "<value>"] pair.
+type promQLSample [2]json.RawMessage
+
 type timeSeriesDescriptor struct {
 	LabelDescriptors []struct {
 		Key         string `json:"key"`
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"regexp"
 	"strings"
 	"time"
 )
 
+var sqlGroupByClause = regexp.MustCompile(`(?is)\bGROUP\s+BY\s+(.+?)(?:\s+ORDER\s+BY\b|\s+LIMIT\b|$)`)
+
 type cloudWatchQuery struct {
 	RefId            string
 	Region           string
@@ -147,3 +150,24 @@ func (q *cloudWatchQuery) buildDeepLink(startTime time.Time, endTime time.Time)
 
 	return fmt.Sprintf(`%s#metricsV2:%s`, url.String(), fragment.Encode()), nil
 }
+
+// sqlGroupByKeys returns the keys of the GROUP BY clause of a Metrics Insights
+// query, e.g. ["InstanceId"] for "... GROUP BY InstanceId".
+func (q *cloudWatchQuery) sqlGroupByKeys() []string {
+	if q.MetricQueryType != MetricQueryTypeQuery {
+		return nil
+	}
+	match := sqlGroupByClause.FindStringSubmatch(q.SqlExpression)
+	if match == nil {
+		return nil
+	}
+
+	keys := []string{}
+	for _, key := range strings.Split(match[1], ",") {
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
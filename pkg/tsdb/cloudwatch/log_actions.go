@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"sort"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -84,6 +85,77 @@ func (e *cloudWatchExecutor) executeLogActions(ctx context.Context, req *backend
 	return resp, nil
 }
 
+// executeLogQueries runs the logs queries of the request to completion with
+// the logs query runner, in parallel.
+func (e *cloudWatchExecutor) executeLogQueries(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	resp := backend.NewQueryDataResponse()
+
+	dsInfo, err := e.getDSInfo(req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, query := range req.Queries {
+		query := query
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			frames, err := e.executeLogQuery(ctx, dsInfo, query, req.PluginContext)
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Responses[query.RefID] = backend.DataResponse{Frames: frames, Error: err}
+		}()
+	}
+	wg.Wait()
+
+	return resp, nil
+}
+
+func (e *cloudWatchExecutor) executeLogQuery(ctx context.Context, dsInfo *datasourceInfo, query backend.DataQuery,
+	pluginCtx backend.PluginContext) (data.Frames, error) {
+	model, err := simplejson.NewJson(query.JSON)
+	if err != nil {
+		return nil, err
+	}
+
+	// Alert queries hold the query string in the expression, as metric queries.
+	if _, ok := model.CheckGet("queryString"); !ok {
+		model.Set("queryString", model.Get("expression").MustString(""))
+	}
+
+	region := model.Get("region").MustString(defaultRegion)
+	if region == defaultRegion {
+		region = dsInfo.region
+	}
+
+	logsClient, err := e.getCWLogsClient(region, pluginCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	startQueryInput, err := buildStartQueryInput(model, query.TimeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := e.logsQueries.run(ctx, accountKey(dsInfo, region), logsClient, startQueryInput)
+	if err != nil {
+		return nil, err
+	}
+
+	frame, err := logsResultsToDataframes(res)
+	if err != nil {
+		return nil, err
+	}
+	frame.Name = query.RefID
+	frame.RefID = query.RefID
+
+	return groupResponseFrame(frame, model.Get("statsGroups").MustStringArray())
+}
+
 func (e *cloudWatchExecutor) executeLogAction(ctx context.Context, model *simplejson.Json, query backend.DataQuery, pluginCtx backend.PluginContext) (*data.Frame, error) {
 	subType := model.Get("subtype").MustString()
 
@@ -208,6 +280,15 @@ func (e *cloudWatchExecutor) handleDescribeLogGroups(ctx context.Context,
 
 func (e *cloudWatchExecutor) executeStartQuery(ctx context.Context, logsClient cloudwatchlogsiface.CloudWatchLogsAPI,
 	parameters *simplejson.Json, timeRange backend.TimeRange) (*cloudwatchlogs.StartQueryOutput, error) {
+	startQueryInput, err := buildStartQueryInput(parameters, timeRange)
+	if err != nil {
+		return nil, err
+	}
+
+	return logsClient.StartQueryWithContext(ctx, startQueryInput)
+}
+
+func buildStartQueryInput(parameters *simplejson.Json, timeRange backend.TimeRange) (*cloudwatchlogs.StartQueryInput, error) {
 	startTime := timeRange.From
 	endTime := timeRange.To
 
@@ -238,7 +319,7 @@ func (e *cloudWatchExecutor) executeStartQuery(ctx context.Context, logsClient c
 		startQueryInput.Limit = aws.Int64(resultsLimit)
 	}
 
-	return logsClient.StartQueryWithContext(ctx, startQueryInput)
+	return startQueryInput, nil
 }
 
 func (e *cloudWatchExecutor) handleStartQuery(ctx context.Context, logsClient cloudwatchlogsiface.CloudWatchLogsAPI,
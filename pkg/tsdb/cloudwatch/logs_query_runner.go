@@ -0,0 +1,190 @@
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	// defaultLogsQueryConcurrency is the default CloudWatch Logs Insights quota
+	// of concurrent queries per account and region.
+	defaultLogsQueryConcurrency = 30
+	defaultLogsPollPeriod       = time.Second
+	maxLogsPollPeriod           = 5 * time.Second
+	maxStartQueryAttempts       = 5
+	stopQueryTimeout            = 5 * time.Second
+)
+
+// logsQueryRunner runs CloudWatch Logs Insights queries to completion in the
+// backend. It starts each query, polls its results with jitter until it
+// terminates and stops it when the caller goes away. The number of queries
+// running at once is limited per AWS account and region, and shared by all
+// the panels and alerts querying it, so that busy dashboards queue up instead
+// of failing with throttling errors.
+type logsQueryRunner struct {
+	concurrency int
+	pollPeriod  time.Duration
+	maxPeriod   time.Duration
+
+	mu     sync.Mutex
+	limits map[string]*semaphore.Weighted
+}
+
+func newLogsQueryRunner(concurrency int) *logsQueryRunner {
+	if concurrency <= 0 {
+		concurrency = defaultLogsQueryConcurrency
+	}
+	return &logsQueryRunner{
+		concurrency: concurrency,
+		pollPeriod:  defaultLogsPollPeriod,
+		maxPeriod:   maxLogsPollPeriod,
+		limits:      map[string]*semaphore.Weighted{},
+	}
+}
+
+// accountKey identifies the account and region a data source queries, as far
+// as it can be told from its settings, for sharing the concurrency limit
+// between data sources using the same credentials.
+func accountKey(dsInfo *datasourceInfo, region string) string {
+	return fmt.Sprintf("%s/%s/%s/%s/%s/%s", dsInfo.authType, dsInfo.profile, dsInfo.accessKey, dsInfo.assumeRoleARN,
+		dsInfo.endpoint, region)
+}
+
+func (r *logsQueryRunner) limit(key string) *semaphore.Weighted {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	limit, ok := r.limits[key]
+	if !ok {
+		limit = semaphore.NewWeighted(int64(r.concurrency))
+		r.limits[key] = limit
+	}
+	return limit
+}
+
+// run starts the query once a slot is free for the account and returns its
+// results when it completes. If ctx is cancelled while the query runs, the
+// query is stopped in CloudWatch so it no longer counts towards the quota.
+func (r *logsQueryRunner) run(ctx context.Context, key string, logsClient cloudwatchlogsiface.CloudWatchLogsAPI,
+	input *cloudwatchlogs.StartQueryInput) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	limit := r.limit(key)
+	if err := limit.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	defer limit.Release(1)
+
+	queryID, err := r.startQuery(ctx, logsClient, input)
+	if err != nil {
+		return nil, err
+	}
+
+	period := r.pollPeriod
+	for {
+		if err := r.wait(ctx, period); err != nil {
+			r.stopQuery(logsClient, queryID)
+			return nil, err
+		}
+
+		res, err := logsClient.GetQueryResultsWithContext(ctx, &cloudwatchlogs.GetQueryResultsInput{
+			QueryId: aws.String(queryID),
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				r.stopQuery(logsClient, queryID)
+				return nil, ctx.Err()
+			}
+			if !isThrottlingError(err) {
+				return nil, err
+			}
+			period = r.backoff(period)
+			continue
+		}
+
+		status := aws.StringValue(res.Status)
+		if !isTerminated(status) {
+			continue
+		}
+		if status != cloudwatchlogs.QueryStatusComplete {
+			return nil, fmt.Errorf("query %s did not complete: %s", queryID, status)
+		}
+		return res, nil
+	}
+}
+
+func (r *logsQueryRunner) startQuery(ctx context.Context, logsClient cloudwatchlogsiface.CloudWatchLogsAPI,
+	input *cloudwatchlogs.StartQueryInput) (string, error) {
+	period := r.pollPeriod
+	for attempt := 1; ; attempt++ {
+		res, err := logsClient.StartQueryWithContext(ctx, input)
+		if err == nil {
+			return aws.StringValue(res.QueryId), nil
+		}
+		// Queries started outside of Grafana count towards the same quota,
+		// so the limit can be hit even with a free slot.
+		if !isThrottlingError(err) || attempt >= maxStartQueryAttempts {
+			return "", err
+		}
+		plog.Debug("Retrying throttled CloudWatch Logs query", "attempt", attempt, "err", err)
+
+		period = r.backoff(period)
+		if err := r.wait(ctx, period); err != nil {
+			return "", err
+		}
+	}
+}
+
+func (r *logsQueryRunner) stopQuery(logsClient cloudwatchlogsiface.CloudWatchLogsAPI, queryID string) {
+	// The request context is gone, stopping the query gets its own.
+	ctx, cancel := context.WithTimeout(context.Background(), stopQueryTimeout)
+	defer cancel()
+
+	_, err := logsClient.StopQueryWithContext(ctx, &cloudwatchlogs.StopQueryInput{QueryId: aws.String(queryID)})
+	if err != nil {
+		plog.Warn("Failed to stop CloudWatch Logs query", "queryId", queryID, "err", err)
+	}
+}
+
+// wait sleeps for period plus up to half of it more, so that the queries of a
+// dashboard loaded at once don't keep polling in lockstep.
+func (r *logsQueryRunner) wait(ctx context.Context, period time.Duration) error {
+	jittered := period + time.Duration(rand.Int63n(int64(period)/2+1))
+	timer := time.NewTimer(jittered)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (r *logsQueryRunner) backoff(period time.Duration) time.Duration {
+	period *= 2
+	if period > r.maxPeriod {
+		return r.maxPeriod
+	}
+	return period
+}
+
+func isThrottlingError(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	switch awsErr.Code() {
+	case "ThrottlingException", LimitExceededException:
+		return true
+	}
+	return false
+}
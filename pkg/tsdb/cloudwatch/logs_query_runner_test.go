@@ -0,0 +1,231 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLogsQueryClient struct {
+	cloudwatchlogsiface.CloudWatchLogsAPI
+
+	mu sync.Mutex
+	// throttledStarts is the number of StartQuery calls failing with a
+	// throttling error before queries start.
+	throttledStarts int
+	// polls is the number of GetQueryResults calls returning a running query
+	// before it completes, -1 keeps it running.
+	polls   int
+	results map[string]int
+	running int
+	maxRun  int
+	started int
+	stopped []string
+}
+
+func (c *fakeLogsQueryClient) StartQueryWithContext(ctx context.Context, input *cloudwatchlogs.StartQueryInput, option ...request.Option) (*cloudwatchlogs.StartQueryOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.throttledStarts > 0 {
+		c.throttledStarts--
+		return nil, awserr.New("ThrottlingException", "Rate exceeded", nil)
+	}
+
+	c.started++
+	c.running++
+	if c.running > c.maxRun {
+		c.maxRun = c.running
+	}
+	if c.results == nil {
+		c.results = map[string]int{}
+	}
+	id := fmt.Sprintf("query-%d", c.started)
+	c.results[id] = 0
+	return &cloudwatchlogs.StartQueryOutput{QueryId: aws.String(id)}, nil
+}
+
+func (c *fakeLogsQueryClient) GetQueryResultsWithContext(ctx context.Context, input *cloudwatchlogs.GetQueryResultsInput, option ...request.Option) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	id := aws.StringValue(input.QueryId)
+	c.results[id]++
+	if c.polls < 0 || c.results[id] <= c.polls {
+		return &cloudwatchlogs.GetQueryResultsOutput{Status: aws.String(cloudwatchlogs.QueryStatusRunning)}, nil
+	}
+
+	c.running--
+	return &cloudwatchlogs.GetQueryResultsOutput{
+		Status: aws.String(cloudwatchlogs.QueryStatusComplete),
+		Results: [][]*cloudwatchlogs.ResultField{
+			{
+				{Field: aws.String("@message"), Value: aws.String("hello")},
+			},
+		},
+	}, nil
+}
+
+func (c *fakeLogsQueryClient) StopQueryWithContext(ctx context.Context, input *cloudwatchlogs.StopQueryInput, option ...request.Option) (*cloudwatchlogs.StopQueryOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.running--
+	c.stopped = append(c.stopped, aws.StringValue(input.QueryId))
+	return &cloudwatchlogs.StopQueryOutput{Success: aws.Bool(true)}, nil
+}
+
+func newTestLogsQueryRunner(concurrency int) *logsQueryRunner {
+	runner := newLogsQueryRunner(concurrency)
+	runner.pollPeriod = time.Millisecond
+	runner.maxPeriod = 4 * time.Millisecond
+	return runner
+}
+
+func TestLogsQueryRunner(t *testing.T) {
+	input := &cloudwatchlogs.StartQueryInput{QueryString: aws.String("fields @message")}
+
+	t.Run("polls until the query completes", func(t *testing.T) {
+		cli := &fakeLogsQueryClient{polls: 3}
+		res, err := newTestLogsQueryRunner(1).run(context.Background(), "account", cli, input)
+		require.NoError(t, err)
+
+		assert.Equal(t, cloudwatchlogs.QueryStatusComplete, *res.Status)
+		assert.Len(t, res.Results, 1)
+		assert.Empty(t, cli.stopped)
+	})
+
+	t.Run("retries throttled queries", func(t *testing.T) {
+		cli := &fakeLogsQueryClient{throttledStarts: 2}
+		_, err := newTestLogsQueryRunner(1).run(context.Background(), "account", cli, input)
+		require.NoError(t, err)
+		assert.Equal(t, 1, cli.started)
+
+		cli = &fakeLogsQueryClient{throttledStarts: maxStartQueryAttempts}
+		_, err = newTestLogsQueryRunner(1).run(context.Background(), "account", cli, input)
+		require.Error(t, err)
+		assert.True(t, isThrottlingError(err))
+		assert.Equal(t, 0, cli.started)
+	})
+
+	t.Run("limits the queries running at once per account", func(t *testing.T) {
+		runner := newTestLogsQueryRunner(2)
+		cli := &fakeLogsQueryClient{polls: 2}
+		otherCli := &fakeLogsQueryClient{polls: 2}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := runner.run(context.Background(), "account", cli, input)
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := runner.run(context.Background(), "other-account", otherCli, input)
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 6, cli.started)
+		assert.Equal(t, 2, cli.maxRun)
+		assert.Equal(t, 2, otherCli.maxRun)
+	})
+
+	t.Run("stops the query when the context is cancelled", func(t *testing.T) {
+		cli := &fakeLogsQueryClient{polls: -1}
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		_, err := newTestLogsQueryRunner(1).run(ctx, "account", cli, input)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Len(t, cli.stopped, 1)
+		assert.Equal(t, 0, cli.running)
+	})
+
+	t.Run("fails queries that do not complete", func(t *testing.T) {
+		cli := &failingLogsQueryClient{fakeLogsQueryClient: &fakeLogsQueryClient{}}
+		_, err := newTestLogsQueryRunner(1).run(context.Background(), "account", cli, input)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "did not complete: Timeout")
+	})
+}
+
+type failingLogsQueryClient struct {
+	*fakeLogsQueryClient
+}
+
+func (c *failingLogsQueryClient) GetQueryResultsWithContext(ctx context.Context, input *cloudwatchlogs.GetQueryResultsInput, option ...request.Option) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	return &cloudwatchlogs.GetQueryResultsOutput{Status: aws.String(cloudwatchlogs.QueryStatusTimeout)}, nil
+}
+
+func TestQuery_LogQueries(t *testing.T) {
+	origNewCWLogsClient := NewCWLogsClient
+	t.Cleanup(func() {
+		NewCWLogsClient = origNewCWLogsClient
+	})
+
+	cli := &fakeLogsQueryClient{polls: 1}
+	NewCWLogsClient = func(sess *session.Session) cloudwatchlogsiface.CloudWatchLogsAPI {
+		return cli
+	}
+
+	im := datasource.NewInstanceManager(func(s backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+		return datasourceInfo{region: "us-east-1"}, nil
+	})
+	executor := newExecutor(im, newTestConfig(), fakeSessionCache{})
+	executor.logsQueries = newTestLogsQueryRunner(1)
+
+	timeRange := backend.TimeRange{From: time.Unix(1584700643, 0), To: time.Unix(1584873443, 0)}
+	resp, err := executor.QueryData(context.Background(), &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{}},
+		Headers:       map[string]string{"FromAlert": "true"},
+		Queries: []backend.DataQuery{
+			{
+				RefID:     "A",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"queryMode": "Logs", "region": "default", "expression": "fields @message"}`),
+			},
+			{
+				RefID:     "B",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"queryMode": "Logs", "region": "default", "queryString": "fields @message"}`),
+			},
+			{
+				RefID:     "C",
+				TimeRange: backend.TimeRange{From: timeRange.To, To: timeRange.From},
+				JSON:      json.RawMessage(`{"queryMode": "Logs", "region": "default", "queryString": "fields @message"}`),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resp.Responses, 3)
+	for _, refID := range []string{"A", "B"} {
+		res := resp.Responses[refID]
+		require.NoError(t, res.Error)
+		require.Len(t, res.Frames, 1)
+		assert.Equal(t, refID, res.Frames[0].RefID)
+		assert.Equal(t, 1, res.Frames[0].Rows())
+	}
+	require.Error(t, resp.Responses["C"].Error)
+	assert.Contains(t, resp.Responses["C"].Error.Error(), "invalid time range")
+	assert.Equal(t, 2, cli.started)
+}
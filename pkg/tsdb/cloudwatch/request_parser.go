@@ -63,7 +63,8 @@ func migrateLegacyQuery(queries []backend.DataQuery, startTime time.Time, endTim
 
 		_, err = model.Get("statistic").String()
 		// If there's not a statistic property in the json, we know it's the legacy format and then it has to be migrated
-		if err != nil {
+		// Metrics Insights queries have their statistic in the SQL expression
+		if err != nil && !isMetricsInsightsModel(model) {
 			stats, err := model.Get("statistics").StringArray()
 			if err != nil {
 				return nil, fmt.Errorf("query must have either statistic or statistics field")
@@ -89,12 +90,23 @@ func parseRequestQuery(model *simplejson.Json, refId string, startTime time.Time
 	if err != nil {
 		return nil, err
 	}
-	namespace, err := model.Get("namespace").String()
+	metricQueryType, err := parseMetricQueryType(model.Get("metricQueryType").Interface())
 	if err != nil {
+		return nil, err
+	}
+	sqlExpression := model.Get("sqlExpression").MustString("")
+	if metricQueryType == MetricQueryTypeQuery && strings.TrimSpace(sqlExpression) == "" {
+		return nil, fmt.Errorf("failed to get sqlExpression: a Metrics Insights query requires an SQL expression")
+	}
+
+	// The namespace, metric name and statistic of Metrics Insights queries are
+	// in the SQL expression.
+	namespace, err := model.Get("namespace").String()
+	if err != nil && metricQueryType != MetricQueryTypeQuery {
 		return nil, fmt.Errorf("failed to get namespace: %v", err)
 	}
 	metricName, err := model.Get("metricName").String()
-	if err != nil {
+	if err != nil && metricQueryType != MetricQueryTypeQuery {
 		return nil, fmt.Errorf("failed to get metricName: %v", err)
 	}
 	dimensions, err := parseDimensions(model)
@@ -103,7 +115,7 @@ func parseRequestQuery(model *simplejson.Json, refId string, startTime time.Time
 	}
 
 	statistic, err := model.Get("statistic").String()
-	if err != nil {
+	if err != nil && metricQueryType != MetricQueryTypeQuery {
 		return nil, fmt.Errorf("failed to parse statistic: %v", err)
 	}
 
@@ -143,7 +155,6 @@ func parseRequestQuery(model *simplejson.Json, refId string, startTime time.Time
 		id = fmt.Sprintf("query%s", refId)
 	}
 	expression := model.Get("expression").MustString("")
-	sqlExpression := model.Get("sqlExpression").MustString("")
 	alias := model.Get("alias").MustString()
 	returnData := !model.Get("hide").MustBool(false)
 	queryType := model.Get("type").MustString()
@@ -155,7 +166,6 @@ func parseRequestQuery(model *simplejson.Json, refId string, startTime time.Time
 	}
 
 	matchExact := model.Get("matchExact").MustBool(true)
-	metricQueryType := metricQueryType(model.Get("metricQueryType").MustInt(0))
 
 	var metricEditorModeValue metricEditorMode
 	memv, err := model.Get("metricEditorMode").Int()
@@ -186,6 +196,11 @@ func parseRequestQuery(model *simplejson.Json, refId string, startTime time.Time
 	}, nil
 }
 
+func isMetricsInsightsModel(model *simplejson.Json) bool {
+	metricQueryType, err := parseMetricQueryType(model.Get("metricQueryType").Interface())
+	return err == nil && metricQueryType == MetricQueryTypeQuery
+}
+
 func getRetainedPeriods(timeSince time.Duration) []int {
 	// See https://aws.amazon.com/about-aws/whats-new/2016/11/cloudwatch-extends-metrics-retention-and-new-user-interface/
 	if timeSince > time.Duration(455)*24*time.Hour {
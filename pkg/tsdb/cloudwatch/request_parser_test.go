@@ -10,6 +10,60 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestMetricsInsightsRequestParser(t *testing.T) {
+	t.Run("metric query type by name, without namespace, metric and statistic", func(t *testing.T) {
+		query := simplejson.NewFromAny(map[string]interface{}{
+			"refId":           "ref1",
+			"region":          "us-east-1",
+			"metricQueryType": "query",
+			"sqlExpression":   `SELECT AVG(CPUUtilization) FROM SCHEMA("AWS/EC2", InstanceId) GROUP BY InstanceId`,
+			"period":          "300",
+		})
+
+		res, err := parseRequestQuery(query, "ref1", time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, MetricQueryTypeQuery, res.MetricQueryType)
+		assert.Equal(t, GMDApiModeSQLExpression, res.getGMDAPIMode())
+		assert.Equal(t, []string{"InstanceId"}, res.sqlGroupByKeys())
+		assert.Empty(t, res.Namespace)
+	})
+
+	t.Run("metric query type by number", func(t *testing.T) {
+		query, err := simplejson.NewJson([]byte(`{"region": "us-east-1", "metricQueryType": 1, "sqlExpression": "SELECT SUM(NetworkIn) FROM \"AWS/EC2\"", "period": "300"}`))
+		require.NoError(t, err)
+
+		res, err := parseRequestQuery(query, "ref1", time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, MetricQueryTypeQuery, res.MetricQueryType)
+		assert.Nil(t, res.sqlGroupByKeys())
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		query := simplejson.NewFromAny(map[string]interface{}{
+			"region":          "us-east-1",
+			"metricQueryType": "query",
+		})
+		_, err := parseRequestQuery(query, "ref1", time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+		require.EqualError(t, err, "failed to get sqlExpression: a Metrics Insights query requires an SQL expression")
+
+		query = simplejson.NewFromAny(map[string]interface{}{
+			"region":          "us-east-1",
+			"metricQueryType": "insights",
+		})
+		_, err = parseRequestQuery(query, "ref1", time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+		require.EqualError(t, err, "invalid metric query type insights")
+	})
+
+	t.Run("legacy migration keeps queries without statistic", func(t *testing.T) {
+		queries, err := migrateLegacyQuery([]backend.DataQuery{{
+			RefID: "A",
+			JSON:  []byte(`{"region": "us-east-1", "metricQueryType": 1, "sqlExpression": "SELECT AVG(CPUUtilization) FROM \"AWS/EC2\""}`),
+		}}, time.Now().Add(-time.Hour), time.Now())
+		require.NoError(t, err)
+		require.Len(t, queries, 1)
+	})
+}
+
 func TestRequestParser(t *testing.T) {
 	t.Run("Query migration ", func(t *testing.T) {
 		t.Run("legacy statistics field is migrated", func(t *testing.T) {
@@ -11,6 +11,7 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/tsdb/legend"
 )
 
 func (e *cloudWatchExecutor) parseResponse(startTime time.Time, endTime time.Time, metricDataOutputs []*cloudwatch.GetMetricDataOutput,
@@ -84,6 +85,10 @@ func aggregateResponse(getMetricDataOutputs []*cloudwatch.GetMetricDataOutput) m
 }
 
 func getLabels(cloudwatchLabel string, query *cloudWatchQuery) data.Labels {
+	if query.MetricQueryType == MetricQueryTypeQuery {
+		return getMetricsInsightsLabels(cloudwatchLabel, query)
+	}
+
 	dims := make([]string, 0, len(query.Dimensions))
 	for k := range query.Dimensions {
 		dims = append(dims, k)
@@ -107,6 +112,26 @@ func getLabels(cloudwatchLabel string, query *cloudWatchQuery) data.Labels {
 	return labels
 }
 
+// getMetricsInsightsLabels returns the labels of a series of a Metrics Insights
+// query, whose label holds the values of the GROUP BY keys separated by spaces.
+// Values are only split between keys when their number matches the keys.
+func getMetricsInsightsLabels(cloudwatchLabel string, query *cloudWatchQuery) data.Labels {
+	labels := data.Labels{}
+	keys := query.sqlGroupByKeys()
+	switch {
+	case len(keys) == 1:
+		labels[keys[0]] = cloudwatchLabel
+	case len(keys) > 1:
+		values := strings.Fields(cloudwatchLabel)
+		if len(values) == len(keys) {
+			for i, key := range keys {
+				labels[key] = values[i]
+			}
+		}
+	}
+	return labels
+}
+
 func buildDataFrames(startTime time.Time, endTime time.Time, aggregatedResponse queryRowResponse,
 	query *cloudWatchQuery) (data.Frames, error) {
 	frames := data.Frames{}
@@ -242,32 +267,23 @@ func formatAlias(query *cloudWatchQuery, stat string, dimensions map[string]stri
 		data["label"] = label
 	}
 
-	// since the SQL query string is not (yet) parsed, we don't know what namespace, metric, statistic and labels it's using at this point
+	// since the SQL query string is not parsed, we don't know what namespace, metric and statistic it's using,
+	// only the labels of its GROUP BY keys
 	if query.MetricQueryType != MetricQueryTypeQuery {
 		data["namespace"] = namespace
 		data["metric"] = metricName
 		data["stat"] = stat
-		for k, v := range dimensions {
-			data[k] = v
-		}
+	}
+	for k, v := range dimensions {
+		data[k] = v
 	}
 
-	result := aliasFormat.ReplaceAllFunc([]byte(query.Alias), func(in []byte) []byte {
-		labelName := strings.Replace(string(in), "{{", "", 1)
-		labelName = strings.Replace(labelName, "}}", "", 1)
-		labelName = strings.TrimSpace(labelName)
-		if val, exists := data[labelName]; exists {
-			return []byte(val)
-		}
-
-		return in
-	})
-
-	if string(result) == "" {
+	result := legend.Format(query.Alias, data, legend.Options{MissingLabel: legend.MissingLabelKeep})
+	if result == "" {
 		return metricName + "_" + stat
 	}
 
-	return string(result)
+	return result
 }
 
 func createDataLinks(link string) []data.DataLink {
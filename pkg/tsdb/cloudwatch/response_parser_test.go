@@ -9,6 +9,7 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -375,6 +376,46 @@ func TestCloudWatchResponseParser(t *testing.T) {
 		assert.True(t, strings.Contains(frames[0].Name, "60"))
 	})
 
+	t.Run("Alias templates apply functions and keep missing fields", func(t *testing.T) {
+		timestamp := time.Unix(0, 0)
+		response := &queryRowResponse{
+			Labels: []string{"lb1"},
+			Metrics: map[string]*cloudwatch.MetricDataResult{
+				"lb1": {
+					Id:         aws.String("id1"),
+					Label:      aws.String("lb1"),
+					Timestamps: []*time.Time{aws.Time(timestamp)},
+					Values:     []*float64{aws.Float64(10)},
+					StatusCode: aws.String("Complete"),
+				},
+			},
+		}
+
+		query := &cloudWatchQuery{
+			RefId:      "refId1",
+			Region:     "us-east-1",
+			Namespace:  "AWS/ApplicationELB",
+			MetricName: "TargetResponseTime",
+			Dimensions: map[string][]string{
+				"LoadBalancer": {"lb1"},
+			},
+			Statistic: "Average",
+			Period:    60,
+			Alias:     `{{region | upper}} {{ LoadBalancer | trimPrefix "lb" }} {{Missing}}`,
+		}
+		frames, err := buildDataFrames(startTime, endTime, *response, query)
+		require.NoError(t, err)
+
+		assert.Equal(t, "US-EAST-1 1 {{Missing}}", frames[0].Name)
+
+		query.Dimensions = map[string][]string{"Load Balancer": {"lb1"}}
+		query.Alias = "{{Load Balancer}} {{ Load Balancer }}"
+		frames, err = buildDataFrames(startTime, endTime, *response, query)
+		require.NoError(t, err)
+
+		assert.Equal(t, "lb1 lb1", frames[0].Name)
+	})
+
 	t.Run("Parse cloudwatch response", func(t *testing.T) {
 		timestamp := time.Unix(0, 0)
 		response := &queryRowResponse{
@@ -427,4 +468,41 @@ func TestCloudWatchResponseParser(t *testing.T) {
 		assert.Equal(t, "Value", frame.Fields[1].Name)
 		assert.Equal(t, "", frame.Fields[1].Config.DisplayName)
 	})
+	t.Run("Metrics Insights query labels and alias", func(t *testing.T) {
+		timestamp := time.Unix(0, 0)
+		response := &queryRowResponse{
+			Labels: []string{"i-123 t3.micro"},
+			Metrics: map[string]*cloudwatch.MetricDataResult{
+				"i-123 t3.micro": {
+					Id:         aws.String("id1"),
+					Label:      aws.String("i-123 t3.micro"),
+					Timestamps: []*time.Time{aws.Time(timestamp)},
+					Values:     []*float64{aws.Float64(10)},
+					StatusCode: aws.String("Complete"),
+				},
+			},
+		}
+
+		query := &cloudWatchQuery{
+			RefId:           "refId1",
+			Region:          "us-east-1",
+			Period:          60,
+			Alias:           "{{InstanceId}} ({{InstanceType}}) in {{region}}",
+			MetricQueryType: MetricQueryTypeQuery,
+			SqlExpression:   `SELECT AVG(CPUUtilization) FROM SCHEMA("AWS/EC2", InstanceId, InstanceType) GROUP BY InstanceId, "InstanceType" ORDER BY AVG() DESC LIMIT 10`,
+		}
+		frames, err := buildDataFrames(startTime, endTime, *response, query)
+		require.NoError(t, err)
+
+		require.Len(t, frames, 1)
+		assert.Equal(t, "i-123 (t3.micro) in us-east-1", frames[0].Name)
+		assert.Equal(t, data.Labels{"InstanceId": "i-123", "InstanceType": "t3.micro"}, frames[0].Fields[1].Labels)
+
+		query.Alias = ""
+		query.SqlExpression = `SELECT AVG(CPUUtilization) FROM SCHEMA("AWS/EC2", InstanceId) group by InstanceId`
+		frames, err = buildDataFrames(startTime, endTime, *response, query)
+		require.NoError(t, err)
+		assert.Equal(t, "i-123 t3.micro", frames[0].Name)
+		assert.Equal(t, data.Labels{"InstanceId": "i-123 t3.micro"}, frames[0].Fields[1].Labels)
+	})
 }
@@ -1,6 +1,7 @@
 package cloudwatch
 
 import (
+	"encoding/json"
 	"fmt"
 )
 
@@ -39,6 +40,32 @@ const (
 	MetricQueryTypeQuery
 )
 
+// parseMetricQueryType parses the metricQueryType of a query model, which is
+// either the number of the type or its name, "search" or "query".
+func parseMetricQueryType(value interface{}) (metricQueryType, error) {
+	switch v := value.(type) {
+	case nil:
+		return MetricQueryTypeSearch, nil
+	case json.Number:
+		n, err := v.Int64()
+		if err == nil && (n == int64(MetricQueryTypeSearch) || n == int64(MetricQueryTypeQuery)) {
+			return metricQueryType(n), nil
+		}
+	case float64:
+		if v == float64(MetricQueryTypeSearch) || v == float64(MetricQueryTypeQuery) {
+			return metricQueryType(v), nil
+		}
+	case string:
+		switch v {
+		case "", "search":
+			return MetricQueryTypeSearch, nil
+		case "query":
+			return MetricQueryTypeQuery, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid metric query type %v", value)
+}
+
 type metricEditorMode uint32
 
 const (
@@ -35,6 +35,7 @@ type SearchRequest struct {
 	Interval    intervalv2.Interval
 	Size        int
 	Sort        map[string]interface{}
+	SortFields  []string
 	Query       *Query
 	Aggs        AggArray
 	CustomProps map[string]interface{}
@@ -45,7 +46,14 @@ func (r *SearchRequest) MarshalJSON() ([]byte, error) {
 	root := make(map[string]interface{})
 
 	root["size"] = r.Size
-	if len(r.Sort) > 0 {
+	if len(r.Sort) > 1 && len(r.SortFields) == len(r.Sort) {
+		// Sorts on several fields are applied in order, which only an array keeps.
+		sort := make([]map[string]interface{}, 0, len(r.SortFields))
+		for _, field := range r.SortFields {
+			sort = append(sort, map[string]interface{}{field: r.Sort[field]})
+		}
+		root["sort"] = sort
+	} else if len(r.Sort) > 0 {
 		root["sort"] = r.Sort
 	}
 
@@ -14,6 +14,7 @@ type SearchRequestBuilder struct {
 	index        string
 	size         int
 	sort         map[string]interface{}
+	sortFields   []string
 	queryBuilder *QueryBuilder
 	aggBuilders  []AggBuilder
 	customProps  map[string]interface{}
@@ -38,6 +39,7 @@ func (b *SearchRequestBuilder) Build() (*SearchRequest, error) {
 		Interval:    b.interval,
 		Size:        b.size,
 		Sort:        b.sort,
+		SortFields:  b.sortFields,
 		CustomProps: b.customProps,
 	}
 
@@ -72,19 +74,34 @@ func (b *SearchRequestBuilder) Size(size int) *SearchRequestBuilder {
 
 // SortDesc adds a sort to the search request
 func (b *SearchRequestBuilder) SortDesc(field, unmappedType string) *SearchRequestBuilder {
+	return b.Sort(field, "desc", unmappedType)
+}
+
+// Sort adds a sort in the given order, "asc" or "desc", to the search request
+func (b *SearchRequestBuilder) Sort(field, order, unmappedType string) *SearchRequestBuilder {
 	props := map[string]string{
-		"order": "desc",
+		"order": order,
 	}
 
 	if unmappedType != "" {
 		props["unmapped_type"] = unmappedType
 	}
 
+	if _, ok := b.sort[field]; !ok {
+		b.sortFields = append(b.sortFields, field)
+	}
 	b.sort[field] = props
 
 	return b
 }
 
+// SearchAfter makes the search return the hits following the hit with the
+// given sort values
+func (b *SearchRequestBuilder) SearchAfter(sortValues []interface{}) *SearchRequestBuilder {
+	b.customProps["search_after"] = sortValues
+	return b
+}
+
 // AddDocValueField adds a doc value field to the search request
 func (b *SearchRequestBuilder) AddDocValueField(field string) *SearchRequestBuilder {
 	// fields field not supported on version >= 5
@@ -106,6 +106,28 @@ func TestSearchRequest(t *testing.T) {
 		})
 	})
 
+	t.Run("When sorting on several fields", func(t *testing.T) {
+		b := setup()
+		b.Sort(timeField, "asc", "boolean")
+		b.Sort("_doc", "asc", "")
+		b.SearchAfter([]interface{}{5, 1})
+		sr, err := b.Build()
+		require.Nil(t, err)
+
+		t.Run("When marshal to JSON should keep the order of the sorts", func(t *testing.T) {
+			body, err := json.Marshal(sr)
+			require.Nil(t, err)
+			json, err := simplejson.NewJson(body)
+			require.Nil(t, err)
+
+			sort := json.Get("sort")
+			require.Equal(t, "asc", sort.GetIndex(0).GetPath(timeField, "order").MustString())
+			require.Equal(t, "asc", sort.GetIndex(1).GetPath("_doc", "order").MustString())
+			require.Equal(t, 5, json.Get("search_after").GetIndex(0).MustInt())
+			require.Equal(t, 1, json.Get("search_after").GetIndex(1).MustInt())
+		})
+	})
+
 	t.Run("When adding doc value field", func(t *testing.T) {
 		b := setup()
 		b.AddDocValueField(timeField)
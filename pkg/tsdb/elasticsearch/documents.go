@@ -0,0 +1,257 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	es "github.com/grafana/grafana/pkg/tsdb/elasticsearch/client"
+)
+
+const (
+	// Document metric types, queried without aggregations
+	rawDocumentType = "raw_document"
+	rawDataType     = "raw_data"
+	logsType        = "logs"
+
+	defaultDocumentsSize = 500
+
+	// Log context directions
+	logContextBefore = "before"
+	logContextAfter  = "after"
+
+	defaultLogContextLimit = 10
+
+	// logsTiebreaker orders logs with the same time, so that log context
+	// queries can continue after a log without skipping the ones next to it.
+	logsTiebreaker = "_doc"
+)
+
+// isDocumentQuery returns whether the query fetches documents instead of
+// aggregating them.
+func isDocumentQuery(q *Query) bool {
+	if len(q.BucketAggs) > 0 || len(q.Metrics) == 0 {
+		return false
+	}
+	switch q.Metrics[0].Type {
+	case rawDocumentType, rawDataType, logsType:
+		return true
+	}
+	return false
+}
+
+// addDocumentQuery requests the latest documents of the query, newest first,
+// or the documents surrounding the document of the log context.
+func addDocumentQuery(b *es.SearchRequestBuilder, q *Query) {
+	if q.LogContext != nil {
+		addLogContextQuery(b, q)
+		return
+	}
+
+	metric := q.Metrics[0]
+	size := metric.Settings.Get("size").MustInt(defaultDocumentsSize)
+	if metric.Type == logsType {
+		size = metric.Settings.Get("limit").MustInt(size)
+	}
+	b.Size(size)
+	b.SortDesc(q.TimeField, "boolean")
+	if metric.Type == logsType {
+		b.SortDesc(logsTiebreaker, "")
+	}
+	b.AddDocValueField(q.TimeField)
+}
+
+// addLogContextQuery requests the documents before or after the one with the
+// sort values of the log context, sorted like logs queries by time and the
+// tiebreaker.
+func addLogContextQuery(b *es.SearchRequestBuilder, q *Query) {
+	order := "desc"
+	if q.LogContext.Direction == logContextAfter {
+		order = "asc"
+	}
+	b.Size(q.LogContext.Limit)
+	b.Sort(q.TimeField, order, "boolean")
+	b.Sort(logsTiebreaker, order, "")
+	b.SearchAfter(q.LogContext.SortValues)
+	b.AddDocValueField(q.TimeField)
+}
+
+// processDocuments converts the hits of a document query to a frame with a
+// row per document: the time, the _id and _index of the document, for logs its
+// sort values to query its log context, and a field per key of its source.
+// Fields with values of different types are strings.
+func processDocuments(hits *es.SearchResponseHits, target *Query) backend.DataResponse {
+	frame := data.NewFrame("")
+	if target.Metrics[0].Type == logsType {
+		frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeLogs}
+	}
+	if hits == nil {
+		return backend.DataResponse{Frames: data.Frames{frame}}
+	}
+
+	if target.LogContext != nil && target.LogContext.Direction == logContextAfter {
+		// The documents after the log context are in ascending order, all
+		// frames of documents are in descending order.
+		reversed := make([]map[string]interface{}, len(hits.Hits))
+		for i, hit := range hits.Hits {
+			reversed[len(hits.Hits)-1-i] = hit
+		}
+		hits = &es.SearchResponseHits{Hits: reversed}
+	}
+
+	times := make([]*time.Time, len(hits.Hits))
+	ids := make([]*string, len(hits.Hits))
+	indices := make([]*string, len(hits.Hits))
+	withSortValues := target.Metrics[0].Type == logsType || target.LogContext != nil
+	sortValues := make([]*string, len(hits.Hits))
+	sources := make([]map[string]interface{}, len(hits.Hits))
+	keys := map[string]struct{}{}
+	for i, hit := range hits.Hits {
+		times[i] = documentTime(hit, target.TimeField)
+		ids[i] = documentString(hit["_id"])
+		indices[i] = documentString(hit["_index"])
+		sortValues[i] = documentString(hit["sort"])
+
+		source := map[string]interface{}{}
+		if s, ok := hit["_source"].(map[string]interface{}); ok {
+			flattenDocument("", s, source)
+		}
+		delete(source, target.TimeField)
+		for key := range source {
+			keys[key] = struct{}{}
+		}
+		sources[i] = source
+	}
+
+	frame.Fields = append(frame.Fields,
+		data.NewField(target.TimeField, nil, times),
+		data.NewField("_id", nil, ids),
+		data.NewField("_index", nil, indices),
+	)
+	if withSortValues {
+		frame.Fields = append(frame.Fields, data.NewField("sort", nil, sortValues))
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+	for _, key := range sortedKeys {
+		values := make([]interface{}, len(sources))
+		for i, source := range sources {
+			values[i] = source[key]
+		}
+		frame.Fields = append(frame.Fields, documentField(key, values))
+	}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// flattenDocument sets the values of nested objects of a document source
+// under their dotted path, e.g. "kubernetes.pod.name".
+func flattenDocument(prefix string, source map[string]interface{}, result map[string]interface{}) {
+	for key, value := range source {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenDocument(key, nested, result)
+			continue
+		}
+		result[key] = value
+	}
+}
+
+// documentTime returns the time of a hit, from its doc value field when
+// requested, and from its source otherwise.
+func documentTime(hit map[string]interface{}, timeField string) *time.Time {
+	var value interface{}
+	if fields, ok := hit["fields"].(map[string]interface{}); ok {
+		if values, ok := fields[timeField].([]interface{}); ok && len(values) > 0 {
+			value = values[0]
+		}
+	}
+	if value == nil {
+		if source, ok := hit["_source"].(map[string]interface{}); ok {
+			value = source[timeField]
+		}
+	}
+
+	var t time.Time
+	switch v := value.(type) {
+	case float64:
+		t = time.Unix(0, int64(v)*int64(time.Millisecond))
+	case string:
+		if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+			t = time.Unix(0, ms*int64(time.Millisecond))
+		} else if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			t = parsed
+		} else {
+			return nil
+		}
+	default:
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func documentString(value interface{}) *string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return &v
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	s := string(encoded)
+	return &s
+}
+
+// documentField returns a number or boolean field when all values are of that
+// type, and a string field otherwise.
+func documentField(name string, values []interface{}) *data.Field {
+	numbers, booleans := true, true
+	for _, value := range values {
+		switch value.(type) {
+		case nil:
+		case float64:
+			booleans = false
+		case bool:
+			numbers = false
+		default:
+			numbers, booleans = false, false
+		}
+	}
+
+	switch {
+	case numbers:
+		field := make([]*float64, len(values))
+		for i, value := range values {
+			if v, ok := value.(float64); ok {
+				field[i] = &v
+			}
+		}
+		return data.NewField(name, nil, field)
+	case booleans:
+		field := make([]*bool, len(values))
+		for i, value := range values {
+			if v, ok := value.(bool); ok {
+				field[i] = &v
+			}
+		}
+		return data.NewField(name, nil, field)
+	}
+	field := make([]*string, len(values))
+	for i, value := range values {
+		field[i] = documentString(value)
+	}
+	return data.NewField(name, nil, field)
+}
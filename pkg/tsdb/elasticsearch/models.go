@@ -15,6 +15,18 @@ type Query struct {
 	IntervalMs    int64
 	RefID         string
 	MaxDataPoints int64
+	LogContext    *LogContext
+}
+
+// LogContext makes a document query return the documents surrounding a
+// document, the ones before or after it in time
+type LogContext struct {
+	// Direction is "before" or "after"
+	Direction string `json:"direction"`
+	// SortValues are the sort values of the document, from its hit
+	SortValues []interface{} `json:"sortValues"`
+	// Limit is the number of documents returned
+	Limit int `json:"limit"`
 }
 
 // BucketAgg represents a bucket aggregation of the time series query model of the datasource
@@ -54,6 +66,8 @@ var metricAggType = map[string]string{
 	"serial_diff":    "Serial Difference",
 	"bucket_script":  "Bucket Script",
 	"raw_document":   "Raw Document",
+	"raw_data":       "Raw Data",
+	"logs":           "Logs",
 	"rate":           "Rate",
 }
 
@@ -72,6 +72,18 @@ func (rp *responseParser) getTimeSeries() (*backend.QueryDataResponse, error) {
 			continue
 		}
 
+		if isDocumentQuery(target) {
+			queryRes := processDocuments(res.Hits, target)
+			for _, frame := range queryRes.Frames {
+				if frame.Meta == nil {
+					frame.Meta = &data.FrameMeta{}
+				}
+				frame.Meta.Custom = debugInfo
+			}
+			result.Responses[target.RefID] = queryRes
+			continue
+		}
+
 		queryRes := backend.DataResponse{}
 
 		props := make(map[string]string)
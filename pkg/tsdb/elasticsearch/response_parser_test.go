@@ -1061,6 +1061,119 @@ func TestResponseParser(t *testing.T) {
 	})
 }
 
+func TestDocumentResponses(t *testing.T) {
+	response := `{
+		"responses": [
+			{
+				"hits": {
+					"total": 2,
+					"hits": [
+						{
+							"_id": "1",
+							"_index": "logs-1",
+							"_source": {"@timestamp": "2021-12-01T10:00:01.5Z", "message": "second", "level": "error", "status": 500, "kubernetes": {"pod": "a"}},
+							"fields": {"@timestamp": ["2021-12-01T10:00:01.500Z"]}
+						},
+						{
+							"_id": "2",
+							"_index": "logs-1",
+							"_source": {"@timestamp": 1638352800000, "message": "first", "status": "unknown", "ok": true}
+						}
+					]
+				}
+			}
+		]
+	}`
+
+	t.Run("Raw data query", func(t *testing.T) {
+		targets := map[string]string{
+			"A": `{
+				"timeField": "@timestamp",
+				"metrics": [{ "type": "raw_data", "id": "1" }],
+				"bucketAggs": []
+			}`,
+		}
+		rp, err := newResponseParserForTest(targets, response)
+		require.NoError(t, err)
+		result, err := rp.getTimeSeries()
+		require.NoError(t, err)
+
+		frames := result.Responses["A"].Frames
+		require.Len(t, frames, 1)
+		frame := frames[0]
+		names := []string{}
+		for _, field := range frame.Fields {
+			names = append(names, field.Name)
+		}
+		require.Equal(t, []string{"@timestamp", "_id", "_index", "kubernetes.pod", "level", "message", "ok", "status"}, names)
+		require.Equal(t, 2, frame.Rows())
+
+		require.Equal(t, time.Date(2021, 12, 1, 10, 0, 1, 500000000, time.UTC), *frame.Fields[0].At(0).(*time.Time))
+		require.Equal(t, time.Date(2021, 12, 1, 10, 0, 0, 0, time.UTC), *frame.Fields[0].At(1).(*time.Time))
+		require.Equal(t, "a", *frame.Fields[3].At(0).(*string))
+		require.Nil(t, frame.Fields[3].At(1))
+		require.Equal(t, true, *frame.Fields[6].At(1).(*bool))
+		// Numbers and strings mixed in a field are strings.
+		require.Equal(t, "500", *frame.Fields[7].At(0).(*string))
+		require.Equal(t, "unknown", *frame.Fields[7].At(1).(*string))
+		require.NotEqual(t, "logs", string(frame.Meta.PreferredVisualization))
+	})
+
+	t.Run("Logs query", func(t *testing.T) {
+		targets := map[string]string{
+			"A": `{
+				"timeField": "@timestamp",
+				"metrics": [{ "type": "logs", "id": "1" }],
+				"bucketAggs": []
+			}`,
+		}
+		rp, err := newResponseParserForTest(targets, response)
+		require.NoError(t, err)
+		result, err := rp.getTimeSeries()
+		require.NoError(t, err)
+
+		frames := result.Responses["A"].Frames
+		require.Len(t, frames, 1)
+		require.Equal(t, "logs", string(frames[0].Meta.PreferredVisualization))
+		require.Equal(t, 2, frames[0].Rows())
+	})
+}
+
+func TestLogContextResponse(t *testing.T) {
+	targets := map[string]string{
+		"A": `{
+			"timeField": "@timestamp",
+			"metrics": [{ "type": "logs", "id": "1" }],
+			"bucketAggs": [],
+			"logContext": { "direction": "after", "sortValues": [1000, 7] }
+		}`,
+	}
+	response := `{
+		"responses": [
+			{
+				"hits": {
+					"hits": [
+						{"_id": "2", "_source": {"@timestamp": 1000, "message": "next"}, "sort": [1000, 8]},
+						{"_id": "3", "_source": {"@timestamp": 3000, "message": "later"}, "sort": [3000, 2]}
+					]
+				}
+			}
+		]
+	}`
+	rp, err := newResponseParserForTest(targets, response)
+	require.NoError(t, err)
+	result, err := rp.getTimeSeries()
+	require.NoError(t, err)
+
+	frame := result.Responses["A"].Frames[0]
+	require.Equal(t, 2, frame.Rows())
+	// Newest first, like the other document frames.
+	require.Equal(t, "3", *frame.Fields[1].At(0).(*string))
+	require.Equal(t, "sort", frame.Fields[3].Name)
+	require.Equal(t, "[3000,2]", *frame.Fields[3].At(0).(*string))
+	require.Equal(t, "2", *frame.Fields[1].At(1).(*string))
+}
+
 func newResponseParserForTest(tsdbQueries map[string]string, responseBody string) (*responseParser, error) {
 	from := time.Date(2018, 5, 15, 17, 50, 0, 0, time.UTC)
 	to := time.Date(2018, 5, 15, 17, 55, 0, 0, time.UTC)
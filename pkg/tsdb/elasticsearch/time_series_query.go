@@ -1,9 +1,11 @@
 package elasticsearch
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Masterminds/semver"
@@ -79,17 +81,21 @@ func (e *timeSeriesQuery) processQuery(q *Query, ms *es.MultiSearchRequestBuilde
 		filters.AddQueryStringFilter(q.RawQuery, true)
 	}
 
+	if q.LogContext != nil && !isDocumentQuery(q) {
+		result.Responses[q.RefID] = backend.DataResponse{
+			Error: fmt.Errorf("invalid query, log context requires a logs or raw data query"),
+		}
+		return nil
+	}
+
 	if len(q.BucketAggs) == 0 {
-		if len(q.Metrics) == 0 || q.Metrics[0].Type != "raw_document" {
+		if !isDocumentQuery(q) {
 			result.Responses[q.RefID] = backend.DataResponse{
 				Error: fmt.Errorf("invalid query, missing metrics and aggregations"),
 			}
 			return nil
 		}
-		metric := q.Metrics[0]
-		b.Size(metric.Settings.Get("size").MustInt(500))
-		b.SortDesc("@timestamp", "boolean")
-		b.AddDocValueField("@timestamp")
+		addDocumentQuery(b, q)
 		return nil
 	}
 
@@ -400,6 +406,10 @@ func (p *timeSeriesQueryParser) parse(tsdbQuery []backend.DataQuery) ([]*Query,
 		if err != nil {
 			return nil, err
 		}
+		logContext, err := p.parseLogContext(model)
+		if err != nil {
+			return nil, err
+		}
 		alias := model.Get("alias").MustString("")
 		interval := model.Get("interval").MustString("")
 
@@ -412,12 +422,46 @@ func (p *timeSeriesQueryParser) parse(tsdbQuery []backend.DataQuery) ([]*Query,
 			Interval:      interval,
 			RefID:         q.RefID,
 			MaxDataPoints: q.MaxDataPoints,
+			LogContext:    logContext,
 		})
 	}
 
 	return queries, nil
 }
 
+func (p *timeSeriesQueryParser) parseLogContext(model *simplejson.Json) (*LogContext, error) {
+	raw, ok := model.CheckGet("logContext")
+	if !ok {
+		return nil, nil
+	}
+	bytes, err := raw.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	logContext := &LogContext{}
+	// Sort values are kept as numbers, as floats would lose the precision of
+	// nanosecond timestamps.
+	decoder := json.NewDecoder(strings.NewReader(string(bytes)))
+	decoder.UseNumber()
+	if err := decoder.Decode(logContext); err != nil {
+		return nil, err
+	}
+
+	if logContext.Direction != logContextBefore && logContext.Direction != logContextAfter {
+		return nil, fmt.Errorf("invalid log context direction %q", logContext.Direction)
+	}
+	if len(logContext.SortValues) != 2 {
+		return nil, fmt.Errorf("log context requires the sort values of a document")
+	}
+	if logContext.Limit < 0 {
+		return nil, fmt.Errorf("invalid log context limit %d", logContext.Limit)
+	}
+	if logContext.Limit == 0 {
+		logContext.Limit = defaultLogContextLimit
+	}
+	return logContext, nil
+}
+
 func (p *timeSeriesQueryParser) parseBucketAggs(model *simplejson.Json) ([]*BucketAgg, error) {
 	var err error
 	var result []*BucketAgg
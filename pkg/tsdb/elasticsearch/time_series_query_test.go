@@ -377,6 +377,68 @@ func TestExecuteTimeSeriesQuery(t *testing.T) {
 			require.Equal(t, sr.Size, 1337)
 		})
 
+		t.Run("With logs metric", func(t *testing.T) {
+			c := newFakeClient("7.10.0")
+			_, err := executeTsdbQuery(c, `{
+				"timeField": "timestamp",
+				"bucketAggs": [],
+				"metrics": [{ "id": "1", "type": "logs", "settings": { "limit": 100 }	}]
+			}`, from, to, 15*time.Second)
+			require.NoError(t, err)
+			sr := c.multisearchRequests[0].Requests[0]
+
+			require.Equal(t, 100, sr.Size)
+			require.Equal(t, map[string]string{"order": "desc", "unmapped_type": "boolean"}, sr.Sort["timestamp"])
+			require.Equal(t, map[string]string{"order": "desc"}, sr.Sort["_doc"])
+			require.Equal(t, []string{"timestamp", "_doc"}, sr.SortFields)
+			require.Equal(t, []string{"timestamp"}, sr.CustomProps["docvalue_fields"])
+		})
+
+		t.Run("With log context", func(t *testing.T) {
+			c := newFakeClient("7.10.0")
+			_, err := executeTsdbQuery(c, `{
+				"timeField": "@timestamp",
+				"bucketAggs": [],
+				"metrics": [{ "id": "1", "type": "logs" }],
+				"logContext": { "direction": "after", "sortValues": [1638352800000, 42] }
+			}`, from, to, 15*time.Second)
+			require.NoError(t, err)
+			sr := c.multisearchRequests[0].Requests[0]
+
+			require.Equal(t, defaultLogContextLimit, sr.Size)
+			require.Equal(t, map[string]string{"order": "asc", "unmapped_type": "boolean"}, sr.Sort["@timestamp"])
+			require.Equal(t, map[string]string{"order": "asc"}, sr.Sort["_doc"])
+			require.Equal(t, []string{"@timestamp", "_doc"}, sr.SortFields)
+			require.Equal(t, []interface{}{json.Number("1638352800000"), json.Number("42")}, sr.CustomProps["search_after"])
+		})
+
+		t.Run("With invalid log context", func(t *testing.T) {
+			c := newFakeClient("7.10.0")
+			_, err := executeTsdbQuery(c, `{
+				"timeField": "@timestamp",
+				"bucketAggs": [],
+				"metrics": [{ "id": "1", "type": "logs" }],
+				"logContext": { "direction": "around", "sortValues": [1638352800000, 42] }
+			}`, from, to, 15*time.Second)
+			require.EqualError(t, err, `invalid log context direction "around"`)
+
+			_, err = executeTsdbQuery(c, `{
+				"timeField": "@timestamp",
+				"bucketAggs": [],
+				"metrics": [{ "id": "1", "type": "logs" }],
+				"logContext": { "direction": "before" }
+			}`, from, to, 15*time.Second)
+			require.EqualError(t, err, "log context requires the sort values of a document")
+
+			_, err = executeTsdbQuery(c, `{
+				"timeField": "@timestamp",
+				"bucketAggs": [],
+				"metrics": [{ "id": "1", "type": "logs" }],
+				"logContext": { "direction": "before", "sortValues": [1638352800000] }
+			}`, from, to, 15*time.Second)
+			require.EqualError(t, err, "log context requires the sort values of a document")
+		})
+
 		t.Run("With date histogram agg", func(t *testing.T) {
 			c := newFakeClient("5.0.0")
 			_, err := executeTsdbQuery(c, `{
@@ -20,6 +20,7 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 
 	"github.com/grafana/grafana/pkg/components/simplejson"
@@ -27,26 +28,39 @@ import (
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
 	"github.com/grafana/grafana/pkg/tsdb/legacydata"
+	"github.com/grafana/grafana/pkg/tsdb/legend"
 )
 
 type Service struct {
 	logger log.Logger
 	im     instancemgmt.InstanceManager
 	tracer tracing.Tracer
+
+	resourceHandler backend.CallResourceHandler
 }
 
 const (
-	TargetFullModelField = "targetFull"
-	TargetModelField     = "target"
+	TargetFullModelField   = "targetFull"
+	TargetModelField       = "target"
+	LegendFormatModelField = "legendFormat"
+
+	defaultMaxDataPoints = 500
 )
 
 func ProvideService(httpClientProvider httpclient.Provider, tracer tracing.Tracer) *Service {
-	return &Service{
+	s := &Service{
 		logger: log.New("tsdb.graphite"),
 		im:     datasource.NewInstanceManager(newInstanceSettings(httpClientProvider)),
 		tracer: tracer,
 	}
+	s.resourceHandler = httpadapter.New(s.newResourceMux())
+	return s
+}
+
+func (s *Service) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return s.resourceHandler.CallResource(ctx, req, sender)
 }
 
 type datasourceInfo struct {
@@ -97,23 +111,12 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 		return nil, err
 	}
 
-	// take the first query in the request list, since all query should share the same timerange
-	q := req.Queries[0]
-
-	/*
-		graphite doc about from and until, with sdk we are getting absolute instead of relative time
-		https://graphite-api.readthedocs.io/en/latest/api.html#from-until
-	*/
-	from, until := epochMStoGraphiteTime(q.TimeRange)
-	formData := url.Values{
-		"from":          []string{from},
-		"until":         []string{until},
-		"format":        []string{"json"},
-		"maxDataPoints": []string{"500"},
-	}
-
-	// Calculate and get the last target of Graphite Request
-	var target string
+	// Each query is rendered on its own. Queries referencing other queries get
+	// them resolved in their targetFull by the frontend, so the referenced
+	// queries are only rendered when they are not hidden. Alerts render their
+	// query even if it's hidden in the panel.
+	fromAlert := req.Headers["FromAlert"] == "true"
+	result := backend.NewQueryDataResponse()
 	emptyQueries := make([]string, 0)
 	for _, query := range req.Queries {
 		model, err := simplejson.NewJson(query.JSON)
@@ -121,6 +124,9 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 			return nil, err
 		}
 		s.logger.Debug("graphite", "query", model)
+		if model.Get("hide").MustBool() && !fromAlert {
+			continue
+		}
 		currTarget := ""
 		if fullTarget, err := model.Get(TargetFullModelField).String(); err == nil {
 			currTarget = fullTarget
@@ -132,17 +138,44 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 			emptyQueries = append(emptyQueries, fmt.Sprintf("Query: %v has no target", model))
 			continue
 		}
-		target = fixIntervalFormat(currTarget)
-	}
 
-	var result = backend.QueryDataResponse{}
+		target := fixIntervalFormat(interpolateInterval(currTarget, query.Interval))
+		frames, err := s.render(ctx, req.PluginContext, dsInfo, query, target, model.Get(LegendFormatModelField).MustString())
+		result.Responses[query.RefID] = backend.DataResponse{
+			Frames: frames,
+			Error:  err,
+		}
+	}
 
-	if target == "" {
+	if len(result.Responses) == 0 {
 		s.logger.Error("No targets in query model", "models without targets", strings.Join(emptyQueries, "\n"))
-		return &result, errors.New("no query target found for the alert rule")
+		return &backend.QueryDataResponse{}, errors.New("no query target found for the alert rule")
 	}
 
-	formData["target"] = []string{target}
+	return result, nil
+}
+
+// render fetches the series of the target with the render API of Graphite,
+// consolidated to the max data points of the query, and names them with the
+// legend format when set.
+func (s *Service) render(ctx context.Context, pluginCtx backend.PluginContext, dsInfo *datasourceInfo, query backend.DataQuery,
+	target string, legendFormat string) (data.Frames, error) {
+	/*
+		graphite doc about from and until, with sdk we are getting absolute instead of relative time
+		https://graphite-api.readthedocs.io/en/latest/api.html#from-until
+	*/
+	from, until := epochMStoGraphiteTime(query.TimeRange)
+	maxDataPoints := query.MaxDataPoints
+	if maxDataPoints <= 0 {
+		maxDataPoints = defaultMaxDataPoints
+	}
+	formData := url.Values{
+		"from":          []string{from},
+		"until":         []string{until},
+		"format":        []string{"json"},
+		"maxDataPoints": []string{strconv.FormatInt(maxDataPoints, 10)},
+		"target":        []string{target},
+	}
 
 	if setting.Env == setting.Dev {
 		s.logger.Debug("Graphite request", "params", formData)
@@ -150,7 +183,7 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 
 	graphiteReq, err := s.createRequest(dsInfo, formData)
 	if err != nil {
-		return &result, err
+		return nil, err
 	}
 
 	ctx, span := s.tracer.Start(ctx, "graphite query")
@@ -158,30 +191,24 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 	span.SetAttributes("from", from, attribute.Key("from").String(from))
 	span.SetAttributes("until", until, attribute.Key("until").String(until))
 	span.SetAttributes("datasource_id", dsInfo.Id, attribute.Key("datasource_id").Int64(dsInfo.Id))
-	span.SetAttributes("org_id", req.PluginContext.OrgID, attribute.Key("org_id").Int64(req.PluginContext.OrgID))
+	span.SetAttributes("org_id", pluginCtx.OrgID, attribute.Key("org_id").Int64(pluginCtx.OrgID))
 
 	defer span.End()
 	s.tracer.Inject(ctx, graphiteReq.Header, span)
 
 	res, err := ctxhttp.Do(ctx, dsInfo.HTTPClient, graphiteReq)
 	if err != nil {
-		return &result, err
+		return nil, err
 	}
 
-	frames, err := s.toDataFrames(res)
+	frames, err := s.toDataFrames(res, legendFormat)
 	if err != nil {
-		return &result, err
-	}
-
-	result = backend.QueryDataResponse{
-		Responses: make(backend.Responses),
+		return nil, err
 	}
-
-	result.Responses["A"] = backend.DataResponse{
-		Frames: frames,
+	for _, frame := range frames {
+		frame.RefID = query.RefID
 	}
-
-	return &result, nil
+	return frames, nil
 }
 
 func (s *Service) parseResponse(res *http.Response) ([]TargetResponseDTO, error) {
@@ -210,7 +237,7 @@ func (s *Service) parseResponse(res *http.Response) ([]TargetResponseDTO, error)
 	return data, nil
 }
 
-func (s *Service) toDataFrames(response *http.Response) (frames data.Frames, error error) {
+func (s *Service) toDataFrames(response *http.Response, legendFormat string) (frames data.Frames, error error) {
 	responseData, err := s.parseResponse(response)
 	if err != nil {
 		return nil, err
@@ -241,6 +268,14 @@ func (s *Service) toDataFrames(response *http.Response) (frames data.Frames, err
 			}
 		}
 
+		// The legend is rendered from the tags of the series, falling back to
+		// its target when it renders empty.
+		if legendFormat != "" {
+			if formatted := legend.Format(legendFormat, tags, legend.Options{}); formatted != "" {
+				name = formatted
+			}
+		}
+
 		frames = append(frames, data.NewFrame(name,
 			data.NewField("time", nil, timeVector),
 			data.NewField("value", tags, values).SetConfig(&data.FieldConfig{DisplayNameFromDS: name})))
@@ -281,6 +316,17 @@ func fixIntervalFormat(target string) string {
 	return target
 }
 
+// interpolateInterval replaces the interval variables of the target by the
+// interval of the query, $__interval in the unit format fixIntervalFormat
+// turns into Graphite units.
+func interpolateInterval(target string, interval time.Duration) string {
+	if interval <= 0 {
+		return target
+	}
+	target = strings.ReplaceAll(target, "$__interval_ms", strconv.FormatInt(interval.Milliseconds(), 10))
+	return strings.ReplaceAll(target, "$__interval", intervalv2.FormatDuration(interval))
+}
+
 func epochMStoGraphiteTime(tr backend.TimeRange) (string, string) {
 	return fmt.Sprintf("%d", tr.From.UTC().Unix()), fmt.Sprintf("%d", tr.To.UTC().Unix())
 }
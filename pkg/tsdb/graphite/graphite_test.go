@@ -1,16 +1,24 @@
 package graphite
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/tsdb/tsdbtest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -69,7 +77,7 @@ func TestFixIntervalFormat(t *testing.T) {
 		expectedFrames := data.Frames{expectedFrame}
 
 		httpResponse := &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body))}
-		dataFrames, err := service.toDataFrames(httpResponse)
+		dataFrames, err := service.toDataFrames(httpResponse, "")
 
 		require.NoError(t, err)
 		if !reflect.DeepEqual(expectedFrames, dataFrames) {
@@ -102,7 +110,7 @@ func TestFixIntervalFormat(t *testing.T) {
 		expectedFrames := data.Frames{expectedFrame}
 
 		httpResponse := &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body))}
-		dataFrames, err := service.toDataFrames(httpResponse)
+		dataFrames, err := service.toDataFrames(httpResponse, "")
 
 		require.NoError(t, err)
 		if !reflect.DeepEqual(expectedFrames, dataFrames) {
@@ -111,4 +119,121 @@ func TestFixIntervalFormat(t *testing.T) {
 			t.Errorf("Data frames should have been equal but was, expected:\n%s\nactual:\n%s", expectedFramesJSON, dataFramesJSON)
 		}
 	})
+
+	t.Run("names the series with the legend format", func(t *testing.T) {
+		body := `[
+			{"target": "a.b", "tags": {"name": "a.b", "server": "web-1"}, "datapoints": [[1, 1]]},
+			{"target": "c.d", "tags": {}, "datapoints": [[1, 1]]}
+		]`
+
+		httpResponse := &http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(body))}
+		dataFrames, err := service.toDataFrames(httpResponse, `{{server | trimPrefix "web-" | upper}}{{missing}}`)
+		require.NoError(t, err)
+
+		require.Len(t, dataFrames, 2)
+		require.Equal(t, "1", dataFrames[0].Name)
+		require.Equal(t, "1", dataFrames[0].Fields[1].Config.DisplayNameFromDS)
+		// Legends rendering empty fall back to the target.
+		require.Equal(t, "c.d", dataFrames[1].Name)
+	})
+}
+
+func newTestService(t *testing.T, handler http.HandlerFunc) *Service {
+	srv := tsdbtest.NewServer(t, handler)
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+
+	s := &Service{
+		logger: log.New("tsdb.graphite"),
+		im:     &tsdbtest.FakeInstanceManager{Instance: datasourceInfo{HTTPClient: srv.Client(), URL: srv.URL}},
+		tracer: tracer,
+	}
+	s.resourceHandler = httpadapter.New(s.newResourceMux())
+	return s
+}
+
+func TestQueryData(t *testing.T) {
+	var mu sync.Mutex
+	var requests []url.Values
+	s := newTestService(t, func(rw http.ResponseWriter, req *http.Request) {
+		require.Equal(t, "/render", req.URL.Path)
+		require.NoError(t, req.ParseForm())
+		mu.Lock()
+		requests = append(requests, req.PostForm)
+		mu.Unlock()
+
+		if req.PostForm.Get("target") == "fail" {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, err := fmt.Fprintf(rw, `[{"target": %q, "datapoints": [[1, 1], [2, 2]]}]`, req.PostForm.Get("target"))
+		require.NoError(t, err)
+	})
+
+	timeRange := backend.TimeRange{From: time.Unix(1000, 0), To: time.Unix(2000, 0)}
+	res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				RefID:         "A",
+				TimeRange:     timeRange,
+				MaxDataPoints: 100,
+				Interval:      time.Minute,
+				JSON:          json.RawMessage(`{"target": "summarize(a.b, '$__interval')"}`),
+			},
+			{
+				RefID:     "B",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"target": "sumSeries(#A)", "targetFull": "sumSeries(a.b)"}`),
+			},
+			{
+				RefID:     "C",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"target": "c.d", "hide": true}`),
+			},
+			{
+				RefID:     "D",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"target": "fail"}`),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, requests, 3)
+	assert.Equal(t, url.Values{
+		"from":          []string{"1000"},
+		"until":         []string{"2000"},
+		"format":        []string{"json"},
+		"maxDataPoints": []string{"100"},
+		"target":        []string{"summarize(a.b, '1min')"},
+	}, requests[0])
+	assert.Equal(t, "sumSeries(a.b)", requests[1].Get("target"))
+	assert.Equal(t, "500", requests[1].Get("maxDataPoints"))
+
+	require.Len(t, res.Responses, 3)
+	for _, refID := range []string{"A", "B"} {
+		require.NoError(t, res.Responses[refID].Error)
+		require.Len(t, res.Responses[refID].Frames, 1)
+		assert.Equal(t, refID, res.Responses[refID].Frames[0].RefID)
+		assert.Equal(t, 2, res.Responses[refID].Frames[0].Rows())
+	}
+	require.Error(t, res.Responses["D"].Error)
+
+	res, err = s.QueryData(context.Background(), &backend.QueryDataRequest{
+		Headers: map[string]string{"FromAlert": "true"},
+		Queries: []backend.DataQuery{{RefID: "A", TimeRange: timeRange, JSON: json.RawMessage(`{"target": "c.d", "hide": true}`)}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, res.Responses["A"].Error)
+	assert.Equal(t, "c.d", requests[3].Get("target"))
+
+	_, err = s.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{RefID: "A", JSON: json.RawMessage(`{"target": ""}`)}},
+	})
+	require.EqualError(t, err, "no query target found for the alert rule")
+}
+
+func TestInterpolateInterval(t *testing.T) {
+	assert.Equal(t, "summarize(a, '10s'), 10000", interpolateInterval("summarize(a, '$__interval'), $__interval_ms", 10*time.Second))
+	assert.Equal(t, "summarize(a, '$__interval')", interpolateInterval("summarize(a, '$__interval')", 0))
 }
@@ -0,0 +1,119 @@
+package graphite
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// autoCompleteParams are the parameters of the tag autocomplete API of Graphite.
+var autoCompleteParams = []string{"expr", "tagPrefix", "tag", "valuePrefix", "limit", "from", "until"}
+
+// infinityDefaultExp matches the Infinity defaults of function parameters,
+// which Graphite returns although they are not valid JSON.
+var infinityDefaultExp = regexp.MustCompile(`"default": ?Infinity`)
+
+func (s *Service) newResourceMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tags/autoComplete/tags", s.handleAutoComplete)
+	mux.HandleFunc("/tags/autoComplete/values", s.handleAutoComplete)
+	mux.HandleFunc("/functions", s.handleFunctions)
+	return mux
+}
+
+func (s *Service) handleAutoComplete(rw http.ResponseWriter, req *http.Request) {
+	params := url.Values{}
+	for _, name := range autoCompleteParams {
+		if values, ok := req.URL.Query()[name]; ok {
+			params[name] = values
+		}
+	}
+	s.proxyResource(rw, req, params, nil)
+}
+
+func (s *Service) handleFunctions(rw http.ResponseWriter, req *http.Request) {
+	s.proxyResource(rw, req, url.Values{}, func(body []byte) []byte {
+		return infinityDefaultExp.ReplaceAll(body, []byte(`"default": 1e9999`))
+	})
+}
+
+// proxyResource forwards the request to the same path of the Graphite API
+// with the given parameters, and writes its response after fixing its body
+// with fixBody if set.
+func (s *Service) proxyResource(rw http.ResponseWriter, req *http.Request, params url.Values, fixBody func([]byte) []byte) {
+	s.logger.Debug("Received resource call", "url", req.URL.String(), "method", req.Method)
+	if req.Method != http.MethodGet {
+		writeResourceError(rw, http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed", req.Method))
+		return
+	}
+
+	pluginCtx := httpadapter.PluginConfigFromContext(req.Context())
+	if pluginCtx.DataSourceInstanceSettings == nil {
+		writeResourceError(rw, http.StatusBadRequest, "missing data source")
+		return
+	}
+	dsInfo, err := s.getDSInfo(pluginCtx)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	u, err := url.Parse(dsInfo.URL)
+	if err != nil {
+		writeResourceError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	u.Path = path.Join(u.Path, req.URL.Path)
+	u.RawQuery = params.Encode()
+
+	graphiteReq, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		writeResourceError(rw, http.StatusInternalServerError, err.Error())
+		return
+	}
+	res, err := ctxhttp.Do(req.Context(), dsInfo.HTTPClient, graphiteReq)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			s.logger.Warn("Failed to close response body", "err", err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadGateway, err.Error())
+		return
+	}
+	if fixBody != nil && res.StatusCode/100 == 2 {
+		body = fixBody(body)
+	}
+
+	if contentType := res.Header.Get("Content-Type"); contentType != "" {
+		rw.Header().Set("Content-Type", contentType)
+	}
+	rw.WriteHeader(res.StatusCode)
+	if _, err := rw.Write(body); err != nil {
+		s.logger.Error("Failed to write resource response", "err", err)
+	}
+}
+
+func writeResourceError(rw http.ResponseWriter, code int, message string) {
+	b, err := json.Marshal(map[string]string{"message": message})
+	if err != nil {
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	_, _ = rw.Write(b)
+}
@@ -0,0 +1,59 @@
+package graphite
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResources(t *testing.T) {
+	s := newTestService(t, func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/tags/autoComplete/tags":
+			assert.Equal(t, url.Values{"expr": []string{"a=b", "c=d"}, "tagPrefix": []string{"na"}}, req.URL.Query())
+			_, _ = rw.Write([]byte(`["name"]`))
+		case "/functions":
+			_, _ = rw.Write([]byte(`{"limit": {"params": [{"name": "n", "default": Infinity}]}}`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	call := func(path string, query string) *backend.CallResourceResponse {
+		sender := &fakeSender{}
+		err := s.CallResource(context.Background(), &backend.CallResourceRequest{
+			PluginContext: backend.PluginContext{DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{}},
+			Method:        http.MethodGet,
+			Path:          path,
+			URL:           path + query,
+		}, sender)
+		require.NoError(t, err)
+		return sender.res
+	}
+
+	res := call("tags/autoComplete/tags", "?expr=a%3Db&expr=c%3Dd&tagPrefix=na&ignored=1")
+	assert.Equal(t, http.StatusOK, res.Status)
+	assert.Equal(t, `["name"]`, string(res.Body))
+
+	// Graphite returns Infinity defaults, the frontend parses 1e9999 as Infinity.
+	res = call("functions", "")
+	assert.Equal(t, http.StatusOK, res.Status)
+	assert.Equal(t, `{"limit": {"params": [{"name": "n", "default": 1e9999}]}}`, string(res.Body))
+
+	res = call("tags/autoComplete/values", "")
+	assert.Equal(t, http.StatusNotFound, res.Status)
+}
+
+type fakeSender struct {
+	res *backend.CallResourceResponse
+}
+
+func (s *fakeSender) Send(res *backend.CallResourceResponse) error {
+	s.res = res
+	return nil
+}
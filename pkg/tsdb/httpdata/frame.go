@@ -0,0 +1,355 @@
+package httpdata
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/ohler55/ojg/jp"
+	"github.com/ohler55/ojg/oj"
+)
+
+// The types of the columns.
+const (
+	typeString  = "string"
+	typeNumber  = "number"
+	typeTime    = "time"
+	typeBoolean = "boolean"
+)
+
+// valueColumn is the name of the field of JSON rows which are not objects,
+// when the query has no columns.
+const valueColumn = "value"
+
+// jsonFrame returns a frame with a row for each value at the root of the JSON
+// body, which are all the values of the arrays it selects. Without columns,
+// the frame has a field for each key of the objects.
+func jsonFrame(body []byte, root string, columns []column) (*data.Frame, error) {
+	// The parser of ojg accepts truncated objects, which the standard library
+	// catches.
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("failed to parse JSON response: invalid or truncated JSON")
+	}
+	doc, err := oj.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
+	}
+
+	rows, err := jsonRows(doc, root)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		columns = jsonColumns(rows)
+	}
+
+	fields := make([]*data.Field, 0, len(columns))
+	for _, col := range columns {
+		get, err := jsonSelector(col.Selector)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(rows))
+		for i, row := range rows {
+			values[i] = get(row)
+		}
+		field, err := newField(col, values)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return data.NewFrame("", fields...), nil
+}
+
+func jsonRows(doc interface{}, root string) ([]interface{}, error) {
+	if root == "" || root == "$" {
+		if rows, ok := doc.([]interface{}); ok {
+			return rows, nil
+		}
+		return []interface{}{doc}, nil
+	}
+
+	x, err := jp.ParseString(root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid root %q: %w", root, err)
+	}
+	selected := x.Get(doc)
+	if len(selected) == 1 {
+		if rows, ok := selected[0].([]interface{}); ok {
+			return rows, nil
+		}
+	}
+	return selected, nil
+}
+
+// jsonColumns returns the columns of all the keys of the rows, in order of
+// appearance and sorted within each row, since the order of the keys of JSON
+// objects is not kept.
+func jsonColumns(rows []interface{}) []column {
+	var columns []column
+	seen := map[string]bool{}
+	for _, row := range rows {
+		obj, ok := row.(map[string]interface{})
+		if !ok {
+			if !seen[""] {
+				seen[""] = true
+				columns = append(columns, column{Name: valueColumn})
+			}
+			continue
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			columns = append(columns, column{Selector: key})
+		}
+	}
+	return columns
+}
+
+// jsonSelector returns a function getting the value of a row the selector
+// points to: the row itself when empty, the value of a JSONPath when it starts
+// with $, or else the value of the key of objects.
+func jsonSelector(selector string) (func(interface{}) interface{}, error) {
+	if selector == "" {
+		return func(row interface{}) interface{} {
+			if _, ok := row.(map[string]interface{}); ok {
+				return nil
+			}
+			return row
+		}, nil
+	}
+
+	if !strings.HasPrefix(selector, "$") {
+		return func(row interface{}) interface{} {
+			if obj, ok := row.(map[string]interface{}); ok {
+				return obj[selector]
+			}
+			return nil
+		}, nil
+	}
+
+	x, err := jp.ParseString(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	return func(row interface{}) interface{} {
+		return x.First(row)
+	}, nil
+}
+
+// csvFrame returns a frame with a row for each record of the CSV body after
+// the header. Without columns, the frame has a field for each column of the
+// header.
+func csvFrame(body []byte, columns []column) (*data.Frame, error) {
+	reader := csv.NewReader(bytes.NewReader(body))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV response: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV response has no header")
+	}
+
+	header, records := records[0], records[1:]
+	if len(columns) == 0 {
+		columns = make([]column, len(header))
+		for i, name := range header {
+			columns[i] = column{Selector: name}
+		}
+	}
+
+	fields := make([]*data.Field, 0, len(columns))
+	for _, col := range columns {
+		index := -1
+		for i, name := range header {
+			if name == col.Selector {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return nil, fmt.Errorf("column %q not found in CSV header", col.Selector)
+		}
+
+		values := make([]interface{}, len(records))
+		for i, record := range records {
+			if index < len(record) && record[index] != "" {
+				values[i] = record[index]
+			}
+		}
+		field, err := newField(col, values)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return data.NewFrame("", fields...), nil
+}
+
+// newField returns the field of the column with the values, which are nil
+// when missing.
+func newField(col column, values []interface{}) (*data.Field, error) {
+	name := col.Name
+	if name == "" {
+		name = col.Selector
+	}
+
+	colType := col.Type
+	if colType == "" {
+		colType = inferType(values)
+	}
+
+	var field *data.Field
+	switch colType {
+	case typeString:
+		strs := make([]*string, len(values))
+		for i, v := range values {
+			if v != nil {
+				s := stringValue(v)
+				strs[i] = &s
+			}
+		}
+		field = data.NewField(name, nil, strs)
+	case typeNumber:
+		nums := make([]*float64, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			n, err := numberValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number in column %q: %w", name, err)
+			}
+			nums[i] = &n
+		}
+		field = data.NewField(name, nil, nums)
+	case typeBoolean:
+		bools := make([]*bool, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			b, err := booleanValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid boolean in column %q: %w", name, err)
+			}
+			bools[i] = &b
+		}
+		field = data.NewField(name, nil, bools)
+	case typeTime:
+		times := make([]*time.Time, len(values))
+		for i, v := range values {
+			if v == nil {
+				continue
+			}
+			t, err := timeValue(v, col.TimeFormat)
+			if err != nil {
+				return nil, fmt.Errorf("invalid time in column %q: %w", name, err)
+			}
+			times[i] = &t
+		}
+		field = data.NewField(name, nil, times)
+	default:
+		return nil, fmt.Errorf("unsupported type %q of column %q", col.Type, name)
+	}
+	return field, nil
+}
+
+// inferType returns the type of the values, number or boolean when they all
+// are, and string otherwise.
+func inferType(values []interface{}) string {
+	numbers, booleans := true, true
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		if _, err := numberValue(v); err != nil {
+			numbers = false
+		}
+		if _, ok := v.(bool); !ok {
+			booleans = false
+		}
+	}
+	switch {
+	case numbers:
+		return typeNumber
+	case booleans:
+		return typeBoolean
+	}
+	return typeString
+}
+
+func stringValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	// Objects and arrays are kept as JSON.
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func numberValue(v interface{}) (float64, error) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+func booleanValue(v interface{}) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(strings.TrimSpace(v))
+	}
+	return false, fmt.Errorf("%v is not a boolean", v)
+}
+
+// timeValue parses times, which are epoch milliseconds when numbers, and
+// strings in the layout, or RFC 3339, otherwise.
+func timeValue(v interface{}, layout string) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t, nil
+		}
+	}
+	ms, err := numberValue(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%v is neither epoch milliseconds nor a time in the layout %q", v, layout)
+	}
+	return time.UnixMilli(int64(math.Round(ms))).UTC(), nil
+}
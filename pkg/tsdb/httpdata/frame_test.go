@@ -0,0 +1,117 @@
+package httpdata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONFrame(t *testing.T) {
+	t.Run("infers the columns of objects", func(t *testing.T) {
+		frame, err := jsonFrame([]byte(`[
+			{"b": 1, "a": "x"},
+			{"a": "y", "c": true, "d": {"e": 1}}
+		]`), "", nil)
+		require.NoError(t, err)
+
+		require.Len(t, frame.Fields, 4)
+		names := []string{}
+		for _, f := range frame.Fields {
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "d"}, names)
+		assert.Equal(t, data.FieldTypeNullableString, frame.Fields[0].Type())
+		assert.Equal(t, data.FieldTypeNullableFloat64, frame.Fields[1].Type())
+		assert.Equal(t, data.FieldTypeNullableBool, frame.Fields[2].Type())
+		assert.Equal(t, data.FieldTypeNullableString, frame.Fields[3].Type())
+		assert.Nil(t, frame.Fields[1].At(1))
+		assert.Equal(t, `{"e":1}`, *frame.Fields[3].At(1).(*string))
+	})
+
+	t.Run("maps values which are not objects", func(t *testing.T) {
+		frame, err := jsonFrame([]byte(`{"values": [1, 2, 3]}`), "$.values", nil)
+		require.NoError(t, err)
+		require.Len(t, frame.Fields, 1)
+		assert.Equal(t, valueColumn, frame.Fields[0].Name)
+		assert.Equal(t, 3, frame.Rows())
+	})
+
+	t.Run("selects the rows of all the matches of the root", func(t *testing.T) {
+		frame, err := jsonFrame([]byte(`{"hosts": [{"cpu": 1}, {"cpu": 2}]}`), "$.hosts[*]",
+			[]column{{Selector: "cpu", Type: typeString}})
+		require.NoError(t, err)
+		require.Equal(t, 2, frame.Rows())
+		assert.Equal(t, "2", *frame.Fields[0].At(1).(*string))
+	})
+
+	t.Run("parses times", func(t *testing.T) {
+		frame, err := jsonFrame([]byte(`[
+			{"ms": 1633046400000, "date": "01/10/2021"},
+			{"ms": "1633046460000", "date": null}
+		]`), "", []column{
+			{Selector: "ms", Type: typeTime},
+			{Selector: "date", Type: typeTime, TimeFormat: "02/01/2006"},
+		})
+		require.NoError(t, err)
+		expected := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+		assert.Equal(t, expected, *frame.Fields[0].At(0).(*time.Time))
+		assert.Equal(t, expected.Add(time.Minute), *frame.Fields[0].At(1).(*time.Time))
+		assert.Equal(t, expected, *frame.Fields[1].At(0).(*time.Time))
+		assert.Nil(t, frame.Fields[1].At(1))
+	})
+
+	t.Run("fails on invalid values", func(t *testing.T) {
+		_, err := jsonFrame([]byte(`[{"a": "x"}]`), "", []column{{Selector: "a", Type: typeNumber}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid number in column "a"`)
+
+		_, err = jsonFrame([]byte(`[{"a": "x"}]`), "", []column{{Selector: "a", Type: "duration"}})
+		require.Error(t, err)
+
+		_, err = jsonFrame([]byte(`[{"a": "x"}]`), "$[", nil)
+		require.Error(t, err)
+
+		_, err = jsonFrame([]byte(`{"a": `), "", nil)
+		require.Error(t, err)
+	})
+}
+
+func TestCSVFrame(t *testing.T) {
+	body := []byte("time,host,value,up\n2021-10-01T00:00:00Z,a,1.5,true\n2021-10-01T00:01:00Z,b,,false\n")
+
+	t.Run("maps all the columns of the header", func(t *testing.T) {
+		frame, err := csvFrame(body, nil)
+		require.NoError(t, err)
+		require.Len(t, frame.Fields, 4)
+		require.Equal(t, 2, frame.Rows())
+		assert.Equal(t, data.FieldTypeNullableString, frame.Fields[0].Type())
+		assert.Equal(t, data.FieldTypeNullableString, frame.Fields[1].Type())
+		assert.Equal(t, data.FieldTypeNullableFloat64, frame.Fields[2].Type())
+		assert.Nil(t, frame.Fields[2].At(1))
+	})
+
+	t.Run("maps the columns of the query", func(t *testing.T) {
+		frame, err := csvFrame(body, []column{
+			{Selector: "time", Type: typeTime},
+			{Selector: "value", Name: "cpu"},
+			{Selector: "up", Type: typeBoolean},
+		})
+		require.NoError(t, err)
+		require.Len(t, frame.Fields, 3)
+		assert.Equal(t, "cpu", frame.Fields[1].Name)
+		assert.Equal(t, time.Date(2021, 10, 1, 0, 1, 0, 0, time.UTC), *frame.Fields[0].At(1).(*time.Time))
+		assert.Equal(t, false, *frame.Fields[2].At(1).(*bool))
+	})
+
+	t.Run("fails on missing columns", func(t *testing.T) {
+		_, err := csvFrame(body, []column{{Selector: "cpu"}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `column "cpu" not found`)
+
+		_, err = csvFrame(nil, nil)
+		require.Error(t, err)
+	})
+}
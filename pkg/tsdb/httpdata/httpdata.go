@@ -0,0 +1,325 @@
+package httpdata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/query/resultlimits"
+)
+
+// maxRedirects is the number of redirects followed, like the default of the
+// http package.
+const maxRedirects = 10
+
+// defaultMaxResponseBytes is the size responses are limited to when the data
+// source doesn't set maxResponseBytes.
+const defaultMaxResponseBytes = 10 * 1024 * 1024
+
+// errResponseTooLarge is the error of responses larger than the limit.
+var errResponseTooLarge = errors.New("response is too large")
+
+// The formats of the responses.
+const (
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+type Service struct {
+	im     instancemgmt.InstanceManager
+	logger log.Logger
+}
+
+func ProvideService(httpClientProvider httpclient.Provider) *Service {
+	return &Service{
+		logger: log.New("tsdb.httpdata"),
+		im:     datasource.NewInstanceManager(newInstanceSettings(httpClientProvider)),
+	}
+}
+
+type datasourceInfo struct {
+	HTTPClient *http.Client
+	URL        *url.URL
+	// MaxResponseBytes is the size of the largest response that is read.
+	MaxResponseBytes int64
+}
+
+type jsonData struct {
+	MaxResponseBytes int64 `json:"maxResponseBytes"`
+}
+
+type queryModel struct {
+	// Path is appended to the URL of the data source.
+	Path   string            `json:"path"`
+	Method string            `json:"method"`
+	Params map[string]string `json:"params"`
+	// Body is the body of POST requests.
+	Body   string `json:"body"`
+	Format string `json:"format"`
+	// Root is the JSONPath of the rows in JSON responses, the whole response by
+	// default.
+	Root    string   `json:"root"`
+	Columns []column `json:"columns"`
+}
+
+// column maps a value of the rows to a field.
+type column struct {
+	// Selector is the JSONPath of the value in the row, or its key, or the
+	// header of its column in CSV responses.
+	Selector string `json:"selector"`
+	// Name is the name of the field, the selector by default.
+	Name string `json:"name"`
+	// Type is the type of the field, inferred from the values by default.
+	Type string `json:"type"`
+	// TimeFormat is the layout of time strings, as in the time package of
+	// Go, RFC 3339 by default.
+	TimeFormat string `json:"timeFormat"`
+}
+
+func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.InstanceFactoryFunc {
+	return func(settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+		u, err := url.Parse(settings.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data source URL: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid data source URL %q, it must use http or https", settings.URL)
+		}
+
+		opts, err := settings.HTTPClientOptions()
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := httpClientProvider.New(opts)
+		if err != nil {
+			return nil, err
+		}
+		client.CheckRedirect = checkRedirect(u)
+
+		var jd jsonData
+		if len(settings.JSONData) > 0 {
+			if err := json.Unmarshal(settings.JSONData, &jd); err != nil {
+				return nil, fmt.Errorf("error reading settings: %w", err)
+			}
+		}
+		maxResponseBytes := jd.MaxResponseBytes
+		if maxResponseBytes <= 0 {
+			maxResponseBytes = defaultMaxResponseBytes
+		}
+
+		return &datasourceInfo{HTTPClient: client, URL: u, MaxResponseBytes: maxResponseBytes}, nil
+	}
+}
+
+func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	dsInfo, err := s.getDSInfo(req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Responses larger than the bytes limit of the results would be cut off
+	// anyway, so they aren't read.
+	maxBytes := dsInfo.MaxResponseBytes
+	if limit := resultlimits.FromHeaders(req.Headers).Bytes; limit > 0 && (maxBytes <= 0 || limit < maxBytes) {
+		maxBytes = limit
+	}
+
+	result := backend.NewQueryDataResponse()
+	for _, query := range req.Queries {
+		result.Responses[query.RefID] = s.query(ctx, dsInfo, query, maxBytes)
+	}
+	return result, nil
+}
+
+func (s *Service) query(ctx context.Context, dsInfo *datasourceInfo, query backend.DataQuery, maxBytes int64) backend.DataResponse {
+	var model queryModel
+	if err := json.Unmarshal(query.JSON, &model); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to unmarshal query: %w", err)}
+	}
+
+	var toFrame func([]byte) (*data.Frame, error)
+	switch model.Format {
+	case formatJSON, "":
+		toFrame = func(body []byte) (*data.Frame, error) {
+			return jsonFrame(body, model.Root, model.Columns)
+		}
+	case formatCSV:
+		toFrame = func(body []byte) (*data.Frame, error) {
+			return csvFrame(body, model.Columns)
+		}
+	default:
+		return backend.DataResponse{Error: fmt.Errorf("unsupported format %q", model.Format)}
+	}
+
+	body, err := s.fetch(ctx, dsInfo, model, maxBytes)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+
+	frame, err := toFrame(body)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+
+	frame.RefID = query.RefID
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+// fetch requests the path of the query, which must stay on the data source
+// URL, and returns the body of the response, which must not be larger than
+// maxBytes when it is positive.
+func (s *Service) fetch(ctx context.Context, dsInfo *datasourceInfo, model queryModel, maxBytes int64) ([]byte, error) {
+	u, err := requestURL(dsInfo.URL, model.Path, model.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	method := strings.ToUpper(model.Method)
+	var body io.Reader
+	switch method {
+	case "":
+		method = http.MethodGet
+	case http.MethodGet:
+	case http.MethodPost:
+		body = bytes.NewBufferString(model.Body)
+	default:
+		return nil, fmt.Errorf("unsupported method %q", model.Method)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil && model.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	s.logger.Debug("HTTP data request", "url", req.URL.String(), "method", method)
+	res, err := dsInfo.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			s.logger.Warn("Failed to close response body", "err", err)
+		}
+	}()
+
+	if res.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("request failed with status %s", res.Status)
+	}
+
+	var reader io.Reader = res.Body
+	if maxBytes > 0 {
+		reader = io.LimitReader(res.Body, maxBytes+1)
+	}
+	resBody, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if maxBytes > 0 && int64(len(resBody)) > maxBytes {
+		return nil, fmt.Errorf("%w, the limit is %d bytes", errResponseTooLarge, maxBytes)
+	}
+	return resBody, nil
+}
+
+// requestURL returns the URL of the data source with the path and parameters
+// of the query.
+func requestURL(base *url.URL, reqPath string, params map[string]string) (*url.URL, error) {
+	ref, err := url.Parse(reqPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %q: %w", reqPath, err)
+	}
+	if ref.IsAbs() || ref.Host != "" {
+		return nil, fmt.Errorf("invalid path %q, it must be relative to the data source URL", reqPath)
+	}
+
+	u := *base
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if ref.Path != "" {
+		// Cleaning the path as absolute drops the .. segments going above the
+		// URL of the data source.
+		p := path.Clean("/" + ref.Path)
+		if strings.HasSuffix(ref.Path, "/") && p != "/" {
+			p += "/"
+		}
+		u.Path += p
+	}
+	u.RawPath = ""
+
+	q := u.Query()
+	for k, v := range ref.Query() {
+		q[k] = append(q[k], v...)
+	}
+	for k, v := range params {
+		q.Add(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return &u, nil
+}
+
+// checkRedirect only follows redirects that stay on the data source URL, so
+// that queries can't be sent to other hosts or paths through a redirect.
+func checkRedirect(base *url.URL) func(req *http.Request, via []*http.Request) error {
+	basePath := strings.TrimSuffix(base.Path, "/")
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		target := req.URL
+		p := path.Clean("/" + target.Path)
+		if target.Scheme != base.Scheme || !strings.EqualFold(target.Host, base.Host) ||
+			(p != basePath && !strings.HasPrefix(p, basePath+"/")) {
+			return fmt.Errorf("refusing redirect to %q outside of the data source URL", target.Redacted())
+		}
+		return nil
+	}
+}
+
+func (s *Service) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	dsInfo, err := s.getDSInfo(req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	// A large response still tells that the data source is reachable.
+	if _, err := s.fetch(ctx, dsInfo, queryModel{}, dsInfo.MaxResponseBytes); err != nil && !errors.Is(err, errResponseTooLarge) {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: err.Error(),
+		}, nil
+	}
+	return &backend.CheckHealthResult{
+		Status:  backend.HealthStatusOk,
+		Message: "Data source is working",
+	}, nil
+}
+
+func (s *Service) getDSInfo(pluginCtx backend.PluginContext) (*datasourceInfo, error) {
+	i, err := s.im.Get(pluginCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, ok := i.(*datasourceInfo)
+	if !ok {
+		return nil, fmt.Errorf("failed to cast datasource info")
+	}
+
+	return instance, nil
+}
@@ -0,0 +1,220 @@
+package httpdata
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/query/resultlimits"
+	"github.com/grafana/grafana/pkg/tsdb/tsdbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, handler http.HandlerFunc) *Service {
+	srv := tsdbtest.NewServer(t, handler)
+	u, err := url.Parse(srv.URL + "/base/")
+	require.NoError(t, err)
+	client := srv.Client()
+	client.CheckRedirect = checkRedirect(u)
+	return &Service{
+		logger: log.New("tsdb.httpdata"),
+		im:     &tsdbtest.FakeInstanceManager{Instance: &datasourceInfo{HTTPClient: client, URL: u}},
+	}
+}
+
+func TestQueryData(t *testing.T) {
+	t.Run("maps the JSON rows to a frame", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "/base/api/items", r.URL.Path)
+			assert.Equal(t, "10", r.URL.Query().Get("limit"))
+			assert.Equal(t, "web", r.URL.Query().Get("service"))
+			_, _ = w.Write([]byte(`{"data": {"items": [
+				{"time": "2021-10-01T00:00:00Z", "stats": {"value": 1.5}, "name": "a"},
+				{"time": "2021-10-01T00:01:00Z", "stats": {"value": 2}, "name": "b"}
+			]}}`))
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{
+				"path": "api/items?limit=10",
+				"params": {"service": "web"},
+				"root": "$.data.items",
+				"columns": [
+					{"selector": "time", "type": "time"},
+					{"selector": "$.stats.value", "name": "value"},
+					{"selector": "name"}
+				]
+			}`)}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+
+		frame := res.Responses["A"].Frames[0]
+		assert.Equal(t, "A", frame.RefID)
+		require.Len(t, frame.Fields, 3)
+		assert.Equal(t, "time", frame.Fields[0].Name)
+		assert.Equal(t, "value", frame.Fields[1].Name)
+		assert.Equal(t, "name", frame.Fields[2].Name)
+		require.Equal(t, 2, frame.Rows())
+		assert.Equal(t, 2.0, *frame.Fields[1].At(1).(*float64))
+		assert.Equal(t, "b", *frame.Fields[2].At(1).(*string))
+	})
+
+	t.Run("posts the body", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			body, err := ioutil.ReadAll(r.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, `{"q": 1}`, string(body))
+			_, _ = w.Write([]byte("name,value\na,1\n"))
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"method": "post", "body": "{\"q\": 1}", "format": "csv"}`)}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, 1, res.Responses["A"].Frames[0].Rows())
+	})
+
+	t.Run("limits the size of responses", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("name,value\na,1\nb,2\n"))
+		})
+		s.im.(*tsdbtest.FakeInstanceManager).Instance.(*datasourceInfo).MaxResponseBytes = 16
+
+		queries := []backend.DataQuery{{RefID: "A", JSON: []byte(`{"format": "csv"}`)}}
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{Queries: queries})
+		require.NoError(t, err)
+		require.Error(t, res.Responses["A"].Error)
+		assert.ErrorIs(t, res.Responses["A"].Error, errResponseTooLarge)
+		assert.EqualError(t, res.Responses["A"].Error, "response is too large, the limit is 16 bytes")
+
+		s.im.(*tsdbtest.FakeInstanceManager).Instance.(*datasourceInfo).MaxResponseBytes = 20
+		res, err = s.QueryData(context.Background(), &backend.QueryDataRequest{Queries: queries})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		assert.Equal(t, 2, res.Responses["A"].Frames[0].Rows())
+
+		// The bytes limit of the results is lower.
+		res, err = s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Headers: map[string]string{resultlimits.BytesHeader: "10"},
+			Queries: queries,
+		})
+		require.NoError(t, err)
+		assert.EqualError(t, res.Responses["A"].Error, "response is too large, the limit is 10 bytes")
+	})
+
+	t.Run("returns errors per query", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "not found", http.StatusNotFound)
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"path": "missing"}`)},
+				{RefID: "B", JSON: []byte(`{"path": "http://example.com/"}`)},
+				{RefID: "C", JSON: []byte(`{"format": "xml"}`)},
+				{RefID: "D", JSON: []byte(`{"method": "DELETE"}`)},
+			},
+		})
+		require.NoError(t, err)
+		require.Error(t, res.Responses["A"].Error)
+		assert.Contains(t, res.Responses["A"].Error.Error(), "404")
+		require.Error(t, res.Responses["B"].Error)
+		assert.Contains(t, res.Responses["B"].Error.Error(), "must be relative")
+		require.Error(t, res.Responses["C"].Error)
+		assert.Contains(t, res.Responses["C"].Error.Error(), "unsupported format")
+		require.Error(t, res.Responses["D"].Error)
+		assert.Contains(t, res.Responses["D"].Error.Error(), "unsupported method")
+	})
+}
+
+func TestRequestURL(t *testing.T) {
+	base, err := url.Parse("http://localhost:8080/api/?token=x")
+	require.NoError(t, err)
+
+	for path, expected := range map[string]string{
+		"":                  "http://localhost:8080/api?token=x",
+		"items/":            "http://localhost:8080/api/items/?token=x",
+		"/items?a=b&c=%20":  "http://localhost:8080/api/items?a=b&c=+&token=x",
+		"../admin":          "http://localhost:8080/api/admin?token=x",
+		"items/../../admin": "http://localhost:8080/api/admin?token=x",
+	} {
+		u, err := requestURL(base, path, nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, u.String(), path)
+	}
+
+	for _, path := range []string{"http://example.com", "//example.com/items"} {
+		_, err := requestURL(base, path, nil)
+		require.Error(t, err, path)
+	}
+}
+
+func TestRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("redirect to %s was followed", r.URL)
+	}))
+	t.Cleanup(other.Close)
+
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/base/moved":
+			http.Redirect(w, r, "/base/items", http.StatusFound)
+		case "/base/items":
+			_, _ = w.Write([]byte(`[{"name": "a"}]`))
+		case "/base/other-host":
+			http.Redirect(w, r, other.URL+"/base/items", http.StatusFound)
+		case "/base/other-path":
+			http.Redirect(w, r, "/admin/items", http.StatusFound)
+		case "/base/loop":
+			http.Redirect(w, r, "/base/loop", http.StatusFound)
+		}
+	})
+	query := func(path string) backend.DataResponse {
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"path": "` + path + `"}`)}},
+		})
+		require.NoError(t, err)
+		return res.Responses["A"]
+	}
+
+	res := query("moved")
+	require.NoError(t, res.Error)
+	require.Equal(t, 1, res.Frames[0].Rows())
+
+	for path, expected := range map[string]string{
+		"other-host": "outside of the data source URL",
+		"other-path": "outside of the data source URL",
+		"loop":       "stopped after 10 redirects",
+	} {
+		res := query(path)
+		require.Error(t, res.Error, path)
+		require.Contains(t, res.Error.Error(), expected, path)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/base", r.URL.Path)
+	})
+	res, err := s.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, backend.HealthStatusOk, res.Status)
+
+	s = newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	res, err = s.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, backend.HealthStatusError, res.Status)
+}
@@ -13,9 +13,14 @@ import (
 
 const maxPointsEnforceFactor float64 = 10
 
+// recordsPerChunk is the number of records decoded between two checks for the
+// cancellation of the query.
+const recordsPerChunk = 1000
+
 // executeQuery runs a flux query using the queryModel to interpolate the query and the runner to execute it.
-// maxSeries somehow limits the response.
-func executeQuery(ctx context.Context, query queryModel, runner queryRunner, maxSeries int) (dr backend.DataResponse) {
+// maxSeries limits the number of series of the response, and maxPoints the number of points per series,
+// which defaults to a factor of the max data points of the query when 0.
+func executeQuery(ctx context.Context, query queryModel, runner queryRunner, maxSeries int, maxPoints int) (dr backend.DataResponse) {
 	dr = backend.DataResponse{}
 
 	flux := interpolate(query)
@@ -28,9 +33,12 @@ func executeQuery(ctx context.Context, query queryModel, runner queryRunner, max
 		dr.Error = err
 	} else {
 		// we only enforce a larger number than maxDataPoints
-		maxPointsEnforced := int(float64(query.MaxDataPoints) * maxPointsEnforceFactor)
+		maxPointsEnforced := maxPoints
+		if maxPointsEnforced <= 0 {
+			maxPointsEnforced = int(float64(query.MaxDataPoints) * maxPointsEnforceFactor)
+		}
 
-		dr = readDataFrames(tables, maxPointsEnforced, maxSeries)
+		dr = readDataFrames(ctx, tables, maxPointsEnforced, maxSeries)
 
 		if dr.Error != nil {
 			// we check if a too-many-data-points error happened, and if it is so,
@@ -62,16 +70,36 @@ func executeQuery(ctx context.Context, query queryModel, runner queryRunner, max
 	return dr
 }
 
-func readDataFrames(result *api.QueryTableResult, maxPoints int, maxSeries int) (dr backend.DataResponse) {
+// readDataFrames decodes the annotated CSV result as it streams in, checking
+// for the cancellation of ctx between chunks of records. The response stops
+// being read as soon as the query is cancelled or a limit is reached.
+func readDataFrames(ctx context.Context, result *api.QueryTableResult, maxPoints int, maxSeries int) (dr backend.DataResponse) {
 	glog.Debug("Reading data frames from query result", "maxPoints", maxPoints, "maxSeries", maxSeries)
 	dr = backend.DataResponse{}
 
+	// result.Close() reads the rest of the response before closing it, close
+	// the body directly instead so that an aborted query is not downloaded.
+	defer func() {
+		if err := result.Closer.Close(); err != nil {
+			glog.Debug("Failed to close Flux query result", "err", err)
+		}
+	}()
+
 	builder := &frameBuilder{
 		maxPoints: maxPoints,
 		maxSeries: maxSeries,
 	}
 
+	records := 0
 	for result.Next() {
+		if records%recordsPerChunk == 0 {
+			if err := ctx.Err(); err != nil {
+				dr.Error = err
+				break
+			}
+		}
+		records++
+
 		// Observe when there is new grouping key producing new table
 		if result.TableChanged() {
 			if builder.frames != nil {
@@ -62,7 +62,7 @@ func executeMockedQuery(t *testing.T, name string, query queryModel) *backend.Da
 		testDataPath: name + ".csv",
 	}
 
-	dr := executeQuery(context.Background(), query, runner, 50)
+	dr := executeQuery(context.Background(), query, runner, 50, 0)
 	return &dr
 }
 
@@ -231,7 +231,7 @@ func TestRealQuery(t *testing.T) {
 		dr := executeQuery(context.Background(), queryModel{
 			MaxDataPoints: 100,
 			RawQuery:      "buckets()",
-		}, runner, 50)
+		}, runner, 50, 0)
 		err = experimental.CheckGoldenDataResponse(filepath.Join("testdata", "buckets-real.golden.txt"), &dr, true)
 		require.NoError(t, err)
 	})
@@ -267,6 +267,34 @@ func TestMaxDataPointsExceededWithAggregate(t *testing.T) {
 	assertDataResponseDimensions(t, dr, 2, 21)
 }
 
+func TestMaxPointsConfigured(t *testing.T) {
+	runner := &MockRunner{testDataPath: "max_data_points_exceeded.csv"}
+	dr := executeQuery(context.Background(), queryModel{MaxDataPoints: 100}, runner, 50, 5)
+
+	require.EqualError(t, dr.Error, "A query returned too many datapoints and the results have been truncated at 6 points to prevent memory issues. At the current graph size, Grafana can only draw 100. Try using the aggregateWindow() function in your query to reduce the number of points returned.")
+	assertDataResponseDimensions(t, &dr, 2, 6)
+}
+
+func TestMaxSeriesExceeded(t *testing.T) {
+	runner := &MockRunner{testDataPath: "multiple.csv"}
+	dr := executeQuery(context.Background(), queryModel{MaxDataPoints: 100}, runner, 1, 0)
+
+	require.EqualError(t, dr.Error, "results are truncated, max series reached (1)")
+}
+
+func TestReadDataFramesCancelled(t *testing.T) {
+	runner := &MockRunner{testDataPath: "simple.csv"}
+	result, err := runner.runQuery(context.Background(), "")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dr := readDataFrames(ctx, result, 100, 50)
+
+	require.ErrorIs(t, dr.Error, context.Canceled)
+	require.Empty(t, dr.Frames)
+}
+
 func TestMultivalue(t *testing.T) {
 	// we await a non-labeled _time column
 	// and two value-columns named _value and _value2
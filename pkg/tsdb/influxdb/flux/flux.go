@@ -36,7 +36,7 @@ func Query(ctx context.Context, dsInfo *models.DatasourceInfo, tsdbQuery backend
 
 		// If the default changes also update labels/placeholder in config page.
 		maxSeries := dsInfo.MaxSeries
-		res := executeQuery(ctx, *qm, r, maxSeries)
+		res := executeQuery(ctx, *qm, r, maxSeries, dsInfo.MaxPoints)
 
 		tRes.Responses[query.RefID] = res
 	}
@@ -0,0 +1,376 @@
+package fsql
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// frameBuilder accumulates the rows of record batches in a slice per column,
+// of the Go type of its field, and makes them into a frame at the end, so that
+// values are copied a column at a time instead of boxed one by one.
+type frameBuilder struct {
+	names   []string
+	columns []interface{}
+}
+
+// newFrameBuilder returns a builder of a frame with a field for each column of
+// the schema.
+func newFrameBuilder(schema *arrow.Schema) (*frameBuilder, error) {
+	b := &frameBuilder{}
+	for _, column := range schema.Fields() {
+		fieldType, err := fieldTypeOf(column)
+		if err != nil {
+			return nil, err
+		}
+		b.names = append(b.names, column.Name)
+		b.columns = append(b.columns, emptyValues(fieldType))
+	}
+	return b, nil
+}
+
+// frame returns the frame of the rows appended so far.
+func (b *frameBuilder) frame() *data.Frame {
+	fields := make([]*data.Field, len(b.columns))
+	for i, values := range b.columns {
+		fields[i] = data.NewField(b.names[i], nil, values)
+	}
+	return data.NewFrame("", fields...)
+}
+
+// append appends the rows of a record batch, which must have the schema of the
+// builder.
+func (b *frameBuilder) append(record array.Record) error {
+	if int(record.NumCols()) != len(b.columns) {
+		return fmt.Errorf("record with %d columns does not match the schema of %d columns", record.NumCols(), len(b.columns))
+	}
+	for i, column := range record.Columns() {
+		values, err := appendValues(b.columns[i], column)
+		if err != nil {
+			return fmt.Errorf("column %q: %w", b.names[i], err)
+		}
+		b.columns[i] = values
+	}
+	return nil
+}
+
+func fieldTypeOf(column arrow.Field) (data.FieldType, error) {
+	var fieldType data.FieldType
+	switch column.Type.ID() {
+	case arrow.BOOL:
+		fieldType = data.FieldTypeBool
+	case arrow.INT8:
+		fieldType = data.FieldTypeInt8
+	case arrow.INT16:
+		fieldType = data.FieldTypeInt16
+	case arrow.INT32:
+		fieldType = data.FieldTypeInt32
+	case arrow.INT64:
+		fieldType = data.FieldTypeInt64
+	case arrow.UINT8:
+		fieldType = data.FieldTypeUint8
+	case arrow.UINT16:
+		fieldType = data.FieldTypeUint16
+	case arrow.UINT32:
+		fieldType = data.FieldTypeUint32
+	case arrow.UINT64:
+		fieldType = data.FieldTypeUint64
+	case arrow.FLOAT32:
+		fieldType = data.FieldTypeFloat32
+	case arrow.FLOAT64:
+		fieldType = data.FieldTypeFloat64
+	case arrow.STRING:
+		fieldType = data.FieldTypeString
+	case arrow.TIMESTAMP:
+		fieldType = data.FieldTypeTime
+	case arrow.DICTIONARY:
+		return data.FieldTypeUnknown, fmt.Errorf("unsupported dictionary encoded column %q, cast it in the query, for example with CAST(%s AS VARCHAR)",
+			column.Name, column.Name)
+	default:
+		return data.FieldTypeUnknown, fmt.Errorf("unsupported type %s of column %q", column.Type, column.Name)
+	}
+	if column.Nullable {
+		fieldType = fieldType.NullableType()
+	}
+	return fieldType, nil
+}
+
+
+// emptyValues returns an empty slice of the Go type of the field type.
+func emptyValues(fieldType data.FieldType) interface{} {
+	switch fieldType {
+	case data.FieldTypeBool:
+		return []bool{}
+	case data.FieldTypeNullableBool:
+		return []*bool{}
+	case data.FieldTypeInt8:
+		return []int8{}
+	case data.FieldTypeNullableInt8:
+		return []*int8{}
+	case data.FieldTypeInt16:
+		return []int16{}
+	case data.FieldTypeNullableInt16:
+		return []*int16{}
+	case data.FieldTypeInt32:
+		return []int32{}
+	case data.FieldTypeNullableInt32:
+		return []*int32{}
+	case data.FieldTypeInt64:
+		return []int64{}
+	case data.FieldTypeNullableInt64:
+		return []*int64{}
+	case data.FieldTypeUint8:
+		return []uint8{}
+	case data.FieldTypeNullableUint8:
+		return []*uint8{}
+	case data.FieldTypeUint16:
+		return []uint16{}
+	case data.FieldTypeNullableUint16:
+		return []*uint16{}
+	case data.FieldTypeUint32:
+		return []uint32{}
+	case data.FieldTypeNullableUint32:
+		return []*uint32{}
+	case data.FieldTypeUint64:
+		return []uint64{}
+	case data.FieldTypeNullableUint64:
+		return []*uint64{}
+	case data.FieldTypeFloat32:
+		return []float32{}
+	case data.FieldTypeNullableFloat32:
+		return []*float32{}
+	case data.FieldTypeFloat64:
+		return []float64{}
+	case data.FieldTypeNullableFloat64:
+		return []*float64{}
+	case data.FieldTypeString:
+		return []string{}
+	case data.FieldTypeNullableString:
+		return []*string{}
+	case data.FieldTypeTime:
+		return []time.Time{}
+	case data.FieldTypeNullableTime:
+		return []*time.Time{}
+	}
+	return nil
+}
+
+// appendValues appends the values of the column to values, a slice made by
+// emptyValues. Values of nullable columns are copied to a slice per record
+// batch that the pointers point into, instead of being allocated one by one.
+func appendValues(values interface{}, column array.Interface) (interface{}, error) {
+	n := column.Len()
+	switch a := column.(type) {
+	case *array.Boolean:
+		vals := make([]bool, n)
+		for i := range vals {
+			vals[i] = a.Value(i)
+		}
+		switch v := values.(type) {
+		case []bool:
+			return append(v, vals...), nil
+		case []*bool:
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Int8:
+		switch v := values.(type) {
+		case []int8:
+			return append(v, a.Int8Values()...), nil
+		case []*int8:
+			vals := append([]int8(nil), a.Int8Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Int16:
+		switch v := values.(type) {
+		case []int16:
+			return append(v, a.Int16Values()...), nil
+		case []*int16:
+			vals := append([]int16(nil), a.Int16Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Int32:
+		switch v := values.(type) {
+		case []int32:
+			return append(v, a.Int32Values()...), nil
+		case []*int32:
+			vals := append([]int32(nil), a.Int32Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Int64:
+		switch v := values.(type) {
+		case []int64:
+			return append(v, a.Int64Values()...), nil
+		case []*int64:
+			vals := append([]int64(nil), a.Int64Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Uint8:
+		switch v := values.(type) {
+		case []uint8:
+			return append(v, a.Uint8Values()...), nil
+		case []*uint8:
+			vals := append([]uint8(nil), a.Uint8Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Uint16:
+		switch v := values.(type) {
+		case []uint16:
+			return append(v, a.Uint16Values()...), nil
+		case []*uint16:
+			vals := append([]uint16(nil), a.Uint16Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Uint32:
+		switch v := values.(type) {
+		case []uint32:
+			return append(v, a.Uint32Values()...), nil
+		case []*uint32:
+			vals := append([]uint32(nil), a.Uint32Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Uint64:
+		switch v := values.(type) {
+		case []uint64:
+			return append(v, a.Uint64Values()...), nil
+		case []*uint64:
+			vals := append([]uint64(nil), a.Uint64Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Float32:
+		switch v := values.(type) {
+		case []float32:
+			return append(v, a.Float32Values()...), nil
+		case []*float32:
+			vals := append([]float32(nil), a.Float32Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Float64:
+		switch v := values.(type) {
+		case []float64:
+			return append(v, a.Float64Values()...), nil
+		case []*float64:
+			vals := append([]float64(nil), a.Float64Values()...)
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.String:
+		vals := make([]string, n)
+		for i := range vals {
+			vals[i] = a.Value(i)
+		}
+		switch v := values.(type) {
+		case []string:
+			return append(v, vals...), nil
+		case []*string:
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	case *array.Timestamp:
+		unit := int64(a.DataType().(*arrow.TimestampType).Unit.Multiplier())
+		vals := make([]time.Time, n)
+		for i, ts := range a.TimestampValues() {
+			vals[i] = time.Unix(0, int64(ts)*unit).UTC()
+		}
+		switch v := values.(type) {
+		case []time.Time:
+			return append(v, vals...), nil
+		case []*time.Time:
+			for i := range vals {
+				if a.IsNull(i) {
+					v = append(v, nil)
+				} else {
+					v = append(v, &vals[i])
+				}
+			}
+			return v, nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported arrow array %s", column.DataType())
+	}
+	return nil, fmt.Errorf("arrow array %s does not match the schema", column.DataType())
+}
@@ -0,0 +1,85 @@
+package fsql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "time", Type: &arrow.TimestampType{Unit: arrow.Nanosecond}},
+	{Name: "host", Type: arrow.BinaryTypes.String},
+	{Name: "usage", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+	{Name: "count", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "ok", Type: arrow.FixedWidthTypes.Boolean},
+}, nil)
+
+func newTestRecord(start time.Time, hosts []string, usages []float64, valid []bool) array.Record {
+	b := array.NewRecordBuilder(memory.DefaultAllocator, testSchema)
+	defer b.Release()
+
+	for i := range hosts {
+		b.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(start.Add(time.Duration(i) * time.Minute).UnixNano()))
+		b.Field(3).(*array.Int64Builder).Append(int64(i))
+		b.Field(4).(*array.BooleanBuilder).Append(i%2 == 0)
+	}
+	b.Field(1).(*array.StringBuilder).AppendValues(hosts, nil)
+	b.Field(2).(*array.Float64Builder).AppendValues(usages, valid)
+	return b.NewRecord()
+}
+
+func TestAppendRecord(t *testing.T) {
+	start := time.Unix(1632305571, 0).UTC()
+
+	b, err := newFrameBuilder(testSchema)
+	require.NoError(t, err)
+	frame := b.frame()
+	for _, field := range frame.Fields {
+		assert.Equal(t, 0, field.Len())
+	}
+	assert.Equal(t, []data.FieldType{data.FieldTypeTime, data.FieldTypeString, data.FieldTypeNullableFloat64,
+		data.FieldTypeInt64, data.FieldTypeBool}, []data.FieldType{frame.Fields[0].Type(), frame.Fields[1].Type(),
+		frame.Fields[2].Type(), frame.Fields[3].Type(), frame.Fields[4].Type()})
+
+	record := newTestRecord(start, []string{"a", "b"}, []float64{1.5, 0}, []bool{true, false})
+	require.NoError(t, b.append(record))
+	require.NoError(t, b.append(record))
+	// The values are copied out of the record batch, which is released after
+	// it is read.
+	record.Release()
+	frame = b.frame()
+
+	require.Equal(t, 4, frame.Rows())
+	assert.Equal(t, start.Add(time.Minute), frame.At(0, 1))
+	assert.Equal(t, "b", frame.At(1, 1))
+	usage := 1.5
+	assert.Equal(t, &usage, frame.At(2, 2))
+	assert.Nil(t, frame.At(2, 3))
+	assert.Equal(t, int64(1), frame.At(3, 3))
+	assert.Equal(t, true, frame.At(4, 2))
+}
+
+func TestTimestampUnits(t *testing.T) {
+	schema := arrow.NewSchema([]arrow.Field{{Name: "time", Type: &arrow.TimestampType{Unit: arrow.Millisecond}}}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	b.Field(0).(*array.TimestampBuilder).Append(1632305571123)
+	record := b.NewRecord()
+	defer record.Release()
+
+	fb, err := newFrameBuilder(schema)
+	require.NoError(t, err)
+	require.NoError(t, fb.append(record))
+	assert.Equal(t, time.Unix(1632305571, 123000000).UTC(), fb.frame().At(0, 0))
+}
+
+func TestUnsupportedTypes(t *testing.T) {
+	_, err := newFrameBuilder(arrow.NewSchema([]arrow.Field{{Name: "data", Type: arrow.BinaryTypes.Binary}}, nil))
+	require.EqualError(t, err, `unsupported type binary of column "data"`)
+}
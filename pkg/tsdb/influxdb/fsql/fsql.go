@@ -0,0 +1,234 @@
+package fsql
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/flight"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/tsdb/influxdb/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+var (
+	glog = log.New("tsdb.influx_fsql")
+)
+
+// commandStatementQueryType is the type of the Flight SQL command executing a
+// SQL query.
+const commandStatementQueryType = "type.googleapis.com/arrow.flight.protocol.sql.CommandStatementQuery"
+
+// Query executes SQL queries with Flight SQL, and returns the Arrow record
+// batches of their results as frames.
+func Query(ctx context.Context, dsInfo *models.DatasourceInfo, tsdbQuery backend.QueryDataRequest) (
+	*backend.QueryDataResponse, error) {
+	tRes := backend.NewQueryDataResponse()
+	glog.Debug("Received a query", "query", tsdbQuery)
+	r, err := runnerFromDataSource(dsInfo)
+	if err != nil {
+		return &backend.QueryDataResponse{}, err
+	}
+
+	for _, query := range tsdbQuery.Queries {
+		qm, err := getQueryModel(query)
+		if err != nil {
+			tRes.Responses[query.RefID] = backend.DataResponse{Error: err}
+			continue
+		}
+		tRes.Responses[query.RefID] = executeQuery(ctx, *qm, r)
+	}
+	return tRes, nil
+}
+
+func executeQuery(ctx context.Context, query queryModel, r *runner) backend.DataResponse {
+	sql, err := interpolate(query)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+	glog.Debug("Executing Flight SQL query", "sql", sql)
+
+	frame, err := r.runQuery(ctx, sql)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+
+	if query.Format == timeSeriesFormat && frame.TimeSeriesSchema().Type == data.TimeSeriesTypeLong {
+		// The record batches of the endpoints are not ordered with each other.
+		frame, err = data.LongToWide(sortByTime(frame), nil)
+		if err != nil {
+			return backend.DataResponse{Error: err}
+		}
+	}
+	frame.Meta = &data.FrameMeta{ExecutedQueryString: sql}
+
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+func sortByTime(frame *data.Frame) *data.Frame {
+	timeField := frame.Fields[frame.TimeSeriesSchema().TimeIndex]
+	rows := make([]int, frame.Rows())
+	for i := range rows {
+		rows[i] = i
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, aOk := timeField.ConcreteAt(rows[i])
+		b, bOk := timeField.ConcreteAt(rows[j])
+		if !aOk || !bOk {
+			return aOk
+		}
+		return a.(time.Time).Before(b.(time.Time))
+	})
+
+	sorted := frame.EmptyCopy()
+	for _, row := range rows {
+		sorted.AppendRow(frame.RowCopy(row)...)
+	}
+	return sorted
+}
+
+// runner is a Flight client with the database its queries run against.
+type runner struct {
+	client   flight.Client
+	token    string
+	database string
+}
+
+// runQuery executes the SQL query and reads the record batches of all the
+// endpoints of its result into a single frame.
+func (r *runner) runQuery(ctx context.Context, sql string) (*data.Frame, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "database", r.database)
+	if r.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+r.token)
+	}
+
+	cmd, err := statementQueryCommand(sql)
+	if err != nil {
+		return nil, err
+	}
+	info, err := r.client.GetFlightInfo(ctx, &flight.FlightDescriptor{Type: flight.FlightDescriptor_CMD, Cmd: cmd})
+	if err != nil {
+		return nil, fmt.Errorf("flight SQL query failed: %w", err)
+	}
+
+	schema, err := flight.DeserializeSchema(info.Schema, memory.DefaultAllocator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schema of flight SQL result: %w", err)
+	}
+	b, err := newFrameBuilder(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, endpoint := range info.Endpoint {
+		if err := r.readEndpoint(ctx, endpoint, b); err != nil {
+			return nil, err
+		}
+	}
+	return b.frame(), nil
+}
+
+func (r *runner) readEndpoint(ctx context.Context, endpoint *flight.FlightEndpoint, b *frameBuilder) error {
+	stream, err := r.client.DoGet(ctx, endpoint.Ticket)
+	if err != nil {
+		return fmt.Errorf("failed to get flight SQL result: %w", err)
+	}
+	reader, err := flight.NewRecordReader(stream)
+	if err != nil {
+		return fmt.Errorf("failed to read flight SQL result: %w", err)
+	}
+	defer reader.Release()
+
+	for reader.Next() {
+		if err := b.append(reader.Record()); err != nil {
+			return err
+		}
+	}
+	if err := reader.Err(); err != nil {
+		return fmt.Errorf("failed to read flight SQL result: %w", err)
+	}
+	return nil
+}
+
+// statementQueryCommand encodes the Flight SQL command executing the query,
+// a CommandStatementQuery message wrapped in an Any message.
+func statementQueryCommand(sql string) ([]byte, error) {
+	var statement []byte
+	statement = protowire.AppendTag(statement, 1, protowire.BytesType)
+	statement = protowire.AppendString(statement, sql)
+	return proto.Marshal(&anypb.Any{TypeUrl: commandStatementQueryType, Value: statement})
+}
+
+// runnerFromDataSource creates a runner from the datasource model (the datasource instance's configuration).
+func runnerFromDataSource(dsInfo *models.DatasourceInfo) (*runner, error) {
+	database := dsInfo.Database
+	if database == "" {
+		database = dsInfo.DefaultBucket
+	}
+	if database == "" {
+		return nil, fmt.Errorf("missing database in datasource configuration")
+	}
+	if dsInfo.FlightSQLClient == nil {
+		return nil, fmt.Errorf("missing Flight SQL client of the datasource")
+	}
+
+	return &runner{
+		client:   dsInfo.FlightSQLClient,
+		token:    dsInfo.Token,
+		database: database,
+	}, nil
+}
+
+// NewClient creates the Flight SQL client of the data source URL. HTTPS URLs
+// use the TLS settings of the data source: its CA certificate, client
+// certificate and skip verification. Like the HTTP client of the data source,
+// the connection goes through the HTTPS_PROXY of the environment, which gRPC
+// honours, unless NO_PROXY excludes the host. The connection is established
+// on the first query.
+func NewClient(rawURL string, opts sdkhttpclient.Options) (flight.Client, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("missing URL from datasource configuration")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL in datasource configuration: %w", err)
+	}
+
+	port := u.Port()
+	dialOpts := []grpc.DialOption{}
+	switch u.Scheme {
+	case "https":
+		if port == "" {
+			port = "443"
+		}
+		tlsConfig, err := sdkhttpclient.GetTLSConfig(opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS settings of the datasource: %w", err)
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	case "http":
+		if port == "" {
+			port = "80"
+		}
+		dialOpts = append(dialOpts, grpc.WithInsecure())
+	default:
+		return nil, fmt.Errorf("unsupported URL scheme %q in datasource configuration", u.Scheme)
+	}
+
+	return flight.NewClientWithMiddleware(net.JoinHostPort(u.Hostname(), port), nil, nil, dialOpts...)
+}
@@ -0,0 +1,188 @@
+package fsql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/arrow/flight"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/influxdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// fakeFlightSQLServer answers every query with two endpoints returning the
+// test records.
+type fakeFlightSQLServer struct {
+	start    time.Time
+	queries  []string
+	metadata metadata.MD
+}
+
+func (s *fakeFlightSQLServer) getFlightInfo(ctx context.Context, desc *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	s.metadata, _ = metadata.FromIncomingContext(ctx)
+
+	cmd := &anypb.Any{}
+	if err := proto.Unmarshal(desc.Cmd, cmd); err != nil {
+		return nil, err
+	}
+	if cmd.TypeUrl != commandStatementQueryType {
+		return nil, fmt.Errorf("unexpected command %s", cmd.TypeUrl)
+	}
+	num, typ, n := protowire.ConsumeTag(cmd.Value)
+	if num != 1 || typ != protowire.BytesType {
+		return nil, fmt.Errorf("unexpected field %d", num)
+	}
+	query, _ := protowire.ConsumeString(cmd.Value[n:])
+	s.queries = append(s.queries, query)
+
+	return &flight.FlightInfo{
+		Schema: flight.SerializeSchema(testSchema, memory.DefaultAllocator),
+		Endpoint: []*flight.FlightEndpoint{
+			{Ticket: &flight.Ticket{Ticket: []byte("a")}},
+			{Ticket: &flight.Ticket{Ticket: []byte("b")}},
+		},
+	}, nil
+}
+
+func (s *fakeFlightSQLServer) doGet(ticket *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	w := flight.NewRecordWriter(stream, ipc.WithSchema(testSchema))
+	defer func() { _ = w.Close() }()
+
+	host := string(ticket.Ticket)
+	record := newTestRecord(s.start, []string{host, host}, []float64{1, 2}, nil)
+	defer record.Release()
+	return w.Write(record)
+}
+
+func startFakeFlightSQLServer(t *testing.T, s *fakeFlightSQLServer) string {
+	server := flight.NewServerWithMiddleware(nil, nil)
+	require.NoError(t, server.Init("localhost:0"))
+	server.RegisterFlightService(&flight.FlightServiceService{
+		GetFlightInfo: s.getFlightInfo,
+		DoGet:         s.doGet,
+	})
+	go func() {
+		_ = server.Serve()
+	}()
+	t.Cleanup(server.Shutdown)
+	return "http://" + server.Addr().String()
+}
+
+func TestQuery(t *testing.T) {
+	s := &fakeFlightSQLServer{start: time.Unix(1632305571, 0).UTC()}
+	dsInfo := &models.DatasourceInfo{
+		URL:      startFakeFlightSQLServer(t, s),
+		Database: "telegraf",
+		Token:    "secret",
+		Version:  "SQL",
+	}
+	client, err := NewClient(dsInfo.URL, sdkhttpclient.Options{})
+	require.NoError(t, err)
+	dsInfo.FlightSQLClient = client
+	t.Cleanup(dsInfo.Dispose)
+
+	timeRange := backend.TimeRange{From: s.start, To: s.start.Add(time.Hour)}
+	res, err := Query(context.Background(), dsInfo, backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{
+				RefID:     "A",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"query": "SELECT * FROM cpu WHERE $__timeFilter(time)"}`),
+			},
+			{
+				RefID:     "B",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"query": "SELECT time, host, usage FROM cpu", "format": "time_series"}`),
+			},
+			{
+				RefID:     "C",
+				TimeRange: timeRange,
+				JSON:      json.RawMessage(`{"query": ""}`),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"SELECT * FROM cpu WHERE time >= '2021-09-22T10:12:51Z' AND time <= '2021-09-22T11:12:51Z'",
+		"SELECT time, host, usage FROM cpu",
+	}, s.queries)
+	assert.Equal(t, []string{"telegraf"}, s.metadata.Get("database"))
+	assert.Equal(t, []string{"Bearer secret"}, s.metadata.Get("authorization"))
+
+	table := res.Responses["A"]
+	require.NoError(t, table.Error)
+	require.Len(t, table.Frames, 1)
+	assert.Equal(t, 4, table.Frames[0].Rows())
+	assert.Equal(t, "a", table.Frames[0].At(1, 0))
+	assert.Equal(t, "b", table.Frames[0].At(1, 3))
+	assert.Equal(t, s.queries[0], table.Frames[0].Meta.ExecutedQueryString)
+
+	series := res.Responses["B"]
+	require.NoError(t, series.Error)
+	require.Len(t, series.Frames, 1)
+	assert.Equal(t, data.TimeSeriesTypeWide, series.Frames[0].TimeSeriesSchema().Type)
+	assert.Equal(t, "a", series.Frames[0].Fields[1].Labels["host"])
+
+	require.EqualError(t, res.Responses["C"].Error, "query is empty")
+}
+
+func TestQueryReusesClient(t *testing.T) {
+	s := &fakeFlightSQLServer{start: time.Unix(1632305571, 0).UTC()}
+	dsInfo := &models.DatasourceInfo{URL: startFakeFlightSQLServer(t, s), Database: "telegraf", Version: "SQL"}
+	client, err := NewClient(dsInfo.URL, sdkhttpclient.Options{})
+	require.NoError(t, err)
+	dsInfo.FlightSQLClient = client
+	t.Cleanup(dsInfo.Dispose)
+
+	req := backend.QueryDataRequest{Queries: []backend.DataQuery{{RefID: "A", JSON: json.RawMessage(`{"query": "SELECT 1"}`)}}}
+	for i := 0; i < 2; i++ {
+		res, err := Query(context.Background(), dsInfo, req)
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+	}
+	require.Len(t, s.queries, 2)
+	require.Same(t, client, dsInfo.FlightSQLClient)
+}
+
+func TestRunnerFromDataSource(t *testing.T) {
+	_, err := runnerFromDataSource(&models.DatasourceInfo{URL: "http://localhost:8086"})
+	require.EqualError(t, err, "missing database in datasource configuration")
+
+	_, err = runnerFromDataSource(&models.DatasourceInfo{URL: "http://localhost:8086", Database: "telegraf"})
+	require.EqualError(t, err, "missing Flight SQL client of the datasource")
+
+	client, err := NewClient("https://localhost", sdkhttpclient.Options{})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, client.Close()) }()
+	r, err := runnerFromDataSource(&models.DatasourceInfo{FlightSQLClient: client, DefaultBucket: "telegraf"})
+	require.NoError(t, err)
+	assert.Equal(t, "telegraf", r.database)
+}
+
+func TestNewClient(t *testing.T) {
+	_, err := NewClient("", sdkhttpclient.Options{})
+	require.EqualError(t, err, "missing URL from datasource configuration")
+
+	_, err = NewClient("ftp://localhost", sdkhttpclient.Options{})
+	require.EqualError(t, err, `unsupported URL scheme "ftp" in datasource configuration`)
+
+	_, err = NewClient("https://localhost", sdkhttpclient.Options{TLS: &sdkhttpclient.TLSOptions{CACertificate: "not a certificate"}})
+	require.EqualError(t, err, "invalid TLS settings of the datasource: failed to parse TLS CA PEM certificate")
+
+	client, err := NewClient("https://localhost", sdkhttpclient.Options{TLS: &sdkhttpclient.TLSOptions{InsecureSkipVerify: true}})
+	require.NoError(t, err)
+	require.NoError(t, client.Close())
+}
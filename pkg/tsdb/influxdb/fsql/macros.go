@@ -0,0 +1,54 @@
+package fsql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+)
+
+// $__timeFilter(column) filters column on the time range of the query
+// $__dateBin(column) bins column by the interval of the query
+// $__timeFrom and $__timeTo are the bounds of the time range as timestamps
+// $__interval_ms is the exact interval in milliseconds
+// $__interval is the interval rounded to nice whole values
+
+var macroExp = regexp.MustCompile(`\$__(timeFilter|dateBin)\(\s*([^)]*?)\s*\)`)
+
+func interpolate(query queryModel) (string, error) {
+	sql := query.RawQuery
+	from := timestamp(query.TimeRange.From)
+	to := timestamp(query.TimeRange.To)
+
+	var err error
+	sql = macroExp.ReplaceAllStringFunc(sql, func(match string) string {
+		groups := macroExp.FindStringSubmatch(match)
+		column := groups[2]
+		if column == "" {
+			err = fmt.Errorf("missing column argument of macro $__%s", groups[1])
+			return match
+		}
+		switch groups[1] {
+		case "timeFilter":
+			return fmt.Sprintf("%s >= %s AND %s <= %s", column, from, column, to)
+		default:
+			return fmt.Sprintf("date_bin(interval '%d millisecond', %s)", query.Interval.Milliseconds(), column)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sql = strings.ReplaceAll(sql, "$__timeFrom", from)
+	sql = strings.ReplaceAll(sql, "$__timeTo", to)
+	sql = strings.ReplaceAll(sql, "$__interval_ms", strconv.FormatInt(query.Interval.Milliseconds(), 10))
+	sql = strings.ReplaceAll(sql, "$__interval", intervalv2.FormatDuration(query.Interval))
+	return sql, nil
+}
+
+func timestamp(t time.Time) string {
+	return "'" + t.UTC().Format(time.RFC3339Nano) + "'"
+}
@@ -0,0 +1,61 @@
+package fsql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	query := queryModel{
+		TimeRange: backend.TimeRange{
+			From: time.Unix(1632305571, 310985041),
+			To:   time.Unix(1632309171, 310985042),
+		},
+		Interval: 61258 * time.Millisecond,
+	}
+
+	tests := []struct {
+		name   string
+		before string
+		after  string
+	}{
+		{
+			name:   "time range",
+			before: `SELECT * FROM cpu WHERE time >= $__timeFrom AND time <= $__timeTo`,
+			after:  `SELECT * FROM cpu WHERE time >= '2021-09-22T10:12:51.310985041Z' AND time <= '2021-09-22T11:12:51.310985042Z'`,
+		},
+		{
+			name:   "time filter",
+			before: `SELECT * FROM cpu WHERE $__timeFilter( time ) AND host = 'a'`,
+			after:  `SELECT * FROM cpu WHERE time >= '2021-09-22T10:12:51.310985041Z' AND time <= '2021-09-22T11:12:51.310985042Z' AND host = 'a'`,
+		},
+		{
+			name:   "date bin",
+			before: `SELECT $__dateBin(time) AS time, avg(usage) FROM cpu GROUP BY 1`,
+			after:  `SELECT date_bin(interval '61258 millisecond', time) AS time, avg(usage) FROM cpu GROUP BY 1`,
+		},
+		{
+			name:   "interval",
+			before: `$__interval, $__interval_ms`,
+			after:  `1m, 61258`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query.RawQuery = tt.before
+			sql, err := interpolate(query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.after, sql)
+		})
+	}
+
+	t.Run("macro without column", func(t *testing.T) {
+		query.RawQuery = `SELECT * FROM cpu WHERE $__timeFilter()`
+		_, err := interpolate(query)
+		require.EqualError(t, err, "missing column argument of macro $__timeFilter")
+	})
+}
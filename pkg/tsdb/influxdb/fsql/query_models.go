@@ -0,0 +1,40 @@
+package fsql
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+const timeSeriesFormat = "time_series"
+
+// queryModel represents a query.
+type queryModel struct {
+	RawQuery string `json:"query"`
+	// Format is either table (the default) or time_series, which converts
+	// the rows into a series per tag set.
+	Format string `json:"format"`
+
+	// Not from JSON
+	TimeRange backend.TimeRange `json:"-"`
+	Interval  time.Duration     `json:"-"`
+}
+
+func getQueryModel(query backend.DataQuery) (*queryModel, error) {
+	model := &queryModel{}
+	if err := json.Unmarshal(query.JSON, model); err != nil {
+		return nil, fmt.Errorf("error reading query: %w", err)
+	}
+	if model.RawQuery == "" {
+		return nil, fmt.Errorf("query is empty")
+	}
+
+	model.TimeRange = query.TimeRange
+	model.Interval = query.Interval
+	if model.Interval.Milliseconds() == 0 {
+		model.Interval = time.Millisecond // 1ms
+	}
+	return model, nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/tsdb/influxdb/flux"
+	"github.com/grafana/grafana/pkg/tsdb/influxdb/fsql"
 	"github.com/grafana/grafana/pkg/tsdb/influxdb/models"
 )
 
@@ -74,8 +75,15 @@ func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.Inst
 			DefaultBucket: jsonData.DefaultBucket,
 			Organization:  jsonData.Organization,
 			MaxSeries:     maxSeries,
+			MaxPoints:     jsonData.MaxPoints,
 			Token:         settings.DecryptedSecureJSONData["token"],
 		}
+		if jsonData.Version == "SQL" {
+			model.FlightSQLClient, err = fsql.NewClient(settings.URL, opts)
+			if err != nil {
+				return nil, err
+			}
+		}
 		return model, nil
 	}
 }
@@ -91,6 +99,9 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 	if version == "Flux" {
 		return flux.Query(ctx, dsInfo, *req)
 	}
+	if version == "SQL" {
+		return fsql.Query(ctx, dsInfo, *req)
+	}
 
 	s.glog.Debug("Making a non-Flux type query")
 
@@ -2,12 +2,17 @@ package models
 
 import (
 	"net/http"
+
+	"github.com/apache/arrow/go/arrow/flight"
 )
 
 type DatasourceInfo struct {
 	HTTPClient *http.Client
 	Token      string
 	URL        string
+	// FlightSQLClient is the Flight SQL client of SQL data sources, reused by
+	// all their queries and closed when the instance is disposed.
+	FlightSQLClient flight.Client
 
 	Database      string `json:"database"`
 	Version       string `json:"version"`
@@ -16,4 +21,15 @@ type DatasourceInfo struct {
 	DefaultBucket string `json:"defaultBucket"`
 	Organization  string `json:"organization"`
 	MaxSeries     int    `json:"maxSeries"`
+	// MaxPoints is the max number of points per series of Flux queries,
+	// defaults to 10 times the max data points of the query when 0.
+	MaxPoints int `json:"maxPoints"`
+}
+
+// Dispose closes the Flight SQL client when the settings of the data source
+// change.
+func (d *DatasourceInfo) Dispose() {
+	if d.FlightSQLClient != nil {
+		_ = d.FlightSQLClient.Close()
+	}
 }
@@ -0,0 +1,173 @@
+package intervalv2
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarUnit is the unit of a calendar interval.
+type CalendarUnit string
+
+const (
+	CalendarDay     CalendarUnit = "d"
+	CalendarWeek    CalendarUnit = "w"
+	CalendarMonth   CalendarUnit = "M"
+	CalendarQuarter CalendarUnit = "Q"
+	CalendarYear    CalendarUnit = "y"
+)
+
+// CalendarOptions are the settings of the calendar the intervals are aligned
+// to.
+type CalendarOptions struct {
+	// Location is the time zone whose midnight days start at, UTC when nil.
+	Location *time.Location
+	// WeekStart is the first day of weeks.
+	WeekStart time.Weekday
+	// FiscalYearStartMonth is the first month of years and quarters, January
+	// when zero.
+	FiscalYearStartMonth time.Month
+}
+
+// CalendarInterval is an interval of the calendar, e.g. a day starting at
+// midnight in a time zone, whose duration varies with DST changes and month
+// lengths.
+type CalendarInterval struct {
+	Unit CalendarUnit
+	CalendarOptions
+}
+
+// ParseCalendarInterval returns the calendar interval of interval, one of 1d,
+// 1w, 1M, 1Q and 1y. ok is false for any other interval, which is a fixed
+// duration.
+func ParseCalendarInterval(interval string, opts CalendarOptions) (CalendarInterval, bool) {
+	interval = strings.TrimPrefix(strings.TrimSpace(interval), "1")
+	switch unit := CalendarUnit(interval); unit {
+	case CalendarDay, CalendarWeek, CalendarMonth, CalendarQuarter, CalendarYear:
+		return CalendarInterval{Unit: unit, CalendarOptions: opts}, true
+	}
+	return CalendarInterval{}, false
+}
+
+// ParseWeekStart parses the week start of the user preferences, e.g. monday.
+// Weeks start on Monday when it is empty.
+func ParseWeekStart(weekStart string) (time.Weekday, error) {
+	switch strings.ToLower(weekStart) {
+	case "", "monday":
+		return time.Monday, nil
+	case "sunday":
+		return time.Sunday, nil
+	case "saturday":
+		return time.Saturday, nil
+	}
+	return 0, fmt.Errorf("invalid week start %q", weekStart)
+}
+
+// WeekShift returns how long before the weeks counted from the Unix epoch, a
+// Thursday, the weeks starting on WeekStart start.
+func (o CalendarOptions) WeekShift() time.Duration {
+	return time.Duration((int(time.Thursday)-int(o.WeekStart)+7)%7) * day
+}
+
+func (c CalendarInterval) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// Start returns the start of the interval t is in.
+func (c CalendarInterval) Start(t time.Time) time.Time {
+	t = t.In(c.location())
+	y, m, d := t.Date()
+
+	yearStart := c.FiscalYearStartMonth
+	if yearStart == 0 {
+		yearStart = time.January
+	}
+	fiscalMonths := time.Month((int(m) - int(yearStart) + 12) % 12)
+
+	switch c.Unit {
+	case CalendarWeek:
+		days := (int(t.Weekday()) - int(c.WeekStart) + 7) % 7
+		return time.Date(y, m, d-days, 0, 0, 0, 0, c.location())
+	case CalendarMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, c.location())
+	case CalendarQuarter:
+		return time.Date(y, m-fiscalMonths%3, 1, 0, 0, 0, 0, c.location())
+	case CalendarYear:
+		return time.Date(y, m-fiscalMonths, 1, 0, 0, 0, 0, c.location())
+	}
+	return time.Date(y, m, d, 0, 0, 0, 0, c.location())
+}
+
+// Next returns the start of the interval following the one t is in.
+func (c CalendarInterval) Next(t time.Time) time.Time {
+	start := c.Start(t)
+	switch c.Unit {
+	case CalendarWeek:
+		return start.AddDate(0, 0, 7)
+	case CalendarMonth:
+		return start.AddDate(0, 1, 0)
+	case CalendarQuarter:
+		return start.AddDate(0, 3, 0)
+	case CalendarYear:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// CalendarSegment is a part of a time range in which the intervals start at
+// floor((t+Shift)/Size)*Size-Shift, t being seconds since the Unix epoch, or
+// which is the single interval starting at Start when Size is zero.
+type CalendarSegment struct {
+	Start time.Time
+	// End is the end of the segment, excluded.
+	End   time.Time
+	Size  time.Duration
+	Shift time.Duration
+}
+
+// Segments splits the intervals overlapping the range from from to to into
+// segments, which lets databases group by the intervals with arithmetic on
+// the Unix time. Days and weeks have a fixed size between changes of the UTC
+// offset of the time zone. Months, quarters, years and the days and weeks in
+// which the offset changes are segments of a single interval.
+func (c CalendarInterval) Segments(from, to time.Time) []CalendarSegment {
+	var segments []CalendarSegment
+	for start := c.Start(from); start.Before(to) || len(segments) == 0; {
+		next := c.Next(start)
+		size, shift := c.epochAlignment(start, next)
+		if n := len(segments); n > 0 && size > 0 && segments[n-1].Size == size && segments[n-1].Shift == shift {
+			segments[n-1].End = next
+		} else {
+			segments = append(segments, CalendarSegment{Start: start, End: next, Size: size, Shift: shift})
+		}
+		start = next
+	}
+	return segments
+}
+
+// epochAlignment returns the size and shift of the interval from start to
+// next, or a zero size when it has no fixed size.
+func (c CalendarInterval) epochAlignment(start, next time.Time) (size, shift time.Duration) {
+	switch c.Unit {
+	case CalendarDay:
+		size = day
+	case CalendarWeek:
+		size = 7 * day
+	default:
+		return 0, 0
+	}
+	// The UTC offset changes in the interval.
+	if next.Sub(start) != size {
+		return 0, 0
+	}
+
+	_, offset := start.Zone()
+	shift = time.Duration(offset) * time.Second
+	if c.Unit == CalendarWeek {
+		shift += c.WeekShift()
+	}
+	return size, shift
+}
@@ -0,0 +1,157 @@
+package intervalv2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCalendarInterval(t *testing.T) {
+	for _, interval := range []string{"1d", "d", "1w", "1M", "1Q", "1y"} {
+		_, ok := ParseCalendarInterval(interval, CalendarOptions{})
+		assert.True(t, ok, interval)
+	}
+	for _, interval := range []string{"", "24h", "2d", "7d", "1m", "10s"} {
+		_, ok := ParseCalendarInterval(interval, CalendarOptions{})
+		assert.False(t, ok, interval)
+	}
+}
+
+func TestParseWeekStart(t *testing.T) {
+	for weekStart, expected := range map[string]time.Weekday{
+		"":         time.Monday,
+		"monday":   time.Monday,
+		"Sunday":   time.Sunday,
+		"saturday": time.Saturday,
+	} {
+		day, err := ParseWeekStart(weekStart)
+		require.NoError(t, err)
+		assert.Equal(t, expected, day)
+	}
+
+	_, err := ParseWeekStart("friday")
+	require.Error(t, err)
+}
+
+func TestCalendarInterval(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// Tuesday 2022-03-15 10:30 in Berlin.
+	at := time.Date(2022, time.March, 15, 10, 30, 0, 0, berlin)
+
+	testCases := []struct {
+		name     string
+		interval string
+		opts     CalendarOptions
+		start    time.Time
+		next     time.Time
+	}{
+		{"day in UTC", "1d", CalendarOptions{},
+			time.Date(2022, time.March, 15, 0, 0, 0, 0, time.UTC), time.Date(2022, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"day in a time zone", "1d", CalendarOptions{Location: berlin},
+			time.Date(2022, time.March, 15, 0, 0, 0, 0, berlin), time.Date(2022, time.March, 16, 0, 0, 0, 0, berlin)},
+		{"week starting on Monday", "1w", CalendarOptions{Location: berlin, WeekStart: time.Monday},
+			time.Date(2022, time.March, 14, 0, 0, 0, 0, berlin), time.Date(2022, time.March, 21, 0, 0, 0, 0, berlin)},
+		{"week starting on Sunday", "1w", CalendarOptions{Location: berlin, WeekStart: time.Sunday},
+			time.Date(2022, time.March, 13, 0, 0, 0, 0, berlin), time.Date(2022, time.March, 20, 0, 0, 0, 0, berlin)},
+		{"month", "1M", CalendarOptions{Location: berlin},
+			time.Date(2022, time.March, 1, 0, 0, 0, 0, berlin), time.Date(2022, time.April, 1, 0, 0, 0, 0, berlin)},
+		{"quarter", "1Q", CalendarOptions{},
+			time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"fiscal quarter", "1Q", CalendarOptions{FiscalYearStartMonth: time.February},
+			time.Date(2022, time.February, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, time.May, 1, 0, 0, 0, 0, time.UTC)},
+		{"year", "1y", CalendarOptions{},
+			time.Date(2022, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"fiscal year", "1y", CalendarOptions{FiscalYearStartMonth: time.October},
+			time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, time.October, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			interval, ok := ParseCalendarInterval(tc.interval, tc.opts)
+			require.True(t, ok)
+			assert.True(t, tc.start.Equal(interval.Start(at)), "start %s", interval.Start(at))
+			assert.True(t, tc.next.Equal(interval.Next(at)), "next %s", interval.Next(at))
+		})
+	}
+
+	t.Run("intervals across DST start at local midnight", func(t *testing.T) {
+		interval := CalendarInterval{Unit: CalendarDay, CalendarOptions: CalendarOptions{Location: berlin}}
+
+		spring := time.Date(2022, time.March, 27, 12, 0, 0, 0, berlin)
+		assert.Equal(t, 23*time.Hour, interval.Next(spring).Sub(interval.Start(spring)))
+		autumn := time.Date(2022, time.October, 30, 12, 0, 0, 0, berlin)
+		assert.Equal(t, 25*time.Hour, interval.Next(autumn).Sub(interval.Start(autumn)))
+
+		interval.Unit, interval.WeekStart = CalendarWeek, time.Monday
+		assert.Equal(t, time.Date(2022, time.March, 28, 0, 0, 0, 0, berlin), interval.Next(spring))
+	})
+}
+
+func TestCalendarInterval_Segments(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	t.Run("aligns days to midnight of the time zone", func(t *testing.T) {
+		interval := CalendarInterval{Unit: CalendarDay, CalendarOptions: CalendarOptions{Location: berlin}}
+		from := time.Date(2022, time.January, 10, 12, 0, 0, 0, berlin)
+
+		segments := interval.Segments(from, from.AddDate(0, 0, 7))
+		require.Len(t, segments, 1)
+		assert.Equal(t, 24*time.Hour, segments[0].Size)
+		assert.Equal(t, time.Hour, segments[0].Shift)
+		assert.True(t, time.Date(2022, time.January, 18, 0, 0, 0, 0, berlin).Equal(segments[0].End))
+		assertSegmentAligned(t, interval, segments[0], from)
+	})
+
+	t.Run("aligns weeks to the week start", func(t *testing.T) {
+		for _, weekStart := range []time.Weekday{time.Sunday, time.Monday, time.Thursday, time.Saturday} {
+			interval := CalendarInterval{Unit: CalendarWeek, CalendarOptions: CalendarOptions{WeekStart: weekStart}}
+			from := time.Date(2022, time.January, 12, 12, 0, 0, 0, time.UTC)
+
+			segments := interval.Segments(from, from.AddDate(0, 0, 7))
+			require.Len(t, segments, 1)
+			assert.Equal(t, 7*24*time.Hour, segments[0].Size)
+			assertSegmentAligned(t, interval, segments[0], from)
+		}
+	})
+
+	t.Run("splits days at DST changes", func(t *testing.T) {
+		interval := CalendarInterval{Unit: CalendarDay, CalendarOptions: CalendarOptions{Location: berlin}}
+		from := time.Date(2022, time.March, 20, 12, 0, 0, 0, berlin)
+
+		segments := interval.Segments(from, from.AddDate(0, 0, 14))
+		require.Len(t, segments, 3)
+		assert.Equal(t, time.Hour, segments[0].Shift)
+		assert.True(t, time.Date(2022, time.March, 27, 0, 0, 0, 0, berlin).Equal(segments[0].End))
+		assert.Zero(t, segments[1].Size)
+		assert.True(t, time.Date(2022, time.March, 27, 0, 0, 0, 0, berlin).Equal(segments[1].Start))
+		assert.Equal(t, 2*time.Hour, segments[2].Shift)
+		assertSegmentAligned(t, interval, segments[0], from)
+		assertSegmentAligned(t, interval, segments[2], from.AddDate(0, 0, 10))
+	})
+
+	t.Run("months are single intervals", func(t *testing.T) {
+		interval := CalendarInterval{Unit: CalendarMonth}
+		from := time.Date(2022, time.January, 31, 12, 0, 0, 0, time.UTC)
+
+		segments := interval.Segments(from, time.Date(2022, time.April, 1, 0, 0, 0, 0, time.UTC))
+		require.Len(t, segments, 3)
+		for i, segment := range segments {
+			assert.Zero(t, segment.Size)
+			assert.Equal(t, time.Date(2022, time.January+time.Month(i), 1, 0, 0, 0, 0, time.UTC), segment.Start)
+		}
+
+		assert.Len(t, interval.Segments(from, from), 1)
+	})
+}
+
+func assertSegmentAligned(t *testing.T, interval CalendarInterval, segment CalendarSegment, at time.Time) {
+	t.Helper()
+	size, shift := int64(segment.Size.Seconds()), int64(segment.Shift.Seconds())
+	start := (at.Unix()+shift)/size*size - shift
+	assert.Equal(t, interval.Start(at).Unix(), start, at)
+}
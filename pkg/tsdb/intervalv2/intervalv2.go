@@ -63,6 +63,11 @@ func (ic *intervalCalculator) Calculate(timerange backend.TimeRange, minInterval
 
 	calculatedInterval := time.Duration((to - from) / resolution)
 
+	// The calculator's own minimum applies on top of the one passed by the caller.
+	if minInterval < ic.minInterval {
+		minInterval = ic.minInterval
+	}
+
 	if calculatedInterval < minInterval {
 		return Interval{Text: FormatDuration(minInterval), Value: minInterval}
 	}
@@ -38,6 +38,16 @@ func TestIntervalCalculator_Calculate(t *testing.T) {
 	}
 }
 
+func TestIntervalCalculator_CalculateWithCalculatorMinInterval(t *testing.T) {
+	calculator := NewCalculator(CalculatorOptions{MinInterval: 30 * time.Second})
+
+	timeNow := time.Now()
+	timeRange := backend.TimeRange{From: timeNow, To: timeNow.Add(60 * time.Minute)}
+
+	assert.Equal(t, "30s", calculator.Calculate(timeRange, time.Millisecond*1, 0).Text)
+	assert.Equal(t, "1m", calculator.Calculate(timeRange, time.Minute, 0).Text)
+}
+
 func TestIntervalCalculator_CalculateSafeInterval(t *testing.T) {
 	calculator := NewCalculator(CalculatorOptions{})
 
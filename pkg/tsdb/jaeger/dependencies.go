@@ -0,0 +1,90 @@
+package jaeger
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/tracingds"
+)
+
+// dependency is a link of the dependency graph, counting the calls of the
+// parent service to the child service.
+type dependency struct {
+	Parent    string `json:"parent"`
+	Child     string `json:"child"`
+	CallCount int64  `json:"callCount"`
+}
+
+func (s *Service) dependencyGraph(ctx context.Context, dsInfo *tracingds.DatasourceInfo, query backend.DataQuery) backend.DataResponse {
+	params := url.Values{}
+	params.Set("endTs", strconv.FormatInt(query.TimeRange.To.UnixMilli(), 10))
+	params.Set("lookback", strconv.FormatInt(query.TimeRange.Duration().Milliseconds(), 10))
+
+	var dependencies []dependency
+	if err := s.get(ctx, dsInfo, "/api/dependencies", params, &dependencies); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to get dependencies: %w", err)}
+	}
+	return backend.DataResponse{Frames: dependencyGraphFrames(dependencies)}
+}
+
+// dependencyGraphFrames returns the nodes and edges frames of the node graph
+// panel. The stat of a node is the number of calls it received.
+func dependencyGraphFrames(dependencies []dependency) data.Frames {
+	type edge struct {
+		parent, child string
+	}
+	calls := map[edge]int64{}
+	received := map[string]int64{}
+	for _, d := range dependencies {
+		calls[edge{d.Parent, d.Child}] += d.CallCount
+		if _, ok := received[d.Parent]; !ok {
+			received[d.Parent] = 0
+		}
+		received[d.Child] += d.CallCount
+	}
+
+	edges := make([]edge, 0, len(calls))
+	for e := range calls {
+		edges = append(edges, e)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].parent != edges[j].parent {
+			return edges[i].parent < edges[j].parent
+		}
+		return edges[i].child < edges[j].child
+	})
+
+	edgesFrame := data.NewFrame("edges",
+		data.NewField("id", nil, []string{}),
+		data.NewField("source", nil, []string{}),
+		data.NewField("target", nil, []string{}),
+		data.NewField("mainStat", nil, []int64{}).SetConfig(&data.FieldConfig{DisplayName: "Calls"}),
+	)
+	edgesFrame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeNodeGraph}
+	for _, e := range edges {
+		edgesFrame.AppendRow(e.parent+"_"+e.child, e.parent, e.child, calls[e])
+	}
+
+	names := make([]string, 0, len(received))
+	for name := range received {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodesFrame := data.NewFrame("nodes",
+		data.NewField("id", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+		data.NewField("mainStat", nil, []int64{}).SetConfig(&data.FieldConfig{DisplayName: "Calls received"}),
+	)
+	nodesFrame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeNodeGraph}
+	for _, name := range names {
+		nodesFrame.AppendRow(name, name, received[name])
+	}
+
+	return data.Frames{nodesFrame, edgesFrame}
+}
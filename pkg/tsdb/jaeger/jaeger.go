@@ -0,0 +1,146 @@
+package jaeger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana/pkg/infra/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/tsdb/tracingds"
+)
+
+// The query types of the data source, queries without query type fetch the
+// trace of their ID.
+const (
+	searchQueryType          = "search"
+	dependencyGraphQueryType = "dependencyGraph"
+)
+
+type Service struct {
+	im     instancemgmt.InstanceManager
+	logger log.Logger
+}
+
+func ProvideService(httpClientProvider httpclient.Provider) *Service {
+	return &Service{
+		logger: log.New("tsdb.jaeger"),
+		im:     datasource.NewInstanceManager(tracingds.NewInstanceSettings(httpClientProvider)),
+	}
+}
+
+type queryModel struct {
+	// TraceID is the ID of the trace of queries without query type.
+	TraceID     string `json:"query"`
+	Service     string `json:"service"`
+	Operation   string `json:"operation"`
+	Tags        string `json:"tags"`
+	MinDuration string `json:"minDuration"`
+	MaxDuration string `json:"maxDuration"`
+	Limit       int    `json:"limit"`
+}
+
+func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	dsInfo, err := tracingds.GetDSInfo(s.im, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	result := backend.NewQueryDataResponse()
+	for _, query := range req.Queries {
+		result.Responses[query.RefID] = s.query(ctx, dsInfo, query)
+	}
+	return result, nil
+}
+
+func (s *Service) query(ctx context.Context, dsInfo *tracingds.DatasourceInfo, query backend.DataQuery) backend.DataResponse {
+	model := &queryModel{}
+	if err := json.Unmarshal(query.JSON, model); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to unmarshal query: %w", err)}
+	}
+
+	var res backend.DataResponse
+	switch query.QueryType {
+	case searchQueryType:
+		res = s.search(ctx, dsInfo, query, model)
+	case dependencyGraphQueryType:
+		res = s.dependencyGraph(ctx, dsInfo, query)
+	case "":
+		res = s.trace(ctx, dsInfo, model.TraceID)
+	default:
+		return backend.DataResponse{Error: fmt.Errorf("unsupported query type %q", query.QueryType)}
+	}
+
+	for _, frame := range res.Frames {
+		frame.RefID = query.RefID
+	}
+	return res
+}
+
+func (s *Service) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	dsInfo, err := tracingds.GetDSInfo(s.im, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []string
+	if err := s.get(ctx, dsInfo, "/api/services", nil, &services); err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: "Jaeger: " + err.Error(),
+		}, nil
+	}
+
+	if len(services) == 0 {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: "Data source connected, but no services received. Verify that Jaeger is configured properly.",
+		}, nil
+	}
+	return &backend.CheckHealthResult{
+		Status:  backend.HealthStatusOk,
+		Message: "Data source connected and services found.",
+	}, nil
+}
+
+// response is the envelope of the responses of the HTTP API of Jaeger.
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"errors"`
+}
+
+// get requests the path of the Jaeger API and decodes the data of the response
+// into v.
+func (s *Service) get(ctx context.Context, dsInfo *tracingds.DatasourceInfo, path string, params url.Values, v interface{}) error {
+	res, err := tracingds.Get(ctx, s.logger, "Jaeger", dsInfo, path, params)
+	if err != nil {
+		return err
+	}
+
+	var decoded response
+	if err := json.Unmarshal(res.Body, &decoded); err != nil {
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("request failed with status %s: %s", res.Status, string(res.Body))
+		}
+		return fmt.Errorf("failed to unmarshal Jaeger response: %w", err)
+	}
+	if len(decoded.Errors) > 0 {
+		return fmt.Errorf("request failed with status %s: %s", res.Status, decoded.Errors[0].Msg)
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status %s", res.Status)
+	}
+
+	if len(decoded.Data) == 0 || string(decoded.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(decoded.Data, v)
+}
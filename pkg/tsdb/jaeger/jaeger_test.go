@@ -0,0 +1,177 @@
+package jaeger
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/tsdb/tracingds"
+	"github.com/grafana/grafana/pkg/tsdb/tsdbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, handler http.HandlerFunc) *Service {
+	srv := tsdbtest.NewServer(t, handler)
+	return &Service{
+		logger: log.New("tsdb.jaeger"),
+		im:     &tsdbtest.FakeInstanceManager{Instance: &tracingds.DatasourceInfo{HTTPClient: srv.Client(), URL: srv.URL}},
+	}
+}
+
+const testTrace = `{
+	"traceID": "abc",
+	"spans": [
+		{
+			"traceID": "abc", "spanID": "2", "processID": "p2", "operationName": "query",
+			"startTime": 1600000000200000, "duration": 300000,
+			"references": [{"refType": "CHILD_OF", "spanID": "1", "traceID": "abc"}],
+			"logs": [{"timestamp": 1600000000250000, "fields": [{"key": "event", "type": "string", "value": "done"}]}],
+			"tags": [{"key": "db.type", "type": "string", "value": "sql"}]
+		},
+		{
+			"traceID": "abc", "spanID": "1", "processID": "p1", "operationName": "GET /api",
+			"startTime": 1600000000000000, "duration": 1000000, "logs": []
+		}
+	],
+	"processes": {
+		"p1": {"serviceName": "frontend", "tags": [{"key": "hostname", "type": "string", "value": "host-1"}]},
+		"p2": {"serviceName": "db", "tags": []}
+	}
+}`
+
+func TestQueryData(t *testing.T) {
+	timeRange := backend.TimeRange{From: time.Unix(1600000000, 0), To: time.Unix(1600003600, 0)}
+
+	t.Run("gets the trace of the ID", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/traces/abc", r.URL.Path)
+			_, _ = w.Write([]byte(`{"data": [` + testTrace + `]}`))
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"query": "abc"}`)}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+
+		frame := res.Responses["A"].Frames[0]
+		assert.Equal(t, "A", frame.RefID)
+		assert.Equal(t, data.VisTypeTrace, string(frame.Meta.PreferredVisualization))
+		require.Equal(t, 2, frame.Rows())
+		assert.Equal(t, []interface{}{
+			"abc", "2", "1", "query", "db", "",
+			1600000000200.0, 300.0,
+			`[{"timestamp":1600000000250,"fields":[{"key":"event","type":"string","value":"done"}]}]`,
+			`[{"key":"db.type","type":"string","value":"sql"}]`,
+			"", "",
+		}, frame.RowCopy(0))
+		assert.Equal(t, "", frame.At(2, 1))
+		assert.Equal(t, `[{"key":"hostname","type":"string","value":"host-1"}]`, frame.At(5, 1))
+	})
+
+	t.Run("searches traces", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/traces", r.URL.Path)
+			assert.Equal(t, "frontend", r.URL.Query().Get("service"))
+			assert.Empty(t, r.URL.Query().Get("operation"))
+			assert.JSONEq(t, `{"error": "true", "http.status": "500 error"}`, r.URL.Query().Get("tags"))
+			assert.Equal(t, "20", r.URL.Query().Get("limit"))
+			assert.Equal(t, "1600000000000000", r.URL.Query().Get("start"))
+			assert.Equal(t, "1600003600000000", r.URL.Query().Get("end"))
+			_, _ = w.Write([]byte(`{"data": [` + testTrace + `]}`))
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				QueryType: searchQueryType,
+				TimeRange: timeRange,
+				JSON: []byte(`{"service": "frontend", "operation": "__ALL__",
+					"tags": "error=true http.status=\"500 error\"", "limit": 20}`),
+			}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+
+		frame := res.Responses["A"].Frames[0]
+		require.Equal(t, 1, frame.Rows())
+		assert.Equal(t, []interface{}{
+			"abc", "frontend: GET /api", time.Unix(1600000000, 0).UTC(), int64(1000000),
+		}, frame.RowCopy(0))
+	})
+
+	t.Run("gets the dependency graph", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/dependencies", r.URL.Path)
+			assert.Equal(t, "1600003600000", r.URL.Query().Get("endTs"))
+			assert.Equal(t, "3600000", r.URL.Query().Get("lookback"))
+			_, _ = w.Write([]byte(`{"data": [
+				{"parent": "frontend", "child": "db", "callCount": 3},
+				{"parent": "frontend", "child": "cache", "callCount": 5},
+				{"parent": "cache", "child": "db", "callCount": 1}
+			]}`))
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{
+				RefID:     "A",
+				QueryType: dependencyGraphQueryType,
+				TimeRange: timeRange,
+				JSON:      []byte(`{}`),
+			}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		frames := res.Responses["A"].Frames
+		require.Len(t, frames, 2)
+
+		nodes, edges := frames[0], frames[1]
+		require.Equal(t, 3, nodes.Rows())
+		assert.Equal(t, []interface{}{"cache", "cache", int64(5)}, nodes.RowCopy(0))
+		assert.Equal(t, []interface{}{"db", "db", int64(4)}, nodes.RowCopy(1))
+		assert.Equal(t, []interface{}{"frontend", "frontend", int64(0)}, nodes.RowCopy(2))
+		require.Equal(t, 3, edges.Rows())
+		assert.Equal(t, []interface{}{"cache_db", "cache", "db", int64(1)}, edges.RowCopy(0))
+		assert.Equal(t, data.VisTypeNodeGraph, string(edges.Meta.PreferredVisualization))
+	})
+
+	t.Run("returns the errors of the API", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"data": null, "errors": [{"code": 404, "msg": "trace not found"}]}`))
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"query": "abc"}`)},
+				{RefID: "B", QueryType: "upload", JSON: []byte(`{}`)},
+			},
+		})
+		require.NoError(t, err)
+		require.EqualError(t, res.Responses["A"].Error, "failed to get trace with id abc: request failed with status 404 Not Found: trace not found")
+		require.EqualError(t, res.Responses["B"].Error, `unsupported query type "upload"`)
+	})
+}
+
+func TestCheckHealth(t *testing.T) {
+	for services, status := range map[string]backend.HealthStatus{
+		`["frontend"]`: backend.HealthStatusOk,
+		`[]`:           backend.HealthStatusError,
+	} {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/services", r.URL.Path)
+			_, _ = w.Write([]byte(`{"data": ` + services + `}`))
+		})
+
+		res, err := s.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, status, res.Status, services)
+	}
+}
@@ -0,0 +1,245 @@
+package jaeger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logfmt/logfmt"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/traceframe"
+	"github.com/grafana/grafana/pkg/tsdb/tracingds"
+)
+
+// allOperations is the operation of searches for any operation, as set by the
+// query editor.
+const allOperations = "__ALL__"
+
+type traceLog struct {
+	// Timestamp is in microseconds.
+	Timestamp float64               `json:"timestamp"`
+	Fields    []traceframe.KeyValue `json:"fields"`
+}
+
+type process struct {
+	ServiceName string                `json:"serviceName"`
+	Tags        []traceframe.KeyValue `json:"tags"`
+}
+
+type spanReference struct {
+	RefType string `json:"refType"`
+	SpanID  string `json:"spanID"`
+	TraceID string `json:"traceID"`
+}
+
+type span struct {
+	TraceID       string `json:"traceID"`
+	SpanID        string `json:"spanID"`
+	ProcessID     string `json:"processID"`
+	OperationName string `json:"operationName"`
+	// StartTime and Duration are in microseconds.
+	StartTime   int64                 `json:"startTime"`
+	Duration    int64                 `json:"duration"`
+	Logs        []traceLog            `json:"logs"`
+	Tags        []traceframe.KeyValue `json:"tags"`
+	References  []spanReference       `json:"references"`
+	Warnings    []string              `json:"warnings"`
+	StackTraces []string              `json:"stackTraces"`
+}
+
+// parentSpanID returns the ID of the span the span is a child of, if any.
+func (s span) parentSpanID() string {
+	for _, ref := range s.References {
+		if ref.RefType == "CHILD_OF" {
+			return ref.SpanID
+		}
+	}
+	return ""
+}
+
+type trace struct {
+	TraceID   string             `json:"traceID"`
+	Spans     []span             `json:"spans"`
+	Processes map[string]process `json:"processes"`
+	Warnings  []string           `json:"warnings"`
+}
+
+func (s *Service) trace(ctx context.Context, dsInfo *tracingds.DatasourceInfo, traceID string) backend.DataResponse {
+	if traceID == "" {
+		return backend.DataResponse{Frames: data.Frames{traceFrame(trace{})}}
+	}
+
+	var traces []trace
+	if err := s.get(ctx, dsInfo, "/api/traces/"+url.PathEscape(traceID), nil, &traces); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to get trace with id %s: %w", traceID, err)}
+	}
+	if len(traces) == 0 {
+		return backend.DataResponse{Frames: data.Frames{traceFrame(trace{})}}
+	}
+	return backend.DataResponse{Frames: data.Frames{traceFrame(traces[0])}}
+}
+
+func (s *Service) search(ctx context.Context, dsInfo *tracingds.DatasourceInfo, query backend.DataQuery, model *queryModel) backend.DataResponse {
+	params, err := searchParams(query, model)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+
+	var traces []trace
+	if err := s.get(ctx, dsInfo, "/api/traces", params, &traces); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to search traces: %w", err)}
+	}
+	return backend.DataResponse{Frames: data.Frames{tracesTableFrame(traces)}}
+}
+
+// searchParams returns the parameters of the search API, as sent by the Jaeger
+// UI. The tags are in logfmt in the query, and are sent as JSON.
+func searchParams(query backend.DataQuery, model *queryModel) (url.Values, error) {
+	params := url.Values{}
+	if model.Service != "" {
+		params.Set("service", model.Service)
+	}
+	if model.Operation != "" && model.Operation != allOperations {
+		params.Set("operation", model.Operation)
+	}
+	if model.Tags != "" {
+		tags, err := logfmtToJSON(model.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tags %q: %w", model.Tags, err)
+		}
+		params.Set("tags", tags)
+	}
+	if model.MinDuration != "" {
+		params.Set("minDuration", model.MinDuration)
+	}
+	if model.MaxDuration != "" {
+		params.Set("maxDuration", model.MaxDuration)
+	}
+	if model.Limit > 0 {
+		params.Set("limit", strconv.Itoa(model.Limit))
+	}
+	params.Set("start", strconv.FormatInt(query.TimeRange.From.UnixNano()/int64(time.Microsecond), 10))
+	params.Set("end", strconv.FormatInt(query.TimeRange.To.UnixNano()/int64(time.Microsecond), 10))
+	params.Set("lookback", "custom")
+	return params, nil
+}
+
+func logfmtToJSON(s string) (string, error) {
+	tags := map[string]string{}
+	decoder := logfmt.NewDecoder(strings.NewReader(s))
+	for decoder.ScanRecord() {
+		for decoder.ScanKeyval() {
+			tags[string(decoder.Key())] = string(decoder.Value())
+		}
+	}
+	if err := decoder.Err(); err != nil {
+		return "", err
+	}
+
+	encoded, err := json.Marshal(tags)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// traceFrame returns the trace frame of the spans of the trace.
+func traceFrame(t trace) *data.Frame {
+	spans := make([]traceframe.Span, 0, len(t.Spans))
+	for _, s := range t.Spans {
+		p := t.Processes[s.ProcessID]
+		logs := make([]traceframe.Log, 0, len(s.Logs))
+		for _, l := range s.Logs {
+			logs = append(logs, traceframe.Log{Timestamp: l.Timestamp / 1000, Fields: l.Fields})
+		}
+		spans = append(spans, traceframe.Span{
+			TraceID:       s.TraceID,
+			SpanID:        s.SpanID,
+			ParentSpanID:  s.parentSpanID(),
+			OperationName: s.OperationName,
+			ServiceName:   p.ServiceName,
+			ServiceTags:   p.Tags,
+			StartTime:     float64(s.StartTime) / 1000,
+			Duration:      float64(s.Duration) / 1000,
+			Logs:          logs,
+			Tags:          s.Tags,
+			Warnings:      s.Warnings,
+			StackTraces:   s.StackTraces,
+		})
+	}
+	return traceframe.New("jaeger", spans)
+}
+
+// traceSummary is the row of a trace in the search results.
+type traceSummary struct {
+	traceID   string
+	traceName string
+	startTime int64
+	duration  int64
+}
+
+func summarize(t trace) (traceSummary, bool) {
+	if len(t.Spans) == 0 {
+		return traceSummary{}, false
+	}
+
+	summary := traceSummary{traceID: t.TraceID, startTime: t.Spans[0].StartTime}
+	root := &t.Spans[0]
+	var end int64
+	for i := range t.Spans {
+		s := &t.Spans[i]
+		if s.StartTime < summary.startTime {
+			summary.startTime = s.StartTime
+		}
+		if s.StartTime+s.Duration > end {
+			end = s.StartTime + s.Duration
+		}
+		// The trace is named after its root span, the earliest span without
+		// parent, or the earliest span when the root span is missing.
+		if isRoot, rootIsRoot := s.parentSpanID() == "", root.parentSpanID() == ""; isRoot != rootIsRoot {
+			if isRoot {
+				root = s
+			}
+		} else if s.StartTime < root.StartTime {
+			root = s
+		}
+	}
+	summary.duration = end - summary.startTime
+	summary.traceName = t.Processes[root.ProcessID].ServiceName + ": " + root.OperationName
+	if summary.traceID == "" {
+		summary.traceID = root.TraceID
+	}
+	return summary, true
+}
+
+// tracesTableFrame returns the table of the traces found by a search, the most
+// recent first.
+func tracesTableFrame(traces []trace) *data.Frame {
+	summaries := make([]traceSummary, 0, len(traces))
+	for _, t := range traces {
+		if summary, ok := summarize(t); ok {
+			summaries = append(summaries, summary)
+		}
+	}
+	sort.SliceStable(summaries, func(i, j int) bool {
+		return summaries[i].startTime > summaries[j].startTime
+	})
+
+	frame := data.NewFrame("Traces",
+		data.NewField("traceID", nil, []string{}).SetConfig(&data.FieldConfig{DisplayNameFromDS: "Trace ID"}),
+		data.NewField("traceName", nil, []string{}).SetConfig(&data.FieldConfig{DisplayNameFromDS: "Trace name"}),
+		data.NewField("startTime", nil, []time.Time{}).SetConfig(&data.FieldConfig{DisplayNameFromDS: "Start time"}),
+		data.NewField("duration", nil, []int64{}).SetConfig(&data.FieldConfig{DisplayNameFromDS: "Duration", Unit: "µs"}),
+	)
+	frame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	for _, summary := range summaries {
+		frame.AppendRow(summary.traceID, summary.traceName, time.UnixMicro(summary.startTime).UTC(), summary.duration)
+	}
+	return frame
+}
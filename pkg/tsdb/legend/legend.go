@@ -0,0 +1,192 @@
+// Package legend renders the legend templates of series, such as
+// "{{instance}}" or "{{app | upper}}", from their labels. The data sources
+// share it so that legends behave the same way everywhere.
+package legend
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+var (
+	logger = log.New("tsdb.legend")
+
+	templatePattern = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
+)
+
+// MissingLabel is how the templates of labels the series doesn't have render.
+type MissingLabel int
+
+const (
+	// MissingLabelEmpty renders the templates of missing labels as empty
+	// strings.
+	MissingLabelEmpty MissingLabel = iota
+	// MissingLabelKeep keeps the templates of missing labels as they are in
+	// the format, e.g. {{job}}.
+	MissingLabelKeep
+)
+
+// Options of the rendering of legends.
+type Options struct {
+	MissingLabel MissingLabel
+}
+
+// Format renders the {{ }} templates of the format with the labels of a
+// series. A template is a label name, optionally followed by functions
+// separated by pipes, with their arguments as quoted strings or bare words,
+// e.g. {{instance | trimPrefix "node-"}}. A function that is unknown or fails
+// leaves the value as it is. Templates of missing labels rendering empty
+// follow the MissingLabel option, while functions like default can fill them.
+func Format(format string, labels map[string]string, opts Options) string {
+	return templatePattern.ReplaceAllStringFunc(format, func(match string) string {
+		template := strings.TrimSuffix(strings.TrimPrefix(match, "{{"), "}}")
+		value, found := render(template, labels)
+		if !found && value == "" && opts.MissingLabel == MissingLabelKeep {
+			return match
+		}
+		return value
+	})
+}
+
+// render renders the inside of a {{ }} template, found being false when the
+// series doesn't have its label. Names with spaces, like the CloudWatch
+// dimension {{Instance Name}}, are looked up as a whole before the template is
+// parsed as a pipeline.
+func render(template string, labels map[string]string) (value string, found bool) {
+	if value, found := labels[strings.TrimSpace(template)]; found {
+		return value, true
+	}
+
+	stages := splitPipeline(template)
+	if len(stages[0]) > 0 {
+		value, found = labels[strings.Join(stages[0], " ")]
+	}
+
+	for _, stage := range stages[1:] {
+		if len(stage) == 0 {
+			continue
+		}
+		fn, ok := funcs[stage[0]]
+		if !ok {
+			logger.Debug("Unknown legend function", "function", stage[0])
+			continue
+		}
+		transformed, err := fn(value, stage[1:])
+		if err != nil {
+			logger.Debug("Legend function failed", "function", stage[0], "err", err)
+			continue
+		}
+		value = transformed
+	}
+	return value, found
+}
+
+// fn transforms a label value in a legend template, e.g. the upper in
+// {{app | upper}}. args are the arguments following the function name.
+type fn func(value string, args []string) (string, error)
+
+var funcs = map[string]fn{
+	"upper": func(value string, args []string) (string, error) {
+		return strings.ToUpper(value), checkArgs(args, 0)
+	},
+	"lower": func(value string, args []string) (string, error) {
+		return strings.ToLower(value), checkArgs(args, 0)
+	},
+	"trimPrefix": func(value string, args []string) (string, error) {
+		if err := checkArgs(args, 1); err != nil {
+			return value, err
+		}
+		return strings.TrimPrefix(value, args[0]), nil
+	},
+	"trimSuffix": func(value string, args []string) (string, error) {
+		if err := checkArgs(args, 1); err != nil {
+			return value, err
+		}
+		return strings.TrimSuffix(value, args[0]), nil
+	},
+	"replace": func(value string, args []string) (string, error) {
+		if err := checkArgs(args, 2); err != nil {
+			return value, err
+		}
+		return strings.ReplaceAll(value, args[0], args[1]), nil
+	},
+	"default": func(value string, args []string) (string, error) {
+		if err := checkArgs(args, 1); err != nil {
+			return value, err
+		}
+		if value == "" {
+			return args[0], nil
+		}
+		return value, nil
+	},
+	// toFloat normalizes numeric values, so that e.g. le="0.50" renders as 0.5.
+	"toFloat": func(value string, args []string) (string, error) {
+		if err := checkArgs(args, 0); err != nil {
+			return value, err
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return value, err
+		}
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	},
+}
+
+func checkArgs(args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %d arguments, got %d", n, len(args))
+	}
+	return nil
+}
+
+// splitPipeline splits a legend template into the words of each of its
+// stages. Quoted words are unquoted and may contain spaces and pipes.
+func splitPipeline(template string) [][]string {
+	stages := [][]string{{}}
+	for i := 0; i < len(template); {
+		switch c := template[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '|':
+			stages = append(stages, []string{})
+			i++
+		case c == '"' || c == '`':
+			end := quotedEnd(template, i)
+			word, err := strconv.Unquote(template[i:end])
+			if err != nil {
+				word = strings.TrimSuffix(template[i+1:end], string(c))
+			}
+			stages[len(stages)-1] = append(stages[len(stages)-1], word)
+			i = end
+		default:
+			end := strings.IndexAny(template[i:], " \t|\"`")
+			if end < 0 {
+				end = len(template) - i
+			}
+			stages[len(stages)-1] = append(stages[len(stages)-1], template[i:i+end])
+			i += end
+		}
+	}
+	return stages
+}
+
+// quotedEnd returns the index after the closing quote of the string starting at
+// start, or the end of s when it is not closed.
+func quotedEnd(s string, start int) int {
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if quote == '"' {
+				i++
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,63 @@
+package legend
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	labels := map[string]string{
+		"app":           "backend",
+		"instance":      "node-1:9100",
+		"Instance Name": "web-1",
+		"le":            "0.50",
+		"path":          "/api|v1",
+	}
+	legend := func(format string) string {
+		return Format(format, labels, Options{})
+	}
+
+	t.Run("it replaces the templates by the label values", func(t *testing.T) {
+		require.Equal(t, "backend on node-1:9100", legend("{{app}} on {{ instance }}"))
+		require.Equal(t, "no templates", legend("no templates"))
+	})
+
+	t.Run("it looks up names with spaces as a whole", func(t *testing.T) {
+		require.Equal(t, "web-1", legend("{{Instance Name}}"))
+		require.Equal(t, "web-1", legend("{{ Instance Name }}"))
+		require.Equal(t, "WEB-1", legend("{{Instance Name | upper}}"))
+	})
+
+	t.Run("it applies functions to the label value", func(t *testing.T) {
+		require.Equal(t, "BACKEND", legend("{{app | upper}}"))
+		require.Equal(t, "1:9100", legend(`{{instance | trimPrefix "node-"}}`))
+		require.Equal(t, "node-1", legend(`{{ instance | trimSuffix ":9100" }}`))
+		require.Equal(t, "0.5", legend("{{le | toFloat}}"))
+		require.Equal(t, "/api v1", legend(`{{path | replace "|" " "}}`))
+	})
+
+	t.Run("it chains functions", func(t *testing.T) {
+		require.Equal(t, "NODE-1", legend(`{{instance | trimSuffix ":9100" | upper}}`))
+		require.Equal(t, "le 0.5 of BACKEND", legend("le {{le|toFloat}} of {{app|upper}}"))
+	})
+
+	t.Run("it leaves the value alone for unknown or failing functions", func(t *testing.T) {
+		require.Equal(t, "backend", legend("{{app | shout}}"))
+		require.Equal(t, "backend", legend("{{app | toFloat}}"))
+		require.Equal(t, "backend", legend("{{app | trimPrefix}}"))
+	})
+
+	t.Run("it renders missing labels as empty strings", func(t *testing.T) {
+		require.Equal(t, "backend ", legend("{{app}} {{job}}"))
+		require.Equal(t, "", legend("{{job | upper}}"))
+		require.Equal(t, "none", legend(`{{job | default "none"}}`))
+	})
+
+	t.Run("it keeps the templates of missing labels", func(t *testing.T) {
+		keep := Options{MissingLabel: MissingLabelKeep}
+		require.Equal(t, "backend {{ job }}", Format("{{app}} {{ job }}", labels, keep))
+		require.Equal(t, "{{job | upper}}", Format("{{job | upper}}", labels, keep))
+		require.Equal(t, "none", Format(`{{job | default "none"}}`, labels, keep))
+	})
+}
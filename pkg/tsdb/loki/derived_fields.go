@@ -0,0 +1,150 @@
+package loki
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const derivedFieldValuePlaceholder = "${__value.raw}"
+
+// derivedFieldConfig is a derived field in the settings of the data source. It
+// extracts a value from log lines with MatcherRegex into the field Name, and
+// links it to an external URL, or to a query of the data source with UID
+// DatasourceUid when set, with URL as the query.
+type derivedFieldConfig struct {
+	MatcherRegex    string `json:"matcherRegex"`
+	Name            string `json:"name"`
+	URL             string `json:"url"`
+	URLDisplayLabel string `json:"urlDisplayLabel"`
+	DatasourceUid   string `json:"datasourceUid"`
+}
+
+type derivedField struct {
+	derivedFieldConfig
+	matcher *regexp.Regexp
+}
+
+// compileDerivedFields returns the derived fields with a name and a valid
+// regex. The regexes are written for the JavaScript engine of the browser, so
+// the ones Go doesn't support are skipped instead of failing the data source.
+func compileDerivedFields(configs []derivedFieldConfig, logger log.Logger) []derivedField {
+	fields := make([]derivedField, 0, len(configs))
+	for _, config := range configs {
+		if config.Name == "" || config.MatcherRegex == "" {
+			continue
+		}
+		matcher, err := regexp.Compile(config.MatcherRegex)
+		if err != nil {
+			logger.Warn("Skipping derived field with an unsupported regex", "name", config.Name, "err", err)
+			continue
+		}
+		fields = append(fields, derivedField{derivedFieldConfig: config, matcher: matcher})
+	}
+	return fields
+}
+
+// applyDerivedFields adds a field per derived field to a frame of log lines,
+// holding the values extracted from the lines, so that they are part of the
+// result wherever it is rendered. Lines without a match have an empty value.
+func applyDerivedFields(frame *data.Frame, fields []derivedField) {
+	if len(fields) == 0 {
+		return
+	}
+	timeField, _ := frame.FieldByName("time")
+	lineField, _ := frame.FieldByName("line")
+	if timeField == nil || lineField == nil {
+		return
+	}
+
+	from, to := frameTimeRange(timeField)
+	for _, derived := range fields {
+		values := make([]string, lineField.Len())
+		for i := range values {
+			values[i] = derived.extract(lineField.At(i).(string))
+		}
+		field := data.NewField(derived.Name, nil, values)
+		if links := derived.links(from, to); len(links) > 0 {
+			field.SetConfig(&data.FieldConfig{Links: links})
+		}
+		frame.Fields = append(frame.Fields, field)
+	}
+}
+
+// extract returns the first group of the regex matched in the line, or the
+// whole match when the regex has no groups.
+func (f derivedField) extract(line string) string {
+	match := f.matcher.FindStringSubmatch(line)
+	switch {
+	case match == nil:
+		return ""
+	case len(match) > 1:
+		return match[1]
+	}
+	return match[0]
+}
+
+func (f derivedField) links(from, to time.Time) []data.DataLink {
+	if f.URL == "" {
+		return nil
+	}
+
+	title := f.URLDisplayLabel
+	if f.DatasourceUid != "" {
+		if title == "" {
+			title = "Query with " + f.DatasourceUid
+		}
+		return []data.DataLink{{
+			Title: title,
+			URL:   exploreQueryURL(f.DatasourceUid, f.URL, from, to),
+		}}
+	}
+
+	if title == "" {
+		title = "Go to " + f.URL
+	}
+	return []data.DataLink{{
+		Title:       title,
+		URL:         f.URL,
+		TargetBlank: true,
+	}}
+}
+
+func frameTimeRange(timeField *data.Field) (time.Time, time.Time) {
+	var from, to time.Time
+	for i := 0; i < timeField.Len(); i++ {
+		t := timeField.At(i).(time.Time)
+		if from.IsZero() || t.Before(from) {
+			from = t
+		}
+		if t.After(to) {
+			to = t
+		}
+	}
+	return from, to
+}
+
+// exploreQueryURL returns the Explore URL that runs the query in the data
+// source with the given UID, over the given range.
+func exploreQueryURL(datasourceUID string, query string, from, to time.Time) string {
+	state, _ := json.Marshal(map[string]interface{}{
+		"datasource": datasourceUID,
+		"queries": []map[string]string{
+			{"refId": "A", "query": query},
+		},
+		"range": map[string]string{
+			"from": strconv.FormatInt(from.UnixMilli(), 10),
+			"to":   strconv.FormatInt(to.UnixMilli(), 10),
+		},
+	})
+
+	// The placeholder stays unescaped, so that it is interpolated with the value.
+	left := strings.ReplaceAll(url.QueryEscape(string(state)), url.QueryEscape(derivedFieldValuePlaceholder), derivedFieldValuePlaceholder)
+	return "/explore?left=" + left
+}
@@ -0,0 +1,58 @@
+package loki
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDerivedFields(t *testing.T) {
+	t.Run("invalid regexes are skipped", func(t *testing.T) {
+		fields := compileDerivedFields([]derivedFieldConfig{
+			{Name: "traceID", MatcherRegex: "traceID=("},
+			{Name: "lookbehind", MatcherRegex: `(?<=trace=)\w+`},
+			{Name: "user", MatcherRegex: `user=(\w+)`},
+		}, log.New("test"))
+		require.Len(t, fields, 1)
+		require.Equal(t, "user", fields[0].Name)
+	})
+
+	t.Run("values are extracted into fields with links", func(t *testing.T) {
+		fields := compileDerivedFields([]derivedFieldConfig{
+			{Name: "traceID", MatcherRegex: `traceID=(\w+)`, URL: derivedFieldValuePlaceholder, DatasourceUid: "tempo"},
+			{Name: "user", MatcherRegex: `user=\w+`, URL: "https://users.example.com/${__value.raw}", URLDisplayLabel: "User"},
+			{Name: "incomplete"},
+		}, log.New("test"))
+		require.Len(t, fields, 2)
+
+		frame := data.NewFrame("",
+			data.NewField("time", nil, []time.Time{time.UnixMilli(2000), time.UnixMilli(1000)}),
+			data.NewField("line", nil, []string{"traceID=abc user=bob", "nothing here"}))
+		applyDerivedFields(frame, fields)
+
+		require.Len(t, frame.Fields, 4)
+		traceID := frame.Fields[2]
+		require.Equal(t, "traceID", traceID.Name)
+		require.Equal(t, "abc", traceID.At(0))
+		require.Equal(t, "", traceID.At(1))
+		require.Len(t, traceID.Config.Links, 1)
+		require.Equal(t, "Query with tempo", traceID.Config.Links[0].Title)
+
+		link, err := url.Parse(traceID.Config.Links[0].URL)
+		require.NoError(t, err)
+		require.Equal(t, "/explore", link.Path)
+		require.JSONEq(t, `{
+			"datasource": "tempo",
+			"queries": [{"refId": "A", "query": "${__value.raw}"}],
+			"range": {"from": "1000", "to": "2000"}
+		}`, link.Query().Get("left"))
+
+		user := frame.Fields[3]
+		require.Equal(t, "user=bob", user.At(0))
+		require.Equal(t, []data.DataLink{{Title: "User", URL: "https://users.example.com/${__value.raw}", TargetBlank: true}}, user.Config.Links)
+	})
+}
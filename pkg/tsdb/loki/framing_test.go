@@ -30,6 +30,7 @@ func TestSuccessResponse(t *testing.T) {
 		{name: "parse a matrix response with NaN", filepath: "matrix_nan"},
 		// you can produce Infinity by using `quantile_over_time(42,` (value larger than 1)
 		{name: "parse a matrix response with Infinity", filepath: "matrix_inf"},
+		{name: "parse a streams response", filepath: "streams_simple"},
 	}
 
 	for _, test := range tt {
@@ -54,6 +55,35 @@ func TestSuccessResponse(t *testing.T) {
 	}
 }
 
+func TestInstantQuery(t *testing.T) {
+	var requestedPath string
+	client := &client.DefaultClient{
+		Address: "http://localhost:9999",
+		Tripperware: func(t http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				requestedPath = req.URL.Path
+				return (&MockedRoundTripper{
+					statusCode:  200,
+					contentType: "application/json",
+					responseBytes: []byte(`{
+						"status": "success",
+						"data": {
+							"resultType": "vector",
+							"result": [{"metric": {"level": "error"}, "value": [1645029699, "12"]}]
+						}
+					}`),
+				}).RoundTrip(req)
+			})
+		},
+	}
+
+	frames, err := runQuery(client, &lokiQuery{Expr: `count_over_time({app="a"}[1m])`, QueryType: QueryTypeInstant})
+	require.NoError(t, err)
+	require.Equal(t, "/loki/api/v1/query", requestedPath)
+	require.Len(t, frames, 1)
+	require.Equal(t, 12.0, frames[0].Fields[1].At(0))
+}
+
 func TestErrorResponse(t *testing.T) {
 	// NOTE: when there is an error-response, it comes with
 	// HTTP code 400, and the format seems to change between versions:
@@ -126,6 +156,12 @@ func (mockedRT *MockedRoundTripper) RoundTrip(req *http.Request) (*http.Response
 	}, nil
 }
 
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
 func makeMockedClient(statusCode int, contentType string, responseBytes []byte) *client.DefaultClient {
 	client := &client.DefaultClient{
 		Address: "http://localhost:9999",
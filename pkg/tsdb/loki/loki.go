@@ -3,20 +3,21 @@ package loki
 import (
 	"context"
 	"crypto/tls"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"regexp"
-	"strings"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/infra/httpclient"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/tsdb/legend"
 	"github.com/grafana/loki/pkg/logcli/client"
 	"github.com/grafana/loki/pkg/loghttp"
 	"github.com/grafana/loki/pkg/logproto"
@@ -33,24 +34,27 @@ type Service struct {
 }
 
 func ProvideService(httpClientProvider httpclient.Provider, tracer tracing.Tracer) *Service {
+	plog := log.New("tsdb.loki")
 	return &Service{
-		im:     datasource.NewInstanceManager(newInstanceSettings(httpClientProvider)),
-		plog:   log.New("tsdb.loki"),
+		im:     datasource.NewInstanceManager(newInstanceSettings(httpClientProvider, plog)),
+		plog:   plog,
 		tracer: tracer,
 	}
 }
 
-var (
-	legendFormat = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
-)
-
 type datasourceInfo struct {
 	HTTPClient        *http.Client
 	URL               string
 	TLSClientConfig   *tls.Config
 	BasicAuthUser     string
 	BasicAuthPassword string
-	TimeInterval      string `json:"timeInterval"`
+	// Headers are the custom and basic auth headers the HTTP client adds to
+	// requests, for the connections that don't go through it.
+	Headers      http.Header `json:"-"`
+	TimeInterval string      `json:"timeInterval"`
+	// DerivedFieldConfigs are applied to the log lines of the results.
+	DerivedFieldConfigs []derivedFieldConfig `json:"derivedFields"`
+	DerivedFields       []derivedField       `json:"-"`
 }
 
 type QueryModel struct {
@@ -60,9 +64,10 @@ type QueryModel struct {
 	Interval     string `json:"interval"`
 	IntervalMS   int    `json:"intervalMS"`
 	Resolution   int64  `json:"resolution"`
+	MaxLines     int    `json:"maxLines"`
 }
 
-func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.InstanceFactoryFunc {
+func newInstanceSettings(httpClientProvider httpclient.Provider, plog log.Logger) datasource.InstanceFactoryFunc {
 	return func(settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
 		opts, err := settings.HTTPClientOptions()
 		if err != nil {
@@ -85,13 +90,17 @@ func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.Inst
 			return nil, fmt.Errorf("error reading settings: %w", err)
 		}
 
+		derivedFields := compileDerivedFields(jsonData.DerivedFieldConfigs, plog)
+
 		model := &datasourceInfo{
 			HTTPClient:        client,
 			URL:               settings.URL,
 			TLSClientConfig:   tlsClientConfig,
 			TimeInterval:      jsonData.TimeInterval,
+			DerivedFields:     derivedFields,
 			BasicAuthUser:     settings.BasicAuthUser,
 			BasicAuthPassword: settings.DecryptedSecureJSONData["basicAuthPassword"],
+			Headers:           clientHeaders(opts),
 		}
 		return model, nil
 	}
@@ -106,17 +115,7 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 		return result, err
 	}
 
-	client := &client.DefaultClient{
-		Address:  dsInfo.URL,
-		Username: dsInfo.BasicAuthUser,
-		Password: dsInfo.BasicAuthPassword,
-		TLSConfig: config.TLSConfig{
-			InsecureSkipVerify: dsInfo.TLSClientConfig.InsecureSkipVerify,
-		},
-		Tripperware: func(t http.RoundTripper) http.RoundTripper {
-			return dsInfo.HTTPClient.Transport
-		},
-	}
+	client := newLokiClient(dsInfo)
 
 	queries, err := parseQuery(req)
 	if err != nil {
@@ -124,6 +123,7 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 	}
 
 	for _, query := range queries {
+		query.DerivedFields = dsInfo.DerivedFields
 		s.plog.Debug("Sending query", "start", query.Start, "end", query.End, "step", query.Step, "query", query.Expr)
 		_, span := s.tracer.Start(ctx, "alerting.loki")
 		span.SetAttributes("expr", query.Expr, attribute.Key("expr").String(query.Expr))
@@ -141,44 +141,78 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 	return result, nil
 }
 
+// clientHeaders returns the headers the HTTP client of the options adds to
+// requests.
+func clientHeaders(opts sdkhttpclient.Options) http.Header {
+	headers := http.Header{}
+	for name, value := range opts.Headers {
+		headers.Set(name, value)
+	}
+	if opts.BasicAuth != nil {
+		auth := opts.BasicAuth.User + ":" + opts.BasicAuth.Password
+		headers.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
+	}
+	return headers
+}
+
+func newLokiClient(dsInfo *datasourceInfo) *client.DefaultClient {
+	return &client.DefaultClient{
+		Address:  dsInfo.URL,
+		Username: dsInfo.BasicAuthUser,
+		Password: dsInfo.BasicAuthPassword,
+		TLSConfig: config.TLSConfig{
+			InsecureSkipVerify: dsInfo.TLSClientConfig.InsecureSkipVerify,
+		},
+		Tripperware: func(t http.RoundTripper) http.RoundTripper {
+			return dsInfo.HTTPClient.Transport
+		},
+	}
+}
+
 //If legend (using of name or pattern instead of time series name) is used, use that name/pattern for formatting
 func formatLegend(metric model.Metric, query *lokiQuery) string {
 	if query.LegendFormat == "" {
 		return metric.String()
 	}
 
-	result := legendFormat.ReplaceAllFunc([]byte(query.LegendFormat), func(in []byte) []byte {
-		labelName := strings.Replace(string(in), "{{", "", 1)
-		labelName = strings.Replace(labelName, "}}", "", 1)
-		labelName = strings.TrimSpace(labelName)
-		if val, exists := metric[model.LabelName(labelName)]; exists {
-			return []byte(val)
-		}
-		return []byte{}
-	})
-
-	return string(result)
+	labels := make(map[string]string, len(metric))
+	for label, value := range metric {
+		labels[string(label)] = string(value)
+	}
+	return legend.Format(query.LegendFormat, labels, legend.Options{})
 }
 
 func parseResponse(value *loghttp.QueryResponse, query *lokiQuery) (data.Frames, error) {
-	frames := data.Frames{}
+	switch result := value.Data.Result.(type) {
+	case loghttp.Matrix:
+		return matrixToFrames(result, query), nil
+	case loghttp.Vector:
+		return vectorToFrames(result, query), nil
+	case loghttp.Scalar:
+		return data.Frames{scalarToFrame(result)}, nil
+	case loghttp.Streams:
+		return streamsToFrames(result, query), nil
+	}
+	return data.Frames{}, fmt.Errorf("unsupported result format: %q", value.Data.ResultType)
+}
 
-	//We are currently processing only matrix results (for alerting)
-	matrix, ok := value.Data.Result.(loghttp.Matrix)
-	if !ok {
-		return frames, fmt.Errorf("unsupported result format: %q", value.Data.ResultType)
+func metricLabels(metric model.Metric) data.Labels {
+	labels := make(data.Labels, len(metric))
+	for k, v := range metric {
+		labels[string(k)] = string(v)
 	}
+	return labels
+}
+
+func matrixToFrames(matrix loghttp.Matrix, query *lokiQuery) data.Frames {
+	frames := data.Frames{}
 
 	for _, v := range matrix {
 		name := formatLegend(v.Metric, query)
-		tags := make(map[string]string, len(v.Metric))
+		tags := metricLabels(v.Metric)
 		timeVector := make([]time.Time, 0, len(v.Values))
 		values := make([]float64, 0, len(v.Values))
 
-		for k, v := range v.Metric {
-			tags[string(k)] = string(v)
-		}
-
 		for _, k := range v.Values {
 			timeVector = append(timeVector, time.Unix(k.Timestamp.Unix(), 0).UTC())
 			values = append(values, float64(k.Value))
@@ -189,19 +223,70 @@ func parseResponse(value *loghttp.QueryResponse, query *lokiQuery) (data.Frames,
 			data.NewField("value", tags, values).SetConfig(&data.FieldConfig{DisplayNameFromDS: name})))
 	}
 
-	return frames, nil
+	return frames
+}
+
+func vectorToFrames(vector loghttp.Vector, query *lokiQuery) data.Frames {
+	frames := data.Frames{}
+
+	for _, v := range vector {
+		name := formatLegend(v.Metric, query)
+		frames = append(frames, data.NewFrame(name,
+			data.NewField("time", nil, []time.Time{time.Unix(v.Timestamp.Unix(), 0).UTC()}),
+			data.NewField("value", metricLabels(v.Metric), []float64{float64(v.Value)}).SetConfig(&data.FieldConfig{DisplayNameFromDS: name})))
+	}
+
+	return frames
+}
+
+func scalarToFrame(scalar loghttp.Scalar) *data.Frame {
+	return data.NewFrame("",
+		data.NewField("time", nil, []time.Time{time.Unix(scalar.Timestamp.Unix(), 0).UTC()}),
+		data.NewField("value", nil, []float64{float64(scalar.Value)}))
+}
+
+// streamsToFrames returns a frame of log lines per stream, with the labels of
+// the stream on the line field and the derived fields of the query.
+func streamsToFrames(streams loghttp.Streams, query *lokiQuery) data.Frames {
+	frames := data.Frames{}
+
+	for _, stream := range streams {
+		timeVector := make([]time.Time, 0, len(stream.Entries))
+		lines := make([]string, 0, len(stream.Entries))
+
+		for _, entry := range stream.Entries {
+			timeVector = append(timeVector, entry.Timestamp.UTC())
+			lines = append(lines, entry.Line)
+		}
+
+		frame := data.NewFrame("",
+			data.NewField("time", nil, timeVector),
+			data.NewField("line", data.Labels(stream.Labels), lines))
+		applyDerivedFields(frame, query.DerivedFields)
+		frames = append(frames, frame)
+	}
+
+	return frames
 }
 
 // we extracted this part of the functionality to make it easy to unit-test it
 func runQuery(client *client.DefaultClient, query *lokiQuery) (data.Frames, error) {
-	// `limit` only applies to log-producing queries, and we
-	// currently only support metric queries, so this can be set to any value.
-	limit := 1
+	// `limit` only applies to log-producing queries, metric queries ignore it.
+	limit := query.MaxLines
+	if limit == 0 {
+		limit = defaultMaxLines
+	}
 
-	// we do not use `interval`, so we set it to zero
-	interval := time.Duration(0)
+	var value *loghttp.QueryResponse
+	var err error
+	if query.QueryType == QueryTypeInstant {
+		value, err = client.Query(query.Expr, limit, query.End, logproto.BACKWARD, false)
+	} else {
+		// we do not use `interval`, so we set it to zero
+		interval := time.Duration(0)
 
-	value, err := client.QueryRange(query.Expr, limit, query.Start, query.End, logproto.BACKWARD, query.Step, interval, false)
+		value, err = client.QueryRange(query.Expr, limit, query.Start, query.End, logproto.BACKWARD, query.Step, interval, false)
+	}
 	if err != nil {
 		return data.Frames{}, err
 	}
@@ -41,11 +41,11 @@ func TestLoki(t *testing.T) {
 }
 
 func TestParseResponse(t *testing.T) {
-	t.Run("value is not of type matrix", func(t *testing.T) {
+	t.Run("value is of an unsupported type", func(t *testing.T) {
 		queryRes := data.Frames{}
 		value := loghttp.QueryResponse{
 			Data: loghttp.QueryResponseData{
-				Result: loghttp.Vector{},
+				ResultType: "unknown",
 			},
 		}
 		res, err := parseResponse(&value, nil)
@@ -95,4 +95,59 @@ func TestParseResponse(t *testing.T) {
 			t.Errorf("Result mismatch (-want +got):\n%s", diff)
 		}
 	})
+	t.Run("vector response should be parsed to a frame per sample", func(t *testing.T) {
+		value := loghttp.QueryResponse{
+			Data: loghttp.QueryResponseData{
+				Result: loghttp.Vector{
+					{Metric: p.Metric{"app": "Application"}, Value: 7, Timestamp: 2000},
+					{Metric: p.Metric{"app": "Other"}, Value: 8, Timestamp: 2000},
+				},
+			},
+		}
+
+		frames, err := parseResponse(&value, &lokiQuery{LegendFormat: "{{app}}"})
+		require.NoError(t, err)
+		require.Len(t, frames, 2)
+		require.Equal(t, "Application", frames[0].Name)
+		require.Equal(t, time.Date(1970, 1, 1, 0, 0, 2, 0, time.UTC), frames[0].Fields[0].At(0))
+		require.Equal(t, 7.0, frames[0].Fields[1].At(0))
+		require.Equal(t, data.Labels{"app": "Other"}, frames[1].Fields[1].Labels)
+	})
+
+	t.Run("scalar response should be parsed to a single value", func(t *testing.T) {
+		value := loghttp.QueryResponse{
+			Data: loghttp.QueryResponseData{
+				Result: loghttp.Scalar{Value: 42, Timestamp: 3000},
+			},
+		}
+
+		frames, err := parseResponse(&value, &lokiQuery{})
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		require.Equal(t, 42.0, frames[0].Fields[1].At(0))
+	})
+
+	t.Run("streams response should be parsed to a frame of lines per stream", func(t *testing.T) {
+		value := loghttp.QueryResponse{
+			Data: loghttp.QueryResponseData{
+				Result: loghttp.Streams{
+					{
+						Labels: loghttp.LabelSet{"app": "Application"},
+						Entries: []loghttp.Entry{
+							{Timestamp: time.Unix(2, 500), Line: "second"},
+							{Timestamp: time.Unix(1, 0), Line: "first"},
+						},
+					},
+				},
+			},
+		}
+
+		frames, err := parseResponse(&value, &lokiQuery{})
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+		require.Equal(t, time.Unix(2, 500).UTC(), frames[0].Fields[0].At(0))
+		require.Equal(t, "second", frames[0].Fields[1].At(0))
+		require.Equal(t, "first", frames[0].Fields[1].At(1))
+		require.Equal(t, data.Labels{"app": "Application"}, frames[0].Fields[1].Labels)
+	})
 }
@@ -2,6 +2,7 @@ package loki
 
 import (
 	"encoding/json"
+	"fmt"
 	"math"
 	"strconv"
 	"strings"
@@ -11,6 +12,10 @@ import (
 	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
 )
 
+// defaultMaxLines is the number of log lines returned by a log query without
+// maxLines.
+const defaultMaxLines = 1000
+
 const (
 	varInterval   = "$__interval"
 	varIntervalMs = "$__interval_ms"
@@ -52,6 +57,16 @@ func interpolateVariables(expr string, interval time.Duration, timeRange time.Du
 	return expr
 }
 
+func parseQueryType(queryType string) (QueryType, error) {
+	switch QueryType(queryType) {
+	case "", QueryTypeRange:
+		return QueryTypeRange, nil
+	case QueryTypeInstant:
+		return QueryTypeInstant, nil
+	}
+	return "", fmt.Errorf("invalid query type %q", queryType)
+}
+
 func parseQuery(queryContext *backend.QueryDataRequest) ([]*lokiQuery, error) {
 	qs := []*lokiQuery{}
 	for _, query := range queryContext.Queries {
@@ -61,6 +76,19 @@ func parseQuery(queryContext *backend.QueryDataRequest) ([]*lokiQuery, error) {
 			return nil, err
 		}
 
+		queryType, err := parseQueryType(model.QueryType)
+		if err != nil {
+			return nil, err
+		}
+
+		maxLines := defaultMaxLines
+		if model.MaxLines < 0 {
+			return nil, fmt.Errorf("invalid max lines %d", model.MaxLines)
+		}
+		if model.MaxLines > 0 {
+			maxLines = model.MaxLines
+		}
+
 		start := query.TimeRange.From
 		end := query.TimeRange.To
 
@@ -78,7 +106,9 @@ func parseQuery(queryContext *backend.QueryDataRequest) ([]*lokiQuery, error) {
 
 		qs = append(qs, &lokiQuery{
 			Expr:         expr,
+			QueryType:    queryType,
 			Step:         step,
+			MaxLines:     maxLines,
 			LegendFormat: model.LegendFormat,
 			Start:        start,
 			End:          end,
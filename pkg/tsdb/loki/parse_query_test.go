@@ -58,4 +58,41 @@ func TestParseQuery(t *testing.T) {
 
 		require.Equal(t, "go_goroutines 2s 2000 50s 50 50000", interpolateVariables(expr, interval, timeRange))
 	})
+	t.Run("parsing query type and max lines", func(t *testing.T) {
+		queryContext := &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{
+					JSON: []byte(`{"expr": "{app=\"a\"}", "queryType": "instant", "maxLines": 20}`),
+					TimeRange: backend.TimeRange{
+						From: time.Now().Add(-3000 * time.Second),
+						To:   time.Now(),
+					},
+					Interval: time.Second * 15,
+				},
+				{
+					JSON: []byte(`{"expr": "{app=\"a\"}"}`),
+					TimeRange: backend.TimeRange{
+						From: time.Now().Add(-3000 * time.Second),
+						To:   time.Now(),
+					},
+					Interval: time.Second * 15,
+				},
+			},
+		}
+		models, err := parseQuery(queryContext)
+		require.NoError(t, err)
+		require.Equal(t, QueryTypeInstant, models[0].QueryType)
+		require.Equal(t, 20, models[0].MaxLines)
+		require.Equal(t, QueryTypeRange, models[1].QueryType)
+		require.Equal(t, defaultMaxLines, models[1].MaxLines)
+	})
+	t.Run("parsing an invalid query type", func(t *testing.T) {
+		queryContext := &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{JSON: []byte(`{"expr": "{app=\"a\"}", "queryType": "stream"}`)},
+			},
+		}
+		_, err := parseQuery(queryContext)
+		require.EqualError(t, err, `invalid query type "stream"`)
+	})
 }
@@ -0,0 +1,195 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/loki/pkg/loghttp"
+)
+
+// Live tailing connects to the tail websocket of Loki and pushes the log
+// lines over Grafana Live, so the browser doesn't connect to Loki. The channel
+// paths of tails start with tailPathPrefix, and the data of the subscription is
+// a tailRequest.
+
+const (
+	tailPathPrefix = "tail/"
+	// maxTailDelay is the longest Loki waits for late log lines.
+	maxTailDelay = 5 * time.Second
+)
+
+type tailRequest struct {
+	// Expr is the LogQL log query tailed.
+	Expr string `json:"expr"`
+	// DelayFor is how many seconds Loki waits for late log lines.
+	DelayFor int `json:"delayFor"`
+	// MaxLines is the number of lines sent at most per message.
+	MaxLines int `json:"maxLines"`
+}
+
+func parseTailRequest(path string, raw json.RawMessage) (*tailRequest, error) {
+	if !strings.HasPrefix(path, tailPathPrefix) {
+		return nil, fmt.Errorf("unknown stream path %q", path)
+	}
+
+	var req tailRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("invalid tail request: %w", err)
+	}
+	if req.Expr == "" {
+		return nil, fmt.Errorf("missing tail expression")
+	}
+	if req.DelayFor < 0 || time.Duration(req.DelayFor)*time.Second > maxTailDelay {
+		return nil, fmt.Errorf("invalid tail delay %d, it must be at most %s", req.DelayFor, maxTailDelay)
+	}
+	if req.MaxLines < 0 {
+		return nil, fmt.Errorf("invalid max lines %d", req.MaxLines)
+	}
+	if req.MaxLines == 0 {
+		req.MaxLines = defaultMaxLines
+	}
+	return &req, nil
+}
+
+func (s *Service) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if !strings.HasPrefix(req.Path, tailPathPrefix) {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	if _, err := parseTailRequest(req.Path, req.Data); err != nil {
+		return nil, err
+	}
+	if _, err := s.getDSInfo(req.PluginContext); err != nil {
+		return nil, err
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+func (s *Service) PublishStream(_ context.Context, _ *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream tails the query of the stream until the last subscriber leaves or
+// Loki closes the connection.
+func (s *Service) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	tail, err := parseTailRequest(req.Path, req.Data)
+	if err != nil {
+		return err
+	}
+	dsInfo, err := s.getDSInfo(req.PluginContext)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dialTail(ctx, dsInfo, tail, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to connect to the Loki tail: %w", err)
+	}
+	s.plog.Debug("Tailing", "expr", tail.Expr)
+	return s.runTail(ctx, conn, dsInfo.DerivedFields, sender.SendFrame)
+}
+
+// dialTail connects to the tail websocket of Loki with the TLS settings and
+// headers of the data source, as the websocket doesn't go through its HTTP
+// client.
+func dialTail(ctx context.Context, dsInfo *datasourceInfo, tail *tailRequest, start time.Time) (*websocket.Conn, error) {
+	u, err := url.Parse(dsInfo.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/loki/api/v1/tail"
+
+	params := url.Values{}
+	params.Set("query", tail.Expr)
+	params.Set("delay_for", strconv.Itoa(tail.DelayFor))
+	params.Set("limit", strconv.Itoa(tail.MaxLines))
+	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	u.RawQuery = params.Encode()
+
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		TLSClientConfig:  dsInfo.TLSClientConfig,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+	}
+	conn, res, err := dialer.DialContext(ctx, u.String(), dsInfo.Headers.Clone())
+	if err != nil {
+		if res != nil {
+			_ = res.Body.Close()
+			return nil, fmt.Errorf("%w: %s", err, res.Status)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// runTail sends a frame with the log lines of every message of the tail.
+func (s *Service) runTail(ctx context.Context, conn *websocket.Conn, derivedFields []derivedField,
+	send func(*data.Frame, data.FrameInclude) error) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// Closing the connection ends the read below.
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		_ = conn.Close()
+	}()
+
+	for {
+		var res loghttp.TailResponse
+		if err := conn.ReadJSON(&res); err != nil {
+			if ctx.Err() != nil || websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return nil
+			}
+			return fmt.Errorf("failed to read from the Loki tail: %w", err)
+		}
+		if len(res.DroppedStreams) > 0 {
+			s.plog.Warn("Loki dropped tailed log lines", "streams", len(res.DroppedStreams))
+		}
+		frame := tailFrame(res.Streams)
+		if frame.Rows() == 0 {
+			continue
+		}
+		applyDerivedFields(frame, derivedFields)
+		if err := send(frame, data.IncludeAll); err != nil {
+			return err
+		}
+	}
+}
+
+// tailFrame returns the log lines of the streams in a single frame, as the
+// frames sent on a channel all have the same fields. The labels of the lines
+// are in a field.
+func tailFrame(streams []loghttp.Stream) *data.Frame {
+	timeVector := []time.Time{}
+	lines := []string{}
+	labels := []string{}
+
+	for _, stream := range streams {
+		streamLabels := data.Labels(stream.Labels).String()
+		for _, entry := range stream.Entries {
+			timeVector = append(timeVector, entry.Timestamp.UTC())
+			lines = append(lines, entry.Line)
+			labels = append(labels, streamLabels)
+		}
+	}
+
+	return data.NewFrame("tail",
+		data.NewField("time", nil, timeVector),
+		data.NewField("line", nil, lines),
+		data.NewField("labels", nil, labels))
+}
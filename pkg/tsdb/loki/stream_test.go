@@ -0,0 +1,122 @@
+package loki
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTailRequest(t *testing.T) {
+	t.Run("defaults the max lines", func(t *testing.T) {
+		req, err := parseTailRequest("tail/abc", []byte(`{"expr": "{app=\"a\"}", "delayFor": 2}`))
+		require.NoError(t, err)
+		require.Equal(t, `{app="a"}`, req.Expr)
+		require.Equal(t, 2, req.DelayFor)
+		require.Equal(t, defaultMaxLines, req.MaxLines)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		_, err := parseTailRequest("query/abc", []byte(`{"expr": "{app=\"a\"}"}`))
+		require.EqualError(t, err, `unknown stream path "query/abc"`)
+
+		_, err = parseTailRequest("tail/abc", []byte(`{}`))
+		require.EqualError(t, err, "missing tail expression")
+
+		_, err = parseTailRequest("tail/abc", []byte(`{"expr": "{app=\"a\"}", "delayFor": 10}`))
+		require.EqualError(t, err, "invalid tail delay 10, it must be at most 5s")
+	})
+}
+
+func TestRunTail(t *testing.T) {
+	var requestedQuery, requestedLimit, tenant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedQuery = r.URL.Query().Get("query")
+		requestedLimit = r.URL.Query().Get("limit")
+		tenant = r.Header.Get("X-Scope-OrgID")
+		user, password, _ := r.BasicAuth()
+		if r.URL.Path != "/loki/api/v1/tail" || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{
+			"streams": [
+				{"stream": {"app": "a"}, "values": [["1645030246277587968", "first"], ["1645030246277587969", "second"]]},
+				{"stream": {"app": "b"}, "values": [["1645030246277587970", "third"]]}
+			]
+		}`)))
+		require.NoError(t, conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	}))
+	defer server.Close()
+
+	dsInfo := &datasourceInfo{
+		URL: server.URL + "/",
+		Headers: clientHeaders(sdkhttpclient.Options{
+			Headers:   map[string]string{"X-Scope-OrgID": "tenant"},
+			BasicAuth: &sdkhttpclient.BasicAuthOptions{User: "user", Password: "secret"},
+		}),
+	}
+	conn, err := dialTail(context.Background(), dsInfo, &tailRequest{Expr: `{app=~"a|b"}`, MaxLines: 10}, time.Now())
+	require.NoError(t, err)
+
+	s := &Service{plog: log.New("test")}
+	frames := []*data.Frame{}
+	err = s.runTail(context.Background(), conn, nil, func(frame *data.Frame, _ data.FrameInclude) error {
+		frames = append(frames, frame)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{app=~"a|b"}`, requestedQuery)
+	require.Equal(t, "10", requestedLimit)
+	require.Equal(t, "tenant", tenant)
+
+	require.Len(t, frames, 1)
+	require.Equal(t, 3, frames[0].Rows())
+	require.Equal(t, "second", frames[0].Fields[1].At(1))
+	require.Equal(t, "app=b", frames[0].Fields[2].At(2))
+	require.Equal(t, time.Unix(0, 1645030246277587970).UTC(), frames[0].Fields[0].At(2))
+}
+
+func TestDialTailErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := dialTail(context.Background(), &datasourceInfo{URL: server.URL}, &tailRequest{Expr: `{app="a"}`}, time.Now())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "401 Unauthorized")
+}
+
+func TestRunTailStopsWithContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer func() { _ = conn.Close() }()
+		// Wait for the client to go away.
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	conn, err := dialTail(context.Background(), &datasourceInfo{URL: server.URL}, &tailRequest{Expr: `{app="a"}`, MaxLines: 10}, time.Now())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	s := &Service{plog: log.New("test")}
+	require.NoError(t, s.runTail(ctx, conn, nil, func(*data.Frame, data.FrameInclude) error { return nil }))
+}
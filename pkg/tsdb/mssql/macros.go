@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
-	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
 	"github.com/grafana/grafana/pkg/tsdb/sqleng"
 )
 
@@ -70,27 +69,6 @@ func (m *msSQLMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *bac
 		return fmt.Sprintf("'%s'", timeRange.From.UTC().Format(time.RFC3339)), nil
 	case "__timeTo":
 		return fmt.Sprintf("'%s'", timeRange.To.UTC().Format(time.RFC3339)), nil
-	case "__timeGroup":
-		if len(args) < 2 {
-			return "", fmt.Errorf("macro %v needs time column and interval", name)
-		}
-		interval, err := gtime.ParseInterval(strings.Trim(args[1], `'"`))
-		if err != nil {
-			return "", fmt.Errorf("error parsing interval %v", args[1])
-		}
-		if len(args) == 3 {
-			err := sqleng.SetupFillmode(query, interval, args[2])
-			if err != nil {
-				return "", err
-			}
-		}
-		return fmt.Sprintf("FLOOR(DATEDIFF(second, '1970-01-01', %s)/%.0f)*%.0f", args[0], interval.Seconds(), interval.Seconds()), nil
-	case "__timeGroupAlias":
-		tg, err := m.evaluateMacro(timeRange, query, "__timeGroup", args)
-		if err == nil {
-			return tg + " AS [time]", nil
-		}
-		return "", err
 	case "__unixEpochFilter":
 		if len(args) == 0 {
 			return "", fmt.Errorf("missing time column argument for macro %v", name)
@@ -105,28 +83,37 @@ func (m *msSQLMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *bac
 		return fmt.Sprintf("%d", timeRange.From.UTC().UnixNano()), nil
 	case "__unixEpochNanoTo":
 		return fmt.Sprintf("%d", timeRange.To.UTC().UnixNano()), nil
-	case "__unixEpochGroup":
-		if len(args) < 2 {
-			return "", fmt.Errorf("macro %v needs time column and interval and optional fill value", name)
-		}
-		interval, err := gtime.ParseInterval(strings.Trim(args[1], `'`))
-		if err != nil {
-			return "", fmt.Errorf("error parsing interval %v", args[1])
-		}
-		if len(args) == 3 {
-			err := sqleng.SetupFillmode(query, interval, args[2])
-			if err != nil {
-				return "", err
-			}
-		}
-		return fmt.Sprintf("FLOOR(%s/%v)*%v", args[0], interval.Seconds(), interval.Seconds()), nil
-	case "__unixEpochGroupAlias":
-		tg, err := m.evaluateMacro(timeRange, query, "__unixEpochGroup", args)
-		if err == nil {
-			return tg + " AS [time]", nil
-		}
-		return "", err
 	default:
-		return "", fmt.Errorf("unknown macro %q", name)
+		sql, ok, err := m.EvaluateMacro(m, timeRange, query, name, args)
+		if !ok {
+			return "", fmt.Errorf("unknown macro %q", name)
+		}
+		return sql, err
 	}
 }
+
+func (m *msSQLMacroEngine) TimeGroup(column string, interval time.Duration) string {
+	return fmt.Sprintf("FLOOR(DATEDIFF(second, '1970-01-01', %s)/%.0f)*%.0f", column, interval.Seconds(), interval.Seconds())
+}
+
+func (m *msSQLMacroEngine) Epoch(column string) string {
+	return fmt.Sprintf("DATEDIFF(second, '1970-01-01', %s)", column)
+}
+
+func (m *msSQLMacroEngine) EpochGroup(column string, size float64) string {
+	return fmt.Sprintf("FLOOR(%s/%v)*%v", column, size, size)
+}
+
+func (m *msSQLMacroEngine) TimeAlias(expression string) string {
+	return expression + " AS [time]"
+}
+
+func (m *msSQLMacroEngine) StringLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// LikeWildcards returns [, which starts a character range in LIKE patterns
+// of SQL Server.
+func (m *msSQLMacroEngine) LikeWildcards() []string {
+	return []string{"["}
+}
@@ -87,6 +87,14 @@ func TestMacroEngine(t *testing.T) {
 			require.Equal(t, sql+" AS [time]", sql2)
 		})
 
+		t.Run("interpolate __timeGroup function with a calendar interval", func(t *testing.T) {
+			query := &backend.DataQuery{JSON: []byte(`{"timezone": "UTC"}`)}
+			sql, err := engine.Interpolate(query, timeRange, "GROUP BY $__timeGroupAlias(time_column,'1M')")
+			require.Nil(t, err)
+
+			require.Equal(t, "GROUP BY CASE WHEN DATEDIFF(second, '1970-01-01', time_column) < 1525132800 THEN 1522540800 END AS [time]", sql)
+		})
+
 		t.Run("interpolate __timeGroup function with fill (value = NULL)", func(t *testing.T) {
 			_, err := engine.Interpolate(query, timeRange, "GROUP BY $__timeGroup(time_column,'5m', NULL)")
 			require.Nil(t, err)
@@ -147,6 +155,32 @@ func TestMacroEngine(t *testing.T) {
 			require.Equal(t, "SELECT FLOOR(time_column/300)*300", sql)
 			require.Equal(t, sql+" AS [time]", sql2)
 		})
+
+		t.Run("interpolate __unixEpochGroupMs function", func(t *testing.T) {
+			sql, err := engine.Interpolate(query, timeRange, "SELECT $__unixEpochGroupMs(time_column,'5m')")
+			require.Nil(t, err)
+			sql2, err := engine.Interpolate(query, timeRange, "SELECT $__unixEpochGroupMsAlias(time_column,'5m')")
+			require.Nil(t, err)
+
+			require.Equal(t, "SELECT FLOOR(time_column/300000)*300000", sql)
+			require.Equal(t, sql+" AS [time]", sql2)
+		})
+
+		t.Run("interpolate __searchLike function", func(t *testing.T) {
+			sql, err := engine.Interpolate(query, timeRange, "SELECT name FROM t WHERE $__searchLike(name)")
+			require.Nil(t, err)
+			require.Equal(t, "SELECT name FROM t WHERE 1=1", sql)
+
+			searchQuery := &backend.DataQuery{JSON: []byte(`{"searchFilter": "it's 5%\\"}`)}
+			sql, err = engine.Interpolate(searchQuery, timeRange, "SELECT name FROM t WHERE $__searchLike(name)")
+			require.Nil(t, err)
+			require.Equal(t, "SELECT name FROM t WHERE name LIKE 'it''s 5!%\\%' ESCAPE '!'", sql)
+
+			searchQuery = &backend.DataQuery{JSON: []byte(`{"searchFilter": "[a-c]_"}`)}
+			sql, err = engine.Interpolate(searchQuery, timeRange, "SELECT name FROM t WHERE $__searchLike(name)")
+			require.Nil(t, err)
+			require.Equal(t, "SELECT name FROM t WHERE name LIKE '![a-c]!_%' ESCAPE '!'", sql)
+		})
 	})
 
 	t.Run("Given a time range between 1960-02-01 07:00 and 1965-02-03 08:00", func(t *testing.T) {
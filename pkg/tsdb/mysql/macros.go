@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
-	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/tsdb/sqleng"
 )
@@ -74,27 +74,6 @@ func (m *mySQLMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *bac
 		return fmt.Sprintf("FROM_UNIXTIME(%d)", timeRange.From.UTC().Unix()), nil
 	case "__timeTo":
 		return fmt.Sprintf("FROM_UNIXTIME(%d)", timeRange.To.UTC().Unix()), nil
-	case "__timeGroup":
-		if len(args) < 2 {
-			return "", fmt.Errorf("macro %v needs time column and interval", name)
-		}
-		interval, err := gtime.ParseInterval(strings.Trim(args[1], `'"`))
-		if err != nil {
-			return "", fmt.Errorf("error parsing interval %v", args[1])
-		}
-		if len(args) == 3 {
-			err := sqleng.SetupFillmode(query, interval, args[2])
-			if err != nil {
-				return "", err
-			}
-		}
-		return fmt.Sprintf("UNIX_TIMESTAMP(%s) DIV %.0f * %.0f", args[0], interval.Seconds(), interval.Seconds()), nil
-	case "__timeGroupAlias":
-		tg, err := m.evaluateMacro(timeRange, query, "__timeGroup", args)
-		if err == nil {
-			return tg + " AS \"time\"", nil
-		}
-		return "", err
 	case "__unixEpochFilter":
 		if len(args) == 0 {
 			return "", fmt.Errorf("missing time column argument for macro %v", name)
@@ -109,28 +88,35 @@ func (m *mySQLMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *bac
 		return fmt.Sprintf("%d", timeRange.From.UTC().UnixNano()), nil
 	case "__unixEpochNanoTo":
 		return fmt.Sprintf("%d", timeRange.To.UTC().UnixNano()), nil
-	case "__unixEpochGroup":
-		if len(args) < 2 {
-			return "", fmt.Errorf("macro %v needs time column and interval and optional fill value", name)
-		}
-		interval, err := gtime.ParseInterval(strings.Trim(args[1], `'`))
-		if err != nil {
-			return "", fmt.Errorf("error parsing interval %v", args[1])
-		}
-		if len(args) == 3 {
-			err := sqleng.SetupFillmode(query, interval, args[2])
-			if err != nil {
-				return "", err
-			}
-		}
-		return fmt.Sprintf("%s DIV %v * %v", args[0], interval.Seconds(), interval.Seconds()), nil
-	case "__unixEpochGroupAlias":
-		tg, err := m.evaluateMacro(timeRange, query, "__unixEpochGroup", args)
-		if err == nil {
-			return tg + " AS \"time\"", nil
-		}
-		return "", err
 	default:
-		return "", fmt.Errorf("unknown macro %v", name)
+		sql, ok, err := m.EvaluateMacro(m, timeRange, query, name, args)
+		if !ok {
+			return "", fmt.Errorf("unknown macro %v", name)
+		}
+		return sql, err
 	}
 }
+
+func (m *mySQLMacroEngine) TimeGroup(column string, interval time.Duration) string {
+	return fmt.Sprintf("UNIX_TIMESTAMP(%s) DIV %.0f * %.0f", column, interval.Seconds(), interval.Seconds())
+}
+
+func (m *mySQLMacroEngine) Epoch(column string) string {
+	return fmt.Sprintf("UNIX_TIMESTAMP(%s)", column)
+}
+
+func (m *mySQLMacroEngine) EpochGroup(column string, size float64) string {
+	return fmt.Sprintf("%s DIV %v * %v", column, size, size)
+}
+
+func (m *mySQLMacroEngine) TimeAlias(expression string) string {
+	return expression + " AS \"time\""
+}
+
+func (m *mySQLMacroEngine) StringLiteral(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", "''").Replace(s) + "'"
+}
+
+func (m *mySQLMacroEngine) LikeWildcards() []string {
+	return nil
+}
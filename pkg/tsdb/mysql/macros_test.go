@@ -57,6 +57,14 @@ func TestMacroEngine(t *testing.T) {
 			require.Equal(t, sql+" AS \"time\"", sql2)
 		})
 
+		t.Run("interpolate __timeGroup function with a calendar interval", func(t *testing.T) {
+			query := &backend.DataQuery{JSON: []byte(`{"weekStart": "monday"}`)}
+			sql, err := engine.Interpolate(query, timeRange, "GROUP BY $__timeGroup(time_column,'1w')")
+			require.Nil(t, err)
+
+			require.Equal(t, "GROUP BY ((UNIX_TIMESTAMP(time_column) + 259200) DIV 604800 * 604800 - 259200)", sql)
+		})
+
 		t.Run("interpolate __timeFilter function", func(t *testing.T) {
 			sql, err := engine.Interpolate(query, timeRange, "WHERE $__timeFilter(time_column)")
 			require.Nil(t, err)
@@ -115,6 +123,27 @@ func TestMacroEngine(t *testing.T) {
 			require.Equal(t, "SELECT time_column DIV 300 * 300", sql)
 			require.Equal(t, sql+" AS \"time\"", sql2)
 		})
+
+		t.Run("interpolate __unixEpochGroupMs function", func(t *testing.T) {
+			sql, err := engine.Interpolate(query, timeRange, "SELECT $__unixEpochGroupMs(time_column,'5m')")
+			require.Nil(t, err)
+			sql2, err := engine.Interpolate(query, timeRange, "SELECT $__unixEpochGroupMsAlias(time_column,'5m')")
+			require.Nil(t, err)
+
+			require.Equal(t, "SELECT time_column DIV 300000 * 300000", sql)
+			require.Equal(t, sql+" AS \"time\"", sql2)
+		})
+
+		t.Run("interpolate __searchLike function", func(t *testing.T) {
+			sql, err := engine.Interpolate(query, timeRange, "SELECT name FROM t WHERE $__searchLike(name)")
+			require.Nil(t, err)
+			require.Equal(t, "SELECT name FROM t WHERE 1=1", sql)
+
+			searchQuery := &backend.DataQuery{JSON: []byte(`{"searchFilter": "it's 5%\\"}`)}
+			sql, err = engine.Interpolate(searchQuery, timeRange, "SELECT name FROM t WHERE $__searchLike(name)")
+			require.Nil(t, err)
+			require.Equal(t, "SELECT name FROM t WHERE name LIKE 'it''s 5!%\\\\%' ESCAPE '!'", sql)
+		})
 	})
 
 	t.Run("Given a time range between 1960-02-01 07:00 and 1965-02-03 08:00", func(t *testing.T) {
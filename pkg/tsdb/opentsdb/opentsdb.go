@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"path"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -70,10 +73,24 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 
 	tsdbQuery.Start = q.TimeRange.From.UnixNano() / int64(time.Millisecond)
 	tsdbQuery.End = q.TimeRange.To.UnixNano() / int64(time.Millisecond)
+	tsdbQuery.ShowQuery = true
 
+	result := backend.NewQueryDataResponse()
+	queries := make([]backend.DataQuery, 0, len(req.Queries))
 	for _, query := range req.Queries {
-		metric := s.buildMetric(query)
+		metric, err := s.buildMetric(query)
+		if err != nil {
+			result.Responses[query.RefID] = backend.DataResponse{Error: err}
+			continue
+		}
+		if metric == nil {
+			continue
+		}
 		tsdbQuery.Queries = append(tsdbQuery.Queries, metric)
+		queries = append(queries, query)
+	}
+	if len(queries) == 0 {
+		return result, nil
 	}
 
 	// TODO: Don't use global variable
@@ -96,10 +113,13 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 		return &backend.QueryDataResponse{}, err
 	}
 
-	result, err := s.parseResponse(res)
+	queryResult, err := s.parseResponse(res, queries)
 	if err != nil {
 		return &backend.QueryDataResponse{}, err
 	}
+	for refID, response := range queryResult.Responses {
+		result.Responses[refID] = response
+	}
 
 	return result, nil
 }
@@ -127,7 +147,13 @@ func (s *Service) createRequest(dsInfo *datasourceInfo, data OpenTsdbQuery) (*ht
 	return req, nil
 }
 
-func (s *Service) parseResponse(res *http.Response) (*backend.QueryDataResponse, error) {
+// nanValueExp matches the NaN values of the nan fill policy, which OpenTSDB
+// returns although they are not valid JSON.
+var nanValueExp = regexp.MustCompile(`:\s*NaN\b`)
+
+// parseResponse returns the results of the sub queries of the request in the
+// response of the query at the same index in queries.
+func (s *Service) parseResponse(res *http.Response, queries []backend.DataQuery) (*backend.QueryDataResponse, error) {
 	resp := backend.NewQueryDataResponse()
 
 	body, err := ioutil.ReadAll(res.Body)
@@ -146,43 +172,144 @@ func (s *Service) parseResponse(res *http.Response) (*backend.QueryDataResponse,
 	}
 
 	var responseData []OpenTsdbResponse
-	err = json.Unmarshal(body, &responseData)
+	err = json.Unmarshal(nanValueExp.ReplaceAll(body, []byte(`:"NaN"`)), &responseData)
 	if err != nil {
 		s.logger.Info("Failed to unmarshal opentsdb response", "error", err, "status", res.Status, "body", string(body))
 		return nil, err
 	}
 
-	frames := data.Frames{}
+	for _, query := range queries {
+		resp.Responses[query.RefID] = backend.DataResponse{Frames: data.Frames{}}
+	}
 	for _, val := range responseData {
-		timeVector := make([]time.Time, 0, len(val.DataPoints))
-		values := make([]float64, 0, len(val.DataPoints))
-		name := val.Metric
-
-		for timeString, value := range val.DataPoints {
-			timestamp, err := strconv.ParseInt(timeString, 10, 64)
-			if err != nil {
-				s.logger.Info("Failed to unmarshal opentsdb timestamp", "timestamp", timeString)
-				return nil, err
-			}
-			timeVector = append(timeVector, time.Unix(timestamp, 0).UTC())
-			values = append(values, value)
+		// Versions of OpenTSDB not returning the sub queries have their
+		// results attributed to the first query.
+		query := queries[0]
+		if val.Query != nil && val.Query.Index >= 0 && val.Query.Index < len(queries) {
+			query = queries[val.Query.Index]
+		}
+
+		frame, err := s.toDataFrame(val, query)
+		if err != nil {
+			return nil, err
 		}
-		frames = append(frames, data.NewFrame(name,
-			data.NewField("time", nil, timeVector),
-			data.NewField("value", nil, values)))
+		result := resp.Responses[query.RefID]
+		result.Frames = append(result.Frames, frame)
+		resp.Responses[query.RefID] = result
 	}
-	result := resp.Responses["A"]
-	result.Frames = frames
-	resp.Responses["A"] = result
 	return resp, nil
 }
 
-func (s *Service) buildMetric(query backend.DataQuery) map[string]interface{} {
+func (s *Service) toDataFrame(val OpenTsdbResponse, query backend.DataQuery) (*data.Frame, error) {
+	timestamps := make([]int64, 0, len(val.DataPoints))
+	for timeString := range val.DataPoints {
+		timestamp, err := strconv.ParseInt(timeString, 10, 64)
+		if err != nil {
+			s.logger.Info("Failed to unmarshal opentsdb timestamp", "timestamp", timeString)
+			return nil, err
+		}
+		timestamps = append(timestamps, timestamp)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+
+	timeVector := make([]time.Time, 0, len(timestamps))
+	values := make([]*float64, 0, len(timestamps))
+	for _, timestamp := range timestamps {
+		value, err := parseValue(val.DataPoints[strconv.FormatInt(timestamp, 10)])
+		if err != nil {
+			s.logger.Info("Failed to unmarshal opentsdb value", "timestamp", timestamp, "error", err)
+			return nil, err
+		}
+		timeVector = append(timeVector, time.Unix(timestamp, 0).UTC())
+		values = append(values, value)
+	}
+
+	var labels data.Labels
+	if len(val.Tags) > 0 {
+		labels = data.Labels(val.Tags)
+	}
+	frame := data.NewFrame(metricLabel(val, query),
+		data.NewField("time", nil, timeVector),
+		data.NewField("value", labels, values))
+	frame.RefID = query.RefID
+	return frame, nil
+}
+
+func parseValue(raw json.RawMessage) (*float64, error) {
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return &v, nil
+	case string:
+		if v == "NaN" {
+			nan := math.NaN()
+			return &nan, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value %s", raw)
+}
+
+// metricLabel names the series of a result like the frontend does, by the
+// alias of the query with $tag_<key> replaced by the tag values, or else by
+// the metric with the values of the tags it filters on.
+func metricLabel(val OpenTsdbResponse, query backend.DataQuery) string {
+	model, err := simplejson.NewJson(query.JSON)
+	if err != nil {
+		return val.Metric
+	}
+
+	if alias := model.Get("alias").MustString(); alias != "" {
+		for key, value := range val.Tags {
+			alias = strings.ReplaceAll(alias, "$tag_"+key, value)
+		}
+		return alias
+	}
+
+	groupBy := map[string]bool{}
+	filters, err := parseFilters(model)
+	if err == nil && len(filters) > 0 {
+		for _, filter := range filters {
+			groupBy[filter.Tagk] = true
+		}
+	} else {
+		for key := range model.Get("tags").MustMap() {
+			groupBy[key] = true
+		}
+	}
+
+	tags := make([]string, 0, len(groupBy))
+	for key, value := range val.Tags {
+		if groupBy[key] {
+			tags = append(tags, key+"="+value)
+		}
+	}
+	if len(tags) == 0 {
+		return val.Metric
+	}
+	sort.Strings(tags)
+	return val.Metric + "{" + strings.Join(tags, ", ") + "}"
+}
+
+// fillPolicies are the policies OpenTSDB fills the missing points of
+// downsampled series with.
+var fillPolicies = map[string]bool{"none": true, "nan": true, "null": true, "zero": true}
+
+// buildMetric returns the sub query of the OpenTSDB request for the query, nil
+// for queries without metric or hidden.
+func (s *Service) buildMetric(query backend.DataQuery) (map[string]interface{}, error) {
 	metric := make(map[string]interface{})
 
 	model, err := simplejson.NewJson(query.JSON)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	if model.Get("metric").MustString() == "" || model.Get("hide").MustBool() {
+		return nil, nil
 	}
 
 	// Setting metric and aggregator
@@ -197,8 +324,15 @@ func (s *Service) buildMetric(query backend.DataQuery) map[string]interface{} {
 			downsampleInterval = "1m" // default value for blank
 		}
 		downsample := downsampleInterval + "-" + model.Get("downsampleAggregator").MustString()
-		if model.Get("downsampleFillPolicy").MustString() != "none" {
-			metric["downsample"] = downsample + "-" + model.Get("downsampleFillPolicy").MustString()
+		fillPolicy := model.Get("downsampleFillPolicy").MustString()
+		if fillPolicy == "" {
+			fillPolicy = "none"
+		}
+		if !fillPolicies[fillPolicy] {
+			return nil, fmt.Errorf("invalid fill policy %q", fillPolicy)
+		}
+		if fillPolicy != "none" {
+			metric["downsample"] = downsample + "-" + fillPolicy
 		} else {
 			metric["downsample"] = downsample
 		}
@@ -227,19 +361,49 @@ func (s *Service) buildMetric(query backend.DataQuery) map[string]interface{} {
 		metric["rateOptions"] = rateOptions
 	}
 
-	// Setting tags
-	tags, tagsCheck := model.CheckGet("tags")
-	if tagsCheck && len(tags.MustMap()) > 0 {
-		metric["tags"] = tags.MustMap()
+	// Setting filters, which take precedence over tags
+	filters, err := parseFilters(model)
+	if err != nil {
+		return nil, err
+	}
+	if len(filters) > 0 {
+		metric["filters"] = filters
+	} else {
+		// Setting tags
+		tags, tagsCheck := model.CheckGet("tags")
+		if tagsCheck && len(tags.MustMap()) > 0 {
+			metric["tags"] = tags.MustMap()
+		}
 	}
 
-	// Setting filters
-	filters, filtersCheck := model.CheckGet("filters")
-	if filtersCheck && len(filters.MustArray()) > 0 {
-		metric["filters"] = filters.MustArray()
+	// Setting explicit tags, returning only the series having exactly the
+	// tags filtered on
+	if model.Get("explicitTags").MustBool() {
+		metric["explicitTags"] = true
 	}
 
-	return metric
+	return metric, nil
+}
+
+func parseFilters(model *simplejson.Json) ([]OpenTsdbFilter, error) {
+	raw, ok := model.CheckGet("filters")
+	if !ok {
+		return nil, nil
+	}
+	b, err := raw.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var filters []OpenTsdbFilter
+	if err := json.Unmarshal(b, &filters); err != nil {
+		return nil, fmt.Errorf("invalid filters: %w", err)
+	}
+	for _, filter := range filters {
+		if filter.Type == "" || filter.Tagk == "" {
+			return nil, fmt.Errorf("invalid filter %+v: missing type or tag key", filter)
+		}
+	}
+	return filters, nil
 }
 
 func (s *Service) getDSInfo(pluginCtx backend.PluginContext) (*datasourceInfo, error) {
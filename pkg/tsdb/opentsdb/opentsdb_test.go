@@ -1,14 +1,19 @@
 package opentsdb
 
 import (
+	"context"
+	"encoding/json"
 	"io/ioutil"
+	"math"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/stretchr/testify/assert"
@@ -35,7 +40,7 @@ func TestOpenTsdbExecutor(t *testing.T) {
 	t.Run("Parse response should handle invalid JSON", func(t *testing.T) {
 		response := `{ invalid }`
 
-		result, err := service.parseResponse(&http.Response{Body: ioutil.NopCloser(strings.NewReader(response))})
+		result, err := service.parseResponse(&http.Response{Body: ioutil.NopCloser(strings.NewReader(response))}, nil)
 		require.Nil(t, result)
 		require.Error(t, err)
 	})
@@ -51,17 +56,19 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			}
 		]`
 
+		value := 50.0
 		testFrame := data.NewFrame("test",
 			data.NewField("time", nil, []time.Time{
 				time.Date(2014, 7, 16, 20, 55, 46, 0, time.UTC),
 			}),
-			data.NewField("value", nil, []float64{
-				50}),
+			data.NewField("value", nil, []*float64{
+				&value}),
 		)
+		testFrame.RefID = "A"
 
 		resp := http.Response{Body: ioutil.NopCloser(strings.NewReader(response))}
 		resp.StatusCode = 200
-		result, err := service.parseResponse(&resp)
+		result, err := service.parseResponse(&resp, []backend.DataQuery{{RefID: "A"}})
 		require.NoError(t, err)
 
 		frame := result.Responses["A"]
@@ -85,7 +92,8 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			),
 		}
 
-		metric := service.buildMetric(query)
+		metric, err := service.buildMetric(query)
+		require.NoError(t, err)
 
 		require.Len(t, metric, 3)
 		require.Equal(t, "cpu.average.percent", metric["metric"])
@@ -107,7 +115,8 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			),
 		}
 
-		metric := service.buildMetric(query)
+		metric, err := service.buildMetric(query)
+		require.NoError(t, err)
 
 		require.Len(t, metric, 2)
 		require.Equal(t, "cpu.average.percent", metric["metric"])
@@ -128,7 +137,8 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			),
 		}
 
-		metric := service.buildMetric(query)
+		metric, err := service.buildMetric(query)
+		require.NoError(t, err)
 
 		require.Len(t, metric, 3)
 		require.Equal(t, "cpu.average.percent", metric["metric"])
@@ -154,7 +164,8 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			),
 		}
 
-		metric := service.buildMetric(query)
+		metric, err := service.buildMetric(query)
+		require.NoError(t, err)
 
 		require.Len(t, metric, 3)
 		require.Equal(t, "cpu.average.percent", metric["metric"])
@@ -185,7 +196,8 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			),
 		}
 
-		metric := service.buildMetric(query)
+		metric, err := service.buildMetric(query)
+		require.NoError(t, err)
 
 		require.Len(t, metric, 5)
 		require.Equal(t, "cpu.average.percent", metric["metric"])
@@ -220,7 +232,8 @@ func TestOpenTsdbExecutor(t *testing.T) {
 			),
 		}
 
-		metric := service.buildMetric(query)
+		metric, err := service.buildMetric(query)
+		require.NoError(t, err)
 
 		require.Len(t, metric, 5)
 		require.Equal(t, "cpu.average.percent", metric["metric"])
@@ -239,4 +252,157 @@ func TestOpenTsdbExecutor(t *testing.T) {
 		require.Equal(t, float64(45), metricRateOptions["counterMax"])
 		require.Equal(t, float64(60), metricRateOptions["resetValue"])
 	})
+	t.Run("Build metric with filters and explicit tags", func(t *testing.T) {
+		query := backend.DataQuery{
+			JSON: []byte(`
+					{
+						"metric": "cpu.average.percent",
+						"aggregator": "avg",
+						"disableDownsampling": false,
+						"downsampleInterval": "5m",
+						"downsampleAggregator": "sum",
+						"downsampleFillPolicy": "zero",
+						"explicitTags": true,
+						"tags": {
+							"env": "prod"
+						},
+						"filters": [
+							{"type": "literal_or", "tagk": "host", "filter": "a|b", "groupBy": true},
+							{"type": "regexp", "tagk": "dc", "filter": "eu-.*", "groupBy": false}
+						]
+					}`,
+			),
+		}
+
+		metric, err := service.buildMetric(query)
+		require.NoError(t, err)
+
+		require.Len(t, metric, 5)
+		require.Equal(t, "5m-sum-zero", metric["downsample"])
+		require.True(t, metric["explicitTags"].(bool))
+		require.Nil(t, metric["tags"])
+		require.Equal(t, []OpenTsdbFilter{
+			{Type: "literal_or", Tagk: "host", Filter: "a|b", GroupBy: true},
+			{Type: "regexp", Tagk: "dc", Filter: "eu-.*"},
+		}, metric["filters"])
+	})
+
+	t.Run("Build metric with invalid options", func(t *testing.T) {
+		_, err := service.buildMetric(backend.DataQuery{
+			JSON: []byte(`{"metric": "cpu", "downsampleFillPolicy": "linear"}`),
+		})
+		require.EqualError(t, err, `invalid fill policy "linear"`)
+
+		_, err = service.buildMetric(backend.DataQuery{
+			JSON: []byte(`{"metric": "cpu", "disableDownsampling": true, "filters": [{"type": "wildcard", "filter": "*"}]}`),
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("Build metric without metric or hidden", func(t *testing.T) {
+		metric, err := service.buildMetric(backend.DataQuery{JSON: []byte(`{"metric": ""}`)})
+		require.NoError(t, err)
+		require.Nil(t, metric)
+
+		metric, err = service.buildMetric(backend.DataQuery{JSON: []byte(`{"metric": "cpu", "hide": true}`)})
+		require.NoError(t, err)
+		require.Nil(t, metric)
+	})
+
+	t.Run("Parse response should map grouped results to their queries", func(t *testing.T) {
+		response := `
+		[
+			{
+				"metric": "cpu",
+				"tags": {"host": "a", "env": "prod"},
+				"aggregateTags": ["core"],
+				"query": {"index": 1},
+				"dps": {"1405544206": null, "1405544146": 50.0, "1405544266": NaN}
+			},
+			{
+				"metric": "cpu",
+				"tags": {"host": "b", "env": "prod"},
+				"aggregateTags": ["core"],
+				"query": {"index": 1},
+				"dps": {"1405544146": 10.0}
+			},
+			{
+				"metric": "mem",
+				"tags": {"host": "a"},
+				"query": {"index": 0},
+				"dps": {"1405544146": 1.0}
+			}
+		]`
+
+		queries := []backend.DataQuery{
+			{RefID: "A", JSON: []byte(`{"metric": "mem", "alias": "memory of $tag_host"}`)},
+			{RefID: "B", JSON: []byte(`{"metric": "cpu", "filters": [{"type": "wildcard", "tagk": "host", "filter": "*", "groupBy": true}]}`)},
+		}
+		resp := http.Response{StatusCode: 200, Body: ioutil.NopCloser(strings.NewReader(response))}
+		result, err := service.parseResponse(&resp, queries)
+		require.NoError(t, err)
+
+		require.Len(t, result.Responses["A"].Frames, 1)
+		assert.Equal(t, "memory of a", result.Responses["A"].Frames[0].Name)
+
+		frames := result.Responses["B"].Frames
+		require.Len(t, frames, 2)
+		assert.Equal(t, "cpu{host=a}", frames[0].Name)
+		assert.Equal(t, "B", frames[0].RefID)
+		assert.Equal(t, data.Labels{"host": "a", "env": "prod"}, frames[0].Fields[1].Labels)
+		assert.Equal(t, "cpu{host=b}", frames[1].Name)
+
+		require.Equal(t, 3, frames[0].Rows())
+		assert.Equal(t, time.Unix(1405544146, 0).UTC(), frames[0].At(0, 0))
+		assert.Equal(t, time.Unix(1405544266, 0).UTC(), frames[0].At(0, 2))
+		assert.Equal(t, 50.0, *frames[0].At(1, 0).(*float64))
+		assert.Nil(t, frames[0].At(1, 1))
+		assert.True(t, math.IsNaN(*frames[0].At(1, 2).(*float64)))
+	})
+}
+
+type fakeInstance struct {
+	dsInfo *datasourceInfo
+}
+
+func (f *fakeInstance) Get(pluginContext backend.PluginContext) (instancemgmt.Instance, error) {
+	return f.dsInfo, nil
+}
+
+func (f *fakeInstance) Do(pluginContext backend.PluginContext, fn instancemgmt.InstanceCallbackFunc) error {
+	return nil
+}
+
+func TestQueryData(t *testing.T) {
+	var body OpenTsdbQuery
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		_, err := rw.Write([]byte(`[{"metric": "mem", "query": {"index": 0}, "dps": {"1": 1}}, {"metric": "cpu", "query": {"index": 1}, "dps": {"1": 2}}]`))
+		require.NoError(t, err)
+	}))
+	t.Cleanup(srv.Close)
+
+	service := &Service{
+		logger: log.New("test"),
+		im:     &fakeInstance{dsInfo: &datasourceInfo{HTTPClient: srv.Client(), URL: srv.URL}},
+	}
+	timeRange := backend.TimeRange{From: time.Unix(1000, 0), To: time.Unix(2000, 0)}
+	result, err := service.QueryData(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{
+			{RefID: "A", TimeRange: timeRange, JSON: []byte(`{"metric": "mem", "disableDownsampling": true}`)},
+			{RefID: "B", TimeRange: timeRange, JSON: []byte(`{"metric": "disk", "hide": true}`)},
+			{RefID: "C", TimeRange: timeRange, JSON: []byte(`{"metric": "cpu", "disableDownsampling": true}`)},
+			{RefID: "D", TimeRange: timeRange, JSON: []byte(`{"metric": "net", "downsampleFillPolicy": "linear"}`)},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, body.ShowQuery)
+	assert.Equal(t, int64(1000000), body.Start)
+	require.Len(t, body.Queries, 2)
+
+	require.Len(t, result.Responses, 3)
+	assert.Equal(t, "mem", result.Responses["A"].Frames[0].Name)
+	assert.Equal(t, "cpu", result.Responses["C"].Frames[0].Name)
+	assert.EqualError(t, result.Responses["D"].Error, `invalid fill policy "linear"`)
 }
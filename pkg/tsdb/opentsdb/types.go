@@ -1,12 +1,35 @@
 package opentsdb
 
+import "encoding/json"
+
 type OpenTsdbQuery struct {
 	Start   int64                    `json:"start"`
 	End     int64                    `json:"end"`
 	Queries []map[string]interface{} `json:"queries"`
+	// ShowQuery makes OpenTSDB return the sub query of each result, so that
+	// results can be mapped back to their query.
+	ShowQuery bool `json:"showQuery,omitempty"`
 }
 
 type OpenTsdbResponse struct {
-	Metric     string             `json:"metric"`
-	DataPoints map[string]float64 `json:"dps"`
+	Metric string `json:"metric"`
+	// DataPoints values are null or NaN for the missing points of the null
+	// and nan fill policies.
+	DataPoints    map[string]json.RawMessage `json:"dps"`
+	Tags          map[string]string          `json:"tags"`
+	AggregateTags []string                   `json:"aggregateTags"`
+	Query         *OpenTsdbResponseQuery     `json:"query"`
+}
+
+type OpenTsdbResponseQuery struct {
+	Index int `json:"index"`
+}
+
+// OpenTsdbFilter is a filter on the values of a tag, grouping the results by
+// the values of the tag when GroupBy is set.
+type OpenTsdbFilter struct {
+	Type    string `json:"type"`
+	Tagk    string `json:"tagk"`
+	Filter  string `json:"filter"`
+	GroupBy bool   `json:"groupBy"`
 }
@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
-	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
 	"github.com/grafana/grafana/pkg/tsdb/sqleng"
 )
 
@@ -88,36 +87,6 @@ func (m *postgresMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *
 		return fmt.Sprintf("'%s'", timeRange.From.UTC().Format(time.RFC3339Nano)), nil
 	case "__timeTo":
 		return fmt.Sprintf("'%s'", timeRange.To.UTC().Format(time.RFC3339Nano)), nil
-	case "__timeGroup":
-		if len(args) < 2 {
-			return "", fmt.Errorf("macro %v needs time column and interval and optional fill value", name)
-		}
-		interval, err := gtime.ParseInterval(strings.Trim(args[1], `'`))
-		if err != nil {
-			return "", fmt.Errorf("error parsing interval %v", args[1])
-		}
-		if len(args) == 3 {
-			err := sqleng.SetupFillmode(query, interval, args[2])
-			if err != nil {
-				return "", err
-			}
-		}
-
-		if m.timescaledb {
-			return fmt.Sprintf("time_bucket('%.3fs',%s)", interval.Seconds(), args[0]), nil
-		}
-
-		return fmt.Sprintf(
-			"floor(extract(epoch from %s)/%v)*%v", args[0],
-			interval.Seconds(),
-			interval.Seconds(),
-		), nil
-	case "__timeGroupAlias":
-		tg, err := m.evaluateMacro(timeRange, query, "__timeGroup", args)
-		if err == nil {
-			return tg + " AS \"time\"", nil
-		}
-		return "", err
 	case "__unixEpochFilter":
 		if len(args) == 0 {
 			return "", fmt.Errorf("missing time column argument for macro %v", name)
@@ -132,28 +101,43 @@ func (m *postgresMacroEngine) evaluateMacro(timeRange backend.TimeRange, query *
 		return fmt.Sprintf("%d", timeRange.From.UTC().UnixNano()), nil
 	case "__unixEpochNanoTo":
 		return fmt.Sprintf("%d", timeRange.To.UTC().UnixNano()), nil
-	case "__unixEpochGroup":
-		if len(args) < 2 {
-			return "", fmt.Errorf("macro %v needs time column and interval and optional fill value", name)
-		}
-		interval, err := gtime.ParseInterval(strings.Trim(args[1], `'`))
-		if err != nil {
-			return "", fmt.Errorf("error parsing interval %v", args[1])
-		}
-		if len(args) == 3 {
-			err := sqleng.SetupFillmode(query, interval, args[2])
-			if err != nil {
-				return "", err
-			}
-		}
-		return fmt.Sprintf("floor(%s/%v)*%v", args[0], interval.Seconds(), interval.Seconds()), nil
-	case "__unixEpochGroupAlias":
-		tg, err := m.evaluateMacro(timeRange, query, "__unixEpochGroup", args)
-		if err == nil {
-			return tg + " AS \"time\"", nil
-		}
-		return "", err
 	default:
-		return "", fmt.Errorf("unknown macro %q", name)
+		sql, ok, err := m.EvaluateMacro(m, timeRange, query, name, args)
+		if !ok {
+			return "", fmt.Errorf("unknown macro %q", name)
+		}
+		return sql, err
 	}
 }
+
+func (m *postgresMacroEngine) TimeGroup(column string, interval time.Duration) string {
+	if m.timescaledb {
+		return fmt.Sprintf("time_bucket('%.3fs',%s)", interval.Seconds(), column)
+	}
+
+	return fmt.Sprintf(
+		"floor(extract(epoch from %s)/%v)*%v", column,
+		interval.Seconds(),
+		interval.Seconds(),
+	)
+}
+
+func (m *postgresMacroEngine) Epoch(column string) string {
+	return fmt.Sprintf("extract(epoch from %s)", column)
+}
+
+func (m *postgresMacroEngine) EpochGroup(column string, size float64) string {
+	return fmt.Sprintf("floor(%s/%v)*%v", column, size, size)
+}
+
+func (m *postgresMacroEngine) TimeAlias(expression string) string {
+	return expression + " AS \"time\""
+}
+
+func (m *postgresMacroEngine) StringLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (m *postgresMacroEngine) LikeWildcards() []string {
+	return nil
+}
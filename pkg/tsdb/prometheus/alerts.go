@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/common/model"
+)
+
+// alertAnnotationsQueryType is the type of queries whose ALERTS or
+// ALERTS_FOR_STATE series are returned as annotation regions. The expression
+// defaults to ALERTS.
+const alertAnnotationsQueryType = "alertAnnotations"
+
+const alertsForStateMetric = "ALERTS_FOR_STATE"
+
+type alertRegion struct {
+	start, end time.Time
+	metric     model.Metric
+}
+
+// alertAnnotationFrame turns the series of alerts into annotation regions, one
+// for every run of samples at most a step apart. The region of an
+// ALERTS_FOR_STATE series starts when the alert became active, the value of its
+// samples, if that is earlier than its first sample. The title of an annotation
+// is the name of the alert, its text the legend of the series and its tags the
+// remaining labels as name=value.
+func alertAnnotationFrame(matrix model.Matrix, query *PrometheusQuery) *data.Frame {
+	step := query.Step
+	if query.DownsampleStep > 0 {
+		step = query.DownsampleStep
+	}
+
+	var regions []alertRegion
+	for _, series := range matrix {
+		if len(series.Values) == 0 {
+			continue
+		}
+		forState := series.Metric[model.MetricNameLabel] == alertsForStateMetric
+
+		region := alertRegion{metric: series.Metric}
+		for i, pair := range series.Values {
+			t := pair.Timestamp.Time().UTC()
+			if i > 0 && t.Sub(region.end) > step {
+				regions = append(regions, region)
+				region = alertRegion{metric: series.Metric}
+			}
+			if region.start.IsZero() {
+				region.start = t
+				if forState && pair.Value > 0 {
+					if activeAt := time.Unix(int64(pair.Value), 0).UTC(); activeAt.Before(t) {
+						region.start = activeAt
+					}
+				}
+			}
+			region.end = t
+		}
+		regions = append(regions, region)
+	}
+
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].start.Before(regions[j].start)
+	})
+
+	starts := make([]time.Time, 0, len(regions))
+	ends := make([]time.Time, 0, len(regions))
+	titles := make([]string, 0, len(regions))
+	texts := make([]string, 0, len(regions))
+	tags := make([]string, 0, len(regions))
+	for _, region := range regions {
+		starts = append(starts, region.start)
+		ends = append(ends, region.end)
+		titles = append(titles, string(region.metric["alertname"]))
+		texts = append(texts, formatLegend(region.metric, query))
+		tags = append(tags, alertTags(region.metric))
+	}
+
+	return newDataFrame("annotations", "annotations",
+		data.NewField("time", nil, starts),
+		data.NewField("timeEnd", nil, ends),
+		data.NewField("title", nil, titles),
+		data.NewField("text", nil, texts),
+		data.NewField("tags", nil, tags),
+	)
+}
+
+// alertTags returns the labels of an alert other than its metric and alert name
+// as comma separated name=value tags, sorted by name.
+func alertTags(metric model.Metric) string {
+	tags := make([]string, 0, len(metric))
+	for name, value := range metric {
+		if name == model.MetricNameLabel || name == "alertname" {
+			continue
+		}
+		tags = append(tags, string(name)+"="+string(value))
+	}
+	sort.Strings(tags)
+	return strings.Join(tags, ",")
+}
@@ -0,0 +1,73 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertAnnotationFrame(t *testing.T) {
+	query := &PrometheusQuery{
+		Expr:             "ALERTS",
+		Step:             time.Minute,
+		RefId:            "A",
+		RangeQuery:       true,
+		AlertAnnotations: true,
+	}
+	minute := func(m int64) p.Time { return p.TimeFromUnix(m * 60) }
+
+	value := map[TimeSeriesQueryType]interface{}{
+		RangeQueryType: p.Matrix{
+			{
+				Metric: p.Metric{"__name__": "ALERTS", "alertname": "HighLatency", "alertstate": "firing", "job": "api"},
+				Values: []p.SamplePair{
+					{Timestamp: minute(10), Value: 1}, {Timestamp: minute(11), Value: 1}, {Timestamp: minute(12), Value: 1},
+					{Timestamp: minute(20), Value: 1},
+				},
+			},
+			{
+				Metric: p.Metric{"__name__": "ALERTS_FOR_STATE", "alertname": "InstanceDown", "instance": "a"},
+				Values: []p.SamplePair{
+					{Timestamp: minute(5), Value: p.SampleValue(2 * 60)}, {Timestamp: minute(6), Value: p.SampleValue(2 * 60)},
+				},
+			},
+		},
+	}
+
+	frames, err := parseTimeSeriesResponse(value, query)
+	require.NoError(t, err)
+	require.Len(t, frames, 1)
+	frame := frames[0]
+	require.Equal(t, "annotations", frameResultType(frame))
+	require.Equal(t, 3, frame.Rows())
+
+	at := func(m int) time.Time { return time.Unix(int64(m*60), 0).UTC() }
+	rows := make([][]interface{}, frame.Rows())
+	for i := range rows {
+		rows[i] = frame.RowCopy(i)
+	}
+	require.Equal(t, [][]interface{}{
+		{at(2), at(6), "InstanceDown", `ALERTS_FOR_STATE{alertname="InstanceDown", instance="a"}`, "instance=a"},
+		{at(10), at(12), "HighLatency", `ALERTS{alertname="HighLatency", alertstate="firing", job="api"}`, "alertstate=firing,job=api"},
+		{at(20), at(20), "HighLatency", `ALERTS{alertname="HighLatency", alertstate="firing", job="api"}`, "alertstate=firing,job=api"},
+	}, rows)
+}
+
+func TestParseAlertAnnotationsQuery(t *testing.T) {
+	service := Service{intervalCalculator: intervalv2.NewCalculator()}
+	timeRange := backend.TimeRange{From: now, To: now.Add(time.Hour)}
+
+	req := queryContext(`{"expr": "", "instant": true, "exemplar": true, "refId": "A"}`, timeRange)
+	req.Queries[0].QueryType = alertAnnotationsQueryType
+	models, err := service.parseTimeSeriesQuery(req, &DatasourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, "ALERTS", models[0].Expr)
+	require.True(t, models[0].AlertAnnotations)
+	require.True(t, models[0].RangeQuery)
+	require.False(t, models[0].InstantQuery)
+	require.False(t, models[0].ExemplarQuery)
+}
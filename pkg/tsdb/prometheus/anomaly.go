@@ -0,0 +1,65 @@
+package prometheus
+
+import (
+	"math"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/common/model"
+)
+
+const defaultSeasonalOffset = 7 * 24 * time.Hour
+
+// addAnomalyScores adds an anomalyScore field to every range frame that has a
+// matching series in baseline, the result of the same query one seasonal offset
+// earlier. The score is the deviation from the baseline value at the same point
+// of the previous season, in standard deviations of the baseline series.
+// Points without a baseline value, or baselines without variance, score null.
+func addAnomalyScores(frames data.Frames, baseline model.Matrix, query *PrometheusQuery) {
+	baselines := make(map[string]*model.SampleStream, len(baseline))
+	for _, series := range baseline {
+		baselines[series.Metric.Fingerprint().String()] = series
+	}
+
+	for _, frame := range frames {
+		if frameResultType(frame) != "matrix" {
+			continue
+		}
+		custom, _ := frame.Meta.Custom.(map[string]interface{})
+		fingerprint, _ := custom["fingerprint"].(string)
+		series, ok := baselines[fingerprint]
+		if !ok {
+			continue
+		}
+
+		// Shift the baseline forward by one season so it lines up with the frame.
+		values := make(map[int64]float64, len(series.Values))
+		sampleValues := make([]float64, 0, len(series.Values))
+		for _, pair := range series.Values {
+			v := float64(pair.Value)
+			if math.IsNaN(v) {
+				continue
+			}
+			values[pair.Timestamp.Time().Add(query.SeasonalOffset).UnixMilli()] = v
+			sampleValues = append(sampleValues, v)
+		}
+		sd := deviation(sampleValues)
+
+		timeField, valueField := frame.Fields[0], frame.Fields[1]
+		scoreField := data.NewFieldFromFieldType(data.FieldTypeNullableFloat64, frame.Rows())
+		scoreField.Name = "anomalyScore"
+		for i := 0; i < frame.Rows(); i++ {
+			v, err := valueField.FloatAt(i)
+			if err != nil || math.IsNaN(v) {
+				continue
+			}
+			b, ok := values[timeField.At(i).(time.Time).UnixMilli()]
+			if !ok || sd == 0 || math.IsNaN(sd) {
+				continue
+			}
+			score := (v - b) / sd
+			scoreField.Set(i, &score)
+		}
+		frame.Fields = append(frame.Fields, scoreField)
+	}
+}
@@ -0,0 +1,76 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Consolidation is how the samples in a bucket of a downsampled series are
+// combined into one.
+type Consolidation string
+
+const (
+	ConsolidationAvg  Consolidation = "avg"
+	ConsolidationMax  Consolidation = "max"
+	ConsolidationMin  Consolidation = "min"
+	ConsolidationLast Consolidation = "last"
+)
+
+func parseConsolidation(consolidation string) (Consolidation, error) {
+	switch c := Consolidation(consolidation); c {
+	case "", ConsolidationAvg, ConsolidationMax, ConsolidationMin, ConsolidationLast:
+		return c, nil
+	}
+	return "", fmt.Errorf("invalid downsampling consolidation %q", consolidation)
+}
+
+// downsampleStep returns the length of the buckets range results are downsampled
+// to, so that they have at most about maxDataPoints points, as a multiple of step.
+// It returns zero when the results have few enough points.
+func downsampleStep(step time.Duration, timeRange time.Duration, maxDataPoints int64) time.Duration {
+	if step <= 0 || maxDataPoints <= 0 {
+		return 0
+	}
+	points := int64(timeRange/step) + 1
+	if points <= maxDataPoints {
+		return 0
+	}
+	factor := (points + maxDataPoints - 1) / maxDataPoints
+	return time.Duration(factor) * step
+}
+
+// downsampleSamples combines the samples in every bucket of bucketMs milliseconds,
+// starting at startMs, into a single sample at the start of the bucket. NaN
+// samples are ignored, a bucket with only NaN samples becomes NaN.
+func downsampleSamples(samples []model.SamplePair, startMs, bucketMs int64, consolidation Consolidation) []model.SamplePair {
+	result := make([]model.SamplePair, 0, len(samples)/2+1)
+	for i := 0; i < len(samples); {
+		bucket := startMs + (int64(samples[i].Timestamp)-startMs)/bucketMs*bucketMs
+		value, count := math.NaN(), 0
+		for ; i < len(samples) && int64(samples[i].Timestamp) < bucket+bucketMs; i++ {
+			v := float64(samples[i].Value)
+			if math.IsNaN(v) {
+				continue
+			}
+			switch {
+			case count == 0, consolidation == ConsolidationLast:
+				value = v
+			case consolidation == ConsolidationMax:
+				value = math.Max(value, v)
+			case consolidation == ConsolidationMin:
+				value = math.Min(value, v)
+			default:
+				value += v
+			}
+			count++
+		}
+		if consolidation == ConsolidationAvg && count > 0 {
+			value /= float64(count)
+		}
+		result = append(result, model.SamplePair{Timestamp: model.Time(bucket), Value: model.SampleValue(value)})
+	}
+	return result
+}
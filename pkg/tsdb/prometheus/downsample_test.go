@@ -0,0 +1,113 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsampleStep(t *testing.T) {
+	require.Zero(t, downsampleStep(time.Minute, time.Hour, 100))
+	require.Zero(t, downsampleStep(time.Minute, time.Hour, 0))
+	require.Equal(t, 2*time.Minute, downsampleStep(time.Minute, time.Hour, 60))
+	require.Equal(t, 7*time.Minute, downsampleStep(time.Minute, time.Hour, 10))
+}
+
+func TestDownsampleSamples(t *testing.T) {
+	nan := p.SampleValue(math.NaN())
+	samples := []p.SamplePair{
+		{Timestamp: 0, Value: 1},
+		{Timestamp: 1000, Value: 5},
+		{Timestamp: 2000, Value: 3},
+		{Timestamp: 3000, Value: nan},
+		{Timestamp: 6000, Value: nan},
+	}
+
+	tt := []struct {
+		consolidation Consolidation
+		expected      []p.SampleValue
+	}{
+		{consolidation: ConsolidationAvg, expected: []p.SampleValue{3, 3}},
+		{consolidation: ConsolidationMax, expected: []p.SampleValue{5, 3}},
+		{consolidation: ConsolidationMin, expected: []p.SampleValue{1, 3}},
+		{consolidation: ConsolidationLast, expected: []p.SampleValue{5, 3}},
+	}
+	for _, test := range tt {
+		t.Run(string(test.consolidation), func(t *testing.T) {
+			result := downsampleSamples(samples, 0, 2000, test.consolidation)
+			require.Len(t, result, 3)
+			require.Equal(t, p.Time(0), result[0].Timestamp)
+			require.Equal(t, test.expected[0], result[0].Value)
+			require.Equal(t, p.Time(2000), result[1].Timestamp)
+			require.Equal(t, test.expected[1], result[1].Value)
+			// A bucket with only NaN samples stays NaN.
+			require.Equal(t, p.Time(6000), result[2].Timestamp)
+			require.True(t, math.IsNaN(float64(result[2].Value)))
+		})
+	}
+}
+
+func TestDownsampledFrames(t *testing.T) {
+	value := map[TimeSeriesQueryType]interface{}{
+		RangeQueryType: p.Matrix{{
+			Metric: p.Metric{"__name__": "up"},
+			Values: []p.SamplePair{
+				{Timestamp: 1000, Value: 1},
+				{Timestamp: 2000, Value: 2},
+				{Timestamp: 3000, Value: 3},
+				{Timestamp: 4000, Value: 4},
+			},
+		}},
+	}
+	query := &PrometheusQuery{
+		Step:           time.Second,
+		Start:          time.Unix(1, 0).UTC(),
+		End:            time.Unix(5, 0).UTC(),
+		RangeQuery:     true,
+		Downsample:     ConsolidationMax,
+		DownsampleStep: 2 * time.Second,
+	}
+	res, err := parseTimeSeriesResponse(value, query)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	require.Equal(t, 3, res[0].Rows())
+	require.Equal(t, time.Unix(1, 0).UTC(), res[0].Fields[0].At(0))
+	require.Equal(t, 2.0, *res[0].Fields[1].At(0).(*float64))
+	require.Equal(t, 4.0, *res[0].Fields[1].At(1).(*float64))
+	require.Nil(t, res[0].Fields[1].At(2))
+	custom := res[0].Meta.Custom.(map[string]interface{})
+	require.Equal(t, "max", custom["consolidation"])
+	require.Equal(t, int64(2000), custom["stepMs"])
+}
+
+func TestParseDownsample(t *testing.T) {
+	service := Service{intervalCalculator: intervalv2.NewCalculator()}
+	parse := func(json string, maxDataPoints int64) ([]*PrometheusQuery, error) {
+		query := backend.DataQuery{
+			JSON:          []byte(json),
+			TimeRange:     backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)},
+			MaxDataPoints: maxDataPoints,
+		}
+		return service.parseTimeSeriesQuery(&backend.QueryDataRequest{Queries: []backend.DataQuery{query}}, &DatasourceInfo{})
+	}
+
+	queries, err := parse(`{"expr": "up", "interval": "15s", "downsample": "avg", "refId": "A"}`, 60)
+	require.NoError(t, err)
+	require.Equal(t, ConsolidationAvg, queries[0].Downsample)
+	// The step of 1m returns 61 points, one more than the max data points.
+	require.Equal(t, time.Minute, queries[0].Step)
+	require.Equal(t, 2*time.Minute, queries[0].DownsampleStep)
+
+	queries, err = parse(`{"expr": "up", "interval": "15s", "refId": "A"}`, 60)
+	require.NoError(t, err)
+	require.Zero(t, queries[0].DownsampleStep)
+
+	_, err = parse(`{"expr": "up", "downsample": "median", "refId": "A"}`, 60)
+	require.EqualError(t, err, `invalid downsampling consolidation "median"`)
+}
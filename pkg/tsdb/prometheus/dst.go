@@ -0,0 +1,135 @@
+package prometheus
+
+import (
+	"time"
+
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+)
+
+// offsetTransition is a change of the UTC offset of a time zone, e.g. the start
+// or end of DST, by delta seconds.
+type offsetTransition struct {
+	at    time.Time
+	delta int64
+}
+
+// utcOffset returns the UTC offset in seconds the step is aligned to at t: the
+// one of the time zone of the query at t, or its fixed UTC offset.
+func (query *PrometheusQuery) utcOffset(t time.Time) int64 {
+	if query.Location == nil || query.Location == time.UTC {
+		return query.UtcOffsetSec
+	}
+	_, offset := t.In(query.Location).Zone()
+	return int64(offset)
+}
+
+// alignOffset returns the offset in seconds the step is aligned to at t: the
+// UTC offset of the query, and for steps of whole weeks the shift to the week
+// start of the query.
+func (query *PrometheusQuery) alignOffset(t time.Time) int64 {
+	offset := query.utcOffset(t)
+	if query.WeekStart != nil && query.Step > 0 && query.Step%(7*24*time.Hour) == 0 {
+		opts := intervalv2.CalendarOptions{WeekStart: *query.WeekStart}
+		offset += int64(opts.WeekShift().Seconds())
+	}
+	return offset
+}
+
+// offsetTransitions returns the changes of the UTC offset of loc between start
+// and end, to the second. Time zones change their offset at most once a day.
+func offsetTransitions(loc *time.Location, start time.Time, end time.Time) []offsetTransition {
+	offsetAt := func(t time.Time) int64 {
+		_, offset := t.In(loc).Zone()
+		return int64(offset)
+	}
+
+	var transitions []offsetTransition
+	for from := start; from.Before(end); {
+		to := from.Add(24 * time.Hour)
+		if to.After(end) {
+			to = end
+		}
+		if before, after := offsetAt(from), offsetAt(to); before != after {
+			// Find the first second with the new offset.
+			lo, hi := from, to
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2).Truncate(time.Second)
+				if offsetAt(mid) == before {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			transitions = append(transitions, offsetTransition{at: hi, delta: after - before})
+		}
+		from = to
+	}
+	return transitions
+}
+
+// dstRanges splits r at the changes of the UTC offset of the time zone of the
+// query, so that every sub-range is aligned to the step with the offset it is in,
+// e.g. daily steps start at local midnight before and after a DST change. r is
+// returned as is when the offset does not change or only by multiples of the step.
+func dstRanges(query *PrometheusQuery, r apiv1.Range) []apiv1.Range {
+	if query.Location == nil || query.Location == time.UTC || query.ExactRange || r.Step <= 0 {
+		return []apiv1.Range{r}
+	}
+
+	var transitions []offsetTransition
+	for _, t := range offsetTransitions(query.Location, r.Start, r.End) {
+		if (time.Duration(t.delta)*time.Second)%r.Step != 0 {
+			transitions = append(transitions, t)
+		}
+	}
+	if len(transitions) == 0 {
+		return []apiv1.Range{r}
+	}
+
+	ranges := []apiv1.Range{}
+	start := r.Start
+	for i := 0; i <= len(transitions); i++ {
+		end := r.End
+		if i < len(transitions) {
+			// The last step before the transition, with the offset before it.
+			end = alignTimeRange(transitions[i].at.Add(-time.Second), r.Step, query.alignOffset(start))
+			if end.After(r.End) {
+				end = r.End
+			}
+		}
+		if !start.After(end) {
+			ranges = append(ranges, apiv1.Range{Start: start, End: end, Step: r.Step})
+		}
+		if i < len(transitions) {
+			// The first step after the transition, with the offset after it.
+			at := transitions[i].at
+			start = alignTimeRange(at, r.Step, query.alignOffset(at))
+			if start.Before(at) {
+				start = start.Add(r.Step)
+			}
+		}
+	}
+	return ranges
+}
+
+// dstGapTolerance returns how much closer than a step samples of a range
+// query split by dstRanges can be without a null being filled in between, in
+// milliseconds: the largest change of the UTC offset in the range.
+func dstGapTolerance(query *PrometheusQuery, r apiv1.Range) int64 {
+	if len(dstRanges(query, r)) <= 1 {
+		return 0
+	}
+	var tolerance int64
+	for _, t := range offsetTransitions(query.Location, r.Start, r.End) {
+		delta := t.delta
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta*1000 > tolerance {
+			tolerance = delta * 1000
+		}
+	}
+	return tolerance
+}
@@ -0,0 +1,169 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+)
+
+func TestDSTAlignment(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	local := func(day int, hour int) time.Time {
+		return time.Date(2022, 3, day, hour, 0, 0, 0, newYork)
+	}
+
+	query := &PrometheusQuery{
+		Expr:         "sum(increase(http_requests_total[1d]))",
+		Step:         24 * time.Hour,
+		Start:        local(10, 15),
+		End:          local(16, 15),
+		RangeQuery:   true,
+		Location:     newYork,
+		UtcOffsetSec: -5 * 60 * 60,
+	}
+
+	t.Run("it finds the offset changes", func(t *testing.T) {
+		transitions := offsetTransitions(newYork, query.Start, query.End)
+		require.Len(t, transitions, 1)
+		require.True(t, local(13, 3).Equal(transitions[0].at))
+		require.Equal(t, int64(60*60), transitions[0].delta)
+	})
+
+	t.Run("it aligns start and end with their own offset", func(t *testing.T) {
+		r := queryTimeRange(query)
+		require.True(t, local(10, 0).Equal(r.Start), r.Start.In(newYork).String())
+		require.True(t, local(16, 0).Equal(r.End), r.End.In(newYork).String())
+	})
+
+	t.Run("it aligns weekly steps to the week start", func(t *testing.T) {
+		weekly := *query
+		weekly.Step = 7 * 24 * time.Hour
+		r := queryTimeRange(&weekly)
+		require.True(t, local(10, 0).Equal(r.Start), r.Start.In(newYork).String())
+
+		monday := time.Monday
+		weekly.WeekStart = &monday
+		r = queryTimeRange(&weekly)
+		require.True(t, local(7, 0).Equal(r.Start), r.Start.In(newYork).String())
+		require.True(t, local(14, 0).Equal(r.End), r.End.In(newYork).String())
+
+		sunday := time.Sunday
+		weekly.WeekStart = &sunday
+		r = queryTimeRange(&weekly)
+		require.True(t, local(6, 0).Equal(r.Start), r.Start.In(newYork).String())
+	})
+
+	t.Run("it splits the range at DST changes", func(t *testing.T) {
+		ranges := dstRanges(query, queryTimeRange(query))
+		require.Len(t, ranges, 2)
+		require.True(t, local(10, 0).Equal(ranges[0].Start))
+		require.True(t, local(13, 0).Equal(ranges[0].End))
+		require.True(t, local(14, 0).Equal(ranges[1].Start))
+		require.True(t, local(16, 0).Equal(ranges[1].End))
+	})
+
+	t.Run("it does not split ranges when the offset change is a multiple of the step", func(t *testing.T) {
+		hourly := *query
+		hourly.Step = time.Hour
+		r := queryTimeRange(&hourly)
+		require.Equal(t, []apiv1.Range{r}, dstRanges(&hourly, r))
+		require.Zero(t, dstGapTolerance(&hourly, r))
+	})
+
+	t.Run("it does not split ranges with a fixed offset", func(t *testing.T) {
+		fixed := *query
+		fixed.Location = nil
+		r := queryTimeRange(&fixed)
+		require.Equal(t, []apiv1.Range{r}, dstRanges(&fixed, r))
+	})
+
+	t.Run("it does not fill nulls across the DST change", func(t *testing.T) {
+		var values []p.SamplePair
+		for _, day := range []int{10, 11, 12, 13, 14, 15, 16} {
+			values = append(values, p.SamplePair{Timestamp: p.TimeFromUnixNano(local(day, 0).UnixNano()), Value: 1})
+		}
+		frames := matrixToDataFrames(p.Matrix{{Metric: p.Metric{"job": "api"}, Values: values}}, query, nil)
+		require.Len(t, frames, 1)
+		require.Equal(t, 7, frames[0].Rows())
+		for i := 0; i < frames[0].Rows(); i++ {
+			require.NotNil(t, frames[0].Fields[1].At(i))
+			require.Equal(t, 0, frames[0].Fields[0].At(i).(time.Time).In(newYork).Hour())
+		}
+	})
+}
+
+func TestQueryRangeAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	client := &splitClient{}
+	query := &PrometheusQuery{
+		Expr:       "up",
+		Step:       24 * time.Hour,
+		Start:      time.Date(2022, 3, 10, 15, 0, 0, 0, newYork),
+		End:        time.Date(2022, 3, 16, 15, 0, 0, 0, newYork),
+		RangeQuery: true,
+		Location:   newYork,
+
+		StreamRangeResponse: true,
+	}
+	frames, _, _, err := queryRange(context.Background(), client, query, queryTimeRange(query))
+	require.NoError(t, err)
+	require.Len(t, client.ranges, 2)
+
+	require.Len(t, frames.(data.Frames), 1)
+	frame := frames.(data.Frames)[0]
+	require.Equal(t, 7, frame.Rows())
+	for i := 0; i < frame.Rows(); i++ {
+		require.Equal(t, 0, frame.Fields[0].At(i).(time.Time).In(newYork).Hour())
+	}
+}
+
+func TestQueryRangeAcrossDSTWithUTCOffset(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// The frontend sends the UTC offset of the time zone at the time of the
+	// request along with it, here the one after the DST change.
+	service := Service{intervalCalculator: intervalv2.NewCalculator()}
+	queries, err := service.parseTimeSeriesQuery(queryContext(`{
+		"expr": "up",
+		"interval": "1d",
+		"range": true,
+		"timezone": "America/New_York",
+		"utcOffsetSec": -14400,
+		"refId": "A"
+	}`, backend.TimeRange{
+		From: time.Date(2022, 3, 10, 15, 0, 0, 0, newYork),
+		To:   time.Date(2022, 3, 16, 15, 0, 0, 0, newYork),
+	}), &DatasourceInfo{})
+	require.NoError(t, err)
+	require.Len(t, queries, 1)
+	query := queries[0]
+	query.StreamRangeResponse = true
+
+	client := &splitClient{}
+	frames, _, _, err := queryRange(context.Background(), client, query, queryTimeRange(query))
+	require.NoError(t, err)
+	require.Len(t, client.ranges, 2)
+	for _, r := range client.ranges {
+		require.Equal(t, 0, r.Start.In(newYork).Hour())
+		require.Equal(t, 0, r.End.In(newYork).Hour())
+	}
+
+	require.Len(t, frames.(data.Frames), 1)
+	frame := frames.(data.Frames)[0]
+	require.Equal(t, 7, frame.Rows())
+	for i := 0; i < frame.Rows(); i++ {
+		require.Equal(t, 0, frame.Fields[0].At(i).(time.Time).In(newYork).Hour())
+	}
+}
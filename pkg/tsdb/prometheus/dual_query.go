@@ -0,0 +1,32 @@
+package prometheus
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// tagDualQueryFrames tells the range frames of a query that is run both as range
+// and instant query from its instant frames. Their names get a " (range)" or
+// " (instant)" suffix and their RefIDs a "-range" or "-instant" suffix, so that
+// transformations can target them separately, and the query type is recorded as
+// "queryType" in the custom metadata next to the "resultType". The display names
+// of the fields stay the same.
+func tagDualQueryFrames(frames data.Frames, query *PrometheusQuery) {
+	if !query.RangeQuery || !query.InstantQuery {
+		return
+	}
+
+	for _, frame := range frames {
+		var queryType string
+		switch frameResultType(frame) {
+		case "matrix":
+			queryType = "range"
+		case "vector", "scalar", "table":
+			queryType = "instant"
+		default:
+			continue
+		}
+		frame.Name += " (" + queryType + ")"
+		frame.RefID = query.RefId + "-" + queryType
+		setFrameCustomMeta(frame, "queryType", queryType)
+	}
+}
@@ -0,0 +1,72 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagDualQueryFrames(t *testing.T) {
+	value := func() map[TimeSeriesQueryType]interface{} {
+		return map[TimeSeriesQueryType]interface{}{
+			RangeQueryType: p.Matrix{{
+				Metric: p.Metric{"__name__": "up"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}},
+			}},
+			InstantQueryType: p.Vector{{
+				Metric:    p.Metric{"__name__": "up"},
+				Value:     1,
+				Timestamp: 1000,
+			}},
+		}
+	}
+	query := &PrometheusQuery{
+		Expr:         "up",
+		Step:         1 * time.Second,
+		Start:        time.Unix(1, 0).UTC(),
+		End:          time.Unix(1, 0).UTC(),
+		RefId:        "A",
+		RangeQuery:   true,
+		InstantQuery: true,
+	}
+
+	t.Run("it tells range and instant frames apart", func(t *testing.T) {
+		res, err := parseTimeSeriesResponse(value(), query)
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+
+		byRefID := map[string]*data.Frame{}
+		for _, frame := range res {
+			byRefID[frame.RefID] = frame
+		}
+		require.Contains(t, byRefID, "A-range")
+		require.Contains(t, byRefID, "A-instant")
+
+		rangeFrame, instantFrame := byRefID["A-range"], byRefID["A-instant"]
+		require.Equal(t, "up (range)", rangeFrame.Name)
+		require.Equal(t, "matrix", frameResultType(rangeFrame))
+		require.Equal(t, "range", rangeFrame.Meta.Custom.(map[string]interface{})["queryType"])
+		require.Equal(t, "up", rangeFrame.Fields[1].Config.DisplayNameFromDS)
+
+		require.Equal(t, "up (instant)", instantFrame.Name)
+		require.Equal(t, "vector", frameResultType(instantFrame))
+		require.Equal(t, "instant", instantFrame.Meta.Custom.(map[string]interface{})["queryType"])
+	})
+
+	t.Run("it leaves frames of a single query type alone", func(t *testing.T) {
+		single := *query
+		single.InstantQuery = false
+		v := value()
+		delete(v, InstantQueryType)
+
+		res, err := parseTimeSeriesResponse(v, &single)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, "up", res[0].Name)
+		require.Empty(t, res[0].RefID)
+		require.NotContains(t, res[0].Meta.Custom, "queryType")
+	})
+}
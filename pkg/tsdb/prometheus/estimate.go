@@ -0,0 +1,235 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	// defaultEvaluationInterval is the step of subqueries without one, like the
+	// default evaluation interval of Prometheus.
+	defaultEvaluationInterval = time.Minute
+	// lowCostSamples and highCostSamples are the estimated sample counts from
+	// which a query is considered of medium and high cost. Prometheus refuses
+	// queries loading more than 50 million samples at once by default.
+	lowCostSamples  = 1_000_000
+	highCostSamples = 50_000_000
+)
+
+// selectorEstimate is the estimated cost of a vector selector of an expression.
+type selectorEstimate struct {
+	Selector string `json:"selector"`
+	Series   int    `json:"series"`
+	Samples  int64  `json:"samples"`
+}
+
+type costEstimate struct {
+	Selectors []selectorEstimate `json:"selectors"`
+	Series    int                `json:"series"`
+	Samples   int64              `json:"samples"`
+	// Cost is low, medium or high, see lowCostSamples and highCostSamples.
+	Cost string `json:"cost"`
+}
+
+// selectorLoad is how many samples a selector loads per series: Evaluations
+// times SamplesPerEvaluation for each point of the query.
+type selectorLoad struct {
+	selector             string
+	evaluations          int64
+	samplesPerEvaluation int64
+}
+
+// handleEstimate estimates how many samples the query parameter loads between
+// start and end, with the step parameter for range queries, from the number of
+// series matching each of its selectors at end. Samples are assumed to be
+// scraped every scrapeInterval, the scrape interval of the data source by
+// default.
+func (s *Service) handleEstimate(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	expr, err := parser.ParseExpr(q.Get("query"))
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, fmt.Errorf("invalid query: %w", err))
+		return
+	}
+	start, end, err := parseResourceRange(q)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+	step, err := parseEstimateDuration(q, "step", 0)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+	scrapeInterval, err := parseEstimateDuration(q, "scrapeInterval", s.estimateScrapeInterval(req))
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	points := int64(1)
+	if step > 0 {
+		points = int64(end.Sub(start)/step) + 1
+	}
+	loads := selectorLoads(expr, step, scrapeInterval)
+
+	cacheParams := url.Values{
+		"query":          {expr.String()},
+		"start":          {strconv.FormatInt(start.Unix(), 10)},
+		"end":            {strconv.FormatInt(end.Unix(), 10)},
+		"step":           {step.String()},
+		"scrapeInterval": {scrapeInterval.String()},
+	}
+	s.serveCachedResource(rw, req, cacheParams, metadataTTL, func(client apiv1.API) (interface{}, error) {
+		estimate := costEstimate{Selectors: []selectorEstimate{}}
+		series := map[string]int{}
+		for _, load := range loads {
+			n, ok := series[load.selector]
+			if !ok {
+				var err error
+				n, err = countSelectorSeries(req.Context(), client, load.selector, end)
+				if err != nil {
+					return nil, err
+				}
+				series[load.selector] = n
+				estimate.Series += n
+			}
+			samples := saturatingMul(saturatingMul(int64(n), points), saturatingMul(load.evaluations, load.samplesPerEvaluation))
+			estimate.Selectors = append(estimate.Selectors, selectorEstimate{Selector: load.selector, Series: n, Samples: samples})
+			estimate.Samples = saturatingAdd(estimate.Samples, samples)
+		}
+		estimate.Cost = costLevel(estimate.Samples)
+		return estimate, nil
+	})
+}
+
+// countSelectorSeries returns the number of series matching selector at t. Prometheus
+// counts them itself, the series API would return every label set.
+func countSelectorSeries(ctx context.Context, client apiv1.API, selector string, t time.Time) (int, error) {
+	result, _, err := client.Query(ctx, "count("+selector+")", t)
+	if err != nil {
+		return 0, err
+	}
+	vector, ok := result.(model.Vector)
+	if !ok {
+		return 0, fmt.Errorf("unexpected %s result counting series", result.Type())
+	}
+	if len(vector) == 0 {
+		return 0, nil
+	}
+	return int(vector[0].Value), nil
+}
+
+func (s *Service) estimateScrapeInterval(req *http.Request) time.Duration {
+	scrapeInterval := 15 * time.Second
+	pluginCtx := httpadapter.PluginConfigFromContext(req.Context())
+	if pluginCtx.DataSourceInstanceSettings == nil {
+		return scrapeInterval
+	}
+	dsInfo, err := s.getDSInfo(pluginCtx)
+	if err != nil || dsInfo.TimeInterval == "" {
+		return scrapeInterval
+	}
+	if parsed, err := intervalv2.ParseIntervalStringToTimeDuration(dsInfo.TimeInterval); err == nil && parsed > 0 {
+		scrapeInterval = parsed
+	}
+	return scrapeInterval
+}
+
+func parseEstimateDuration(q url.Values, name string, defaultValue time.Duration) (time.Duration, error) {
+	v := q.Get(name)
+	if v == "" {
+		return defaultValue, nil
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := model.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return time.Duration(d), nil
+}
+
+// selectorLoads returns the load of each vector selector of expr, in order of
+// appearance. Selectors of range vectors load a range of samples per evaluation,
+// and selectors within subqueries are evaluated once per subquery step.
+func selectorLoads(expr parser.Expr, step time.Duration, scrapeInterval time.Duration) []selectorLoad {
+	var loads []selectorLoad
+	parser.Inspect(expr, func(n parser.Node, path []parser.Node) error {
+		vs, ok := n.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+
+		load := selectorLoad{selector: vs.String(), evaluations: 1, samplesPerEvaluation: 1}
+		if len(path) > 0 {
+			if ms, ok := path[len(path)-1].(*parser.MatrixSelector); ok {
+				load.samplesPerEvaluation = atLeastOne(int64(ms.Range / scrapeInterval))
+			}
+		}
+		for _, p := range path {
+			sq, ok := p.(*parser.SubqueryExpr)
+			if !ok {
+				continue
+			}
+			subqueryStep := sq.Step
+			if subqueryStep == 0 {
+				subqueryStep = step
+			}
+			if subqueryStep == 0 {
+				subqueryStep = defaultEvaluationInterval
+			}
+			load.evaluations *= atLeastOne(int64(sq.Range / subqueryStep))
+		}
+		loads = append(loads, load)
+		return nil
+	})
+	return loads
+}
+
+func atLeastOne(n int64) int64 {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// saturatingMul returns a * b for non-negative a and b, or math.MaxInt64 when
+// it overflows.
+func saturatingMul(a, b int64) int64 {
+	if a != 0 && b > math.MaxInt64/a {
+		return math.MaxInt64
+	}
+	return a * b
+}
+
+// saturatingAdd returns a + b for non-negative a and b, or math.MaxInt64 when
+// it overflows.
+func saturatingAdd(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+func costLevel(samples int64) string {
+	switch {
+	case samples >= highCostSamples:
+		return "high"
+	case samples >= lowCostSamples:
+		return "medium"
+	}
+	return "low"
+}
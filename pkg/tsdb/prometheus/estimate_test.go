@@ -0,0 +1,105 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectorLoads(t *testing.T) {
+	expr, err := parser.ParseExpr(`sum(rate(http_requests_total[5m])) / up + max_over_time(rate(errors_total[1m])[1h:5m])`)
+	require.NoError(t, err)
+
+	loads := selectorLoads(expr, time.Minute, 15*time.Second)
+	require.Equal(t, []selectorLoad{
+		{selector: "http_requests_total", evaluations: 1, samplesPerEvaluation: 20},
+		{selector: "up", evaluations: 1, samplesPerEvaluation: 1},
+		{selector: "errors_total", evaluations: 12, samplesPerEvaluation: 4},
+	}, loads)
+}
+
+func TestEstimateResource(t *testing.T) {
+	client := &seriesClient{series: map[string]int{"http_requests_total": 10, "up": 3}}
+	s := newResourceTestService(client, 0)
+
+	query := url.QueryEscape(`rate(http_requests_total[1m]) / on() group_left up + up`)
+	res := callResource(t, s, "estimate?query="+query+"&start=0&end=3600&step=60&scrapeInterval=15s")
+	require.Equal(t, http.StatusOK, res.Status)
+
+	var body struct {
+		Data costEstimate `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(res.Body, &body))
+	require.Equal(t, costEstimate{
+		Selectors: []selectorEstimate{
+			{Selector: "http_requests_total", Series: 10, Samples: 10 * 61 * 4},
+			{Selector: "up", Series: 3, Samples: 3 * 61},
+			{Selector: "up", Series: 3, Samples: 3 * 61},
+		},
+		Series:  13,
+		Samples: 10*61*4 + 2*3*61,
+		Cost:    "low",
+	}, body.Data)
+	require.Equal(t, []string{"count(http_requests_total)", "count(up)"}, client.calls, "series are counted once per selector")
+
+	t.Run("it rejects invalid queries", func(t *testing.T) {
+		res := callResource(t, s, "estimate?query="+url.QueryEscape("rate(up"))
+		require.Equal(t, http.StatusBadRequest, res.Status)
+		require.Contains(t, string(res.Body), "invalid query")
+	})
+
+	t.Run("it rejects invalid steps", func(t *testing.T) {
+		res := callResource(t, s, "estimate?query=up&step=often")
+		require.Equal(t, http.StatusBadRequest, res.Status)
+		require.JSONEq(t, `{"status":"error","error":"invalid step \"often\""}`, string(res.Body))
+	})
+}
+
+func TestEstimateOverflow(t *testing.T) {
+	client := &seriesClient{series: map[string]int{"up": 1_000_000}}
+	s := newResourceTestService(client, 0)
+
+	query := url.QueryEscape(`max_over_time(up[1000w:1ms])`)
+	res := callResource(t, s, "estimate?query="+query+"&start=0&end=31536000&step=0.001&scrapeInterval=0.001")
+	require.Equal(t, http.StatusOK, res.Status)
+
+	var body struct {
+		Data costEstimate `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(res.Body, &body))
+	require.Equal(t, int64(math.MaxInt64), body.Data.Samples)
+	require.Equal(t, "high", body.Data.Cost)
+}
+
+func TestCostLevel(t *testing.T) {
+	require.Equal(t, "low", costLevel(999_999))
+	require.Equal(t, "medium", costLevel(1_000_000))
+	require.Equal(t, "high", costLevel(50_000_000))
+}
+
+type seriesClient struct {
+	apiv1.API
+
+	series map[string]int
+	calls  []string
+}
+
+func (c *seriesClient) Query(ctx context.Context, query string, ts time.Time) (model.Value, apiv1.Warnings, error) {
+	c.calls = append(c.calls, query)
+	selector := strings.TrimSuffix(strings.TrimPrefix(query, "count("), ")")
+	n, ok := c.series[selector]
+	if !ok {
+		return model.Vector{}, nil, nil
+	}
+	return model.Vector{{Value: model.SampleValue(n), Timestamp: model.TimeFromUnixNano(ts.UnixNano())}}, nil, nil
+}
@@ -0,0 +1,80 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+)
+
+const exemplarTraceIDPlaceholder = "${__value.raw}"
+
+// applyExemplarTraceLinks adds data links to the fields of an exemplar frame that
+// hold the trace IDs of the exemplarTraceIdDestinations of the data source, so
+// that links are part of the result wherever it is rendered.
+func applyExemplarTraceLinks(frame *data.Frame, query *PrometheusQuery) {
+	for _, destination := range query.ExemplarTraceIdDestinations {
+		for _, field := range frame.Fields {
+			if field.Name != destination.Name {
+				continue
+			}
+			if field.Config == nil {
+				field.Config = &data.FieldConfig{}
+			}
+			field.Config.Links = append(field.Config.Links, exemplarTraceLinks(destination, query)...)
+		}
+	}
+}
+
+// exemplarTraceLinks returns the links of a destination: one opening the trace in
+// Explore of the trace data source, and one to the URL of the destination.
+func exemplarTraceLinks(destination promclient.ExemplarTraceIdDestination, query *PrometheusQuery) []data.DataLink {
+	var links []data.DataLink
+
+	if destination.DatasourceUid != "" {
+		title := destination.URLDisplayLabel
+		if title == "" {
+			title = "Query with " + destination.DatasourceUid
+		}
+		links = append(links, data.DataLink{
+			Title: title,
+			URL:   exploreTraceURL(destination.DatasourceUid, query),
+		})
+	}
+
+	if destination.URL != "" {
+		title := destination.URLDisplayLabel
+		if title == "" {
+			title = "Go to " + destination.URL
+		}
+		links = append(links, data.DataLink{
+			Title:       title,
+			URL:         destination.URL,
+			TargetBlank: true,
+		})
+	}
+
+	return links
+}
+
+// exploreTraceURL returns the Explore URL that queries the trace ID of the link
+// value in the data source with the given UID, over the range of the query.
+func exploreTraceURL(datasourceUID string, query *PrometheusQuery) string {
+	state, _ := json.Marshal(map[string]interface{}{
+		"datasource": datasourceUID,
+		"queries": []map[string]string{
+			{"refId": "A", "query": exemplarTraceIDPlaceholder, "queryType": "traceId"},
+		},
+		"range": map[string]string{
+			"from": strconv.FormatInt(query.Start.UnixMilli(), 10),
+			"to":   strconv.FormatInt(query.End.UnixMilli(), 10),
+		},
+	})
+
+	// The placeholder stays unescaped, so that it is interpolated with the value.
+	left := strings.ReplaceAll(url.QueryEscape(string(state)), url.QueryEscape(exemplarTraceIDPlaceholder), exemplarTraceIDPlaceholder)
+	return "/explore?left=" + left
+}
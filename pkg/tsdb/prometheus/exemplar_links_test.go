@@ -0,0 +1,72 @@
+package prometheus
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExemplarTraceLinks(t *testing.T) {
+	exemplars := []apiv1.ExemplarQueryResult{
+		{
+			SeriesLabels: model.LabelSet{"__name__": "tns_request_duration_seconds_bucket"},
+			Exemplars: []apiv1.Exemplar{
+				{Labels: model.LabelSet{"traceID": "abc"}, Value: 0.5, Timestamp: 1000},
+			},
+		},
+	}
+	query := &PrometheusQuery{
+		Step:  time.Second,
+		Start: time.Unix(0, 0),
+		End:   time.Unix(3600, 0),
+	}
+	traceIDField := func(frames data.Frames) *data.Field {
+		t.Helper()
+		require.Len(t, frames, 1)
+		field, _ := frames[0].FieldByName("traceID")
+		require.NotNil(t, field)
+		return field
+	}
+
+	t.Run("it adds no links without destinations", func(t *testing.T) {
+		field := traceIDField(exemplarToDataFrames(exemplars, query, nil))
+		require.Nil(t, field.Config)
+	})
+
+	t.Run("it links trace IDs to the trace data source and the URL of the destination", func(t *testing.T) {
+		query := *query
+		query.ExemplarTraceIdDestinations = []promclient.ExemplarTraceIdDestination{
+			{Name: "traceID", DatasourceUid: "tempo-uid"},
+			{Name: "traceID", URL: "https://traces.example.com/${__value.raw}", URLDisplayLabel: "Open trace"},
+			{Name: "spanID", DatasourceUid: "tempo-uid"},
+		}
+
+		field := traceIDField(exemplarToDataFrames(exemplars, &query, nil))
+		require.Len(t, field.Config.Links, 2)
+
+		internal := field.Config.Links[0]
+		require.Equal(t, "Query with tempo-uid", internal.Title)
+		require.True(t, strings.HasPrefix(internal.URL, "/explore?left="))
+		require.Contains(t, internal.URL, "${__value.raw}")
+		state, err := url.QueryUnescape(strings.TrimPrefix(internal.URL, "/explore?left="))
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"datasource": "tempo-uid",
+			"queries": [{"refId": "A", "query": "${__value.raw}", "queryType": "traceId"}],
+			"range": {"from": "0", "to": "3600000"}
+		}`, state)
+
+		require.Equal(t, data.DataLink{
+			Title:       "Open trace",
+			URL:         "https://traces.example.com/${__value.raw}",
+			TargetBlank: true,
+		}, field.Config.Links[1])
+	})
+}
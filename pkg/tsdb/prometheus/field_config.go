@@ -0,0 +1,88 @@
+package prometheus
+
+import (
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/common/model"
+)
+
+// metricNameUnits maps the base unit suffixes of the Prometheus metric naming
+// conventions to Grafana units.
+var metricNameUnits = []struct {
+	suffix string
+	unit   string
+}{
+	{"_milliseconds", "ms"},
+	{"_seconds", "s"},
+	{"_bytes", "bytes"},
+	{"_ratio", "percentunit"},
+	{"_celsius", "celsius"},
+	{"_volts", "volt"},
+	{"_amperes", "amp"},
+	{"_joules", "joule"},
+	{"_grams", "massg"},
+	{"_meters", "lengthm"},
+}
+
+// unitFromMetricName returns the Grafana unit for the base unit suffix of a
+// metric name, or an empty string when it has none.
+func unitFromMetricName(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	name = strings.TrimSuffix(name, "_sum")
+	for _, u := range metricNameUnits {
+		if strings.HasSuffix(name, u.suffix) {
+			return u.unit
+		}
+	}
+	return ""
+}
+
+// applyUniformFieldConfig gives the value fields of all series sharing a metric
+// name the same unit and decimals. The config is derived once per metric name:
+// from the query when it sets a unit, from the metric name otherwise.
+func applyUniformFieldConfig(frames data.Frames, query *PrometheusQuery) {
+	configs := map[string]*data.FieldConfig{}
+	for _, frame := range frames {
+		if typ := frameResultType(frame); typ != "matrix" && typ != "vector" {
+			continue
+		}
+		valueField := frame.Fields[1]
+		name, ok := valueField.Labels[model.MetricNameLabel]
+		if !ok {
+			continue
+		}
+
+		cfg, ok := configs[name]
+		if !ok {
+			cfg = &data.FieldConfig{Unit: query.Unit, Decimals: query.Decimals}
+			if cfg.Unit == "" {
+				cfg.Unit = unitFromMetricName(name)
+			}
+			configs[name] = cfg
+		}
+
+		if valueField.Config == nil {
+			valueField.Config = &data.FieldConfig{}
+		}
+		valueField.Config.Unit = cfg.Unit
+		valueField.Config.Decimals = cfg.Decimals
+	}
+}
+
+// exemplarUnit returns the unit for the values of exemplars of the given series.
+// Exemplar values are observations, so histogram suffixes are ignored. It returns
+// an empty string when the series don't agree on a unit.
+func exemplarUnit(seriesNames map[string]bool) string {
+	unit := ""
+	for name := range seriesNames {
+		name = strings.TrimSuffix(name, "_bucket")
+		name = strings.TrimSuffix(name, "_count")
+		u := unitFromMetricName(name)
+		if u == "" || (unit != "" && u != unit) {
+			return ""
+		}
+		unit = u
+	}
+	return unit
+}
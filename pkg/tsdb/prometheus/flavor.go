@@ -0,0 +1,112 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// flavorRetryInterval is how long a failed flavor detection is not repeated.
+const flavorRetryInterval = time.Minute
+
+// nativeHistogramFunctions matches the functions that only work on native
+// histograms. The parser does not know them yet, so they are matched as text.
+var nativeHistogramFunctions = regexp.MustCompile(`\bhistogram_(count|sum|fraction|avg|stddev|stdvar)\s*\(`)
+
+// FlavorDetector detects the flavor of the server of a data source from its build
+// info on the first query, and remembers it for as long as the settings of the
+// data source don't change.
+type FlavorDetector struct {
+	mu          sync.Mutex
+	detected    bool
+	lastAttempt time.Time
+	info        *promclient.BuildInfo
+}
+
+// capabilities returns the features supported by the server, nil when they could
+// not be detected, in which case nothing is gated. The build info is requested
+// without holding the lock, and queries running meanwhile are not gated.
+func (d *FlavorDetector) capabilities(ctx context.Context, client apiv1.API) *promclient.Capabilities {
+	d.mu.Lock()
+	if d.detected {
+		capabilities := d.info.Capabilities()
+		d.mu.Unlock()
+		return &capabilities
+	}
+	if time.Since(d.lastAttempt) < flavorRetryInterval {
+		d.mu.Unlock()
+		return nil
+	}
+	d.lastAttempt = time.Now()
+	d.mu.Unlock()
+
+	info, err := buildInfo(ctx, client)
+	if err != nil {
+		plog.Warn("Failed to detect the Prometheus flavor", "err", err)
+		return nil
+	}
+	flavor := info.Flavor()
+	plog.Debug("Detected the Prometheus flavor", "flavor", flavor)
+	if flavor == promclient.FlavorVictoriaMetrics {
+		plog.Info("Disabling exemplars, the server reports no build details and is taken for VictoriaMetrics", "version", info.Version)
+	}
+
+	d.mu.Lock()
+	d.info = info
+	d.detected = true
+	d.mu.Unlock()
+
+	capabilities := info.Capabilities()
+	return &capabilities
+}
+
+// checkCapabilities returns an error when the expression of query uses features
+// the server does not support, and turns off the exemplar query when exemplars
+// are not supported.
+func checkCapabilities(query *PrometheusQuery) error {
+	capabilities := query.Capabilities
+	if capabilities == nil {
+		return nil
+	}
+	if query.ExemplarQuery && !capabilities.Exemplars {
+		plog.Debug("Skipping the exemplar query, exemplars are not supported", "server", capabilities.Server)
+		query.ExemplarQuery = false
+	}
+	if !capabilities.NativeHistograms && nativeHistogramFunctions.MatchString(query.Expr) {
+		return fmt.Errorf("native histograms are not supported by %s", capabilities.Server)
+	}
+	if !capabilities.AtModifier && usesAtModifier(query.Expr) {
+		return fmt.Errorf("the @ modifier is not supported by %s", capabilities.Server)
+	}
+	return nil
+}
+
+// usesAtModifier returns whether expr evaluates any selector or subquery at a
+// fixed time with the @ modifier. It returns false when expr cannot be parsed.
+func usesAtModifier(expr string) bool {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return false
+	}
+
+	found := false
+	parser.Inspect(node, func(n parser.Node, _ []parser.Node) error {
+		switch n := n.(type) {
+		case *parser.VectorSelector:
+			found = n.Timestamp != nil || n.StartOrEnd != 0
+		case *parser.SubqueryExpr:
+			found = n.Timestamp != nil || n.StartOrEnd != 0
+		}
+		if found {
+			return errStopInspect
+		}
+		return nil
+	})
+	return found
+}
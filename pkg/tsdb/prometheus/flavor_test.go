@@ -0,0 +1,118 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCapabilities(t *testing.T) {
+	thanos := &promclient.Capabilities{Server: "thanos 0.19.0"}
+
+	t.Run("it skips the exemplar query when exemplars are not supported", func(t *testing.T) {
+		query := &PrometheusQuery{Expr: "up", RangeQuery: true, ExemplarQuery: true, Capabilities: thanos}
+		require.NoError(t, checkCapabilities(query))
+		require.False(t, query.ExemplarQuery)
+		require.True(t, query.RangeQuery)
+	})
+
+	t.Run("it refuses native histogram functions when they are not supported", func(t *testing.T) {
+		query := &PrometheusQuery{Expr: "histogram_count(rate(request_duration_seconds[5m]))", Capabilities: thanos}
+		require.EqualError(t, checkCapabilities(query), "native histograms are not supported by thanos 0.19.0")
+
+		query = &PrometheusQuery{Expr: "histogram_quantile(0.9, rate(request_duration_seconds_bucket[5m]))", Capabilities: thanos}
+		require.NoError(t, checkCapabilities(query))
+	})
+
+	t.Run("it refuses the @ modifier when it is not supported", func(t *testing.T) {
+		for _, expr := range []string{"up @ 1609746000", "rate(http_requests_total[5m] @ end())", "max_over_time(up[1h:5m] @ start())"} {
+			query := &PrometheusQuery{Expr: expr, Capabilities: thanos}
+			require.EqualError(t, checkCapabilities(query), "the @ modifier is not supported by thanos 0.19.0", expr)
+		}
+
+		query := &PrometheusQuery{Expr: `sum by (job) (rate(http_requests_total{job="@"}[5m]))`, Capabilities: thanos}
+		require.NoError(t, checkCapabilities(query))
+	})
+
+	t.Run("it gates nothing when the capabilities are not known", func(t *testing.T) {
+		query := &PrometheusQuery{Expr: "up @ 1609746000", ExemplarQuery: true}
+		require.NoError(t, checkCapabilities(query))
+		require.True(t, query.ExemplarQuery)
+	})
+}
+
+func TestFlavorDetector(t *testing.T) {
+	t.Run("it detects the flavor once", func(t *testing.T) {
+		client := &buildInfoClient{info: apiv1.BuildinfoResult{Version: "2.25.0", Revision: "a"}}
+		detector := &FlavorDetector{}
+
+		for i := 0; i < 2; i++ {
+			capabilities := detector.capabilities(context.Background(), client)
+			require.NotNil(t, capabilities)
+			require.True(t, capabilities.AtModifier)
+			require.False(t, capabilities.Exemplars)
+		}
+		require.Equal(t, 1, client.calls)
+	})
+
+	t.Run("it retries a failed detection after a while", func(t *testing.T) {
+		client := &buildInfoClient{err: errors.New("connection refused")}
+		detector := &FlavorDetector{}
+
+		require.Nil(t, detector.capabilities(context.Background(), client))
+		require.Nil(t, detector.capabilities(context.Background(), client))
+		require.Equal(t, 1, client.calls)
+
+		detector.lastAttempt = time.Now().Add(-flavorRetryInterval)
+		client.err = nil
+		require.NotNil(t, detector.capabilities(context.Background(), client))
+		require.Equal(t, 2, client.calls)
+	})
+
+	t.Run("it does not wait for a detection in progress", func(t *testing.T) {
+		client := &buildInfoClient{
+			info:    apiv1.BuildinfoResult{Version: "2.25.0", Revision: "a"},
+			started: make(chan struct{}),
+			release: make(chan struct{}),
+		}
+		detector := &FlavorDetector{}
+
+		detected := make(chan *promclient.Capabilities)
+		go func() {
+			detected <- detector.capabilities(context.Background(), client)
+		}()
+		<-client.started
+		require.Nil(t, detector.capabilities(context.Background(), client))
+
+		close(client.release)
+		require.NotNil(t, <-detected)
+		require.NotNil(t, detector.capabilities(context.Background(), client))
+		require.Equal(t, 1, client.calls)
+	})
+}
+
+type buildInfoClient struct {
+	apiv1.API
+
+	info  apiv1.BuildinfoResult
+	err   error
+	calls int
+	// started is closed when the build info is requested, which then waits for
+	// release to be closed, when they are set.
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *buildInfoClient) Buildinfo(ctx context.Context) (apiv1.BuildinfoResult, error) {
+	c.calls++
+	if c.started != nil {
+		close(c.started)
+		<-c.release
+	}
+	return c.info, c.err
+}
@@ -14,13 +14,18 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana-plugin-sdk-go/experimental"
 	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
 	"github.com/prometheus/client_golang/api"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func TestMatrixResponses(t *testing.T) {
+func TestStoredResponses(t *testing.T) {
 	tt := []struct {
 		name     string
 		filepath string
@@ -29,6 +34,8 @@ func TestMatrixResponses(t *testing.T) {
 		{name: "parse a simple matrix response with value missing steps", filepath: "range_missing"},
 		{name: "parse a response with Infinity", filepath: "range_infinity"},
 		{name: "parse a response with NaN", filepath: "range_nan"},
+		{name: "parse a scalar response", filepath: "instant_scalar"},
+		{name: "parse an exemplar response", filepath: "exemplar_simple"},
 	}
 
 	for _, test := range tt {
@@ -40,6 +47,10 @@ func TestMatrixResponses(t *testing.T) {
 			query, err := loadStoredPrometheusQuery(queryFileName)
 			require.NoError(t, err)
 
+			if *recordURL != "" {
+				require.NoError(t, recordPrometheusResponse(*recordURL, query, responseFileName))
+			}
+
 			responseBytes, err := os.ReadFile(responseFileName)
 			require.NoError(t, err)
 
@@ -51,6 +62,18 @@ func TestMatrixResponses(t *testing.T) {
 			require.True(t, found)
 
 			require.NoError(t, experimental.CheckGoldenDataResponse(goldenFileName, &dr, true))
+
+			// Only range responses are streamed.
+			if !query.RangeQuery {
+				return
+			}
+			streamed, err := runStreamedQuery(responseBytes, query)
+			require.NoError(t, err)
+
+			dr, found = streamed.Responses["A"]
+			require.True(t, found)
+
+			require.NoError(t, experimental.CheckGoldenDataResponse(goldenFileName, &dr, false))
 		})
 	}
 }
@@ -89,12 +112,14 @@ func makeMockedApi(responseBytes []byte) (apiv1.API, error) {
 // struct here, because it has `time.time` and `time.duration` fields that
 // cannot be unmarshalled from JSON automatically.
 type storedPrometheusQuery struct {
-	RefId      string
-	RangeQuery bool
-	Start      int64
-	End        int64
-	Step       int64
-	Expr       string
+	RefId         string
+	RangeQuery    bool
+	InstantQuery  bool
+	ExemplarQuery bool
+	Start         int64
+	End           int64
+	Step          int64
+	Expr          string
 }
 
 func loadStoredPrometheusQuery(fileName string) (PrometheusQuery, error) {
@@ -111,12 +136,14 @@ func loadStoredPrometheusQuery(fileName string) (PrometheusQuery, error) {
 	}
 
 	return PrometheusQuery{
-		RefId:      query.RefId,
-		RangeQuery: query.RangeQuery,
-		Start:      time.Unix(query.Start, 0),
-		End:        time.Unix(query.End, 0),
-		Step:       time.Second * time.Duration(query.Step),
-		Expr:       query.Expr,
+		RefId:         query.RefId,
+		RangeQuery:    query.RangeQuery,
+		InstantQuery:  query.InstantQuery,
+		ExemplarQuery: query.ExemplarQuery,
+		Start:         time.Unix(query.Start, 0),
+		End:           time.Unix(query.End, 0),
+		Step:          time.Second * time.Duration(query.Step),
+		Expr:          query.Expr,
 	}, nil
 }
 
@@ -132,5 +159,200 @@ func runQuery(response []byte, query PrometheusQuery) (*backend.QueryDataRespons
 	}
 
 	s := Service{tracer: tracer}
-	return s.runQueries(context.Background(), api, []*PrometheusQuery{&query})
+	return s.runQueries(context.Background(), api, []*PrometheusQuery{&query}, 1)
+}
+
+func runStreamedQuery(response []byte, query PrometheusQuery) (*backend.QueryDataResponse, error) {
+	client, err := promclient.NewClient("http://localhost:9999", &mockedRoundTripper{responseBytes: response})
+	if err != nil {
+		return nil, err
+	}
+
+	tracer, err := tracing.InitializeTracerForTest()
+	if err != nil {
+		return nil, err
+	}
+
+	s := Service{tracer: tracer}
+	query.StreamRangeResponse = true
+	return s.runQueries(context.Background(), client, []*PrometheusQuery{&query}, 1)
+}
+
+func TestSanitizePrometheusResponse(t *testing.T) {
+	response := []byte(`{
+		"status": "success",
+		"data": {
+			"resultType": "vector",
+			"result": [
+				{"metric": {"__name__": "up", "instance": "10.0.0.1:9090", "job": "a"}, "value": [1641889530, "1"]},
+				{"metric": {"__name__": "up", "instance": "10.0.0.2:9090", "job": "a"}, "value": [1641889530, "1"]},
+				{"metric": {"__name__": "up", "instance": "10.0.0.1:9090", "job": "b"}, "value": [1641889530, "0"]}
+			]
+		}
+	}`)
+
+	sanitized, err := sanitizePrometheusResponse(response)
+	require.NoError(t, err)
+
+	var result struct {
+		Data struct {
+			Result []struct {
+				Metric map[string]string
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(sanitized, &result))
+	require.Len(t, result.Data.Result, 3)
+	require.Equal(t, map[string]string{"__name__": "up", "instance": "instance-1", "job": "a"}, result.Data.Result[0].Metric)
+	require.Equal(t, "instance-2", result.Data.Result[1].Metric["instance"])
+	require.Equal(t, "instance-1", result.Data.Result[2].Metric["instance"])
+}
+
+func TestQueryStats(t *testing.T) {
+	response := []byte(`{
+		"status": "success",
+		"data": {
+			"resultType": "matrix",
+			"result": [{"metric": {"__name__": "up"}, "values": [[1641889530, "1"]]}],
+			"stats": {
+				"timings": {"evalTotalTime": 0.5, "execTotalTime": 0.75},
+				"samples": {"totalQueryableSamples": 1200, "peakSamples": 300}
+			}
+		}
+	}`)
+	query := PrometheusQuery{
+		RefId:      "A",
+		RangeQuery: true,
+		Start:      time.Unix(1641889530, 0),
+		End:        time.Unix(1641889530, 0),
+		Step:       time.Second,
+		Expr:       "up",
+		Stats:      true,
+	}
+
+	result, err := runStreamedQuery(response, query)
+	require.NoError(t, err)
+
+	frames := result.Responses["A"].Frames
+	require.Len(t, frames, 1)
+	stats := frames[0].Meta.Custom.(map[string]interface{})["stats"].(*promclient.QueryStats)
+	require.Equal(t, int64(1200), stats.Samples.TotalQueryableSamples)
+	require.Equal(t, int64(300), stats.Samples.PeakSamples)
+	require.Equal(t, 0.75, stats.Timings.ExecTotalTime)
+}
+
+func TestRangeQueryWarnings(t *testing.T) {
+	response := []byte(`{
+		"status": "success",
+		"warnings": ["results are partial"],
+		"data": {
+			"resultType": "matrix",
+			"result": [{"metric": {"__name__": "up"}, "values": [[1641889530, "1"]]}]
+		}
+	}`)
+	query := PrometheusQuery{
+		RefId:      "A",
+		RangeQuery: true,
+		Start:      time.Unix(1641889530, 0),
+		End:        time.Unix(1641889530, 0),
+		Step:       time.Second,
+		Expr:       "up",
+	}
+
+	for name, run := range map[string]func([]byte, PrometheusQuery) (*backend.QueryDataResponse, error){
+		"buffered": runQuery,
+		"streamed": runStreamedQuery,
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := run(response, query)
+			require.NoError(t, err)
+
+			frames := result.Responses["A"].Frames
+			require.Len(t, frames, 1)
+			require.Equal(t, []data.Notice{{Severity: data.NoticeSeverityWarning, Text: "results are partial"}}, frames[0].Meta.Notices)
+		})
+	}
+}
+
+func TestQuerySpanAttributes(t *testing.T) {
+	response := []byte(`{
+		"status": "success",
+		"data": {
+			"resultType": "matrix",
+			"result": [
+				{"metric": {"__name__": "up", "job": "a"}, "values": [[1641889530, "1"]]},
+				{"metric": {"__name__": "up", "job": "b"}, "values": [[1641889530, "1"]]}
+			],
+			"stats": {"timings": {"evalTotalTime": 0.5, "execTotalTime": 0.75}}
+		}
+	}`)
+	query := PrometheusQuery{
+		RefId:      "A",
+		RangeQuery: true,
+		Start:      time.Unix(1641889530, 0),
+		End:        time.Unix(1641889590, 0),
+		Step:       15 * time.Second,
+		Expr:       "up",
+		Stats:      true,
+
+		StreamRangeResponse: true,
+	}
+	client, err := promclient.NewClient("http://localhost:9999", &mockedRoundTripper{responseBytes: response})
+	require.NoError(t, err)
+
+	tracer := &recordingTracer{}
+	s := Service{tracer: tracer}
+	_, err = s.runQueries(context.Background(), client, []*PrometheusQuery{&query}, 1)
+	require.NoError(t, err)
+
+	require.Len(t, tracer.spans, 1)
+	attributes := tracer.spans[0].attributes
+	require.Equal(t, exprHash("up"), attributes["expr_hash"])
+	require.Equal(t, exprHash("up"), tracer.spans[0].values["expr_hash"].AsString())
+	require.Equal(t, int64(15000), attributes["step_ms"])
+	require.Equal(t, int64(60000), attributes["range_ms"])
+	require.Equal(t, 2, attributes["series_count"])
+	require.Equal(t, 0.5, attributes["prometheus_eval_total_time"])
+	require.Equal(t, 0.75, attributes["prometheus_exec_total_time"])
+	require.True(t, tracer.spans[0].ended)
+}
+
+type recordingTracer struct {
+	spans []*recordingSpan
 }
+
+func (t *recordingTracer) Run(context.Context) error {
+	return nil
+}
+
+func (t *recordingTracer) Start(ctx context.Context, spanName string, opts ...trace.SpanStartOption) (context.Context, tracing.Span) {
+	span := &recordingSpan{attributes: map[string]interface{}{}, values: map[attribute.Key]attribute.Value{}}
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func (t *recordingTracer) Inject(context.Context, http.Header, tracing.Span) {}
+
+type recordingSpan struct {
+	attributes map[string]interface{}
+	// values are the OpenTelemetry attributes.
+	values map[attribute.Key]attribute.Value
+	ended  bool
+}
+
+func (s *recordingSpan) End() {
+	s.ended = true
+}
+
+func (s *recordingSpan) SetAttributes(key string, value interface{}, kv attribute.KeyValue) {
+	s.attributes[key] = value
+	s.values[kv.Key] = kv.Value
+}
+
+func (s *recordingSpan) SetName(name string) {}
+
+func (s *recordingSpan) SetStatus(code codes.Code, description string) {}
+
+func (s *recordingSpan) RecordError(err error, options ...trace.EventOption) {}
+
+func (s *recordingSpan) AddEvents(keys []string, values []tracing.EventValue) {}
@@ -0,0 +1,91 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+const defaultHealthCheckQuery = "1+1"
+
+// healthDetails are the JSON details of a health check result.
+type healthDetails struct {
+	Flavor   string `json:"flavor"`
+	Version  string `json:"version,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+type buildInfoGetter interface {
+	BuildInfo(ctx context.Context) (*promclient.BuildInfo, error)
+}
+
+// CheckHealth checks that the Prometheus API is reachable by asking for its
+// build info, and that it evaluates queries when the data source has a probe
+// query. The details tell the flavor and version of the server.
+func (s *Service) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	dsInfo, err := s.getDSInfo(req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dsInfo.getClient(map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := buildInfo(ctx, client)
+	if err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: fmt.Sprintf("Failed to reach the Prometheus API: %s", ConvertAPIError(err)),
+		}, nil
+	}
+	details := healthDetails{Flavor: info.Flavor()}
+	if info != nil {
+		details.Version = info.Version
+		details.Revision = info.Revision
+	}
+	jsonDetails, err := json.Marshal(details)
+	if err != nil {
+		return nil, err
+	}
+
+	message := "Successfully queried the Prometheus API."
+	if dsInfo.HealthCheckQuery != "" {
+		if _, _, err := client.Query(ctx, dsInfo.HealthCheckQuery, time.Now()); err != nil {
+			return &backend.CheckHealthResult{
+				Status:      backend.HealthStatusError,
+				Message:     fmt.Sprintf("The Prometheus API is reachable, but the probe query %q failed: %s", dsInfo.HealthCheckQuery, ConvertAPIError(err)),
+				JSONDetails: jsonDetails,
+			}, nil
+		}
+		message = "Successfully queried the Prometheus API and evaluated the probe query."
+	}
+
+	return &backend.CheckHealthResult{
+		Status:      backend.HealthStatusOk,
+		Message:     message,
+		JSONDetails: jsonDetails,
+	}, nil
+}
+
+// buildInfo returns the build info of the server, nil when it is not known.
+func buildInfo(ctx context.Context, client apiv1.API) (*promclient.BuildInfo, error) {
+	if getter, ok := client.(buildInfoGetter); ok {
+		return getter.BuildInfo(ctx)
+	}
+	info, err := client.Buildinfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &promclient.BuildInfo{
+		Version:   info.Version,
+		Revision:  info.Revision,
+		Branch:    info.Branch,
+		GoVersion: info.GoVersion,
+	}, nil
+}
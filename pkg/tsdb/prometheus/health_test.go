@@ -0,0 +1,126 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHealth(t *testing.T) {
+	const buildInfo = `{"status":"success","data":{"version":"2.33.1","revision":"4e08110"}}`
+
+	t.Run("it returns the build info of the server", func(t *testing.T) {
+		s, requests := newHealthTestService(t, "", map[string]string{"/api/v1/status/buildinfo": buildInfo})
+
+		res, err := s.CheckHealth(context.Background(), healthRequest())
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusOk, res.Status)
+		require.Equal(t, "Successfully queried the Prometheus API.", res.Message)
+		require.JSONEq(t, `{"flavor":"prometheus","version":"2.33.1","revision":"4e08110"}`, string(res.JSONDetails))
+		require.Equal(t, []string{"/api/v1/status/buildinfo"}, *requests)
+	})
+
+	t.Run("it runs the probe query", func(t *testing.T) {
+		s, requests := newHealthTestService(t, "1+1", map[string]string{
+			"/api/v1/status/buildinfo": buildInfo,
+			"/api/v1/query":            `{"status":"success","data":{"resultType":"scalar","result":[1641889530,"2"]}}`,
+		})
+
+		res, err := s.CheckHealth(context.Background(), healthRequest())
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusOk, res.Status)
+		require.Equal(t, "Successfully queried the Prometheus API and evaluated the probe query.", res.Message)
+		require.Equal(t, []string{"/api/v1/status/buildinfo", "/api/v1/query"}, *requests)
+	})
+
+	t.Run("it fails when the probe query fails", func(t *testing.T) {
+		s, _ := newHealthTestService(t, "up{", map[string]string{
+			"/api/v1/status/buildinfo": buildInfo,
+			"/api/v1/query":            `{"status":"error","errorType":"bad_data","error":"parse error"}`,
+		})
+
+		res, err := s.CheckHealth(context.Background(), healthRequest())
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, res.Status)
+		require.Contains(t, res.Message, `the probe query "up{" failed: parse error`)
+		require.NotEmpty(t, res.JSONDetails)
+	})
+
+	t.Run("it reports an unknown flavor when there is no build info", func(t *testing.T) {
+		s, _ := newHealthTestService(t, "", map[string]string{})
+
+		res, err := s.CheckHealth(context.Background(), healthRequest())
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusOk, res.Status)
+		require.JSONEq(t, `{"flavor":"unknown"}`, string(res.JSONDetails))
+	})
+
+	t.Run("it fails when the API is not reachable", func(t *testing.T) {
+		s, _ := newHealthTestService(t, "", map[string]string{"/api/v1/status/buildinfo": ""})
+
+		res, err := s.CheckHealth(context.Background(), healthRequest())
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, res.Status)
+		require.Equal(t, "Failed to reach the Prometheus API: server error: 502", res.Message)
+	})
+}
+
+func healthRequest() *backend.CheckHealthRequest {
+	return &backend.CheckHealthRequest{
+		PluginContext: backend.PluginContext{
+			DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{ID: 1, UID: "prometheus"},
+		},
+	}
+}
+
+// newHealthTestService returns a service whose client answers the requests with
+// the responses of their paths, 404 for unknown paths and 502 for empty responses.
+// The paths of the requests are collected in the returned slice.
+func newHealthTestService(t *testing.T, healthCheckQuery string, responses map[string]string) (*Service, *[]string) {
+	t.Helper()
+	requests := &[]string{}
+	client, err := promclient.NewClient("http://localhost:9090", healthRoundTripper(func(req *http.Request) (*http.Response, error) {
+		*requests = append(*requests, req.URL.Path)
+		body, ok := responses[req.URL.Path]
+		status := http.StatusOK
+		switch {
+		case !ok:
+			status = http.StatusNotFound
+		case body == "":
+			status = http.StatusBadGateway
+		case strings.Contains(body, `"status":"error"`):
+			status = http.StatusBadRequest
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(bytes.NewReader([]byte(body)))}, nil
+	}))
+	require.NoError(t, err)
+
+	s := &Service{
+		im: datasource.NewInstanceManager(func(settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+			return DatasourceInfo{
+				ID:               settings.ID,
+				HealthCheckQuery: healthCheckQuery,
+				getClient: func(map[string]string) (apiv1.API, error) {
+					return client, nil
+				},
+			}, nil
+		}),
+	}
+	return s, requests
+}
+
+type healthRoundTripper func(req *http.Request) (*http.Response, error)
+
+func (f healthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,185 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/common/model"
+)
+
+const bucketLabel = "le"
+
+// bucketFrames returns the histogram bucket series of frames, grouped by their
+// labels without `le` and sorted by the numeric upper bound of each bucket.
+func bucketFrames(frames data.Frames) [][]*data.Frame {
+	groups := map[string][]*data.Frame{}
+	keys := []string{}
+	for _, frame := range frames {
+		if typ := frameResultType(frame); typ != "matrix" && typ != "vector" {
+			continue
+		}
+		if _, err := bucketBound(frame); err != nil {
+			continue
+		}
+
+		labels := data.Labels{}
+		for k, v := range frame.Fields[1].Labels {
+			if k != bucketLabel {
+				labels[k] = v
+			}
+		}
+		key := labels.String()
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], frame)
+	}
+	sort.Strings(keys)
+
+	result := make([][]*data.Frame, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		sort.SliceStable(group, func(i, j int) bool {
+			a, _ := bucketBound(group[i])
+			b, _ := bucketBound(group[j])
+			return a < b
+		})
+		result = append(result, group)
+	}
+	return result
+}
+
+// bucketBound returns the upper bound of the bucket series in frame.
+func bucketBound(frame *data.Frame) (float64, error) {
+	if len(frame.Fields) < 2 {
+		return 0, fmt.Errorf("not a series frame")
+	}
+	le, ok := frame.Fields[1].Labels[bucketLabel]
+	if !ok {
+		return 0, fmt.Errorf("missing %q label", bucketLabel)
+	}
+	return strconv.ParseFloat(le, 64)
+}
+
+// applyBucketRangeLegends names every histogram bucket series by the range of
+// values it adds to its cumulative predecessor, `(prev, le]`, rendered into the
+// legend in place of the raw `le` value.
+func applyBucketRangeLegends(frames data.Frames, query *PrometheusQuery) {
+	for _, group := range bucketFrames(frames) {
+		lower := math.Inf(-1)
+		for _, frame := range group {
+			upper, _ := bucketBound(frame)
+			valueField := frame.Fields[1]
+
+			metric := make(model.Metric, len(valueField.Labels))
+			for k, v := range valueField.Labels {
+				metric[model.LabelName(k)] = model.LabelValue(v)
+			}
+			metric[bucketLabel] = model.LabelValue(fmt.Sprintf("(%s, %s]", formatBucketBound(lower), formatBucketBound(upper)))
+
+			name := formatLegend(metric, query)
+			frame.Name = name
+			if valueField.Config == nil {
+				valueField.Config = &data.FieldConfig{}
+			}
+			valueField.Config.DisplayNameFromDS = name
+			lower = upper
+		}
+	}
+}
+
+func formatBucketBound(bound float64) string {
+	if math.IsInf(bound, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(bound, 'g', -1, 64)
+}
+
+// heatmapFrames replaces every group of histogram bucket series in frames by a single
+// heatmap-ready frame: the shared time field followed by a field per bucket, sorted
+// by `le` and named by it, holding the count of the bucket itself instead of the
+// cumulative count. Groups whose series do not share their timestamps are kept as
+// they are, like all frames that are not range bucket series.
+func heatmapFrames(frames data.Frames) data.Frames {
+	converted := map[*data.Frame]bool{}
+	heatmaps := data.Frames{}
+	for _, group := range bucketFrames(frames) {
+		if frameResultType(group[0]) != "matrix" {
+			continue
+		}
+		heatmap := bucketsToHeatmap(group)
+		if heatmap == nil {
+			continue
+		}
+		for _, frame := range group {
+			converted[frame] = true
+		}
+		heatmaps = append(heatmaps, heatmap)
+	}
+
+	result := make(data.Frames, 0, len(frames)-len(converted)+len(heatmaps))
+	for _, frame := range frames {
+		if !converted[frame] {
+			result = append(result, frame)
+		}
+	}
+	return append(result, heatmaps...)
+}
+
+// bucketsToHeatmap de-accumulates the sorted bucket series of a histogram into a
+// single frame, or returns nil if they do not share their timestamps.
+func bucketsToHeatmap(buckets []*data.Frame) *data.Frame {
+	timeField := buckets[0].Fields[0]
+	for _, frame := range buckets[1:] {
+		if !sameTimes(timeField, frame.Fields[0]) {
+			return nil
+		}
+	}
+
+	fields := make([]*data.Field, 0, len(buckets)+1)
+	fields = append(fields, timeField)
+	var previous *data.Field
+	for _, frame := range buckets {
+		cumulative := frame.Fields[1]
+		field := data.NewFieldFromFieldType(data.FieldTypeNullableFloat64, cumulative.Len())
+		field.Name = cumulative.Labels[bucketLabel]
+		field.Labels = cumulative.Labels
+		field.Config = cumulative.Config
+
+		for i := 0; i < cumulative.Len(); i++ {
+			v, ok := cumulative.ConcreteAt(i)
+			if !ok {
+				continue
+			}
+			count := v.(float64)
+			if previous != nil {
+				if p, ok := previous.ConcreteAt(i); ok {
+					count -= p.(float64)
+				}
+			}
+			field.Set(i, &count)
+		}
+
+		previous = cumulative
+		fields = append(fields, field)
+	}
+
+	name := buckets[0].Fields[1].Labels[model.MetricNameLabel]
+	return newDataFrame(name, "matrix", fields...)
+}
+
+func sameTimes(a, b *data.Field) bool {
+	if a.Len() != b.Len() {
+		return false
+	}
+	for i := 0; i < a.Len(); i++ {
+		if !a.At(i).(time.Time).Equal(b.At(i).(time.Time)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketRangeLegends(t *testing.T) {
+	bucket := func(le string, v p.SampleValue) *p.SampleStream {
+		return &p.SampleStream{
+			Metric: p.Metric{"__name__": "request_duration_seconds_bucket", "job": "app", "le": p.LabelValue(le)},
+			Values: []p.SamplePair{{Value: v, Timestamp: 1000}},
+		}
+	}
+
+	value := map[TimeSeriesQueryType]interface{}{
+		RangeQueryType: p.Matrix{bucket("+Inf", 10), bucket("0.5", 8), bucket("0.1", 3)},
+	}
+	query := &PrometheusQuery{
+		LegendFormat:      "{{le}}",
+		Step:              1 * time.Second,
+		Start:             time.Unix(1, 0).UTC(),
+		End:               time.Unix(1, 0).UTC(),
+		BucketRangeLegend: true,
+	}
+	res, err := parseTimeSeriesResponse(value, query)
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+
+	names := map[string]string{}
+	for _, frame := range res {
+		names[frame.Fields[1].Labels["le"]] = frame.Name
+		require.Equal(t, frame.Name, frame.Fields[1].Config.DisplayNameFromDS)
+	}
+	require.Equal(t, map[string]string{
+		"0.1":  "(-Inf, 0.1]",
+		"0.5":  "(0.1, 0.5]",
+		"+Inf": "(0.5, +Inf]",
+	}, names)
+}
+
+func TestBucketRangeLegendsForHeatmapQueries(t *testing.T) {
+	service := Service{intervalCalculator: intervalv2.NewCalculator()}
+	query := backend.DataQuery{
+		JSON:      []byte(`{"expr": "rate(request_duration_seconds_bucket[5m])", "format": "heatmap", "legendFormat": "{{le}}", "refId": "A"}`),
+		TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)},
+	}
+	queries, err := service.parseTimeSeriesQuery(&backend.QueryDataRequest{Queries: []backend.DataQuery{query}}, &DatasourceInfo{})
+	require.NoError(t, err)
+	require.True(t, queries[0].BucketRangeLegend)
+}
+
+func TestHeatmapFormat(t *testing.T) {
+	bucket := func(le string, values ...p.SampleValue) *p.SampleStream {
+		s := &p.SampleStream{Metric: p.Metric{"__name__": "request_duration_seconds_bucket", "le": p.LabelValue(le)}}
+		for i, v := range values {
+			s.Values = append(s.Values, p.SamplePair{Value: v, Timestamp: p.Time(1000 * (i + 1))})
+		}
+		return s
+	}
+
+	value := map[TimeSeriesQueryType]interface{}{
+		RangeQueryType: p.Matrix{
+			bucket("+Inf", 10, 12),
+			bucket("0.1", 3, 4),
+			{Metric: p.Metric{"__name__": "up"}, Values: []p.SamplePair{{Value: 1, Timestamp: 1000}, {Value: 1, Timestamp: 2000}}},
+			bucket("0.5", 8, 8),
+		},
+	}
+	query := &PrometheusQuery{
+		Step:   1 * time.Second,
+		Start:  time.Unix(1, 0).UTC(),
+		End:    time.Unix(2, 0).UTC(),
+		Format: formatHeatmap,
+	}
+	res, err := parseTimeSeriesResponse(value, query)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	require.Equal(t, "up", res[0].Name)
+
+	heatmap := res[1]
+	require.Equal(t, "request_duration_seconds_bucket", heatmap.Name)
+	require.Len(t, heatmap.Fields, 4)
+
+	counts := map[string][]float64{}
+	names := []string{}
+	for _, field := range heatmap.Fields[1:] {
+		names = append(names, field.Name)
+		for i := 0; i < field.Len(); i++ {
+			v, ok := field.ConcreteAt(i)
+			require.True(t, ok)
+			counts[field.Name] = append(counts[field.Name], v.(float64))
+		}
+	}
+	require.Equal(t, []string{"0.1", "0.5", "+Inf"}, names)
+	require.Equal(t, map[string][]float64{
+		"0.1":  {3, 4},
+		"0.5":  {5, 4},
+		"+Inf": {2, 4},
+	}, counts)
+}
@@ -0,0 +1,136 @@
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// highCardinalitySeries is the number of series from which a query without any
+// aggregation gets a hint to aggregate its result.
+const highCardinalitySeries = 100
+
+// counterSuffixes are the metric name suffixes of counters, including the
+// counters of histograms and summaries.
+var counterSuffixes = []string{"_total", "_count", "_sum", "_bucket"}
+
+// counterFunctions are the functions that make sense of a counter as it is.
+var counterFunctions = map[string]bool{
+	"rate":             true,
+	"irate":            true,
+	"increase":         true,
+	"resets":           true,
+	"changes":          true,
+	"absent":           true,
+	"absent_over_time": true,
+	"timestamp":        true,
+}
+
+// counterAggregations are the aggregations that make sense of a counter as it is.
+var counterAggregations = map[parser.ItemType]bool{
+	parser.COUNT:        true,
+	parser.COUNT_VALUES: true,
+	parser.GROUP:        true,
+}
+
+// queryHints returns advice on the expression of query, given the number of
+// series it returned: counters used without rate(), rate() ranges too short
+// for the scrape interval, and many series that are not aggregated. It
+// returns nil when the expression cannot be parsed.
+func queryHints(query *PrometheusQuery, series int) []string {
+	node, err := parser.ParseExpr(query.sourceExpr())
+	if err != nil {
+		return nil
+	}
+
+	var hints []string
+	seen := map[string]bool{}
+	aggregated := false
+	parser.Inspect(node, func(n parser.Node, path []parser.Node) error {
+		switch n := n.(type) {
+		case *parser.AggregateExpr:
+			aggregated = true
+		case *parser.VectorSelector:
+			if n.Name == "" || seen[n.Name] || !isCounterName(n.Name) || usesCounter(path) {
+				return nil
+			}
+			seen[n.Name] = true
+			hints = append(hints, fmt.Sprintf("Metric %s looks like a counter, which only increases. Consider applying rate() or increase() to it.", n.Name))
+		case *parser.Call:
+			if (n.Func.Name != "rate" && n.Func.Name != "increase") || len(n.Args) == 0 || query.ScrapeInterval <= 0 {
+				return nil
+			}
+			var window time.Duration
+			switch arg := n.Args[0].(type) {
+			case *parser.MatrixSelector:
+				window = arg.Range
+			case *parser.SubqueryExpr:
+				window = arg.Range
+			default:
+				return nil
+			}
+			if minWindow := 4 * query.ScrapeInterval; window < minWindow {
+				hints = append(hints, fmt.Sprintf("The range of %s() is %s, shorter than four times the scrape interval of %s, so it can miss samples. Consider a range of at least %s, or $__rate_interval.",
+					n.Func.Name, model.Duration(window), model.Duration(query.ScrapeInterval), model.Duration(minWindow)))
+			}
+		}
+		return nil
+	})
+
+	if !aggregated && series >= highCardinalitySeries {
+		hints = append(hints, fmt.Sprintf("The query returned %d series. Consider aggregating them, e.g. with sum by (...).", series))
+	}
+	return hints
+}
+
+func isCounterName(name string) bool {
+	for _, suffix := range counterSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// usesCounter reports whether one of the ancestors of a counter selector makes
+// sense of its value as it is.
+func usesCounter(path []parser.Node) bool {
+	for _, node := range path {
+		switch n := node.(type) {
+		case *parser.Call:
+			if counterFunctions[n.Func.Name] {
+				return true
+			}
+		case *parser.AggregateExpr:
+			if counterAggregations[n.Op] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addQueryHints attaches the hints of query to its first series frame, or its
+// first frame when there are no series, as informational notices.
+func addQueryHints(frames data.Frames, query *PrometheusQuery, series int) {
+	if len(frames) == 0 {
+		return
+	}
+	frame := frames[0]
+	for _, f := range frames {
+		if typ := frameResultType(f); typ == "matrix" || typ == "vector" || typ == "table" {
+			frame = f
+			break
+		}
+	}
+	for _, hint := range queryHints(query, series) {
+		frame.AppendNotices(data.Notice{
+			Severity: data.NoticeSeverityInfo,
+			Text:     hint,
+		})
+	}
+}
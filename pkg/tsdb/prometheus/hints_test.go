@@ -0,0 +1,74 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHints(t *testing.T) {
+	hints := func(expr string, series int) []string {
+		return queryHints(&PrometheusQuery{Expr: expr, ScrapeInterval: 15 * time.Second}, series)
+	}
+
+	t.Run("counters without rate", func(t *testing.T) {
+		require.Equal(t, []string{
+			"Metric http_requests_total looks like a counter, which only increases. Consider applying rate() or increase() to it.",
+		}, hints(`sum(http_requests_total{job="a"}) / sum(http_requests_total)`, 1))
+		require.Len(t, hints("request_duration_seconds_count", 1), 1)
+
+		require.Empty(t, hints("sum(rate(http_requests_total[5m]))", 1))
+		require.Empty(t, hints("increase(http_requests_total[1h])", 1))
+		require.Empty(t, hints("count(http_requests_total)", 1))
+		require.Empty(t, hints("absent(http_requests_total)", 1))
+		require.Empty(t, hints("up", 1))
+	})
+
+	t.Run("short rate ranges", func(t *testing.T) {
+		require.Equal(t, []string{
+			"The range of rate() is 30s, shorter than four times the scrape interval of 15s, so it can miss samples. Consider a range of at least 1m, or $__rate_interval.",
+		}, hints("sum(rate(http_requests_total[30s]))", 1))
+		require.Len(t, hints("increase(http_requests_total[30s:5s])", 1), 1)
+
+		require.Empty(t, hints("sum(rate(http_requests_total[1m]))", 1))
+		require.Empty(t, hints("sum(irate(http_requests_total[30s]))", 1))
+		require.Empty(t, queryHints(&PrometheusQuery{Expr: "sum(rate(http_requests_total[30s]))"}, 1))
+	})
+
+	t.Run("many series without aggregation", func(t *testing.T) {
+		require.Equal(t, []string{
+			"The query returned 150 series. Consider aggregating them, e.g. with sum by (...).",
+		}, hints("rate(http_requests_total[5m])", 150))
+
+		require.Empty(t, hints("rate(http_requests_total[5m])", 99))
+		require.Empty(t, hints("sum by (instance) (rate(http_requests_total[5m]))", 150))
+	})
+
+	t.Run("invalid expressions", func(t *testing.T) {
+		require.Nil(t, hints("rate(", 150))
+	})
+}
+
+func TestQueryHintNotices(t *testing.T) {
+	value := map[TimeSeriesQueryType]interface{}{
+		RangeQueryType: p.Matrix{{
+			Metric: p.Metric{"__name__": "http_requests_total"},
+			Values: []p.SamplePair{{Value: 1, Timestamp: 1000}},
+		}},
+	}
+	query := &PrometheusQuery{
+		Expr:       "http_requests_total",
+		Step:       1 * time.Second,
+		Start:      time.Unix(1, 0).UTC(),
+		End:        time.Unix(1, 0).UTC(),
+		RangeQuery: true,
+	}
+	res, err := parseTimeSeriesResponse(value, query)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Len(t, res[0].Meta.Notices, 1)
+	require.Equal(t, data.NoticeSeverityInfo, res[0].Meta.Notices[0].Severity)
+}
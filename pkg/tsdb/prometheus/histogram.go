@@ -0,0 +1,101 @@
+package prometheus
+
+import (
+	"math"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	"github.com/prometheus/common/model"
+)
+
+// histogramToDataFrames converts the native histogram samples of a series into the
+// frames a classic histogram would produce: a count and a sum series, and a
+// cumulative series per bucket upper bound labelled with `le`. The bucket series
+// can be used by heatmaps like `_bucket` series. The part of the histogram each
+// frame holds is stored in its `histogram` custom meta.
+func histogramToDataFrames(metric model.Metric, histograms []promclient.SampleHistogramPair, query *PrometheusQuery, frames data.Frames) data.Frames {
+	count := &model.SampleStream{Metric: histogramMetric(metric, "_count")}
+	sum := &model.SampleStream{Metric: histogramMetric(metric, "_sum")}
+	for _, h := range histograms {
+		count.Values = append(count.Values, model.SamplePair{Timestamp: h.Timestamp, Value: h.Histogram.Count})
+		sum.Values = append(sum.Values, model.SamplePair{Timestamp: h.Timestamp, Value: h.Histogram.Sum})
+	}
+
+	bounds := histogramUpperBounds(histograms)
+	buckets := make(model.Matrix, 0, len(bounds))
+	for _, bound := range bounds {
+		bucket := &model.SampleStream{Metric: histogramMetric(metric, "_bucket")}
+		bucket.Metric[bucketLabel] = model.LabelValue(formatBucketBound(bound))
+		for _, h := range histograms {
+			bucket.Values = append(bucket.Values, model.SamplePair{
+				Timestamp: h.Timestamp,
+				Value:     cumulativeCount(h.Histogram, bound),
+			})
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	parts := []struct {
+		part   string
+		matrix model.Matrix
+	}{
+		{"count", model.Matrix{count}},
+		{"sum", model.Matrix{sum}},
+		{"bucket", buckets},
+	}
+	for _, p := range parts {
+		partFrames := matrixToDataFrames(p.matrix, query, nil)
+		for _, frame := range partFrames {
+			setFrameCustomMeta(frame, "histogram", p.part)
+		}
+		frames = append(frames, partFrames...)
+	}
+
+	return frames
+}
+
+// histogramMetric returns a copy of metric named like the classic histogram series
+// with suffix. Metrics without a name, e.g. the result of rate(), stay unnamed.
+func histogramMetric(metric model.Metric, suffix string) model.Metric {
+	m := metric.Clone()
+	if name, ok := m[model.MetricNameLabel]; ok {
+		m[model.MetricNameLabel] = name + model.LabelValue(suffix)
+	}
+	return m
+}
+
+// histogramUpperBounds returns the sorted upper bounds of all buckets of the
+// histograms, always ending with +Inf.
+func histogramUpperBounds(histograms []promclient.SampleHistogramPair) []float64 {
+	seen := map[float64]bool{}
+	bounds := []float64{}
+	for _, h := range histograms {
+		for _, b := range h.Histogram.Buckets {
+			upper := float64(b.Upper)
+			if !seen[upper] {
+				seen[upper] = true
+				bounds = append(bounds, upper)
+			}
+		}
+	}
+	if !seen[math.Inf(1)] {
+		bounds = append(bounds, math.Inf(1))
+	}
+	sort.Float64s(bounds)
+	return bounds
+}
+
+// cumulativeCount returns the number of observations of h in the buckets up to bound.
+func cumulativeCount(h promclient.SampleHistogram, bound float64) model.SampleValue {
+	if math.IsInf(bound, 1) {
+		return h.Count
+	}
+	var count model.SampleValue
+	for _, b := range h.Buckets {
+		if float64(b.Upper) <= bound {
+			count += b.Count
+		}
+	}
+	return count
+}
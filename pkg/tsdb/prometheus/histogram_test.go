@@ -0,0 +1,57 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNativeHistogramResponses(t *testing.T) {
+	response := []byte(`{
+		"status": "success",
+		"data": {
+			"resultType": "matrix",
+			"result": [{
+				"metric": {"__name__": "request_duration_seconds", "job": "app"},
+				"histograms": [
+					[1641889530, {"count": "5", "sum": "4.5", "buckets": [[0, "0.5", "1", "2"], [0, "1", "2", "3"]]}],
+					[1641889531, {"count": "7", "sum": "6", "buckets": [[0, "0.5", "1", "3"], [0, "2", "4", "4"]]}]
+				]
+			}]
+		}
+	}`)
+	query := PrometheusQuery{
+		RefId:      "A",
+		RangeQuery: true,
+		Start:      time.Unix(1641889530, 0),
+		End:        time.Unix(1641889531, 0),
+		Step:       time.Second,
+		Expr:       "request_duration_seconds",
+	}
+
+	result, err := runStreamedQuery(response, query)
+	require.NoError(t, err)
+	frames := result.Responses["A"].Frames
+
+	values := map[string][]float64{}
+	for _, frame := range frames {
+		field := frame.Fields[1]
+		key := field.Labels["__name__"] + field.Labels["le"]
+		for i := 0; i < field.Len(); i++ {
+			v, _ := field.ConcreteAt(i)
+			values[key] = append(values[key], v.(float64))
+		}
+		require.Equal(t, "app", field.Labels["job"])
+		require.Contains(t, []interface{}{"count", "sum", "bucket"}, frame.Meta.Custom.(map[string]interface{})["histogram"])
+	}
+
+	require.Equal(t, map[string][]float64{
+		"request_duration_seconds_count":      {5, 7},
+		"request_duration_seconds_sum":        {4.5, 6},
+		"request_duration_seconds_bucket1":    {2, 3},
+		"request_duration_seconds_bucket2":    {5, 3},
+		"request_duration_seconds_bucket4":    {5, 7},
+		"request_duration_seconds_bucket+Inf": {5, 7},
+	}, values)
+}
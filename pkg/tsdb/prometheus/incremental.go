@@ -0,0 +1,143 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	lru "github.com/hashicorp/golang-lru"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	defaultIncrementalQueryOverlap = 10 * time.Minute
+	incrementalCacheSize           = 500
+)
+
+// IncrementalQueryCache remembers the series of the last range of a query. When the
+// query runs again over a range that starts within the cached one, on the same step
+// grid, only the part after the cached range is queried and merged into the cached
+// series. The last overlap of the cached range is queried again, since Prometheus
+// may not have had all of its samples yet.
+type IncrementalQueryCache struct {
+	overlap time.Duration
+	entries *lru.Cache
+}
+
+type incrementalCacheEntry struct {
+	timeRange apiv1.Range
+	series    []*promclient.Series
+}
+
+func NewIncrementalQueryCache(overlap time.Duration) (*IncrementalQueryCache, error) {
+	entries, err := lru.New(incrementalCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &IncrementalQueryCache{overlap: overlap, entries: entries}, nil
+}
+
+// incrementalCacheKey identifies a query by its expression, step, custom query
+// parameters and the headers of the request, which hold the credentials of
+// forwarded OAuth identities.
+func incrementalCacheKey(expr string, step time.Duration, params url.Values, headers map[string]string) string {
+	return fmt.Sprintf("%s|%s|%s|%s", expr, step, params.Encode(), hashHeaders(headers))
+}
+
+// hashHeaders returns a hash of headers, for cache keys that must not be shared
+// between users.
+func hashHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := fnv.New64a()
+	for _, name := range names {
+		_, _ = fmt.Fprintf(h, "%s=%s;", name, headers[name])
+	}
+	return fmt.Sprintf("%x", h.Sum64())
+}
+
+// querySeries returns the series of key over r, fetching only what is not cached.
+// The statistics and warnings returned are those of the fetch.
+func (c *IncrementalQueryCache) querySeries(ctx context.Context, key string, r apiv1.Range, fetch func(context.Context, apiv1.Range) ([]*promclient.Series, *promclient.QueryStats, apiv1.Warnings, error)) ([]*promclient.Series, *promclient.QueryStats, apiv1.Warnings, error) {
+	var cached *incrementalCacheEntry
+	if v, ok := c.entries.Get(key); ok {
+		cached = v.(*incrementalCacheEntry)
+		if !c.reusable(cached, r) {
+			cached = nil
+		}
+	}
+
+	if cached == nil {
+		series, stats, warnings, err := fetch(ctx, r)
+		if err != nil {
+			return nil, nil, warnings, err
+		}
+		c.entries.Add(key, &incrementalCacheEntry{timeRange: r, series: series})
+		return series, stats, warnings, nil
+	}
+
+	// Re-query from the first step of the overlap, on the grid of the cached range.
+	deltaStart := cached.timeRange.End.Add(-c.overlap)
+	if steps := deltaStart.Sub(cached.timeRange.Start) / r.Step; steps > 0 {
+		deltaStart = cached.timeRange.Start.Add(steps * r.Step)
+	} else {
+		deltaStart = cached.timeRange.Start
+	}
+	if deltaStart.Before(r.Start) {
+		deltaStart = r.Start
+	}
+
+	delta, stats, warnings, err := fetch(ctx, apiv1.Range{Start: deltaStart, End: r.End, Step: r.Step})
+	if err != nil {
+		return nil, nil, warnings, err
+	}
+
+	series := stitchSeries([][]*promclient.Series{trimSeries(cached.series, r.Start, deltaStart), delta})
+	c.entries.Add(key, &incrementalCacheEntry{timeRange: r, series: series})
+	return series, stats, warnings, nil
+}
+
+// reusable reports whether the cached results can be extended to r.
+func (c *IncrementalQueryCache) reusable(cached *incrementalCacheEntry, r apiv1.Range) bool {
+	return cached.timeRange.Step == r.Step &&
+		!r.Start.Before(cached.timeRange.Start) &&
+		!r.Start.After(cached.timeRange.End) &&
+		!r.End.Before(cached.timeRange.End) &&
+		r.Start.Sub(cached.timeRange.Start)%r.Step == 0
+}
+
+// trimSeries returns copies of series holding only their samples in [start, end).
+// Series without samples in that window are left out. The cached series are never
+// modified, since frames may still be built from them.
+func trimSeries(series []*promclient.Series, start, end time.Time) []*promclient.Series {
+	from, to := model.TimeFromUnixNano(start.UnixNano()), model.TimeFromUnixNano(end.UnixNano())
+	inWindow := func(ts model.Time) bool { return !ts.Before(from) && ts.Before(to) }
+
+	trimmed := make([]*promclient.Series, 0, len(series))
+	for _, s := range series {
+		t := &promclient.Series{SampleStream: model.SampleStream{Metric: s.Metric}}
+		for _, v := range s.Values {
+			if inWindow(v.Timestamp) {
+				t.Values = append(t.Values, v)
+			}
+		}
+		for _, h := range s.Histograms {
+			if inWindow(h.Timestamp) {
+				t.Histograms = append(t.Histograms, h)
+			}
+		}
+		if len(t.Values) > 0 || len(t.Histograms) > 0 {
+			trimmed = append(trimmed, t)
+		}
+	}
+	return trimmed
+}
@@ -0,0 +1,100 @@
+package prometheus
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIncrementalQueryCache(t *testing.T) {
+	hour := func(h int) time.Time { return time.Unix(0, 0).Add(time.Duration(h) * time.Hour) }
+	run := func(t *testing.T, cache *IncrementalQueryCache, client *splitClient, start, end int) []float64 {
+		t.Helper()
+		key := incrementalCacheKey("up", time.Hour, nil, nil)
+		r := apiv1.Range{Start: hour(start), End: hour(end), Step: time.Hour}
+		series, _, _, err := cache.querySeries(context.Background(), key, r, func(ctx context.Context, r apiv1.Range) ([]*promclient.Series, *promclient.QueryStats, apiv1.Warnings, error) {
+			return querySeries(ctx, client, &PrometheusQuery{Expr: "up", StreamRangeResponse: true}, r)
+		})
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+
+		values := []float64{}
+		for _, v := range series[0].Values {
+			values = append(values, float64(v.Value))
+		}
+		return values
+	}
+
+	t.Run("it only queries the new part of the range and the overlap", func(t *testing.T) {
+		cache, err := NewIncrementalQueryCache(time.Hour)
+		require.NoError(t, err)
+		client := &splitClient{}
+
+		run(t, cache, client, 0, 10)
+		values := run(t, cache, client, 2, 12)
+
+		require.Len(t, client.ranges, 2)
+		require.Equal(t, apiv1.Range{Start: hour(9), End: hour(12), Step: time.Hour}, client.ranges[1])
+		require.Equal(t, []float64{2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, values)
+	})
+
+	t.Run("it queries the whole range when the cached range cannot be extended", func(t *testing.T) {
+		cache, err := NewIncrementalQueryCache(time.Hour)
+		require.NoError(t, err)
+		client := &splitClient{}
+
+		run(t, cache, client, 5, 10)
+		values := run(t, cache, client, 0, 12)
+
+		require.Len(t, client.ranges, 2)
+		require.Equal(t, apiv1.Range{Start: hour(0), End: hour(12), Step: time.Hour}, client.ranges[1])
+		require.Len(t, values, 13)
+	})
+
+	t.Run("it uses different entries per request headers", func(t *testing.T) {
+		require.NotEqual(t,
+			incrementalCacheKey("up", time.Hour, nil, map[string]string{"Authorization": "a"}),
+			incrementalCacheKey("up", time.Hour, nil, map[string]string{"Authorization": "b"}),
+		)
+	})
+
+	t.Run("it uses different entries per custom query parameters", func(t *testing.T) {
+		require.NotEqual(t,
+			incrementalCacheKey("up", time.Hour, nil, nil),
+			incrementalCacheKey("up", time.Hour, url.Values{"dedup": []string{"false"}}, nil),
+		)
+	})
+
+	t.Run("it returns frames of the merged series", func(t *testing.T) {
+		cache, err := NewIncrementalQueryCache(time.Hour)
+		require.NoError(t, err)
+		client := &splitClient{}
+		query := func(start, end int) data.Frames {
+			q := &PrometheusQuery{
+				Expr:                "up",
+				Step:                time.Hour,
+				Start:               hour(start),
+				End:                 hour(end),
+				IncrementalCache:    cache,
+				IncrementalCacheKey: incrementalCacheKey("up", time.Hour, nil, nil),
+				StreamRangeResponse: true,
+			}
+			res, _, _, err := queryRange(context.Background(), client, q, apiv1.Range{Start: q.Start, End: q.End, Step: q.Step})
+			require.NoError(t, err)
+			return res.(data.Frames)
+		}
+
+		query(0, 10)
+		frames := query(1, 11)
+		require.Len(t, frames, 1)
+		require.Equal(t, 11, frames[0].Rows())
+		v, _ := frames[0].Fields[1].ConcreteAt(10)
+		require.Equal(t, float64(11), v)
+	})
+}
@@ -0,0 +1,125 @@
+package prometheus
+
+import (
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/common/model"
+)
+
+// longFrames replaces the range and instant series frames of a result by a
+// single long frame per result type: the time, a column per label, the metric
+// name first, and the value, with a row per sample sorted by time and labels.
+// Null samples have no row. Labels a series does not have are empty. The
+// number of series folded into a long frame is kept in its "series" metadata.
+func longFrames(frames data.Frames, query *PrometheusQuery) data.Frames {
+	result := make(data.Frames, 0, len(frames))
+	series := map[string]data.Frames{}
+	var types []string
+	for _, frame := range frames {
+		typ := frameResultType(frame)
+		if typ != "matrix" && typ != "vector" {
+			result = append(result, frame)
+			continue
+		}
+		if _, ok := series[typ]; !ok {
+			types = append(types, typ)
+		}
+		series[typ] = append(series[typ], frame)
+	}
+
+	for _, typ := range types {
+		result = append(result, seriesToLongFrame(series[typ], typ, query))
+	}
+	return result
+}
+
+func seriesToLongFrame(frames data.Frames, typ string, query *PrometheusQuery) *data.Frame {
+	type row struct {
+		time   time.Time
+		key    string
+		labels data.Labels
+		value  float64
+	}
+
+	labelNames := map[string]bool{}
+	var rows []row
+	for _, frame := range frames {
+		timeField, valueField := frame.Fields[0], frame.Fields[1]
+		for name := range valueField.Labels {
+			labelNames[name] = true
+		}
+		key := valueField.Labels.String()
+		for i := 0; i < valueField.Len(); i++ {
+			v, ok := valueField.ConcreteAt(i)
+			if !ok {
+				continue
+			}
+			rows = append(rows, row{
+				time:   timeField.At(i).(time.Time),
+				key:    key,
+				labels: valueField.Labels,
+				value:  v.(float64),
+			})
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if !rows[i].time.Equal(rows[j].time) {
+			return rows[i].time.Before(rows[j].time)
+		}
+		return rows[i].key < rows[j].key
+	})
+
+	names := make([]string, 0, len(labelNames))
+	for name := range labelNames {
+		if name != model.MetricNameLabel {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if labelNames[model.MetricNameLabel] {
+		names = append([]string{model.MetricNameLabel}, names...)
+	}
+
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, len(rows))
+	timeField.Name = data.TimeSeriesTimeFieldName
+	valueField := data.NewFieldFromFieldType(data.FieldTypeFloat64, len(rows))
+	valueField.Name = data.TimeSeriesValueFieldName
+	labelFields := make([]*data.Field, len(names))
+	for i, name := range names {
+		labelFields[i] = data.NewFieldFromFieldType(data.FieldTypeString, len(rows))
+		labelFields[i].Name = name
+	}
+
+	for i, r := range rows {
+		timeField.Set(i, r.time)
+		valueField.Set(i, r.value)
+		for j, name := range names {
+			labelFields[j].Set(i, r.labels[name])
+		}
+	}
+
+	fields := make([]*data.Field, 0, len(names)+2)
+	fields = append(fields, timeField)
+	fields = append(fields, labelFields...)
+	fields = append(fields, valueField)
+	frame := newDataFrame(query.RefId, typ, fields...)
+	frame.Meta.Type = data.FrameTypeTimeSeriesLong
+	setFrameCustomMeta(frame, "series", len(frames))
+	return frame
+}
+
+// frameSeries returns the number of series in a series frame, more than one
+// for long frames.
+func frameSeries(frame *data.Frame) int {
+	if frame.Meta != nil {
+		if custom, ok := frame.Meta.Custom.(map[string]interface{}); ok {
+			if n, ok := custom["series"].(int); ok {
+				return n
+			}
+		}
+	}
+	return 1
+}
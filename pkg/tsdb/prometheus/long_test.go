@@ -0,0 +1,81 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLongFormat(t *testing.T) {
+	value := map[TimeSeriesQueryType]interface{}{
+		RangeQueryType: p.Matrix{
+			{
+				Metric: p.Metric{"__name__": "up", "job": "b"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}, {Value: 0, Timestamp: 2000}},
+			},
+			{
+				Metric: p.Metric{"__name__": "up", "job": "a", "instance": "x"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}, {Value: p.SampleValue(math.NaN()), Timestamp: 2000}},
+			},
+		},
+	}
+	query := &PrometheusQuery{
+		RefId:        "A",
+		Step:         1 * time.Second,
+		Start:        time.Unix(1, 0).UTC(),
+		End:          time.Unix(2, 0).UTC(),
+		RangeQuery:   true,
+		ResultFormat: ResultFormatLong,
+	}
+	res, err := parseTimeSeriesResponse(value, query)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+
+	frame := res[0]
+	require.Equal(t, "A", frame.Name)
+	require.Equal(t, data.FrameType(data.FrameTypeTimeSeriesLong), frame.Meta.Type)
+	require.Equal(t, 2, countSeries(res))
+
+	names := make([]string, len(frame.Fields))
+	for i, field := range frame.Fields {
+		names[i] = field.Name
+	}
+	require.Equal(t, []string{"Time", "__name__", "instance", "job", "Value"}, names)
+
+	// The NaN sample of job a has no row.
+	require.Equal(t, 3, frame.Rows())
+	require.Equal(t, []interface{}{time.Unix(1, 0).UTC(), "up", "x", "a", 1.0}, frame.RowCopy(0))
+	require.Equal(t, []interface{}{time.Unix(1, 0).UTC(), "up", "", "b", 1.0}, frame.RowCopy(1))
+	require.Equal(t, []interface{}{time.Unix(2, 0).UTC(), "up", "", "b", 0.0}, frame.RowCopy(2))
+}
+
+func TestParseResultFormat(t *testing.T) {
+	service := Service{intervalCalculator: intervalv2.NewCalculator()}
+	parse := func(json string) ([]*PrometheusQuery, error) {
+		query := backend.DataQuery{
+			JSON:      []byte(json),
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)},
+		}
+		return service.parseTimeSeriesQuery(&backend.QueryDataRequest{Queries: []backend.DataQuery{query}}, &DatasourceInfo{})
+	}
+
+	queries, err := parse(`{"expr": "up", "refId": "A"}`)
+	require.NoError(t, err)
+	require.Equal(t, ResultFormatWide, queries[0].ResultFormat)
+
+	queries, err = parse(`{"expr": "up", "resultFormat": "long", "refId": "A"}`)
+	require.NoError(t, err)
+	require.Equal(t, ResultFormatLong, queries[0].ResultFormat)
+
+	_, err = parse(`{"expr": "up", "resultFormat": "tall", "refId": "A"}`)
+	require.EqualError(t, err, `invalid result format "tall"`)
+
+	_, err = parse(`{"expr": "up", "resultFormat": "long", "format": "heatmap", "refId": "A"}`)
+	require.Error(t, err)
+}
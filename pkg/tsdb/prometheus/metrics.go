@@ -0,0 +1,29 @@
+package prometheus
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var resultSeries = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "grafana",
+	Subsystem: "prometheus_datasource",
+	Name:      "result_series",
+	Help:      "Number of series in the query results of Prometheus data sources",
+	Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+}, []string{"datasource_uid", "query_type"})
+
+func init() {
+	prometheus.MustRegister(resultSeries)
+}
+
+// observeResultSeries records the number of range and instant series in the
+// frames of a query.
+func observeResultSeries(query *PrometheusQuery, frames data.Frames) {
+	if query.RangeQuery {
+		resultSeries.WithLabelValues(query.DatasourceUID, "range").Observe(float64(countSeriesOfType(frames, "matrix")))
+	}
+	if query.InstantQuery {
+		resultSeries.WithLabelValues(query.DatasourceUID, "instant").Observe(float64(countSeriesOfType(frames, "vector")))
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const compressionMiddlewareName = "prom-compression"
+
+// ResponseCompression controls the compression of responses.
+type ResponseCompression string
+
+const (
+	// ResponseCompressionDefault leaves the Accept-Encoding header to the HTTP
+	// transport, which asks for gzip unless a header is set already.
+	ResponseCompressionDefault ResponseCompression = ""
+	// ResponseCompressionForce always asks for gzip responses, also when
+	// another middleware or proxy header asked for something else.
+	ResponseCompressionForce ResponseCompression = "force"
+	// ResponseCompressionDisable asks for uncompressed responses.
+	ResponseCompressionDisable ResponseCompression = "disable"
+)
+
+// Compression sets the Accept-Encoding header of requests according to
+// responses, and gzips the bodies of POST requests when compressRequests is set.
+// Responses gzipped on request of the middleware are decompressed, as the HTTP
+// transport only does that for the header it sets itself.
+func Compression(logger log.Logger, responses ResponseCompression, compressRequests bool) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc(compressionMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		if responses == ResponseCompressionDefault && !compressRequests {
+			return next
+		}
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+
+			switch responses {
+			case ResponseCompressionForce:
+				req.Header.Set("Accept-Encoding", "gzip")
+			case ResponseCompressionDisable:
+				req.Header.Set("Accept-Encoding", "identity")
+			}
+
+			if compressRequests && req.Method == http.MethodPost && req.Body != nil && req.Header.Get("Content-Encoding") == "" {
+				body, err := gzipBody(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				req.GetBody = func() (io.ReadCloser, error) {
+					return io.NopCloser(bytes.NewReader(body)), nil
+				}
+				req.ContentLength = int64(len(body))
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+
+			res, err := next.RoundTrip(req)
+			if err != nil || responses != ResponseCompressionForce {
+				return res, err
+			}
+			if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") || res.Body == nil {
+				return res, nil
+			}
+			reader, err := gzip.NewReader(res.Body)
+			if err != nil {
+				_ = res.Body.Close()
+				logger.Debug("Invalid gzip response", "url", req.URL.String(), "error", err)
+				return nil, err
+			}
+			res.Body = &gzipBodyReader{Reader: reader, body: res.Body}
+			res.Header.Del("Content-Encoding")
+			res.Header.Del("Content-Length")
+			res.ContentLength = -1
+			res.Uncompressed = true
+			return res, nil
+		})
+	})
+}
+
+func gzipBody(body io.ReadCloser) ([]byte, error) {
+	defer func() { _ = body.Close() }()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := io.Copy(w, body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type gzipBodyReader struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (r *gzipBodyReader) Close() error {
+	_ = r.Reader.Close()
+	return r.body.Close()
+}
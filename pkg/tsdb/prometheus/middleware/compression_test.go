@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	gzipped := func(t *testing.T, s string) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write([]byte(s))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		return buf.Bytes()
+	}
+
+	var sent *http.Request
+	var sentBody []byte
+	setup := func(responses ResponseCompression, compressRequests bool, res func() *http.Response) http.RoundTripper {
+		finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req
+			sentBody = nil
+			if req.Body != nil {
+				b, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+				sentBody = b
+			}
+			if res != nil {
+				return res(), nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+		})
+		return Compression(log.New("test"), responses, compressRequests).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+	}
+
+	post := func(t *testing.T, rt http.RoundTripper) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "http://test.com/api/v1/query", strings.NewReader("query=up"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	t.Run("it has a name", func(t *testing.T) {
+		mw := Compression(log.New("test"), ResponseCompressionForce, false)
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, compressionMiddlewareName, middlewareName.MiddlewareName())
+	})
+
+	t.Run("it asks for and decompresses gzip responses when forced", func(t *testing.T) {
+		body := gzipped(t, `{"status":"success"}`)
+		rt := setup(ResponseCompressionForce, false, func() *http.Response {
+			return &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        http.Header{"Content-Encoding": {"gzip"}, "Content-Length": {"42"}},
+				Body:          io.NopCloser(bytes.NewReader(body)),
+				ContentLength: int64(len(body)),
+			}
+		})
+
+		res := post(t, rt)
+		require.Equal(t, "gzip", sent.Header.Get("Accept-Encoding"))
+		require.Equal(t, "query=up", string(sentBody))
+		require.True(t, res.Uncompressed)
+		require.Empty(t, res.Header.Get("Content-Encoding"))
+		require.Equal(t, int64(-1), res.ContentLength)
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, `{"status":"success"}`, string(b))
+	})
+
+	t.Run("it leaves uncompressed responses alone when forced", func(t *testing.T) {
+		res := post(t, setup(ResponseCompressionForce, false, nil))
+		b, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "{}", string(b))
+		require.False(t, res.Uncompressed)
+	})
+
+	t.Run("it asks for uncompressed responses when disabled", func(t *testing.T) {
+		post(t, setup(ResponseCompressionDisable, false, nil))
+		require.Equal(t, "identity", sent.Header.Get("Accept-Encoding"))
+	})
+
+	t.Run("it compresses POST bodies", func(t *testing.T) {
+		post(t, setup(ResponseCompressionDefault, true, nil))
+		require.Equal(t, "gzip", sent.Header.Get("Content-Encoding"))
+		require.Empty(t, sent.Header.Get("Accept-Encoding"))
+		require.Equal(t, int64(len(sentBody)), sent.ContentLength)
+
+		r, err := gzip.NewReader(bytes.NewReader(sentBody))
+		require.NoError(t, err)
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, "query=up", string(b))
+
+		replayed, err := sent.GetBody()
+		require.NoError(t, err)
+		b, err = io.ReadAll(replayed)
+		require.NoError(t, err)
+		require.Equal(t, sentBody, b)
+	})
+
+	t.Run("it does not compress bodies with an encoding", func(t *testing.T) {
+		rt := setup(ResponseCompressionDefault, true, nil)
+		req, err := http.NewRequest(http.MethodPost, "http://test.com/api/v1/read", strings.NewReader("snappy"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Encoding", "snappy")
+		_, err = rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, "snappy", string(sentBody))
+	})
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 
@@ -14,36 +15,43 @@ const (
 	grafanaDataKey                      = "grafanaData"
 )
 
+type queryParametersContextKey struct{}
+
+// WithCustomQueryParameters returns a context carrying per-query parameters.
+// They are added to every request made with the context, replacing any
+// datasource-level parameter with the same name.
+func WithCustomQueryParameters(ctx context.Context, values url.Values) context.Context {
+	if len(values) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, queryParametersContextKey{}, values)
+}
+
+func customQueryParametersFromContext(ctx context.Context) url.Values {
+	values, _ := ctx.Value(queryParametersContextKey{}).(url.Values)
+	return values
+}
+
 func CustomQueryParameters(logger log.Logger) sdkhttpclient.Middleware {
 	return sdkhttpclient.NamedMiddlewareFunc(customQueryParametersMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
-		grafanaData, exists := opts.CustomOptions[grafanaDataKey]
-		if !exists {
-			return next
-		}
-
-		data, ok := grafanaData.(map[string]interface{})
-		if !ok {
-			return next
-		}
-		customQueryParamsVal, exists := data[customQueryParametersKey]
-		if !exists {
-			return next
-		}
-
-		customQueryParams, ok := customQueryParamsVal.(string)
-		if !ok || customQueryParams == "" {
-			return next
-		}
-
-		values, err := url.ParseQuery(customQueryParams)
-		if err != nil {
-			logger.Error("Failed to parse custom query parameters, skipping middleware", "error", err)
-			return next
-		}
+		values := datasourceQueryParameters(logger, opts)
 
 		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			queryValues := customQueryParametersFromContext(req.Context())
+			if len(values) == 0 && len(queryValues) == 0 {
+				return next.RoundTrip(req)
+			}
+
 			q := req.URL.Query()
 			for k, keyValues := range values {
+				if _, exists := queryValues[k]; exists {
+					continue
+				}
+				for _, value := range keyValues {
+					q.Add(k, value)
+				}
+			}
+			for k, keyValues := range queryValues {
 				for _, value := range keyValues {
 					q.Add(k, value)
 				}
@@ -54,3 +62,31 @@ func CustomQueryParameters(logger log.Logger) sdkhttpclient.Middleware {
 		})
 	})
 }
+
+func datasourceQueryParameters(logger log.Logger, opts sdkhttpclient.Options) url.Values {
+	grafanaData, exists := opts.CustomOptions[grafanaDataKey]
+	if !exists {
+		return nil
+	}
+
+	data, ok := grafanaData.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	customQueryParamsVal, exists := data[customQueryParametersKey]
+	if !exists {
+		return nil
+	}
+
+	customQueryParams, ok := customQueryParamsVal.(string)
+	if !ok || customQueryParams == "" {
+		return nil
+	}
+
+	values, err := url.ParseQuery(customQueryParams)
+	if err != nil {
+		logger.Error("Failed to parse custom query parameters, skipping datasource parameters", "error", err)
+		return nil
+	}
+	return values
+}
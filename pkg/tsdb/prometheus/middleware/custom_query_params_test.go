@@ -168,3 +168,58 @@ func TestCustomQueryParametersMiddleware(t *testing.T) {
 		require.Equal(t, "http://test.com/query?custom=par%2Fam&second=f+oo", req.URL.String())
 	})
 }
+
+func TestCustomQueryParametersMiddlewareWithContext(t *testing.T) {
+	finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+
+	roundTrip := func(t *testing.T, rt http.RoundTripper, values url.Values) *http.Request {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "http://test.com/query?hello=name", nil)
+		require.NoError(t, err)
+		req = req.WithContext(WithCustomQueryParameters(req.Context(), values))
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.NotNil(t, res)
+		if res.Body != nil {
+			require.NoError(t, res.Body.Close())
+		}
+		return req
+	}
+
+	t.Run("Without datasource parameters should apply parameters from context", func(t *testing.T) {
+		rt := CustomQueryParameters(log.New("test")).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+
+		req := roundTrip(t, rt, url.Values{"dedup": []string{"false"}})
+
+		require.Equal(t, "http://test.com/query?dedup=false&hello=name", req.URL.String())
+	})
+
+	t.Run("Parameters from context should replace datasource parameters with the same name", func(t *testing.T) {
+		rt := CustomQueryParameters(log.New("test")).CreateMiddleware(httpclient.Options{
+			CustomOptions: map[string]interface{}{
+				grafanaDataKey: map[string]interface{}{
+					customQueryParametersKey: "timeout=10s&dedup=true",
+				},
+			},
+		}, finalRoundTripper)
+
+		req := roundTrip(t, rt, url.Values{"timeout": []string{"1m"}, "max_source_resolution": []string{"5m"}})
+
+		q := req.URL.Query()
+		require.Len(t, q, 4)
+		require.Equal(t, []string{"1m"}, q["timeout"])
+		require.Equal(t, []string{"true"}, q["dedup"])
+		require.Equal(t, []string{"5m"}, q["max_source_resolution"])
+		require.Equal(t, []string{"name"}, q["hello"])
+	})
+
+	t.Run("Without parameters in context should leave the request untouched", func(t *testing.T) {
+		rt := CustomQueryParameters(log.New("test")).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+
+		req := roundTrip(t, rt, nil)
+
+		require.Equal(t, "http://test.com/query?hello=name", req.URL.String())
+	})
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const failoverMiddlewareName = "prom-failover"
+
+type servedByContextKey struct{}
+
+// ServedBy records which backend served the requests made with a context
+// returned by WithServedBy.
+type ServedBy struct {
+	mu        sync.Mutex
+	url       string
+	secondary bool
+}
+
+// WithServedBy returns a context recording the backend serving its requests
+// when the data source has secondary URLs.
+func WithServedBy(ctx context.Context) (context.Context, *ServedBy) {
+	servedBy := &ServedBy{}
+	return context.WithValue(ctx, servedByContextKey{}, servedBy), servedBy
+}
+
+// URL returns the URL of the backend that served the requests, without
+// credentials. A secondary backend is reported when it served any request.
+// It is empty when nothing has been recorded.
+func (s *ServedBy) URL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.url
+}
+
+// Secondary reports whether a secondary backend served any request.
+func (s *ServedBy) Secondary() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.secondary
+}
+
+func (s *ServedBy) record(u *url.URL, secondary bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.secondary && !secondary {
+		return
+	}
+	s.url = redactedURL(u)
+	s.secondary = secondary
+}
+
+func redactedURL(u *url.URL) string {
+	redacted := *u
+	redacted.User = nil
+	return redacted.String()
+}
+
+// Failover sends requests that fail with a network error or a server error to
+// the secondary URLs in turn, replacing the primary URL the requests are made
+// to. It is meant for HA pairs of Prometheus servers without a load balancer in
+// front of them. Requests with a body that cannot be replayed are sent only to
+// the primary URL. Invalid secondary URLs are logged and ignored.
+func Failover(logger log.Logger, primary string, secondaries []string) sdkhttpclient.Middleware {
+	primaryURL, err := url.Parse(strings.TrimSuffix(primary, "/"))
+	if err != nil {
+		logger.Warn("Invalid primary URL, failover is disabled", "url", primary, "error", err)
+		primaryURL = nil
+	}
+	var secondaryURLs []*url.URL
+	for _, s := range secondaries {
+		u, err := url.Parse(strings.TrimSuffix(s, "/"))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			logger.Warn("Ignoring invalid secondary URL", "url", s)
+			continue
+		}
+		secondaryURLs = append(secondaryURLs, u)
+	}
+
+	return sdkhttpclient.NamedMiddlewareFunc(failoverMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		if primaryURL == nil || len(secondaryURLs) == 0 {
+			return next
+		}
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			servedBy, _ := req.Context().Value(servedByContextKey{}).(*ServedBy)
+
+			res, err := next.RoundTrip(req)
+			for i := 0; failoverNeeded(res, err) && i < len(secondaryURLs); i++ {
+				target, ok := rebaseURL(req.URL, primaryURL, secondaryURLs[i])
+				if !ok || (req.Body != nil && req.GetBody == nil) {
+					break
+				}
+				if err != nil {
+					logger.Warn("Request failed, failing over", "url", redactedURL(req.URL), "to", redactedURL(secondaryURLs[i]), "error", err)
+				} else {
+					logger.Warn("Request failed, failing over", "url", redactedURL(req.URL), "to", redactedURL(secondaryURLs[i]), "status", res.StatusCode)
+					if res.Body != nil {
+						_ = res.Body.Close()
+					}
+				}
+
+				secondaryReq := req.Clone(req.Context())
+				secondaryReq.URL = target
+				secondaryReq.Host = ""
+				if req.GetBody != nil {
+					body, bodyErr := req.GetBody()
+					if bodyErr != nil {
+						return nil, bodyErr
+					}
+					secondaryReq.Body = body
+				}
+				res, err = next.RoundTrip(secondaryReq)
+				if servedBy != nil && !failoverNeeded(res, err) {
+					servedBy.record(secondaryURLs[i], true)
+					return res, err
+				}
+			}
+			if servedBy != nil && !failoverNeeded(res, err) {
+				servedBy.record(primaryURL, false)
+			}
+			return res, err
+		})
+	})
+}
+
+func failoverNeeded(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	return res.StatusCode >= http.StatusInternalServerError
+}
+
+// rebaseURL moves u from under the base URL from to under the base URL to. It
+// returns false when u is not under from.
+func rebaseURL(u *url.URL, from *url.URL, to *url.URL) (*url.URL, bool) {
+	if u.Scheme != from.Scheme || u.Host != from.Host || !strings.HasPrefix(u.Path, from.Path) {
+		return nil, false
+	}
+	rebased := *u
+	rebased.Scheme = to.Scheme
+	rebased.Host = to.Host
+	rebased.User = to.User
+	rebased.Path = to.Path + strings.TrimPrefix(u.Path, from.Path)
+	rebased.RawPath = ""
+	return &rebased, true
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// queryArgs are the arguments of the query endpoints moved to the body of POST
+// requests. Other parameters, like custom query parameters, stay in the URL.
+var queryArgs = []string{"query", "time", "start", "end", "step", "timeout"}
+
+// ForceHttpPost sends the GET requests to the query endpoints as POST requests,
+// with their arguments in the form body, so long expressions don't exceed URL
+// length limits. The prometheus library POSTs range and instant queries already,
+// but always GETs exemplars.
+func ForceHttpPost(logger log.Logger) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc("force-http-post", func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if _, ok := QueryType(req); !ok || req.Method != http.MethodGet {
+				return next.RoundTrip(req)
+			}
+
+			params := req.URL.Query()
+			form := url.Values{}
+			for _, name := range queryArgs {
+				if values, ok := params[name]; ok {
+					form[name] = values
+					params.Del(name)
+				}
+			}
+			body := form.Encode()
+
+			post := req.Clone(req.Context())
+			post.Method = http.MethodPost
+			post.URL.RawQuery = params.Encode()
+			post.Body = io.NopCloser(strings.NewReader(body))
+			post.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(body)), nil
+			}
+			post.ContentLength = int64(len(body))
+			post.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return next.RoundTrip(post)
+		})
+	})
+}
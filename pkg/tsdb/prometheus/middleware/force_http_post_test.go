@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForceHttpPostMiddleware(t *testing.T) {
+	t.Run("Name should be correct", func(t *testing.T) {
+		mw := ForceHttpPost(log.New("test"))
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, "force-http-post", middlewareName.MiddlewareName())
+	})
+
+	t.Run("Should POST the arguments of query requests", func(t *testing.T) {
+		var sent *http.Request
+		var body string
+		finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req
+			b, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			body = string(b)
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})
+		rt := ForceHttpPost(log.New("test")).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus/api/v1/query_exemplars?query=up&start=1&end=2&dedup=true", nil)
+		require.NoError(t, err)
+		_, err = rt.RoundTrip(req)
+		require.NoError(t, err)
+
+		require.Equal(t, http.MethodPost, sent.Method)
+		require.Equal(t, "dedup=true", sent.URL.RawQuery)
+		require.Equal(t, "application/x-www-form-urlencoded", sent.Header.Get("Content-Type"))
+		require.Equal(t, "end=2&query=up&start=1", body)
+		require.Equal(t, http.MethodGet, req.Method)
+	})
+
+	t.Run("Should not change other requests", func(t *testing.T) {
+		var sent *http.Request
+		finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		})
+		rt := ForceHttpPost(log.New("test")).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus/api/v1/labels?match[]=up", nil)
+		require.NoError(t, err)
+		_, err = rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Same(t, req, sent)
+	})
+}
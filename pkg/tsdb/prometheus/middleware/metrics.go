@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/httpclient"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsMiddlewareName = "prom-metrics"
+
+var (
+	queryRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "prometheus_datasource",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the query requests sent to Prometheus data sources",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"datasource_uid", "query_type"})
+
+	queryResponseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "prometheus_datasource",
+		Name:      "response_size_bytes",
+		Help:      "Size of the query responses of Prometheus data sources",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 9),
+	}, []string{"datasource_uid", "query_type"})
+
+	queryRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "grafana",
+		Subsystem: "prometheus_datasource",
+		Name:      "request_errors_total",
+		Help:      "The number of query requests to Prometheus data sources that failed or got an error response",
+	}, []string{"datasource_uid", "query_type"})
+)
+
+func init() {
+	prometheus.MustRegister(queryRequestDuration, queryResponseSize, queryRequestErrors)
+}
+
+// queryTypes maps the query endpoints of the Prometheus API to the query types
+// used as metric labels.
+var queryTypes = map[string]string{
+	"/api/v1/query_range":     "range",
+	"/api/v1/query":           "instant",
+	"/api/v1/query_exemplars": "exemplar",
+}
+
+// QueryType returns the query type of a request to the Prometheus API, and
+// false when it is not a query request, e.g. a request for label values.
+func QueryType(r *http.Request) (string, bool) {
+	for path, queryType := range queryTypes {
+		if strings.HasSuffix(r.URL.Path, path) {
+			return queryType, true
+		}
+	}
+	return "", false
+}
+
+// Metrics records the duration, response size and errors of the query requests
+// of the data source with the given UID, by query type. A request that is
+// retried is measured once, including all of its attempts.
+func Metrics(datasourceUID string) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc(metricsMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			queryType, ok := QueryType(r)
+			if !ok {
+				return next.RoundTrip(r)
+			}
+
+			start := time.Now()
+			res, err := next.RoundTrip(r)
+			queryRequestDuration.WithLabelValues(datasourceUID, queryType).Observe(time.Since(start).Seconds())
+			if err != nil || res.StatusCode >= http.StatusBadRequest {
+				queryRequestErrors.WithLabelValues(datasourceUID, queryType).Inc()
+			}
+			if err != nil || res.Body == nil {
+				return res, err
+			}
+
+			responseSize := queryResponseSize.WithLabelValues(datasourceUID, queryType)
+			res.Body = httpclient.CountBytesReader(res.Body, func(bytesRead int64) {
+				responseSize.Observe(float64(bytesRead))
+			})
+			return res, nil
+		})
+	})
+}
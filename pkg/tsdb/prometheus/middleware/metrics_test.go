@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsMiddleware(t *testing.T) {
+	t.Run("Name should be correct", func(t *testing.T) {
+		mw := Metrics("uid")
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, "prom-metrics", middlewareName.MiddlewareName())
+	})
+
+	t.Run("Should detect the query type from the path", func(t *testing.T) {
+		for path, expected := range map[string]string{
+			"http://prometheus/api/v1/query_range":            "range",
+			"http://prometheus/api/v1/query":                  "instant",
+			"http://prometheus/prefix/api/v1/query_exemplars": "exemplar",
+		} {
+			req, err := http.NewRequest(http.MethodGet, path, nil)
+			require.NoError(t, err)
+			queryType, ok := QueryType(req)
+			require.True(t, ok)
+			require.Equal(t, expected, queryType)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus/api/v1/labels", nil)
+		require.NoError(t, err)
+		_, ok := QueryType(req)
+		require.False(t, ok)
+	})
+
+	t.Run("Should count errors and response sizes by query type", func(t *testing.T) {
+		status := http.StatusOK
+		finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("response"))}, nil
+		})
+		rt := Metrics("metrics-test").CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+
+		roundTrip := func(path string) {
+			req, err := http.NewRequest(http.MethodPost, "http://prometheus"+path, nil)
+			require.NoError(t, err)
+			res, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+			_, err = io.ReadAll(res.Body)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+		}
+
+		roundTrip("/api/v1/query_range")
+		status = http.StatusServiceUnavailable
+		roundTrip("/api/v1/query_range")
+		roundTrip("/api/v1/labels")
+
+		require.Equal(t, 1.0, testutil.ToFloat64(queryRequestErrors.WithLabelValues("metrics-test", "range")))
+		require.Equal(t, 0.0, testutil.ToFloat64(queryRequestErrors.WithLabelValues("metrics-test", "instant")))
+		// The labels request is not a query, so there are only range response sizes.
+		require.Equal(t, 1, testutil.CollectAndCount(queryResponseSize))
+	})
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/prometheus/common/model"
+)
+
+const queryTimeoutMiddlewareName = "prom-query-timeout"
+
+type queryTimeoutContextKey struct{}
+
+// WithQueryTimeout returns a context carrying the timeout of a single query.
+// It replaces the timeout of the data source for every request made with the
+// context.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	if timeout <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, queryTimeoutContextKey{}, timeout)
+}
+
+func queryTimeoutFromContext(ctx context.Context) time.Duration {
+	timeout, _ := ctx.Value(queryTimeoutContextKey{}).(time.Duration)
+	return timeout
+}
+
+// QueryTimeout limits the wait for the response headers of each request to
+// the timeout of the data source, like the ResponseHeaderTimeout of the
+// transport it replaces. Query requests whose context carries a query timeout
+// get a deadline of that timeout instead, covering the whole request, and
+// range and instant queries also pass it to Prometheus as the timeout
+// parameter, so the evaluation is aborted on the server as well. The transport
+// of the data source must not set a timeout of its own, or it would cut longer
+// query timeouts short.
+func QueryTimeout(datasourceTimeout time.Duration) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc(queryTimeoutMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			queryType, isQuery := QueryType(req)
+			if timeout := queryTimeoutFromContext(req.Context()); timeout > 0 && isQuery {
+				if queryType != "exemplar" {
+					req = req.Clone(req.Context())
+					q := req.URL.Query()
+					q.Set("timeout", model.Duration(timeout).String())
+					req.URL.RawQuery = q.Encode()
+				}
+				ctx, cancel := context.WithTimeout(req.Context(), timeout)
+				res, err := next.RoundTrip(req.WithContext(ctx))
+				return cancelAfterBody(res, err, cancel)
+			}
+			if datasourceTimeout <= 0 {
+				return next.RoundTrip(req)
+			}
+
+			ctx, cancel := context.WithCancel(req.Context())
+			timer := time.AfterFunc(datasourceTimeout, cancel)
+			res, err := next.RoundTrip(req.WithContext(ctx))
+			if !timer.Stop() && err != nil {
+				err = fmt.Errorf("timeout awaiting response headers after %s: %w", datasourceTimeout, err)
+			}
+			return cancelAfterBody(res, err, cancel)
+		})
+	})
+}
+
+// cancelAfterBody releases the context of a request with cancel once the body
+// of its response is closed, or right away if there is no body to read.
+func cancelAfterBody(res *http.Response, err error, cancel context.CancelFunc) (*http.Response, error) {
+	if err != nil || res.Body == nil {
+		cancel()
+		return res, err
+	}
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	return res, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
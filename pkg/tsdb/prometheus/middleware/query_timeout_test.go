@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryTimeoutMiddleware(t *testing.T) {
+	var sent *http.Request
+	finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	roundTrip := func(t *testing.T, ctx context.Context, url string) time.Duration {
+		t.Helper()
+		mw := QueryTimeout(30 * time.Second)
+		rt := mw.CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, queryTimeoutMiddlewareName, middlewareName.MiddlewareName())
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		require.NoError(t, err)
+		start := time.Now()
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		deadline, ok := sent.Context().Deadline()
+		require.True(t, ok)
+		require.NoError(t, res.Body.Close())
+		require.Error(t, sent.Context().Err(), "closing the body releases the deadline")
+		return deadline.Sub(start)
+	}
+
+	t.Run("it applies and sends the query timeout", func(t *testing.T) {
+		ctx := WithQueryTimeout(context.Background(), 2*time.Minute)
+		timeout := roundTrip(t, ctx, "http://test.com/api/v1/query_range?query=up")
+		require.InDelta(t, 2*time.Minute, timeout, float64(time.Second))
+		require.Equal(t, "2m", sent.URL.Query().Get("timeout"))
+		require.Equal(t, "up", sent.URL.Query().Get("query"))
+	})
+
+	t.Run("it does not send the timeout parameter to other endpoints", func(t *testing.T) {
+		ctx := WithQueryTimeout(context.Background(), 2*time.Minute)
+		timeout := roundTrip(t, ctx, "http://test.com/api/v1/query_exemplars?query=up")
+		require.InDelta(t, 2*time.Minute, timeout, float64(time.Second))
+		require.Empty(t, sent.URL.Query().Get("timeout"))
+	})
+}
+
+func TestQueryTimeoutMiddleware_ResponseHeaders(t *testing.T) {
+	roundTrip := func(t *testing.T, ctx context.Context, url string, headerDelay time.Duration) (*http.Response, *http.Request, error) {
+		t.Helper()
+		var sent *http.Request
+		rt := QueryTimeout(50*time.Millisecond).CreateMiddleware(httpclient.Options{}, httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req
+			select {
+			case <-time.After(headerDelay):
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		require.NoError(t, err)
+		res, err := rt.RoundTrip(req)
+		return res, sent, err
+	}
+
+	t.Run("it limits the wait for the response headers to the data source timeout", func(t *testing.T) {
+		_, _, err := roundTrip(t, context.Background(), "http://test.com/api/v1/query_range?query=up", time.Second)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Contains(t, err.Error(), "timeout awaiting response headers after 50ms")
+	})
+
+	t.Run("it does not limit reading the body", func(t *testing.T) {
+		res, sent, err := roundTrip(t, context.Background(), "http://test.com/api/v1/labels", 0)
+		require.NoError(t, err)
+		_, ok := sent.Context().Deadline()
+		require.False(t, ok, "there is no deadline on the whole request")
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, sent.Context().Err(), "the body can be read past the data source timeout")
+		require.NoError(t, res.Body.Close())
+		require.Error(t, sent.Context().Err(), "closing the body releases the context")
+	})
+
+	t.Run("it applies the query timeout to query endpoints only", func(t *testing.T) {
+		ctx := WithQueryTimeout(context.Background(), 2*time.Minute)
+		res, sent, err := roundTrip(t, ctx, "http://test.com/api/v1/labels", 0)
+		require.NoError(t, err)
+		_, ok := sent.Context().Deadline()
+		require.False(t, ok)
+		require.Empty(t, sent.URL.Query().Get("timeout"))
+		require.NoError(t, res.Body.Close())
+
+		_, _, err = roundTrip(t, ctx, "http://test.com/api/v1/query?query=up", 100*time.Millisecond)
+		require.NoError(t, err, "the query timeout replaces the data source timeout")
+	})
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const redirectMiddlewareName = "prom-redirect"
+
+// Redirect follows at most maxRedirects redirects to the same host, keeping the
+// method, body and headers (including auth headers) of the original request.
+// Redirects to a different host are never followed, so credentials are not
+// leaked; an error naming the target host is returned instead.
+func Redirect(logger log.Logger, maxRedirects int) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc(redirectMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for redirects := 0; ; redirects++ {
+				res, err := next.RoundTrip(req)
+				if err != nil || !isRedirect(res.StatusCode) {
+					return res, err
+				}
+
+				location, err := res.Location()
+				if err != nil {
+					// Without a usable Location header there is nothing to follow.
+					return res, nil
+				}
+				if res.Body != nil {
+					_ = res.Body.Close()
+				}
+
+				if location.Host != req.URL.Host {
+					return nil, fmt.Errorf("prometheus redirected the request to a different host %q, which is not followed", location.Host)
+				}
+				if redirects >= maxRedirects {
+					return nil, fmt.Errorf("prometheus redirected the request more than %d times", maxRedirects)
+				}
+
+				logger.Debug("Following redirect", "from", req.URL.String(), "to", location.String())
+				if req, err = redirectRequest(req, location.String()); err != nil {
+					return nil, err
+				}
+			}
+		})
+	})
+}
+
+func isRedirect(statusCode int) bool {
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func redirectRequest(req *http.Request, location string) (*http.Request, error) {
+	redirected, err := http.NewRequestWithContext(req.Context(), req.Method, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	redirected.Header = req.Header.Clone()
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		redirected.Body = body
+		redirected.GetBody = req.GetBody
+		redirected.ContentLength = req.ContentLength
+	}
+
+	return redirected, nil
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectMiddleware(t *testing.T) {
+	redirectingRoundTripper := func(requests *[]*http.Request, location string) http.RoundTripper {
+		return httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			*requests = append(*requests, req)
+			if req.URL.Path == "/api/v1/query" {
+				return &http.Response{
+					StatusCode: http.StatusTemporaryRedirect,
+					Header:     http.Header{"Location": []string{location}},
+					Body:       io.NopCloser(strings.NewReader("")),
+					Request:    req,
+				}, nil
+			}
+			return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+		})
+	}
+
+	newRequest := func(t *testing.T) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://prometheus:9090/api/v1/query", strings.NewReader("query=up"))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		return req
+	}
+
+	t.Run("Name should be correct", func(t *testing.T) {
+		mw := Redirect(log.New("test"), 1)
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, "prom-redirect", middlewareName.MiddlewareName())
+	})
+
+	t.Run("Should follow a redirect to the same host preserving auth headers and body", func(t *testing.T) {
+		var requests []*http.Request
+		rt := Redirect(log.New("test"), 1).CreateMiddleware(httpclient.Options{}, redirectingRoundTripper(&requests, "/prometheus/api/v1/query"))
+
+		res, err := rt.RoundTrip(newRequest(t))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		require.Len(t, requests, 2)
+		redirected := requests[1]
+		require.Equal(t, "http://prometheus:9090/prometheus/api/v1/query", redirected.URL.String())
+		require.Equal(t, http.MethodPost, redirected.Method)
+		require.Equal(t, "Bearer token", redirected.Header.Get("Authorization"))
+		body, err := io.ReadAll(redirected.Body)
+		require.NoError(t, err)
+		require.Equal(t, "query=up", string(body))
+	})
+
+	t.Run("Should fail when redirected to a different host", func(t *testing.T) {
+		var requests []*http.Request
+		rt := Redirect(log.New("test"), 1).CreateMiddleware(httpclient.Options{}, redirectingRoundTripper(&requests, "http://other:9090/api/v1/query"))
+
+		_, err := rt.RoundTrip(newRequest(t))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "other:9090")
+		require.Len(t, requests, 1)
+	})
+
+	t.Run("Should fail when exceeding the redirect limit", func(t *testing.T) {
+		var requests []*http.Request
+		rt := Redirect(log.New("test"), 2).CreateMiddleware(httpclient.Options{}, redirectingRoundTripper(&requests, "/api/v1/query"))
+
+		_, err := rt.RoundTrip(newRequest(t))
+		require.Error(t, err)
+		require.Len(t, requests, 3)
+	})
+}
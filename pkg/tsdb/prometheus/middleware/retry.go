@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+const retryMiddlewareName = "prom-retry"
+
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy decides which failed requests are retried and how long to wait
+// in between. Network errors, like connection resets, are always retriable,
+// except for a cancelled or expired request context. Of the responses only 429
+// and server errors are: StatusCodes narrows them down to the listed codes,
+// 429, 502, 503 and 504 when empty. Other client errors mean the query itself
+// is bad and are never retried, whatever StatusCodes holds.
+//
+// The first retry waits Backoff, every further one twice as long as the one
+// before, up to MaxBackoff. A Retry-After header asking for a longer wait is
+// honored, also up to MaxBackoff.
+type RetryPolicy struct {
+	MaxRetries  int
+	StatusCodes []int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Retriable reports whether the outcome of a round trip should be retried.
+func (p RetryPolicy) Retriable(res *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < http.StatusInternalServerError {
+		return false
+	}
+	codes := p.StatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryStatusCodes
+	}
+	for _, code := range codes {
+		if code == res.StatusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// Delay returns how long to wait before the given retry, counting from zero.
+func (p RetryPolicy) Delay(retry int, res *http.Response) time.Duration {
+	delay := p.Backoff
+	for i := 0; i < retry && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			if retryAfter := time.Duration(seconds) * time.Second; retryAfter > delay {
+				delay = retryAfter
+			}
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay
+}
+
+// Retry repeats requests failing in a way the policy considers retriable, up
+// to policy.MaxRetries times. Requests with a body that cannot be replayed are
+// sent only once. Waiting for the next attempt stops when the request context
+// is done.
+func Retry(logger log.Logger, policy RetryPolicy) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc(retryMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			for retries := 0; ; retries++ {
+				res, err := next.RoundTrip(req)
+				if retries >= policy.MaxRetries || !policy.Retriable(res, err) {
+					return res, err
+				}
+				if req.Body != nil && req.GetBody == nil {
+					return res, err
+				}
+
+				delay := policy.Delay(retries, res)
+				if err != nil {
+					logger.Debug("Retrying request after error", "url", req.URL.String(), "error", err, "retry", retries+1, "delay", delay)
+				} else {
+					logger.Debug("Retrying request after server error", "url", req.URL.String(), "status", res.StatusCode, "retry", retries+1, "delay", delay)
+					if res.Body != nil {
+						_ = res.Body.Close()
+					}
+				}
+
+				if err := wait(req.Context(), delay); err != nil {
+					return nil, err
+				}
+
+				if req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+			}
+		})
+	})
+}
+
+func wait(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,173 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryMiddleware(t *testing.T) {
+	roundTrip := func(t *testing.T, policy RetryPolicy, req *http.Request, results ...func() (*http.Response, error)) (*http.Response, int, error) {
+		t.Helper()
+		calls := 0
+		finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			result := results[len(results)-1]
+			if calls < len(results) {
+				result = results[calls]
+			}
+			calls++
+			return result()
+		})
+		rt := Retry(log.New("test"), policy).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+		res, err := rt.RoundTrip(req)
+		return res, calls - 1, err
+	}
+	status := func(code int) func() (*http.Response, error) {
+		return func() (*http.Response, error) {
+			return &http.Response{StatusCode: code, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		}
+	}
+
+	t.Run("Name should be correct", func(t *testing.T) {
+		mw := Retry(log.New("test"), RetryPolicy{})
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, "prom-retry", middlewareName.MiddlewareName())
+	})
+
+	t.Run("it does not retry a 400", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, retries, err := roundTrip(t, RetryPolicy{MaxRetries: 3}, req, status(http.StatusBadRequest), status(http.StatusOK))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, 0, retries)
+	})
+
+	t.Run("it does not retry a 4xx even when configured", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		policy := RetryPolicy{MaxRetries: 3, StatusCodes: []int{http.StatusBadRequest}}
+		res, retries, err := roundTrip(t, policy, req, status(http.StatusBadRequest), status(http.StatusOK))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadRequest, res.StatusCode)
+		require.Equal(t, 0, retries)
+	})
+
+	t.Run("it retries a 503", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "http://prometheus:9090/api/v1/query", strings.NewReader("query=up"))
+		require.NoError(t, err)
+
+		res, retries, err := roundTrip(t, RetryPolicy{MaxRetries: 3}, req, status(http.StatusServiceUnavailable), status(http.StatusOK))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 1, retries)
+	})
+
+	t.Run("it only retries the configured server errors", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		policy := RetryPolicy{MaxRetries: 3, StatusCodes: []int{http.StatusServiceUnavailable}}
+		res, retries, err := roundTrip(t, policy, req, status(http.StatusInternalServerError), status(http.StatusOK))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		require.Equal(t, 0, retries)
+	})
+
+	t.Run("it gives up after max retries", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		calls := 0
+		finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return status(http.StatusBadGateway)()
+		})
+		rt := Retry(log.New("test"), RetryPolicy{MaxRetries: 2}).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusBadGateway, res.StatusCode)
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("it retries network errors but not cancellations", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		networkErr := func() (*http.Response, error) { return nil, errors.New("connection reset by peer") }
+		res, retries, err := roundTrip(t, RetryPolicy{MaxRetries: 3}, req, networkErr, status(http.StatusOK))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 1, retries)
+
+		canceled := func() (*http.Response, error) { return nil, context.Canceled }
+		_, retries, err = roundTrip(t, RetryPolicy{MaxRetries: 3}, req, canceled, status(http.StatusOK))
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 0, retries)
+	})
+
+	t.Run("it retries a 429 but not a 500 by default", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		res, retries, err := roundTrip(t, RetryPolicy{MaxRetries: 3}, req, status(http.StatusTooManyRequests), status(http.StatusOK))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, 1, retries)
+
+		res, retries, err = roundTrip(t, RetryPolicy{MaxRetries: 3}, req, status(http.StatusInternalServerError), status(http.StatusOK))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		require.Equal(t, 0, retries)
+	})
+
+	t.Run("it stops waiting for the next attempt when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://prometheus:9090/api/v1/query", nil)
+		require.NoError(t, err)
+
+		failAndCancel := func() (*http.Response, error) {
+			cancel()
+			return status(http.StatusServiceUnavailable)()
+		}
+		policy := RetryPolicy{MaxRetries: 3, Backoff: time.Hour}
+		_, retries, err := roundTrip(t, policy, req, failAndCancel, status(http.StatusOK))
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 0, retries)
+	})
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	t.Run("it doubles the backoff up to the maximum", func(t *testing.T) {
+		require.Equal(t, 100*time.Millisecond, policy.Delay(0, nil))
+		require.Equal(t, 200*time.Millisecond, policy.Delay(1, nil))
+		require.Equal(t, 800*time.Millisecond, policy.Delay(3, nil))
+		require.Equal(t, time.Second, policy.Delay(4, nil))
+		require.Equal(t, time.Second, policy.Delay(60, nil))
+	})
+
+	t.Run("it honors Retry-After up to the maximum", func(t *testing.T) {
+		res := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+		res.Header.Set("Retry-After", "1")
+		require.Equal(t, time.Second, policy.Delay(0, res))
+
+		res.Header.Set("Retry-After", "120")
+		require.Equal(t, time.Second, policy.Delay(0, res))
+
+		res.Header.Set("Retry-After", "Wed, 21 Oct 2015 07:28:00 GMT")
+		require.Equal(t, 100*time.Millisecond, policy.Delay(0, res))
+	})
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+)
+
+const serverClockMiddlewareName = "prom-server-clock"
+
+// ServerClock tells the current time of the Prometheus server from the Date
+// header of its last response.
+type ServerClock struct {
+	mu     sync.RWMutex
+	offset time.Duration
+	known  bool
+	now    func() time.Time
+}
+
+func NewServerClock() *ServerClock {
+	return &ServerClock{now: time.Now}
+}
+
+// Now returns the current time of the Prometheus server, or the one of the
+// Grafana server as long as no response with a Date header was received.
+func (c *ServerClock) Now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now().Add(c.offset)
+}
+
+// Known reports whether the time of the Prometheus server is known.
+func (c *ServerClock) Known() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.known
+}
+
+func (c *ServerClock) observe(res *http.Response) {
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// The Date header has a resolution of a second, the server time is
+	// somewhere in the second after it.
+	offset := date.Add(500 * time.Millisecond).Sub(c.now())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = offset
+	c.known = true
+}
+
+// ServerTime updates the clock with the Date header of every
+// response.
+func ServerTime(clock *ServerClock) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc(serverClockMiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			res, err := next.RoundTrip(req)
+			if err == nil && res != nil {
+				clock.observe(res)
+			}
+			return res, err
+		})
+	})
+}
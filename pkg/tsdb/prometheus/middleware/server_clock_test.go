@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTimeMiddleware(t *testing.T) {
+	t.Run("Name should be correct", func(t *testing.T) {
+		mw := ServerTime(NewServerClock())
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, "prom-server-clock", middlewareName.MiddlewareName())
+	})
+
+	t.Run("Should tell the time of the server from the Date header", func(t *testing.T) {
+		local := time.Date(2022, 3, 10, 12, 0, 0, 0, time.UTC)
+		clock := NewServerClock()
+		clock.now = func() time.Time { return local }
+		require.False(t, clock.Known())
+		require.Equal(t, local, clock.Now())
+
+		date := ""
+		finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			if date != "" {
+				header.Set("Date", date)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: header}, nil
+		})
+		rt := ServerTime(clock).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+		roundTrip := func() {
+			req, err := http.NewRequest(http.MethodGet, "http://prometheus/api/v1/query_range", nil)
+			require.NoError(t, err)
+			_, err = rt.RoundTrip(req)
+			require.NoError(t, err)
+		}
+
+		roundTrip()
+		require.False(t, clock.Known())
+
+		date = local.Add(-time.Minute).Format(http.TimeFormat)
+		roundTrip()
+		require.True(t, clock.Known())
+		require.Equal(t, local.Add(-time.Minute+500*time.Millisecond), clock.Now())
+
+		local = local.Add(time.Hour)
+		require.Equal(t, local.Add(-time.Minute+500*time.Millisecond), clock.Now())
+
+		date = "yesterday"
+		roundTrip()
+		require.Equal(t, local.Add(-time.Minute+500*time.Millisecond), clock.Now())
+	})
+
+	t.Run("Should use the local time without a clock", func(t *testing.T) {
+		var clock *ServerClock
+		require.False(t, clock.Known())
+		require.WithinDuration(t, time.Now(), clock.Now(), time.Second)
+	})
+}
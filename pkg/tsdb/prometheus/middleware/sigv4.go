@@ -0,0 +1,250 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol/rest"
+	"github.com/grafana/grafana-aws-sdk/pkg/awsds"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const sigV4MiddlewareName = "prom-sigv4"
+
+// sigV4ExpiryWindow is how long before they expire assumed-role credentials are
+// refreshed, so that no request is signed with credentials about to expire.
+const sigV4ExpiryWindow = time.Minute
+
+// sigV4PermittedHeaders are the headers kept when signing, all others are
+// removed from the request like the SigV4 middleware of the HTTP client
+// provider does.
+var sigV4PermittedHeaders = map[string]struct{}{
+	"Host":            {},
+	"Uber-Trace-Id":   {},
+	"User-Agent":      {},
+	"Accept":          {},
+	"Accept-Encoding": {},
+	"Content-Type":    {},
+	"Content-Length":  {},
+}
+
+// sigV4CredentialsCacheSize bounds the credentials kept per data source, one
+// set per region queries sign their requests for.
+const sigV4CredentialsCacheSize = 32
+
+// SigV4Options overrides the region a query signs its requests for. The
+// external ID of the assumed role is always the one of the data source, so
+// that queries can't assume the role on behalf of another tenant.
+type SigV4Options struct {
+	Region string
+}
+
+type sigV4ContextKey struct{}
+
+// WithSigV4Options returns a context carrying the SigV4 options of a single
+// query.
+func WithSigV4Options(ctx context.Context, options SigV4Options) context.Context {
+	if options == (SigV4Options{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, sigV4ContextKey{}, options)
+}
+
+func sigV4OptionsFromContext(ctx context.Context) SigV4Options {
+	options, _ := ctx.Value(sigV4ContextKey{}).(SigV4Options)
+	return options
+}
+
+// SigV4Credentials caches the AWS credentials of a data source, one set per
+// region, up to sigV4CredentialsCacheSize. Credentials of assumed roles are
+// only requested from STS again shortly before they expire, instead of for
+// every request.
+type SigV4Credentials struct {
+	mu          sync.Mutex
+	credentials *lru.Cache
+	// newCredentials is replaced in tests.
+	newCredentials func(config sigV4Config) (*credentials.Credentials, error)
+}
+
+func NewSigV4Credentials() *SigV4Credentials {
+	// lru.New only fails for a non-positive size.
+	cache, _ := lru.New(sigV4CredentialsCacheSize)
+	return &SigV4Credentials{
+		credentials:    cache,
+		newCredentials: newSigV4Credentials,
+	}
+}
+
+func (c *SigV4Credentials) get(config sigV4Config) (*credentials.Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if creds, ok := c.credentials.Get(config); ok {
+		return creds.(*credentials.Credentials), nil
+	}
+	creds, err := c.newCredentials(config)
+	if err != nil {
+		return nil, err
+	}
+	c.credentials.Add(config, creds)
+	return creds, nil
+}
+
+type sigV4Config struct {
+	authType      string
+	profile       string
+	accessKey     string
+	secretKey     string
+	assumeRoleARN string
+	externalID    string
+	region        string
+}
+
+// SigV4 signs requests with AWS Signature Version 4 like the SigV4 middleware
+// of the HTTP client provider, which it replaces, but takes the credentials
+// from creds so that they are reused across requests. A query can sign its
+// requests for another region with WithSigV4Options.
+func SigV4(creds *SigV4Credentials) sdkhttpclient.Middleware {
+	return sdkhttpclient.NamedMiddlewareFunc(sigV4MiddlewareName, func(opts sdkhttpclient.Options, next http.RoundTripper) http.RoundTripper {
+		if opts.SigV4 == nil {
+			return next
+		}
+		settings := *opts.SigV4
+
+		return sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			config := sigV4Config{
+				authType:      settings.AuthType,
+				profile:       settings.Profile,
+				accessKey:     settings.AccessKey,
+				secretKey:     settings.SecretKey,
+				assumeRoleARN: settings.AssumeRoleARN,
+				externalID:    settings.ExternalID,
+				region:        settings.Region,
+			}
+			if options := sigV4OptionsFromContext(req.Context()); options.Region != "" {
+				config.region = options.Region
+			}
+
+			c, err := creds.get(config)
+			if err != nil {
+				return nil, err
+			}
+			req, err = signSigV4(req, v4.NewSigner(c), settings.Service, config.region)
+			if err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	})
+}
+
+func signSigV4(req *http.Request, signer *v4.Signer, service string, region string) (*http.Request, error) {
+	req = req.Clone(req.Context())
+
+	body := []byte{}
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := req.Body.Close(); err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	if strings.Contains(req.URL.RawPath, "%2C") {
+		req.URL.RawPath = rest.EscapePath(req.URL.RawPath, false)
+	}
+
+	for h := range req.Header {
+		if _, ok := sigV4PermittedHeaders[h]; !ok {
+			req.Header.Del(h)
+		}
+	}
+
+	if _, err := signer.Sign(req, bytes.NewReader(body), service, region, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+func newSigV4Credentials(config sigV4Config) (*credentials.Credentials, error) {
+	authType, err := sigV4AuthType(config.authType)
+	if err != nil {
+		return nil, err
+	}
+
+	authSettings := awsds.ReadAuthSettingsFromEnvironmentVariables()
+	allowed := false
+	for _, provider := range authSettings.AllowedAuthProviders {
+		if provider == authType.String() {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return nil, fmt.Errorf("attempting to use an auth type for SigV4 that is not allowed: %q", authType.String())
+	}
+	if config.assumeRoleARN != "" && !authSettings.AssumeRoleEnabled {
+		return nil, fmt.Errorf("attempting to use assume role (ARN) for SigV4 which is not enabled")
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		CredentialsChainVerboseErrors: aws.Bool(true),
+		Region:                        aws.String(config.region),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var c *credentials.Credentials
+	switch authType {
+	case awsds.AuthTypeKeys:
+		c = credentials.NewStaticCredentials(config.accessKey, config.secretKey, "")
+	case awsds.AuthTypeSharedCreds:
+		c = credentials.NewSharedCredentials("", config.profile)
+	case awsds.AuthTypeEC2IAMRole:
+		c = credentials.NewCredentials(&ec2rolecreds.EC2RoleProvider{
+			Client:       ec2metadata.New(sess),
+			ExpiryWindow: sigV4ExpiryWindow,
+		})
+	default:
+		c = sess.Config.Credentials
+	}
+
+	if config.assumeRoleARN == "" {
+		return c, nil
+	}
+	sess = sess.Copy(&aws.Config{Credentials: c})
+	return stscreds.NewCredentials(sess, config.assumeRoleARN, func(p *stscreds.AssumeRoleProvider) {
+		if config.externalID != "" {
+			p.ExternalID = aws.String(config.externalID)
+		}
+		p.ExpiryWindow = sigV4ExpiryWindow
+	}), nil
+}
+
+// sigV4AuthType is awsds.ToAuthType without panicking on unknown auth types.
+func sigV4AuthType(authType string) (awsds.AuthType, error) {
+	switch authType {
+	case "credentials", "sharedCreds", "keys", "default", "ec2_iam_role", "arn":
+		return awsds.ToAuthType(authType), nil
+	}
+	return 0, fmt.Errorf("invalid SigV4 auth type %q", authType)
+}
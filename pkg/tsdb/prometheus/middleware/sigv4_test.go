@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigV4Middleware(t *testing.T) {
+	var sent *http.Request
+	finalRoundTripper := httpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	opts := httpclient.Options{SigV4: &httpclient.SigV4Config{
+		AuthType:      "keys",
+		Service:       "aps",
+		AccessKey:     "access",
+		SecretKey:     "secret",
+		AssumeRoleARN: "arn:aws:iam::123:role/prometheus",
+		ExternalID:    "datasource",
+		Region:        "us-east-1",
+	}}
+
+	setup := func() (*SigV4Credentials, *[]sigV4Config) {
+		var mu sync.Mutex
+		var created []sigV4Config
+		creds := NewSigV4Credentials()
+		creds.newCredentials = func(config sigV4Config) (*credentials.Credentials, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			created = append(created, config)
+			return credentials.NewStaticCredentials("assumed", "secret", "token"), nil
+		}
+		return creds, &created
+	}
+
+	roundTrip := func(t *testing.T, rt http.RoundTripper, ctx context.Context) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://test.com/api/v1/query", strings.NewReader("query=up"))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("X-Custom", "value")
+		res, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, "value", req.Header.Get("X-Custom"), "the original request is not changed")
+	}
+
+	t.Run("it has a name", func(t *testing.T) {
+		creds, _ := setup()
+		mw := SigV4(creds)
+		middlewareName, ok := mw.(httpclient.MiddlewareName)
+		require.True(t, ok)
+		require.Equal(t, sigV4MiddlewareName, middlewareName.MiddlewareName())
+	})
+
+	t.Run("it does nothing without SigV4 settings", func(t *testing.T) {
+		creds, created := setup()
+		rt := SigV4(creds).CreateMiddleware(httpclient.Options{}, finalRoundTripper)
+		roundTrip(t, rt, context.Background())
+		require.Empty(t, sent.Header.Get("Authorization"))
+		require.Empty(t, *created)
+	})
+
+	t.Run("it signs requests and reuses the credentials", func(t *testing.T) {
+		creds, created := setup()
+		rt := SigV4(creds).CreateMiddleware(opts, finalRoundTripper)
+
+		for i := 0; i < 3; i++ {
+			roundTrip(t, rt, context.Background())
+			require.Contains(t, sent.Header.Get("Authorization"), "Credential=assumed/")
+			require.Contains(t, sent.Header.Get("Authorization"), "/us-east-1/aps/aws4_request")
+			require.Equal(t, "token", sent.Header.Get("X-Amz-Security-Token"))
+			require.Empty(t, sent.Header.Get("X-Custom"))
+			body, err := io.ReadAll(sent.Body)
+			require.NoError(t, err)
+			require.Equal(t, "query=up", string(body))
+		}
+		require.Len(t, *created, 1)
+		require.Equal(t, "datasource", (*created)[0].externalID)
+	})
+
+	t.Run("queries can override the region", func(t *testing.T) {
+		creds, created := setup()
+		rt := SigV4(creds).CreateMiddleware(opts, finalRoundTripper)
+
+		ctx := WithSigV4Options(context.Background(), SigV4Options{Region: "eu-west-1"})
+		roundTrip(t, rt, ctx)
+		require.Contains(t, sent.Header.Get("Authorization"), "/eu-west-1/aps/aws4_request")
+		roundTrip(t, rt, ctx)
+		roundTrip(t, rt, context.Background())
+
+		require.Len(t, *created, 2)
+		require.Equal(t, "eu-west-1", (*created)[0].region)
+		require.Equal(t, "datasource", (*created)[0].externalID)
+		require.Equal(t, "us-east-1", (*created)[1].region)
+		require.Equal(t, "datasource", (*created)[1].externalID)
+	})
+
+	t.Run("it keeps a bounded number of credentials", func(t *testing.T) {
+		creds, created := setup()
+		rt := SigV4(creds).CreateMiddleware(opts, finalRoundTripper)
+
+		for i := 0; i <= sigV4CredentialsCacheSize; i++ {
+			roundTrip(t, rt, WithSigV4Options(context.Background(), SigV4Options{Region: fmt.Sprintf("eu-west-%d", i)}))
+		}
+		require.Equal(t, sigV4CredentialsCacheSize, creds.credentials.Len())
+
+		// The credentials of the first region were evicted.
+		roundTrip(t, rt, WithSigV4Options(context.Background(), SigV4Options{Region: "eu-west-0"}))
+		require.Len(t, *created, sigV4CredentialsCacheSize+2)
+	})
+}
+
+func TestNewSigV4Credentials(t *testing.T) {
+	t.Run("it rejects unknown auth types", func(t *testing.T) {
+		_, err := newSigV4Credentials(sigV4Config{authType: "magic"})
+		require.EqualError(t, err, `invalid SigV4 auth type "magic"`)
+	})
+
+	t.Run("it rejects auth types that are not allowed", func(t *testing.T) {
+		t.Setenv("AWS_AUTH_AllowedAuthProviders", "default")
+		_, err := newSigV4Credentials(sigV4Config{authType: "keys"})
+		require.EqualError(t, err, `attempting to use an auth type for SigV4 that is not allowed: "keys"`)
+	})
+
+	t.Run("it rejects assuming roles when it is not enabled", func(t *testing.T) {
+		t.Setenv("AWS_AUTH_AllowedAuthProviders", "keys")
+		t.Setenv("AWS_AUTH_AssumeRoleEnabled", "false")
+		_, err := newSigV4Credentials(sigV4Config{authType: "keys", assumeRoleARN: "arn:aws:iam::123:role/prometheus"})
+		require.EqualError(t, err, "attempting to use assume role (ARN) for SigV4 which is not enabled")
+	})
+
+	t.Run("it uses static keys", func(t *testing.T) {
+		t.Setenv("AWS_AUTH_AllowedAuthProviders", "keys")
+		c, err := newSigV4Credentials(sigV4Config{authType: "keys", accessKey: "access", secretKey: "secret", region: "us-east-1"})
+		require.NoError(t, err)
+		v, err := c.Get()
+		require.NoError(t, err)
+		require.Equal(t, "access", v.AccessKeyID)
+	})
+}
@@ -0,0 +1,139 @@
+package promclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+const buildInfoEndpoint = "/api/v1/status/buildinfo"
+
+// Flavors of Prometheus compatible servers.
+const (
+	FlavorPrometheus      = "prometheus"
+	FlavorThanos          = "thanos"
+	FlavorCortex          = "cortex"
+	FlavorMimir           = "mimir"
+	FlavorVictoriaMetrics = "victoriametrics"
+	FlavorUnknown         = "unknown"
+)
+
+// BuildInfo is the build information a Prometheus compatible server reports.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Branch    string `json:"branch"`
+	GoVersion string `json:"goVersion"`
+	// Application is only reported by Grafana Mimir and Cortex.
+	Application string `json:"application"`
+}
+
+// Flavor returns the kind of server that reported the build information. Thanos
+// reports its own 0.x versions, where Prometheus is at 2.x. VictoriaMetrics
+// pretends to be Prometheus 2.24.0, but reports nothing but the version.
+func (b *BuildInfo) Flavor() string {
+	switch {
+	case b == nil:
+		return FlavorUnknown
+	case strings.Contains(strings.ToLower(b.Application), "mimir"):
+		return FlavorMimir
+	case strings.Contains(strings.ToLower(b.Application), "cortex"):
+		return FlavorCortex
+	case strings.HasPrefix(strings.TrimPrefix(b.Version, "v"), "0."):
+		return FlavorThanos
+	case b.Revision == "" && b.Branch == "" && b.GoVersion == "":
+		return FlavorVictoriaMetrics
+	default:
+		return FlavorPrometheus
+	}
+}
+
+// Capabilities are the optional query features a server supports.
+type Capabilities struct {
+	// Server describes the server in error messages, e.g. "thanos 0.19.0".
+	Server           string
+	Exemplars        bool
+	NativeHistograms bool
+	AtModifier       bool
+}
+
+// minVersions are the first versions of each flavor with exemplars, native
+// histograms and the @ modifier, empty when the flavor does not support them.
+var minVersions = map[string][3]string{
+	FlavorPrometheus: {"2.26.0", "2.40.0", "2.25.0"},
+	FlavorThanos:     {"0.22.0", "0.32.0", "0.21.0"},
+	FlavorCortex:     {"1.10.0", "", "1.10.0"},
+	FlavorMimir:      {"2.0.0", "2.7.0", "2.0.0"},
+}
+
+// Capabilities returns the features supported by the server. All of them are
+// assumed to be supported when the flavor or version is not known, so that
+// queries are never refused by mistake.
+func (b *BuildInfo) Capabilities() Capabilities {
+	flavor := b.Flavor()
+	if flavor == FlavorVictoriaMetrics {
+		return Capabilities{Server: "VictoriaMetrics", AtModifier: true}
+	}
+	all := Capabilities{Exemplars: true, NativeHistograms: true, AtModifier: true}
+	versions, ok := minVersions[flavor]
+	if !ok {
+		return all
+	}
+	version, err := semver.NewVersion(b.Version)
+	if err != nil {
+		return all
+	}
+	atLeast := func(min string) bool {
+		return min != "" && !version.LessThan(semver.MustParse(min))
+	}
+	return Capabilities{
+		Server:           fmt.Sprintf("%s %s", flavor, b.Version),
+		Exemplars:        atLeast(versions[0]),
+		NativeHistograms: atLeast(versions[1]),
+		AtModifier:       atLeast(versions[2]),
+	}
+}
+
+// BuildInfo returns the build information of the server, or nil when the server
+// does not implement the build info endpoint, like versions of Prometheus before
+// 2.14 do.
+func (c *Client) BuildInfo(ctx context.Context) (*BuildInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.client.URL(buildInfoEndpoint, nil).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	var body struct {
+		Status    string     `json:"status"`
+		Data      *BuildInfo `json:"data"`
+		ErrorType string     `json:"errorType"`
+		Error     string     `json:"error"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&body)
+	if err == nil && body.Status == "error" {
+		return nil, &apiv1.Error{Type: apiv1.ErrorType(body.ErrorType), Msg: body.Error}
+	}
+	if res.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("server error: %d", res.StatusCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return body.Data, nil
+}
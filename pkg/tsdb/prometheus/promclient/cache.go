@@ -17,6 +17,12 @@ type promClientProvider interface {
 	GetClient(map[string]string) (apiv1.API, error)
 }
 
+// versionedPromClientProvider is a promClientProvider whose clients are rebuilt
+// when the version changes, e.g. because its TLS files changed.
+type versionedPromClientProvider interface {
+	ClientVersion() string
+}
+
 func NewProviderCache(p promClientProvider) (*ProviderCache, error) {
 	cache, err := lru.New(500)
 	if err != nil {
@@ -52,5 +58,11 @@ func (c *ProviderCache) key(headers map[string]string) string {
 		i++
 	}
 	sort.Strings(vals)
-	return strings.Join(vals, "")
+	key := strings.Join(vals, "")
+	if p, ok := c.provider.(versionedPromClientProvider); ok {
+		if version := p.ClientVersion(); version != "" {
+			key = version + "|" + key
+		}
+	}
+	return key
 }
@@ -71,6 +71,33 @@ func TestCache_GetClient(t *testing.T) {
 		require.NotNil(t, c)
 		require.Equal(t, 2, tc.clientProvider.numCalls)
 	})
+
+	t.Run("it rebuilds clients when the provider version changes", func(t *testing.T) {
+		fp := &fakeVersionedPromClientProvider{fakePromClientProvider: newFakePromClientProvider(), version: "1"}
+		providerCache, err := promclient.NewProviderCache(fp)
+		require.Nil(t, err)
+
+		c, err := providerCache.GetClient(headers)
+		require.Nil(t, err)
+		c2, err := providerCache.GetClient(headers)
+		require.Nil(t, err)
+		require.Same(t, c, c2)
+
+		fp.version = "2"
+		c3, err := providerCache.GetClient(headers)
+		require.Nil(t, err)
+		require.NotSame(t, c, c3)
+		require.Equal(t, 2, fp.numCalls)
+	})
+}
+
+type fakeVersionedPromClientProvider struct {
+	*fakePromClientProvider
+	version string
+}
+
+func (p *fakeVersionedPromClientProvider) ClientVersion() string {
+	return p.version
 }
 
 type cacheTestContext struct {
@@ -0,0 +1,230 @@
+package promclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+const queryRangeEndpoint = "/api/v1/query_range"
+
+// Client is a Prometheus API client that can also stream the result of range queries.
+type Client struct {
+	apiv1.API
+
+	client     api.Client
+	httpClient *http.Client
+}
+
+func NewClient(address string, roundTripper http.RoundTripper) (*Client, error) {
+	client, err := api.NewClient(api.Config{
+		Address:      address,
+		RoundTripper: roundTripper,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		API:        apiv1.NewAPI(client),
+		client:     client,
+		httpClient: &http.Client{Transport: roundTripper},
+	}, nil
+}
+
+// RangeQueryOptions are the optional parameters of a range query.
+type RangeQueryOptions struct {
+	// Stats asks Prometheus for the statistics of the query.
+	Stats bool
+}
+
+// QueryRangeStream runs a range query and calls fn with every series of the result as
+// soon as it is decoded, instead of reading the whole response into memory first.
+// Unlike QueryRange, it also decodes native histogram samples.
+// Decoding stops once ctx is done. The statistics of the query are returned when
+// they were asked for, along with the warnings of the API like QueryRange.
+func (c *Client) QueryRangeStream(ctx context.Context, query string, r apiv1.Range, opts RangeQueryOptions, fn func(*Series) error) (*QueryStats, apiv1.Warnings, error) {
+	args := url.Values{}
+	args.Set("query", query)
+	args.Set("start", formatTime(r.Start))
+	args.Set("end", formatTime(r.End))
+	args.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+	if opts.Stats {
+		args.Set("stats", "all")
+	}
+
+	res, err := c.doGetFallback(ctx, c.client.URL(queryRangeEndpoint, nil), args)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	stats, warnings, err := decodeMatrixStream(ctx, res.Body, fn)
+	if err != nil {
+		if _, ok := err.(*apiv1.Error); !ok && res.StatusCode/100 != 2 {
+			return nil, warnings, fmt.Errorf("server error: %d", res.StatusCode)
+		}
+		return nil, warnings, err
+	}
+	return stats, warnings, nil
+}
+
+// doGetFallback posts the query like the Prometheus client does, and falls back to
+// GET for servers that do not allow POST.
+func (c *Client) doGetFallback(ctx context.Context, u *url.URL, args url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(args.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil || res.StatusCode != http.StatusMethodNotAllowed {
+		return res, err
+	}
+	_ = res.Body.Close()
+
+	u.RawQuery = args.Encode()
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+// decodeMatrixStream decodes a Prometheus API response holding a matrix, one series at a time.
+func decodeMatrixStream(ctx context.Context, r io.Reader, fn func(*Series) error) (*QueryStats, apiv1.Warnings, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, nil, err
+	}
+
+	var status, errorType, errorMsg string
+	var stats *QueryStats
+	var warnings apiv1.Warnings
+	for dec.More() {
+		key, err := decodeKey(dec)
+		if err != nil {
+			return nil, warnings, err
+		}
+
+		switch key {
+		case "status":
+			err = dec.Decode(&status)
+		case "errorType":
+			err = dec.Decode(&errorType)
+		case "error":
+			err = dec.Decode(&errorMsg)
+		case "warnings":
+			err = dec.Decode(&warnings)
+		case "data":
+			stats, err = decodeMatrixData(ctx, dec, fn)
+		default:
+			var skipped json.RawMessage
+			err = dec.Decode(&skipped)
+		}
+		if err != nil {
+			return nil, warnings, err
+		}
+	}
+
+	if status == "error" {
+		return nil, warnings, &apiv1.Error{Type: apiv1.ErrorType(errorType), Msg: errorMsg}
+	}
+	return stats, warnings, expectDelim(dec, '}')
+}
+
+func decodeMatrixData(ctx context.Context, dec *json.Decoder, fn func(*Series) error) (*QueryStats, error) {
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+
+	var stats *QueryStats
+	for dec.More() {
+		key, err := decodeKey(dec)
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "resultType":
+			var resultType string
+			if err := dec.Decode(&resultType); err != nil {
+				return nil, err
+			}
+			if resultType != model.ValMatrix.String() {
+				return nil, fmt.Errorf("unexpected result type %q of range query", resultType)
+			}
+		case "result":
+			if err := expectDelim(dec, '['); err != nil {
+				return nil, err
+			}
+			for dec.More() {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				var series Series
+				if err := dec.Decode(&series); err != nil {
+					return nil, err
+				}
+				if err := fn(&series); err != nil {
+					return nil, err
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return nil, err
+			}
+		case "stats":
+			stats = &QueryStats{}
+			if err := dec.Decode(stats); err != nil {
+				return nil, err
+			}
+		default:
+			var skipped json.RawMessage
+			if err := dec.Decode(&skipped); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return stats, expectDelim(dec, '}')
+}
+
+func decodeKey(dec *json.Decoder) (string, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := token.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected token %v in prometheus response", token)
+	}
+	return key, nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected token %v in prometheus response, expected %v", token, delim)
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.Unix())+float64(t.Nanosecond())/1e9, 'f', -1, 64)
+}
@@ -0,0 +1,255 @@
+package promclient_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+const matrixResponse = `{
+	"status": "success",
+	"data": {
+		"resultType": "matrix",
+		"result": [
+			{"metric": {"__name__": "up", "job": "a"}, "values": [[1641889530, "1"], [1641889531, "0"]]},
+			{"metric": {"__name__": "up", "job": "b"}, "values": [[1641889530, "1"]]}
+		]
+	}
+}`
+
+func TestClient_QueryRangeStream(t *testing.T) {
+	queryRange := apiv1.Range{Start: time.Unix(1641889530, 0), End: time.Unix(1641889531, 0), Step: time.Second}
+
+	t.Run("it decodes every series of the matrix", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusOK, matrixResponse)
+
+		var series []*promclient.Series
+		_, _, err := client.QueryRangeStream(context.Background(), "up", queryRange, promclient.RangeQueryOptions{}, func(s *promclient.Series) error {
+			series = append(series, s)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, series, 2)
+		require.Equal(t, model.LabelValue("a"), series[0].Metric["job"])
+		require.Len(t, series[0].Values, 2)
+		require.Equal(t, model.SampleValue(0), series[0].Values[1].Value)
+		require.Equal(t, model.LabelValue("b"), series[1].Metric["job"])
+	})
+
+	t.Run("it decodes native histograms", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusOK, `{
+			"status": "success",
+			"data": {
+				"resultType": "matrix",
+				"result": [{
+					"metric": {"__name__": "request_duration_seconds"},
+					"histograms": [[1641889530, {"count": "5", "sum": "4.5", "buckets": [[0, "0.5", "1", "2"], [0, "1", "2", "3"]]}]]
+				}]
+			}
+		}`)
+
+		var series []*promclient.Series
+		_, _, err := client.QueryRangeStream(context.Background(), "request_duration_seconds", queryRange, promclient.RangeQueryOptions{}, func(s *promclient.Series) error {
+			series = append(series, s)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, series, 1)
+		require.Empty(t, series[0].Values)
+		require.Len(t, series[0].Histograms, 1)
+
+		h := series[0].Histograms[0]
+		require.Equal(t, model.Time(1641889530000), h.Timestamp)
+		require.Equal(t, model.SampleValue(5), h.Histogram.Count)
+		require.Equal(t, model.SampleValue(4.5), h.Histogram.Sum)
+		require.Equal(t, []promclient.HistogramBucket{
+			{Boundaries: 0, Lower: 0.5, Upper: 1, Count: 2},
+			{Boundaries: 0, Lower: 1, Upper: 2, Count: 3},
+		}, h.Histogram.Buckets)
+	})
+
+	t.Run("it asks for and decodes query statistics", func(t *testing.T) {
+		client, err := promclient.NewClient("http://localhost:9090", roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, req.ParseForm())
+			require.Equal(t, "all", req.PostForm.Get("stats"))
+			return response(http.StatusOK, `{
+				"status": "success",
+				"data": {
+					"resultType": "matrix",
+					"result": [],
+					"stats": {
+						"timings": {"evalTotalTime": 0.5, "execTotalTime": 0.75},
+						"samples": {"totalQueryableSamples": 1200, "peakSamples": 300}
+					}
+				}
+			}`), nil
+		}))
+		require.NoError(t, err)
+
+		stats, _, err := client.QueryRangeStream(context.Background(), "up", queryRange, promclient.RangeQueryOptions{Stats: true}, func(s *promclient.Series) error {
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, &promclient.QueryStats{
+			Timings: promclient.QueryTimings{EvalTotalTime: 0.5, ExecTotalTime: 0.75},
+			Samples: promclient.QuerySamples{TotalQueryableSamples: 1200, PeakSamples: 300},
+		}, stats)
+	})
+
+	t.Run("it stops decoding when the context is cancelled", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusOK, matrixResponse)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		calls := 0
+		_, _, err := client.QueryRangeStream(ctx, "up", queryRange, promclient.RangeQueryOptions{}, func(s *promclient.Series) error {
+			calls++
+			cancel()
+			return nil
+		})
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("it returns prometheus errors", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+
+		_, _, err := client.QueryRangeStream(context.Background(), "up{", queryRange, promclient.RangeQueryOptions{}, func(s *promclient.Series) error {
+			return nil
+		})
+		var apiErr *apiv1.Error
+		require.True(t, errors.As(err, &apiErr))
+		require.Equal(t, apiv1.ErrBadData, apiErr.Type)
+		require.Equal(t, "parse error", apiErr.Msg)
+	})
+
+	t.Run("it returns the warnings of the response", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusOK, `{"status":"success","warnings":["results are partial"],"data":{"resultType":"matrix","result":[]}}`)
+
+		_, warnings, err := client.QueryRangeStream(context.Background(), "up", queryRange, promclient.RangeQueryOptions{}, func(s *promclient.Series) error {
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, apiv1.Warnings{"results are partial"}, warnings)
+	})
+
+	t.Run("it rejects results that are not a matrix", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusOK, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+
+		_, _, err := client.QueryRangeStream(context.Background(), "up", queryRange, promclient.RangeQueryOptions{}, func(s *promclient.Series) error {
+			return nil
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("it falls back to GET when POST is not allowed", func(t *testing.T) {
+		var methods []string
+		rt := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			methods = append(methods, req.Method)
+			if req.Method == http.MethodPost {
+				return response(http.StatusMethodNotAllowed, ""), nil
+			}
+			require.Equal(t, "up", req.URL.Query().Get("query"))
+			require.Equal(t, "/api/v1/query_range", req.URL.Path)
+			return response(http.StatusOK, matrixResponse), nil
+		})
+		client, err := promclient.NewClient("http://localhost:9090", rt)
+		require.NoError(t, err)
+
+		_, _, err = client.QueryRangeStream(context.Background(), "up", queryRange, promclient.RangeQueryOptions{}, func(s *promclient.Series) error {
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{http.MethodPost, http.MethodGet}, methods)
+	})
+}
+
+func TestClient_BuildInfo(t *testing.T) {
+	t.Run("it detects the flavor of the server", func(t *testing.T) {
+		for body, flavor := range map[string]string{
+			`{"status":"success","data":{"version":"2.33.1","revision":"4e08110"}}`:         promclient.FlavorPrometheus,
+			`{"status":"success","data":{"version":"0.25.0"}}`:                              promclient.FlavorThanos,
+			`{"status":"success","data":{"version":"2.0.0","application":"Grafana Mimir"}}`: promclient.FlavorMimir,
+			`{"status":"success","data":{"version":"2.24.0"}}`:                              promclient.FlavorVictoriaMetrics,
+		} {
+			client := newStreamingClient(t, http.StatusOK, body)
+			info, err := client.BuildInfo(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, flavor, info.Flavor())
+		}
+	})
+
+	t.Run("it returns no build info when the endpoint does not exist", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusNotFound, "404 page not found")
+		info, err := client.BuildInfo(context.Background())
+		require.NoError(t, err)
+		require.Nil(t, info)
+		require.Equal(t, promclient.FlavorUnknown, info.Flavor())
+	})
+
+	t.Run("it returns server errors", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusUnauthorized, "unauthorized")
+		_, err := client.BuildInfo(context.Background())
+		require.EqualError(t, err, "server error: 401")
+	})
+}
+
+func TestBuildInfo_Capabilities(t *testing.T) {
+	t.Run("it gates features on the version", func(t *testing.T) {
+		info := &promclient.BuildInfo{Version: "2.25.2", Revision: "a"}
+		require.Equal(t, promclient.Capabilities{Server: "prometheus 2.25.2", AtModifier: true}, info.Capabilities())
+
+		info = &promclient.BuildInfo{Version: "2.40.0", Revision: "a"}
+		require.Equal(t, promclient.Capabilities{Server: "prometheus 2.40.0", Exemplars: true, NativeHistograms: true, AtModifier: true}, info.Capabilities())
+
+		info = &promclient.BuildInfo{Version: "1.13.0", Application: "Cortex"}
+		require.Equal(t, promclient.Capabilities{Server: "cortex 1.13.0", Exemplars: true, AtModifier: true}, info.Capabilities())
+	})
+
+	t.Run("it assumes everything is supported when the version is not known", func(t *testing.T) {
+		all := promclient.Capabilities{Exemplars: true, NativeHistograms: true, AtModifier: true}
+		var info *promclient.BuildInfo
+		require.Equal(t, all, info.Capabilities())
+
+		info = &promclient.BuildInfo{Version: "main", Revision: "a"}
+		require.Equal(t, all, info.Capabilities())
+	})
+
+	t.Run("it does not support exemplars and native histograms on VictoriaMetrics", func(t *testing.T) {
+		info := &promclient.BuildInfo{Version: "2.24.0"}
+		require.Equal(t, promclient.Capabilities{Server: "VictoriaMetrics", AtModifier: true}, info.Capabilities())
+	})
+}
+
+func newStreamingClient(t *testing.T, status int, body string) *promclient.Client {
+	t.Helper()
+	client, err := promclient.NewClient("http://localhost:9090", roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return response(status, body), nil
+	}))
+	require.NoError(t, err)
+	return client
+}
+
+func response(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(body))),
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,70 @@
+package promclient
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/common/model"
+)
+
+// Series is a series of a range query result. Besides float samples, it holds the
+// native histogram samples Prometheus 2.40 and later return in `histograms`.
+type Series struct {
+	model.SampleStream
+	Histograms []SampleHistogramPair `json:"histograms"`
+}
+
+// SampleHistogramPair is a native histogram sample.
+type SampleHistogramPair struct {
+	Timestamp model.Time
+	Histogram SampleHistogram
+}
+
+// SampleHistogram is a native histogram, its buckets are not cumulative.
+type SampleHistogram struct {
+	Count   model.SampleValue `json:"count"`
+	Sum     model.SampleValue `json:"sum"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+// HistogramBucket is a bucket of a native histogram. Boundaries tells which of its
+// bounds are inclusive: 0 is open left, 1 open right, 2 open both and 3 closed both.
+type HistogramBucket struct {
+	Boundaries int
+	Lower      model.SampleValue
+	Upper      model.SampleValue
+	Count      model.SampleValue
+}
+
+// UnmarshalJSON decodes the `[<timestamp>, <histogram>]` form of the sample.
+func (p *SampleHistogramPair) UnmarshalJSON(b []byte) error {
+	var v [2]json.RawMessage
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(v[0], &p.Timestamp); err != nil {
+		return err
+	}
+	return json.Unmarshal(v[1], &p.Histogram)
+}
+
+// UnmarshalJSON decodes the `[<boundaries>, <lower>, <upper>, <count>]` form of the bucket.
+func (b *HistogramBucket) UnmarshalJSON(buf []byte) error {
+	var v []json.RawMessage
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return err
+	}
+	if len(v) != 4 {
+		return fmt.Errorf("histogram bucket has %d elements, expected 4", len(v))
+	}
+	if err := json.Unmarshal(v[0], &b.Boundaries); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(v[1], &b.Lower); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(v[2], &b.Upper); err != nil {
+		return err
+	}
+	return json.Unmarshal(v[3], &b.Count)
+}
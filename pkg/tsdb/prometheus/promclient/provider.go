@@ -2,6 +2,7 @@ package promclient
 
 import (
 	"strings"
+	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 
@@ -10,34 +11,123 @@ import (
 	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana/pkg/infra/httpclient"
 	"github.com/grafana/grafana/pkg/infra/log"
-	"github.com/prometheus/client_golang/api"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 5 * time.Second
+)
+
 type Provider struct {
 	settings       backend.DataSourceInstanceSettings
 	jsonData       JsonData
 	clientProvider httpclient.Provider
+	features       featuremgmt.FeatureToggles
 	log            log.Logger
+
+	sigV4Credentials *middleware.SigV4Credentials
+	tlsFiles         *tlsFiles
+	serverClock      *middleware.ServerClock
 }
 
 func NewProvider(
 	settings backend.DataSourceInstanceSettings,
 	jsonData JsonData,
 	clientProvider httpclient.Provider,
+	features featuremgmt.FeatureToggles,
+	tlsFilesPath string,
 	log log.Logger,
 ) *Provider {
 	return &Provider{
 		settings:       settings,
 		jsonData:       jsonData,
 		clientProvider: clientProvider,
+		features:       features,
 		log:            log,
+
+		sigV4Credentials: middleware.NewSigV4Credentials(),
+		serverClock:      middleware.NewServerClock(),
+		tlsFiles: &tlsFiles{
+			dir:        tlsFilesPath,
+			certFile:   jsonData.TLSClientCertFile,
+			keyFile:    jsonData.TLSClientKeyFile,
+			caCertFile: jsonData.TLSCACertFile,
+		},
 	}
 }
 
 type JsonData struct {
-	Method       string `json:"httpMethod"`
-	TimeInterval string `json:"timeInterval"`
+	// Method is the HTTP method of query requests, GET or POST. Range and instant
+	// queries are POSTed, falling back to GET, and exemplars are fetched with GET
+	// when it is not set.
+	Method                string `json:"httpMethod"`
+	TimeInterval          string `json:"timeInterval"`
+	ClampEndToNow         bool   `json:"clampEndToNow"`
+	CalculatorMinInterval string `json:"calculatorMinInterval"`
+	// MaxRedirects enables following up to this many same-host redirects.
+	MaxRedirects      int `json:"maxRedirects"`
+	MaxLabelsPerField int `json:"maxLabelsPerField"`
+	// MaxSeries truncates results with more series, unless a query sets a lower limit.
+	MaxSeries int `json:"maxSeries"`
+	// MaxRetries enables retrying failed requests; RetryStatusCodes limits the
+	// retried status codes, RetryBackoff and RetryMaxBackoff the waits between
+	// retries, e.g. "100ms" and "5s". See middleware.RetryPolicy.
+	MaxRetries       int    `json:"maxRetries"`
+	RetryStatusCodes []int  `json:"retryStatusCodes"`
+	RetryBackoff     string `json:"retryBackoff"`
+	RetryMaxBackoff  string `json:"retryMaxBackoff"`
+	// SplitInterval and SplitConcurrency split long range queries, see the
+	// DatasourceInfo fields of the same names.
+	SplitInterval    string `json:"splitInterval"`
+	SplitConcurrency int    `json:"splitConcurrency"`
+	// StreamRangeResponses decodes range query results while they are received.
+	StreamRangeResponses bool `json:"streamRangeResponses"`
+	// ExemplarChunkInterval splits exemplar queries over longer ranges into
+	// windows of this length, e.g. "1h".
+	ExemplarChunkInterval string `json:"exemplarChunkInterval"`
+	// QueryConcurrency limits how many queries of a request run at the same time.
+	QueryConcurrency int `json:"queryConcurrency"`
+	// IncrementalQuerying caches range results and only queries what is new on refresh,
+	// re-querying the last IncrementalQueryOverlapWindow to pick up late samples.
+	IncrementalQuerying           bool   `json:"incrementalQuerying"`
+	IncrementalQueryOverlapWindow string `json:"incrementalQueryOverlapWindow"`
+	// RemoteRead fetches the samples of range queries of plain selectors with the
+	// remote read API, falling back to the query API when it is not available.
+	RemoteRead bool `json:"remoteRead"`
+	// RemoteReadLookbackDelta is the --query.lookback-delta of the Prometheus
+	// server, e.g. "5m", the Prometheus default when empty.
+	RemoteReadLookbackDelta string `json:"remoteReadLookbackDelta"`
+	// RecordingRuleRewrite answers queries whose expression is the same as the one
+	// of a recording rule from the series of the rule.
+	RecordingRuleRewrite bool `json:"recordingRuleRewrite"`
+	// LabelsCacheTTL is how long label names and values are cached, e.g. "5m".
+	LabelsCacheTTL string `json:"labelsCacheTTL"`
+	// AzureEndpointResourceId is the resource AAD tokens are requested for when
+	// the azureCredentials are set, e.g. https://prometheus.monitor.azure.com.
+	AzureEndpointResourceId string `json:"azureEndpointResourceId"`
+	// ExemplarTraceIdDestinations adds trace links to the fields of exemplar labels.
+	ExemplarTraceIdDestinations []ExemplarTraceIdDestination `json:"exemplarTraceIdDestinations"`
+	// HealthCheckProbe makes the health check run HealthCheckQuery, "1+1" when
+	// empty, to verify that Prometheus also evaluates queries.
+	HealthCheckProbe bool   `json:"healthCheckProbe"`
+	HealthCheckQuery string `json:"healthCheckQuery"`
+	// TLSClientCertFile, TLSClientKeyFile and TLSCACertFile are absolute paths of
+	// PEM files used instead of the certificates stored in the data source. They
+	// must be in the tls_files_path directory of the [datasources] server config.
+	// Clients are rebuilt when the files change, e.g. when cert-manager rotates them.
+	TLSClientCertFile string `json:"tlsClientCertFile"`
+	TLSClientKeyFile  string `json:"tlsClientKeyFile"`
+	TLSCACertFile     string `json:"tlsCACertFile"`
+	// SecondaryURLs are replicas of the Prometheus server of the data source URL.
+	// Requests failing with a network or server error are sent to them in turn.
+	SecondaryURLs []string `json:"secondaryUrls"`
+	// ResponseCompression is "force" to always ask for gzip responses, or
+	// "disable" to ask for uncompressed ones. CompressRequests gzips the bodies
+	// of POST requests. See middleware.Compression.
+	ResponseCompression string `json:"responseCompression"`
+	CompressRequests    bool   `json:"compressRequests"`
 }
 
 func (p *Provider) GetClient(headers map[string]string) (apiv1.API, error) {
@@ -46,44 +136,105 @@ func (p *Provider) GetClient(headers map[string]string) (apiv1.API, error) {
 		return nil, err
 	}
 
-	opts.Middlewares = p.middlewares()
+	// The data source timeout is applied per request by the query timeout
+	// middleware instead of the transport, so that queries can set a longer one.
+	timeout := sdkhttpclient.DefaultTimeoutOptions.Timeout
+	if opts.Timeouts != nil {
+		timeouts := *opts.Timeouts
+		timeout = timeouts.Timeout
+		timeouts.Timeout = 0
+		opts.Timeouts = &timeouts
+	}
+
+	opts.Middlewares = p.middlewares(timeout)
 	opts.Headers = reqHeaders(headers)
 
-	// Set SigV4 service namespace
-	if opts.SigV4 != nil {
-		opts.SigV4.Service = "aps"
-	}
+	p.configureSigV4(&opts)
 
-	roundTripper, err := p.clientProvider.GetTransport(opts)
-	if err != nil {
+	if err := p.configureTLSFiles(&opts); err != nil {
 		return nil, err
 	}
 
-	cfg := api.Config{
-		Address:      p.settings.URL,
-		RoundTripper: roundTripper,
+	if err := p.configureAzureAuthentication(&opts); err != nil {
+		return nil, err
 	}
 
-	client, err := api.NewClient(cfg)
+	roundTripper, err := p.clientProvider.GetTransport(opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return apiv1.NewAPI(client), nil
+	return NewClient(p.settings.URL, roundTripper)
 }
 
-func (p *Provider) middlewares() []sdkhttpclient.Middleware {
+// ServerClock tells the time of the Prometheus server from the responses of
+// the clients of the provider when ClampEndToNow is set.
+func (p *Provider) ServerClock() *middleware.ServerClock {
+	return p.serverClock
+}
+
+// ClientVersion changes when clients have to be rebuilt, see ProviderCache.
+func (p *Provider) ClientVersion() string {
+	return p.tlsFiles.Version()
+}
+
+func (p *Provider) middlewares(timeout time.Duration) []sdkhttpclient.Middleware {
 	middlewares := []sdkhttpclient.Middleware{
+		middleware.Metrics(p.settings.UID),
+		middleware.QueryTimeout(timeout),
 		middleware.CustomQueryParameters(p.log),
 		sdkhttpclient.CustomHeadersMiddleware(),
 	}
-	if strings.ToLower(p.jsonData.Method) == "get" {
+	if p.jsonData.ClampEndToNow {
+		middlewares = append(middlewares, middleware.ServerTime(p.serverClock))
+	}
+	switch strings.ToLower(p.jsonData.Method) {
+	case "get":
 		middlewares = append(middlewares, middleware.ForceHttpGet(p.log))
+	case "post":
+		middlewares = append(middlewares, middleware.ForceHttpPost(p.log))
+	}
+	if p.jsonData.MaxRedirects > 0 {
+		middlewares = append(middlewares, middleware.Redirect(p.log, p.jsonData.MaxRedirects))
+	}
+	if p.jsonData.MaxRetries > 0 {
+		middlewares = append(middlewares, middleware.Retry(p.log, middleware.RetryPolicy{
+			MaxRetries:  p.jsonData.MaxRetries,
+			StatusCodes: p.jsonData.RetryStatusCodes,
+			Backoff:     p.retryDuration("retryBackoff", p.jsonData.RetryBackoff, defaultRetryBackoff),
+			MaxBackoff:  p.retryDuration("retryMaxBackoff", p.jsonData.RetryMaxBackoff, defaultRetryMaxBackoff),
+		}))
+	}
+	// Every retry tries all backends again, instead of retrying a backend that is down.
+	if len(p.jsonData.SecondaryURLs) > 0 {
+		middlewares = append(middlewares, middleware.Failover(p.log, p.settings.URL, p.jsonData.SecondaryURLs))
+	}
+	compression := middleware.ResponseCompression(p.jsonData.ResponseCompression)
+	switch compression {
+	case middleware.ResponseCompressionDefault, middleware.ResponseCompressionForce, middleware.ResponseCompressionDisable:
+	default:
+		p.log.Warn("Invalid response compression, using the default", "responseCompression", p.jsonData.ResponseCompression)
+		compression = middleware.ResponseCompressionDefault
+	}
+	if compression != middleware.ResponseCompressionDefault || p.jsonData.CompressRequests {
+		middlewares = append(middlewares, middleware.Compression(p.log, compression, p.jsonData.CompressRequests))
 	}
 
 	return middlewares
 }
 
+func (p *Provider) retryDuration(name string, value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		p.log.Warn("Invalid retry setting, using the default", "setting", name, "value", value, "default", defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
 func reqHeaders(headers map[string]string) map[string]string {
 	// copy to avoid changing the original map
 	h := make(map[string]string, len(headers))
@@ -92,3 +243,12 @@ func reqHeaders(headers map[string]string) map[string]string {
 	}
 	return h
 }
+
+// ExemplarTraceIdDestination links the exemplar label Name, holding a trace ID,
+// to a trace data source, an external URL, or both.
+type ExemplarTraceIdDestination struct {
+	Name            string `json:"name"`
+	URL             string `json:"url"`
+	URLDisplayLabel string `json:"urlDisplayLabel"`
+	DatasourceUid   string `json:"datasourceUid"`
+}
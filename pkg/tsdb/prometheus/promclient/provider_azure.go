@@ -0,0 +1,45 @@
+package promclient
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/azcredentials"
+)
+
+// configureAzureAuthentication hands the Azure credentials of the data source to
+// the Azure authentication middleware of the HTTP client provider, which attaches
+// AAD bearer tokens for the azureEndpointResourceId of the data source.
+func (p *Provider) configureAzureAuthentication(opts *sdkhttpclient.Options) error {
+	// Azure authentication is experimental
+	if p.features == nil || !p.features.IsEnabled(featuremgmt.FlagPrometheusAzureAuth) {
+		return nil
+	}
+
+	var data map[string]interface{}
+	if len(p.settings.JSONData) > 0 {
+		if err := json.Unmarshal(p.settings.JSONData, &data); err != nil {
+			return fmt.Errorf("invalid Azure configuration: %w", err)
+		}
+	}
+
+	credentials, err := azcredentials.FromDatasourceData(data, p.settings.DecryptedSecureJSONData)
+	if err != nil {
+		return fmt.Errorf("invalid Azure credentials: %w", err)
+	}
+	if credentials == nil {
+		return nil
+	}
+
+	if opts.CustomOptions == nil {
+		opts.CustomOptions = map[string]interface{}{}
+	}
+	opts.CustomOptions["_azureCredentials"] = credentials
+	if p.jsonData.AzureEndpointResourceId != "" {
+		opts.CustomOptions["azureEndpointResourceId"] = p.jsonData.AzureEndpointResourceId
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package promclient
+
+import (
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana/pkg/infra/httpclient/httpclientprovider"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/middleware"
+)
+
+// configureSigV4 signs requests for Amazon Managed Prometheus and replaces the
+// SigV4 middleware of the HTTP client provider, if SigV4 is enabled, with one
+// that caches the credentials of the data source. Without it every request
+// would assume the role of the data source with STS again.
+func (p *Provider) configureSigV4(opts *sdkhttpclient.Options) {
+	if opts.SigV4 == nil {
+		return
+	}
+	opts.SigV4.Service = "aps"
+
+	opts.ConfigureMiddleware = func(opts sdkhttpclient.Options, existing []sdkhttpclient.Middleware) []sdkhttpclient.Middleware {
+		middlewares := make([]sdkhttpclient.Middleware, 0, len(existing))
+		for _, m := range existing {
+			if named, ok := m.(sdkhttpclient.MiddlewareName); ok && named.MiddlewareName() == httpclientprovider.SigV4MiddlewareName {
+				m = middleware.SigV4(p.sigV4Credentials)
+			}
+			middlewares = append(middlewares, m)
+		}
+		return middlewares
+	}
+}
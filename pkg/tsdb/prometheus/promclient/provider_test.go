@@ -4,15 +4,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
 
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
 	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/tsdb/azuremonitor/azcredentials"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 
 	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
 	"github.com/grafana/grafana/pkg/infra/httpclient"
+	"github.com/grafana/grafana/pkg/infra/httpclient/httpclientprovider"
 
 	"github.com/stretchr/testify/require"
 )
@@ -32,17 +36,46 @@ func TestGetClient(t *testing.T) {
 		require.Equal(t, "aps", tc.httpProvider.opts.SigV4.Service)
 	})
 
-	t.Run("it always uses the custom params and custom headers middlewares", func(t *testing.T) {
+	t.Run("it replaces the SigV4 middleware with one caching credentials", func(t *testing.T) {
+		tc := setup(`{"sigV4Auth":true}`)
+
+		_, err := tc.promClientProvider.GetClient(headers)
+		require.Nil(t, err)
+
+		configure := tc.httpProvider.opts.ConfigureMiddleware
+		require.NotNil(t, configure)
+		middlewares := configure(tc.httpProvider.opts, []sdkhttpclient.Middleware{
+			sdkhttpclient.CustomHeadersMiddleware(),
+			httpclientprovider.SigV4Middleware(),
+		})
+		require.Len(t, middlewares, 2)
+		require.Equal(t, "CustomHeaders", middlewares[0].(sdkhttpclient.MiddlewareName).MiddlewareName())
+		require.Equal(t, "prom-sigv4", middlewares[1].(sdkhttpclient.MiddlewareName).MiddlewareName())
+	})
+
+	t.Run("it always uses the metrics, timeout, custom params and custom headers middlewares", func(t *testing.T) {
 		tc := setup()
 
 		_, err := tc.promClientProvider.GetClient(headers)
 		require.Nil(t, err)
 
-		require.Len(t, tc.httpProvider.middlewares(), 2)
+		require.Len(t, tc.httpProvider.middlewares(), 4)
+		require.Contains(t, tc.httpProvider.middlewares(), "prom-metrics")
+		require.Contains(t, tc.httpProvider.middlewares(), "prom-query-timeout")
 		require.Contains(t, tc.httpProvider.middlewares(), "prom-custom-query-parameters")
 		require.Contains(t, tc.httpProvider.middlewares(), "CustomHeaders")
 	})
 
+	t.Run("it leaves the data source timeout to the timeout middleware", func(t *testing.T) {
+		tc := setup(`{"timeout":"60"}`)
+
+		_, err := tc.promClientProvider.GetClient(headers)
+		require.Nil(t, err)
+
+		require.Zero(t, tc.httpProvider.opts.Timeouts.Timeout)
+		require.Equal(t, 30*time.Second, sdkhttpclient.DefaultTimeoutOptions.Timeout)
+	})
+
 	t.Run("extra headers", func(t *testing.T) {
 		t.Run("it sets the headers when 'oauthPassThru' is true and auth headers are passed", func(t *testing.T) {
 			tc := setup(`{"oauthPassThru":true}`)
@@ -70,6 +103,132 @@ func TestGetClient(t *testing.T) {
 		})
 	})
 
+	t.Run("redirect middleware", func(t *testing.T) {
+		t.Run("it adds the redirect middleware when maxRedirects is set", func(t *testing.T) {
+			tc := setup(`{"maxRedirects":3}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.Contains(t, tc.httpProvider.middlewares(), "prom-redirect")
+		})
+
+		t.Run("it does not add the redirect middleware by default", func(t *testing.T) {
+			tc := setup(`{}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.NotContains(t, tc.httpProvider.middlewares(), "prom-redirect")
+		})
+	})
+
+	t.Run("retry middleware", func(t *testing.T) {
+		t.Run("it adds the retry middleware when maxRetries is set", func(t *testing.T) {
+			tc := setup(`{"maxRetries":2,"retryStatusCodes":[429,503],"retryBackoff":"50ms","retryMaxBackoff":"2s"}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.Contains(t, tc.httpProvider.middlewares(), "prom-retry")
+		})
+
+		t.Run("it does not add the retry middleware by default", func(t *testing.T) {
+			tc := setup(`{}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.NotContains(t, tc.httpProvider.middlewares(), "prom-retry")
+		})
+	})
+
+	t.Run("failover middleware", func(t *testing.T) {
+		t.Run("it adds the failover middleware after the retry middleware when secondary URLs are set", func(t *testing.T) {
+			tc := setup(`{"maxRetries":2,"secondaryUrls":["http://prometheus-1:9090"]}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			middlewares := tc.httpProvider.middlewares()
+			require.Equal(t, []string{"prom-retry", "prom-failover"}, middlewares[len(middlewares)-2:])
+		})
+
+		t.Run("it does not add the failover middleware by default", func(t *testing.T) {
+			tc := setup(`{}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.NotContains(t, tc.httpProvider.middlewares(), "prom-failover")
+		})
+	})
+
+	t.Run("compression middleware", func(t *testing.T) {
+		t.Run("it adds the compression middleware when compression is configured", func(t *testing.T) {
+			for _, jsonData := range []string{`{"responseCompression":"force"}`, `{"responseCompression":"disable"}`, `{"compressRequests":true}`} {
+				tc := setup(jsonData)
+
+				_, err := tc.promClientProvider.GetClient(headers)
+				require.Nil(t, err)
+
+				require.Contains(t, tc.httpProvider.middlewares(), "prom-compression", jsonData)
+			}
+		})
+
+		t.Run("it does not add the compression middleware by default", func(t *testing.T) {
+			tc := setup(`{}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.NotContains(t, tc.httpProvider.middlewares(), "prom-compression")
+		})
+	})
+
+	t.Run("azure authentication", func(t *testing.T) {
+		azureSettings := `{
+			"azureCredentials": {
+				"authType": "clientsecret",
+				"azureCloud": "AzureCloud",
+				"tenantId": "tenant",
+				"clientId": "client"
+			},
+			"azureEndpointResourceId": "https://prometheus.monitor.azure.com"
+		}`
+
+		t.Run("it passes the credentials to the http client when enabled", func(t *testing.T) {
+			tc := setupWithFeatures(featuremgmt.WithFeatures(featuremgmt.FlagPrometheusAzureAuth), azureSettings)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.Equal(t, &azcredentials.AzureClientSecretCredentials{
+				AzureCloud:   "AzureCloud",
+				TenantId:     "tenant",
+				ClientId:     "client",
+				ClientSecret: "secret",
+			}, tc.httpProvider.opts.CustomOptions["_azureCredentials"])
+			require.Equal(t, "https://prometheus.monitor.azure.com", tc.httpProvider.opts.CustomOptions["azureEndpointResourceId"])
+		})
+
+		t.Run("it ignores the credentials when disabled", func(t *testing.T) {
+			tc := setup(azureSettings)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.NotContains(t, tc.httpProvider.opts.CustomOptions, "_azureCredentials")
+		})
+
+		t.Run("it fails with invalid credentials", func(t *testing.T) {
+			tc := setupWithFeatures(featuremgmt.WithFeatures(featuremgmt.FlagPrometheusAzureAuth), `{"azureCredentials":{"authType":"password"}}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Error(t, err)
+		})
+	})
+
 	t.Run("force get middleware", func(t *testing.T) {
 		t.Run("it add the force-get middleware when httpMethod is get", func(t *testing.T) {
 			tc := setup(`{"httpMethod":"get"}`)
@@ -77,7 +236,7 @@ func TestGetClient(t *testing.T) {
 			_, err := tc.promClientProvider.GetClient(headers)
 			require.Nil(t, err)
 
-			require.Len(t, tc.httpProvider.middlewares(), 3)
+			require.Len(t, tc.httpProvider.middlewares(), 5)
 			require.Contains(t, tc.httpProvider.middlewares(), "force-http-get")
 		})
 
@@ -87,7 +246,7 @@ func TestGetClient(t *testing.T) {
 			_, err := tc.promClientProvider.GetClient(headers)
 			require.Nil(t, err)
 
-			require.Len(t, tc.httpProvider.middlewares(), 3)
+			require.Len(t, tc.httpProvider.middlewares(), 5)
 			require.Contains(t, tc.httpProvider.middlewares(), "force-http-get")
 		})
 
@@ -127,9 +286,33 @@ func TestGetClient(t *testing.T) {
 			require.NotContains(t, tc.httpProvider.middlewares(), "force-http-get")
 		})
 	})
+
+	t.Run("force post middleware", func(t *testing.T) {
+		t.Run("it adds the force-post middleware when httpMethod is POST", func(t *testing.T) {
+			tc := setup(`{"httpMethod":"POST"}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.Contains(t, tc.httpProvider.middlewares(), "force-http-post")
+		})
+
+		t.Run("it does not add the force-post middleware when httpMethod is not set", func(t *testing.T) {
+			tc := setup(`{}`)
+
+			_, err := tc.promClientProvider.GetClient(headers)
+			require.Nil(t, err)
+
+			require.NotContains(t, tc.httpProvider.middlewares(), "force-http-post")
+		})
+	})
 }
 
 func setup(jsonData ...string) *testContext {
+	return setupWithFeatures(nil, jsonData...)
+}
+
+func setupWithFeatures(features featuremgmt.FeatureToggles, jsonData ...string) *testContext {
 	var rawData []byte
 	if len(jsonData) > 0 {
 		rawData = []byte(jsonData[0])
@@ -138,9 +321,13 @@ func setup(jsonData ...string) *testContext {
 	var jd promclient.JsonData
 	_ = json.Unmarshal(rawData, &jd)
 
-	settings := backend.DataSourceInstanceSettings{URL: "test-url", JSONData: rawData}
+	settings := backend.DataSourceInstanceSettings{
+		URL:                     "test-url",
+		JSONData:                rawData,
+		DecryptedSecureJSONData: map[string]string{"azureClientSecret": "secret"},
+	}
 	hp := &fakeHttpClientProvider{}
-	p := promclient.NewProvider(settings, jd, hp, nil)
+	p := promclient.NewProvider(settings, jd, hp, features, "", nil)
 
 	return &testContext{
 		httpProvider:       hp,
@@ -0,0 +1,132 @@
+package promclient
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+)
+
+// tlsFilesCheckInterval is how often the TLS files of a data source are checked
+// for changes.
+const tlsFilesCheckInterval = 10 * time.Second
+
+// tlsFiles tracks the TLS client certificate, key and CA certificate files of a
+// data source, e.g. those rotated by cert-manager, so that clients are rebuilt
+// with the new files when they change on disk. The files must be in dir, the
+// tls_files_path of the server config.
+type tlsFiles struct {
+	dir        string
+	certFile   string
+	keyFile    string
+	caCertFile string
+
+	mu          sync.Mutex
+	lastChecked time.Time
+	version     string
+}
+
+func (f *tlsFiles) paths() []string {
+	var paths []string
+	for _, path := range []string{f.certFile, f.keyFile, f.caCertFile} {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Version changes when one of the files changes. Files are only checked once
+// every tlsFilesCheckInterval.
+func (f *tlsFiles) Version() string {
+	paths := f.paths()
+	if len(paths) == 0 {
+		return ""
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now := time.Now(); now.Sub(f.lastChecked) >= tlsFilesCheckInterval {
+		f.lastChecked = now
+		versions := make([]string, 0, len(paths))
+		for _, path := range paths {
+			// os.Stat follows symlinks, which Kubernetes swaps to update mounted secrets.
+			info, err := os.Stat(path)
+			if err != nil {
+				versions = append(versions, path+":missing")
+				continue
+			}
+			versions = append(versions, fmt.Sprintf("%s:%d:%d", path, info.ModTime().UnixNano(), info.Size()))
+		}
+		f.version = strings.Join(versions, ",")
+	}
+	return f.version
+}
+
+// configureTLSFiles reads the TLS files of the data source into the TLS options,
+// taking precedence over certificates stored in the data source settings.
+func (p *Provider) configureTLSFiles(opts *sdkhttpclient.Options) error {
+	if len(p.tlsFiles.paths()) == 0 {
+		return nil
+	}
+	if (p.tlsFiles.certFile == "") != (p.tlsFiles.keyFile == "") {
+		return fmt.Errorf("TLS client certificate and key files must be set together")
+	}
+
+	if opts.TLS == nil {
+		opts.TLS = &sdkhttpclient.TLSOptions{}
+	}
+	for _, file := range []struct {
+		name string
+		path string
+		dest *string
+	}{
+		{"TLS client certificate", p.tlsFiles.certFile, &opts.TLS.ClientCertificate},
+		{"TLS client key", p.tlsFiles.keyFile, &opts.TLS.ClientKey},
+		{"TLS CA certificate", p.tlsFiles.caCertFile, &opts.TLS.CACertificate},
+	} {
+		if file.path == "" {
+			continue
+		}
+		if !filepath.IsAbs(file.path) {
+			return fmt.Errorf("%s file %q must be an absolute path", file.name, file.path)
+		}
+		if err := p.tlsFiles.checkPath(file.path); err != nil {
+			return fmt.Errorf("%s file %q: %w", file.name, file.path, err)
+		}
+		content, err := os.ReadFile(file.path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s file: %w", file.name, err)
+		}
+		*file.dest = string(content)
+	}
+	return nil
+}
+
+// checkPath rejects paths outside of the TLS files directory. Symlinks are
+// resolved first so that a link in the directory can't point outside of it.
+func (f *tlsFiles) checkPath(path string) error {
+	if f.dir == "" {
+		return fmt.Errorf("TLS files are disabled, set tls_files_path in the [datasources] section of the server config")
+	}
+	dir, err := filepath.Abs(f.dir)
+	if err != nil {
+		return err
+	}
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = resolved
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve path: %w", err)
+	}
+	rel, err := filepath.Rel(dir, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("not in the TLS files directory %q", f.dir)
+	}
+	return nil
+}
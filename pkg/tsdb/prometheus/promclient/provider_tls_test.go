@@ -0,0 +1,108 @@
+package promclient
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(certFile, []byte("cert"), 0600))
+	require.NoError(t, os.WriteFile(keyFile, []byte("key"), 0600))
+	require.NoError(t, os.WriteFile(caFile, []byte("ca"), 0600))
+
+	t.Run("it reads the files into the TLS options", func(t *testing.T) {
+		p := &Provider{tlsFiles: &tlsFiles{dir: dir, certFile: certFile, keyFile: keyFile, caCertFile: caFile}}
+		opts := sdkhttpclient.Options{TLS: &sdkhttpclient.TLSOptions{ServerName: "prometheus", ClientCertificate: "stored"}}
+		require.NoError(t, p.configureTLSFiles(&opts))
+		require.Equal(t, &sdkhttpclient.TLSOptions{
+			ServerName:        "prometheus",
+			ClientCertificate: "cert",
+			ClientKey:         "key",
+			CACertificate:     "ca",
+		}, opts.TLS)
+	})
+
+	t.Run("it leaves the TLS options alone without files", func(t *testing.T) {
+		p := &Provider{tlsFiles: &tlsFiles{}}
+		opts := sdkhttpclient.Options{}
+		require.NoError(t, p.configureTLSFiles(&opts))
+		require.Nil(t, opts.TLS)
+		require.Empty(t, p.tlsFiles.Version())
+	})
+
+	t.Run("it requires the certificate and key together", func(t *testing.T) {
+		p := &Provider{tlsFiles: &tlsFiles{dir: dir, certFile: certFile}}
+		err := p.configureTLSFiles(&sdkhttpclient.Options{})
+		require.EqualError(t, err, "TLS client certificate and key files must be set together")
+	})
+
+	t.Run("it requires absolute paths", func(t *testing.T) {
+		p := &Provider{tlsFiles: &tlsFiles{dir: dir, caCertFile: "ca.crt"}}
+		err := p.configureTLSFiles(&sdkhttpclient.Options{})
+		require.EqualError(t, err, `TLS CA certificate file "ca.crt" must be an absolute path`)
+	})
+
+	t.Run("it fails on missing files", func(t *testing.T) {
+		p := &Provider{tlsFiles: &tlsFiles{dir: dir, caCertFile: filepath.Join(dir, "missing.crt")}}
+		err := p.configureTLSFiles(&sdkhttpclient.Options{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to resolve path")
+	})
+
+	t.Run("it rejects files without a TLS files directory", func(t *testing.T) {
+		p := &Provider{tlsFiles: &tlsFiles{caCertFile: caFile}}
+		err := p.configureTLSFiles(&sdkhttpclient.Options{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "TLS files are disabled")
+	})
+
+	t.Run("it rejects files outside of the TLS files directory", func(t *testing.T) {
+		outside := filepath.Join(t.TempDir(), "secret")
+		require.NoError(t, os.WriteFile(outside, []byte("secret"), 0600))
+		link := filepath.Join(dir, "link.crt")
+		require.NoError(t, os.Symlink(outside, link))
+
+		rel, err := filepath.Rel(dir, outside)
+		require.NoError(t, err)
+
+		for _, path := range []string{outside, dir + string(filepath.Separator) + rel, link} {
+			p := &Provider{tlsFiles: &tlsFiles{dir: dir, caCertFile: path}}
+			err := p.configureTLSFiles(&sdkhttpclient.Options{})
+			require.Error(t, err, path)
+			require.Contains(t, err.Error(), "not in the TLS files directory", path)
+		}
+	})
+
+	t.Run("it follows symlinks within the TLS files directory", func(t *testing.T) {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "..data", "mounted.crt"), []byte("mounted"), 0600))
+		link := filepath.Join(dir, "mounted.crt")
+		require.NoError(t, os.Symlink(filepath.Join(dir, "..data", "mounted.crt"), link))
+
+		p := &Provider{tlsFiles: &tlsFiles{dir: dir, caCertFile: link}}
+		opts := sdkhttpclient.Options{}
+		require.NoError(t, p.configureTLSFiles(&opts))
+		require.Equal(t, "mounted", opts.TLS.CACertificate)
+	})
+
+	t.Run("the version changes with the files", func(t *testing.T) {
+		files := &tlsFiles{certFile: certFile, keyFile: keyFile}
+		version := files.Version()
+		require.NotEmpty(t, version)
+
+		require.NoError(t, os.WriteFile(certFile, []byte("rotated cert"), 0600))
+		require.Equal(t, version, files.Version(), "files are only checked once per interval")
+
+		files.lastChecked = time.Time{}
+		require.NotEqual(t, version, files.Version())
+	})
+}
@@ -0,0 +1,106 @@
+package promclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+const remoteReadEndpoint = "/api/v1/read"
+
+// ErrRemoteReadUnsupported is returned by RemoteRead when the server does not
+// answer remote read requests with protobuf.
+var ErrRemoteReadUnsupported = errors.New("remote read is not supported")
+
+var remoteReadMatcherTypes = map[labels.MatchType]prompb.LabelMatcher_Type{
+	labels.MatchEqual:     prompb.LabelMatcher_EQ,
+	labels.MatchNotEqual:  prompb.LabelMatcher_NEQ,
+	labels.MatchRegexp:    prompb.LabelMatcher_RE,
+	labels.MatchNotRegexp: prompb.LabelMatcher_NRE,
+}
+
+// RemoteRead returns the raw samples of the series matching matchers between
+// start and end, using the snappy compressed protobuf format of the remote read
+// API. Decoding it takes a fraction of the CPU of decoding JSON.
+func (c *Client) RemoteRead(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) ([]*Series, error) {
+	query := &prompb.Query{
+		StartTimestampMs: start.UnixMilli(),
+		EndTimestampMs:   end.UnixMilli(),
+	}
+	for _, m := range matchers {
+		query.Matchers = append(query.Matchers, &prompb.LabelMatcher{Type: remoteReadMatcherTypes[m.Type], Name: m.Name, Value: m.Value})
+	}
+	readRequest := &prompb.ReadRequest{
+		Queries:               []*prompb.Query{query},
+		AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES},
+	}
+	b, err := readRequest.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.client.URL(remoteReadEndpoint, nil).String(), bytes.NewReader(snappy.Encode(nil, b)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	switch {
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented:
+		return nil, ErrRemoteReadUnsupported
+	case res.StatusCode/100 != 2:
+		return nil, fmt.Errorf("server error: %d", res.StatusCode)
+	case res.Header.Get("Content-Type") != "application/x-protobuf":
+		return nil, ErrRemoteReadUnsupported
+	}
+
+	compressed, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	b, err = snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	var readResponse prompb.ReadResponse
+	if err := readResponse.Unmarshal(b); err != nil {
+		return nil, err
+	}
+	if len(readResponse.Results) == 0 {
+		return []*Series{}, nil
+	}
+
+	series := make([]*Series, 0, len(readResponse.Results[0].Timeseries))
+	for _, ts := range readResponse.Results[0].Timeseries {
+		s := &Series{}
+		s.Metric = make(model.Metric, len(ts.Labels))
+		for _, l := range ts.Labels {
+			s.Metric[model.LabelName(l.Name)] = model.LabelValue(l.Value)
+		}
+		s.Values = make([]model.SamplePair, len(ts.Samples))
+		for i, sample := range ts.Samples {
+			s.Values[i] = model.SamplePair{Timestamp: model.Time(sample.Timestamp), Value: model.SampleValue(sample.Value)}
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
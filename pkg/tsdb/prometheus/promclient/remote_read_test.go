@@ -0,0 +1,74 @@
+package promclient_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_RemoteRead(t *testing.T) {
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "__name__", "up")}
+	start, end := time.Unix(1641889530, 0), time.Unix(1641889590, 0)
+
+	t.Run("it sends the matchers and decodes the series", func(t *testing.T) {
+		var readRequest prompb.ReadRequest
+		client, err := promclient.NewClient("http://localhost:9090", roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "/api/v1/read", req.URL.Path)
+			require.Equal(t, "snappy", req.Header.Get("Content-Encoding"))
+			compressed, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			b, err := snappy.Decode(nil, compressed)
+			require.NoError(t, err)
+			require.NoError(t, readRequest.Unmarshal(b))
+
+			return remoteReadResponse(t, &prompb.ReadResponse{Results: []*prompb.QueryResult{{Timeseries: []*prompb.TimeSeries{{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "up"}, {Name: "job", Value: "a"}},
+				Samples: []prompb.Sample{{Timestamp: 1641889530000, Value: 1}, {Timestamp: 1641889545000, Value: 0}},
+			}}}}}), nil
+		}))
+		require.NoError(t, err)
+
+		series, err := client.RemoteRead(context.Background(), matchers, start, end)
+		require.NoError(t, err)
+
+		require.Len(t, readRequest.Queries, 1)
+		require.Equal(t, int64(1641889530000), readRequest.Queries[0].StartTimestampMs)
+		require.Equal(t, int64(1641889590000), readRequest.Queries[0].EndTimestampMs)
+		require.Equal(t, []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "up"}}, readRequest.Queries[0].Matchers)
+
+		require.Len(t, series, 1)
+		require.Equal(t, model.Metric{"__name__": "up", "job": "a"}, series[0].Metric)
+		require.Equal(t, []model.SamplePair{{Timestamp: 1641889530000, Value: 1}, {Timestamp: 1641889545000, Value: 0}}, series[0].Values)
+	})
+
+	t.Run("it reports servers without remote read", func(t *testing.T) {
+		client := newStreamingClient(t, http.StatusNotFound, "404 page not found")
+		_, err := client.RemoteRead(context.Background(), matchers, start, end)
+		require.ErrorIs(t, err, promclient.ErrRemoteReadUnsupported)
+
+		client = newStreamingClient(t, http.StatusOK, "{}")
+		_, err = client.RemoteRead(context.Background(), matchers, start, end)
+		require.ErrorIs(t, err, promclient.ErrRemoteReadUnsupported)
+	})
+}
+
+func remoteReadResponse(t *testing.T, readResponse *prompb.ReadResponse) *http.Response {
+	t.Helper()
+	b, err := readResponse.Marshal()
+	require.NoError(t, err)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/x-protobuf"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(snappy.Encode(nil, b))),
+	}
+}
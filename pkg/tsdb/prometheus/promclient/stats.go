@@ -0,0 +1,41 @@
+package promclient
+
+// QueryStats are the statistics Prometheus returns for queries run with `stats=all`.
+type QueryStats struct {
+	Timings QueryTimings `json:"timings"`
+	Samples QuerySamples `json:"samples"`
+}
+
+// QueryTimings are the durations of the phases of a query, in seconds.
+type QueryTimings struct {
+	EvalTotalTime        float64 `json:"evalTotalTime"`
+	ResultSortTime       float64 `json:"resultSortTime"`
+	QueryPreparationTime float64 `json:"queryPreparationTime"`
+	InnerEvalTime        float64 `json:"innerEvalTime"`
+	ExecQueueTime        float64 `json:"execQueueTime"`
+	ExecTotalTime        float64 `json:"execTotalTime"`
+}
+
+// QuerySamples counts the samples a query loaded.
+type QuerySamples struct {
+	TotalQueryableSamples int64 `json:"totalQueryableSamples"`
+	PeakSamples           int64 `json:"peakSamples"`
+}
+
+// Add accumulates the statistics of another request of the same query, e.g. of
+// another sub-range. Samples and timings add up, the peak is the highest one.
+func (s *QueryStats) Add(o *QueryStats) {
+	if o == nil {
+		return
+	}
+	s.Timings.EvalTotalTime += o.Timings.EvalTotalTime
+	s.Timings.ResultSortTime += o.Timings.ResultSortTime
+	s.Timings.QueryPreparationTime += o.Timings.QueryPreparationTime
+	s.Timings.InnerEvalTime += o.Timings.InnerEvalTime
+	s.Timings.ExecQueueTime += o.Timings.ExecQueueTime
+	s.Timings.ExecTotalTime += o.Timings.ExecTotalTime
+	s.Samples.TotalQueryableSamples += o.Samples.TotalQueryableSamples
+	if o.Samples.PeakSamples > s.Samples.PeakSamples {
+		s.Samples.PeakSamples = o.Samples.PeakSamples
+	}
+}
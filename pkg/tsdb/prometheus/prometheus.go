@@ -5,42 +5,60 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
 	"github.com/grafana/grafana/pkg/infra/httpclient"
+	"github.com/grafana/grafana/pkg/infra/localcache"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/grafana/grafana/pkg/services/oauthtoken"
+	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
 var (
-	plog         = log.New("tsdb.prometheus")
-	legendFormat = regexp.MustCompile(`\{\{\s*(.+?)\s*\}\}`)
-	safeRes      = 11000
+	plog    = log.New("tsdb.prometheus")
+	safeRes = 11000
 )
 
 type Service struct {
 	intervalCalculator intervalv2.Calculator
 	im                 instancemgmt.InstanceManager
 	tracer             tracing.Tracer
+	resourceHandler    backend.CallResourceHandler
+	resourceCache      *localcache.CacheService
+	oAuthTokenService  oauthtoken.OAuthTokenService
 }
 
-func ProvideService(httpClientProvider httpclient.Provider, tracer tracing.Tracer) *Service {
+func ProvideService(cfg *setting.Cfg, httpClientProvider httpclient.Provider, tracer tracing.Tracer, features featuremgmt.FeatureToggles,
+	oAuthTokenService oauthtoken.OAuthTokenService) *Service {
 	plog.Debug("initializing")
-	return &Service{
+	s := &Service{
 		intervalCalculator: intervalv2.NewCalculator(),
-		im:                 datasource.NewInstanceManager(newInstanceSettings(httpClientProvider)),
+		im:                 datasource.NewInstanceManager(newInstanceSettings(httpClientProvider, features, cfg.DataSourceTLSFilesPath)),
 		tracer:             tracer,
+		resourceCache:      localcache.New(metadataCacheTTL, 2*metadataCacheTTL),
+		oAuthTokenService:  oAuthTokenService,
 	}
+	s.resourceHandler = httpadapter.New(s.newResourceMux())
+	return s
 }
 
-func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.InstanceFactoryFunc {
+func (s *Service) CallResource(ctx context.Context, req *backend.CallResourceRequest, sender backend.CallResourceResponseSender) error {
+	return s.resourceHandler.CallResource(ctx, req, sender)
+}
+
+func newInstanceSettings(httpClientProvider httpclient.Provider, features featuremgmt.FeatureToggles, tlsFilesPath string) datasource.InstanceFactoryFunc {
 	return func(settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
 		var jsonData promclient.JsonData
 		err := json.Unmarshal(settings.JSONData, &jsonData)
@@ -48,17 +66,120 @@ func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.Inst
 			return nil, fmt.Errorf("error reading settings: %w", err)
 		}
 
-		p := promclient.NewProvider(settings, jsonData, httpClientProvider, plog)
+		if m := strings.ToUpper(jsonData.Method); m != "" && m != http.MethodGet && m != http.MethodPost {
+			return nil, fmt.Errorf("error reading settings: invalid http method %q", jsonData.Method)
+		}
+
+		var calculatorMinInterval time.Duration
+		if jsonData.CalculatorMinInterval != "" {
+			calculatorMinInterval, err = intervalv2.ParseIntervalStringToTimeDuration(jsonData.CalculatorMinInterval)
+			if err != nil {
+				return nil, fmt.Errorf("error reading settings: invalid calculator min interval: %w", err)
+			}
+		}
+
+		var splitInterval time.Duration
+		if jsonData.SplitInterval != "" {
+			splitInterval, err = intervalv2.ParseIntervalStringToTimeDuration(jsonData.SplitInterval)
+			if err != nil {
+				return nil, fmt.Errorf("error reading settings: invalid split interval: %w", err)
+			}
+		}
+		var exemplarChunkInterval time.Duration
+		if jsonData.ExemplarChunkInterval != "" {
+			exemplarChunkInterval, err = intervalv2.ParseIntervalStringToTimeDuration(jsonData.ExemplarChunkInterval)
+			if err != nil {
+				return nil, fmt.Errorf("error reading settings: invalid exemplar chunk interval: %w", err)
+			}
+		}
+		splitConcurrency := jsonData.SplitConcurrency
+		if splitConcurrency <= 0 {
+			splitConcurrency = defaultSplitConcurrency
+		}
+		queryConcurrency := jsonData.QueryConcurrency
+		if queryConcurrency <= 0 {
+			queryConcurrency = defaultQueryConcurrency
+		}
+
+		var incrementalCache *IncrementalQueryCache
+		if jsonData.IncrementalQuerying {
+			overlap := defaultIncrementalQueryOverlap
+			if jsonData.IncrementalQueryOverlapWindow != "" {
+				overlap, err = intervalv2.ParseIntervalStringToTimeDuration(jsonData.IncrementalQueryOverlapWindow)
+				if err != nil {
+					return nil, fmt.Errorf("error reading settings: invalid incremental query overlap window: %w", err)
+				}
+			}
+			incrementalCache, err = NewIncrementalQueryCache(overlap)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var recordingRules *RecordingRules
+		if jsonData.RecordingRuleRewrite {
+			recordingRules, err = NewRecordingRules()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var remoteReadLookback time.Duration
+		if jsonData.RemoteReadLookbackDelta != "" {
+			remoteReadLookback, err = intervalv2.ParseIntervalStringToTimeDuration(jsonData.RemoteReadLookbackDelta)
+			if err != nil {
+				return nil, fmt.Errorf("error reading settings: invalid remote read lookback delta: %w", err)
+			}
+		}
+
+		labelsCacheTTL := defaultLabelsCacheTTL
+		if jsonData.LabelsCacheTTL != "" {
+			labelsCacheTTL, err = intervalv2.ParseIntervalStringToTimeDuration(jsonData.LabelsCacheTTL)
+			if err != nil {
+				return nil, fmt.Errorf("error reading settings: invalid labels cache TTL: %w", err)
+			}
+		}
+
+		var healthCheckQuery string
+		if jsonData.HealthCheckProbe {
+			healthCheckQuery = jsonData.HealthCheckQuery
+			if healthCheckQuery == "" {
+				healthCheckQuery = defaultHealthCheckQuery
+			}
+		}
+
+		p := promclient.NewProvider(settings, jsonData, httpClientProvider, features, tlsFilesPath, plog)
 		pc, err := promclient.NewProviderCache(p)
 		if err != nil {
 			return nil, err
 		}
 
 		mdl := DatasourceInfo{
-			ID:           settings.ID,
-			URL:          settings.URL,
-			TimeInterval: jsonData.TimeInterval,
-			getClient:    pc.GetClient,
+			ID:            settings.ID,
+			UID:           settings.UID,
+			URL:           settings.URL,
+			TimeInterval:  jsonData.TimeInterval,
+			ClampEndToNow: jsonData.ClampEndToNow,
+			ServerClock:   p.ServerClock(),
+			getClient:     pc.GetClient,
+
+			CalculatorMinInterval: calculatorMinInterval,
+			MaxLabelsPerField:     jsonData.MaxLabelsPerField,
+			StreamRangeResponses:  jsonData.StreamRangeResponses,
+			MaxSeries:             jsonData.MaxSeries,
+			SplitInterval:         splitInterval,
+			SplitConcurrency:      splitConcurrency,
+			ExemplarChunkInterval: exemplarChunkInterval,
+			QueryConcurrency:      queryConcurrency,
+			IncrementalCache:      incrementalCache,
+			LabelsCacheTTL:        labelsCacheTTL,
+			RemoteRead:            jsonData.RemoteRead,
+			RemoteReadLookback:    remoteReadLookback,
+			RecordingRules:        recordingRules,
+			HealthCheckQuery:      healthCheckQuery,
+			FlavorDetector:        &FlavorDetector{},
+
+			ExemplarTraceIdDestinations: jsonData.ExemplarTraceIdDestinations,
 		}
 
 		return mdl, nil
@@ -87,6 +208,15 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 	return result, err
 }
 
+// calculatorFor returns the interval calculator to use for the data source.
+// The service-wide calculator is used unless the data source overrides its minimum.
+func (s *Service) calculatorFor(dsInfo *DatasourceInfo) intervalv2.Calculator {
+	if dsInfo.CalculatorMinInterval == 0 {
+		return s.intervalCalculator
+	}
+	return intervalv2.NewCalculator(intervalv2.CalculatorOptions{MinInterval: dsInfo.CalculatorMinInterval})
+}
+
 func (s *Service) getDSInfo(pluginCtx backend.PluginContext) (*DatasourceInfo, error) {
 	i, err := s.im.Get(pluginCtx)
 	if err != nil {
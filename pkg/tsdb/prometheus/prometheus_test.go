@@ -0,0 +1,108 @@
+package prometheus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_runQueriesConcurrently(t *testing.T) {
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	s := &Service{tracer: tracer}
+
+	start := time.Unix(0, 0)
+	var queries []*PrometheusQuery
+	for _, refID := range []string{"A", "B", "C", "D", "E"} {
+		queries = append(queries, &PrometheusQuery{
+			Expr:       "up",
+			Step:       time.Hour,
+			Start:      start,
+			End:        start.Add(2 * time.Hour),
+			RefId:      refID,
+			RangeQuery: true,
+
+			StreamRangeResponse: true,
+		})
+	}
+
+	client := &splitClient{}
+	res, err := s.runQueries(context.Background(), client, queries, 2)
+	require.NoError(t, err)
+	require.Len(t, client.ranges, 5)
+	require.LessOrEqual(t, client.maxActive, 2)
+
+	require.Len(t, res.Responses, 5)
+	for _, query := range queries {
+		require.NoError(t, res.Responses[query.RefId].Error)
+		require.Len(t, res.Responses[query.RefId].Frames, 1)
+	}
+
+	client = &splitClient{failAt: start}
+	res, err = s.runQueries(context.Background(), client, queries[:2], 2)
+	require.NoError(t, err)
+	require.Error(t, res.Responses["A"].Error)
+	require.Error(t, res.Responses["B"].Error)
+}
+
+func TestService_runQueriesInRequestOrder(t *testing.T) {
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	s := &Service{tracer: tracer}
+
+	var queries []*PrometheusQuery
+	for i, refID := range []string{"C", "A", "E", "B", "D"} {
+		start := time.Unix(int64(i)*3600, 0)
+		queries = append(queries, &PrometheusQuery{
+			Expr:       "up",
+			Step:       time.Hour,
+			Start:      start,
+			End:        start.Add(time.Hour),
+			RefId:      refID,
+			RangeQuery: true,
+
+			StreamRangeResponse: true,
+		})
+	}
+
+	client := &splitClient{}
+	_, err = s.runQueries(context.Background(), client, queries, 1)
+	require.NoError(t, err)
+	require.Len(t, client.ranges, 5)
+	for i, query := range queries {
+		require.Equal(t, query.Start, client.ranges[i].Start)
+	}
+}
+
+func TestService_runQueryInTheFuture(t *testing.T) {
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	s := &Service{tracer: tracer}
+
+	now := time.Now()
+	query := &PrometheusQuery{
+		Expr:       "up",
+		Step:       time.Minute,
+		Start:      now.Add(time.Hour),
+		End:        now,
+		EndClamped: true,
+		RefId:      "A",
+		RangeQuery: true,
+
+		StreamRangeResponse: true,
+	}
+
+	client := &splitClient{}
+	res, err := s.runQueries(context.Background(), client, []*PrometheusQuery{query}, 1)
+	require.NoError(t, err)
+	require.Empty(t, client.ranges)
+
+	dr := res.Responses["A"]
+	require.NoError(t, dr.Error)
+	require.Len(t, dr.Frames, 1)
+	require.Equal(t, 0, dr.Frames[0].Rows())
+	require.Len(t, dr.Frames[0].Meta.Notices, 1)
+}
@@ -0,0 +1,59 @@
+package prometheus
+
+import (
+	"errors"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+var errStopInspect = errors.New("stop inspecting")
+
+// groupingLabels returns the labels of the outermost `by (...)` clause of expr.
+// It returns nil when expr cannot be parsed or its outermost aggregation has no `by` clause.
+func groupingLabels(expr string) []string {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil
+	}
+
+	var grouping []string
+	parser.Inspect(node, func(n parser.Node, _ []parser.Node) error {
+		agg, ok := n.(*parser.AggregateExpr)
+		if !ok {
+			return nil
+		}
+		if !agg.Without {
+			grouping = agg.Grouping
+		}
+		return errStopInspect
+	})
+
+	return grouping
+}
+
+// rateWindow returns the range of the outermost rate() or irate() call of expr, e.g. "5m".
+// It returns an empty string when expr cannot be parsed or has no such call.
+func rateWindow(expr string) string {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return ""
+	}
+
+	var window string
+	parser.Inspect(node, func(n parser.Node, _ []parser.Node) error {
+		call, ok := n.(*parser.Call)
+		if !ok || (call.Func.Name != "rate" && call.Func.Name != "irate") || len(call.Args) == 0 {
+			return nil
+		}
+		switch arg := call.Args[0].(type) {
+		case *parser.MatrixSelector:
+			window = model.Duration(arg.Range).String()
+		case *parser.SubqueryExpr:
+			window = model.Duration(arg.Range).String()
+		}
+		return errStopInspect
+	})
+
+	return window
+}
@@ -0,0 +1,35 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateWindow(t *testing.T) {
+	tt := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "rate(x[5m])", expected: "5m"},
+		{expr: "sum by (job) (irate(x[1m30s]))", expected: "1m30s"},
+		{expr: "rate(x[1h]) / rate(y[5m])", expected: "1h"},
+		{expr: "max_over_time(rate(x[1m])[10m:1m])", expected: "1m"},
+		{expr: "rate(rate(x[1m])[10m:1m])", expected: "10m"},
+		{expr: "x", expected: ""},
+		{expr: "rate(", expected: ""},
+	}
+
+	for _, test := range tt {
+		t.Run(test.expr, func(t *testing.T) {
+			require.Equal(t, test.expected, rateWindow(test.expr))
+		})
+	}
+}
+
+func TestGroupingLabels(t *testing.T) {
+	require.Equal(t, []string{"job", "instance"}, groupingLabels("sum by (job, instance) (up)"))
+	require.Equal(t, []string{"job"}, groupingLabels("max by (job) (sum by (job, instance) (up))"))
+	require.Nil(t, groupingLabels("sum without (job) (up)"))
+	require.Nil(t, groupingLabels("up"))
+}
@@ -0,0 +1,166 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The golden tests in framing_test.go read Prometheus responses stored in
+// testdata. To add a fixture for a new result shape, write a
+// testdata/<name>.query.json with an Expr and one of RangeQuery, InstantQuery
+// or ExemplarQuery, add <name> to the test table and record the response of a
+// real Prometheus with:
+//
+//	go test ./pkg/tsdb/prometheus -run TestStoredResponses -prometheus.record-url=http://localhost:9090
+//
+// The response is stored sanitized in testdata/<name>.result.json, and the
+// golden frames in testdata/<name>.result.golden.txt are regenerated from it.
+var recordURL = flag.String("prometheus.record-url", "", "record the responses of the golden tests from the Prometheus at this URL")
+
+// sanitizedLabels are the labels whose values identify the recording
+// environment. They are replaced by stable placeholders in recorded responses.
+var sanitizedLabels = map[string]struct{}{
+	"instance": {},
+	"host":     {},
+	"hostname": {},
+	"node":     {},
+	"pod":      {},
+	"ip":       {},
+}
+
+func recordPrometheusResponse(address string, query PrometheusQuery, fileName string) error {
+	if query.Expr == "" {
+		return fmt.Errorf("cannot record %s: the query has no Expr", fileName)
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	// Record the same range the data source queries.
+	timeRange := queryTimeRange(&query)
+	params := url.Values{}
+	params.Set("query", query.Expr)
+	switch {
+	case query.RangeQuery:
+		u.Path += "/api/v1/query_range"
+		params.Set("start", formatRecordTime(timeRange.Start))
+		params.Set("end", formatRecordTime(timeRange.End))
+		params.Set("step", strconv.FormatFloat(query.Step.Seconds(), 'f', -1, 64))
+	case query.InstantQuery:
+		u.Path += "/api/v1/query"
+		params.Set("time", formatRecordTime(query.End))
+	case query.ExemplarQuery:
+		u.Path += "/api/v1/query_exemplars"
+		params.Set("start", formatRecordTime(timeRange.Start))
+		params.Set("end", formatRecordTime(timeRange.End))
+	default:
+		return fmt.Errorf("cannot record %s: the query has no query type", fileName)
+	}
+	u.RawQuery = params.Encode()
+
+	res, err := http.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("recording %s: unexpected status %s: %s", fileName, res.Status, body)
+	}
+
+	sanitized, err := sanitizePrometheusResponse(body)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, sanitized, 0600)
+}
+
+func formatRecordTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
+
+// sanitizePrometheusResponse replaces the values of the sanitizedLabels in the
+// series and exemplars of a response by "<label>-<n>", numbered in the order
+// the values first appear, and indents the response for review.
+func sanitizePrometheusResponse(body []byte) ([]byte, error) {
+	var response map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+
+	placeholders := map[string]map[string]string{}
+	sanitize := func(labels interface{}) {
+		metric, ok := labels.(map[string]interface{})
+		if !ok {
+			return
+		}
+		names := make([]string, 0, len(metric))
+		for name := range metric {
+			names = append(names, name)
+		}
+		// Number the values in a stable order, map iteration is random.
+		sort.Strings(names)
+		for _, name := range names {
+			if _, ok := sanitizedLabels[name]; !ok {
+				continue
+			}
+			value := fmt.Sprint(metric[name])
+			if placeholders[name] == nil {
+				placeholders[name] = map[string]string{}
+			}
+			placeholder, ok := placeholders[name][value]
+			if !ok {
+				placeholder = fmt.Sprintf("%s-%d", name, len(placeholders[name])+1)
+				placeholders[name][value] = placeholder
+			}
+			metric[name] = placeholder
+		}
+	}
+
+	switch data := response["data"].(type) {
+	case map[string]interface{}:
+		if result, ok := data["result"].([]interface{}); ok {
+			for _, series := range result {
+				if s, ok := series.(map[string]interface{}); ok {
+					sanitize(s["metric"])
+				}
+			}
+		}
+	case []interface{}:
+		// Exemplar responses hold a list of series with their exemplars.
+		for _, series := range data {
+			s, ok := series.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			sanitize(s["seriesLabels"])
+			if exemplars, ok := s["exemplars"].([]interface{}); ok {
+				for _, exemplar := range exemplars {
+					if e, ok := exemplar.(map[string]interface{}); ok {
+						sanitize(e["labels"])
+					}
+				}
+			}
+		}
+	}
+
+	sanitized, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(sanitized, '\n'), nil
+}
@@ -0,0 +1,200 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/promql/parser"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// recordingRulesRefreshInterval is how long the recording rules of a data
+	// source are used before they are fetched again, also after a failed fetch.
+	recordingRulesRefreshInterval = 5 * time.Minute
+	// recordingRulesCacheSize is the number of identities whose rules are kept.
+	recordingRulesCacheSize = 100
+)
+
+// nameDroppingFunctions are the functions whose results never keep the metric
+// name of their argument.
+var nameDroppingFunctions = map[string]bool{
+	"rate":               true,
+	"irate":              true,
+	"increase":           true,
+	"delta":              true,
+	"idelta":             true,
+	"deriv":              true,
+	"histogram_quantile": true,
+}
+
+// RecordingRules maps the expressions of the recording rules of a data source to
+// the names of the rules, so that queries with the same expression can read the
+// precomputed series of the rule instead. The rules are kept per identity, the
+// headers of the requests, since forwarded OAuth identities and tenant headers
+// like X-Scope-OrgID may see different rules.
+type RecordingRules struct {
+	mu      sync.Mutex
+	entries *lru.Cache
+	fetches singleflight.Group
+}
+
+type recordingRulesEntry struct {
+	lastAttempt time.Time
+	rules       map[string]string
+}
+
+// NewRecordingRules returns a mapping that is filled from the rules of the data
+// source on the first lookup of every identity.
+func NewRecordingRules() (*RecordingRules, error) {
+	entries, err := lru.New(recordingRulesCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordingRules{entries: entries}, nil
+}
+
+// lookup returns the name of the recording rule with the same expression as expr
+// among the rules visible with headers. The rules are fetched again when they are
+// older than the refresh interval, once for concurrent lookups.
+func (r *RecordingRules) lookup(ctx context.Context, client apiv1.API, headers map[string]string, expr string) (string, bool) {
+	key, ok := ruleExprKey(expr)
+	if !ok {
+		return "", false
+	}
+
+	identity := hashHeaders(headers)
+	entry := r.entry(identity)
+
+	r.mu.Lock()
+	stale := time.Since(entry.lastAttempt) >= recordingRulesRefreshInterval
+	r.mu.Unlock()
+
+	if stale {
+		_, _, _ = r.fetches.Do(identity, func() (interface{}, error) {
+			rules, err := fetchRecordingRules(ctx, client)
+
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			entry.lastAttempt = time.Now()
+			if err != nil {
+				// The previous rules stay in use until the next attempt.
+				plog.Warn("Failed to fetch the recording rules", "err", err)
+				return nil, nil
+			}
+			entry.rules = rules
+			return nil, nil
+		})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name, ok := entry.rules[key]
+	return name, ok
+}
+
+// entry returns the rules of the identity, adding an empty entry when there is
+// none.
+func (r *RecordingRules) entry(identity string) *recordingRulesEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if v, ok := r.entries.Get(identity); ok {
+		return v.(*recordingRulesEntry)
+	}
+	entry := &recordingRulesEntry{}
+	r.entries.Add(identity, entry)
+	return entry
+}
+
+// fetchRecordingRules returns the names of the healthy recording rules without
+// extra labels by the keys of their expressions. Only rules whose expressions drop
+// the metric name are included, so that a query answered from a rule returns the
+// same series as the expression would. The first of several rules with the same
+// expression wins.
+func fetchRecordingRules(ctx context.Context, client apiv1.API) (map[string]string, error) {
+	result, err := client.Rules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := map[string]string{}
+	for _, group := range result.Groups {
+		for _, r := range group.Rules {
+			recording, ok := r.(apiv1.RecordingRule)
+			if !ok || recording.Health != apiv1.RuleHealthGood || len(recording.Labels) > 0 {
+				continue
+			}
+			node, err := parser.ParseExpr(recording.Query)
+			if err != nil || !dropsMetricName(node) {
+				continue
+			}
+			key := node.String()
+			if _, exists := rules[key]; !exists {
+				rules[key] = recording.Name
+			}
+		}
+	}
+	return rules, nil
+}
+
+// ruleExprKey returns the expression in the canonical format of the PromQL
+// printer, so that expressions that only differ in formatting match.
+func ruleExprKey(expr string) (string, bool) {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", false
+	}
+	return node.String(), true
+}
+
+func dropsMetricName(node parser.Expr) bool {
+	switch n := node.(type) {
+	case *parser.ParenExpr:
+		return dropsMetricName(n.Expr)
+	case *parser.AggregateExpr:
+		return n.Op != parser.TOPK && n.Op != parser.BOTTOMK
+	case *parser.Call:
+		return nameDroppingFunctions[n.Func.Name]
+	}
+	return false
+}
+
+// recordingRuleExpr returns the expression that reads the series of a recording
+// rule. The rule series carry the rule name as metric name, which is removed to
+// return the same series as the expression of the rule.
+func recordingRuleExpr(name string) string {
+	return fmt.Sprintf(`label_replace(%s, "__name__", "", "", "")`, name)
+}
+
+// sourceExpr returns the expression of the query as it was written, before it
+// was rewritten to read a recording rule.
+func (query *PrometheusQuery) sourceExpr() string {
+	if query.RecordingRule != "" {
+		return query.OriginalExpr
+	}
+	return query.Expr
+}
+
+// rewriteRecordingRules replaces the expressions of the queries that match a
+// recording rule by the series of the rule. Queries with exemplars are not
+// rewritten, as recording rules have no exemplars.
+func rewriteRecordingRules(ctx context.Context, client apiv1.API, rules *RecordingRules, headers map[string]string, queries []*PrometheusQuery) {
+	for _, query := range queries {
+		if query.ExemplarQuery {
+			continue
+		}
+		name, ok := rules.lookup(ctx, client, headers, query.Expr)
+		if !ok {
+			continue
+		}
+		plog.Debug("Answering query from a recording rule", "query", query.Expr, "rule", name)
+		query.RecordingRule = name
+		query.OriginalExpr = query.Expr
+		query.Expr = recordingRuleExpr(name)
+	}
+}
@@ -0,0 +1,166 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingRules(t *testing.T) {
+	client := &recordingRulesClient{rules: apiv1.RulesResult{Groups: []apiv1.RuleGroup{{Rules: apiv1.Rules{
+		apiv1.RecordingRule{Name: "job:requests:rate5m", Query: "sum by (job) (rate(requests_total[5m]))", Health: apiv1.RuleHealthGood},
+		apiv1.RecordingRule{Name: "job:requests:rate5m_copy", Query: "sum by(job)(rate(requests_total[5m]))", Health: apiv1.RuleHealthGood},
+		apiv1.RecordingRule{Name: "job:errors:rate5m", Query: "sum by (job) (rate(errors_total[5m]))", Health: apiv1.RuleHealthBad},
+		apiv1.RecordingRule{Name: "job:latency:avg", Query: "avg by (job) (latency)", Labels: model.LabelSet{"team": "a"}, Health: apiv1.RuleHealthGood},
+		apiv1.RecordingRule{Name: "top:latency", Query: "topk(5, latency)", Health: apiv1.RuleHealthGood},
+		apiv1.AlertingRule{Name: "HighLatency", Query: "avg(latency) > 1", Health: apiv1.RuleHealthGood},
+	}}}}}
+
+	t.Run("it matches expressions regardless of formatting", func(t *testing.T) {
+		rules := newTestRecordingRules(t)
+		name, ok := rules.lookup(context.Background(), client, nil, "sum(rate(requests_total[5m]))by(job)")
+		require.True(t, ok)
+		require.Equal(t, "job:requests:rate5m", name)
+	})
+
+	t.Run("it skips rules that would not return the same series", func(t *testing.T) {
+		rules := newTestRecordingRules(t)
+		for _, expr := range []string{
+			"sum by (job) (rate(errors_total[5m]))",
+			"avg by (job) (latency)",
+			"topk(5, latency)",
+			"avg(latency) > 1",
+			"sum by (instance) (rate(requests_total[5m]))",
+			"rate(",
+		} {
+			_, ok := rules.lookup(context.Background(), client, nil, expr)
+			require.False(t, ok, expr)
+		}
+	})
+
+	t.Run("it fetches the rules again after the refresh interval", func(t *testing.T) {
+		client := &recordingRulesClient{rules: client.rules}
+		rules := newTestRecordingRules(t)
+		for i := 0; i < 3; i++ {
+			_, ok := rules.lookup(context.Background(), client, nil, "sum by (job) (rate(requests_total[5m]))")
+			require.True(t, ok)
+		}
+		require.Equal(t, 1, client.calls)
+
+		// A failing fetch keeps the previous rules.
+		rules.entry(hashHeaders(nil)).lastAttempt = time.Now().Add(-recordingRulesRefreshInterval)
+		client.err = errors.New("unavailable")
+		_, ok := rules.lookup(context.Background(), client, nil, "sum by (job) (rate(requests_total[5m]))")
+		require.True(t, ok)
+		require.Equal(t, 2, client.calls)
+	})
+
+	t.Run("it fetches the rules of every identity", func(t *testing.T) {
+		client := &recordingRulesClient{rules: client.rules}
+		rules := newTestRecordingRules(t)
+		expr := "sum by (job) (rate(requests_total[5m]))"
+
+		_, ok := rules.lookup(context.Background(), client, map[string]string{"X-Scope-OrgID": "a"}, expr)
+		require.True(t, ok)
+		_, ok = rules.lookup(context.Background(), client, map[string]string{"X-Scope-OrgID": "a"}, expr)
+		require.True(t, ok)
+		require.Equal(t, 1, client.calls)
+
+		client.rules = apiv1.RulesResult{}
+		_, ok = rules.lookup(context.Background(), client, map[string]string{"X-Scope-OrgID": "b"}, expr)
+		require.False(t, ok)
+		require.Equal(t, 2, client.calls)
+	})
+
+	t.Run("concurrent lookups fetch the rules once", func(t *testing.T) {
+		client := &recordingRulesClient{rules: client.rules, delay: 10 * time.Millisecond}
+		rules := newTestRecordingRules(t)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rules.lookup(context.Background(), client, nil, "sum by (job) (rate(requests_total[5m]))")
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, 1, client.callCount())
+	})
+
+	t.Run("it rewrites matching queries without exemplars", func(t *testing.T) {
+		queries := []*PrometheusQuery{
+			{Expr: "sum by (job) (rate(requests_total[5m]))"},
+			{Expr: "sum by (job) (rate(requests_total[5m]))", ExemplarQuery: true},
+			{Expr: "up"},
+		}
+		rewriteRecordingRules(context.Background(), client, newTestRecordingRules(t), nil, queries)
+
+		require.Equal(t, `label_replace(job:requests:rate5m, "__name__", "", "", "")`, queries[0].Expr)
+		require.Equal(t, "job:requests:rate5m", queries[0].RecordingRule)
+		require.Equal(t, "sum by (job) (rate(requests_total[5m]))", queries[0].sourceExpr())
+		require.Equal(t, "sum by (job) (rate(requests_total[5m]))", queries[1].Expr)
+		require.Empty(t, queries[1].RecordingRule)
+		require.Equal(t, "up", queries[2].Expr)
+	})
+}
+
+func TestRecordingRuleFrames(t *testing.T) {
+	value := map[TimeSeriesQueryType]interface{}{
+		RangeQueryType: model.Matrix{{
+			Metric: model.Metric{},
+			Values: []model.SamplePair{{Value: 1, Timestamp: 1000}},
+		}},
+	}
+	query := &PrometheusQuery{
+		Expr:          `label_replace(requests:rate5m, "__name__", "", "", "")`,
+		OriginalExpr:  "sum(rate(requests_total[5m]))",
+		RecordingRule: "requests:rate5m",
+		Step:          1 * time.Second,
+		Start:         time.Unix(1, 0).UTC(),
+		End:           time.Unix(1, 0).UTC(),
+		RangeQuery:    true,
+	}
+	res, err := parseTimeSeriesResponse(value, query)
+	require.NoError(t, err)
+	require.Len(t, res, 1)
+	require.Equal(t, "sum(rate(requests_total[5m]))", res[0].Name)
+	require.Equal(t, "requests:rate5m", res[0].Meta.Custom.(map[string]interface{})["recordingRule"])
+}
+
+func newTestRecordingRules(t *testing.T) *RecordingRules {
+	t.Helper()
+	rules, err := NewRecordingRules()
+	require.NoError(t, err)
+	return rules
+}
+
+type recordingRulesClient struct {
+	apiv1.API
+
+	mu    sync.Mutex
+	rules apiv1.RulesResult
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (c *recordingRulesClient) Rules(ctx context.Context) (apiv1.RulesResult, error) {
+	c.mu.Lock()
+	c.calls++
+	c.mu.Unlock()
+	time.Sleep(c.delay)
+	return c.rules, c.err
+}
+
+func (c *recordingRulesClient) callCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
@@ -0,0 +1,116 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// defaultRemoteReadLookback is how far before a step PromQL looks for a sample of
+// a series, the default --query.lookback-delta of Prometheus.
+const defaultRemoteReadLookback = 5 * time.Minute
+
+// Remote read returns every raw sample of the range, so it is only used for
+// short ranges with small steps, where the query API would return about as many
+// points. Longer ranges and steps are cheaper to evaluate on the server.
+const (
+	remoteReadMaxRange = 6 * time.Hour
+	remoteReadMaxStep  = time.Minute
+)
+
+type remoteReader interface {
+	RemoteRead(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) ([]*promclient.Series, error)
+}
+
+// queryRemoteRead answers the range query of query from the raw samples of the
+// remote read API, when the data source enables it, the expression is a plain
+// selector and the range and step are small enough. It returns false when the
+// query must run against the JSON API instead, also when remote read fails,
+// unless the query was cancelled or timed out.
+func queryRemoteRead(ctx context.Context, client apiv1.API, query *PrometheusQuery, timeRange apiv1.Range) ([]*promclient.Series, bool, error) {
+	reader, ok := client.(remoteReader)
+	if !ok || !query.RemoteRead || query.Stats {
+		return nil, false, nil
+	}
+	if timeRange.End.Sub(timeRange.Start) > remoteReadMaxRange || timeRange.Step > remoteReadMaxStep {
+		return nil, false, nil
+	}
+	matchers, ok := remoteReadMatchers(query.Expr)
+	if !ok {
+		return nil, false, nil
+	}
+
+	lookback := query.RemoteReadLookback
+	if lookback <= 0 {
+		lookback = defaultRemoteReadLookback
+	}
+	raw, err := reader.RemoteRead(ctx, matchers, timeRange.Start.Add(-lookback), timeRange.End)
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return nil, false, err
+		}
+		if errors.Is(err, promclient.ErrRemoteReadUnsupported) {
+			plog.Debug("Remote read is not supported, falling back to the query API", "query", query.Expr)
+		} else {
+			plog.Warn("Remote read failed, falling back to the query API", "query", query.Expr, "err", err)
+		}
+		return nil, false, nil
+	}
+
+	series := make([]*promclient.Series, 0, len(raw))
+	for _, s := range raw {
+		s.Values = stepSamples(s.Values, timeRange, lookback)
+		if len(s.Values) > 0 {
+			series = append(series, s)
+		}
+	}
+	return series, true, nil
+}
+
+// remoteReadMatchers returns the label matchers of expr when it is a plain vector
+// selector, without offset or @ modifier, so that it can be evaluated from raw samples.
+func remoteReadMatchers(expr string) ([]*labels.Matcher, bool) {
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		return nil, false
+	}
+	selector, ok := node.(*parser.VectorSelector)
+	if !ok || selector.OriginalOffset != 0 || selector.Timestamp != nil || selector.StartOrEnd != 0 {
+		return nil, false
+	}
+	return selector.LabelMatchers, true
+}
+
+// stepSamples evaluates raw samples at every step of r, like PromQL evaluates a
+// vector selector: the value at a step is the one of the latest sample less than
+// lookback before it, unless that sample is a staleness marker.
+func stepSamples(raw []model.SamplePair, r apiv1.Range, lookback time.Duration) []model.SamplePair {
+	samples := []model.SamplePair{}
+	if r.Step <= 0 {
+		return samples
+	}
+
+	i := 0
+	for t := r.Start; !t.After(r.End); t = t.Add(r.Step) {
+		ts := model.TimeFromUnixNano(t.UnixNano())
+		for i < len(raw) && raw[i].Timestamp <= ts {
+			i++
+		}
+		if i == 0 {
+			continue
+		}
+		last := raw[i-1]
+		if ts.Sub(last.Timestamp) >= lookback || value.IsStaleNaN(float64(last.Value)) {
+			continue
+		}
+		samples = append(samples, model.SamplePair{Timestamp: ts, Value: last.Value})
+	}
+	return samples
+}
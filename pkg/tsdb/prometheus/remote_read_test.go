@@ -0,0 +1,136 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteReadMatchers(t *testing.T) {
+	matchers, ok := remoteReadMatchers(`up{job="a"}`)
+	require.True(t, ok)
+	require.Len(t, matchers, 2)
+
+	for _, expr := range []string{"rate(up[5m])", "up offset 5m", "up @ 1641889530", "sum(up)", "up{"} {
+		_, ok := remoteReadMatchers(expr)
+		require.False(t, ok, expr)
+	}
+}
+
+func TestStepSamples(t *testing.T) {
+	r := apiv1.Range{Start: time.Unix(0, 0), End: time.Unix(600, 0), Step: 2 * time.Minute}
+	raw := []model.SamplePair{
+		{Timestamp: model.TimeFromUnix(10), Value: 1},
+		{Timestamp: model.TimeFromUnix(130), Value: 2},
+		{Timestamp: model.TimeFromUnix(230), Value: model.SampleValue(math.Float64frombits(value.StaleNaN))},
+		{Timestamp: model.TimeFromUnix(250), Value: 3},
+	}
+
+	samples := stepSamples(raw, r, 5*time.Minute)
+	require.Equal(t, []model.SamplePair{
+		// At 0s there is no sample yet, at 240s the marker at 230s ended the series,
+		// and at 600s the last sample is older than the lookback.
+		{Timestamp: model.TimeFromUnix(120), Value: 1},
+		{Timestamp: model.TimeFromUnix(360), Value: 3},
+		{Timestamp: model.TimeFromUnix(480), Value: 3},
+	}, samples)
+}
+
+func TestQueryRemoteRead(t *testing.T) {
+	r := apiv1.Range{Start: time.Unix(0, 0), End: time.Unix(120, 0), Step: time.Minute}
+	query := &PrometheusQuery{Expr: "up", RangeQuery: true, RemoteRead: true}
+
+	t.Run("it evaluates the raw samples at every step", func(t *testing.T) {
+		client := &remoteReadClient{series: []*promclient.Series{{SampleStream: model.SampleStream{
+			Metric: model.Metric{"__name__": "up"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(-30), Value: 1}},
+		}}}}
+		series, ok, err := queryRemoteRead(context.Background(), client, query, r)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Len(t, series, 1)
+		require.Len(t, series[0].Values, 3)
+		require.Equal(t, time.Unix(-300, 0), client.start)
+	})
+
+	t.Run("it uses the lookback delta of the data source", func(t *testing.T) {
+		client := &remoteReadClient{series: []*promclient.Series{{SampleStream: model.SampleStream{
+			Metric: model.Metric{"__name__": "up"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnix(-30), Value: 1}},
+		}}}}
+		lookback := *query
+		lookback.RemoteReadLookback = time.Minute
+		series, ok, err := queryRemoteRead(context.Background(), client, &lookback, r)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Len(t, series, 1)
+		require.Len(t, series[0].Values, 1, "only the first step is less than a minute after the sample")
+		require.Equal(t, time.Unix(-60, 0), client.start)
+	})
+
+	t.Run("it only reads short ranges with small steps", func(t *testing.T) {
+		for _, r := range []apiv1.Range{
+			{Start: time.Unix(0, 0), End: time.Unix(0, 0).Add(remoteReadMaxRange + time.Minute), Step: time.Minute},
+			{Start: time.Unix(0, 0), End: time.Unix(3600, 0), Step: 5 * time.Minute},
+		} {
+			client := &remoteReadClient{}
+			_, ok, err := queryRemoteRead(context.Background(), client, query, r)
+			require.NoError(t, err)
+			require.False(t, ok)
+			require.True(t, client.start.IsZero(), "remote read is not called")
+		}
+	})
+
+	t.Run("it does not fall back after the query is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		client := &remoteReadClient{err: context.Canceled}
+		_, ok, err := queryRemoteRead(ctx, client, query, r)
+		require.ErrorIs(t, err, context.Canceled)
+		require.False(t, ok)
+
+		client = &remoteReadClient{err: context.DeadlineExceeded}
+		_, _, err = queryRemoteRead(context.Background(), client, query, r)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("it falls back to the query API", func(t *testing.T) {
+		client := &remoteReadClient{err: promclient.ErrRemoteReadUnsupported}
+		_, ok, err := queryRemoteRead(context.Background(), client, query, r)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		client = &remoteReadClient{err: errors.New("server error: 500")}
+		_, ok, err = queryRemoteRead(context.Background(), client, query, r)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		disabled := *query
+		disabled.RemoteRead = false
+		_, ok, err = queryRemoteRead(context.Background(), &remoteReadClient{}, &disabled, r)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+}
+
+type remoteReadClient struct {
+	apiv1.API
+
+	series []*promclient.Series
+	err    error
+	start  time.Time
+}
+
+func (c *remoteReadClient) RemoteRead(ctx context.Context, matchers []*labels.Matcher, start, end time.Time) ([]*promclient.Series, error) {
+	c.start = start
+	return c.series, c.err
+}
@@ -0,0 +1,215 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+)
+
+const (
+	// metadataCacheTTL is how long metric metadata responses are reused. Metadata
+	// rarely changes, but the query editor asks for it on every keystroke.
+	metadataCacheTTL      = time.Minute
+	defaultLabelsCacheTTL = time.Minute
+)
+
+// forwardedHeaders are the request headers that identify the user to Prometheus
+// when OAuth identities are forwarded.
+var forwardedHeaders = []string{"Authorization", "X-ID-Token"}
+
+func (s *Service) newResourceMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/metadata", s.handleMetadata)
+	mux.HandleFunc("/api/v1/targets/metadata", s.handleTargetsMetadata)
+	mux.HandleFunc("/api/v1/labels", s.handleLabels)
+	mux.HandleFunc("/api/v1/label/", s.handleLabelValues)
+	mux.HandleFunc("/api/v1/rules", s.handleRules)
+	mux.HandleFunc("/api/v1/alerts", s.handleAlerts)
+	mux.HandleFunc("/validate", s.handleValidate)
+	mux.HandleFunc("/estimate", s.handleEstimate)
+	return mux
+}
+
+func (s *Service) handleMetadata(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	s.serveCachedResource(rw, req, q, metadataTTL, func(client apiv1.API) (interface{}, error) {
+		return client.Metadata(req.Context(), q.Get("metric"), q.Get("limit"))
+	})
+}
+
+func (s *Service) handleTargetsMetadata(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	s.serveCachedResource(rw, req, q, metadataTTL, func(client apiv1.API) (interface{}, error) {
+		return client.TargetsMetadata(req.Context(), q.Get("match_target"), q.Get("metric"), q.Get("limit"))
+	})
+}
+
+// handleLabels returns the label names of the series matching the match[]
+// selectors. Like label values, they are cached by selectors and time range.
+func (s *Service) handleLabels(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	start, end, err := parseResourceRange(q)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+	s.serveCachedResource(rw, req, labelsCacheParams(q, start, end), labelsTTL, func(client apiv1.API) (interface{}, error) {
+		names, _, err := client.LabelNames(req.Context(), q["match[]"], start, end)
+		return names, err
+	})
+}
+
+// handleLabelValues serves /api/v1/label/{name}/values.
+func (s *Service) handleLabelValues(rw http.ResponseWriter, req *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, "/api/v1/label/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "values" {
+		writeResourceError(rw, http.StatusNotFound, fmt.Errorf("unknown resource %s", req.URL.Path))
+		return
+	}
+
+	q := req.URL.Query()
+	start, end, err := parseResourceRange(q)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+	s.serveCachedResource(rw, req, labelsCacheParams(q, start, end), labelsTTL, func(client apiv1.API) (interface{}, error) {
+		values, _, err := client.LabelValues(req.Context(), parts[0], q["match[]"], start, end)
+		return values, err
+	})
+}
+
+// parseResourceRange reads the start and end parameters, in Unix seconds or RFC 3339
+// like the Prometheus API. A missing start means the beginning of time, a missing
+// end now.
+func parseResourceRange(q url.Values) (time.Time, time.Time, error) {
+	start, end := time.Unix(0, 0), time.Now()
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{{"start", &start}, {"end", &end}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		if secs, err := strconv.ParseFloat(v, 64); err == nil {
+			whole, frac := math.Modf(secs)
+			*p.t = time.Unix(int64(whole), int64(frac*float64(time.Second)))
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid %s %q", p.name, v)
+		}
+		*p.t = t
+	}
+	return start, end, nil
+}
+
+func metadataTTL(*DatasourceInfo) time.Duration {
+	return metadataCacheTTL
+}
+
+func labelsTTL(dsInfo *DatasourceInfo) time.Duration {
+	return dsInfo.LabelsCacheTTL
+}
+
+// labelsCacheParams returns the parameters label resources are cached by, their
+// match[] selectors in any order and the start and end times they were given,
+// whatever their format.
+func labelsCacheParams(q url.Values, start, end time.Time) url.Values {
+	matches := append([]string{}, q["match[]"]...)
+	sort.Strings(matches)
+	params := url.Values{"match[]": matches}
+	if q.Get("start") != "" {
+		params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
+	}
+	if q.Get("end") != "" {
+		params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
+	}
+	return params
+}
+
+// serveCachedResource writes the result of fetch in the format of the Prometheus
+// API. Results are cached for the duration ttl returns for the data source, per data
+// source, user, path and cacheParams.
+func (s *Service) serveCachedResource(rw http.ResponseWriter, req *http.Request, cacheParams url.Values, ttl func(*DatasourceInfo) time.Duration, fetch func(apiv1.API) (interface{}, error)) {
+	plog.Debug("Received resource call", "url", req.URL.String(), "method", req.Method)
+	if req.Method != http.MethodGet {
+		writeResourceError(rw, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", req.Method))
+		return
+	}
+
+	pluginCtx := httpadapter.PluginConfigFromContext(req.Context())
+	if pluginCtx.DataSourceInstanceSettings == nil {
+		writeResourceError(rw, http.StatusBadRequest, fmt.Errorf("missing data source"))
+		return
+	}
+	dsInfo, err := s.getDSInfo(pluginCtx)
+	if err != nil {
+		writeResourceError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	headers := map[string]string{}
+	for _, name := range forwardedHeaders {
+		if value := req.Header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+
+	cacheTTL := ttl(dsInfo)
+	key := fmt.Sprintf("%s|%s|%s|%s", pluginCtx.DataSourceInstanceSettings.UID, req.URL.Path, cacheParams.Encode(), hashHeaders(headers))
+	if cached, ok := s.resourceCache.Get(key); ok && cacheTTL > 0 {
+		writeResourceData(rw, cached)
+		return
+	}
+
+	client, err := dsInfo.getClient(headers)
+	if err != nil {
+		writeResourceError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	result, err := fetch(client)
+	if err != nil {
+		plog.Error("Resource request failed", "url", req.URL.String(), "err", err)
+		writeResourceError(rw, http.StatusBadGateway, ConvertAPIError(err))
+		return
+	}
+
+	if cacheTTL > 0 {
+		s.resourceCache.Set(key, result, cacheTTL)
+	}
+	writeResourceData(rw, result)
+}
+
+func writeResourceData(rw http.ResponseWriter, result interface{}) {
+	writeResourceJSON(rw, http.StatusOK, map[string]interface{}{"status": "success", "data": result})
+}
+
+func writeResourceError(rw http.ResponseWriter, code int, err error) {
+	writeResourceJSON(rw, code, map[string]interface{}{"status": "error", "error": err.Error()})
+}
+
+func writeResourceJSON(rw http.ResponseWriter, code int, body interface{}) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		plog.Error("Failed to marshal resource response", "err", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	if _, err := rw.Write(b); err != nil {
+		plog.Error("Failed to write resource response", "err", err)
+	}
+}
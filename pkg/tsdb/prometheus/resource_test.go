@@ -0,0 +1,187 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataResources(t *testing.T) {
+	t.Run("it returns the metric metadata and caches it", func(t *testing.T) {
+		client := &metadataClient{}
+		s := newResourceTestService(client, 0)
+
+		for i := 0; i < 2; i++ {
+			res := callResource(t, s, "api/v1/metadata?metric=up")
+			require.Equal(t, http.StatusOK, res.Status)
+
+			var body struct {
+				Status string                      `json:"status"`
+				Data   map[string][]apiv1.Metadata `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(res.Body, &body))
+			require.Equal(t, "success", body.Status)
+			require.Equal(t, "Whether the target is up.", body.Data["up"][0].Help)
+		}
+		require.Equal(t, []string{"up"}, client.metadataCalls)
+	})
+
+	t.Run("it caches per query", func(t *testing.T) {
+		client := &metadataClient{}
+		s := newResourceTestService(client, 0)
+
+		callResource(t, s, "api/v1/metadata?metric=up")
+		callResource(t, s, "api/v1/metadata?metric=down")
+		require.Equal(t, []string{"up", "down"}, client.metadataCalls)
+	})
+
+	t.Run("it returns the targets metadata", func(t *testing.T) {
+		client := &metadataClient{}
+		s := newResourceTestService(client, 0)
+
+		res := callResource(t, s, `api/v1/targets/metadata?match_target={job="prometheus"}&metric=up`)
+		require.Equal(t, http.StatusOK, res.Status)
+		require.Equal(t, []string{`{job="prometheus"}`}, client.targetsCalls)
+		require.Contains(t, string(res.Body), `"type":"gauge"`)
+	})
+}
+
+func TestLabelResources(t *testing.T) {
+	t.Run("it caches label names by their selectors and time range", func(t *testing.T) {
+		client := &metadataClient{}
+		s := newResourceTestService(client, time.Minute)
+
+		res := callResource(t, s, "api/v1/labels?match[]=up&match[]=down&start=1641889530&end=1641889590")
+		require.Equal(t, http.StatusOK, res.Status)
+		require.JSONEq(t, `{"status":"success","data":["__name__","job"]}`, string(res.Body))
+
+		callResource(t, s, "api/v1/labels?match[]=down&match[]=up&start=2022-01-11T08:25:30Z&end=1641889590")
+		require.Len(t, client.labelNamesCalls, 1)
+
+		callResource(t, s, "api/v1/labels?match[]=down&match[]=up&start=1641889600&end=1641889660")
+		callResource(t, s, "api/v1/labels?match[]=up")
+		require.Len(t, client.labelNamesCalls, 3)
+		require.Equal(t, time.Unix(1641889530, 0), client.labelNamesCalls[0].start)
+		require.Equal(t, time.Unix(1641889600, 0), client.labelNamesCalls[1].start)
+	})
+
+	t.Run("it returns label values", func(t *testing.T) {
+		client := &metadataClient{}
+		s := newResourceTestService(client, time.Minute)
+
+		res := callResource(t, s, "api/v1/label/job/values?match[]=up")
+		require.Equal(t, http.StatusOK, res.Status)
+		require.JSONEq(t, `{"status":"success","data":["job-a","job-b"]}`, string(res.Body))
+
+		res = callResource(t, s, "api/v1/label/job/other")
+		require.Equal(t, http.StatusNotFound, res.Status)
+	})
+
+	t.Run("it does not cache without a TTL", func(t *testing.T) {
+		client := &metadataClient{}
+		s := newResourceTestService(client, 0)
+
+		callResource(t, s, "api/v1/labels")
+		callResource(t, s, "api/v1/labels")
+		require.Len(t, client.labelNamesCalls, 2)
+	})
+
+	t.Run("it rejects invalid times", func(t *testing.T) {
+		s := newResourceTestService(&metadataClient{}, time.Minute)
+
+		res := callResource(t, s, "api/v1/labels?start=yesterday")
+		require.Equal(t, http.StatusBadRequest, res.Status)
+	})
+}
+
+func newResourceTestService(client apiv1.API, labelsCacheTTL time.Duration) *Service {
+	s := &Service{
+		im: datasource.NewInstanceManager(func(settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+			return DatasourceInfo{
+				ID:             settings.ID,
+				LabelsCacheTTL: labelsCacheTTL,
+				getClient: func(map[string]string) (apiv1.API, error) {
+					return client, nil
+				},
+			}, nil
+		}),
+		resourceCache: localcache.New(metadataCacheTTL, 2*metadataCacheTTL),
+	}
+	s.resourceHandler = httpadapter.New(s.newResourceMux())
+	return s
+}
+
+func callResource(t *testing.T, s *Service, url string) *backend.CallResourceResponse {
+	t.Helper()
+	req := &backend.CallResourceRequest{
+		PluginContext: backend.PluginContext{
+			DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{ID: 1, UID: "prometheus"},
+		},
+		Method: http.MethodGet,
+		Path:   strings.SplitN(url, "?", 2)[0],
+		URL:    url,
+	}
+
+	sender := &fakeSender{}
+	err := s.CallResource(context.Background(), req, sender)
+	require.NoError(t, err)
+	require.NotNil(t, sender.res)
+	return sender.res
+}
+
+type fakeSender struct {
+	res *backend.CallResourceResponse
+}
+
+func (s *fakeSender) Send(res *backend.CallResourceResponse) error {
+	s.res = res
+	return nil
+}
+
+type metadataClient struct {
+	apiv1.API
+
+	metadataCalls   []string
+	targetsCalls    []string
+	labelNamesCalls []labelsCall
+}
+
+type labelsCall struct {
+	matches    []string
+	start, end time.Time
+}
+
+func (c *metadataClient) LabelNames(ctx context.Context, matches []string, startTime time.Time, endTime time.Time) ([]string, apiv1.Warnings, error) {
+	c.labelNamesCalls = append(c.labelNamesCalls, labelsCall{matches: matches, start: startTime, end: endTime})
+	return []string{"__name__", "job"}, nil, nil
+}
+
+func (c *metadataClient) LabelValues(ctx context.Context, label string, matches []string, startTime time.Time, endTime time.Time) (model.LabelValues, apiv1.Warnings, error) {
+	return model.LabelValues{"job-a", "job-b"}, nil, nil
+}
+
+func (c *metadataClient) Metadata(ctx context.Context, metric string, limit string) (map[string][]apiv1.Metadata, error) {
+	c.metadataCalls = append(c.metadataCalls, metric)
+	return map[string][]apiv1.Metadata{
+		metric: {{Type: apiv1.MetricTypeGauge, Help: "Whether the target is up.", Unit: ""}},
+	}, nil
+}
+
+func (c *metadataClient) TargetsMetadata(ctx context.Context, matchTarget string, metric string, limit string) ([]apiv1.MetricMetadata, error) {
+	c.targetsCalls = append(c.targetsCalls, matchTarget)
+	return []apiv1.MetricMetadata{
+		{Metric: metric, Type: apiv1.MetricTypeGauge, Help: "Whether the target is up."},
+	}, nil
+}
@@ -0,0 +1,240 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// The rules and alerts resources return the rules and alerts of the server in the
+// format of the Prometheus API. Thanos and Mimir can return the same rule group,
+// rule or alert more than once, from every ruler or shard evaluating it. These
+// copies are merged into one.
+
+type ruleGroup struct {
+	Name     string  `json:"name"`
+	File     string  `json:"file"`
+	Interval float64 `json:"interval"`
+	Rules    []*rule `json:"rules"`
+}
+
+type rule struct {
+	Type           string         `json:"type"`
+	Name           string         `json:"name"`
+	Query          string         `json:"query"`
+	Duration       float64        `json:"duration,omitempty"`
+	Labels         model.LabelSet `json:"labels,omitempty"`
+	Annotations    model.LabelSet `json:"annotations,omitempty"`
+	Alerts         []*alert       `json:"alerts,omitempty"`
+	State          string         `json:"state,omitempty"`
+	Health         string         `json:"health"`
+	LastError      string         `json:"lastError,omitempty"`
+	EvaluationTime float64        `json:"evaluationTime"`
+	LastEvaluation time.Time      `json:"lastEvaluation"`
+}
+
+type alert struct {
+	Labels      model.LabelSet `json:"labels"`
+	Annotations model.LabelSet `json:"annotations"`
+	State       string         `json:"state"`
+	ActiveAt    time.Time      `json:"activeAt"`
+	Value       string         `json:"value"`
+}
+
+const (
+	ruleTypeAlerting  = "alerting"
+	ruleTypeRecording = "recording"
+)
+
+// ruleTypeFilters maps the values of the type parameter of the rules API to rule types.
+var ruleTypeFilters = map[string]string{
+	"alert":  ruleTypeAlerting,
+	"record": ruleTypeRecording,
+}
+
+// alertStateSeverity orders the states of alerts and alerting rules, the most
+// severe state of the copies of a rule or alert wins.
+var alertStateSeverity = map[string]int{
+	string(apiv1.AlertStateInactive): 0,
+	string(apiv1.AlertStatePending):  1,
+	string(apiv1.AlertStateFiring):   2,
+}
+
+// handleRules serves /api/v1/rules. Like the Prometheus API, it only returns the
+// alerting or recording rules when the type parameter is alert or record.
+func (s *Service) handleRules(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+	ruleType := ""
+	if t := q.Get("type"); t != "" {
+		var ok bool
+		if ruleType, ok = ruleTypeFilters[t]; !ok {
+			writeResourceError(rw, http.StatusBadRequest, fmt.Errorf("invalid rule type %q", t))
+			return
+		}
+	}
+	s.serveCachedResource(rw, req, nil, noResourceCacheTTL, func(client apiv1.API) (interface{}, error) {
+		result, err := client.Rules(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"groups": mergeRuleGroups(result.Groups, ruleType)}, nil
+	})
+}
+
+// handleAlerts serves /api/v1/alerts.
+func (s *Service) handleAlerts(rw http.ResponseWriter, req *http.Request) {
+	s.serveCachedResource(rw, req, nil, noResourceCacheTTL, func(client apiv1.API) (interface{}, error) {
+		result, err := client.Alerts(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		alerts := make([]*alert, 0, len(result.Alerts))
+		for i := range result.Alerts {
+			alerts = append(alerts, convertAlert(&result.Alerts[i]))
+		}
+		return map[string]interface{}{"alerts": mergeAlerts([]*alert{}, alerts)}, nil
+	})
+}
+
+// noResourceCacheTTL is the TTL of resources that must not be cached, like the
+// state of rules and alerts.
+func noResourceCacheTTL(*DatasourceInfo) time.Duration {
+	return 0
+}
+
+// mergeRuleGroups merges the copies of rule groups and of their rules, and keeps
+// only the rules of ruleType, unless it is empty. Groups are sorted by file and
+// name, rules keep their order.
+func mergeRuleGroups(groups []apiv1.RuleGroup, ruleType string) []*ruleGroup {
+	merged := []*ruleGroup{}
+	byKey := map[string]*ruleGroup{}
+	rulesByKey := map[string]*rule{}
+	for _, g := range groups {
+		key := g.File + "\x00" + g.Name
+		group, ok := byKey[key]
+		if !ok {
+			group = &ruleGroup{Name: g.Name, File: g.File, Interval: g.Interval, Rules: []*rule{}}
+			byKey[key] = group
+			merged = append(merged, group)
+		}
+		for _, r := range g.Rules {
+			converted := convertRule(r)
+			if converted == nil || (ruleType != "" && converted.Type != ruleType) {
+				continue
+			}
+			ruleKey := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%s", key, converted.Type, converted.Name, converted.Query, converted.Labels.Fingerprint())
+			if existing, ok := rulesByKey[ruleKey]; ok {
+				mergeRule(existing, converted)
+				continue
+			}
+			rulesByKey[ruleKey] = converted
+			group.Rules = append(group.Rules, converted)
+		}
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].File != merged[j].File {
+			return merged[i].File < merged[j].File
+		}
+		return merged[i].Name < merged[j].Name
+	})
+	return merged
+}
+
+func convertRule(r interface{}) *rule {
+	switch r := r.(type) {
+	case apiv1.AlertingRule:
+		alerts := make([]*alert, 0, len(r.Alerts))
+		for _, a := range r.Alerts {
+			alerts = append(alerts, convertAlert(a))
+		}
+		return &rule{
+			Type:           ruleTypeAlerting,
+			Name:           r.Name,
+			Query:          r.Query,
+			Duration:       r.Duration,
+			Labels:         r.Labels,
+			Annotations:    r.Annotations,
+			Alerts:         mergeAlerts(nil, alerts),
+			State:          r.State,
+			Health:         string(r.Health),
+			LastError:      r.LastError,
+			EvaluationTime: r.EvaluationTime,
+			LastEvaluation: r.LastEvaluation,
+		}
+	case apiv1.RecordingRule:
+		return &rule{
+			Type:           ruleTypeRecording,
+			Name:           r.Name,
+			Query:          r.Query,
+			Labels:         r.Labels,
+			Health:         string(r.Health),
+			LastError:      r.LastError,
+			EvaluationTime: r.EvaluationTime,
+			LastEvaluation: r.LastEvaluation,
+		}
+	default:
+		return nil
+	}
+}
+
+func convertAlert(a *apiv1.Alert) *alert {
+	return &alert{
+		Labels:      a.Labels,
+		Annotations: a.Annotations,
+		State:       string(a.State),
+		ActiveAt:    a.ActiveAt,
+		Value:       a.Value,
+	}
+}
+
+// mergeRule merges the state of another copy of a rule into r. The most severe
+// state and a failing health win, as does the latest evaluation.
+func mergeRule(r *rule, other *rule) {
+	if alertStateSeverity[other.State] > alertStateSeverity[r.State] {
+		r.State = other.State
+	}
+	if r.Health != string(apiv1.RuleHealthBad) && other.Health != string(apiv1.RuleHealthUnknown) {
+		r.Health = other.Health
+		r.LastError = other.LastError
+	}
+	if other.LastEvaluation.After(r.LastEvaluation) {
+		r.LastEvaluation = other.LastEvaluation
+		r.EvaluationTime = other.EvaluationTime
+	}
+	r.Alerts = mergeAlerts(r.Alerts, other.Alerts)
+}
+
+// mergeAlerts adds the alerts of other to alerts, merging alerts with the same
+// labels. Of these the most severe state and the earliest activation win.
+// Alerts are sorted by their labels.
+func mergeAlerts(alerts []*alert, other []*alert) []*alert {
+	byFingerprint := make(map[model.Fingerprint]*alert, len(alerts))
+	for _, a := range alerts {
+		byFingerprint[a.Labels.Fingerprint()] = a
+	}
+	for _, a := range other {
+		existing, ok := byFingerprint[a.Labels.Fingerprint()]
+		if !ok {
+			byFingerprint[a.Labels.Fingerprint()] = a
+			alerts = append(alerts, a)
+			continue
+		}
+		if alertStateSeverity[a.State] > alertStateSeverity[existing.State] {
+			existing.State = a.State
+			existing.Value = a.Value
+		}
+		if !a.ActiveAt.IsZero() && (existing.ActiveAt.IsZero() || a.ActiveAt.Before(existing.ActiveAt)) {
+			existing.ActiveAt = a.ActiveAt
+		}
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].Labels.Before(alerts[j].Labels)
+	})
+	return alerts
+}
@@ -0,0 +1,104 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRulesResource(t *testing.T) {
+	activeAt := time.Date(2022, 1, 11, 8, 0, 0, 0, time.UTC)
+	lastEvaluation := time.Date(2022, 1, 11, 9, 0, 0, 0, time.UTC)
+	highLatency := func(state apiv1.AlertState, activeAt time.Time, health apiv1.RuleHealth, lastEvaluation time.Time) apiv1.AlertingRule {
+		return apiv1.AlertingRule{
+			Name:   "HighLatency",
+			Query:  "latency > 1",
+			Labels: model.LabelSet{"severity": "page"},
+			Alerts: []*apiv1.Alert{{
+				Labels:   model.LabelSet{"alertname": "HighLatency", "instance": "a"},
+				State:    state,
+				ActiveAt: activeAt,
+				Value:    "2",
+			}},
+			State:          string(state),
+			Health:         health,
+			LastEvaluation: lastEvaluation,
+		}
+	}
+	// Two rulers return the same group, as Thanos and Mimir do.
+	client := &rulesClient{rules: apiv1.RulesResult{Groups: []apiv1.RuleGroup{
+		{Name: "latency", File: "b.yml", Interval: 60, Rules: apiv1.Rules{
+			apiv1.RecordingRule{Name: "job:latency:avg", Query: "avg by (job) (latency)", Health: apiv1.RuleHealthGood},
+			highLatency(apiv1.AlertStatePending, activeAt, apiv1.RuleHealthGood, lastEvaluation.Add(-time.Minute)),
+		}},
+		{Name: "up", File: "a.yml", Interval: 60, Rules: apiv1.Rules{}},
+		{Name: "latency", File: "b.yml", Interval: 60, Rules: apiv1.Rules{
+			apiv1.RecordingRule{Name: "job:latency:avg", Query: "avg by (job) (latency)", Health: apiv1.RuleHealthGood},
+			highLatency(apiv1.AlertStateFiring, activeAt.Add(time.Minute), apiv1.RuleHealthBad, lastEvaluation),
+		}},
+	}}}
+
+	t.Run("it merges the copies of groups, rules and alerts", func(t *testing.T) {
+		s := newResourceTestService(client, 0)
+
+		res := callResource(t, s, "api/v1/rules")
+		require.Equal(t, http.StatusOK, res.Status)
+		require.JSONEq(t, `{"status":"success","data":{"groups":[
+			{"name":"up","file":"a.yml","interval":60,"rules":[]},
+			{"name":"latency","file":"b.yml","interval":60,"rules":[
+				{"type":"recording","name":"job:latency:avg","query":"avg by (job) (latency)","health":"ok","evaluationTime":0,"lastEvaluation":"0001-01-01T00:00:00Z"},
+				{"type":"alerting","name":"HighLatency","query":"latency > 1","labels":{"severity":"page"},"state":"firing","health":"err","evaluationTime":0,"lastEvaluation":"2022-01-11T09:00:00Z",
+					"alerts":[{"labels":{"alertname":"HighLatency","instance":"a"},"annotations":null,"state":"firing","activeAt":"2022-01-11T08:00:00Z","value":"2"}]}
+			]}
+		]}}`, string(res.Body))
+	})
+
+	t.Run("it filters the rules by type", func(t *testing.T) {
+		s := newResourceTestService(client, 0)
+
+		res := callResource(t, s, "api/v1/rules?type=record")
+		require.Equal(t, http.StatusOK, res.Status)
+		require.NotContains(t, string(res.Body), "HighLatency")
+		require.Contains(t, string(res.Body), "job:latency:avg")
+
+		res = callResource(t, s, "api/v1/rules?type=unknown")
+		require.Equal(t, http.StatusBadRequest, res.Status)
+	})
+}
+
+func TestAlertsResource(t *testing.T) {
+	activeAt := time.Date(2022, 1, 11, 8, 0, 0, 0, time.UTC)
+	client := &rulesClient{alerts: apiv1.AlertsResult{Alerts: []apiv1.Alert{
+		{Labels: model.LabelSet{"alertname": "Down", "instance": "b"}, State: apiv1.AlertStateFiring, ActiveAt: activeAt, Value: "0"},
+		{Labels: model.LabelSet{"alertname": "Down", "instance": "a"}, State: apiv1.AlertStatePending, ActiveAt: activeAt, Value: "0"},
+		{Labels: model.LabelSet{"alertname": "Down", "instance": "a"}, State: apiv1.AlertStateFiring, ActiveAt: activeAt.Add(-time.Minute), Value: "0"},
+	}}}
+	s := newResourceTestService(client, 0)
+
+	res := callResource(t, s, "api/v1/alerts")
+	require.Equal(t, http.StatusOK, res.Status)
+	require.JSONEq(t, `{"status":"success","data":{"alerts":[
+		{"labels":{"alertname":"Down","instance":"a"},"annotations":null,"state":"firing","activeAt":"2022-01-11T07:59:00Z","value":"0"},
+		{"labels":{"alertname":"Down","instance":"b"},"annotations":null,"state":"firing","activeAt":"2022-01-11T08:00:00Z","value":"0"}
+	]}}`, string(res.Body))
+}
+
+type rulesClient struct {
+	apiv1.API
+
+	rules  apiv1.RulesResult
+	alerts apiv1.AlertsResult
+}
+
+func (c *rulesClient) Rules(ctx context.Context) (apiv1.RulesResult, error) {
+	return c.rules, nil
+}
+
+func (c *rulesClient) Alerts(ctx context.Context) (apiv1.AlertsResult, error) {
+	return c.alerts, nil
+}
@@ -0,0 +1,259 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"golang.org/x/sync/errgroup"
+)
+
+const defaultSplitConcurrency = 4
+
+// querySplitRange runs the range query in its queryRanges, at most
+// query.SplitConcurrency at a time, and stitches the series of all sub-ranges together
+// before they are converted to frames. It fails if any of the sub-ranges fails.
+func querySplitRange(ctx context.Context, client apiv1.API, query *PrometheusQuery, timeRange apiv1.Range) (data.Frames, *promclient.QueryStats, apiv1.Warnings, error) {
+	series, stats, warnings, err := querySplitSeries(ctx, client, query, timeRange)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	frames := data.Frames{}
+	for _, s := range series {
+		frames = seriesToDataFrames(s, query, frames)
+	}
+	return frames, stats, warnings, nil
+}
+
+// querySplitSeries returns the stitched series of the sub-ranges of timeRange,
+// their combined statistics when asked for and their distinct warnings.
+func querySplitSeries(ctx context.Context, client apiv1.API, query *PrometheusQuery, timeRange apiv1.Range) ([]*promclient.Series, *promclient.QueryStats, apiv1.Warnings, error) {
+	ranges := queryRanges(query, timeRange)
+	results := make([][]*promclient.Series, len(ranges))
+
+	var mu sync.Mutex
+	var stats *promclient.QueryStats
+	var warnings apiv1.Warnings
+
+	concurrency := query.SplitConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSplitConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
+	eg, ectx := errgroup.WithContext(ctx)
+	for i, r := range ranges {
+		i, r := i, r
+		eg.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ectx.Done():
+				return ectx.Err()
+			}
+			defer func() { <-sem }()
+
+			series, rangeStats, rangeWarnings, err := querySeries(ectx, client, query, r)
+			if err != nil {
+				return err
+			}
+			results[i] = series
+
+			mu.Lock()
+			defer mu.Unlock()
+			if rangeStats != nil {
+				if stats == nil {
+					stats = &promclient.QueryStats{}
+				}
+				stats.Add(rangeStats)
+			}
+			warnings = appendWarnings(warnings, rangeWarnings)
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return stitchSeries(results), stats, warnings, nil
+}
+
+// appendWarnings adds the warnings that are not in warnings yet.
+func appendWarnings(warnings, more apiv1.Warnings) apiv1.Warnings {
+	for _, w := range more {
+		found := false
+		for _, existing := range warnings {
+			if existing == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+// queryRanges returns the sub-ranges the range query is run in: the dstRanges of
+// timeRange, split into sub-ranges of at most query.SplitInterval.
+func queryRanges(query *PrometheusQuery, timeRange apiv1.Range) []apiv1.Range {
+	ranges := []apiv1.Range{}
+	for _, r := range dstRanges(query, timeRange) {
+		if query.SplitInterval > 0 && r.End.Sub(r.Start) > query.SplitInterval {
+			ranges = append(ranges, splitRange(r, query.SplitInterval)...)
+		} else {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// splitRange splits r into consecutive sub-ranges of at most interval, rounded up
+// to a multiple of the step. Sub-ranges start one step after the previous one
+// ends, so no sample is returned twice.
+func splitRange(r apiv1.Range, interval time.Duration) []apiv1.Range {
+	if r.Step > 0 && interval%r.Step != 0 {
+		interval += r.Step - interval%r.Step
+	}
+
+	ranges := []apiv1.Range{}
+	for start := r.Start; !start.After(r.End); {
+		end := start.Add(interval)
+		if end.After(r.End) {
+			end = r.End
+		}
+		ranges = append(ranges, apiv1.Range{Start: start, End: end, Step: r.Step})
+		if r.Step <= 0 {
+			break
+		}
+		start = end.Add(r.Step)
+	}
+	return ranges
+}
+
+func querySeries(ctx context.Context, client apiv1.API, query *PrometheusQuery, r apiv1.Range) ([]*promclient.Series, *promclient.QueryStats, apiv1.Warnings, error) {
+	if streamer, ok := client.(rangeStreamer); ok && query.StreamRangeResponse {
+		series := []*promclient.Series{}
+		stats, warnings, err := streamer.QueryRangeStream(ctx, query.Expr, r, rangeQueryOptions(query), func(s *promclient.Series) error {
+			series = append(series, s)
+			return nil
+		})
+		return series, stats, warnings, err
+	}
+
+	value, warnings, err := client.QueryRange(ctx, query.Expr, r)
+	if err != nil {
+		return nil, nil, warnings, err
+	}
+	matrix, _ := value.(model.Matrix)
+	series := make([]*promclient.Series, 0, len(matrix))
+	for _, s := range matrix {
+		series = append(series, &promclient.Series{SampleStream: *s})
+	}
+	return series, nil, warnings, nil
+}
+
+// stitchSeries joins the series of consecutive sub-range results by their labels,
+// in the order they first appear.
+func stitchSeries(results [][]*promclient.Series) []*promclient.Series {
+	stitched := []*promclient.Series{}
+	byFingerprint := map[model.Fingerprint]*promclient.Series{}
+	for _, result := range results {
+		for _, s := range result {
+			fp := s.Metric.Fingerprint()
+			existing, ok := byFingerprint[fp]
+			if !ok {
+				byFingerprint[fp] = s
+				stitched = append(stitched, s)
+				continue
+			}
+			existing.Values = append(existing.Values, s.Values...)
+			existing.Histograms = append(existing.Histograms, s.Histograms...)
+		}
+	}
+	return stitched
+}
+
+// exemplarChunks are the results of the windows of a chunked exemplar query, in
+// the order of the windows.
+type exemplarChunks [][]apiv1.ExemplarQueryResult
+
+// queryExemplars runs the exemplar query of query over timeRange. Ranges longer
+// than query.ExemplarChunkInterval are queried in windows of that length, at most
+// query.SplitConcurrency at a time, and the exemplarChunks of all windows are
+// returned. It fails if any of the windows fails.
+func queryExemplars(ctx context.Context, client apiv1.API, query *PrometheusQuery, timeRange apiv1.Range) (interface{}, error) {
+	interval := query.ExemplarChunkInterval
+	if interval <= 0 || timeRange.End.Sub(timeRange.Start) <= interval {
+		return client.QueryExemplars(ctx, query.Expr, timeRange.Start, timeRange.End)
+	}
+
+	windows := splitRange(apiv1.Range{Start: timeRange.Start, End: timeRange.End, Step: time.Millisecond}, interval)
+	chunks := make(exemplarChunks, len(windows))
+
+	concurrency := query.SplitConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultSplitConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
+	eg, ectx := errgroup.WithContext(ctx)
+	for i, w := range windows {
+		i, w := i, w
+		eg.Go(func() error {
+			select {
+			case sem <- struct{}{}:
+			case <-ectx.Done():
+				return ectx.Err()
+			}
+			defer func() { <-sem }()
+
+			result, err := client.QueryExemplars(ectx, query.Expr, w.Start, w.End)
+			if err != nil {
+				return err
+			}
+			chunks[i] = result
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// mergeExemplarChunks joins the exemplars of the series of all windows by the
+// labels of the series, in the order they first appear. Exemplars returned for
+// more than one window are only kept once.
+func mergeExemplarChunks(chunks exemplarChunks) []apiv1.ExemplarQueryResult {
+	merged := []apiv1.ExemplarQueryResult{}
+	bySeries := map[model.Fingerprint]int{}
+	seen := map[model.Fingerprint]map[string]bool{}
+	for _, chunk := range chunks {
+		for _, result := range chunk {
+			fp := model.Metric(result.SeriesLabels).Fingerprint()
+			i, ok := bySeries[fp]
+			if !ok {
+				i = len(merged)
+				bySeries[fp] = i
+				seen[fp] = map[string]bool{}
+				merged = append(merged, apiv1.ExemplarQueryResult{SeriesLabels: result.SeriesLabels})
+			}
+			for _, exemplar := range result.Exemplars {
+				key := fmt.Sprintf("%d|%s|%v", exemplar.Timestamp, exemplar.Labels.Fingerprint(), exemplar.Value)
+				if seen[fp][key] {
+					continue
+				}
+				seen[fp][key] = true
+				merged[i].Exemplars = append(merged[i].Exemplars, exemplar)
+			}
+		}
+	}
+	return merged
+}
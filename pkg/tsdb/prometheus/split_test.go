@@ -0,0 +1,215 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitRange(t *testing.T) {
+	t.Run("it splits into consecutive sub-ranges", func(t *testing.T) {
+		r := apiv1.Range{Start: time.Unix(0, 0), End: time.Unix(0, 0).Add(72 * time.Hour), Step: time.Minute}
+
+		ranges := splitRange(r, 24*time.Hour)
+		require.Len(t, ranges, 3)
+		require.Equal(t, r.Start, ranges[0].Start)
+		require.Equal(t, r.End, ranges[2].End)
+		for i := 1; i < len(ranges); i++ {
+			require.Equal(t, ranges[i-1].End.Add(time.Minute), ranges[i].Start)
+			require.Equal(t, time.Minute, ranges[i].Step)
+		}
+	})
+
+	t.Run("it rounds the interval up to the step", func(t *testing.T) {
+		r := apiv1.Range{Start: time.Unix(0, 0), End: time.Unix(100, 0), Step: 30 * time.Second}
+
+		ranges := splitRange(r, 50*time.Second)
+		require.Equal(t, time.Unix(60, 0), ranges[0].End)
+		require.Equal(t, time.Unix(90, 0), ranges[1].Start)
+	})
+}
+
+func TestQuerySplitRange(t *testing.T) {
+	r := apiv1.Range{Start: time.Unix(0, 0), End: time.Unix(0, 0).Add(10 * time.Hour), Step: time.Hour}
+	query := &PrometheusQuery{
+		Expr:             "up",
+		Step:             time.Hour,
+		Start:            r.Start,
+		End:              r.End,
+		SplitInterval:    2 * time.Hour,
+		SplitConcurrency: 2,
+
+		StreamRangeResponse: true,
+	}
+
+	t.Run("it stitches the series of all sub-ranges", func(t *testing.T) {
+		client := &splitClient{}
+		frames, _, _, err := querySplitRange(context.Background(), client, query, r)
+		require.NoError(t, err)
+		require.Len(t, client.ranges, 4)
+		require.LessOrEqual(t, client.maxActive, 2)
+
+		require.Len(t, frames, 1)
+		require.Equal(t, 11, frames[0].Rows())
+		for i := 0; i < frames[0].Rows(); i++ {
+			v, ok := frames[0].Fields[1].ConcreteAt(i)
+			require.True(t, ok)
+			require.Equal(t, float64(i), v)
+		}
+	})
+
+	t.Run("it combines the statistics of all sub-ranges", func(t *testing.T) {
+		statsQuery := *query
+		statsQuery.Stats = true
+		_, stats, _, err := querySplitRange(context.Background(), &splitClient{}, &statsQuery, r)
+		require.NoError(t, err)
+		require.Equal(t, int64(11), stats.Samples.TotalQueryableSamples)
+		require.Equal(t, int64(3), stats.Samples.PeakSamples)
+	})
+
+	t.Run("it fails when a sub-range fails", func(t *testing.T) {
+		client := &splitClient{failAt: time.Unix(0, 0).Add(3 * time.Hour)}
+		_, _, _, err := querySplitRange(context.Background(), client, query, r)
+		require.Error(t, err)
+	})
+}
+
+// splitClient returns a sample per hour, valued by the hour, for every range.
+type splitClient struct {
+	apiv1.API
+
+	mu        sync.Mutex
+	ranges    []apiv1.Range
+	active    int
+	maxActive int
+	failAt    time.Time
+}
+
+func (c *splitClient) QueryRangeStream(ctx context.Context, query string, r apiv1.Range, opts promclient.RangeQueryOptions, fn func(*promclient.Series) error) (*promclient.QueryStats, apiv1.Warnings, error) {
+	c.mu.Lock()
+	c.ranges = append(c.ranges, r)
+	c.active++
+	if c.active > c.maxActive {
+		c.maxActive = c.active
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.active--
+		c.mu.Unlock()
+	}()
+	time.Sleep(time.Millisecond)
+
+	if r.Start.Equal(c.failAt) {
+		return nil, nil, errors.New("sub-range failed")
+	}
+
+	series := &promclient.Series{SampleStream: model.SampleStream{Metric: model.Metric{"__name__": "up"}}}
+	for ts := r.Start; !ts.After(r.End); ts = ts.Add(r.Step) {
+		series.Values = append(series.Values, model.SamplePair{
+			Timestamp: model.TimeFromUnixNano(ts.UnixNano()),
+			Value:     model.SampleValue(ts.Unix() / 3600),
+		})
+	}
+	var stats *promclient.QueryStats
+	if opts.Stats {
+		stats = &promclient.QueryStats{Samples: promclient.QuerySamples{
+			TotalQueryableSamples: int64(len(series.Values)),
+			PeakSamples:           int64(len(series.Values)),
+		}}
+	}
+	return stats, nil, fn(series)
+}
+
+func TestExemplarChunking(t *testing.T) {
+	r := apiv1.Range{Start: time.Unix(0, 0), End: time.Unix(0, 0).Add(10 * time.Hour), Step: time.Hour}
+	query := &PrometheusQuery{
+		Expr:                  "histogram_quantile(0.99, rate(request_duration_seconds_bucket[5m]))",
+		Step:                  time.Hour,
+		Start:                 r.Start,
+		End:                   r.End,
+		SplitConcurrency:      2,
+		ExemplarChunkInterval: 3 * time.Hour,
+	}
+
+	t.Run("it queries the exemplars in windows and merges their series", func(t *testing.T) {
+		client := &exemplarChunkClient{}
+		result, err := queryExemplars(context.Background(), client, query, r)
+		require.NoError(t, err)
+		require.Len(t, client.windows, 4)
+		require.LessOrEqual(t, client.maxActive, 2)
+
+		merged := mergeExemplarChunks(result.(exemplarChunks))
+		require.Len(t, merged, 1)
+		// One exemplar per hour, those returned for two windows are only kept once.
+		require.Len(t, merged[0].Exemplars, 11)
+		for i, exemplar := range merged[0].Exemplars {
+			require.Equal(t, model.TimeFromUnix(int64(i*3600)), exemplar.Timestamp)
+		}
+	})
+
+	t.Run("it does not split short ranges", func(t *testing.T) {
+		client := &exemplarChunkClient{}
+		shortQuery := *query
+		shortQuery.ExemplarChunkInterval = 24 * time.Hour
+		result, err := queryExemplars(context.Background(), client, &shortQuery, r)
+		require.NoError(t, err)
+		require.Len(t, client.windows, 1)
+		require.IsType(t, []apiv1.ExemplarQueryResult{}, result)
+	})
+
+	t.Run("it fails when a window fails", func(t *testing.T) {
+		client := &exemplarChunkClient{fail: true}
+		_, err := queryExemplars(context.Background(), client, query, r)
+		require.Error(t, err)
+	})
+}
+
+// exemplarChunkClient returns an exemplar per hour for every window, starting at
+// the hour the window starts in, which may be before the window.
+type exemplarChunkClient struct {
+	apiv1.API
+
+	mu        sync.Mutex
+	windows   []apiv1.Range
+	active    int
+	maxActive int
+	fail      bool
+}
+
+func (c *exemplarChunkClient) QueryExemplars(ctx context.Context, query string, start time.Time, end time.Time) ([]apiv1.ExemplarQueryResult, error) {
+	c.mu.Lock()
+	c.windows = append(c.windows, apiv1.Range{Start: start, End: end})
+	c.active++
+	if c.active > c.maxActive {
+		c.maxActive = c.active
+	}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.active--
+		c.mu.Unlock()
+	}()
+	time.Sleep(time.Millisecond)
+
+	if c.fail && len(c.windows) > 1 {
+		return nil, errors.New("window failed")
+	}
+
+	result := apiv1.ExemplarQueryResult{SeriesLabels: model.LabelSet{"__name__": "request_duration_seconds_bucket"}}
+	for ts := start.Truncate(time.Hour); !ts.After(end); ts = ts.Add(time.Hour) {
+		result.Exemplars = append(result.Exemplars, apiv1.Exemplar{
+			Labels:    model.LabelSet{"traceID": "abc"},
+			Value:     1,
+			Timestamp: model.TimeFromUnixNano(ts.UnixNano()),
+		})
+	}
+	return []apiv1.ExemplarQueryResult{result}, nil
+}
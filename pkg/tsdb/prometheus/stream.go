@@ -0,0 +1,271 @@
+package prometheus
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins/adapters"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+)
+
+// The query streams re-run a query on the server at a fixed interval and push
+// the new samples over Grafana Live, so live dashboards don't poll with a
+// request per refresh. The data of the subscription is a streamRequest.
+//
+// Grafana Live runs a single stream per channel, with the user who subscribed
+// first, and the queries of the stream forward the OAuth identity of this user.
+// The channel path is therefore bound to the user and the query: it is
+// streamPathPrefix followed by streamPathHash, and subscriptions of other users
+// are rejected.
+
+const (
+	streamPathPrefix      = "query/"
+	defaultStreamInterval = 10 * time.Second
+	minStreamInterval     = time.Second
+	// maxStreamRange limits the range of streamed range queries, which run
+	// again with every interval.
+	maxStreamRange = time.Hour
+)
+
+type streamRequest struct {
+	// Query is the query model, as in query requests.
+	Query json.RawMessage `json:"query"`
+	// Interval is how often the query runs, e.g. "10s".
+	Interval string `json:"interval"`
+	// Range is the range of the query, ending at the time it runs, e.g. "5m".
+	// The query is an instant query when it is empty.
+	Range string `json:"range"`
+}
+
+type streamConfig struct {
+	query    json.RawMessage
+	interval time.Duration
+	rng      time.Duration
+}
+
+func parseStreamRequest(path string, raw json.RawMessage) (*streamConfig, error) {
+	if !strings.HasPrefix(path, streamPathPrefix) {
+		return nil, fmt.Errorf("unknown stream path %q", path)
+	}
+
+	var req streamRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return nil, fmt.Errorf("invalid stream request: %w", err)
+	}
+
+	var model map[string]interface{}
+	if err := json.Unmarshal(req.Query, &model); err != nil || model == nil {
+		return nil, fmt.Errorf("invalid stream query")
+	}
+
+	config := &streamConfig{interval: defaultStreamInterval}
+	if req.Interval != "" {
+		interval, err := intervalv2.ParseIntervalStringToTimeDuration(req.Interval)
+		if err != nil || interval < minStreamInterval {
+			return nil, fmt.Errorf("invalid stream interval %q, it must be at least %s", req.Interval, minStreamInterval)
+		}
+		config.interval = interval
+	}
+	if req.Range != "" {
+		rng, err := intervalv2.ParseIntervalStringToTimeDuration(req.Range)
+		if err != nil || rng <= 0 || rng > maxStreamRange {
+			return nil, fmt.Errorf("invalid stream range %q, it must be at most %s", req.Range, maxStreamRange)
+		}
+		config.rng = rng
+	}
+
+	model["instant"] = config.rng == 0
+	model["range"] = config.rng > 0
+	// Exemplars are not streamed.
+	model["exemplar"] = false
+	query, err := json.Marshal(model)
+	if err != nil {
+		return nil, err
+	}
+	config.query = query
+	return config, nil
+}
+
+func (s *Service) SubscribeStream(_ context.Context, req *backend.SubscribeStreamRequest) (*backend.SubscribeStreamResponse, error) {
+	if !strings.HasPrefix(req.Path, streamPathPrefix) {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusNotFound}, nil
+	}
+	if _, err := parseStreamRequest(req.Path, req.Data); err != nil {
+		return nil, err
+	}
+	if !streamPathMatches(req.Path, req.PluginContext, req.Data) {
+		return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusPermissionDenied}, nil
+	}
+	if _, err := s.getDSInfo(req.PluginContext); err != nil {
+		return nil, err
+	}
+	return &backend.SubscribeStreamResponse{Status: backend.SubscribeStreamStatusOK}, nil
+}
+
+func (s *Service) PublishStream(_ context.Context, _ *backend.PublishStreamRequest) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{Status: backend.PublishStreamStatusPermissionDenied}, nil
+}
+
+// RunStream runs the query of the stream at its interval until the last
+// subscriber leaves. A failing run is logged and the stream goes on.
+func (s *Service) RunStream(ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender) error {
+	config, err := parseStreamRequest(req.Path, req.Data)
+	if err != nil {
+		return err
+	}
+	if !streamPathMatches(req.Path, req.PluginContext, req.Data) {
+		return fmt.Errorf("the stream path does not belong to the user")
+	}
+	dsInfo, err := s.getDSInfo(req.PluginContext)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(config.interval)
+	defer ticker.Stop()
+	return s.runQueryStream(ctx, req.PluginContext, dsInfo, config, ticker.C, sender.SendFrame)
+}
+
+// runQueryStream runs the query once right away and then for every tick. Only
+// the rows newer than the ones already sent for a series are sent, and series
+// no longer returned by the query are forgotten.
+func (s *Service) runQueryStream(ctx context.Context, pluginCtx backend.PluginContext, dsInfo *DatasourceInfo, config *streamConfig,
+	ticks <-chan time.Time, send func(*data.Frame, data.FrameInclude) error) error {
+	lastSent := map[string]time.Time{}
+	now := time.Now()
+	for {
+		from := now.Add(-config.rng)
+		req := &backend.QueryDataRequest{
+			PluginContext: pluginCtx,
+			Headers:       s.streamHeaders(ctx, pluginCtx),
+			Queries: []backend.DataQuery{{
+				RefID:         "A",
+				JSON:          config.query,
+				TimeRange:     backend.TimeRange{From: from, To: now},
+				Interval:      config.interval,
+				MaxDataPoints: int64(config.rng/config.interval) + 1,
+			}},
+		}
+		res, err := s.executeTimeSeriesQuery(ctx, req, dsInfo)
+		if err == nil {
+			err = res.Responses["A"].Error
+		}
+		if err != nil {
+			plog.Warn("Stream query failed", "err", err)
+		} else {
+			keys := make(map[string]bool, len(res.Responses["A"].Frames))
+			for _, frame := range res.Responses["A"].Frames {
+				keys[streamKey(frame)] = true
+				if err := sendNewRows(frame, lastSent, send); err != nil {
+					return err
+				}
+			}
+			for key := range lastSent {
+				if !keys[key] {
+					delete(lastSent, key)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case now = <-ticks:
+		}
+	}
+}
+
+func sendNewRows(frame *data.Frame, lastSent map[string]time.Time, send func(*data.Frame, data.FrameInclude) error) error {
+	if len(frame.Fields) == 0 || frame.Fields[0].Type() != data.FieldTypeTime {
+		return nil
+	}
+	key := streamKey(frame)
+	last, seen := lastSent[key]
+	filtered, err := frame.FilterRowsByField(0, func(t interface{}) (bool, error) {
+		return !seen || t.(time.Time).After(last), nil
+	})
+	if err != nil || filtered.Rows() == 0 {
+		return err
+	}
+	lastSent[key] = filtered.Fields[0].At(filtered.Rows() - 1).(time.Time)
+	return send(filtered, data.IncludeAll)
+}
+
+// streamKey tells the frames of a stream apart: series by their fingerprint,
+// other frames by their name.
+func streamKey(frame *data.Frame) string {
+	if frame.Meta != nil {
+		if custom, ok := frame.Meta.Custom.(map[string]interface{}); ok {
+			if fingerprint, ok := custom["fingerprint"].(string); ok {
+				return fingerprint
+			}
+		}
+	}
+	return frame.Name
+}
+
+// streamPathHash returns the hash of the user and the subscription data the
+// channel path of a stream ends with: the hex-encoded SHA-256 of the ID of the
+// organization, the login of the user and the compacted JSON data, separated
+// by newlines.
+func streamPathHash(orgID int64, user *backend.User, raw json.RawMessage) string {
+	login := ""
+	if user != nil {
+		login = user.Login
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, raw); err != nil {
+		compacted.Reset()
+		compacted.Write(raw)
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s\n%s", orgID, login, compacted.String())))
+	return hex.EncodeToString(sum[:])
+}
+
+// streamPathMatches returns whether path is the channel path of the user of the
+// plugin context with the subscription data.
+func streamPathMatches(path string, pluginCtx backend.PluginContext, raw json.RawMessage) bool {
+	if pluginCtx.User == nil || pluginCtx.User.Login == "" {
+		return false
+	}
+	return path == streamPathPrefix+streamPathHash(pluginCtx.OrgID, pluginCtx.User, raw)
+}
+
+// streamHeaders returns the headers of the queries of a stream. Stream requests
+// don't carry the headers of the user like query requests do, so when the data
+// source forwards the OAuth identity the headers are made from the token of the
+// user who started the stream, the only user its channel path lets subscribe.
+// They are looked up for every run of the query, which refreshes expired tokens.
+func (s *Service) streamHeaders(ctx context.Context, pluginCtx backend.PluginContext) map[string]string {
+	headers := map[string]string{}
+	if s.oAuthTokenService == nil || pluginCtx.User == nil || pluginCtx.DataSourceInstanceSettings == nil {
+		return headers
+	}
+	jsonData, err := simplejson.NewJson(pluginCtx.DataSourceInstanceSettings.JSONData)
+	if err != nil || !s.oAuthTokenService.IsOAuthPassThruEnabled(&models.DataSource{JsonData: jsonData}) {
+		return headers
+	}
+
+	user, err := adapters.SignedInUserFromBackendUser(ctx, pluginCtx.OrgID, pluginCtx.User)
+	if err != nil {
+		plog.Warn("Failed to get the user of the stream", "login", pluginCtx.User.Login, "err", err)
+		return headers
+	}
+	if token := s.oAuthTokenService.GetCurrentOAuthToken(ctx, user); token != nil {
+		headers["Authorization"] = fmt.Sprintf("%s %s", token.Type(), token.AccessToken)
+		if idToken, ok := token.Extra("id_token").(string); ok && idToken != "" {
+			headers["X-ID-Token"] = idToken
+		}
+	}
+	return headers
+}
@@ -0,0 +1,264 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/infra/tracing"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestParseStreamRequest(t *testing.T) {
+	t.Run("it runs an instant query without a range", func(t *testing.T) {
+		config, err := parseStreamRequest("query/a", json.RawMessage(`{"query": {"expr": "up", "range": true, "exemplar": true}, "interval": "5s"}`))
+		require.NoError(t, err)
+		require.Equal(t, 5*time.Second, config.interval)
+		require.Zero(t, config.rng)
+		require.JSONEq(t, `{"expr": "up", "instant": true, "range": false, "exemplar": false}`, string(config.query))
+	})
+
+	t.Run("it runs a range query with a range", func(t *testing.T) {
+		config, err := parseStreamRequest("query/a", json.RawMessage(`{"query": {"expr": "up"}, "range": "5m"}`))
+		require.NoError(t, err)
+		require.Equal(t, defaultStreamInterval, config.interval)
+		require.Equal(t, 5*time.Minute, config.rng)
+		require.JSONEq(t, `{"expr": "up", "instant": false, "range": true, "exemplar": false}`, string(config.query))
+	})
+
+	t.Run("it validates the request", func(t *testing.T) {
+		for path, raw := range map[string]string{
+			"other/a": `{"query": {"expr": "up"}}`,
+			"query/a": `{"interval": "10s"}`,
+			"query/b": `{"query": {"expr": "up"}, "interval": "100ms"}`,
+			"query/c": `{"query": {"expr": "up"}, "range": "1d"}`,
+		} {
+			_, err := parseStreamRequest(path, json.RawMessage(raw))
+			require.Error(t, err, path)
+		}
+	})
+}
+
+func TestStreamPath(t *testing.T) {
+	s := newResourceTestService(&metadataClient{}, 0)
+	raw := json.RawMessage(`{"query": {"expr": "up"}, "interval": "5s"}`)
+	alice := backend.PluginContext{
+		OrgID:                      1,
+		User:                       &backend.User{Login: "alice"},
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{ID: 1, UID: "prometheus"},
+	}
+	bob := alice
+	bob.User = &backend.User{Login: "bob"}
+	path := streamPathPrefix + streamPathHash(alice.OrgID, alice.User, raw)
+
+	t.Run("it lets the user of the path subscribe", func(t *testing.T) {
+		res, err := s.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{PluginContext: alice, Path: path, Data: raw})
+		require.NoError(t, err)
+		require.Equal(t, backend.SubscribeStreamStatusOK, res.Status)
+
+		// The hash doesn't depend on the formatting of the data.
+		compacted := json.RawMessage(`{"query":{"expr":"up"},"interval":"5s"}`)
+		res, err = s.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{PluginContext: alice, Path: path, Data: compacted})
+		require.NoError(t, err)
+		require.Equal(t, backend.SubscribeStreamStatusOK, res.Status)
+	})
+
+	t.Run("it rejects another user on the same path", func(t *testing.T) {
+		res, err := s.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{PluginContext: bob, Path: path, Data: raw})
+		require.NoError(t, err)
+		require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, res.Status)
+
+		err = s.RunStream(context.Background(), &backend.RunStreamRequest{PluginContext: bob, Path: path, Data: raw}, nil)
+		require.Error(t, err)
+	})
+
+	t.Run("it rejects another query on the same path", func(t *testing.T) {
+		other := json.RawMessage(`{"query": {"expr": "down"}, "interval": "5s"}`)
+		res, err := s.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{PluginContext: alice, Path: path, Data: other})
+		require.NoError(t, err)
+		require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, res.Status)
+	})
+
+	t.Run("it rejects subscriptions without a user", func(t *testing.T) {
+		anonymous := alice
+		anonymous.User = nil
+		res, err := s.SubscribeStream(context.Background(), &backend.SubscribeStreamRequest{
+			PluginContext: anonymous,
+			Path:          streamPathPrefix + streamPathHash(anonymous.OrgID, nil, raw),
+			Data:          raw,
+		})
+		require.NoError(t, err)
+		require.Equal(t, backend.SubscribeStreamStatusPermissionDenied, res.Status)
+	})
+}
+
+func TestRunQueryStream(t *testing.T) {
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	s := &Service{tracer: tracer, intervalCalculator: intervalv2.NewCalculator()}
+
+	start := time.Unix(1641889530, 0)
+	// The second run returns the same sample as the first, which is not sent again.
+	client := &streamClient{timestamps: []time.Time{start, start, start.Add(20 * time.Second), start.Add(20 * time.Second)}}
+	dsInfo := &DatasourceInfo{getClient: func(map[string]string) (apiv1.API, error) {
+		return client, nil
+	}}
+	config, err := parseStreamRequest("query/a", json.RawMessage(`{"query": {"expr": "up"}}`))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan time.Time)
+	var sent []*data.Frame
+	done := make(chan error)
+	go func() {
+		done <- s.runQueryStream(ctx, backend.PluginContext{}, dsInfo, config, ticks, func(frame *data.Frame, _ data.FrameInclude) error {
+			sent = append(sent, frame)
+			return nil
+		})
+	}()
+
+	// The first run starts right away.
+	ticks <- start.Add(10 * time.Second)
+	ticks <- start.Add(20 * time.Second)
+	ticks <- start.Add(30 * time.Second)
+	cancel()
+	require.NoError(t, <-done)
+
+	require.Len(t, sent, 2)
+	require.Equal(t, start.UTC(), sent[0].Fields[0].At(0))
+	require.Equal(t, start.Add(20*time.Second).UTC(), sent[1].Fields[0].At(0))
+}
+
+func TestRunQueryStreamForgetsSeries(t *testing.T) {
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	s := &Service{tracer: tracer, intervalCalculator: intervalv2.NewCalculator()}
+
+	start := time.Unix(1641889530, 0)
+	// The series of the first run is missing from the second one, so its sample
+	// is sent again when it is back.
+	client := &streamClient{
+		timestamps: []time.Time{start, start.Add(10 * time.Second), start},
+		instances:  []string{"a", "b", "a"},
+	}
+	dsInfo := &DatasourceInfo{getClient: func(map[string]string) (apiv1.API, error) {
+		return client, nil
+	}}
+	config, err := parseStreamRequest("query/a", json.RawMessage(`{"query": {"expr": "up"}}`))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan time.Time)
+	var sent []*data.Frame
+	done := make(chan error)
+	go func() {
+		done <- s.runQueryStream(ctx, backend.PluginContext{}, dsInfo, config, ticks, func(frame *data.Frame, _ data.FrameInclude) error {
+			sent = append(sent, frame)
+			return nil
+		})
+	}()
+
+	ticks <- start.Add(10 * time.Second)
+	ticks <- start.Add(20 * time.Second)
+	cancel()
+	require.NoError(t, <-done)
+
+	require.Len(t, sent, 3)
+	require.Equal(t, start.UTC(), sent[2].Fields[0].At(0))
+}
+
+func TestRunQueryStreamHeaders(t *testing.T) {
+	bus.AddHandler("test", func(ctx context.Context, query *models.GetUserByLoginQuery) error {
+		query.Result = &models.User{Id: 2, Login: query.LoginOrEmail}
+		return nil
+	})
+	bus.AddHandler("test", func(ctx context.Context, query *models.GetSignedInUserQuery) error {
+		query.Result = &models.SignedInUser{UserId: query.UserId, OrgId: query.OrgId, Login: "user"}
+		return nil
+	})
+	t.Cleanup(bus.ClearBusHandlers)
+
+	tracer, err := tracing.InitializeTracerForTest()
+	require.NoError(t, err)
+	tokens := &fakeOAuthTokenService{token: (&oauth2.Token{AccessToken: "token", TokenType: "Bearer"}).
+		WithExtra(map[string]interface{}{"id_token": "id-token"})}
+	s := &Service{tracer: tracer, intervalCalculator: intervalv2.NewCalculator(), oAuthTokenService: tokens}
+
+	start := time.Unix(1641889530, 0)
+	client := &streamClient{timestamps: []time.Time{start}}
+	var headers map[string]string
+	dsInfo := &DatasourceInfo{getClient: func(h map[string]string) (apiv1.API, error) {
+		headers = h
+		return client, nil
+	}}
+	config, err := parseStreamRequest("query/a", json.RawMessage(`{"query": {"expr": "up"}}`))
+	require.NoError(t, err)
+	pluginCtx := backend.PluginContext{
+		OrgID: 1,
+		User:  &backend.User{Login: "user"},
+		DataSourceInstanceSettings: &backend.DataSourceInstanceSettings{
+			JSONData: json.RawMessage(`{"oauthPassThru": true}`),
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.runQueryStream(ctx, pluginCtx, dsInfo, config, nil, func(*data.Frame, data.FrameInclude) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"Authorization": "Bearer token", "X-ID-Token": "id-token"}, headers)
+	require.Equal(t, int64(2), tokens.user.UserId)
+	require.Equal(t, int64(1), tokens.user.OrgId)
+}
+
+type fakeOAuthTokenService struct {
+	token *oauth2.Token
+	user  *models.SignedInUser
+}
+
+func (ts *fakeOAuthTokenService) GetCurrentOAuthToken(_ context.Context, user *models.SignedInUser) *oauth2.Token {
+	ts.user = user
+	return ts.token
+}
+
+func (ts *fakeOAuthTokenService) IsOAuthPassThruEnabled(ds *models.DataSource) bool {
+	return ds.JsonData.Get("oauthPassThru").MustBool()
+}
+
+type streamClient struct {
+	apiv1.API
+
+	mu         sync.Mutex
+	timestamps []time.Time
+	// instances are the instance labels of the series, one per query.
+	instances []string
+}
+
+func (c *streamClient) Query(ctx context.Context, query string, ts time.Time) (model.Value, apiv1.Warnings, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timestamp := c.timestamps[0]
+	c.timestamps = c.timestamps[1:]
+	metric := model.Metric{"__name__": "up"}
+	if len(c.instances) > 0 {
+		metric["instance"] = model.LabelValue(c.instances[0])
+		c.instances = c.instances[1:]
+	}
+	return model.Vector{{
+		Metric:    metric,
+		Value:     1,
+		Timestamp: model.TimeFromUnixNano(timestamp.UnixNano()),
+	}}, nil, nil
+}
@@ -0,0 +1,158 @@
+package prometheus
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/prometheus/common/model"
+)
+
+// mergeInstantTables joins the instant results of all queries with MergeInstantTable
+// set into a single table frame. Series are joined on their labels, without the
+// metric name, and every query contributes a value column named by its refId.
+// The table is returned for the first merged query, the other merged queries
+// return no frames.
+func mergeInstantTables(result *backend.QueryDataResponse, queries []*PrometheusQuery) {
+	var refIDs []string
+	for _, query := range queries {
+		if !query.MergeInstantTable || !query.InstantQuery || query.RangeQuery {
+			continue
+		}
+		if res, ok := result.Responses[query.RefId]; !ok || res.Error != nil {
+			continue
+		}
+		refIDs = append(refIDs, query.RefId)
+	}
+	if len(refIDs) < 2 {
+		return
+	}
+
+	type row struct {
+		labels data.Labels
+		values map[string]float64
+	}
+	rows := map[string]*row{}
+	labelNames := map[string]bool{}
+
+	for _, refID := range refIDs {
+		for _, frame := range result.Responses[refID].Frames {
+			if frameResultType(frame) != "vector" || frame.Rows() == 0 {
+				continue
+			}
+			valueField := frame.Fields[1]
+			labels := data.Labels{}
+			for k, v := range valueField.Labels {
+				if k != model.MetricNameLabel {
+					labels[k] = v
+					labelNames[k] = true
+				}
+			}
+			key := labels.String()
+			r, ok := rows[key]
+			if !ok {
+				r = &row{labels: labels, values: map[string]float64{}}
+				rows[key] = r
+			}
+			v, _ := valueField.FloatAt(0)
+			r.values[refID] = v
+		}
+	}
+
+	names := make([]string, 0, len(labelNames))
+	for name := range labelNames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	keys := make([]string, 0, len(rows))
+	for key := range rows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]*data.Field, 0, len(names)+len(refIDs))
+	for _, name := range names {
+		field := data.NewFieldFromFieldType(data.FieldTypeString, len(keys))
+		field.Name = name
+		fields = append(fields, field)
+	}
+	for _, refID := range refIDs {
+		field := data.NewFieldFromFieldType(data.FieldTypeNullableFloat64, len(keys))
+		field.Name = refID
+		fields = append(fields, field)
+	}
+
+	for i, key := range keys {
+		r := rows[key]
+		for j, name := range names {
+			fields[j].Set(i, r.labels[name])
+		}
+		for j, refID := range refIDs {
+			// Series missing from a query stay null.
+			if v, ok := r.values[refID]; ok {
+				fields[len(names)+j].Set(i, &v)
+			}
+		}
+	}
+
+	table := newDataFrame(strings.Join(refIDs, ","), "table", fields...)
+	table.RefID = refIDs[0]
+	result.Responses[refIDs[0]] = backend.DataResponse{Frames: data.Frames{table}}
+	for _, refID := range refIDs[1:] {
+		result.Responses[refID] = backend.DataResponse{Frames: data.Frames{}}
+	}
+}
+
+// vectorToTableFrame converts an instant vector into a single table frame with a
+// row per series: the time, a column per label, the metric name first, and the value.
+// Labels a series does not have are empty.
+func vectorToTableFrame(vector model.Vector, query *PrometheusQuery) *data.Frame {
+	labelNames := map[string]bool{}
+	for _, sample := range vector {
+		for name := range sample.Metric {
+			labelNames[string(name)] = true
+		}
+	}
+	names := make([]string, 0, len(labelNames))
+	for name := range labelNames {
+		if name != model.MetricNameLabel {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if labelNames[model.MetricNameLabel] {
+		names = append([]string{model.MetricNameLabel}, names...)
+	}
+
+	samples := make(model.Vector, len(vector))
+	copy(samples, vector)
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Metric.Before(samples[j].Metric)
+	})
+
+	timeField := data.NewFieldFromFieldType(data.FieldTypeTime, len(samples))
+	timeField.Name = data.TimeSeriesTimeFieldName
+	valueField := data.NewFieldFromFieldType(data.FieldTypeFloat64, len(samples))
+	valueField.Name = data.TimeSeriesValueFieldName
+	labelFields := make([]*data.Field, len(names))
+	for i, name := range names {
+		labelFields[i] = data.NewFieldFromFieldType(data.FieldTypeString, len(samples))
+		labelFields[i].Name = name
+	}
+
+	for i, sample := range samples {
+		timeField.Set(i, sample.Timestamp.Time().UTC())
+		valueField.Set(i, float64(sample.Value))
+		for j, name := range names {
+			labelFields[j].Set(i, string(sample.Metric[model.LabelName(name)]))
+		}
+	}
+
+	fields := make([]*data.Field, 0, len(names)+2)
+	fields = append(fields, timeField)
+	fields = append(fields, labelFields...)
+	fields = append(fields, valueField)
+	return newDataFrame(query.RefId, "table", fields...)
+}
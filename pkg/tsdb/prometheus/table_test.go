@@ -0,0 +1,100 @@
+package prometheus
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	p "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeInstantTables(t *testing.T) {
+	t.Run("merges two instant vectors on their labels", func(t *testing.T) {
+		queryA := &PrometheusQuery{RefId: "A", InstantQuery: true, MergeInstantTable: true}
+		queryB := &PrometheusQuery{RefId: "B", InstantQuery: true, MergeInstantTable: true}
+
+		framesA := vectorToDataFrames(p.Vector{
+			&p.Sample{Metric: p.Metric{"__name__": "up", "instance": "a"}, Value: 1, Timestamp: 1000},
+			&p.Sample{Metric: p.Metric{"__name__": "up", "instance": "b"}, Value: 0, Timestamp: 1000},
+		}, queryA, data.Frames{})
+		framesB := vectorToDataFrames(p.Vector{
+			&p.Sample{Metric: p.Metric{"__name__": "cpu", "instance": "a"}, Value: 0.5, Timestamp: 1000},
+			&p.Sample{Metric: p.Metric{"__name__": "cpu", "instance": "c"}, Value: 0.7, Timestamp: 1000},
+		}, queryB, data.Frames{})
+
+		result := &backend.QueryDataResponse{Responses: backend.Responses{
+			"A": {Frames: framesA},
+			"B": {Frames: framesB},
+		}}
+		mergeInstantTables(result, []*PrometheusQuery{queryA, queryB})
+
+		require.Len(t, result.Responses["B"].Frames, 0)
+		require.Len(t, result.Responses["A"].Frames, 1)
+		table := result.Responses["A"].Frames[0]
+		require.Len(t, table.Fields, 3)
+		require.Equal(t, "instance", table.Fields[0].Name)
+		require.Equal(t, "A", table.Fields[1].Name)
+		require.Equal(t, "B", table.Fields[2].Name)
+		require.Equal(t, 3, table.Rows())
+
+		one, zero, half, seven := 1.0, 0.0, 0.5, 0.7
+		var null *float64
+		require.Equal(t, []interface{}{"a", &one, &half}, table.RowCopy(0))
+		require.Equal(t, []interface{}{"b", &zero, null}, table.RowCopy(1))
+		require.Equal(t, []interface{}{"c", null, &seven}, table.RowCopy(2))
+	})
+
+	t.Run("leaves responses alone without two mergeable queries", func(t *testing.T) {
+		queryA := &PrometheusQuery{RefId: "A", InstantQuery: true, MergeInstantTable: true}
+		queryB := &PrometheusQuery{RefId: "B", InstantQuery: true}
+
+		framesA := vectorToDataFrames(p.Vector{
+			&p.Sample{Metric: p.Metric{"instance": "a"}, Value: 1, Timestamp: 1000},
+		}, queryA, data.Frames{})
+		result := &backend.QueryDataResponse{Responses: backend.Responses{
+			"A": {Frames: framesA},
+			"B": {Frames: data.Frames{}},
+		}}
+		mergeInstantTables(result, []*PrometheusQuery{queryA, queryB})
+
+		require.Equal(t, framesA, result.Responses["A"].Frames)
+	})
+}
+
+func TestVectorToTableFrame(t *testing.T) {
+	t.Run("converts an instant vector into a table with a row per series", func(t *testing.T) {
+		query := &PrometheusQuery{RefId: "A", InstantQuery: true, Format: formatTable}
+		value := map[TimeSeriesQueryType]interface{}{
+			InstantQueryType: p.Vector{
+				&p.Sample{Metric: p.Metric{"__name__": "up", "job": "b", "instance": "b:9090"}, Value: 0, Timestamp: 1000},
+				&p.Sample{Metric: p.Metric{"__name__": "up", "job": "a"}, Value: 1, Timestamp: 1000},
+			},
+		}
+
+		frames, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, frames, 1)
+
+		table := frames[0]
+		require.Equal(t, "table", frameResultType(table))
+		names := []string{}
+		for _, field := range table.Fields {
+			names = append(names, field.Name)
+		}
+		require.Equal(t, []string{"Time", "__name__", "instance", "job", "Value"}, names)
+		require.Equal(t, 2, table.Rows())
+
+		require.Equal(t, "a", table.Fields[3].At(0))
+		require.Equal(t, "", table.Fields[2].At(0))
+		require.Equal(t, 1.0, table.Fields[4].At(0))
+		require.Equal(t, "b:9090", table.Fields[2].At(1))
+		require.Equal(t, 0.0, table.Fields[4].At(1))
+	})
+
+	t.Run("returns an empty table for an empty vector", func(t *testing.T) {
+		table := vectorToTableFrame(p.Vector{}, &PrometheusQuery{RefId: "A"})
+		require.Len(t, table.Fields, 2)
+		require.Equal(t, 0, table.Rows())
+	})
+}
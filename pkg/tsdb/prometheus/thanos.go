@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// thanosResolutions are the downsampling resolutions of Thanos, by the values of
+// the maxSourceResolution option.
+var thanosResolutions = map[string]string{
+	"auto": "auto",
+	"raw":  "0s",
+	"0s":   "0s",
+	"5m":   "5m",
+	"1h":   "1h",
+}
+
+// ThanosOptions are the query options of Thanos queriers. Prometheus ignores them.
+type ThanosOptions struct {
+	// PartialResponse returns the results of the available stores when others
+	// fail, instead of an error. The querier default applies when nil.
+	PartialResponse *bool `json:"partialResponse"`
+	// Dedup deduplicates the series of replicas. The querier default applies when nil.
+	Dedup *bool `json:"dedup"`
+	// MaxSourceResolution is the coarsest downsampling resolution to read, one of
+	// auto, raw, 5m or 1h.
+	MaxSourceResolution string `json:"maxSourceResolution"`
+	// StoreMatch limits the query to the stores whose labels match any of these
+	// series selectors, e.g. {cluster="eu"}.
+	StoreMatch []string `json:"storeMatch"`
+}
+
+// queryParameters validates the options and returns them as parameters of the
+// query API.
+func (o *ThanosOptions) queryParameters() (url.Values, error) {
+	values := url.Values{}
+	if o == nil {
+		return values, nil
+	}
+	if o.PartialResponse != nil {
+		values.Set("partial_response", strconv.FormatBool(*o.PartialResponse))
+	}
+	if o.Dedup != nil {
+		values.Set("dedup", strconv.FormatBool(*o.Dedup))
+	}
+	if o.MaxSourceResolution != "" {
+		resolution, ok := thanosResolutions[o.MaxSourceResolution]
+		if !ok {
+			return nil, fmt.Errorf("invalid Thanos max source resolution %q", o.MaxSourceResolution)
+		}
+		values.Set("max_source_resolution", resolution)
+	}
+	for _, match := range o.StoreMatch {
+		if _, err := parser.ParseMetricSelector(match); err != nil {
+			return nil, fmt.Errorf("invalid Thanos store match %q: %w", match, err)
+		}
+		values.Add("storeMatch[]", match)
+	}
+	return values, nil
+}
@@ -0,0 +1,58 @@
+package prometheus
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThanosOptions(t *testing.T) {
+	service := Service{intervalCalculator: intervalv2.NewCalculator()}
+	parse := func(json string) ([]*PrometheusQuery, error) {
+		query := backend.DataQuery{
+			JSON:      []byte(json),
+			TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)},
+		}
+		return service.parseTimeSeriesQuery(&backend.QueryDataRequest{Queries: []backend.DataQuery{query}}, &DatasourceInfo{})
+	}
+
+	t.Run("it sends the options as query parameters", func(t *testing.T) {
+		queries, err := parse(`{
+			"expr": "up",
+			"customQueryParameters": "dedup=true&foo=bar",
+			"thanos": {
+				"partialResponse": false,
+				"dedup": false,
+				"maxSourceResolution": "raw",
+				"storeMatch": ["{cluster=\"eu\"}", "{cluster=\"us\"}"]
+			},
+			"refId": "A"
+		}`)
+		require.NoError(t, err)
+		require.Equal(t, url.Values{
+			"foo":                   {"bar"},
+			"partial_response":      {"false"},
+			"dedup":                 {"false"},
+			"max_source_resolution": {"0s"},
+			"storeMatch[]":          {`{cluster="eu"}`, `{cluster="us"}`},
+		}, queries[0].CustomQueryParameters)
+	})
+
+	t.Run("it leaves unset options to the querier", func(t *testing.T) {
+		queries, err := parse(`{"expr": "up", "thanos": {"maxSourceResolution": "auto"}, "refId": "A"}`)
+		require.NoError(t, err)
+		require.Equal(t, url.Values{"max_source_resolution": {"auto"}}, queries[0].CustomQueryParameters)
+	})
+
+	t.Run("it validates the options", func(t *testing.T) {
+		_, err := parse(`{"expr": "up", "thanos": {"maxSourceResolution": "10m"}, "refId": "A"}`)
+		require.EqualError(t, err, `invalid Thanos max source resolution "10m"`)
+
+		_, err = parse(`{"expr": "up", "thanos": {"storeMatch": ["{cluster="]}, "refId": "A"}`)
+		require.Error(t, err)
+	})
+}
@@ -2,22 +2,34 @@ package prometheus
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"math"
+	"net/url"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/tracing"
 	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	"github.com/grafana/grafana/pkg/tsdb/legend"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/middleware"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+var timeNow = time.Now
+
 //Internal interval and range variables
 const (
 	varInterval     = "$__interval"
@@ -26,92 +38,301 @@ const (
 	varRangeS       = "$__range_s"
 	varRangeMs      = "$__range_ms"
 	varRateInterval = "$__rate_interval"
+	varOffset       = "$__offset"
+	// The Unix seconds of the edges of the dashboard range, e.g. for the @ modifier.
+	varFromTimestamp = "$__from_timestamp"
+	varToTimestamp   = "$__to_timestamp"
 )
 
 //Internal interval and range variables with {} syntax
 //Repetitive code, we should have functionality to unify these
 const (
-	varIntervalAlt     = "${__interval}"
-	varIntervalMsAlt   = "${__interval_ms}"
-	varRangeAlt        = "${__range}"
-	varRangeSAlt       = "${__range_s}"
-	varRangeMsAlt      = "${__range_ms}"
-	varRateIntervalAlt = "${__rate_interval}"
+	varIntervalAlt      = "${__interval}"
+	varIntervalMsAlt    = "${__interval_ms}"
+	varRangeAlt         = "${__range}"
+	varRangeSAlt        = "${__range_s}"
+	varRangeMsAlt       = "${__range_ms}"
+	varRateIntervalAlt  = "${__rate_interval}"
+	varOffsetAlt        = "${__offset}"
+	varFromTimestampAlt = "${__from_timestamp}"
+	varToTimestampAlt   = "${__to_timestamp}"
 )
 
+// intervalMultipleRegex matches $__interval_x{N} and ${__interval_x{N}}, N times the interval.
+var intervalMultipleRegex = regexp.MustCompile(`\$__interval_x(\d+)|\$\{__interval_x(\d+)\}`)
+
+// awsRegionRegex matches AWS regions like us-east-1 or us-gov-west-1.
+var awsRegionRegex = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
 type TimeSeriesQueryType string
 
 const (
 	RangeQueryType    TimeSeriesQueryType = "range"
 	InstantQueryType  TimeSeriesQueryType = "instant"
 	ExemplarQueryType TimeSeriesQueryType = "exemplar"
+	// SeasonalBaselineQueryType is the range query shifted back by the seasonal offset.
+	// It does not produce frames of its own.
+	SeasonalBaselineQueryType TimeSeriesQueryType = "seasonalBaseline"
 )
 
-func (s *Service) runQueries(ctx context.Context, client apiv1.API, queries []*PrometheusQuery) (*backend.QueryDataResponse, error) {
+const defaultQueryConcurrency = 10
+
+// runQueries runs the queries at most concurrency at a time, or
+// defaultQueryConcurrency when it is not positive. A query failing on the
+// Prometheus side only fails its own response; failing to convert a result
+// fails the whole request, as the first such query in request order.
+func (s *Service) runQueries(ctx context.Context, client apiv1.API, queries []*PrometheusQuery, concurrency int) (*backend.QueryDataResponse, error) {
 	result := backend.QueryDataResponse{
 		Responses: backend.Responses{},
 	}
 
-	for _, query := range queries {
-		plog.Debug("Sending query", "start", query.Start, "end", query.End, "step", query.Step, "query", query.Expr)
+	if concurrency <= 0 {
+		concurrency = defaultQueryConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
+	responses := make([]backend.DataResponse, len(queries))
+	errs := make([]error, len(queries))
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		i, query := i, query
+		// The slot is taken before starting the goroutine so that the queries
+		// start in the order of the request.
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			responses[i], errs[i] = s.runQuery(ctx, client, query)
+		}()
+	}
+	wg.Wait()
 
-		ctx, span := s.tracer.Start(ctx, "datasource.prometheus")
-		span.SetAttributes("expr", query.Expr, attribute.Key("expr").String(query.Expr))
-		span.SetAttributes("start_unixnano", query.Start, attribute.Key("start_unixnano").Int64(query.Start.UnixNano()))
-		span.SetAttributes("stop_unixnano", query.End, attribute.Key("stop_unixnano").Int64(query.End.UnixNano()))
-		defer span.End()
+	for i, query := range queries {
+		if errs[i] != nil {
+			return &result, errs[i]
+		}
+		result.Responses[query.RefId] = responses[i]
+	}
 
-		response := make(map[TimeSeriesQueryType]interface{})
+	mergeInstantTables(&result, queries)
 
-		timeRange := apiv1.Range{
-			Step: query.Step,
-			// Align query range to step. It rounds start and end down to a multiple of step.
-			Start: alignTimeRange(query.Start, query.Step, query.UtcOffsetSec),
-			End:   alignTimeRange(query.End, query.Step, query.UtcOffsetSec),
-		}
+	return &result, nil
+}
+
+func (s *Service) runQuery(ctx context.Context, client apiv1.API, query *PrometheusQuery) (backend.DataResponse, error) {
+	plog.Debug("Sending query", "start", query.Start, "end", query.End, "step", query.Step, "query", query.Expr)
+
+	ctx, span := s.tracer.Start(ctx, "datasource.prometheus")
+	span.SetAttributes("expr", query.Expr, attribute.Key("expr").String(query.Expr))
+	span.SetAttributes("start_unixnano", query.Start, attribute.Key("start_unixnano").Int64(query.Start.UnixNano()))
+	span.SetAttributes("stop_unixnano", query.End, attribute.Key("stop_unixnano").Int64(query.End.UnixNano()))
+	hash := exprHash(query.Expr)
+	span.SetAttributes("expr_hash", hash, attribute.Key("expr_hash").String(hash))
+	span.SetAttributes("step_ms", query.Step.Milliseconds(), attribute.Key("step_ms").Int64(query.Step.Milliseconds()))
+	rangeMs := query.End.Sub(query.Start).Milliseconds()
+	span.SetAttributes("range_ms", rangeMs, attribute.Key("range_ms").Int64(rangeMs))
+	defer span.End()
+
+	if err := checkCapabilities(query); err != nil {
+		recordSpanError(span, err)
+		return backend.DataResponse{Error: err}, nil
+	}
+
+	// The whole range is in the future, there is nothing to query.
+	if query.EndClamped && query.End.Before(query.Start) {
+		return backend.DataResponse{Frames: data.Frames{futureRangeFrame(query)}}, nil
+	}
 
-		if query.RangeQuery {
-			rangeResponse, _, err := client.QueryRange(ctx, query.Expr, timeRange)
+	ctx = middleware.WithCustomQueryParameters(ctx, query.CustomQueryParameters)
+	ctx = middleware.WithQueryTimeout(ctx, query.Timeout)
+	ctx = middleware.WithSigV4Options(ctx, query.SigV4)
+	ctx, servedBy := middleware.WithServedBy(ctx)
+
+	response := make(map[TimeSeriesQueryType]interface{})
+	var stats *promclient.QueryStats
+	var warnings apiv1.Warnings
+
+	timeRange := queryTimeRange(query)
+
+	if query.RangeQuery {
+		rangeResponse, rangeStats, rangeWarnings, err := queryRange(ctx, client, query, timeRange)
+		if err != nil {
+			plog.Error("Range query failed", "query", query.Expr, "err", err)
+			recordSpanError(span, err)
+			return backend.DataResponse{Error: err}, nil
+		}
+		response[RangeQueryType] = rangeResponse
+		stats = rangeStats
+		warnings = rangeWarnings
+
+		// Like exemplars, a failing baseline query only disables the anomaly scores.
+		if query.SeasonalAnomaly {
+			baselineRange := timeRange
+			baselineRange.Start = timeRange.Start.Add(-query.SeasonalOffset)
+			baselineRange.End = timeRange.End.Add(-query.SeasonalOffset)
+			baselineResponse, _, err := client.QueryRange(ctx, query.Expr, baselineRange)
 			if err != nil {
-				plog.Error("Range query failed", "query", query.Expr, "err", err)
-				result.Responses[query.RefId] = backend.DataResponse{Error: err}
-				continue
+				plog.Error("Seasonal baseline query failed", "query", query.Expr, "err", err)
+			} else {
+				response[SeasonalBaselineQueryType] = baselineResponse
 			}
-			response[RangeQueryType] = rangeResponse
 		}
+	}
 
-		if query.InstantQuery {
-			instantResponse, _, err := client.Query(ctx, query.Expr, query.End)
-			if err != nil {
-				plog.Error("Instant query failed", "query", query.Expr, "err", err)
-				result.Responses[query.RefId] = backend.DataResponse{Error: err}
-				continue
-			}
-			response[InstantQueryType] = instantResponse
+	if query.InstantQuery {
+		instantResponse, instantWarnings, err := client.Query(ctx, query.Expr, query.End)
+		if err != nil {
+			plog.Error("Instant query failed", "query", query.Expr, "err", err)
+			recordSpanError(span, err)
+			return backend.DataResponse{Error: err}, nil
 		}
+		response[InstantQueryType] = instantResponse
+		warnings = appendWarnings(warnings, instantWarnings)
+	}
 
-		// This is a special case
-		// If exemplar query returns error, we want to only log it and continue with other results processing
-		if query.ExemplarQuery {
-			exemplarResponse, err := client.QueryExemplars(ctx, query.Expr, timeRange.Start, timeRange.End)
-			if err != nil {
-				plog.Error("Exemplar query failed", "query", query.Expr, "err", err)
-			} else {
-				response[ExemplarQueryType] = exemplarResponse
+	// This is a special case
+	// If exemplar query returns error, we want to only log it and continue with other results processing
+	if query.ExemplarQuery {
+		exemplarResponse, err := queryExemplars(ctx, client, query, timeRange)
+		if err != nil {
+			plog.Error("Exemplar query failed", "query", query.Expr, "err", err)
+		} else {
+			response[ExemplarQueryType] = exemplarResponse
+		}
+	}
+
+	frames, err := parseTimeSeriesResponse(response, query)
+	if err != nil {
+		recordSpanError(span, err)
+		return backend.DataResponse{}, err
+	}
+	seriesCount := countSeries(frames)
+	span.SetAttributes("series_count", seriesCount, attribute.Key("series_count").Int(seriesCount))
+	observeResultSeries(query, frames)
+	if stats != nil {
+		for _, frame := range frames {
+			setFrameCustomMeta(frame, "stats", stats)
+		}
+		// Prometheus only reports its timings when the query asks for statistics.
+		span.SetAttributes("prometheus_eval_total_time", stats.Timings.EvalTotalTime, attribute.Key("prometheus_eval_total_time").Float64(stats.Timings.EvalTotalTime))
+		span.SetAttributes("prometheus_exec_total_time", stats.Timings.ExecTotalTime, attribute.Key("prometheus_exec_total_time").Float64(stats.Timings.ExecTotalTime))
+	}
+	for _, warning := range warnings {
+		for _, frame := range frames {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     warning,
+			})
+		}
+	}
+	// Only data sources with secondary URLs record the backend serving a query.
+	if backendURL := servedBy.URL(); backendURL != "" {
+		for _, frame := range frames {
+			setFrameCustomMeta(frame, "servedBy", backendURL)
+			if servedBy.Secondary() {
+				frame.AppendNotices(data.Notice{
+					Severity: data.NoticeSeverityWarning,
+					Text:     fmt.Sprintf("The primary Prometheus server failed, the query was served by %s.", backendURL),
+				})
 			}
 		}
+	}
 
-		frames, err := parseTimeSeriesResponse(response, query)
-		if err != nil {
-			return &result, err
+	return backend.DataResponse{
+		Frames: frames,
+	}, nil
+}
+
+// exprHash identifies an expression in traces without recording all of it in the attribute.
+func exprHash(expr string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(expr))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+func recordSpanError(span tracing.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// rangeStreamer is implemented by clients that can decode range query results
+// series by series.
+type rangeStreamer interface {
+	QueryRangeStream(ctx context.Context, query string, r apiv1.Range, opts promclient.RangeQueryOptions, fn func(*promclient.Series) error) (*promclient.QueryStats, apiv1.Warnings, error)
+}
+
+// queryRange runs the range query of query. When the data source streams range
+// responses and the client supports it, every series is converted to frames as
+// soon as it is decoded and the frames are returned instead of the matrix.
+// Native histograms and query statistics are only supported this way.
+func queryRange(ctx context.Context, client apiv1.API, query *PrometheusQuery, timeRange apiv1.Range) (interface{}, *promclient.QueryStats, apiv1.Warnings, error) {
+	// Alert annotations are made from the matrix as Prometheus returns it.
+	if query.AlertAnnotations {
+		matrix, warnings, err := client.QueryRange(ctx, query.Expr, timeRange)
+		return matrix, nil, warnings, err
+	}
+
+	series, ok, err := queryRemoteRead(ctx, client, query, timeRange)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if ok {
+		frames := data.Frames{}
+		for _, s := range series {
+			frames = seriesToDataFrames(s, query, frames)
 		}
+		return frames, nil, nil, nil
+	}
 
-		result.Responses[query.RefId] = backend.DataResponse{
-			Frames: frames,
+	split := len(queryRanges(query, timeRange)) > 1
+	if query.IncrementalCache != nil {
+		series, stats, warnings, err := query.IncrementalCache.querySeries(ctx, query.IncrementalCacheKey, timeRange, func(ctx context.Context, r apiv1.Range) ([]*promclient.Series, *promclient.QueryStats, apiv1.Warnings, error) {
+			if len(queryRanges(query, r)) > 1 {
+				return querySplitSeries(ctx, client, query, r)
+			}
+			return querySeries(ctx, client, query, r)
+		})
+		if err != nil {
+			return nil, nil, warnings, err
 		}
+		frames := data.Frames{}
+		for _, s := range series {
+			frames = seriesToDataFrames(s, query, frames)
+		}
+		return frames, stats, warnings, nil
+	}
+	if split {
+		return querySplitRange(ctx, client, query, timeRange)
 	}
 
-	return &result, nil
+	streamer, ok := client.(rangeStreamer)
+	if !ok || !query.StreamRangeResponse {
+		matrix, warnings, err := client.QueryRange(ctx, query.Expr, timeRange)
+		return matrix, nil, warnings, err
+	}
+
+	frames := data.Frames{}
+	stats, warnings, err := streamer.QueryRangeStream(ctx, query.Expr, timeRange, rangeQueryOptions(query), func(series *promclient.Series) error {
+		frames = seriesToDataFrames(series, query, frames)
+		return nil
+	})
+	return frames, stats, warnings, err
+}
+
+func rangeQueryOptions(query *PrometheusQuery) promclient.RangeQueryOptions {
+	return promclient.RangeQueryOptions{Stats: query.Stats}
+}
+
+func seriesToDataFrames(series *promclient.Series, query *PrometheusQuery, frames data.Frames) data.Frames {
+	if len(series.Histograms) > 0 {
+		frames = histogramToDataFrames(series.Metric, series.Histograms, query, frames)
+	}
+	if len(series.Values) > 0 || len(series.Histograms) == 0 {
+		frames = matrixToDataFrames(model.Matrix{&series.SampleStream}, query, frames)
+	}
+	return frames
 }
 
 func (s *Service) executeTimeSeriesQuery(ctx context.Context, req *backend.QueryDataRequest, dsInfo *DatasourceInfo) (*backend.QueryDataResponse, error) {
@@ -128,53 +349,114 @@ func (s *Service) executeTimeSeriesQuery(ctx context.Context, req *backend.Query
 		return &result, err
 	}
 
-	return s.runQueries(ctx, client, queries)
+	if dsInfo.RecordingRules != nil {
+		rewriteRecordingRules(ctx, client, dsInfo.RecordingRules, req.Headers, queries)
+	}
+
+	if dsInfo.FlavorDetector != nil {
+		capabilities := dsInfo.FlavorDetector.capabilities(ctx, client)
+		for _, query := range queries {
+			query.Capabilities = capabilities
+		}
+	}
+
+	return s.runQueries(ctx, client, queries, dsInfo.QueryConcurrency)
 }
 
 func formatLegend(metric model.Metric, query *PrometheusQuery) string {
-	var legend string
+	var name string
 
 	if query.LegendFormat == "" {
-		legend = metric.String()
+		name = formatSeriesName(metric, query.LabelOrder)
 	} else {
-		result := legendFormat.ReplaceAllFunc([]byte(query.LegendFormat), func(in []byte) []byte {
-			labelName := strings.Replace(string(in), "{{", "", 1)
-			labelName = strings.Replace(labelName, "}}", "", 1)
-			labelName = strings.TrimSpace(labelName)
-			if val, exists := metric[model.LabelName(labelName)]; exists {
-				return []byte(val)
-			}
-			return []byte{}
-		})
-		legend = string(result)
+		labels := make(map[string]string, len(metric))
+		for label, value := range metric {
+			labels[string(label)] = string(value)
+		}
+		name = legend.Format(query.LegendFormat, labels, legend.Options{})
 	}
 
 	// If legend is empty brackets, use query expression
-	if legend == "{}" {
-		legend = query.Expr
+	if name == "{}" {
+		name = query.sourceExpr()
 	}
 
-	return legend
+	return name
+}
+
+// formatSeriesName renders a metric like model.Metric.String, but with the labels
+// in labelOrder first, in that order. The remaining labels follow sorted by name.
+func formatSeriesName(metric model.Metric, labelOrder []string) string {
+	if len(labelOrder) == 0 {
+		return metric.String()
+	}
+
+	labelStrings := make([]string, 0, len(metric))
+	seen := make(map[model.LabelName]bool, len(labelOrder))
+	for _, name := range labelOrder {
+		label := model.LabelName(name)
+		if value, ok := metric[label]; ok && label != model.MetricNameLabel && !seen[label] {
+			labelStrings = append(labelStrings, fmt.Sprintf("%s=%q", label, value))
+			seen[label] = true
+		}
+	}
+
+	rest := make([]string, 0, len(metric)-len(labelStrings))
+	for label, value := range metric {
+		if label != model.MetricNameLabel && !seen[label] {
+			rest = append(rest, fmt.Sprintf("%s=%q", label, value))
+		}
+	}
+	sort.Strings(rest)
+	labelStrings = append(labelStrings, rest...)
+
+	metricName, hasName := metric[model.MetricNameLabel]
+	if len(labelStrings) == 0 {
+		if hasName {
+			return string(metricName)
+		}
+		return "{}"
+	}
+	return fmt.Sprintf("%s{%s}", metricName, strings.Join(labelStrings, ", "))
 }
 
 func (s *Service) parseTimeSeriesQuery(queryContext *backend.QueryDataRequest, dsInfo *DatasourceInfo) ([]*PrometheusQuery, error) {
 	qs := []*PrometheusQuery{}
+	intervalCalculator := s.calculatorFor(dsInfo)
+	// The queries of a request share the same now, so that they get the same
+	// $__offset.
+	now := timeNow()
 	for _, query := range queryContext.Queries {
 		model := &QueryModel{}
 		err := json.Unmarshal(query.JSON, model)
 		if err != nil {
 			return nil, err
 		}
+		// A query can declare the scrape interval of its own job, it defaults to
+		// the one of the data source.
+		timeInterval := dsInfo.TimeInterval
+		if model.ScrapeInterval != "" {
+			if _, err := intervalv2.ParseIntervalStringToTimeDuration(model.ScrapeInterval); err != nil {
+				return nil, fmt.Errorf("invalid scrape interval %q: %w", model.ScrapeInterval, err)
+			}
+			timeInterval = model.ScrapeInterval
+		}
+
 		//Final interval value
-		interval, err := calculatePrometheusInterval(model, dsInfo, query, s.intervalCalculator)
+		interval, err := calculatePrometheusInterval(model, dsInfo, timeInterval, query, intervalCalculator)
 		if err != nil {
 			return nil, err
 		}
 
 		// Interpolate variables in expr
-		timeRange := query.TimeRange.To.Sub(query.TimeRange.From)
-		expr := interpolateVariables(model, interval, timeRange, s.intervalCalculator, dsInfo.TimeInterval)
+		// How far the range ends before now, e.g. by a time shift of the dashboard.
+		offset := now.Sub(query.TimeRange.To).Truncate(time.Second)
+		if offset < 0 {
+			offset = 0
+		}
+		expr := interpolateVariables(model, interval, query.TimeRange, offset, intervalCalculator, timeInterval)
 		rangeQuery := model.RangeQuery
+		instantQuery := model.InstantQuery
 		if !model.InstantQuery && !model.RangeQuery {
 			// In older dashboards, we were not setting range query param and !range && !instant was run as range query
 			rangeQuery = true
@@ -186,41 +468,274 @@ func (s *Service) parseTimeSeriesQuery(queryContext *backend.QueryDataRequest, d
 			exemplarQuery = false
 		}
 
+		// Alert annotations are made from the range of the alert series only.
+		alertAnnotations := query.QueryType == alertAnnotationsQueryType
+		if alertAnnotations {
+			if strings.TrimSpace(expr) == "" {
+				expr = "ALERTS"
+			}
+			rangeQuery, instantQuery, exemplarQuery = true, false, false
+		}
+
+		valueFilter, err := parseValueFilter(model.ValueFilter, model.ValueFilterAggregation)
+		if err != nil {
+			return nil, err
+		}
+
+		incompletePointsMode := IncompletePointsMode(model.IncompletePointsMode)
+		switch incompletePointsMode {
+		case "":
+			incompletePointsMode = IncompletePointsDrop
+		case IncompletePointsDrop, IncompletePointsMark:
+		default:
+			return nil, fmt.Errorf("invalid incomplete points mode %q", model.IncompletePointsMode)
+		}
+
+		resultFormat := ResultFormat(model.ResultFormat)
+		switch resultFormat {
+		case "":
+			resultFormat = ResultFormatWide
+		case ResultFormatWide, ResultFormatLong:
+		default:
+			return nil, fmt.Errorf("invalid result format %q", model.ResultFormat)
+		}
+		if resultFormat == ResultFormatLong && model.Format == formatHeatmap {
+			return nil, fmt.Errorf("the long result format is not supported by the heatmap format")
+		}
+
+		downsample, err := parseConsolidation(model.Downsample)
+		if err != nil {
+			return nil, err
+		}
+		var downsampleStepDuration time.Duration
+		if downsample != "" {
+			downsampleStepDuration = downsampleStep(interval, query.TimeRange.To.Sub(query.TimeRange.From), query.MaxDataPoints)
+		}
+
+		// The NaN policy applies to all NaN samples, unless a more specific policy is set.
+		boundaryPolicy, interiorPolicy := model.BoundaryNaNPolicy, model.InteriorNaNPolicy
+		if boundaryPolicy == "" {
+			boundaryPolicy = model.NaNPolicy
+		}
+		if interiorPolicy == "" {
+			interiorPolicy = model.NaNPolicy
+		}
+		boundaryNaNPolicy, err := parseNaNPolicy(boundaryPolicy)
+		if err != nil {
+			return nil, err
+		}
+		interiorNaNPolicy, err := parseNaNPolicy(interiorPolicy)
+		if err != nil {
+			return nil, err
+		}
+
+		seasonalOffset := defaultSeasonalOffset
+		if model.SeasonalOffset != "" {
+			seasonalOffset, err = intervalv2.ParseIntervalStringToTimeDuration(model.SeasonalOffset)
+			if err != nil {
+				return nil, fmt.Errorf("invalid seasonal offset %q: %w", model.SeasonalOffset, err)
+			}
+		}
+
+		// A time zone aligns steps to its clock, e.g. daily steps start at local midnight,
+		// also across DST changes. It takes precedence over the UTC offset the frontend
+		// sends along with it, which is the offset of the time zone at the time of the
+		// request only.
+		location := time.UTC
+		utcOffsetSec := model.UtcOffsetSec
+		if model.Timezone != "" {
+			location, err = time.LoadLocation(model.Timezone)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timezone %q: %w", model.Timezone, err)
+			}
+			_, offset := query.TimeRange.From.In(location).Zone()
+			utcOffsetSec = int64(offset)
+		}
+
+		var weekStart *time.Weekday
+		if model.WeekStart != "" {
+			day, err := intervalv2.ParseWeekStart(model.WeekStart)
+			if err != nil {
+				return nil, err
+			}
+			weekStart = &day
+		}
+
+		splitInterval := dsInfo.SplitInterval
+		if model.SplitInterval != "" {
+			splitInterval, err = intervalv2.ParseIntervalStringToTimeDuration(model.SplitInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid split interval %q: %w", model.SplitInterval, err)
+			}
+		}
+
+		// A query can lower the limit of the data source, not raise it.
+		maxSeries := dsInfo.MaxSeries
+		if model.MaxSeries > 0 && (maxSeries == 0 || model.MaxSeries < maxSeries) {
+			maxSeries = model.MaxSeries
+		}
+
+		var timeout time.Duration
+		if model.Timeout != "" {
+			timeout, err = intervalv2.ParseIntervalStringToTimeDuration(model.Timeout)
+			if err != nil || timeout <= 0 {
+				return nil, fmt.Errorf("invalid timeout %q", model.Timeout)
+			}
+		}
+
+		if model.SigV4Region != "" && !awsRegionRegex.MatchString(model.SigV4Region) {
+			return nil, fmt.Errorf("invalid SigV4 region %q", model.SigV4Region)
+		}
+
+		customQueryParameters, err := url.ParseQuery(model.CustomQueryParameters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid custom query parameters %q: %w", model.CustomQueryParameters, err)
+		}
+		// The Thanos options take precedence over custom query parameters of the same name.
+		thanosParameters, err := model.Thanos.queryParameters()
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range thanosParameters {
+			customQueryParameters[name] = values
+		}
+
+		scrapeInterval := 15 * time.Second
+		if timeInterval != "" {
+			if parsed, err := intervalv2.ParseIntervalStringToTimeDuration(timeInterval); err == nil {
+				scrapeInterval = parsed
+			}
+		}
+
+		// An unset format behaves like time_series, but is recorded so tooling can find such queries.
+		format := model.Format
+		formatDefaulted := false
+		if format == "" {
+			format = formatTimeSeries
+			formatDefaulted = true
+		}
+
+		end := query.TimeRange.To
+		endClamped, endClampedLocally := false, false
+		if dsInfo.ClampEndToNow {
+			if now := dsInfo.ServerClock.Now(); end.After(now) {
+				end = now
+				endClamped = true
+				endClampedLocally = !dsInfo.ServerClock.Known()
+			}
+		}
+
 		qs = append(qs, &PrometheusQuery{
 			Expr:          expr,
 			Step:          interval,
 			LegendFormat:  model.LegendFormat,
 			Start:         query.TimeRange.From,
-			End:           query.TimeRange.To,
+			End:           end,
 			RefId:         query.RefID,
-			InstantQuery:  model.InstantQuery,
+			InstantQuery:  instantQuery,
 			RangeQuery:    rangeQuery,
 			ExemplarQuery: exemplarQuery,
-			UtcOffsetSec:  model.UtcOffsetSec,
+			UtcOffsetSec:  utcOffsetSec,
+			EndClamped:    endClamped,
+
+			EndClampedLocally: endClampedLocally,
+
+			SkipEmptyExemplarFrame: model.SkipEmptyExemplarFrame,
+			ValueFilter:            valueFilter,
+			LabelOrder:             groupingLabels(expr),
+
+			IncompletePoints:      model.IncompletePoints,
+			IncompletePointsMode:  incompletePointsMode,
+			EpochMsField:          model.EpochMsField,
+			Format:                format,
+			FormatDefaulted:       formatDefaulted,
+			ResultFormat:          resultFormat,
+			BoundaryNaNPolicy:     boundaryNaNPolicy,
+			InteriorNaNPolicy:     interiorNaNPolicy,
+			UniformFieldConfig:    model.UniformFieldConfig,
+			Unit:                  model.Unit,
+			Decimals:              model.Decimals,
+			ExemplarUnit:          model.ExemplarUnit,
+			RateWindow:            rateWindow(expr),
+			MergeInstantTable:     model.MergeInstantTable,
+			SeasonalAnomaly:       model.SeasonalAnomaly,
+			SeasonalOffset:        seasonalOffset,
+			Location:              location,
+			WeekStart:             weekStart,
+			ScrapeInterval:        scrapeInterval,
+			MaxLabelsPerField:     dsInfo.MaxLabelsPerField,
+			MaxSeries:             maxSeries,
+			BucketRangeLegend:     model.BucketRangeLegend || format == formatHeatmap,
+			Downsample:            downsample,
+			DownsampleStep:        downsampleStepDuration,
+			SplitInterval:         splitInterval,
+			SplitConcurrency:      dsInfo.SplitConcurrency,
+			ExemplarChunkInterval: dsInfo.ExemplarChunkInterval,
+			RemoteRead:            dsInfo.RemoteRead,
+			RemoteReadLookback:    dsInfo.RemoteReadLookback,
+			Stats:                 model.Stats,
+			StreamRangeResponse:   dsInfo.StreamRangeResponses,
+			IncrementalCache:      dsInfo.IncrementalCache,
+			IncrementalCacheKey:   incrementalCacheKey(expr, interval, customQueryParameters, queryContext.Headers),
+			CustomQueryParameters: customQueryParameters,
+			Timeout:               timeout,
+			SigV4:                 middleware.SigV4Options{Region: model.SigV4Region},
+			ExactRange:            model.AlignRange != nil && !*model.AlignRange,
+			AlertAnnotations:      alertAnnotations,
+
+			ExemplarTraceIdDestinations: dsInfo.ExemplarTraceIdDestinations,
+			DatasourceUID:               dsInfo.UID,
 		})
 	}
 	return qs, nil
 }
 
+func parseNaNPolicy(policy string) (NaNPolicy, error) {
+	switch p := NaNPolicy(policy); p {
+	case "":
+		return NaNPolicyNull, nil
+	case NaNPolicyNull, NaNPolicyDrop, NaNPolicyConnected, NaNPolicyKeep:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid NaN policy %q", policy)
+}
+
 func parseTimeSeriesResponse(value map[TimeSeriesQueryType]interface{}, query *PrometheusQuery) (data.Frames, error) {
 	var (
 		frames     = data.Frames{}
 		nextFrames = data.Frames{}
 	)
 
-	for _, value := range value {
+	for queryType, value := range value {
+		if queryType == SeasonalBaselineQueryType {
+			continue
+		}
+
 		// Zero out the slice to prevent data corruption.
 		nextFrames = nextFrames[:0]
 
 		switch v := value.(type) {
 		case model.Matrix:
+			if query.AlertAnnotations {
+				nextFrames = append(nextFrames, alertAnnotationFrame(v, query))
+				break
+			}
 			nextFrames = matrixToDataFrames(v, query, nextFrames)
+		case data.Frames:
+			// Range results that were already converted while streaming.
+			nextFrames = append(nextFrames, v...)
 		case model.Vector:
-			nextFrames = vectorToDataFrames(v, query, nextFrames)
+			if query.Format == formatTable {
+				nextFrames = append(nextFrames, vectorToTableFrame(v, query))
+			} else {
+				nextFrames = vectorToDataFrames(v, query, nextFrames)
+			}
 		case *model.Scalar:
 			nextFrames = scalarToDataFrames(v, query, nextFrames)
 		case []apiv1.ExemplarQueryResult:
 			nextFrames = exemplarToDataFrames(v, query, nextFrames)
+		case exemplarChunks:
+			nextFrames = exemplarToDataFrames(mergeExemplarChunks(v), query, nextFrames)
 		default:
 			plog.Error("Query returned unexpected result type", "type", v, "query", query.Expr)
 			continue
@@ -229,10 +744,170 @@ func parseTimeSeriesResponse(value map[TimeSeriesQueryType]interface{}, query *P
 		frames = append(frames, nextFrames...)
 	}
 
+	// The hints about the number of series consider all series Prometheus returned.
+	series := countSeries(frames)
+
+	if baseline, ok := value[SeasonalBaselineQueryType].(model.Matrix); ok {
+		addAnomalyScores(frames, baseline, query)
+	}
+
+	if query.ValueFilter != nil {
+		frames = filterFramesByValue(frames, query.ValueFilter)
+	}
+
+	if query.BucketRangeLegend {
+		applyBucketRangeLegends(frames, query)
+	}
+
+	if query.UniformFieldConfig {
+		applyUniformFieldConfig(frames, query)
+	}
+
+	if query.Format == formatHeatmap {
+		frames = heatmapFrames(frames)
+	}
+
+	// After the heatmap conversion, so that a heatmap counts as one series and
+	// the buckets of a histogram are never cut.
+	if query.MaxSeries > 0 {
+		frames = limitSeries(frames, query.MaxSeries)
+	}
+
+	if query.ResultFormat == ResultFormatLong {
+		frames = longFrames(frames, query)
+	}
+
+	tagDualQueryFrames(frames, query)
+
+	for _, frame := range frames {
+		typ := frameResultType(frame)
+		if typ != "matrix" && typ != "vector" {
+			continue
+		}
+		if query.MaxLabelsPerField > 0 {
+			// Heatmap frames have a value field per bucket.
+			dropped := 0
+			for _, field := range frame.Fields[1:] {
+				if field.Labels != nil {
+					dropped += capLabels(field, query.MaxLabelsPerField)
+				}
+			}
+			if dropped > 0 {
+				setFrameCustomMeta(frame, "droppedLabels", dropped)
+			}
+		}
+		if len(query.LabelOrder) > 0 {
+			setFrameCustomMeta(frame, "labelOrder", query.LabelOrder)
+		}
+		if query.RateWindow != "" {
+			setFrameCustomMeta(frame, "rateWindow", query.RateWindow)
+		}
+		if typ == "matrix" && query.DownsampleStep > 0 {
+			setFrameCustomMeta(frame, "stepMs", query.DownsampleStep.Milliseconds())
+			setFrameCustomMeta(frame, "consolidation", string(query.Downsample))
+		} else if typ == "matrix" && query.Step > 0 {
+			setFrameCustomMeta(frame, "stepMs", query.Step.Milliseconds())
+		}
+	}
+
+	estimatedBytes := estimateFramesSize(frames)
+	for _, frame := range frames {
+		setFrameCustomMeta(frame, "estimatedBytes", estimatedBytes)
+		if query.Format != "" {
+			setFrameCustomMeta(frame, "format", query.Format)
+		}
+		if query.FormatDefaulted {
+			setFrameCustomMeta(frame, "formatDefaulted", true)
+		}
+		if query.RecordingRule != "" {
+			setFrameCustomMeta(frame, "recordingRule", query.RecordingRule)
+		}
+	}
+
+	addQueryHints(frames, query, series)
+
+	if query.EndClamped {
+		server := "Prometheus"
+		if query.EndClampedLocally {
+			server = "Grafana"
+		}
+		for _, frame := range frames {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityInfo,
+				Text:     fmt.Sprintf("The end of the query range was in the future and has been limited to the current time of the %s server.", server),
+			})
+		}
+	}
+
 	return frames, nil
 }
 
-func calculatePrometheusInterval(model *QueryModel, dsInfo *DatasourceInfo, query backend.DataQuery, intervalCalculator intervalv2.Calculator) (time.Duration, error) {
+// futureRangeFrame returns the empty frame of a query whose range starts
+// after the clamped end.
+func futureRangeFrame(query *PrometheusQuery) *data.Frame {
+	frame := data.NewFrame("")
+	frame.RefID = query.RefId
+	frame.AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityInfo,
+		Text:     "The query range is in the future, there is no data to return.",
+	})
+	return frame
+}
+
+// countSeries returns the number of matrix and vector series frames.
+func countSeries(frames data.Frames) int {
+	series := 0
+	for _, frame := range frames {
+		if typ := frameResultType(frame); typ == "matrix" || typ == "vector" {
+			series += frameSeries(frame)
+		}
+	}
+	return series
+}
+
+// countSeriesOfType returns the number of series frames of the given result type.
+func countSeriesOfType(frames data.Frames, resultType string) int {
+	series := 0
+	for _, frame := range frames {
+		if frameResultType(frame) == resultType {
+			series += frameSeries(frame)
+		}
+	}
+	return series
+}
+
+// limitSeries keeps the first max series frames and drops the others, with a
+// warning on the first frame that tells how many series there were. Frames that
+// are not series, like exemplars, are always kept.
+func limitSeries(frames data.Frames, max int) data.Frames {
+	series := countSeries(frames)
+	if series <= max {
+		return frames
+	}
+
+	kept := make(data.Frames, 0, len(frames)-series+max)
+	seen := 0
+	for _, frame := range frames {
+		if typ := frameResultType(frame); typ == "matrix" || typ == "vector" {
+			seen++
+			if seen > max {
+				continue
+			}
+		}
+		kept = append(kept, frame)
+	}
+
+	kept[0].AppendNotices(data.Notice{
+		Severity: data.NoticeSeverityWarning,
+		Text:     fmt.Sprintf("The query returned %d series, only the first %d are shown.", series, max),
+	})
+	setFrameCustomMeta(kept[0], "seriesCount", series)
+	return kept
+}
+
+// calculatePrometheusInterval returns the step of the query. timeInterval is the
+// scrape interval of the query, which is also the default min interval.
+func calculatePrometheusInterval(model *QueryModel, dsInfo *DatasourceInfo, timeInterval string, query backend.DataQuery, intervalCalculator intervalv2.Calculator) (time.Duration, error) {
 	queryInterval := model.Interval
 
 	//If we are using variable for interval/step, we will replace it with calculated interval
@@ -240,7 +915,7 @@ func calculatePrometheusInterval(model *QueryModel, dsInfo *DatasourceInfo, quer
 		queryInterval = ""
 	}
 
-	minInterval, err := intervalv2.GetIntervalFrom(dsInfo.TimeInterval, queryInterval, model.IntervalMS, 15*time.Second)
+	minInterval, err := intervalv2.GetIntervalFrom(timeInterval, queryInterval, model.IntervalMS, 15*time.Second)
 	if err != nil {
 		return time.Duration(0), err
 	}
@@ -252,16 +927,35 @@ func calculatePrometheusInterval(model *QueryModel, dsInfo *DatasourceInfo, quer
 		adjustedInterval = calculatedInterval.Value
 	}
 
+	var interval time.Duration
 	if model.Interval == varRateInterval || model.Interval == varRateIntervalAlt {
 		// Rate interval is final and is not affected by resolution
-		return calculateRateInterval(adjustedInterval, dsInfo.TimeInterval, intervalCalculator), nil
+		interval = calculateRateInterval(adjustedInterval, timeInterval, intervalCalculator)
 	} else {
 		intervalFactor := model.IntervalFactor
 		if intervalFactor == 0 {
 			intervalFactor = 1
 		}
-		return time.Duration(int64(adjustedInterval) * intervalFactor), nil
+		interval = time.Duration(int64(adjustedInterval) * intervalFactor)
 	}
+
+	// The step never goes below the min step of the query nor the min interval of
+	// the data source, even when the query sets its own interval.
+	if model.MinStep != "" {
+		minStep, err := intervalv2.ParseIntervalStringToTimeDuration(model.MinStep)
+		if err != nil {
+			return time.Duration(0), fmt.Errorf("invalid min step %q: %w", model.MinStep, err)
+		}
+		if interval < minStep {
+			interval = minStep
+		}
+	}
+	if dsInfo.TimeInterval != "" {
+		if dsMinInterval, err := intervalv2.ParseIntervalStringToTimeDuration(dsInfo.TimeInterval); err == nil && interval < dsMinInterval {
+			interval = dsMinInterval
+		}
+	}
+	return interval, nil
 }
 
 func calculateRateInterval(interval time.Duration, scrapeInterval string, intervalCalculator intervalv2.Calculator) time.Duration {
@@ -279,9 +973,9 @@ func calculateRateInterval(interval time.Duration, scrapeInterval string, interv
 	return rateInterval
 }
 
-func interpolateVariables(model *QueryModel, interval time.Duration, timeRange time.Duration, intervalCalculator intervalv2.Calculator, timeInterval string) string {
+func interpolateVariables(model *QueryModel, interval time.Duration, timeRange backend.TimeRange, offset time.Duration, intervalCalculator intervalv2.Calculator, timeInterval string) string {
 	expr := model.Expr
-	rangeMs := timeRange.Milliseconds()
+	rangeMs := timeRange.To.Sub(timeRange.From).Milliseconds()
 	rangeSRounded := int64(math.Round(float64(rangeMs) / 1000.0))
 
 	var rateInterval time.Duration
@@ -291,6 +985,24 @@ func interpolateVariables(model *QueryModel, interval time.Duration, timeRange t
 		rateInterval = calculateRateInterval(interval, timeInterval, intervalCalculator)
 	}
 
+	// Before $__interval, which is a prefix of $__interval_x{N}. Unlike
+	// $__interval these are not rounded, so they stay valid range vector and
+	// offset durations.
+	expr = intervalMultipleRegex.ReplaceAllStringFunc(expr, func(match string) string {
+		groups := intervalMultipleRegex.FindStringSubmatch(match)
+		n, err := strconv.ParseInt(groups[1]+groups[2], 10, 64)
+		if err != nil {
+			return match
+		}
+		return formatPromDuration(time.Duration(n) * interval)
+	})
+	expr = strings.ReplaceAll(expr, varOffset, formatPromDuration(offset))
+	expr = strings.ReplaceAll(expr, varOffsetAlt, formatPromDuration(offset))
+	expr = strings.ReplaceAll(expr, varFromTimestamp, strconv.FormatInt(timeRange.From.Unix(), 10))
+	expr = strings.ReplaceAll(expr, varFromTimestampAlt, strconv.FormatInt(timeRange.From.Unix(), 10))
+	expr = strings.ReplaceAll(expr, varToTimestamp, strconv.FormatInt(timeRange.To.Unix(), 10))
+	expr = strings.ReplaceAll(expr, varToTimestampAlt, strconv.FormatInt(timeRange.To.Unix(), 10))
+
 	expr = strings.ReplaceAll(expr, varIntervalMs, strconv.FormatInt(int64(interval/time.Millisecond), 10))
 	expr = strings.ReplaceAll(expr, varInterval, intervalv2.FormatDuration(interval))
 	expr = strings.ReplaceAll(expr, varRangeMs, strconv.FormatInt(rangeMs, 10))
@@ -305,59 +1017,208 @@ func interpolateVariables(model *QueryModel, interval time.Duration, timeRange t
 	expr = strings.ReplaceAll(expr, varRangeSAlt, strconv.FormatInt(rangeSRounded, 10))
 	expr = strings.ReplaceAll(expr, varRangeAlt, strconv.FormatInt(rangeSRounded, 10)+"s")
 	expr = strings.ReplaceAll(expr, varRateIntervalAlt, rateInterval.String())
-	return expr
+	return interpolateTemplateVariables(expr, model.Variables)
 }
 
 func matrixToDataFrames(matrix model.Matrix, query *PrometheusQuery, frames data.Frames) data.Frames {
+	timeRange := queryTimeRange(query)
+	startTimestamp := timeRange.Start.UnixMilli()
+	endTimestamp := timeRange.End.UnixMilli()
+	stepMs := query.Step.Milliseconds()
+	if query.DownsampleStep > 0 {
+		stepMs = query.DownsampleStep.Milliseconds()
+	}
+	// For each step we create 1 data point. This results in range / step + 1 data points.
+	datapointsCount := int((endTimestamp-startTimestamp)/stepMs) + 1
+	// Across DST changes samples are up to the offset change closer or further apart
+	// than a step, which must not be taken for a missing sample.
+	gapTolerance := dstGapTolerance(query, timeRange)
+
+	// All series share the range and step, so the rows are collected in the same
+	// buffers for every series. The fields get copies of the exact length.
+	times := make([]time.Time, 0, datapointsCount)
+	values := make([]*float64, 0, datapointsCount)
+
 	for _, v := range matrix {
 		tags := make(map[string]string, len(v.Metric))
 		for k, v := range v.Metric {
 			tags[string(k)] = string(v)
 		}
 
-		baseTimestamp := alignTimeRange(query.Start, query.Step, query.UtcOffsetSec).UnixMilli()
-		endTimestamp := alignTimeRange(query.End, query.Step, query.UtcOffsetSec).UnixMilli()
-		// For each step we create 1 data point. This results in range / step + 1 data points.
-		datapointsCount := int((endTimestamp-baseTimestamp)/query.Step.Milliseconds()) + 1
+		pairs := v.Values
+		if query.DownsampleStep > 0 {
+			pairs = downsampleSamples(pairs, startTimestamp, stepMs, query.Downsample)
+		}
 
-		timeField := data.NewFieldFromFieldType(data.FieldTypeTime, datapointsCount)
-		valueField := data.NewFieldFromFieldType(data.FieldTypeNullableFloat64, datapointsCount)
-		idx := 0
+		times, values = times[:0], values[:0]
+		baseTimestamp := startTimestamp
+		// The values the rows point to, one allocation per series instead of one per sample.
+		floats := make([]float64, len(pairs))
+		// Rows of NaN samples and the rows of the first and last real value, for the NaN policies.
+		nanRows := []int{}
+		firstValue, lastValue := -1, -1
+		samples := 0
 
-		for _, pair := range v.Values {
+		for i, pair := range pairs {
 			timestamp := int64(pair.Timestamp)
-			value := float64(pair.Value)
+			floats[i] = float64(pair.Value)
 
-			for t := baseTimestamp; t < timestamp; t += query.Step.Milliseconds() {
-				timeField.Set(idx, time.Unix(0, t*1000000).UTC())
-				idx++
+			for t := baseTimestamp; t+gapTolerance < timestamp; t += stepMs {
+				times = append(times, time.UnixMilli(t).UTC())
+				values = append(values, nil)
 			}
 
-			timeField.Set(idx, time.Unix(pair.Timestamp.Unix(), 0).UTC())
-			if !math.IsNaN(value) {
-				valueField.Set(idx, &value)
+			idx := len(times)
+			times = append(times, time.UnixMilli(timestamp).UTC())
+			if !math.IsNaN(floats[i]) {
+				values = append(values, &floats[i])
+				if firstValue == -1 {
+					firstValue = idx
+				}
+				lastValue = idx
+				samples++
+			} else {
+				values = append(values, nil)
+				nanRows = append(nanRows, idx)
 			}
-			baseTimestamp = timestamp + query.Step.Milliseconds()
-			idx++
+			baseTimestamp = timestamp + stepMs
 		}
 
-		for t := baseTimestamp; t <= endTimestamp; t += query.Step.Milliseconds() {
-			timeField.Set(idx, time.Unix(0, t*1000000).UTC())
-			idx++
+		for t := baseTimestamp; t <= endTimestamp; t += stepMs {
+			times = append(times, time.UnixMilli(t).UTC())
+			values = append(values, nil)
 		}
 
+		timeField := data.NewField(data.TimeSeriesTimeFieldName, nil, times)
+		valueField := data.NewField(data.TimeSeriesValueFieldName, nil, values)
+
 		name := formatLegend(v.Metric, query)
-		timeField.Name = data.TimeSeriesTimeFieldName
-		valueField.Name = data.TimeSeriesValueFieldName
 		valueField.Config = &data.FieldConfig{DisplayNameFromDS: name}
 		valueField.Labels = tags
 
-		frames = append(frames, newDataFrame(name, "matrix", timeField, valueField))
+		frame := newDataFrame(name, "matrix", timeField, valueField)
+		if n := query.IncompletePoints; n > 0 {
+			if n > frame.Rows() {
+				n = frame.Rows()
+			}
+			switch query.IncompletePointsMode {
+			case IncompletePointsMark:
+				setFrameCustomMeta(frame, "provisionalPoints", n)
+			default:
+				for i := 0; i < n; i++ {
+					frame.DeleteRow(frame.Rows() - 1)
+				}
+			}
+		}
+		applyNaNPolicies(frame, nanRows, firstValue, lastValue, query)
+		if query.ScrapeInterval > 0 {
+			setFrameCustomMeta(frame, "completeness", completeness(samples, endTimestamp-startTimestamp, query))
+		}
+		if query.EpochMsField {
+			frame.Fields = append(frame.Fields, epochMsField(timeField))
+		}
+		setFrameCustomMeta(frame, "checksum", seriesChecksum(timeField, valueField))
+		setFrameCustomMeta(frame, "fingerprint", v.Metric.Fingerprint().String())
+		frames = append(frames, frame)
 	}
 
 	return frames
 }
 
+// capLabels keeps at most max labels on field and returns how many were dropped.
+// The metric name is always kept, the other labels are kept in order of their names.
+func capLabels(field *data.Field, max int) int {
+	if len(field.Labels) <= max {
+		return 0
+	}
+
+	names := make([]string, 0, len(field.Labels))
+	for name := range field.Labels {
+		if name != model.MetricNameLabel {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	kept := make(data.Labels, max)
+	if name, ok := field.Labels[model.MetricNameLabel]; ok {
+		kept[model.MetricNameLabel] = name
+	}
+	for _, name := range names {
+		if len(kept) == max {
+			break
+		}
+		kept[name] = field.Labels[name]
+	}
+
+	dropped := len(field.Labels) - len(kept)
+	field.Labels = kept
+	return dropped
+}
+
+// completeness returns the ratio of real samples in a series to the number of samples
+// expected over a window of windowMs milliseconds. At most one sample is expected per
+// scrape interval, or per step when the step is longer. The ratio is capped at 1.
+func completeness(samples int, windowMs int64, query *PrometheusQuery) float64 {
+	resolution := query.ScrapeInterval
+	if query.Step > resolution {
+		resolution = query.Step
+	}
+	if query.DownsampleStep > resolution {
+		resolution = query.DownsampleStep
+	}
+	expected := windowMs/resolution.Milliseconds() + 1
+	return math.Min(float64(samples)/float64(expected), 1)
+}
+
+// applyNaNPolicies applies the boundary and interior NaN policies of query to the
+// NaN rows of a series frame. NaN values are already null in the frame.
+func applyNaNPolicies(frame *data.Frame, nanRows []int, firstValue, lastValue int, query *PrometheusQuery) {
+	dropRows := make([]int, 0, len(nanRows))
+	for _, row := range nanRows {
+		if row >= frame.Rows() {
+			// Already removed as an incomplete trailing point.
+			continue
+		}
+
+		policy := query.InteriorNaNPolicy
+		if firstValue == -1 || row < firstValue || row > lastValue {
+			policy = query.BoundaryNaNPolicy
+		}
+
+		switch policy {
+		case NaNPolicyDrop:
+			dropRows = append(dropRows, row)
+		case NaNPolicyConnected:
+			valueField := frame.Fields[1]
+			if valueField.Config == nil {
+				valueField.Config = &data.FieldConfig{}
+			}
+			if valueField.Config.Custom == nil {
+				valueField.Config.Custom = map[string]interface{}{}
+			}
+			valueField.Config.Custom["spanNulls"] = true
+		case NaNPolicyKeep:
+			nan := math.NaN()
+			frame.Fields[1].Set(row, &nan)
+		}
+	}
+
+	// Delete from the end so the remaining row indexes stay valid.
+	for i := len(dropRows) - 1; i >= 0; i-- {
+		frame.DeleteRow(dropRows[i])
+	}
+}
+
+// epochMsField returns a field with the instants of timeField as epoch milliseconds.
+func epochMsField(timeField *data.Field) *data.Field {
+	epochs := make([]int64, timeField.Len())
+	for i := range epochs {
+		epochs[i] = timeField.At(i).(time.Time).UnixMilli()
+	}
+	return data.NewField("epochMs", nil, epochs)
+}
+
 func scalarToDataFrames(scalar *model.Scalar, query *PrometheusQuery, frames data.Frames) data.Frames {
 	timeVector := []time.Time{time.Unix(scalar.Timestamp.Unix(), 0).UTC()}
 	values := []float64{float64(scalar.Value)}
@@ -385,15 +1246,14 @@ func vectorToDataFrames(vector model.Vector, query *PrometheusQuery, frames data
 			tags[string(k)] = string(v)
 		}
 
-		frames = append(
-			frames,
-			newDataFrame(
-				name,
-				"vector",
-				data.NewField("Time", nil, timeVector),
-				data.NewField("Value", tags, values).SetConfig(&data.FieldConfig{DisplayNameFromDS: name}),
-			),
+		frame := newDataFrame(
+			name,
+			"vector",
+			data.NewField("Time", nil, timeVector),
+			data.NewField("Value", tags, values).SetConfig(&data.FieldConfig{DisplayNameFromDS: name}),
 		)
+		setFrameCustomMeta(frame, "fingerprint", v.Metric.Fingerprint().String())
+		frames = append(frames, frame)
 	}
 
 	return frames
@@ -404,9 +1264,15 @@ func exemplarToDataFrames(response []apiv1.ExemplarQueryResult, query *Prometheu
 	// We should figure out a better approximation here.
 	events := make([]ExemplarEvent, 0, len(response)*2)
 
+	seriesNames := make(map[string]bool)
+
 	for _, exemplarData := range response {
+		if name, ok := exemplarData.SeriesLabels[model.MetricNameLabel]; ok && len(exemplarData.Exemplars) > 0 {
+			seriesNames[string(name)] = true
+		}
+		seriesFingerprint := model.Metric(exemplarData.SeriesLabels).Fingerprint().String()
 		for _, exemplar := range exemplarData.Exemplars {
-			event := ExemplarEvent{}
+			event := ExemplarEvent{SeriesFingerprint: seriesFingerprint}
 			exemplarTime := time.Unix(exemplar.Timestamp.Unix(), 0).UTC()
 			event.Time = exemplarTime
 			event.Value = float64(exemplar.Value)
@@ -424,6 +1290,10 @@ func exemplarToDataFrames(response []apiv1.ExemplarQueryResult, query *Prometheu
 		}
 	}
 
+	if len(events) == 0 && query.SkipEmptyExemplarFrame {
+		return frames
+	}
+
 	// Sampling of exemplars
 	bucketedExemplars := make(map[string][]ExemplarEvent)
 	values := make([]float64, 0, len(events))
@@ -492,11 +1362,21 @@ func exemplarToDataFrames(response []apiv1.ExemplarQueryResult, query *Prometheu
 	timeField.Name = "Time"
 	valueField := data.NewFieldFromFieldType(data.FieldTypeFloat64, len(sampleExemplars))
 	valueField.Name = "Value"
+	unit := query.ExemplarUnit
+	if unit == "" {
+		unit = exemplarUnit(seriesNames)
+	}
+	if unit != "" {
+		valueField.Config = &data.FieldConfig{Unit: unit}
+	}
+	fingerprintField := data.NewFieldFromFieldType(data.FieldTypeString, len(sampleExemplars))
+	fingerprintField.Name = "seriesFingerprint"
 	labelsVector := make(map[string][]string, len(sampleExemplars))
 
 	for i, exemplar := range sampleExemplars {
 		timeField.Set(i, exemplar.Time)
 		valueField.Set(i, exemplar.Value)
+		fingerprintField.Set(i, exemplar.SeriesFingerprint)
 
 		for label, value := range exemplar.Labels {
 			if labelsVector[label] == nil {
@@ -507,13 +1387,24 @@ func exemplarToDataFrames(response []apiv1.ExemplarQueryResult, query *Prometheu
 		}
 	}
 
-	dataFields := make([]*data.Field, 0, len(labelsVector)+2)
+	dataFields := make([]*data.Field, 0, len(labelsVector)+3)
 	dataFields = append(dataFields, timeField, valueField)
-	for label, vector := range labelsVector {
-		dataFields = append(dataFields, data.NewField(label, nil, vector))
+	if len(sampleExemplars) > 0 {
+		dataFields = append(dataFields, fingerprintField)
+	}
+	// Sort the label fields, the frames are compared in tests and by the panels.
+	labelNames := make([]string, 0, len(labelsVector))
+	for label := range labelsVector {
+		labelNames = append(labelNames, label)
+	}
+	sort.Strings(labelNames)
+	for _, label := range labelNames {
+		dataFields = append(dataFields, data.NewField(label, nil, labelsVector[label]))
 	}
 
-	return append(frames, newDataFrame("exemplar", "exemplar", dataFields...))
+	frame := newDataFrame("exemplar", "exemplar", dataFields...)
+	applyExemplarTraceLinks(frame, query)
+	return append(frames, frame)
 }
 
 func deviation(values []float64) float64 {
@@ -532,7 +1423,7 @@ func deviation(values []float64) float64 {
 func newDataFrame(name string, typ string, fields ...*data.Field) *data.Frame {
 	frame := data.NewFrame(name, fields...)
 	frame.Meta = &data.FrameMeta{
-		Custom: map[string]string{
+		Custom: map[string]interface{}{
 			"resultType": typ,
 		},
 	}
@@ -540,6 +1431,96 @@ func newDataFrame(name string, typ string, fields ...*data.Field) *data.Frame {
 	return frame
 }
 
+// setFrameCustomMeta adds a key to the custom metadata of a frame created by newDataFrame.
+func setFrameCustomMeta(frame *data.Frame, key string, value interface{}) {
+	if frame.Meta == nil {
+		frame.Meta = &data.FrameMeta{}
+	}
+	custom, ok := frame.Meta.Custom.(map[string]interface{})
+	if !ok {
+		custom = map[string]interface{}{}
+		frame.Meta.Custom = custom
+	}
+	custom[key] = value
+}
+
+// estimateFramesSize returns a rough estimate of the serialized size of frames in bytes,
+// computed as rows × fields × a typical width of each field type. Labels and
+// metadata are not included.
+func estimateFramesSize(frames data.Frames) int64 {
+	var size int64
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			size += int64(field.Len()) * fieldTypeWidth(field.Type())
+		}
+	}
+	return size
+}
+
+func fieldTypeWidth(t data.FieldType) int64 {
+	var width int64
+	switch t.NonNullableType() {
+	case data.FieldTypeBool, data.FieldTypeInt8, data.FieldTypeUint8:
+		width = 1
+	case data.FieldTypeInt16, data.FieldTypeUint16:
+		width = 2
+	case data.FieldTypeInt32, data.FieldTypeUint32, data.FieldTypeFloat32:
+		width = 4
+	case data.FieldTypeString:
+		// Strings are mostly label values, assume a typical length.
+		width = 16
+	default:
+		width = 8
+	}
+	if t.Nullable() {
+		// Count one byte per value for the validity bitmap.
+		width++
+	}
+	return width
+}
+
+// seriesChecksum returns a cheap FNV-1a hash over the samples of a series.
+// Clients can compare it across refreshes to skip re-rendering unchanged data.
+func seriesChecksum(timeField, valueField *data.Field) string {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for i := 0; i < valueField.Len(); i++ {
+		binary.LittleEndian.PutUint64(buf, uint64(timeField.At(i).(time.Time).UnixMilli()))
+		_, _ = h.Write(buf)
+
+		// Every value is prefixed with a presence byte, so nulls never hash like a value.
+		v, ok := valueField.ConcreteAt(i)
+		if !ok {
+			_, _ = h.Write([]byte{0})
+			continue
+		}
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(v.(float64)))
+		_, _ = h.Write([]byte{1})
+		_, _ = h.Write(buf)
+	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// queryTimeRange returns the range the query asks Prometheus for. Unless the
+// query asks for its exact range, start and end are rounded down to a multiple
+// of step, each with the UTC offset of the query at that time, and weekly steps
+// to the week start of the query.
+func queryTimeRange(query *PrometheusQuery) apiv1.Range {
+	if query.ExactRange {
+		return apiv1.Range{Start: query.Start, End: query.End, Step: query.Step}
+	}
+	return apiv1.Range{
+		Start: alignTimeRange(query.Start, query.Step, query.alignOffset(query.Start)),
+		End:   alignTimeRange(query.End, query.Step, query.alignOffset(query.End)),
+		Step:  query.Step,
+	}
+}
+
+// formatPromDuration formats d in the duration syntax of PromQL, e.g. 1h30m.
+func formatPromDuration(d time.Duration) string {
+	return model.Duration(d).String()
+}
+
 func alignTimeRange(t time.Time, step time.Duration, offset int64) time.Time {
 	return time.Unix(int64(math.Floor((float64(t.Unix()+offset)/step.Seconds()))*step.Seconds()-float64(offset)), 0)
 }
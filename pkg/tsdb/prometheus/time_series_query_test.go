@@ -1,12 +1,18 @@
 package prometheus
 
 import (
+	"fmt"
 	"math"
+	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	sdkhttpclient "github.com/grafana/grafana-plugin-sdk-go/backend/httpclient"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/middleware"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	p "github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
@@ -42,6 +48,31 @@ func TestPrometheus_timeSeriesQuery_formatLeged(t *testing.T) {
 		require.Equal(t, `http_request_total{app="backend", device="mobile"}`, formatLegend(metric, query))
 	})
 
+	t.Run("build full series name in label order", func(t *testing.T) {
+		metric := map[p.LabelName]p.LabelValue{
+			p.LabelName(p.MetricNameLabel): p.LabelValue("http_request_total"),
+			p.LabelName("job"):             p.LabelValue("grafana"),
+			p.LabelName("instance"):        p.LabelValue("host:3000"),
+			p.LabelName("device"):          p.LabelValue("mobile"),
+		}
+
+		query := &PrometheusQuery{
+			LegendFormat: "",
+			LabelOrder:   []string{"job", "instance"},
+		}
+
+		require.Equal(t, `http_request_total{job="grafana", instance="host:3000", device="mobile"}`, formatLegend(metric, query))
+	})
+
+	t.Run("build series name without braces when only the metric name is present", func(t *testing.T) {
+		metric := map[p.LabelName]p.LabelValue{
+			p.LabelName(p.MetricNameLabel): p.LabelValue("http_request_total"),
+		}
+
+		require.Equal(t, "http_request_total", formatLegend(metric, &PrometheusQuery{}))
+		require.Equal(t, "http_request_total", formatLegend(metric, &PrometheusQuery{LabelOrder: []string{"job"}}))
+	})
+
 	t.Run("use query expr when no labels", func(t *testing.T) {
 		metric := map[p.LabelName]p.LabelValue{}
 
@@ -186,6 +217,61 @@ func TestPrometheus_timeSeriesQuery_parseTimeSeriesQuery(t *testing.T) {
 		require.Equal(t, time.Minute*4, models[0].Step)
 	})
 
+	t.Run("parsing query model with min step", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"minStep": "5m",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, time.Minute*5, models[0].Step)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"minStep": "30s",
+			"refId": "A"
+		}`, timeRange)
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, time.Minute*2, models[0].Step)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"minStep": "often",
+			"refId": "A"
+		}`, timeRange)
+		_, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with an interval below the min interval of the data source", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"interval": "10s",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{
+			TimeInterval: "1m",
+		}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, time.Minute, models[0].Step)
+	})
+
 	t.Run("parsing query model with $__interval variable", func(t *testing.T) {
 		timeRange := backend.TimeRange{
 			From: now,
@@ -205,6 +291,83 @@ func TestPrometheus_timeSeriesQuery_parseTimeSeriesQuery(t *testing.T) {
 		require.Equal(t, "rate(ALERTS{job=\"test\" [2m]})", models[0].Expr)
 	})
 
+	t.Run("parsing query model with $__interval_x{N} variables", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "rate(ALERTS{job=\"test\" [$__interval_x3]}) / rate(ALERTS{job=\"test\" [${__interval_x10}]}) offset $__interval",
+			"format": "time_series",
+			"intervalFactor": 1,
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, "rate(ALERTS{job=\"test\" [6m]}) / rate(ALERTS{job=\"test\" [20m]}) offset 2m", models[0].Expr)
+	})
+
+	t.Run("parsing query model with $__offset variable", func(t *testing.T) {
+		now := time.Date(2022, 1, 10, 12, 0, 0, 500000000, time.UTC)
+		calls := 0
+		timeNow = func() time.Time {
+			calls++
+			return now.Add(time.Duration(calls) * time.Second)
+		}
+		t.Cleanup(func() { timeNow = time.Now })
+
+		to := now.Add(-90 * time.Minute)
+		timeRange := backend.TimeRange{
+			From: to.Add(-1 * time.Hour),
+			To:   to,
+		}
+
+		query := queryContext(`{
+			"expr": "rate(up[5m] offset $__offset) - rate(up[5m] offset ${__offset})",
+			"refId": "A"
+		}`, timeRange)
+		query.Queries = append(query.Queries, backend.DataQuery{
+			JSON:      []byte(`{"expr": "rate(up[5m] offset $__offset)", "refId": "B"}`),
+			TimeRange: timeRange,
+			RefID:     "B",
+		})
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, 1, calls)
+		require.Equal(t, "rate(up[5m] offset 1h30m1s) - rate(up[5m] offset 1h30m1s)", models[0].Expr)
+		require.Equal(t, "rate(up[5m] offset 1h30m1s)", models[1].Expr)
+
+		query = queryContext(`{
+			"expr": "rate(up[5m] offset $__offset)",
+			"refId": "A"
+		}`, backend.TimeRange{From: time.Now().Add(-1 * time.Hour), To: time.Now().Add(1 * time.Hour)})
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, "rate(up[5m] offset 0s)", models[0].Expr)
+	})
+
+	t.Run("parsing query model with $__from_timestamp and $__to_timestamp variables", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: time.Unix(1641889530, 0),
+			To:   time.Unix(1641893130, 0),
+		}
+
+		query := queryContext(`{
+			"expr": "up @ $__to_timestamp - up @ ${__from_timestamp}",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, "up @ 1641893130 - up @ 1641889530", models[0].Expr)
+	})
+
 	t.Run("parsing query model with ${__interval} variable", func(t *testing.T) {
 		timeRange := backend.TimeRange{
 			From: now,
@@ -434,6 +597,36 @@ func TestPrometheus_timeSeriesQuery_parseTimeSeriesQuery(t *testing.T) {
 		require.Equal(t, "rate(ALERTS{job=\"test\" [5m15s]})", models[0].Expr)
 	})
 
+	t.Run("parsing query model with $__rate_interval variable and a scrape interval of the query", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "rate(http_requests_total[$__rate_interval])",
+			"scrapeInterval": "5m",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{
+			TimeInterval: "15s",
+		}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, "rate(http_requests_total[20m0s])", models[0].Expr)
+		require.Equal(t, 5*time.Minute, models[0].Step)
+		require.Equal(t, 5*time.Minute, models[0].ScrapeInterval)
+
+		query = queryContext(`{
+			"expr": "rate(http_requests_total[$__rate_interval])",
+			"scrapeInterval": "every now and then",
+			"refId": "A"
+		}`, timeRange)
+		_, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.Error(t, err)
+	})
+
 	t.Run("parsing query model with $__rate_interval variable in expr and interval", func(t *testing.T) {
 		timeRange := backend.TimeRange{
 			From: now,
@@ -497,7 +690,7 @@ func TestPrometheus_timeSeriesQuery_parseTimeSeriesQuery(t *testing.T) {
 		require.Equal(t, true, models[0].InstantQuery)
 	})
 
-	t.Run("parsing query model of with no query type", func(t *testing.T) {
+	t.Run("parsing query model with calculator min interval in the data source", func(t *testing.T) {
 		timeRange := backend.TimeRange{
 			From: now,
 			To:   now.Add(48 * time.Hour),
@@ -506,118 +699,719 @@ func TestPrometheus_timeSeriesQuery_parseTimeSeriesQuery(t *testing.T) {
 		query := queryContext(`{
 			"expr": "go_goroutines",
 			"format": "time_series",
-			"intervalFactor": 1,
+			"intervalMs": 1,
 			"refId": "A"
 		}`, timeRange)
 
 		dsInfo := &DatasourceInfo{}
 		models, err := service.parseTimeSeriesQuery(query, dsInfo)
 		require.NoError(t, err)
-		require.Equal(t, true, models[0].RangeQuery)
+		require.Equal(t, time.Minute*2, models[0].Step)
+
+		dsInfo = &DatasourceInfo{CalculatorMinInterval: 5 * time.Minute}
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, time.Minute*5, models[0].Step)
 	})
-}
 
-func TestPrometheus_parseTimeSeriesResponse(t *testing.T) {
-	t.Run("exemplars response should be sampled and parsed normally", func(t *testing.T) {
-		value := make(map[TimeSeriesQueryType]interface{})
-		exemplars := []apiv1.ExemplarQueryResult{
-			{
-				SeriesLabels: p.LabelSet{
-					"__name__": "tns_request_duration_seconds_bucket",
-					"instance": "app:80",
-					"job":      "tns/app",
-				},
-				Exemplars: []apiv1.Exemplar{
-					{
-						Labels:    p.LabelSet{"traceID": "test1"},
-						Value:     0.003535405,
-						Timestamp: p.TimeFromUnixNano(time.Now().Add(-2 * time.Minute).UnixNano()),
-					},
-					{
-						Labels:    p.LabelSet{"traceID": "test2"},
-						Value:     0.005555605,
-						Timestamp: p.TimeFromUnixNano(time.Now().Add(-4 * time.Minute).UnixNano()),
-					},
-					{
-						Labels:    p.LabelSet{"traceID": "test3"},
-						Value:     0.007545445,
-						Timestamp: p.TimeFromUnixNano(time.Now().Add(-6 * time.Minute).UnixNano()),
-					},
-					{
-						Labels:    p.LabelSet{"traceID": "test4"},
-						Value:     0.009545445,
-						Timestamp: p.TimeFromUnixNano(time.Now().Add(-7 * time.Minute).UnixNano()),
-					},
-				},
-			},
+	t.Run("parsing query model with by clause", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
 		}
 
-		value[ExemplarQueryType] = exemplars
-		query := &PrometheusQuery{
-			LegendFormat: "legend {{app}}",
-		}
-		res, err := parseTimeSeriesResponse(value, query)
+		query := queryContext(`{
+			"expr": "sum by (job, instance) (rate(http_requests_total[5m]))",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
 		require.NoError(t, err)
+		require.Equal(t, []string{"job", "instance"}, models[0].LabelOrder)
+	})
 
-		// Test fields
-		require.Len(t, res, 1)
-		require.Equal(t, res[0].Name, "exemplar")
-		require.Equal(t, res[0].Fields[0].Name, "Time")
-		require.Equal(t, res[0].Fields[1].Name, "Value")
-		require.Len(t, res[0].Fields, 6)
+	t.Run("parsing query model without by clause", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
 
-		// Test correct values (sampled to 2)
-		require.Equal(t, res[0].Fields[1].Len(), 2)
-		require.Equal(t, res[0].Fields[1].At(0), 0.009545445)
-		require.Equal(t, res[0].Fields[1].At(1), 0.003535405)
+		query := queryContext(`{
+			"expr": "sum without (job) (rate(http_requests_total[5m]))",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Nil(t, models[0].LabelOrder)
 	})
 
-	t.Run("matrix response should be parsed normally", func(t *testing.T) {
-		values := []p.SamplePair{
-			{Value: 1, Timestamp: 1000},
-			{Value: 2, Timestamp: 2000},
-			{Value: 3, Timestamp: 3000},
-			{Value: 4, Timestamp: 4000},
-			{Value: 5, Timestamp: 5000},
+	t.Run("parsing query model with and without format", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
 		}
-		value := make(map[TimeSeriesQueryType]interface{})
-		value[RangeQueryType] = p.Matrix{
-			&p.SampleStream{
-				Metric: p.Metric{"app": "Application", "tag2": "tag2"},
-				Values: values,
+
+		withFormat := queryContext(`{
+			"expr": "go_goroutines",
+			"format": "time_series",
+			"refId": "A"
+		}`, timeRange)
+		withoutFormat := queryContext(`{
+			"expr": "go_goroutines",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		set, err := service.parseTimeSeriesQuery(withFormat, dsInfo)
+		require.NoError(t, err)
+		unset, err := service.parseTimeSeriesQuery(withoutFormat, dsInfo)
+		require.NoError(t, err)
+
+		require.Equal(t, "time_series", set[0].Format)
+		require.False(t, set[0].FormatDefaulted)
+		require.Equal(t, "time_series", unset[0].Format)
+		require.True(t, unset[0].FormatDefaulted)
+
+		value := map[TimeSeriesQueryType]interface{}{
+			RangeQueryType: p.Matrix{
+				&p.SampleStream{
+					Metric: p.Metric{"app": "Application"},
+					Values: []p.SamplePair{{Value: 1, Timestamp: p.TimeFromUnix(now.Unix())}},
+				},
 			},
 		}
-		query := &PrometheusQuery{
-			LegendFormat: "legend {{app}}",
-			Step:         1 * time.Second,
-			Start:        time.Unix(1, 0).UTC(),
-			End:          time.Unix(5, 0).UTC(),
-			UtcOffsetSec: 0,
-		}
-		res, err := parseTimeSeriesResponse(value, query)
+		setFrames, err := parseTimeSeriesResponse(value, set[0])
+		require.NoError(t, err)
+		unsetFrames, err := parseTimeSeriesResponse(value, unset[0])
 		require.NoError(t, err)
 
-		require.Len(t, res, 1)
-		require.Equal(t, res[0].Name, "legend Application")
-		require.Len(t, res[0].Fields, 2)
-		require.Len(t, res[0].Fields[0].Labels, 0)
-		require.Equal(t, res[0].Fields[0].Name, "Time")
-		require.Len(t, res[0].Fields[1].Labels, 2)
-		require.Equal(t, res[0].Fields[1].Labels.String(), "app=Application, tag2=tag2")
-		require.Equal(t, res[0].Fields[1].Name, "Value")
-		require.Equal(t, res[0].Fields[1].Config.DisplayNameFromDS, "legend Application")
-
-		// Ensure the timestamps are UTC zoned
-		testValue := res[0].Fields[0].At(0)
-		require.Equal(t, "UTC", testValue.(time.Time).Location().String())
+		require.Len(t, unsetFrames, len(setFrames))
+		require.Equal(t, setFrames[0].Fields, unsetFrames[0].Fields)
+		require.Nil(t, setFrames[0].Meta.Custom.(map[string]interface{})["formatDefaulted"])
+		require.Equal(t, true, unsetFrames[0].Meta.Custom.(map[string]interface{})["formatDefaulted"])
 	})
 
-	t.Run("matrix response with missed data points should be parsed correctly", func(t *testing.T) {
-		values := []p.SamplePair{
-			{Value: 1, Timestamp: 1000},
-			{Value: 4, Timestamp: 4000},
+	t.Run("parsing query model with NaN policies", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
 		}
-		value := make(map[TimeSeriesQueryType]interface{})
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"boundaryNaNPolicy": "drop",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, NaNPolicyDrop, models[0].BoundaryNaNPolicy)
+		require.Equal(t, NaNPolicyNull, models[0].InteriorNaNPolicy)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"interiorNaNPolicy": "interpolate",
+			"refId": "A"
+		}`, timeRange)
+		_, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.Error(t, err)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"nanPolicy": "keep",
+			"boundaryNaNPolicy": "drop",
+			"refId": "A"
+		}`, timeRange)
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, NaNPolicyDrop, models[0].BoundaryNaNPolicy)
+		require.Equal(t, NaNPolicyKeep, models[0].InteriorNaNPolicy)
+	})
+
+	t.Run("parsing query model with rate", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "sum(rate(http_requests_total[5m]))",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, "5m", models[0].RateWindow)
+
+		value := map[TimeSeriesQueryType]interface{}{
+			InstantQueryType: p.Vector{&p.Sample{Metric: p.Metric{}, Value: 1, Timestamp: 1000}},
+		}
+		res, err := parseTimeSeriesResponse(value, models[0])
+		require.NoError(t, err)
+		require.Equal(t, "5m", res[0].Meta.Custom.(map[string]interface{})["rateWindow"])
+	})
+
+	t.Run("parsing query model with timezone aligns days to local midnight", func(t *testing.T) {
+		newYork, err := time.LoadLocation("America/New_York")
+		require.NoError(t, err)
+
+		timeRange := backend.TimeRange{
+			From: time.Date(2022, 1, 10, 15, 0, 0, 0, newYork),
+			To:   time.Date(2022, 1, 20, 15, 0, 0, 0, newYork),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"interval": "1d",
+			"timezone": "America/New_York",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, 24*time.Hour, models[0].Step)
+		require.Equal(t, int64(-5*60*60), models[0].UtcOffsetSec)
+		require.Equal(t, newYork, models[0].Location)
+
+		start := alignTimeRange(models[0].Start, models[0].Step, models[0].UtcOffsetSec)
+		require.True(t, time.Date(2022, 1, 10, 0, 0, 0, 0, newYork).Equal(start))
+
+		// The frontend sends the UTC offset of the time zone at the time of
+		// the request along with it.
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"interval": "1d",
+			"timezone": "America/New_York",
+			"utcOffsetSec": -14400,
+			"refId": "A"
+		}`, timeRange)
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, int64(-5*60*60), models[0].UtcOffsetSec)
+		require.Equal(t, newYork, models[0].Location)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"timezone": "Nowhere/Special",
+			"refId": "A"
+		}`, timeRange)
+		_, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with value filter", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"valueFilter": ">= 100",
+			"valueFilterAggregation": "max",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, &ValueFilter{Operator: ">=", Threshold: 100, Aggregation: ValueFilterMax}, models[0].ValueFilter)
+	})
+
+	t.Run("parsing query model with invalid value filter", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"valueFilter": "100",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		_, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with custom query parameters", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"customQueryParameters": "max_source_resolution=5m&dedup=false",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, url.Values{"max_source_resolution": []string{"5m"}, "dedup": []string{"false"}}, models[0].CustomQueryParameters)
+	})
+
+	t.Run("parsing query model with invalid custom query parameters", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"customQueryParameters": "timeout=%zz",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		_, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.Error(t, err)
+	})
+
+	t.Run("parsing query model with range alignment disabled", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: time.Unix(1000, 500*int64(time.Millisecond)),
+			To:   time.Unix(4000, 250*int64(time.Millisecond)),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"interval": "1m",
+			"alignRange": false,
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.True(t, models[0].ExactRange)
+
+		r := queryTimeRange(models[0])
+		require.Equal(t, timeRange.From, r.Start)
+		require.Equal(t, timeRange.To, r.End)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"interval": "1m",
+			"refId": "A"
+		}`, timeRange)
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.False(t, models[0].ExactRange)
+
+		r = queryTimeRange(models[0])
+		require.Equal(t, time.Unix(960, 0), r.Start)
+		require.Equal(t, time.Unix(3960, 0), r.End)
+	})
+
+	t.Run("parsing query model with max series", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		dsInfo := &DatasourceInfo{MaxSeries: 1000}
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"refId": "A"
+		}`, timeRange)
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, 1000, models[0].MaxSeries)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"maxSeries": 50,
+			"refId": "A"
+		}`, timeRange)
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, 50, models[0].MaxSeries)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"maxSeries": 5000,
+			"refId": "A"
+		}`, timeRange)
+		models, err = service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, 1000, models[0].MaxSeries)
+
+		models, err = service.parseTimeSeriesQuery(query, &DatasourceInfo{})
+		require.NoError(t, err)
+		require.Equal(t, 5000, models[0].MaxSeries)
+	})
+
+	t.Run("parsing query model with a timeout", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"refId": "A"
+		}`, timeRange)
+		models, err := service.parseTimeSeriesQuery(query, &DatasourceInfo{})
+		require.NoError(t, err)
+		require.Zero(t, models[0].Timeout)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"timeout": "2m",
+			"refId": "A"
+		}`, timeRange)
+		models, err = service.parseTimeSeriesQuery(query, &DatasourceInfo{})
+		require.NoError(t, err)
+		require.Equal(t, 2*time.Minute, models[0].Timeout)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"timeout": "soon",
+			"refId": "A"
+		}`, timeRange)
+		_, err = service.parseTimeSeriesQuery(query, &DatasourceInfo{})
+		require.EqualError(t, err, `invalid timeout "soon"`)
+	})
+
+	t.Run("parsing query model with SigV4 options", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"sigV4Region": "eu-west-1",
+			"sigV4ExternalId": "tenant-a",
+			"refId": "A"
+		}`, timeRange)
+		models, err := service.parseTimeSeriesQuery(query, &DatasourceInfo{})
+		require.NoError(t, err)
+		// The external ID of the assumed role is only taken from the data source.
+		require.Equal(t, middleware.SigV4Options{Region: "eu-west-1"}, models[0].SigV4)
+
+		query = queryContext(`{
+			"expr": "go_goroutines",
+			"sigV4Region": "europe",
+			"refId": "A"
+		}`, timeRange)
+		_, err = service.parseTimeSeriesQuery(query, &DatasourceInfo{})
+		require.EqualError(t, err, `invalid SigV4 region "europe"`)
+	})
+
+	t.Run("parsing query model with a future end clamps it to now", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: time.Now().Add(-1 * time.Hour),
+			To:   time.Now().Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"format": "time_series",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{ClampEndToNow: true}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.True(t, models[0].EndClamped)
+		require.False(t, models[0].End.After(time.Now()))
+		require.True(t, models[0].End.Before(timeRange.To))
+	})
+
+	t.Run("parsing query model with a future end clamps it to the time of Prometheus", func(t *testing.T) {
+		// Prometheus is 30 minutes behind the Grafana server.
+		clock := middleware.NewServerClock()
+		rt := middleware.ServerTime(clock).CreateMiddleware(sdkhttpclient.Options{}, sdkhttpclient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Date", time.Now().Add(-30*time.Minute).Format(http.TimeFormat))
+			return &http.Response{StatusCode: http.StatusOK, Header: header}, nil
+		}))
+		req, err := http.NewRequest(http.MethodGet, "http://prometheus/api/v1/query", nil)
+		require.NoError(t, err)
+		_, err = rt.RoundTrip(req)
+		require.NoError(t, err)
+
+		timeRange := backend.TimeRange{
+			From: time.Now().Add(-1 * time.Hour),
+			To:   time.Now().Add(-10 * time.Minute),
+		}
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"format": "time_series",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{ClampEndToNow: true, ServerClock: clock}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.True(t, models[0].EndClamped)
+		require.False(t, models[0].EndClampedLocally)
+		require.WithinDuration(t, time.Now().Add(-30*time.Minute), models[0].End, 2*time.Second)
+	})
+
+	t.Run("parsing query model with a future end keeps it when clamping is disabled", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: time.Now().Add(-1 * time.Hour),
+			To:   time.Now().Add(1 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"format": "time_series",
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.False(t, models[0].EndClamped)
+		require.Equal(t, timeRange.To, models[0].End)
+	})
+
+	t.Run("parsing query model of with no query type", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: now,
+			To:   now.Add(48 * time.Hour),
+		}
+
+		query := queryContext(`{
+			"expr": "go_goroutines",
+			"format": "time_series",
+			"intervalFactor": 1,
+			"refId": "A"
+		}`, timeRange)
+
+		dsInfo := &DatasourceInfo{}
+		models, err := service.parseTimeSeriesQuery(query, dsInfo)
+		require.NoError(t, err)
+		require.Equal(t, true, models[0].RangeQuery)
+	})
+}
+
+func TestPrometheus_parseTimeSeriesResponse(t *testing.T) {
+	t.Run("exemplars response should be sampled and parsed normally", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		exemplars := []apiv1.ExemplarQueryResult{
+			{
+				SeriesLabels: p.LabelSet{
+					"__name__": "tns_request_duration_seconds_bucket",
+					"instance": "app:80",
+					"job":      "tns/app",
+				},
+				Exemplars: []apiv1.Exemplar{
+					{
+						Labels:    p.LabelSet{"traceID": "test1"},
+						Value:     0.003535405,
+						Timestamp: p.TimeFromUnixNano(time.Now().Add(-2 * time.Minute).UnixNano()),
+					},
+					{
+						Labels:    p.LabelSet{"traceID": "test2"},
+						Value:     0.005555605,
+						Timestamp: p.TimeFromUnixNano(time.Now().Add(-4 * time.Minute).UnixNano()),
+					},
+					{
+						Labels:    p.LabelSet{"traceID": "test3"},
+						Value:     0.007545445,
+						Timestamp: p.TimeFromUnixNano(time.Now().Add(-6 * time.Minute).UnixNano()),
+					},
+					{
+						Labels:    p.LabelSet{"traceID": "test4"},
+						Value:     0.009545445,
+						Timestamp: p.TimeFromUnixNano(time.Now().Add(-7 * time.Minute).UnixNano()),
+					},
+				},
+			},
+		}
+
+		value[ExemplarQueryType] = exemplars
+		query := &PrometheusQuery{
+			LegendFormat: "legend {{app}}",
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		// Test fields
+		require.Len(t, res, 1)
+		require.Equal(t, res[0].Name, "exemplar")
+		require.Equal(t, res[0].Fields[0].Name, "Time")
+		require.Equal(t, res[0].Fields[1].Name, "Value")
+		require.Equal(t, res[0].Fields[2].Name, "seriesFingerprint")
+		require.Len(t, res[0].Fields, 7)
+
+		// Test correct values (sampled to 2)
+		require.Equal(t, res[0].Fields[1].Len(), 2)
+		require.Equal(t, res[0].Fields[1].At(0), 0.009545445)
+		require.Equal(t, res[0].Fields[1].At(1), 0.003535405)
+	})
+
+	t.Run("exemplars should carry the fingerprint of their series frame", func(t *testing.T) {
+		seriesLabels := p.LabelSet{
+			"__name__": "tns_request_duration_seconds_bucket",
+			"instance": "app:80",
+		}
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric(seriesLabels),
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}},
+			},
+			&p.SampleStream{
+				Metric: p.Metric{"__name__": "tns_request_duration_seconds_bucket", "instance": "app:81"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}},
+			},
+		}
+		value[ExemplarQueryType] = []apiv1.ExemplarQueryResult{
+			{
+				SeriesLabels: seriesLabels,
+				Exemplars: []apiv1.Exemplar{
+					{Labels: p.LabelSet{"traceID": "test1"}, Value: 0.5, Timestamp: 1000},
+				},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:  1 * time.Second,
+			Start: time.Unix(1, 0).UTC(),
+			End:   time.Unix(1, 0).UTC(),
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 3)
+
+		fingerprints := map[string]string{}
+		var exemplarFingerprint interface{}
+		for _, frame := range res {
+			custom := frame.Meta.Custom.(map[string]interface{})
+			if custom["resultType"] == "exemplar" {
+				exemplarFingerprint = frame.Fields[2].At(0)
+				continue
+			}
+			fingerprints[frame.Fields[1].Labels["instance"]] = custom["fingerprint"].(string)
+		}
+		require.Equal(t, fingerprints["app:80"], exemplarFingerprint)
+		require.NotEqual(t, fingerprints["app:81"], exemplarFingerprint)
+	})
+
+	t.Run("exemplar value field should get the unit of its metric", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[ExemplarQueryType] = []apiv1.ExemplarQueryResult{
+			{
+				SeriesLabels: p.LabelSet{"__name__": "tns_request_duration_seconds_bucket", "le": "0.5"},
+				Exemplars: []apiv1.Exemplar{
+					{Labels: p.LabelSet{"traceID": "test1"}, Value: 0.3, Timestamp: 1000},
+				},
+			},
+			{
+				SeriesLabels: p.LabelSet{"__name__": "tns_request_duration_seconds_bucket", "le": "1"},
+				Exemplars: []apiv1.Exemplar{
+					{Labels: p.LabelSet{"traceID": "test2"}, Value: 0.7, Timestamp: 5000},
+				},
+			},
+		}
+		query := &PrometheusQuery{
+			Step: 1 * time.Second,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Equal(t, "Value", res[0].Fields[1].Name)
+		require.Equal(t, "s", res[0].Fields[1].Config.Unit)
+
+		query.ExemplarUnit = "ms"
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Equal(t, "ms", res[0].Fields[1].Config.Unit)
+	})
+
+	t.Run("empty exemplars response should produce an empty frame", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[ExemplarQueryType] = []apiv1.ExemplarQueryResult{}
+
+		query := &PrometheusQuery{
+			Step: 1 * time.Second,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Equal(t, "exemplar", res[0].Name)
+		require.Len(t, res[0].Fields, 2)
+		require.Equal(t, "Time", res[0].Fields[0].Name)
+		require.Equal(t, data.FieldTypeTime, res[0].Fields[0].Type())
+		require.Equal(t, "Value", res[0].Fields[1].Name)
+		require.Equal(t, data.FieldTypeFloat64, res[0].Fields[1].Type())
+		require.Equal(t, 0, res[0].Fields[0].Len())
+	})
+
+	t.Run("empty exemplars response should not produce a frame when skipped", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[ExemplarQueryType] = []apiv1.ExemplarQueryResult{}
+
+		query := &PrometheusQuery{
+			Step:                   1 * time.Second,
+			SkipEmptyExemplarFrame: true,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 0)
+	})
+
+	t.Run("matrix response should be parsed normally", func(t *testing.T) {
+		values := []p.SamplePair{
+			{Value: 1, Timestamp: 1000},
+			{Value: 2, Timestamp: 2000},
+			{Value: 3, Timestamp: 3000},
+			{Value: 4, Timestamp: 4000},
+			{Value: 5, Timestamp: 5000},
+		}
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application", "tag2": "tag2"},
+				Values: values,
+			},
+		}
+		query := &PrometheusQuery{
+			LegendFormat: "legend {{app}}",
+			Step:         1 * time.Second,
+			Start:        time.Unix(1, 0).UTC(),
+			End:          time.Unix(5, 0).UTC(),
+			UtcOffsetSec: 0,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Equal(t, res[0].Name, "legend Application")
+		require.Len(t, res[0].Fields, 2)
+		require.Len(t, res[0].Fields[0].Labels, 0)
+		require.Equal(t, res[0].Fields[0].Name, "Time")
+		require.Len(t, res[0].Fields[1].Labels, 2)
+		require.Equal(t, res[0].Fields[1].Labels.String(), "app=Application, tag2=tag2")
+		require.Equal(t, res[0].Fields[1].Name, "Value")
+		require.Equal(t, res[0].Fields[1].Config.DisplayNameFromDS, "legend Application")
+
+		// Ensure the timestamps are UTC zoned
+		testValue := res[0].Fields[0].At(0)
+		require.Equal(t, "UTC", testValue.(time.Time).Location().String())
+	})
+
+	t.Run("matrix response with missed data points should be parsed correctly", func(t *testing.T) {
+		values := []p.SamplePair{
+			{Value: 1, Timestamp: 1000},
+			{Value: 4, Timestamp: 4000},
+		}
+		value := make(map[TimeSeriesQueryType]interface{})
 		value[RangeQueryType] = p.Matrix{
 			&p.SampleStream{
 				Metric: p.Metric{"app": "Application", "tag2": "tag2"},
@@ -643,6 +1437,213 @@ func TestPrometheus_parseTimeSeriesResponse(t *testing.T) {
 		require.Nil(t, res[0].Fields[1].At(2))
 	})
 
+	t.Run("matrix response should be truncated to the max series", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		matrix := p.Matrix{}
+		for _, app := range []string{"a", "b", "c", "d"} {
+			matrix = append(matrix, &p.SampleStream{
+				Metric: p.Metric{"app": p.LabelValue(app)},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}},
+			})
+		}
+		value[RangeQueryType] = matrix
+		query := &PrometheusQuery{
+			Step:      time.Second,
+			Start:     time.Unix(1, 0).UTC(),
+			End:       time.Unix(1, 0).UTC(),
+			MaxSeries: 2,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+		require.Equal(t, "a", res[0].Fields[1].Labels["app"])
+		require.Equal(t, "b", res[1].Fields[1].Labels["app"])
+		require.Len(t, res[0].Meta.Notices, 1)
+		require.Equal(t, data.NoticeSeverityWarning, res[0].Meta.Notices[0].Severity)
+		require.Equal(t, 4, res[0].Meta.Custom.(map[string]interface{})["seriesCount"])
+		require.Empty(t, res[1].Meta.Notices)
+
+		query.MaxSeries = 4
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 4)
+		require.Empty(t, res[0].Meta.Notices)
+	})
+
+	t.Run("heatmap response should be truncated by histogram", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		matrix := p.Matrix{}
+		for _, app := range []string{"a", "b"} {
+			for _, le := range []string{"0.1", "1", "+Inf"} {
+				matrix = append(matrix, &p.SampleStream{
+					Metric: p.Metric{"app": p.LabelValue(app), "le": p.LabelValue(le)},
+					Values: []p.SamplePair{{Value: 1, Timestamp: 1000}},
+				})
+			}
+		}
+		value[RangeQueryType] = matrix
+		query := &PrometheusQuery{
+			Step:      time.Second,
+			Start:     time.Unix(1, 0).UTC(),
+			End:       time.Unix(1, 0).UTC(),
+			Format:    formatHeatmap,
+			MaxSeries: 2,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+		require.Len(t, res[0].Fields, 4)
+		require.Empty(t, res[0].Meta.Notices)
+
+		query.MaxSeries = 1
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Len(t, res[0].Fields, 4)
+		require.Len(t, res[0].Meta.Notices, 1)
+	})
+
+	t.Run("matrix response should carry the effective step", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 60000}},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:  time.Minute,
+			Start: time.Unix(60, 0).UTC(),
+			End:   time.Unix(60, 0).UTC(),
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, int64(60000), res[0].Meta.Custom.(map[string]interface{})["stepMs"])
+	})
+
+	t.Run("matrix response of an exact range should keep unaligned timestamps", func(t *testing.T) {
+		values := []p.SamplePair{
+			{Value: 1, Timestamp: 1500},
+			{Value: 4, Timestamp: 4500},
+		}
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application"},
+				Values: values,
+			},
+		}
+		query := &PrometheusQuery{
+			Step:       1 * time.Second,
+			Start:      time.Unix(1, 500*int64(time.Millisecond)).UTC(),
+			End:        time.Unix(4, 500*int64(time.Millisecond)).UTC(),
+			ExactRange: true,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, 4, res[0].Fields[0].Len())
+		require.Equal(t, time.Unix(1, 500*int64(time.Millisecond)).UTC(), res[0].Fields[0].At(0))
+		require.Equal(t, time.Unix(2, 500*int64(time.Millisecond)).UTC(), res[0].Fields[0].At(1))
+		require.Nil(t, res[0].Fields[1].At(1))
+		require.Equal(t, 4.0, *res[0].Fields[1].At(3).(*float64))
+	})
+
+	t.Run("matrix response should drop incomplete trailing points", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application"},
+				Values: []p.SamplePair{
+					{Value: 10, Timestamp: 1000},
+					{Value: 10, Timestamp: 2000},
+					{Value: 2, Timestamp: 3000},
+				},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:                 1 * time.Second,
+			Start:                time.Unix(1, 0).UTC(),
+			End:                  time.Unix(3, 0).UTC(),
+			IncompletePoints:     1,
+			IncompletePointsMode: IncompletePointsDrop,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Equal(t, 2, res[0].Rows())
+		require.Equal(t, time.Unix(2, 0).UTC(), res[0].Fields[0].At(1))
+
+		query.IncompletePointsMode = IncompletePointsMark
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Equal(t, 3, res[0].Rows())
+		require.Equal(t, 1, res[0].Meta.Custom.(map[string]interface{})["provisionalPoints"])
+	})
+
+	t.Run("matrix response should include an epoch field when enabled", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application"},
+				Values: []p.SamplePair{
+					{Value: 1, Timestamp: 1000},
+					{Value: 3, Timestamp: 3000},
+				},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:         1 * time.Second,
+			Start:        time.Unix(1, 0).UTC(),
+			End:          time.Unix(3, 0).UTC(),
+			EpochMsField: true,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Len(t, res[0].Fields, 3)
+		epochField := res[0].Fields[2]
+		require.Equal(t, "epochMs", epochField.Name)
+		require.Equal(t, res[0].Fields[0].Len(), epochField.Len())
+		for i := 0; i < epochField.Len(); i++ {
+			require.Equal(t, res[0].Fields[0].At(i).(time.Time).UnixMilli(), epochField.At(i))
+		}
+		require.Equal(t, int64(2000), epochField.At(1))
+	})
+
+	t.Run("matrix response should carry an estimate of its serialized size", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}, {Value: 2, Timestamp: 2000}, {Value: 3, Timestamp: 3000}},
+			},
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Other"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}, {Value: 2, Timestamp: 2000}, {Value: 3, Timestamp: 3000}},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:  1 * time.Second,
+			Start: time.Unix(1, 0).UTC(),
+			End:   time.Unix(3, 0).UTC(),
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 2)
+		// 2 frames × 3 rows × (8 bytes time + 9 bytes nullable float)
+		require.Equal(t, int64(102), res[0].Meta.Custom.(map[string]interface{})["estimatedBytes"])
+		require.Equal(t, int64(102), res[1].Meta.Custom.(map[string]interface{})["estimatedBytes"])
+	})
+
 	t.Run("matrix response with NaN value should be changed to null", func(t *testing.T) {
 		value := make(map[TimeSeriesQueryType]interface{})
 		value[RangeQueryType] = p.Matrix{
@@ -668,6 +1669,323 @@ func TestPrometheus_parseTimeSeriesResponse(t *testing.T) {
 		require.Equal(t, res[0].Fields[1].At(0), nilPointer)
 	})
 
+	t.Run("matrix response with clamped end should carry a notice", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application"},
+				Values: []p.SamplePair{
+					{Value: 1, Timestamp: 1000},
+				},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:       1 * time.Second,
+			Start:      time.Unix(1, 0).UTC(),
+			End:        time.Unix(2, 0).UTC(),
+			EndClamped: true,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Len(t, res[0].Meta.Notices, 1)
+		require.Equal(t, data.NoticeSeverityInfo, res[0].Meta.Notices[0].Severity)
+		require.Equal(t, "The end of the query range was in the future and has been limited to the current time of the Prometheus server.", res[0].Meta.Notices[0].Text)
+
+		query.EndClampedLocally = true
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Equal(t, "The end of the query range was in the future and has been limited to the current time of the Grafana server.", res[0].Meta.Notices[0].Text)
+	})
+
+	t.Run("matrix response should carry a checksum of its values", func(t *testing.T) {
+		newValue := func(values ...p.SampleValue) map[TimeSeriesQueryType]interface{} {
+			pairs := make([]p.SamplePair, 0, len(values))
+			for i, v := range values {
+				pairs = append(pairs, p.SamplePair{Value: v, Timestamp: p.Time(int64(i+1) * 1000)})
+			}
+			return map[TimeSeriesQueryType]interface{}{
+				RangeQueryType: p.Matrix{
+					&p.SampleStream{Metric: p.Metric{"app": "Application"}, Values: pairs},
+				},
+			}
+		}
+		query := &PrometheusQuery{
+			Step:  1 * time.Second,
+			Start: time.Unix(1, 0).UTC(),
+			End:   time.Unix(3, 0).UTC(),
+		}
+		checksum := func(value map[TimeSeriesQueryType]interface{}) interface{} {
+			res, err := parseTimeSeriesResponse(value, query)
+			require.NoError(t, err)
+			require.Len(t, res, 1)
+			return res[0].Meta.Custom.(map[string]interface{})["checksum"]
+		}
+
+		first := checksum(newValue(1, 2, 3))
+		require.NotEmpty(t, first)
+		require.Equal(t, first, checksum(newValue(1, 2, 3)))
+		require.NotEqual(t, first, checksum(newValue(1, 2, 4)))
+		require.NotEqual(t, first, checksum(newValue(1, 2, p.SampleValue(math.NaN()))))
+	})
+
+	t.Run("matrix response should drop series not matching the value filter", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "above"},
+				Values: []p.SamplePair{{Value: 50, Timestamp: 1000}, {Value: 150, Timestamp: 2000}},
+			},
+			&p.SampleStream{
+				Metric: p.Metric{"app": "below"},
+				Values: []p.SamplePair{{Value: 150, Timestamp: 1000}, {Value: 50, Timestamp: 2000}},
+			},
+		}
+		query := &PrometheusQuery{
+			LegendFormat: "{{app}}",
+			Step:         1 * time.Second,
+			Start:        time.Unix(1, 0).UTC(),
+			End:          time.Unix(2, 0).UTC(),
+			ValueFilter:  &ValueFilter{Operator: ">", Threshold: 100, Aggregation: ValueFilterLast},
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		require.Equal(t, "above", res[0].Name)
+
+		query.ValueFilter.Aggregation = ValueFilterMax
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 2)
+
+		query.ValueFilter.Aggregation = ValueFilterMean
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Len(t, res, 0)
+	})
+
+	t.Run("vector response should be named in by clause order", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[InstantQueryType] = p.Vector{
+			&p.Sample{
+				Metric:    p.Metric{"job": "grafana", "instance": "host:3000"},
+				Value:     1,
+				Timestamp: 1000,
+			},
+		}
+		query := &PrometheusQuery{
+			Expr:       "sum by (job, instance) (up)",
+			LabelOrder: groupingLabels("sum by (job, instance) (up)"),
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Equal(t, `{job="grafana", instance="host:3000"}`, res[0].Name)
+		require.Equal(t, []string{"job", "instance"}, res[0].Meta.Custom.(map[string]interface{})["labelOrder"])
+	})
+
+	t.Run("matrix response should apply boundary and interior NaN policies", func(t *testing.T) {
+		nan := p.SampleValue(math.NaN())
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"app": "Application"},
+				Values: []p.SamplePair{
+					{Value: nan, Timestamp: 1000},
+					{Value: 2, Timestamp: 2000},
+					{Value: nan, Timestamp: 3000},
+					{Value: 4, Timestamp: 4000},
+					{Value: nan, Timestamp: 5000},
+				},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:              1 * time.Second,
+			Start:             time.Unix(1, 0).UTC(),
+			End:               time.Unix(5, 0).UTC(),
+			BoundaryNaNPolicy: NaNPolicyDrop,
+			InteriorNaNPolicy: NaNPolicyNull,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Equal(t, 3, res[0].Rows())
+		require.Equal(t, time.Unix(2, 0).UTC(), res[0].Fields[0].At(0))
+		require.Equal(t, time.Unix(4, 0).UTC(), res[0].Fields[0].At(2))
+		var nilPointer *float64
+		require.Equal(t, nilPointer, res[0].Fields[1].At(1))
+		require.Nil(t, res[0].Fields[1].Config.Custom)
+
+		query.BoundaryNaNPolicy = NaNPolicyNull
+		query.InteriorNaNPolicy = NaNPolicyDrop
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Equal(t, 4, res[0].Rows())
+		require.Equal(t, nilPointer, res[0].Fields[1].At(0))
+		require.Equal(t, time.Unix(4, 0).UTC(), res[0].Fields[0].At(2))
+
+		query.InteriorNaNPolicy = NaNPolicyConnected
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Equal(t, 5, res[0].Rows())
+		require.Equal(t, true, res[0].Fields[1].Config.Custom["spanNulls"])
+
+		query.BoundaryNaNPolicy = NaNPolicyKeep
+		query.InteriorNaNPolicy = NaNPolicyKeep
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		require.Equal(t, 5, res[0].Rows())
+		for _, row := range []int{0, 2, 4} {
+			v, ok := res[0].Fields[1].ConcreteAt(row)
+			require.True(t, ok)
+			require.True(t, math.IsNaN(v.(float64)))
+		}
+	})
+
+	t.Run("matrix response should get a uniform field config per metric name", func(t *testing.T) {
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: p.Metric{"__name__": "http_request_duration_seconds_sum", "handler": "/a"},
+				Values: []p.SamplePair{{Value: 1, Timestamp: 1000}},
+			},
+			&p.SampleStream{
+				Metric: p.Metric{"__name__": "http_request_duration_seconds_sum", "handler": "/b"},
+				Values: []p.SamplePair{{Value: 2, Timestamp: 1000}},
+			},
+			&p.SampleStream{
+				Metric: p.Metric{"__name__": "http_response_size_bytes", "handler": "/a"},
+				Values: []p.SamplePair{{Value: 3, Timestamp: 1000}},
+			},
+		}
+		decimals := uint16(2)
+		query := &PrometheusQuery{
+			Step:               1 * time.Second,
+			Start:              time.Unix(1, 0).UTC(),
+			End:                time.Unix(1, 0).UTC(),
+			UniformFieldConfig: true,
+			Decimals:           &decimals,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 3)
+		for _, frame := range res {
+			cfg := frame.Fields[1].Config
+			switch frame.Fields[1].Labels["__name__"] {
+			case "http_request_duration_seconds_sum":
+				require.Equal(t, "s", cfg.Unit)
+			case "http_response_size_bytes":
+				require.Equal(t, "bytes", cfg.Unit)
+			}
+			require.Equal(t, &decimals, cfg.Decimals)
+			require.Equal(t, frame.Name, cfg.DisplayNameFromDS)
+		}
+
+		query.Unit = "short"
+		res, err = parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+		for _, frame := range res {
+			require.Equal(t, "short", frame.Fields[1].Config.Unit)
+		}
+	})
+
+	t.Run("matrix response should be scored against the seasonal baseline", func(t *testing.T) {
+		metric := p.Metric{"app": "Application"}
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: metric,
+				Values: []p.SamplePair{{Value: 10, Timestamp: 101000}, {Value: 20, Timestamp: 102000}, {Value: 30, Timestamp: 103000}},
+			},
+		}
+		value[SeasonalBaselineQueryType] = p.Matrix{
+			&p.SampleStream{
+				Metric: metric,
+				Values: []p.SamplePair{{Value: 10, Timestamp: 1000}, {Value: 12, Timestamp: 2000}},
+			},
+		}
+		query := &PrometheusQuery{
+			Step:            1 * time.Second,
+			Start:           time.Unix(101, 0).UTC(),
+			End:             time.Unix(103, 0).UTC(),
+			SeasonalAnomaly: true,
+			SeasonalOffset:  100 * time.Second,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Len(t, res[0].Fields, 3)
+		scoreField := res[0].Fields[2]
+		require.Equal(t, "anomalyScore", scoreField.Name)
+
+		// The baseline has a standard deviation of sqrt(2).
+		require.Equal(t, 0.0, *scoreField.At(0).(*float64))
+		require.InDelta(t, 8/math.Sqrt2, *scoreField.At(1).(*float64), 1e-9)
+		require.Nil(t, scoreField.At(2))
+	})
+
+	t.Run("matrix response should report its completeness", func(t *testing.T) {
+		// 5 minutes at a 15s scrape interval are 21 expected samples, 14 of them are present.
+		values := []p.SamplePair{}
+		for i := 0; i < 21; i++ {
+			if i%3 == 2 {
+				continue
+			}
+			values = append(values, p.SamplePair{Value: 1, Timestamp: p.Time(int64(i) * 15000)})
+		}
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{Metric: p.Metric{"app": "Application"}, Values: values},
+		}
+		query := &PrometheusQuery{
+			Step:           15 * time.Second,
+			Start:          time.Unix(0, 0).UTC(),
+			End:            time.Unix(300, 0).UTC(),
+			ScrapeInterval: 15 * time.Second,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Len(t, values, 14)
+		require.InDelta(t, 14.0/21.0, res[0].Meta.Custom.(map[string]interface{})["completeness"], 1e-9)
+	})
+
+	t.Run("matrix response should cap the labels of a field", func(t *testing.T) {
+		metric := p.Metric{"__name__": "up"}
+		for i := 0; i < 19; i++ {
+			metric[p.LabelName(fmt.Sprintf("label%02d", i))] = "value"
+		}
+		value := make(map[TimeSeriesQueryType]interface{})
+		value[RangeQueryType] = p.Matrix{
+			&p.SampleStream{Metric: metric, Values: []p.SamplePair{{Value: 1, Timestamp: 1000}}},
+		}
+		query := &PrometheusQuery{
+			Step:              1 * time.Second,
+			Start:             time.Unix(1, 0).UTC(),
+			End:               time.Unix(1, 0).UTC(),
+			MaxLabelsPerField: 5,
+		}
+		res, err := parseTimeSeriesResponse(value, query)
+		require.NoError(t, err)
+
+		require.Len(t, res, 1)
+		require.Len(t, metric, 20)
+		require.Equal(t, data.Labels{
+			"__name__": "up",
+			"label00":  "value",
+			"label01":  "value",
+			"label02":  "value",
+			"label03":  "value",
+		}, res[0].Fields[1].Labels)
+		require.Equal(t, 15, res[0].Meta.Custom.(map[string]interface{})["droppedLabels"])
+	})
+
 	t.Run("vector response should be parsed normally", func(t *testing.T) {
 		value := make(map[TimeSeriesQueryType]interface{})
 		value[RangeQueryType] = p.Vector{
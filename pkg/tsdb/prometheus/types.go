@@ -1,15 +1,69 @@
 package prometheus
 
 import (
+	"net/url"
 	"time"
 
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/middleware"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus/promclient"
 	apiv1 "github.com/prometheus/client_golang/api/prometheus/v1"
 )
 
 type DatasourceInfo struct {
 	ID           int64
+	UID          string
 	URL          string
 	TimeInterval string
+	// ClampEndToNow limits the end of the queried range to the current time of
+	// the Prometheus server, so ranges reaching into the future don't return
+	// sparse trailing data. The time is told by ServerClock, the clock of the
+	// Grafana server is used until Prometheus sent a response.
+	ClampEndToNow bool
+	ServerClock   *middleware.ServerClock
+	// CalculatorMinInterval overrides the minimum of the interval calculator.
+	// The service-wide default is used when it is zero.
+	CalculatorMinInterval time.Duration
+	// MaxLabelsPerField caps the number of labels kept on each series field, no cap when zero.
+	MaxLabelsPerField int
+	// StreamRangeResponses turns range query results into frames while they are
+	// decoded, which also decodes native histograms and query statistics.
+	StreamRangeResponses bool
+	// MaxSeries is the number of series results are truncated to, unless a query
+	// sets a lower limit. No limit when zero.
+	MaxSeries int
+	// ExemplarTraceIdDestinations are the trace links added to exemplar frames.
+	ExemplarTraceIdDestinations []promclient.ExemplarTraceIdDestination
+	// SplitInterval splits range queries into sub-ranges of at most this length,
+	// unless a query sets its own. SplitConcurrency limits how many of the
+	// sub-ranges of a query run at the same time.
+	SplitInterval    time.Duration
+	SplitConcurrency int
+	// ExemplarChunkInterval splits exemplar queries over longer ranges into
+	// windows of this length, which run SplitConcurrency at a time.
+	ExemplarChunkInterval time.Duration
+	// QueryConcurrency limits how many queries of a request run at the same time.
+	QueryConcurrency int
+	// IncrementalCache holds the range results of earlier queries, so that repeated
+	// queries only ask Prometheus for the new part of their range. It is nil when
+	// incremental querying is disabled.
+	IncrementalCache *IncrementalQueryCache
+	// RemoteRead answers range queries of plain selectors from the remote read API.
+	RemoteRead bool
+	// RemoteReadLookback is the lookback delta of the Prometheus server, used to
+	// evaluate remote read samples. The Prometheus default applies when zero.
+	RemoteReadLookback time.Duration
+	// LabelsCacheTTL is how long the label names and values resources are cached,
+	// they are not cached when zero.
+	LabelsCacheTTL time.Duration
+	// HealthCheckQuery is the probe query of the health check, which only checks
+	// that the API is reachable when it is empty.
+	HealthCheckQuery string
+	// RecordingRules answers queries matching the expression of a recording rule
+	// from the series of the rule. Queries are not rewritten when it is nil.
+	RecordingRules *RecordingRules
+	// FlavorDetector remembers the flavor of the server, to gate query features
+	// on its capabilities. Nothing is gated when it is nil.
+	FlavorDetector *FlavorDetector
 
 	getClient clientGetter
 }
@@ -27,16 +81,171 @@ type PrometheusQuery struct {
 	RangeQuery    bool
 	ExemplarQuery bool
 	UtcOffsetSec  int64
+	// EndClamped is set when End was moved back to the current time of the
+	// Prometheus server, or of the Grafana server when EndClampedLocally is set.
+	EndClamped        bool
+	EndClampedLocally bool
+	// SkipEmptyExemplarFrame leaves out the exemplar frame, which has the
+	// standard schema, when the exemplar query returned no exemplars.
+	SkipEmptyExemplarFrame bool
+	// ValueFilter drops series that don't satisfy it after the frames are built.
+	ValueFilter *ValueFilter
+	// LabelOrder is the order of the `by (...)` clause of the expression, used for series names.
+	LabelOrder []string
+	// IncompletePoints is the number of trailing buckets of a range query that
+	// may still be aggregating. IncompletePointsMode decides what happens to them.
+	IncompletePoints     int
+	IncompletePointsMode IncompletePointsMode
+	// EpochMsField adds an int64 field with the sample timestamps in epoch milliseconds to range results.
+	EpochMsField bool
+	// Format is the requested result format. FormatDefaulted is set when the
+	// query model had no format and time_series was assumed.
+	Format          string
+	FormatDefaulted bool
+	// ResultFormat is the shape of the series frames, a frame per series or a
+	// single long frame.
+	ResultFormat ResultFormat
+	// BoundaryNaNPolicy applies to NaN samples before the first and after the last
+	// real value of a series, InteriorNaNPolicy to the NaN samples in between. They
+	// apply to range results, instant results keep NaN samples.
+	BoundaryNaNPolicy NaNPolicy
+	InteriorNaNPolicy NaNPolicy
+	// UniformFieldConfig gives all series sharing a metric name the same unit and decimals.
+	// Unit and Decimals override what is derived from the metric name.
+	UniformFieldConfig bool
+	Unit               string
+	Decimals           *uint16
+	// ExemplarUnit overrides the unit of the exemplar value field, which is
+	// otherwise derived from the metric names of the exemplar series.
+	ExemplarUnit string
+	// RateWindow is the range of the outermost rate() of the expression, e.g. "5m".
+	RateWindow string
+	// MergeInstantTable joins the instant result with those of the other queries
+	// of the request that set it into a single table frame.
+	MergeInstantTable bool
+	// SeasonalAnomaly runs the range query again, SeasonalOffset earlier, and
+	// scores the deviation of every point from that seasonal baseline.
+	SeasonalAnomaly bool
+	SeasonalOffset  time.Duration
+	// Location is the time zone used to align the range to the step, UTC when unset
+	// or when the query has an explicit UTC offset.
+	Location *time.Location
+	// WeekStart aligns steps of whole weeks to the first day of the week in the
+	// time zone, instead of to the Thursday of the Unix epoch, when set.
+	WeekStart *time.Weekday
+	// ScrapeInterval is the scrape interval of the query, the one of the data
+	// source unless the query sets its own. It is used to report the completeness
+	// of range results.
+	ScrapeInterval time.Duration
+	// MaxLabelsPerField is the label cap of the data source.
+	MaxLabelsPerField int
+	// MaxSeries is the number of series the result is truncated to, no limit when zero.
+	MaxSeries int
+	// BucketRangeLegend renders the `le` label of histogram bucket series as the
+	// range of the bucket, e.g. `(0.1, 0.5]`. Heatmap queries always do.
+	BucketRangeLegend bool
+	// Downsample is the consolidation of the buckets of DownsampleStep that range
+	// results are downsampled to, when the step would return more points than the
+	// max data points of the query. They are not downsampled when it is zero.
+	Downsample     Consolidation
+	DownsampleStep time.Duration
+	// SplitInterval is the length of the sub-ranges the range query is split into,
+	// it is not split when zero. At most SplitConcurrency sub-ranges run at once.
+	SplitInterval    time.Duration
+	SplitConcurrency int
+	// ExemplarChunkInterval is the length of the windows the exemplar query is
+	// split into, it is not split when zero.
+	ExemplarChunkInterval time.Duration
+	// IncrementalCache is the cache of the data source when incremental querying is
+	// enabled, and IncrementalCacheKey identifies the results of this query in it.
+	IncrementalCache    *IncrementalQueryCache
+	IncrementalCacheKey string
+	// RemoteRead answers the range query from the raw samples of the remote read
+	// API, in protobuf, when the expression is a plain selector.
+	RemoteRead bool
+	// RemoteReadLookback is the RemoteReadLookback of the data source.
+	RemoteReadLookback time.Duration
+	// Stats attaches the statistics of the range query to the frame metadata.
+	Stats bool
+	// StreamRangeResponse is the StreamRangeResponses setting of the data source.
+	StreamRangeResponse bool
+	// CustomQueryParameters are added to the requests of this query, replacing
+	// datasource-level custom query parameters with the same name.
+	CustomQueryParameters url.Values
+	// Timeout is the deadline of the requests of this query, also passed to
+	// Prometheus as the query timeout. The data source timeout applies when zero.
+	Timeout time.Duration
+	// SigV4 overrides the region the requests of this query are signed for, for
+	// data sources with SigV4 authentication.
+	SigV4 middleware.SigV4Options
+	// ExactRange sends the requested start and end as they are, instead of
+	// aligning them to the step.
+	ExactRange bool
+	// AlertAnnotations turns the alert series of the range query into a frame of
+	// annotation regions.
+	AlertAnnotations bool
+	// ExemplarTraceIdDestinations are the trace links of the data source.
+	ExemplarTraceIdDestinations []promclient.ExemplarTraceIdDestination
+	// DatasourceUID is the UID of the data source, used to label metrics.
+	DatasourceUID string
+	// RecordingRule is the name of the recording rule whose series answer the query,
+	// OriginalExpr the expression it replaced. Empty when the query is not rewritten.
+	RecordingRule string
+	OriginalExpr  string
+	// Capabilities are the features supported by the server, nil when unknown.
+	Capabilities *promclient.Capabilities
 }
 
+type NaNPolicy string
+
+const (
+	// NaNPolicyNull turns NaN samples into nulls.
+	NaNPolicyNull NaNPolicy = "null"
+	// NaNPolicyDrop removes the rows of NaN samples.
+	NaNPolicyDrop NaNPolicy = "drop"
+	// NaNPolicyConnected turns NaN samples into nulls and connects the values around them.
+	NaNPolicyConnected NaNPolicy = "connected"
+	// NaNPolicyKeep keeps NaN samples as NaN values, e.g. for alert conditions that
+	// tell NaN results from missing data.
+	NaNPolicyKeep NaNPolicy = "keep"
+)
+
+const (
+	formatTimeSeries = "time_series"
+	formatHeatmap    = "heatmap"
+	formatTable      = "table"
+)
+
+type IncompletePointsMode string
+
+const (
+	// IncompletePointsDrop removes the trailing buckets from the series.
+	IncompletePointsDrop IncompletePointsMode = "drop"
+	// IncompletePointsMark keeps the trailing buckets and reports them as provisional in the frame metadata.
+	IncompletePointsMark IncompletePointsMode = "mark"
+)
+
+type ResultFormat string
+
+const (
+	// ResultFormatWide returns a frame per series, with the labels on the value field.
+	ResultFormatWide ResultFormat = "wide"
+	// ResultFormatLong returns a single frame with a row per sample and a column per label.
+	ResultFormatLong ResultFormat = "long"
+)
+
 type ExemplarEvent struct {
 	Time   time.Time
 	Value  float64
 	Labels map[string]string
+	// SeriesFingerprint identifies the series the exemplar belongs to.
+	// It matches the fingerprint in the metadata of the frame built for that series.
+	SeriesFingerprint string
 }
 
 type QueryModel struct {
 	Expr           string `json:"expr"`
+	Format         string `json:"format"`
 	LegendFormat   string `json:"legendFormat"`
 	Interval       string `json:"interval"`
 	IntervalMS     int64  `json:"intervalMS"`
@@ -46,4 +255,41 @@ type QueryModel struct {
 	ExemplarQuery  bool   `json:"exemplar"`
 	IntervalFactor int64  `json:"intervalFactor"`
 	UtcOffsetSec   int64  `json:"utcOffsetSec"`
+
+	SkipEmptyExemplarFrame bool    `json:"skipEmptyExemplarFrame"`
+	ValueFilter            string  `json:"valueFilter"`
+	ValueFilterAggregation string  `json:"valueFilterAggregation"`
+	IncompletePoints       int     `json:"incompletePoints"`
+	IncompletePointsMode   string  `json:"incompletePointsMode"`
+	EpochMsField           bool    `json:"epochMsField"`
+	NaNPolicy              string  `json:"nanPolicy"`
+	Downsample             string  `json:"downsample"`
+	BoundaryNaNPolicy      string  `json:"boundaryNaNPolicy"`
+	InteriorNaNPolicy      string  `json:"interiorNaNPolicy"`
+	UniformFieldConfig     bool    `json:"uniformFieldConfig"`
+	Unit                   string  `json:"unit"`
+	Decimals               *uint16 `json:"decimals"`
+	ExemplarUnit           string  `json:"exemplarUnit"`
+	MergeInstantTable      bool    `json:"mergeInstantTable"`
+	SeasonalAnomaly        bool    `json:"seasonalAnomaly"`
+	SeasonalOffset         string  `json:"seasonalOffset"`
+	Timezone               string  `json:"timezone"`
+	WeekStart              string  `json:"weekStart"`
+	BucketRangeLegend      bool    `json:"bucketRangeLegend"`
+	SplitInterval          string  `json:"splitInterval"`
+	Stats                  bool    `json:"stats"`
+	CustomQueryParameters  string  `json:"customQueryParameters"`
+	AlignRange             *bool   `json:"alignRange"`
+	MinStep                string  `json:"minStep"`
+	ScrapeInterval         string  `json:"scrapeInterval"`
+	MaxSeries              int     `json:"maxSeries"`
+	Timeout                string  `json:"timeout"`
+	ResultFormat           string  `json:"resultFormat"`
+	SigV4Region            string  `json:"sigV4Region"`
+
+	// Variables are the values of the template variables of the expression, for
+	// queries that are not interpolated by the frontend, like those of alert rules.
+	Variables map[string]TemplateVariableValue `json:"variables"`
+	// Thanos are the options of Thanos queriers, sent as query parameters.
+	Thanos *ThanosOptions `json:"thanos"`
 }
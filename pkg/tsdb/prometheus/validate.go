@@ -0,0 +1,98 @@
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// validationError is a syntax error of an expression. Start and End are the
+// byte offsets of the erroneous part, Line and Column the 1-based position of
+// Start.
+type validationError struct {
+	Message string `json:"message"`
+	Start   int    `json:"start"`
+	End     int    `json:"end"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+type validationResult struct {
+	Valid  bool              `json:"valid"`
+	Errors []validationError `json:"errors"`
+	// Selectors are the vector selectors of a valid expression, in order of
+	// appearance and without duplicates.
+	Selectors []string `json:"selectors"`
+}
+
+// handleValidate parses the query parameter, or form value, with the PromQL
+// parser and reports its syntax errors and selectors. It does not contact
+// Prometheus, so template variables have to be interpolated by the caller.
+func (s *Service) handleValidate(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodPost {
+		writeResourceError(rw, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", req.Method))
+		return
+	}
+	expr := req.FormValue("query")
+	if strings.TrimSpace(expr) == "" {
+		writeResourceError(rw, http.StatusBadRequest, fmt.Errorf("missing query"))
+		return
+	}
+	writeResourceData(rw, validateExpr(expr))
+}
+
+func validateExpr(expr string) validationResult {
+	result := validationResult{Errors: []validationError{}, Selectors: []string{}}
+
+	node, err := parser.ParseExpr(expr)
+	if err != nil {
+		var parseErrs parser.ParseErrors
+		var parseErr *parser.ParseErr
+		switch {
+		case errors.As(err, &parseErrs):
+			for _, e := range parseErrs {
+				result.Errors = append(result.Errors, newValidationError(expr, e))
+			}
+		case errors.As(err, &parseErr):
+			result.Errors = append(result.Errors, newValidationError(expr, *parseErr))
+		default:
+			result.Errors = append(result.Errors, validationError{Message: err.Error(), Line: 1, Column: 1})
+		}
+		return result
+	}
+
+	result.Valid = true
+	seen := map[string]bool{}
+	parser.Inspect(node, func(n parser.Node, _ []parser.Node) error {
+		if vs, ok := n.(*parser.VectorSelector); ok {
+			selector := vs.String()
+			if !seen[selector] {
+				seen[selector] = true
+				result.Selectors = append(result.Selectors, selector)
+			}
+		}
+		return nil
+	})
+	return result
+}
+
+func newValidationError(expr string, e parser.ParseErr) validationError {
+	start, end := int(e.PositionRange.Start), int(e.PositionRange.End)
+	if start > len(expr) {
+		start = len(expr)
+	}
+	if end < start {
+		end = start
+	}
+	line := strings.Count(expr[:start], "\n") + 1
+	column := start - strings.LastIndex(expr[:start], "\n")
+
+	message := e.Error()
+	if e.Err != nil {
+		message = e.Err.Error()
+	}
+	return validationError{Message: message, Start: start, End: end, Line: line, Column: column}
+}
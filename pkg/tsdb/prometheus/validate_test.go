@@ -0,0 +1,57 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateExpr(t *testing.T) {
+	t.Run("it returns the selectors of valid expressions", func(t *testing.T) {
+		result := validateExpr(`sum(rate(http_requests_total{job="api"}[5m])) / sum(rate(http_requests_total{job="api"}[5m])) + up`)
+		require.True(t, result.Valid)
+		require.Empty(t, result.Errors)
+		require.Equal(t, []string{`http_requests_total{job="api"}`, "up"}, result.Selectors)
+	})
+
+	t.Run("it returns the position of syntax errors", func(t *testing.T) {
+		result := validateExpr("sum(up)\n  / rate(up[5m)")
+		require.False(t, result.Valid)
+		require.Empty(t, result.Selectors)
+		require.Len(t, result.Errors, 2)
+		require.Equal(t, validationError{
+			Message: `unexpected ")" in subquery or range, expected ":" or "]"`,
+			Start:   22,
+			End:     23,
+			Line:    2,
+			Column:  15,
+		}, result.Errors[0])
+		require.Equal(t, "unclosed left bracket", result.Errors[1].Message)
+	})
+
+	t.Run("it reports semantic errors", func(t *testing.T) {
+		result := validateExpr(`rate(up)`)
+		require.False(t, result.Valid)
+		require.Len(t, result.Errors, 1)
+		require.Contains(t, result.Errors[0].Message, "expected type range vector")
+		require.Equal(t, 1, result.Errors[0].Line)
+	})
+}
+
+func TestValidateResource(t *testing.T) {
+	s := newResourceTestService(&metadataClient{}, 0)
+
+	res := callResource(t, s, "validate?query="+url.QueryEscape(`up{job="api"}`))
+	require.Equal(t, http.StatusOK, res.Status)
+	require.JSONEq(t, `{"status":"success","data":{"valid":true,"errors":[],"selectors":["up{job=\"api\"}"]}}`, string(res.Body))
+
+	res = callResource(t, s, "validate?query="+url.QueryEscape(`up{`))
+	require.Equal(t, http.StatusOK, res.Status)
+	require.Contains(t, string(res.Body), `"valid":false`)
+
+	res = callResource(t, s, "validate")
+	require.Equal(t, http.StatusBadRequest, res.Status)
+	require.JSONEq(t, `{"status":"error","error":"missing query"}`, string(res.Body))
+}
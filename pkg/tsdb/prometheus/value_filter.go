@@ -0,0 +1,137 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+type ValueFilterAggregation string
+
+const (
+	ValueFilterLast ValueFilterAggregation = "last"
+	ValueFilterMax  ValueFilterAggregation = "max"
+	ValueFilterMean ValueFilterAggregation = "mean"
+)
+
+// valueFilterOperators is ordered so two-character operators are matched before their prefixes.
+var valueFilterOperators = []string{">=", "<=", "==", "!=", ">", "<"}
+
+// ValueFilter drops series whose aggregated value does not satisfy a comparison, e.g. `>100`.
+type ValueFilter struct {
+	Operator    string
+	Threshold   float64
+	Aggregation ValueFilterAggregation
+}
+
+func parseValueFilter(filter string, aggregation string) (*ValueFilter, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, nil
+	}
+
+	agg := ValueFilterAggregation(aggregation)
+	switch agg {
+	case "":
+		agg = ValueFilterLast
+	case ValueFilterLast, ValueFilterMax, ValueFilterMean:
+	default:
+		return nil, fmt.Errorf("invalid value filter aggregation %q", aggregation)
+	}
+
+	for _, op := range valueFilterOperators {
+		if !strings.HasPrefix(filter, op) {
+			continue
+		}
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimPrefix(filter, op)), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value filter %q: %w", filter, err)
+		}
+		return &ValueFilter{Operator: op, Threshold: threshold, Aggregation: agg}, nil
+	}
+
+	return nil, fmt.Errorf("invalid value filter %q: missing comparison operator", filter)
+}
+
+func (f *ValueFilter) matches(v float64) bool {
+	switch f.Operator {
+	case ">=":
+		return v >= f.Threshold
+	case "<=":
+		return v <= f.Threshold
+	case "==":
+		return v == f.Threshold
+	case "!=":
+		return v != f.Threshold
+	case ">":
+		return v > f.Threshold
+	case "<":
+		return v < f.Threshold
+	}
+	return false
+}
+
+// filterFramesByValue removes the series frames that don't satisfy the filter.
+// Exemplar frames are never filtered.
+func filterFramesByValue(frames data.Frames, filter *ValueFilter) data.Frames {
+	filtered := frames[:0]
+	for _, frame := range frames {
+		if frameResultType(frame) == "exemplar" || len(frame.Fields) < 2 {
+			filtered = append(filtered, frame)
+			continue
+		}
+
+		v, ok := aggregateValueField(frame.Fields[1], filter.Aggregation)
+		if ok && filter.matches(v) {
+			filtered = append(filtered, frame)
+		}
+	}
+	return filtered
+}
+
+// aggregateValueField reduces the non-null, non-NaN values of a field.
+// It returns false when the field has no such value.
+func aggregateValueField(field *data.Field, aggregation ValueFilterAggregation) (float64, bool) {
+	var (
+		result float64
+		sum    float64
+		count  int
+	)
+	for i := 0; i < field.Len(); i++ {
+		v, err := field.FloatAt(i)
+		if err != nil || math.IsNaN(v) {
+			continue
+		}
+		switch {
+		case aggregation == ValueFilterMax && (count == 0 || v > result):
+			result = v
+		case aggregation == ValueFilterLast:
+			result = v
+		}
+		sum += v
+		count++
+	}
+
+	if count == 0 {
+		return 0, false
+	}
+	if aggregation == ValueFilterMean {
+		return sum / float64(count), true
+	}
+	return result, true
+}
+
+func frameResultType(frame *data.Frame) string {
+	if frame.Meta == nil {
+		return ""
+	}
+	custom, ok := frame.Meta.Custom.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	typ, _ := custom["resultType"].(string)
+	return typ
+}
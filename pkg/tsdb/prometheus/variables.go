@@ -0,0 +1,106 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templateVariableRegex matches the template variable syntaxes of dashboards:
+// $name, ${name}, ${name:format} and [[name]], [[name:format]].
+var templateVariableRegex = regexp.MustCompile(`\$(\w+)|\$\{(\w+)(?::(\w+))?\}|\[\[(\w+)(?::(\w+))?\]\]`)
+
+// regexSpecialCharacters are escaped in the values of multi-value variables,
+// which are used as alternatives in regex label matchers.
+var regexSpecialCharacters = regexp.MustCompile(`[$^*{}\[\]'+?.()|]`)
+
+// TemplateVariableValue is the value of a template variable in the query model:
+// a string, or a list of strings for multi-value variables.
+type TemplateVariableValue struct {
+	Values []string
+	Multi  bool
+}
+
+func (v *TemplateVariableValue) UnmarshalJSON(b []byte) error {
+	var value string
+	if err := json.Unmarshal(b, &value); err == nil {
+		*v = TemplateVariableValue{Values: []string{value}}
+		return nil
+	}
+	var values []string
+	if err := json.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("template variable values must be a string or a list of strings")
+	}
+	*v = TemplateVariableValue{Values: values, Multi: true}
+	return nil
+}
+
+// interpolateTemplateVariables replaces the template variables of expr that are
+// in variables by their values, like the frontend does before sending a query.
+// This lets queries that are not sent by a dashboard, like alert rules, use
+// variables too. Unknown variables and the built-in $__ variables are left as
+// they are. Values are escaped for PromQL strings, and multi-value variables
+// become a regex alternative of their escaped values, e.g. (a|b). The raw,
+// regex, pipe and csv formats are supported as well.
+func interpolateTemplateVariables(expr string, variables map[string]TemplateVariableValue) string {
+	if len(variables) == 0 {
+		return expr
+	}
+	return templateVariableRegex.ReplaceAllStringFunc(expr, func(match string) string {
+		groups := templateVariableRegex.FindStringSubmatch(match)
+		name := groups[1] + groups[2] + groups[4]
+		format := groups[3] + groups[5]
+		value, ok := variables[name]
+		if !ok || strings.HasPrefix(name, "__") {
+			return match
+		}
+		formatted, ok := formatTemplateVariable(value, format)
+		if !ok {
+			return match
+		}
+		return formatted
+	})
+}
+
+func formatTemplateVariable(value TemplateVariableValue, format string) (string, bool) {
+	switch format {
+	case "":
+		if !value.Multi {
+			return prometheusEscape(strings.Join(value.Values, "")), true
+		}
+		return regexAlternative(value.Values), true
+	case "regex":
+		return regexAlternative(value.Values), true
+	case "raw", "csv":
+		return strings.Join(value.Values, ","), true
+	case "pipe":
+		return strings.Join(value.Values, "|"), true
+	}
+	return "", false
+}
+
+// regexAlternative returns a regex matching any of values, for use in a PromQL string.
+func regexAlternative(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = prometheusRegexEscape(v)
+	}
+	if len(escaped) == 1 {
+		return escaped[0]
+	}
+	return "(" + strings.Join(escaped, "|") + ")"
+}
+
+// prometheusEscape escapes a value for a PromQL string literal.
+func prometheusEscape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return strings.ReplaceAll(value, `'`, `\'`)
+}
+
+// prometheusRegexEscape escapes a value for a regex in a PromQL string literal,
+// which needs the backslashes of the regex escaped once more.
+func prometheusRegexEscape(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\\\`)
+	return regexSpecialCharacters.ReplaceAllString(value, `\\$0`)
+}
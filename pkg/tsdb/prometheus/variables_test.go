@@ -0,0 +1,68 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolateTemplateVariables(t *testing.T) {
+	var variables map[string]TemplateVariableValue
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"job": "api",
+		"path": "C:\\data's",
+		"instance": ["a:9090", "b.example:9090"],
+		"single": ["only"],
+		"__interval": "1h"
+	}`), &variables))
+
+	tt := []struct {
+		expr     string
+		expected string
+	}{
+		{expr: `up{job="$job"}`, expected: `up{job="api"}`},
+		{expr: `up{job="${job}"}`, expected: `up{job="api"}`},
+		{expr: `up{job="[[job]]"}`, expected: `up{job="api"}`},
+		{expr: `up{path='$path'}`, expected: `up{path='C:\\data\'s'}`},
+		{expr: `up{instance=~"$instance"}`, expected: `up{instance=~"(a:9090|b\\.example:9090)"}`},
+		{expr: `up{instance=~"$single"}`, expected: `up{instance=~"only"}`},
+		{expr: `up{instance=~"${job:regex}"}`, expected: `up{instance=~"api"}`},
+		{expr: `up{instance=~"${instance:pipe}"}`, expected: `up{instance=~"a:9090|b.example:9090"}`},
+		{expr: `up{instance=~"${instance:raw}"}`, expected: `up{instance=~"a:9090,b.example:9090"}`},
+		{expr: `up{job="${job:unknown}"}`, expected: `up{job="${job:unknown}"}`},
+		{expr: `up{job="$other"}`, expected: `up{job="$other"}`},
+		{expr: `rate(up[$__interval])`, expected: `rate(up[$__interval])`},
+		{expr: `up{job="$job_name"}`, expected: `up{job="$job_name"}`},
+	}
+	for _, test := range tt {
+		t.Run(test.expr, func(t *testing.T) {
+			require.Equal(t, test.expected, interpolateTemplateVariables(test.expr, variables))
+		})
+	}
+
+	t.Run("the result is valid PromQL", func(t *testing.T) {
+		_, err := parser.ParseExpr(interpolateTemplateVariables(`up{instance=~"$instance", path='$path'}`, variables))
+		require.NoError(t, err)
+	})
+
+	t.Run("it rejects other values", func(t *testing.T) {
+		require.Error(t, json.Unmarshal([]byte(`{"job": 1}`), &variables))
+	})
+}
+
+func TestParseQueryWithTemplateVariables(t *testing.T) {
+	service := Service{intervalCalculator: intervalv2.NewCalculator()}
+	query := backend.DataQuery{
+		JSON:      []byte(`{"expr": "sum(rate(requests_total{job=~\"$job\"}[5m]))", "variables": {"job": ["a", "b"]}, "refId": "A"}`),
+		TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)},
+		Interval:  time.Minute,
+	}
+	queries, err := service.parseTimeSeriesQuery(&backend.QueryDataRequest{Queries: []backend.DataQuery{query}}, &DatasourceInfo{})
+	require.NoError(t, err)
+	require.Equal(t, `sum(rate(requests_total{job=~"(a|b)"}[5m]))`, queries[0].Expr)
+}
@@ -0,0 +1,252 @@
+package sqleng
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/gtime"
+
+	"github.com/grafana/grafana/pkg/tsdb/intervalv2"
+)
+
+// SQLMacroDialect generates the SQL of the macros shared by the SQL data
+// sources, which differs between databases.
+type SQLMacroDialect interface {
+	// TimeGroup returns the expression rounding the time column down to the
+	// interval.
+	TimeGroup(column string, interval time.Duration) string
+	// Epoch returns the expression converting the time column to seconds since
+	// the Unix epoch.
+	Epoch(column string) string
+	// EpochGroup returns the expression rounding the numeric column down to
+	// a multiple of size.
+	EpochGroup(column string, size float64) string
+	// TimeAlias returns the expression aliased as the time column.
+	TimeAlias(expression string) string
+	// StringLiteral returns s quoted as a string literal.
+	StringLiteral(s string) string
+	// LikeWildcards returns the characters that are special in LIKE patterns
+	// besides % and _, e.g. [ starting a character range.
+	LikeWildcards() []string
+}
+
+// searchFilterEscape is the escape character of the LIKE patterns of
+// $__searchLike, which is not special in string literals of any dialect.
+const searchFilterEscape = "!"
+
+// maxCalendarSegments is the maximum number of segments of the calendar
+// intervals of $__timeGroup, which each add a condition to the SQL.
+const maxCalendarSegments = 1000
+
+// EvaluateMacro evaluates the macros implemented the same way by all the SQL
+// data sources, generating the SQL of the dialect. ok is false for the macros
+// it doesn't implement, which are left to the data source.
+func (m *SQLMacroEngineBase) EvaluateMacro(dialect SQLMacroDialect, timeRange backend.TimeRange, query *backend.DataQuery,
+	name string, args []string) (sql string, ok bool, err error) {
+	switch name {
+	case "__timeGroup", "__timeGroupAlias":
+		calendar, ok, err := calendarInterval(query, name, args)
+		if err != nil {
+			return "", true, err
+		}
+		if ok {
+			sql, err = calendarGroup(dialect, timeRange, args[0], calendar)
+			if err != nil {
+				return "", true, err
+			}
+			break
+		}
+		interval, err := groupInterval(query, name, args)
+		if err != nil {
+			return "", true, err
+		}
+		sql = dialect.TimeGroup(args[0], interval)
+	case "__unixEpochGroup", "__unixEpochGroupAlias":
+		interval, err := groupInterval(query, name, args)
+		if err != nil {
+			return "", true, err
+		}
+		sql = dialect.EpochGroup(args[0], interval.Seconds())
+	case "__unixEpochGroupMs", "__unixEpochGroupMsAlias":
+		interval, err := groupInterval(query, name, args)
+		if err != nil {
+			return "", true, err
+		}
+		sql = dialect.EpochGroup(args[0], float64(interval.Milliseconds()))
+	// Not $__searchFilter, which the frontend replaces by the search text
+	// before the query is sent.
+	case "__searchLike":
+		if len(args) == 0 || args[0] == "" {
+			return "", true, fmt.Errorf("missing column argument for macro %v", name)
+		}
+		search, err := searchFilter(query)
+		if err != nil {
+			return "", true, err
+		}
+		if search == "" {
+			return "1=1", true, nil
+		}
+		return fmt.Sprintf("%s LIKE %s ESCAPE '%s'", args[0], dialect.StringLiteral(escapeLikePattern(dialect, search)+"%"),
+			searchFilterEscape), true, nil
+	default:
+		return "", false, nil
+	}
+
+	if strings.HasSuffix(name, "Alias") {
+		sql = dialect.TimeAlias(sql)
+	}
+	return sql, true, nil
+}
+
+// groupInterval parses the interval of the grouping macros, and sets up the
+// fill mode of the query when they have a third argument.
+func groupInterval(query *backend.DataQuery, name string, args []string) (time.Duration, error) {
+	if len(args) < 2 {
+		return 0, fmt.Errorf("macro %v needs time column and interval and optional fill value", name)
+	}
+	interval, err := gtime.ParseInterval(strings.Trim(args[1], `'"`))
+	if err != nil {
+		return 0, fmt.Errorf("error parsing interval %v", args[1])
+	}
+	if interval <= 0 {
+		return 0, fmt.Errorf("invalid interval %v", args[1])
+	}
+	if len(args) == 3 {
+		if err := SetupFillmode(query, interval, args[2]); err != nil {
+			return 0, err
+		}
+	}
+	return interval, nil
+}
+
+// calendarInterval returns the calendar interval the time grouping macros
+// group by, aligned to the calendar settings of the query. ok is false for
+// fixed intervals, for queries without calendar settings, which keep grouping
+// by fixed intervals, and for days in UTC which are 24 hours long.
+func calendarInterval(query *backend.DataQuery, name string, args []string) (intervalv2.CalendarInterval, bool, error) {
+	if len(args) < 2 {
+		return intervalv2.CalendarInterval{}, false, fmt.Errorf("macro %v needs time column and interval and optional fill value", name)
+	}
+	calendar, ok := intervalv2.ParseCalendarInterval(strings.Trim(args[1], `'"`), intervalv2.CalendarOptions{})
+	if !ok {
+		return intervalv2.CalendarInterval{}, false, nil
+	}
+	opts, ok, err := calendarOptions(query)
+	if err != nil || !ok {
+		return intervalv2.CalendarInterval{}, false, err
+	}
+	if calendar.Unit == intervalv2.CalendarDay && opts.Location == time.UTC {
+		return intervalv2.CalendarInterval{}, false, nil
+	}
+	if len(args) == 3 {
+		return intervalv2.CalendarInterval{}, false, fmt.Errorf("macro %v does not support fill values with the calendar interval %v", name, args[1])
+	}
+	calendar.CalendarOptions = opts
+	return calendar, true, nil
+}
+
+// calendarOptions returns the calendar settings of the query: its time zone,
+// the first day of weeks and the first month of fiscal years, 0 for January.
+// ok is false when the query sets none of them.
+func calendarOptions(query *backend.DataQuery) (opts intervalv2.CalendarOptions, ok bool, err error) {
+	var model struct {
+		Timezone             string `json:"timezone"`
+		WeekStart            string `json:"weekStart"`
+		FiscalYearStartMonth *int   `json:"fiscalYearStartMonth"`
+	}
+	if len(query.JSON) > 0 {
+		if err := json.Unmarshal(query.JSON, &model); err != nil {
+			return opts, false, err
+		}
+	}
+	if model.Timezone == "" && model.WeekStart == "" && model.FiscalYearStartMonth == nil {
+		return opts, false, nil
+	}
+
+	opts.Location = time.UTC
+	if model.Timezone != "" && !strings.EqualFold(model.Timezone, "utc") {
+		location, err := time.LoadLocation(model.Timezone)
+		if err != nil {
+			return opts, false, fmt.Errorf("invalid timezone %q: %w", model.Timezone, err)
+		}
+		opts.Location = location
+	}
+	if opts.WeekStart, err = intervalv2.ParseWeekStart(model.WeekStart); err != nil {
+		return opts, false, err
+	}
+	if month := model.FiscalYearStartMonth; month != nil {
+		if *month < 0 || *month > 11 {
+			return opts, false, fmt.Errorf("invalid fiscal year start month %d", *month)
+		}
+		opts.FiscalYearStartMonth = time.Month(*month + 1)
+	}
+	return opts, true, nil
+}
+
+// calendarGroup returns the expression rounding the time column down to the
+// start of its calendar interval, in seconds since the Unix epoch. Intervals
+// of a fixed size are computed with arithmetic, the others are listed in a
+// CASE expression, which leaves the rows after the time range out.
+func calendarGroup(dialect SQLMacroDialect, timeRange backend.TimeRange, column string, calendar intervalv2.CalendarInterval) (string, error) {
+	epoch := dialect.Epoch(column)
+	segments := calendar.Segments(timeRange.From, timeRange.To)
+	if len(segments) > maxCalendarSegments {
+		return "", fmt.Errorf("time range is too large to group by the calendar interval 1%v", calendar.Unit)
+	}
+
+	group := func(segment intervalv2.CalendarSegment) string {
+		if segment.Size == 0 {
+			return fmt.Sprintf("%d", segment.Start.Unix())
+		}
+		shift := segment.Shift.Seconds()
+		if shift == 0 {
+			return dialect.EpochGroup(epoch, segment.Size.Seconds())
+		}
+		return shiftEpoch(dialect.EpochGroup(shiftEpoch(epoch, shift), segment.Size.Seconds()), -shift)
+	}
+	if len(segments) == 1 && segments[0].Size > 0 {
+		return group(segments[0]), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString("CASE")
+	for _, segment := range segments {
+		fmt.Fprintf(&sb, " WHEN %s < %d THEN %s", epoch, segment.End.Unix(), group(segment))
+	}
+	sb.WriteString(" END")
+	return sb.String(), nil
+}
+
+func shiftEpoch(expression string, seconds float64) string {
+	if seconds < 0 {
+		return fmt.Sprintf("(%s - %v)", expression, -seconds)
+	}
+	return fmt.Sprintf("(%s + %v)", expression, seconds)
+}
+
+// searchFilter returns the text searched by the query, which variable queries
+// pass in their searchFilter property.
+func searchFilter(query *backend.DataQuery) (string, error) {
+	var model struct {
+		SearchFilter string `json:"searchFilter"`
+	}
+	if len(query.JSON) == 0 {
+		return "", nil
+	}
+	if err := json.Unmarshal(query.JSON, &model); err != nil {
+		return "", err
+	}
+	return model.SearchFilter, nil
+}
+
+// escapeLikePattern escapes the wildcards of LIKE patterns of the dialect in s.
+func escapeLikePattern(dialect SQLMacroDialect, s string) string {
+	oldnew := []string{searchFilterEscape, searchFilterEscape + searchFilterEscape}
+	for _, wildcard := range append([]string{"%", "_"}, dialect.LikeWildcards()...) {
+		oldnew = append(oldnew, wildcard, searchFilterEscape+wildcard)
+	}
+	return strings.NewReplacer(oldnew...).Replace(s)
+}
@@ -0,0 +1,184 @@
+package sqleng
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMacroDialect struct{}
+
+func (testMacroDialect) TimeGroup(column string, interval time.Duration) string {
+	return fmt.Sprintf("group(%s, %v)", column, interval.Seconds())
+}
+
+func (testMacroDialect) Epoch(column string) string {
+	return fmt.Sprintf("unix(%s)", column)
+}
+
+func (testMacroDialect) EpochGroup(column string, size float64) string {
+	return fmt.Sprintf("epoch(%s, %v)", column, size)
+}
+
+func (testMacroDialect) TimeAlias(expression string) string {
+	return expression + " AS time"
+}
+
+func (testMacroDialect) StringLiteral(s string) string {
+	return "'" + s + "'"
+}
+
+func (testMacroDialect) LikeWildcards() []string {
+	return []string{"["}
+}
+
+func TestEvaluateMacro(t *testing.T) {
+	engine := NewSQLMacroEngineBase()
+
+	tests := []struct {
+		name string
+		args []string
+		sql  string
+	}{
+		{name: "__timeGroup", args: []string{"t", "'5m'"}, sql: "group(t, 300)"},
+		{name: "__timeGroupAlias", args: []string{"t", "5m"}, sql: "group(t, 300) AS time"},
+		{name: "__unixEpochGroup", args: []string{"t", "'1h'"}, sql: "epoch(t, 3600)"},
+		{name: "__unixEpochGroupAlias", args: []string{"t", "'1h'"}, sql: "epoch(t, 3600) AS time"},
+		{name: "__unixEpochGroupMs", args: []string{"t", "'500ms'"}, sql: "epoch(t, 500)"},
+		{name: "__unixEpochGroupMsAlias", args: []string{"t", "'5m'"}, sql: "epoch(t, 300000) AS time"},
+		{name: "__searchLike", args: []string{"name"}, sql: "1=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, ok, err := engine.EvaluateMacro(testMacroDialect{}, backend.TimeRange{}, &backend.DataQuery{}, tt.name, tt.args)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, tt.sql, sql)
+		})
+	}
+
+	t.Run("unknown macros are left to the data source", func(t *testing.T) {
+		_, ok, err := engine.EvaluateMacro(testMacroDialect{}, backend.TimeRange{}, &backend.DataQuery{}, "__timeFilter", []string{"t"})
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		_, _, err := engine.EvaluateMacro(testMacroDialect{}, backend.TimeRange{}, &backend.DataQuery{}, "__timeGroupAlias", []string{"t"})
+		require.EqualError(t, err, "macro __timeGroupAlias needs time column and interval and optional fill value")
+		_, _, err = engine.EvaluateMacro(testMacroDialect{}, backend.TimeRange{}, &backend.DataQuery{}, "__unixEpochGroupMs", []string{"t", "'0s'"})
+		require.EqualError(t, err, "invalid interval '0s'")
+		_, _, err = engine.EvaluateMacro(testMacroDialect{}, backend.TimeRange{}, &backend.DataQuery{}, "__searchLike", []string{""})
+		require.EqualError(t, err, "missing column argument for macro __searchLike")
+	})
+
+	t.Run("calendar intervals", func(t *testing.T) {
+		timeRange := backend.TimeRange{
+			From: time.Date(2022, time.March, 20, 12, 0, 0, 0, time.UTC),
+			To:   time.Date(2022, time.March, 23, 12, 0, 0, 0, time.UTC),
+		}
+		tests := []struct {
+			name     string
+			json     string
+			interval string
+			to       time.Time
+			sql      string
+		}{
+			{name: "days in UTC are fixed", json: `{}`, interval: "'1d'", sql: "group(t, 86400)"},
+			{name: "days in a time zone", json: `{"timezone": "Europe/Berlin"}`, interval: "'1d'",
+				sql: "(epoch((unix(t) + 3600), 86400) - 3600)"},
+			{name: "days west of UTC", json: `{"timezone": "America/New_York"}`, interval: "1d",
+				sql: "(epoch((unix(t) - 14400), 86400) + 14400)"},
+			{name: "weeks are fixed without calendar settings", json: `{}`, interval: "'1w'", sql: "group(t, 604800)"},
+			{name: "weeks start on Monday", json: `{"timezone": "utc"}`, interval: "'1w'",
+				sql: "(epoch((unix(t) + 259200), 604800) - 259200)"},
+			{name: "weeks start on the week start", json: `{"weekStart": "sunday"}`, interval: "'1w'",
+				sql: "(epoch((unix(t) + 345600), 604800) - 345600)"},
+			{name: "months", json: `{"fiscalYearStartMonth": 0}`, interval: "'1M'",
+				sql: "CASE WHEN unix(t) < 1648771200 THEN 1646092800 END"},
+			{name: "fiscal years", json: `{"fiscalYearStartMonth": 3}`, interval: "'1y'", to: time.Date(2022, time.April, 2, 0, 0, 0, 0, time.UTC),
+				sql: "CASE WHEN unix(t) < 1648771200 THEN 1617235200 WHEN unix(t) < 1680307200 THEN 1648771200 END"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				query := &backend.DataQuery{JSON: []byte(tt.json), TimeRange: timeRange}
+				if !tt.to.IsZero() {
+					query.TimeRange.To = tt.to
+				}
+				sql, _, err := engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__timeGroup", []string{"t", tt.interval})
+				require.NoError(t, err)
+				assert.Equal(t, tt.sql, sql)
+			})
+		}
+
+		t.Run("days across DST", func(t *testing.T) {
+			query := &backend.DataQuery{JSON: []byte(`{"timezone": "Europe/Berlin"}`), TimeRange: backend.TimeRange{
+				From: time.Date(2022, time.March, 26, 12, 0, 0, 0, time.UTC),
+				To:   time.Date(2022, time.March, 28, 12, 0, 0, 0, time.UTC),
+			}}
+			sql, _, err := engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__timeGroupAlias", []string{"t", "'1d'"})
+			require.NoError(t, err)
+			assert.Equal(t, "CASE WHEN unix(t) < 1648335600 THEN (epoch((unix(t) + 3600), 86400) - 3600)"+
+				" WHEN unix(t) < 1648418400 THEN 1648335600"+
+				" WHEN unix(t) < 1648504800 THEN (epoch((unix(t) + 7200), 86400) - 7200) END AS time", sql)
+		})
+
+		t.Run("fill values without calendar settings", func(t *testing.T) {
+			query := &backend.DataQuery{JSON: []byte(`{}`), TimeRange: timeRange}
+			sql, _, err := engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__timeGroupAlias", []string{"t", "'1w'", "0"})
+			require.NoError(t, err)
+			assert.Equal(t, "group(t, 604800) AS time", sql)
+		})
+
+		t.Run("invalid settings", func(t *testing.T) {
+			query := &backend.DataQuery{JSON: []byte(`{"timezone": "Mars/Olympus"}`), TimeRange: timeRange}
+			_, _, err := engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__timeGroup", []string{"t", "'1d'"})
+			require.Error(t, err)
+
+			query = &backend.DataQuery{JSON: []byte(`{"weekStart": "friday"}`), TimeRange: timeRange}
+			_, _, err = engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__timeGroup", []string{"t", "'1w'"})
+			require.EqualError(t, err, `invalid week start "friday"`)
+
+			query = &backend.DataQuery{JSON: []byte(`{"weekStart": "monday"}`), TimeRange: timeRange}
+			_, _, err = engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__timeGroup", []string{"t", "'1M'", "0"})
+			require.EqualError(t, err, "macro __timeGroup does not support fill values with the calendar interval '1M'")
+
+			query.TimeRange.From = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+			_, _, err = engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__timeGroup", []string{"t", "'1M'"})
+			require.EqualError(t, err, "time range is too large to group by the calendar interval 1M")
+		})
+	})
+
+	t.Run("search filter escapes LIKE wildcards", func(t *testing.T) {
+		query := &backend.DataQuery{JSON: []byte(`{"searchFilter": "a_b%![c]"}`)}
+		sql, _, err := engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__searchLike", []string{"name"})
+		require.NoError(t, err)
+		assert.Equal(t, "name LIKE 'a!_b!%!!![c]%' ESCAPE '!'", sql)
+	})
+
+	t.Run("fill modes", func(t *testing.T) {
+		for fill, expected := range map[string]map[string]interface{}{
+			"NULL":     {"fillMode": "null"},
+			"previous": {"fillMode": "previous"},
+			"linear":   {"fillMode": "linear"},
+			"1.5":      {"fillMode": "value", "fillValue": 1.5},
+		} {
+			query := &backend.DataQuery{JSON: []byte(`{"rawSql": "SELECT 1"}`)}
+			_, _, err := engine.EvaluateMacro(testMacroDialect{}, query.TimeRange, query, "__unixEpochGroupMsAlias", []string{"t", "'500ms'", fill})
+			require.NoError(t, err)
+
+			var model map[string]interface{}
+			require.NoError(t, json.Unmarshal(query.JSON, &model))
+			assert.Equal(t, true, model["fill"])
+			assert.Equal(t, 0.5, model["fillInterval"])
+			for key, value := range expected {
+				assert.Equal(t, value, model[key], fill)
+			}
+		}
+	})
+}
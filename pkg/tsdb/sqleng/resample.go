@@ -90,8 +90,9 @@ func resample(f *data.Frame, qm dataQueryModel) (*data.Frame, error) {
 	lastSeenRowIdx := -1
 	timeField := f.Fields[tsSchema.TimeIndex]
 
-	startUnixTime := qm.TimeRange.From.Unix() / int64(qm.Interval.Seconds()) * int64(qm.Interval.Seconds())
-	startTime := time.Unix(startUnixTime, 0)
+	// Intervals of the millisecond epoch grouping can be below a second.
+	startUnixNano := qm.TimeRange.From.UnixNano() / qm.Interval.Nanoseconds() * qm.Interval.Nanoseconds()
+	startTime := time.Unix(0, startUnixNano)
 
 	for currentTime := startTime; !currentTime.After(qm.TimeRange.To); currentTime = currentTime.Add(qm.Interval) {
 		initialRowIdx := 0
@@ -134,5 +135,58 @@ func resample(f *data.Frame, qm dataQueryModel) (*data.Frame, error) {
 		resampledRowidx++
 	}
 
+	if qm.fillLinear {
+		interpolateLinear(resampledFrame, tsSchema)
+	}
+
 	return resampledFrame, nil
 }
+
+// interpolateLinear fills the null values of the value fields of a resampled
+// frame by linear interpolation in time between the closest values before and
+// after them. Null values without a value on both sides stay null.
+func interpolateLinear(f *data.Frame, tsSchema data.TimeSeriesSchema) {
+	timeField := f.Fields[tsSchema.TimeIndex]
+	for _, idx := range tsSchema.ValueIndices {
+		field := f.Fields[idx]
+		if field.Type() != data.FieldTypeNullableFloat64 {
+			continue
+		}
+
+		previous := -1
+		for row := 0; row < field.Len(); row++ {
+			if field.At(row).(*float64) == nil {
+				continue
+			}
+			if previous >= 0 && row-previous > 1 {
+				interpolateGap(field, timeField, previous, row)
+			}
+			previous = row
+		}
+	}
+}
+
+// interpolateGap sets the values of the rows between from and to on the line
+// between their values.
+func interpolateGap(field, timeField *data.Field, from, to int) {
+	fromTime, fromOk := timeField.ConcreteAt(from)
+	toTime, toOk := timeField.ConcreteAt(to)
+	if !fromOk || !toOk {
+		return
+	}
+	fromValue, toValue := *field.At(from).(*float64), *field.At(to).(*float64)
+	span := float64(toTime.(time.Time).Sub(fromTime.(time.Time)))
+	if span <= 0 {
+		return
+	}
+
+	for row := from + 1; row < to; row++ {
+		t, ok := timeField.ConcreteAt(row)
+		if !ok {
+			continue
+		}
+		ratio := float64(t.(time.Time).Sub(fromTime.(time.Time))) / span
+		value := fromValue + (toValue-fromValue)*ratio
+		field.Set(row, &value)
+	}
+}
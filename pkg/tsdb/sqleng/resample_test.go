@@ -310,3 +310,56 @@ func TestResampleWide(t *testing.T) {
 		})
 	}
 }
+
+func TestResampleLinear(t *testing.T) {
+	input := data.NewFrame("linear_test",
+		data.NewField("Time", nil, []time.Time{
+			time.Date(2020, 1, 2, 3, 4, 19, 0, time.UTC),
+			time.Date(2020, 1, 2, 3, 4, 20, 0, time.UTC),
+			time.Date(2020, 1, 2, 3, 4, 24, 0, time.UTC),
+		}),
+		data.NewField("Values", nil, []*float64{
+			pointer.Float64(10),
+			pointer.Float64(12),
+			pointer.Float64(20),
+		}))
+
+	frame, err := resample(input, dataQueryModel{
+		FillMissing: &data.FillMissing{Mode: data.FillModeNull},
+		TimeRange: backend.TimeRange{
+			From: time.Date(2020, 1, 2, 3, 4, 18, 0, time.UTC),
+			To:   time.Date(2020, 1, 2, 3, 4, 25, 0, time.UTC),
+		},
+		Interval:   time.Second,
+		fillLinear: true,
+	})
+	require.NoError(t, err)
+
+	// Points before the first and after the last value are not interpolated.
+	expected := []*float64{nil, pointer.Float64(10), pointer.Float64(12), pointer.Float64(14), pointer.Float64(16),
+		pointer.Float64(18), pointer.Float64(20), nil}
+	require.Equal(t, len(expected), frame.Rows())
+	for i, value := range expected {
+		require.Equal(t, value, frame.At(1, i), i)
+	}
+}
+
+func TestResampleSubSecondInterval(t *testing.T) {
+	input := data.NewFrame("ms_test",
+		data.NewField("Time", nil, []time.Time{
+			time.Date(2020, 1, 2, 3, 4, 5, 500*int(time.Millisecond), time.UTC),
+		}),
+		data.NewField("Values", nil, []*float64{pointer.Float64(1)}))
+
+	frame, err := resample(input, dataQueryModel{
+		FillMissing: &data.FillMissing{Mode: data.FillModeNull},
+		TimeRange: backend.TimeRange{
+			From: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			To:   time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC),
+		},
+		Interval: 500 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, frame.Rows())
+	require.Equal(t, pointer.Float64(1), frame.At(1, 1))
+}
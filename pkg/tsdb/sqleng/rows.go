@@ -0,0 +1,141 @@
+package sqleng
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+	"github.com/grafana/grafana/pkg/services/query/resultlimits"
+)
+
+// rowLimits are the limits of the rows read from the result of a query.
+type rowLimits struct {
+	// rows is the maximum number of rows, there is no limit when it is
+	// negative or zero.
+	rows int64
+	// bytes is the maximum estimated size of the rows, there is no limit when
+	// it is zero or negative.
+	bytes int64
+	// cells is the maximum number of cells, rows times columns, there is no
+	// limit when it is zero or negative.
+	cells int64
+}
+
+// withResultLimits lowers the limits to the result limits of a query
+// request, so that rows beyond them aren't read only to be cut off later.
+func (l rowLimits) withResultLimits(headers map[string]string) rowLimits {
+	result := resultlimits.FromHeaders(headers)
+	if result.Bytes > 0 && (l.bytes <= 0 || result.Bytes < l.bytes) {
+		l.bytes = result.Bytes
+	}
+	l.cells = result.Cells
+	return l
+}
+
+// frameFromRows reads the rows into a frame, like sqlutil.FrameFromRows.
+// Reading stops once a limit is reached, leaving the rest of the result
+// unread, and a warning notice tells the result is partial.
+func frameFromRows(rows *sql.Rows, limits rowLimits, converters ...sqlutil.Converter) (*data.Frame, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	scanner, converters, err := sqlutil.MakeScanRow(types, names, converters...)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := sqlutil.NewFrame(names, converters...)
+
+	var count, size int64
+	for rows.Next() {
+		if limits.rows > 0 && count == limits.rows {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text:     fmt.Sprintf("Results have been limited to %v rows because the SQL row limit was reached", limits.rows),
+			})
+			break
+		}
+
+		if limits.cells > 0 && (count+1)*int64(len(names)) > limits.cells {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text: fmt.Sprintf("Results have been limited to %v rows because the query result limit of %v cells was reached",
+					count, limits.cells),
+			})
+			break
+		}
+
+		r := scanner.NewScannableRow()
+		if err := rows.Scan(r...); err != nil {
+			return nil, err
+		}
+
+		size += rowSize(r)
+		if limits.bytes > 0 && size > limits.bytes {
+			frame.AppendNotices(data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text: fmt.Sprintf("Results have been limited to %v rows because the SQL row bytes limit of %v was reached",
+					count, limits.bytes),
+			})
+			break
+		}
+
+		if err := sqlutil.Append(frame, r, converters...); err != nil {
+			return nil, err
+		}
+
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// rowSize estimates the memory used by the scanned values of a row.
+func rowSize(row []interface{}) int64 {
+	var size int64
+	for _, value := range row {
+		size += valueSize(reflect.ValueOf(value))
+	}
+	return size
+}
+
+func valueSize(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return int64(v.Type().Size())
+		}
+		return int64(v.Type().Size()) + valueSize(v.Elem())
+	case reflect.String:
+		return int64(v.Type().Size()) + int64(v.Len())
+	case reflect.Slice:
+		return int64(v.Type().Size()) + int64(v.Len())*int64(v.Type().Elem().Size())
+	case reflect.Struct:
+		// Only the strings of structs such as sql.NullString are counted
+		// besides their size, their pointers aren't followed.
+		size := int64(v.Type().Size())
+		for i := 0; i < v.NumField(); i++ {
+			if field := v.Field(i); field.Kind() == reflect.String {
+				size += int64(field.Len())
+			}
+		}
+		return size
+	default:
+		return int64(v.Type().Size())
+	}
+}
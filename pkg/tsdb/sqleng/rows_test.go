@@ -0,0 +1,109 @@
+package sqleng
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana-plugin-sdk-go/data/sqlutil"
+	"github.com/grafana/grafana/pkg/services/query/resultlimits"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameFromRows(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	_, err = db.Exec("CREATE TABLE metrics (name TEXT, value INTEGER)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO metrics VALUES ('first', 1), ('second', 2), ('third', 3)")
+	require.NoError(t, err)
+
+	query := func(t *testing.T, limits rowLimits) *data.Frame {
+		t.Helper()
+		rows, err := db.Query("SELECT name, value FROM metrics ORDER BY value")
+		require.NoError(t, err)
+		defer func() { require.NoError(t, rows.Close()) }()
+
+		// SQLite doesn't report the scan types of columns before reading a
+		// row, so they are scanned as strings.
+		frame, err := frameFromRows(rows, limits, stringConverter("TEXT"), stringConverter("INTEGER"))
+		require.NoError(t, err)
+		return frame
+	}
+
+	t.Run("reads all the rows without limits", func(t *testing.T) {
+		frame := query(t, rowLimits{})
+		require.Equal(t, 3, frame.Rows())
+		assert.Nil(t, frame.Meta)
+	})
+
+	t.Run("stops at the row limit", func(t *testing.T) {
+		frame := query(t, rowLimits{rows: 2})
+		require.Equal(t, 2, frame.Rows())
+		require.Len(t, frame.Meta.Notices, 1)
+		assert.Equal(t, "Results have been limited to 2 rows because the SQL row limit was reached", frame.Meta.Notices[0].Text)
+	})
+
+	t.Run("stops at the row bytes limit", func(t *testing.T) {
+		size := rowSize([]interface{}{&sql.NullString{String: "first", Valid: true}, &sql.NullString{String: "1", Valid: true}})
+		frame := query(t, rowLimits{rows: 10, bytes: size + 1})
+		require.Equal(t, 1, frame.Rows())
+		require.Len(t, frame.Meta.Notices, 1)
+		assert.Equal(t, data.NoticeSeverityWarning, frame.Meta.Notices[0].Severity)
+		assert.Contains(t, frame.Meta.Notices[0].Text, "limited to 1 rows because the SQL row bytes limit")
+	})
+
+	t.Run("stops at the result cells limit", func(t *testing.T) {
+		frame := query(t, rowLimits{rows: 10, cells: 5})
+		require.Equal(t, 2, frame.Rows())
+		require.Len(t, frame.Meta.Notices, 1)
+		assert.Equal(t, "Results have been limited to 2 rows because the query result limit of 5 cells was reached",
+			frame.Meta.Notices[0].Text)
+	})
+}
+
+func TestRowLimits_WithResultLimits(t *testing.T) {
+	limits := rowLimits{rows: 100, bytes: 1000}
+	assert.Equal(t, limits, limits.withResultLimits(nil))
+	assert.Equal(t, rowLimits{rows: 100, bytes: 500, cells: 20}, limits.withResultLimits(map[string]string{
+		resultlimits.BytesHeader: "500",
+		resultlimits.CellsHeader: "20",
+	}))
+	assert.Equal(t, limits, limits.withResultLimits(map[string]string{resultlimits.BytesHeader: "2000"}))
+	assert.Equal(t, rowLimits{rows: 100, bytes: 2000}, rowLimits{rows: 100}.withResultLimits(map[string]string{
+		resultlimits.BytesHeader: "2000",
+	}))
+}
+
+func TestRowSize(t *testing.T) {
+	short := rowSize([]interface{}{stringPointer("a")})
+	long := rowSize([]interface{}{stringPointer("abcdefghij")})
+	assert.Equal(t, int64(9), long-short)
+
+	nullString := rowSize([]interface{}{&sql.NullString{String: "abc", Valid: true}})
+	assert.Greater(t, nullString, rowSize([]interface{}{&sql.NullString{}}))
+}
+
+func stringConverter(typeName string) sqlutil.Converter {
+	return sqlutil.Converter{
+		InputScanType: reflect.TypeOf(sql.NullString{}),
+		InputTypeName: typeName,
+		FrameConverter: sqlutil.FrameConverter{
+			FieldType: data.FieldTypeNullableString,
+			ConverterFunc: func(in interface{}) (interface{}, error) {
+				ns := in.(*sql.NullString)
+				if !ns.Valid {
+					return nil, nil
+				}
+				return &ns.String, nil
+			},
+		},
+	}
+}
+
+func stringPointer(s string) *string { return &s }
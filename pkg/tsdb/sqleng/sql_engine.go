@@ -84,6 +84,7 @@ type DataPluginConfiguration struct {
 	TimeColumnNames   []string
 	MetricColumnTypes []string
 	RowLimit          int64
+	RowBytesLimit     int64
 }
 type DataSourceHandler struct {
 	macroEngine            SQLMacroEngine
@@ -93,7 +94,7 @@ type DataSourceHandler struct {
 	metricColumnTypes      []string
 	log                    log.Logger
 	dsInfo                 DataSourceInfo
-	rowLimit               int64
+	rowLimits              rowLimits
 }
 type QueryJson struct {
 	RawSql       string  `json:"rawSql"`
@@ -131,7 +132,7 @@ func NewQueryDataHandler(config DataPluginConfiguration, queryResultTransformer
 		timeColumnNames:        []string{"time"},
 		log:                    log,
 		dsInfo:                 config.DSInfo,
-		rowLimit:               config.RowLimit,
+		rowLimits:              rowLimits{rows: config.RowLimit, bytes: config.RowBytesLimit},
 	}
 
 	if len(config.TimeColumnNames) > 0 {
@@ -174,6 +175,7 @@ func (e *DataSourceHandler) QueryData(ctx context.Context, req *backend.QueryDat
 	result := backend.NewQueryDataResponse()
 	ch := make(chan DBDataResponse, len(req.Queries))
 	var wg sync.WaitGroup
+	limits := e.rowLimits.withResultLimits(req.Headers)
 	// Execute each query in a goroutine and wait for them to finish afterwards
 	for _, query := range req.Queries {
 		queryjson := QueryJson{
@@ -189,7 +191,7 @@ func (e *DataSourceHandler) QueryData(ctx context.Context, req *backend.QueryDat
 		}
 
 		wg.Add(1)
-		go e.executeQuery(query, &wg, ctx, ch, queryjson)
+		go e.executeQuery(query, &wg, ctx, ch, queryjson, limits)
 	}
 
 	wg.Wait()
@@ -205,7 +207,7 @@ func (e *DataSourceHandler) QueryData(ctx context.Context, req *backend.QueryDat
 }
 
 func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitGroup, queryContext context.Context,
-	ch chan DBDataResponse, queryJson QueryJson) {
+	ch chan DBDataResponse, queryJson QueryJson, limits rowLimits) {
 	defer wg.Done()
 	queryResult := DBDataResponse{
 		dataResponse: backend.DataResponse{},
@@ -279,7 +281,7 @@ func (e *DataSourceHandler) executeQuery(query backend.DataQuery, wg *sync.WaitG
 
 	// Convert row.Rows to dataframe
 	stringConverters := e.queryResultTransformer.GetConverterList()
-	frame, err := sqlutil.FrameFromRows(rows.Rows, e.rowLimit, sqlutil.ToConverters(stringConverters...)...)
+	frame, err := frameFromRows(rows.Rows, limits, sqlutil.ToConverters(stringConverters...)...)
 	if err != nil {
 		errAppendDebug("convert frame from rows error", err, interpolatedQuery)
 		return
@@ -420,6 +422,10 @@ func (e *DataSourceHandler) newProcessCfg(query backend.DataQuery, queryContext
 			qm.FillMissing.Mode = data.FillModeNull
 		case "previous":
 			qm.FillMissing.Mode = data.FillModePrevious
+		case "linear":
+			// Missing points are null when resampling, and interpolated after.
+			qm.FillMissing.Mode = data.FillModeNull
+			qm.fillLinear = true
 		case "value":
 			qm.FillMissing.Mode = data.FillModeValue
 			qm.FillMissing.Value = queryJson.FillValue
@@ -487,6 +493,7 @@ type dataQueryModel struct {
 	TimeRange         backend.TimeRange
 	FillMissing       *data.FillMissing // property not set until after Interpolate()
 	Interval          time.Duration
+	fillLinear        bool
 	columnNames       []string
 	columnTypes       []*sql.ColumnType
 	timeIndex         int
@@ -968,6 +975,8 @@ func SetupFillmode(query *backend.DataQuery, interval time.Duration, fillmode st
 		rawQueryProp["fillMode"] = "null"
 	case "previous":
 		rawQueryProp["fillMode"] = "previous"
+	case "linear":
+		rawQueryProp["fillMode"] = "linear"
 	default:
 		rawQueryProp["fillMode"] = "value"
 		floatVal, err := strconv.ParseFloat(fillmode, 64)
@@ -0,0 +1,251 @@
+package tempo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// traceQLQueryType is the query type of TraceQL searches, the queries of
+// other types look up a trace by its ID.
+const traceQLQueryType = "traceql"
+
+const (
+	defaultSearchLimit     = 20
+	defaultSpansPerSpanSet = 3
+)
+
+// The search response of Tempo, with the spans in the OTLP JSON format.
+type searchResponse struct {
+	Traces []searchTrace `json:"traces"`
+}
+
+type searchTrace struct {
+	TraceID           string          `json:"traceID"`
+	RootServiceName   string          `json:"rootServiceName"`
+	RootTraceName     string          `json:"rootTraceName"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	DurationMs        float64         `json:"durationMs"`
+	SpanSet           *searchSpanSet  `json:"spanSet"`
+	SpanSets          []searchSpanSet `json:"spanSets"`
+}
+
+type searchSpanSet struct {
+	Spans   []searchSpan `json:"spans"`
+	Matched int64        `json:"matched"`
+}
+
+type searchSpan struct {
+	SpanID            string            `json:"spanID"`
+	StartTimeUnixNano string            `json:"startTimeUnixNano"`
+	DurationNanos     string            `json:"durationNanos"`
+	Attributes        []searchAttribute `json:"attributes"`
+}
+
+type searchAttribute struct {
+	Key   string                     `json:"key"`
+	Value map[string]json.RawMessage `json:"value"`
+}
+
+// value returns the value of the attribute, whatever its OTLP type, as a
+// string.
+func (a searchAttribute) value() string {
+	for _, raw := range a.Value {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return s
+		}
+		return string(raw)
+	}
+	return ""
+}
+
+func (s *Service) searchTraceQL(ctx context.Context, dsInfo *datasourceInfo, query backend.DataQuery, model *QueryModel) backend.DataResponse {
+	if model.Limit < 0 || model.SpansPerSpanSet < 0 {
+		return backend.DataResponse{Error: fmt.Errorf("invalid search limit %d or spans per span set %d", model.Limit, model.SpansPerSpanSet)}
+	}
+
+	request, err := s.createSearchRequest(ctx, dsInfo, query, model)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+
+	resp, err := dsInfo.HTTPClient.Do(request)
+	if err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed get to tempo: %w", err)}
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			s.tlog.Warn("failed to close response body", "err", err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return backend.DataResponse{Error: fmt.Errorf("failed to search traces: %s Status: %s Body: %s", model.TraceID, resp.Status, string(body))}
+	}
+
+	var search searchResponse
+	if err := json.Unmarshal(body, &search); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to read the tempo search response: %w", err)}
+	}
+
+	frames, err := searchToFrames(&search)
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+	for _, frame := range frames {
+		frame.RefID = query.RefID
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+func (s *Service) createSearchRequest(ctx context.Context, dsInfo *datasourceInfo, query backend.DataQuery, model *QueryModel) (*http.Request, error) {
+	limit := defaultSearchLimit
+	if model.Limit > 0 {
+		limit = model.Limit
+	}
+	spansPerSpanSet := defaultSpansPerSpanSet
+	if model.SpansPerSpanSet > 0 {
+		spansPerSpanSet = model.SpansPerSpanSet
+	}
+
+	params := url.Values{}
+	params.Set("q", model.TraceID)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("spss", strconv.Itoa(spansPerSpanSet))
+	if !query.TimeRange.From.IsZero() && !query.TimeRange.To.IsZero() {
+		params.Set("start", strconv.FormatInt(query.TimeRange.From.Unix(), 10))
+		params.Set("end", strconv.FormatInt(query.TimeRange.To.Unix(), 10))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", dsInfo.URL+"/api/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	s.tlog.Debug("Tempo search request", "url", req.URL.String())
+	return req, nil
+}
+
+// searchToFrames returns a "Traces" frame with a row per trace, followed by a
+// "Spans" frame per span set of the traces. The span frames are nested in the
+// rows of the traces through the traceID in their custom metadata, so that the
+// trace list can expand a trace into its matched spans.
+func searchToFrames(search *searchResponse) (data.Frames, error) {
+	traces := data.NewFrame("Traces",
+		data.NewField("traceID", nil, []string{}),
+		data.NewField("startTime", nil, []time.Time{}),
+		data.NewField("traceService", nil, []string{}),
+		data.NewField("traceName", nil, []string{}),
+		data.NewField("traceDuration", nil, []float64{}).SetConfig(&data.FieldConfig{Unit: "ms"}),
+		data.NewField("matched", nil, []int64{}),
+	)
+	traces.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeTable}
+	frames := data.Frames{traces}
+
+	for _, trace := range search.Traces {
+		start, err := parseUnixNano(trace.StartTimeUnixNano)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time of trace %s: %w", trace.TraceID, err)
+		}
+
+		spanSets := trace.SpanSets
+		if len(spanSets) == 0 && trace.SpanSet != nil {
+			spanSets = []searchSpanSet{*trace.SpanSet}
+		}
+		var matched int64
+		for _, spanSet := range spanSets {
+			matched += spanSet.Matched
+			spans, err := spanSetToFrame(trace.TraceID, spanSet)
+			if err != nil {
+				return nil, err
+			}
+			frames = append(frames, spans)
+		}
+
+		traces.AppendRow(trace.TraceID, start, trace.RootServiceName, trace.RootTraceName, trace.DurationMs, matched)
+	}
+
+	return frames, nil
+}
+
+func spanSetToFrame(traceID string, spanSet searchSpanSet) (*data.Frame, error) {
+	// Every attribute of the spans is a column.
+	keys := []string{}
+	seen := map[string]struct{}{}
+	for _, span := range spanSet.Spans {
+		for _, attribute := range span.Attributes {
+			if _, ok := seen[attribute.Key]; !ok {
+				seen[attribute.Key] = struct{}{}
+				keys = append(keys, attribute.Key)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	frame := data.NewFrame("Spans",
+		data.NewField("traceID", nil, []string{}),
+		data.NewField("spanID", nil, []string{}),
+		data.NewField("spanStartTime", nil, []time.Time{}),
+		data.NewField("duration", nil, []float64{}).SetConfig(&data.FieldConfig{Unit: "ms"}),
+	)
+	for _, key := range keys {
+		frame.Fields = append(frame.Fields, data.NewField(key, nil, []string{}))
+	}
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTable,
+		Custom:                 map[string]interface{}{"traceID": traceID, "matched": spanSet.Matched},
+	}
+
+	for _, span := range spanSet.Spans {
+		start, err := parseUnixNano(span.StartTimeUnixNano)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start time of span %s: %w", span.SpanID, err)
+		}
+		var duration float64
+		if span.DurationNanos != "" {
+			nanos, err := strconv.ParseInt(span.DurationNanos, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration of span %s: %w", span.SpanID, err)
+			}
+			duration = float64(nanos) / float64(time.Millisecond)
+		}
+
+		attributes := make(map[string]string, len(span.Attributes))
+		for _, attribute := range span.Attributes {
+			attributes[attribute.Key] = attribute.value()
+		}
+		row := []interface{}{traceID, span.SpanID, start, duration}
+		for _, key := range keys {
+			row = append(row, attributes[key])
+		}
+		frame.AppendRow(row...)
+	}
+
+	return frame, nil
+}
+
+func parseUnixNano(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	nanos, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nanos).UTC(), nil
+}
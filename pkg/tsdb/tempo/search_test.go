@@ -0,0 +1,112 @@
+package tempo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/stretchr/testify/require"
+)
+
+const searchResponseBody = `{
+	"traces": [
+		{
+			"traceID": "2f3e0cee77ae5dc9c17ade3689eb2e54",
+			"rootServiceName": "shop-backend",
+			"rootTraceName": "update-billing",
+			"startTimeUnixNano": "1684778327699392724",
+			"durationMs": 557,
+			"spanSets": [
+				{
+					"spans": [
+						{
+							"spanID": "563d623c76514f8e",
+							"startTimeUnixNano": "1684778327735077898",
+							"durationNanos": "446979497",
+							"attributes": [
+								{"key": "status", "value": {"stringValue": "error"}},
+								{"key": "http.status_code", "value": {"intValue": "500"}}
+							]
+						}
+					],
+					"matched": 1
+				}
+			]
+		},
+		{
+			"traceID": "a4b8e3b1f0c6a2d2",
+			"rootServiceName": "shop-frontend",
+			"rootTraceName": "checkout",
+			"startTimeUnixNano": "1684778327000000000",
+			"durationMs": 12,
+			"spanSet": {"spans": [{"spanID": "1", "startTimeUnixNano": "1684778327000000000", "durationNanos": "1000000"}], "matched": 3}
+		}
+	]
+}`
+
+func TestSearchTraceQL(t *testing.T) {
+	var requested *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r
+		_, _ = w.Write([]byte(searchResponseBody))
+	}))
+	defer server.Close()
+
+	service := &Service{tlog: log.New("tempo-test")}
+	dsInfo := &datasourceInfo{HTTPClient: server.Client(), URL: server.URL}
+	query := backend.DataQuery{
+		RefID:     "A",
+		QueryType: traceQLQueryType,
+		TimeRange: backend.TimeRange{From: time.Unix(1684778000, 0), To: time.Unix(1684779000, 0)},
+	}
+
+	res := service.searchTraceQL(context.Background(), dsInfo, query, &QueryModel{TraceID: `{ status = error }`, Limit: 5})
+	require.NoError(t, res.Error)
+
+	require.Equal(t, "/api/search", requested.URL.Path)
+	require.Equal(t, `{ status = error }`, requested.URL.Query().Get("q"))
+	require.Equal(t, "5", requested.URL.Query().Get("limit"))
+	require.Equal(t, "3", requested.URL.Query().Get("spss"))
+	require.Equal(t, "1684778000", requested.URL.Query().Get("start"))
+	require.Equal(t, "1684779000", requested.URL.Query().Get("end"))
+
+	require.Len(t, res.Frames, 3)
+	traces := res.Frames[0]
+	require.Equal(t, "Traces", traces.Name)
+	require.Equal(t, "A", traces.RefID)
+	require.Equal(t, 2, traces.Rows())
+	require.Equal(t, "shop-backend", traces.Fields[2].At(0))
+	require.Equal(t, time.Unix(0, 1684778327699392724).UTC(), traces.Fields[1].At(0))
+	require.Equal(t, int64(3), traces.Fields[5].At(1))
+
+	spans := res.Frames[1]
+	require.Equal(t, "Spans", spans.Name)
+	require.Equal(t, "2f3e0cee77ae5dc9c17ade3689eb2e54", spans.Meta.Custom.(map[string]interface{})["traceID"])
+	require.Equal(t, []string{"traceID", "spanID", "spanStartTime", "duration", "http.status_code", "status"}, fieldNames(spans))
+	require.InDelta(t, 446.979497, spans.Fields[3].At(0), 1e-9)
+	require.Equal(t, "500", spans.Fields[4].At(0))
+	require.Equal(t, "error", spans.Fields[5].At(0))
+
+	require.Equal(t, "a4b8e3b1f0c6a2d2", res.Frames[2].Fields[0].At(0))
+}
+
+func TestSearchTraceQLError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid TraceQL query", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	service := &Service{tlog: log.New("tempo-test")}
+	dsInfo := &datasourceInfo{HTTPClient: server.Client(), URL: server.URL}
+
+	res := service.searchTraceQL(context.Background(), dsInfo, backend.DataQuery{RefID: "A"}, &QueryModel{TraceID: "{"})
+	require.Error(t, res.Error)
+	require.Contains(t, res.Error.Error(), "invalid TraceQL query")
+
+	res = service.searchTraceQL(context.Background(), dsInfo, backend.DataQuery{RefID: "A"}, &QueryModel{TraceID: "{}", Limit: -1})
+	require.EqualError(t, res.Error, "invalid search limit -1 or spans per span set 0")
+}
@@ -0,0 +1,255 @@
+package tempo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/plugins/adapters"
+)
+
+// serviceMapQueryType is the query type of service graphs, built from the
+// metrics the metrics-generator of Tempo writes to the Prometheus data source
+// linked in the serviceMap settings.
+const serviceMapQueryType = "serviceMap"
+
+// The service graph metrics, queried as rates over the range of the query by
+// client and server.
+const (
+	serviceGraphRequestsMetric       = "traces_service_graph_request_total"
+	serviceGraphFailedRequestsMetric = "traces_service_graph_request_failed_total"
+	serviceGraphLatencySumMetric     = "traces_service_graph_request_server_seconds_sum"
+	serviceGraphLatencyCountMetric   = "traces_service_graph_request_server_seconds_count"
+)
+
+var serviceGraphMetrics = []string{
+	serviceGraphRequestsMetric,
+	serviceGraphFailedRequestsMetric,
+	serviceGraphLatencySumMetric,
+	serviceGraphLatencyCountMetric,
+}
+
+// secretsDecrypter decrypts the secure settings of the linked Prometheus data
+// source.
+type secretsDecrypter interface {
+	DecryptedValues(ds *models.DataSource) map[string]string
+}
+
+type serviceGraphEdge struct {
+	client, server string
+}
+
+// serviceGraphRates are the rates per second of an edge.
+type serviceGraphRates struct {
+	requests     float64
+	failed       float64
+	latencySum   float64
+	latencyCount float64
+}
+
+func (r *serviceGraphRates) add(o serviceGraphRates) {
+	r.requests += o.requests
+	r.failed += o.failed
+	r.latencySum += o.latencySum
+	r.latencyCount += o.latencyCount
+}
+
+// latencyMs is the average latency of the requests in milliseconds.
+func (r serviceGraphRates) latencyMs() float64 {
+	if r.latencyCount == 0 {
+		return 0
+	}
+	return r.latencySum / r.latencyCount * 1000
+}
+
+func (s *Service) queryServiceGraph(ctx context.Context, pluginCtx backend.PluginContext, dsInfo *datasourceInfo, query backend.DataQuery) backend.DataResponse {
+	if dsInfo.ServiceMapDatasourceUID == "" {
+		return backend.DataResponse{Error: fmt.Errorf("no Prometheus data source is linked for the service graph")}
+	}
+	if s.dataSourceCache == nil || s.secrets == nil || s.prometheus == nil {
+		return backend.DataResponse{Error: fmt.Errorf("service graphs are not available")}
+	}
+
+	// The linked data source is looked up as the user, like the data sources of
+	// the queries of the user, so that their permissions apply.
+	user, err := adapters.SignedInUserFromBackendUser(ctx, pluginCtx.OrgID, pluginCtx.User)
+	if err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to get the user of the service graph query: %w", err)}
+	}
+	ds, err := s.dataSourceCache.GetDatasourceByUID(ctx, dsInfo.ServiceMapDatasourceUID, user, false)
+	if err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to get the service graph data source: %w", err)}
+	}
+	if ds.Type != models.DS_PROMETHEUS {
+		return backend.DataResponse{Error: fmt.Errorf("the service graph data source %q is not a Prometheus data source", ds.Name)}
+	}
+	settings, err := adapters.ModelToInstanceSettings(ds, func(map[string][]byte) map[string]string {
+		return s.secrets.DecryptedValues(ds)
+	})
+	if err != nil {
+		return backend.DataResponse{Error: err}
+	}
+
+	req := &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{
+			OrgID:                      pluginCtx.OrgID,
+			User:                       pluginCtx.User,
+			PluginID:                   ds.Type,
+			DataSourceInstanceSettings: settings,
+		},
+		Headers: s.serviceGraphHeaders(ctx, ds, user),
+	}
+	for _, metric := range serviceGraphMetrics {
+		model, err := json.Marshal(map[string]interface{}{
+			"refId":   metric,
+			"expr":    fmt.Sprintf("sum by (client, server) (rate(%s[$__range]))", metric),
+			"instant": true,
+			"range":   false,
+		})
+		if err != nil {
+			return backend.DataResponse{Error: err}
+		}
+		req.Queries = append(req.Queries, backend.DataQuery{
+			RefID:         metric,
+			JSON:          model,
+			TimeRange:     query.TimeRange,
+			Interval:      query.Interval,
+			MaxDataPoints: query.MaxDataPoints,
+		})
+	}
+
+	res, err := s.prometheus.QueryData(ctx, req)
+	if err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to query the service graph metrics: %w", err)}
+	}
+	edges := map[serviceGraphEdge]*serviceGraphRates{}
+	for _, metric := range serviceGraphMetrics {
+		metricRes := res.Responses[metric]
+		if metricRes.Error != nil {
+			return backend.DataResponse{Error: fmt.Errorf("failed to query %s: %w", metric, metricRes.Error)}
+		}
+		joinServiceGraphFrames(edges, metric, metricRes.Frames)
+	}
+
+	frames := serviceGraphFrames(edges)
+	for _, frame := range frames {
+		frame.RefID = query.RefID
+	}
+	return backend.DataResponse{Frames: frames}
+}
+
+// serviceGraphHeaders returns the OAuth headers of the user when the linked
+// data source forwards the OAuth identity, as query requests do.
+func (s *Service) serviceGraphHeaders(ctx context.Context, ds *models.DataSource, user *models.SignedInUser) map[string]string {
+	headers := map[string]string{}
+	if s.oAuthTokenService == nil || !s.oAuthTokenService.IsOAuthPassThruEnabled(ds) {
+		return headers
+	}
+	if token := s.oAuthTokenService.GetCurrentOAuthToken(ctx, user); token != nil {
+		headers["Authorization"] = fmt.Sprintf("%s %s", token.Type(), token.AccessToken)
+		if idToken, ok := token.Extra("id_token").(string); ok && idToken != "" {
+			headers["X-ID-Token"] = idToken
+		}
+	}
+	return headers
+}
+
+// joinServiceGraphFrames adds the last values of the series of a metric to
+// the rates of the edges of their client and server labels.
+func joinServiceGraphFrames(edges map[serviceGraphEdge]*serviceGraphRates, metric string, frames data.Frames) {
+	for _, frame := range frames {
+		for _, field := range frame.Fields {
+			if field.Type() != data.FieldTypeFloat64 || field.Len() == 0 {
+				continue
+			}
+			edge := serviceGraphEdge{client: field.Labels["client"], server: field.Labels["server"]}
+			if edge.client == "" || edge.server == "" {
+				continue
+			}
+			rates, ok := edges[edge]
+			if !ok {
+				rates = &serviceGraphRates{}
+				edges[edge] = rates
+			}
+
+			value := field.At(field.Len() - 1).(float64)
+			switch metric {
+			case serviceGraphRequestsMetric:
+				rates.requests += value
+			case serviceGraphFailedRequestsMetric:
+				rates.failed += value
+			case serviceGraphLatencySumMetric:
+				rates.latencySum += value
+			case serviceGraphLatencyCountMetric:
+				rates.latencyCount += value
+			}
+		}
+	}
+}
+
+// serviceGraphFrames returns the nodes and edges frames of the node graph
+// panel. The stats of a node are those of the requests it served.
+func serviceGraphFrames(edges map[serviceGraphEdge]*serviceGraphRates) data.Frames {
+	keys := make([]serviceGraphEdge, 0, len(edges))
+	for edge := range edges {
+		keys = append(keys, edge)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].client != keys[j].client {
+			return keys[i].client < keys[j].client
+		}
+		return keys[i].server < keys[j].server
+	})
+
+	nodes := map[string]*serviceGraphRates{}
+	edgesFrame := data.NewFrame("edges",
+		data.NewField("id", nil, []string{}),
+		data.NewField("source", nil, []string{}),
+		data.NewField("target", nil, []string{}),
+		data.NewField("mainStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Requests", Unit: "reqps"}),
+		data.NewField("secondaryStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Average response time", Unit: "ms"}),
+	)
+	edgesFrame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeNodeGraph}
+	for _, edge := range keys {
+		rates := edges[edge]
+		if _, ok := nodes[edge.client]; !ok {
+			nodes[edge.client] = &serviceGraphRates{}
+		}
+		if _, ok := nodes[edge.server]; !ok {
+			nodes[edge.server] = &serviceGraphRates{}
+		}
+		nodes[edge.server].add(*rates)
+		edgesFrame.AppendRow(edge.client+"_"+edge.server, edge.client, edge.server, rates.requests, rates.latencyMs())
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nodesFrame := data.NewFrame("nodes",
+		data.NewField("id", nil, []string{}),
+		data.NewField("title", nil, []string{}),
+		data.NewField("mainStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Average response time", Unit: "ms"}),
+		data.NewField("secondaryStat", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Requests", Unit: "reqps"}),
+		data.NewField("arc__success", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Success", Color: map[string]interface{}{"mode": "fixed", "fixedColor": "green"}}),
+		data.NewField("arc__failed", nil, []float64{}).SetConfig(&data.FieldConfig{DisplayName: "Failed", Color: map[string]interface{}{"mode": "fixed", "fixedColor": "red"}}),
+	)
+	nodesFrame.Meta = &data.FrameMeta{PreferredVisualization: data.VisTypeNodeGraph}
+	for _, name := range names {
+		rates := nodes[name]
+		success, failed := 1.0, 0.0
+		if rates.requests > 0 {
+			failed = rates.failed / rates.requests
+			success = 1 - failed
+		}
+		nodesFrame.AppendRow(name, name, rates.latencyMs(), rates.requests, success, failed)
+	}
+
+	return data.Frames{nodesFrame, edgesFrame}
+}
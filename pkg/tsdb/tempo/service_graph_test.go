@@ -0,0 +1,207 @@
+package tempo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// fakeDataSources denies the data source to the users with the login
+// "denied", like data source permissions would.
+type fakeDataSources struct {
+	ds *models.DataSource
+}
+
+func (f *fakeDataSources) GetDatasource(context.Context, int64, *models.SignedInUser, bool) (*models.DataSource, error) {
+	return nil, models.ErrDataSourceNotFound
+}
+
+func (f *fakeDataSources) GetDatasourceByUID(_ context.Context, uid string, user *models.SignedInUser, _ bool) (*models.DataSource, error) {
+	if user.Login == "denied" {
+		return nil, models.ErrDataSourceAccessDenied
+	}
+	if uid != f.ds.Uid || user.OrgId != f.ds.OrgId {
+		return nil, models.ErrDataSourceNotFound
+	}
+	return f.ds, nil
+}
+
+func (f *fakeDataSources) DecryptedValues(*models.DataSource) map[string]string {
+	return map[string]string{"basicAuthPassword": "secret"}
+}
+
+type fakeOAuthTokenService struct {
+	token *oauth2.Token
+}
+
+func (ts *fakeOAuthTokenService) GetCurrentOAuthToken(context.Context, *models.SignedInUser) *oauth2.Token {
+	return ts.token
+}
+
+func (ts *fakeOAuthTokenService) IsOAuthPassThruEnabled(ds *models.DataSource) bool {
+	return ds.JsonData != nil && ds.JsonData.Get("oauthPassThru").MustBool()
+}
+
+// addUserHandlers answers the user lookups of the service graph queries with
+// users of organization 1.
+func addUserHandlers(t *testing.T) {
+	logins := []string{"user", "denied"}
+	bus.AddHandler("test", func(ctx context.Context, query *models.GetUserByLoginQuery) error {
+		for i, login := range logins {
+			if login == query.LoginOrEmail {
+				query.Result = &models.User{Id: int64(i + 1), Login: login}
+				return nil
+			}
+		}
+		return models.ErrUserNotFound
+	})
+	bus.AddHandler("test", func(ctx context.Context, query *models.GetSignedInUserQuery) error {
+		query.Result = &models.SignedInUser{UserId: query.UserId, OrgId: 1, Login: logins[query.UserId-1]}
+		if query.OrgId != 1 {
+			query.Result.OrgId = -1
+		}
+		return nil
+	})
+	t.Cleanup(bus.ClearBusHandlers)
+}
+
+type fakePrometheus struct {
+	req    *backend.QueryDataRequest
+	values map[string][]serviceGraphSample
+}
+
+type serviceGraphSample struct {
+	client, server string
+	value          float64
+}
+
+func (f *fakePrometheus) QueryData(_ context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	f.req = req
+	res := backend.NewQueryDataResponse()
+	for _, query := range req.Queries {
+		var frames data.Frames
+		for _, sample := range f.values[query.RefID] {
+			frames = append(frames, data.NewFrame("",
+				data.NewField("Time", nil, []time.Time{query.TimeRange.To}),
+				data.NewField("Value", data.Labels{"client": sample.client, "server": sample.server}, []float64{sample.value})))
+		}
+		res.Responses[query.RefID] = backend.DataResponse{Frames: frames}
+	}
+	return res, nil
+}
+
+func TestQueryServiceGraph(t *testing.T) {
+	addUserHandlers(t)
+	prometheus := &fakePrometheus{values: map[string][]serviceGraphSample{
+		serviceGraphRequestsMetric: {
+			{client: "user", server: "app", value: 10},
+			{client: "app", server: "db", value: 4},
+		},
+		serviceGraphFailedRequestsMetric: {
+			{client: "user", server: "app", value: 1},
+		},
+		serviceGraphLatencySumMetric: {
+			{client: "user", server: "app", value: 2},
+			{client: "app", server: "db", value: 0.2},
+		},
+		serviceGraphLatencyCountMetric: {
+			{client: "user", server: "app", value: 10},
+			{client: "app", server: "db", value: 4},
+		},
+	}}
+	dataSources := &fakeDataSources{ds: &models.DataSource{
+		Uid:      "prom",
+		OrgId:    1,
+		Type:     models.DS_PROMETHEUS,
+		Url:      "http://prometheus",
+		JsonData: simplejson.NewFromAny(map[string]interface{}{"oauthPassThru": true}),
+	}}
+	service := &Service{
+		tlog:              log.New("tempo-test"),
+		dataSourceCache:   dataSources,
+		secrets:           dataSources,
+		oAuthTokenService: &fakeOAuthTokenService{token: &oauth2.Token{AccessToken: "token", TokenType: "Bearer"}},
+		prometheus:        prometheus,
+	}
+	pluginCtx := backend.PluginContext{OrgID: 1, User: &backend.User{Login: "user"}}
+	query := backend.DataQuery{
+		RefID:     "A",
+		QueryType: serviceMapQueryType,
+		TimeRange: backend.TimeRange{From: time.Unix(0, 0), To: time.Unix(3600, 0)},
+	}
+
+	res := service.queryServiceGraph(context.Background(), pluginCtx, &datasourceInfo{ServiceMapDatasourceUID: "prom"}, query)
+	require.NoError(t, res.Error)
+
+	require.Equal(t, "prometheus", prometheus.req.PluginContext.PluginID)
+	require.Equal(t, "http://prometheus", prometheus.req.PluginContext.DataSourceInstanceSettings.URL)
+	require.Equal(t, "secret", prometheus.req.PluginContext.DataSourceInstanceSettings.DecryptedSecureJSONData["basicAuthPassword"])
+	require.Equal(t, map[string]string{"Authorization": "Bearer token"}, prometheus.req.Headers)
+	require.Len(t, prometheus.req.Queries, 4)
+	var model map[string]interface{}
+	require.NoError(t, json.Unmarshal(prometheus.req.Queries[0].JSON, &model))
+	require.Equal(t, "sum by (client, server) (rate(traces_service_graph_request_total[$__range]))", model["expr"])
+	require.Equal(t, true, model["instant"])
+
+	require.Len(t, res.Frames, 2)
+	nodes, edges := res.Frames[0], res.Frames[1]
+	require.Equal(t, "A", nodes.RefID)
+	require.Equal(t, data.VisTypeNodeGraph, string(nodes.Meta.PreferredVisualization))
+
+	require.Equal(t, 3, nodes.Rows())
+	// app, db and user, sorted by name.
+	require.Equal(t, "app", nodes.Fields[0].At(0))
+	require.InDelta(t, 200.0, nodes.Fields[2].At(0), 1e-9)
+	require.Equal(t, 10.0, nodes.Fields[3].At(0))
+	require.InDelta(t, 0.9, nodes.Fields[4].At(0), 1e-9)
+	require.InDelta(t, 0.1, nodes.Fields[5].At(0), 1e-9)
+	require.Equal(t, "user", nodes.Fields[0].At(2))
+	require.Equal(t, 0.0, nodes.Fields[3].At(2))
+
+	require.Equal(t, 2, edges.Rows())
+	require.Equal(t, "app_db", edges.Fields[0].At(0))
+	require.Equal(t, "app", edges.Fields[1].At(0))
+	require.Equal(t, "db", edges.Fields[2].At(0))
+	require.Equal(t, 4.0, edges.Fields[3].At(0))
+	require.InDelta(t, 50.0, edges.Fields[4].At(0), 1e-9)
+}
+
+func TestQueryServiceGraphErrors(t *testing.T) {
+	addUserHandlers(t)
+	dataSources := &fakeDataSources{ds: &models.DataSource{Uid: "loki", OrgId: 1, Name: "Loki", Type: "loki"}}
+	prometheus := &fakePrometheus{}
+	service := &Service{
+		tlog:            log.New("tempo-test"),
+		dataSourceCache: dataSources,
+		secrets:         dataSources,
+		prometheus:      prometheus,
+	}
+	user := &backend.User{Login: "user"}
+	dsInfo := &datasourceInfo{ServiceMapDatasourceUID: "loki"}
+
+	res := service.queryServiceGraph(context.Background(), backend.PluginContext{OrgID: 1, User: user}, &datasourceInfo{}, backend.DataQuery{})
+	require.EqualError(t, res.Error, "no Prometheus data source is linked for the service graph")
+
+	res = service.queryServiceGraph(context.Background(), backend.PluginContext{OrgID: 1, User: user}, dsInfo, backend.DataQuery{})
+	require.EqualError(t, res.Error, `the service graph data source "Loki" is not a Prometheus data source`)
+
+	res = service.queryServiceGraph(context.Background(), backend.PluginContext{OrgID: 2, User: user}, dsInfo, backend.DataQuery{})
+	require.Error(t, res.Error)
+
+	res = service.queryServiceGraph(context.Background(), backend.PluginContext{OrgID: 1}, dsInfo, backend.DataQuery{})
+	require.Error(t, res.Error)
+
+	res = service.queryServiceGraph(context.Background(), backend.PluginContext{OrgID: 1, User: &backend.User{Login: "denied"}}, dsInfo, backend.DataQuery{})
+	require.ErrorIs(t, res.Error, models.ErrDataSourceAccessDenied)
+	require.Nil(t, prometheus.req)
+}
@@ -13,28 +13,59 @@ import (
 	"github.com/grafana/grafana-plugin-sdk-go/data"
 	"github.com/grafana/grafana/pkg/infra/httpclient"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/datasources"
+	"github.com/grafana/grafana/pkg/services/oauthtoken"
+	"github.com/grafana/grafana/pkg/tsdb/prometheus"
 	"go.opentelemetry.io/collector/model/otlp"
 )
 
 type Service struct {
 	im   instancemgmt.InstanceManager
 	tlog log.Logger
+	// dataSourceCache, secrets, oAuthTokenService and prometheus query the
+	// metrics of service graphs.
+	dataSourceCache   datasources.CacheService
+	secrets           secretsDecrypter
+	oAuthTokenService oauthtoken.OAuthTokenService
+	prometheus        backend.QueryDataHandler
 }
 
-func ProvideService(httpClientProvider httpclient.Provider) *Service {
-	return &Service{
-		tlog: log.New("tsdb.tempo"),
-		im:   datasource.NewInstanceManager(newInstanceSettings(httpClientProvider)),
+func ProvideService(httpClientProvider httpclient.Provider, dataSourceCache datasources.CacheService, dataSources *datasources.Service,
+	oAuthTokenService oauthtoken.OAuthTokenService, prometheus *prometheus.Service) *Service {
+	s := &Service{
+		tlog:              log.New("tsdb.tempo"),
+		im:                datasource.NewInstanceManager(newInstanceSettings(httpClientProvider)),
+		dataSourceCache:   dataSourceCache,
+		oAuthTokenService: oAuthTokenService,
 	}
+	if dataSources != nil && prometheus != nil {
+		s.secrets = dataSources
+		s.prometheus = prometheus
+	}
+	return s
 }
 
 type datasourceInfo struct {
 	HTTPClient *http.Client
 	URL        string
+	// ServiceMapDatasourceUID is the UID of the Prometheus data source with
+	// the service graph metrics.
+	ServiceMapDatasourceUID string
+}
+
+type jsonData struct {
+	ServiceMap struct {
+		DatasourceUID string `json:"datasourceUid"`
+	} `json:"serviceMap"`
 }
 
 type QueryModel struct {
+	// TraceID is the TraceQL query of searches.
 	TraceID string `json:"query"`
+	// Limit is the number of traces a search returns at most.
+	Limit int `json:"limit"`
+	// SpansPerSpanSet is the number of spans a search returns at most per span set.
+	SpansPerSpanSet int `json:"spss"`
 }
 
 func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.InstanceFactoryFunc {
@@ -49,9 +80,17 @@ func newInstanceSettings(httpClientProvider httpclient.Provider) datasource.Inst
 			return nil, err
 		}
 
+		var jsonData jsonData
+		if len(settings.JSONData) > 0 {
+			if err := json.Unmarshal(settings.JSONData, &jsonData); err != nil {
+				return nil, fmt.Errorf("error reading settings: %w", err)
+			}
+		}
+
 		model := &datasourceInfo{
-			HTTPClient: client,
-			URL:        settings.URL,
+			HTTPClient:              client,
+			URL:                     settings.URL,
+			ServiceMapDatasourceUID: jsonData.ServiceMap.DatasourceUID,
 		}
 		return model, nil
 	}
@@ -73,6 +112,15 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 		return nil, err
 	}
 
+	switch req.Queries[0].QueryType {
+	case traceQLQueryType:
+		result.Responses[refID] = s.searchTraceQL(ctx, dsInfo, req.Queries[0], model)
+		return result, nil
+	case serviceMapQueryType:
+		result.Responses[refID] = s.queryServiceGraph(ctx, req.PluginContext, dsInfo, req.Queries[0])
+		return result, nil
+	}
+
 	request, err := s.createRequest(ctx, dsInfo, model.TraceID)
 	if err != nil {
 		return result, err
@@ -106,10 +154,7 @@ func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest)
 		return &backend.QueryDataResponse{}, fmt.Errorf("failed to convert tempo response to Otlp: %w", err)
 	}
 
-	frame, err := TraceToFrame(otTrace)
-	if err != nil {
-		return &backend.QueryDataResponse{}, fmt.Errorf("failed to transform trace %v to data frame: %w", model.TraceID, err)
-	}
+	frame := TraceToFrame(otTrace)
 	frame.RefID = refID
 	frames := []*data.Frame{frame}
 	queryRes.Frames = frames
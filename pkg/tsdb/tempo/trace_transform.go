@@ -1,84 +1,39 @@
 package tempo
 
 import (
-	"encoding/json"
-	"fmt"
 	"strings"
 
 	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/traceframe"
 	"go.opentelemetry.io/collector/model/pdata"
 	"go.opentelemetry.io/collector/translator/conventions"
 	tracetranslator "go.opentelemetry.io/collector/translator/trace"
 )
 
-type KeyValue struct {
-	Value interface{} `json:"value"`
-	Key   string      `json:"key"`
-}
-
-type TraceLog struct {
-	// Millisecond epoch time
-	Timestamp float64     `json:"timestamp"`
-	Fields    []*KeyValue `json:"fields"`
-}
-
-func TraceToFrame(td pdata.Traces) (*data.Frame, error) {
+func TraceToFrame(td pdata.Traces) *data.Frame {
 	// In open telemetry format the spans are grouped first by resource/service they originated in and inside that
 	// resource they are grouped by the instrumentation library which created them.
 
 	resourceSpans := td.ResourceSpans()
-
-	if resourceSpans.Len() == 0 {
-		return nil, nil
-	}
-
-	frame := &data.Frame{
-		Name: "Trace",
-		Fields: []*data.Field{
-			data.NewField("traceID", nil, []string{}),
-			data.NewField("spanID", nil, []string{}),
-			data.NewField("parentSpanID", nil, []string{}),
-			data.NewField("operationName", nil, []string{}),
-			data.NewField("serviceName", nil, []string{}),
-			data.NewField("serviceTags", nil, []string{}),
-			data.NewField("startTime", nil, []float64{}),
-			data.NewField("duration", nil, []float64{}),
-			data.NewField("logs", nil, []string{}),
-			data.NewField("tags", nil, []string{}),
-		},
-		Meta: &data.FrameMeta{
-			// TODO: use constant once available in the SDK
-			PreferredVisualization: "trace",
-		},
-	}
-
+	var spans []traceframe.Span
 	for i := 0; i < resourceSpans.Len(); i++ {
-		rs := resourceSpans.At(i)
-		rows, err := resourceSpansToRows(rs)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, row := range rows {
-			frame.AppendRow(row...)
-		}
+		spans = append(spans, resourceSpansToRows(resourceSpans.At(i))...)
 	}
-
-	return frame, nil
+	return traceframe.New("otlp", spans)
 }
 
 // resourceSpansToRows processes all the spans for a particular resource/service
-func resourceSpansToRows(rs pdata.ResourceSpans) ([][]interface{}, error) {
+func resourceSpansToRows(rs pdata.ResourceSpans) []traceframe.Span {
 	resource := rs.Resource()
 	ilss := rs.InstrumentationLibrarySpans()
 
 	if resource.Attributes().Len() == 0 || ilss.Len() == 0 {
-		return [][]interface{}{}, nil
+		return nil
 	}
 
 	// Approximate the number of the spans as the number of the spans in the first
 	// instrumentation library info.
-	rows := make([][]interface{}, 0, ilss.At(0).Spans().Len())
+	rows := make([]traceframe.Span, 0, ilss.At(0).Spans().Len())
 
 	for i := 0; i < ilss.Len(); i++ {
 		ils := ilss.At(i)
@@ -87,81 +42,47 @@ func resourceSpansToRows(rs pdata.ResourceSpans) ([][]interface{}, error) {
 		spans := ils.Spans()
 
 		for j := 0; j < spans.Len(); j++ {
-			span := spans.At(j)
-			row, err := spanToSpanRow(span, ils.InstrumentationLibrary(), resource)
-			if err != nil {
-				return nil, err
-			}
-			if row != nil {
-				rows = append(rows, row)
-			}
+			rows = append(rows, spanToSpanRow(spans.At(j), ils.InstrumentationLibrary(), resource))
 		}
 	}
 
-	return rows, nil
+	return rows
 }
 
-func spanToSpanRow(span pdata.Span, libraryTags pdata.InstrumentationLibrary, resource pdata.Resource) ([]interface{}, error) {
+func spanToSpanRow(span pdata.Span, libraryTags pdata.InstrumentationLibrary, resource pdata.Resource) traceframe.Span {
 	// If the id representation changed from hexstring to something else we need to change the transformBase64IDToHexString in the frontend code
 	traceID := span.TraceID().HexString()
 	traceID = strings.TrimLeft(traceID, "0")
 
-	spanID := span.SpanID().HexString()
-
-	parentSpanID := span.ParentSpanID().HexString()
-	startTime := float64(span.StartTimestamp()) / 1_000_000
 	serviceName, serviceTags := resourceToProcess(resource)
 
-	serviceTagsJson, err := json.Marshal(serviceTags)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal service tags: %w", err)
-	}
-
-	spanTags, err := json.Marshal(getSpanTags(span, libraryTags))
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal span tags: %w", err)
-	}
-
-	logs, err := json.Marshal(spanEventsToLogs(span.Events()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal span logs: %w", err)
-	}
-
-	return []interface{}{
-		traceID,
-		spanID,
-		parentSpanID,
-		span.Name(),
-		serviceName,
-		toJSONString(serviceTagsJson),
-		startTime,
-		float64(span.EndTimestamp()-span.StartTimestamp()) / 1_000_000,
-		toJSONString(logs),
-		toJSONString(spanTags),
-	}, nil
-}
-
-func toJSONString(json []byte) string {
-	s := string(json)
-	if s == "null" {
-		return ""
+	return traceframe.Span{
+		TraceID:       traceID,
+		SpanID:        span.SpanID().HexString(),
+		ParentSpanID:  span.ParentSpanID().HexString(),
+		OperationName: span.Name(),
+		ServiceName:   serviceName,
+		ServiceTags:   serviceTags,
+		StartTime:     float64(span.StartTimestamp()) / 1_000_000,
+		Duration:      float64(span.EndTimestamp()-span.StartTimestamp()) / 1_000_000,
+		Logs:          spanEventsToLogs(span.Events()),
+		Tags:          getSpanTags(span, libraryTags),
 	}
-	return s
 }
 
-func resourceToProcess(resource pdata.Resource) (string, []*KeyValue) {
+func resourceToProcess(resource pdata.Resource) (string, []traceframe.KeyValue) {
 	attrs := resource.Attributes()
 	serviceName := tracetranslator.ResourceNoServiceName
 	if attrs.Len() == 0 {
 		return serviceName, nil
 	}
 
-	tags := make([]*KeyValue, 0, attrs.Len()-1)
+	tags := make([]traceframe.KeyValue, 0, attrs.Len()-1)
 	attrs.Range(func(key string, attr pdata.AttributeValue) bool {
 		if key == conventions.AttributeServiceName {
 			serviceName = attr.StringVal()
 		}
-		tags = append(tags, &KeyValue{Key: key, Value: getAttributeVal(attr)})
+		tags = append(tags, traceframe.KeyValue{Key: key, Value: getAttributeVal(attr)})
 		return true
 	})
 
@@ -185,20 +106,20 @@ func getAttributeVal(attr pdata.AttributeValue) interface{} {
 	}
 }
 
-func getSpanTags(span pdata.Span, instrumentationLibrary pdata.InstrumentationLibrary) []*KeyValue {
-	var tags []*KeyValue
+func getSpanTags(span pdata.Span, instrumentationLibrary pdata.InstrumentationLibrary) []traceframe.KeyValue {
+	var tags []traceframe.KeyValue
 
 	libraryTags := getTagsFromInstrumentationLibrary(instrumentationLibrary)
 	if libraryTags != nil {
 		tags = append(tags, libraryTags...)
 	}
 	span.Attributes().Range(func(key string, attr pdata.AttributeValue) bool {
-		tags = append(tags, &KeyValue{Key: key, Value: getAttributeVal(attr)})
+		tags = append(tags, traceframe.KeyValue{Key: key, Value: getAttributeVal(attr)})
 		return true
 	})
 
 	status := span.Status()
-	possibleNilTags := []*KeyValue{
+	possibleNilTags := []*traceframe.KeyValue{
 		getTagFromSpanKind(span.Kind()),
 		getTagFromStatusCode(status.Code()),
 		getErrorTagFromStatusCode(status.Code()),
@@ -208,23 +129,23 @@ func getSpanTags(span pdata.Span, instrumentationLibrary pdata.InstrumentationLi
 
 	for _, tag := range possibleNilTags {
 		if tag != nil {
-			tags = append(tags, tag)
+			tags = append(tags, *tag)
 		}
 	}
 	return tags
 }
 
-func getTagsFromInstrumentationLibrary(il pdata.InstrumentationLibrary) []*KeyValue {
-	var keyValues []*KeyValue
+func getTagsFromInstrumentationLibrary(il pdata.InstrumentationLibrary) []traceframe.KeyValue {
+	var keyValues []traceframe.KeyValue
 	if ilName := il.Name(); ilName != "" {
-		kv := &KeyValue{
+		kv := traceframe.KeyValue{
 			Key:   conventions.InstrumentationLibraryName,
 			Value: ilName,
 		}
 		keyValues = append(keyValues, kv)
 	}
 	if ilVersion := il.Version(); ilVersion != "" {
-		kv := &KeyValue{
+		kv := traceframe.KeyValue{
 			Key:   conventions.InstrumentationLibraryVersion,
 			Value: ilVersion,
 		}
@@ -234,7 +155,7 @@ func getTagsFromInstrumentationLibrary(il pdata.InstrumentationLibrary) []*KeyVa
 	return keyValues
 }
 
-func getTagFromSpanKind(spanKind pdata.SpanKind) *KeyValue {
+func getTagFromSpanKind(spanKind pdata.SpanKind) *traceframe.KeyValue {
 	var tagStr string
 	switch spanKind {
 	case pdata.SpanKindClient:
@@ -251,22 +172,22 @@ func getTagFromSpanKind(spanKind pdata.SpanKind) *KeyValue {
 		return nil
 	}
 
-	return &KeyValue{
+	return &traceframe.KeyValue{
 		Key:   tracetranslator.TagSpanKind,
 		Value: tagStr,
 	}
 }
 
-func getTagFromStatusCode(statusCode pdata.StatusCode) *KeyValue {
-	return &KeyValue{
+func getTagFromStatusCode(statusCode pdata.StatusCode) *traceframe.KeyValue {
+	return &traceframe.KeyValue{
 		Key:   tracetranslator.TagStatusCode,
 		Value: int64(statusCode),
 	}
 }
 
-func getErrorTagFromStatusCode(statusCode pdata.StatusCode) *KeyValue {
+func getErrorTagFromStatusCode(statusCode pdata.StatusCode) *traceframe.KeyValue {
 	if statusCode == pdata.StatusCodeError {
-		return &KeyValue{
+		return &traceframe.KeyValue{
 			Key:   tracetranslator.TagError,
 			Value: true,
 		}
@@ -274,19 +195,19 @@ func getErrorTagFromStatusCode(statusCode pdata.StatusCode) *KeyValue {
 	return nil
 }
 
-func getTagFromStatusMsg(statusMsg string) *KeyValue {
+func getTagFromStatusMsg(statusMsg string) *traceframe.KeyValue {
 	if statusMsg == "" {
 		return nil
 	}
-	return &KeyValue{
+	return &traceframe.KeyValue{
 		Key:   tracetranslator.TagStatusMsg,
 		Value: statusMsg,
 	}
 }
 
-func getTagFromTraceState(traceState pdata.TraceState) *KeyValue {
+func getTagFromTraceState(traceState pdata.TraceState) *traceframe.KeyValue {
 	if traceState != pdata.TraceStateEmpty {
-		return &KeyValue{
+		return &traceframe.KeyValue{
 			Key:   tracetranslator.TagW3CTraceState,
 			Value: string(traceState),
 		}
@@ -294,26 +215,26 @@ func getTagFromTraceState(traceState pdata.TraceState) *KeyValue {
 	return nil
 }
 
-func spanEventsToLogs(events pdata.SpanEventSlice) []*TraceLog {
+func spanEventsToLogs(events pdata.SpanEventSlice) []traceframe.Log {
 	if events.Len() == 0 {
 		return nil
 	}
 
-	logs := make([]*TraceLog, 0, events.Len())
+	logs := make([]traceframe.Log, 0, events.Len())
 	for i := 0; i < events.Len(); i++ {
 		event := events.At(i)
-		fields := make([]*KeyValue, 0, event.Attributes().Len()+1)
+		fields := make([]traceframe.KeyValue, 0, event.Attributes().Len()+1)
 		if event.Name() != "" {
-			fields = append(fields, &KeyValue{
+			fields = append(fields, traceframe.KeyValue{
 				Key:   tracetranslator.TagMessage,
 				Value: event.Name(),
 			})
 		}
 		event.Attributes().Range(func(key string, attr pdata.AttributeValue) bool {
-			fields = append(fields, &KeyValue{Key: key, Value: getAttributeVal(attr)})
+			fields = append(fields, traceframe.KeyValue{Key: key, Value: getAttributeVal(attr)})
 			return true
 		})
-		logs = append(logs, &TraceLog{
+		logs = append(logs, traceframe.Log{
 			Timestamp: float64(event.Timestamp()) / 1_000_000,
 			Fields:    fields,
 		})
@@ -22,8 +22,7 @@ func TestTraceToFrame(t *testing.T) {
 		otTrace, err := otlp.NewProtobufTracesUnmarshaler().UnmarshalTraces(proto)
 		require.NoError(t, err)
 
-		frame, err := TraceToFrame(otTrace)
-		require.NoError(t, err)
+		frame := TraceToFrame(otTrace)
 
 		require.Equal(t, 30, frame.Rows())
 		require.ElementsMatch(t, fields, fieldNames(frame))
@@ -34,21 +33,21 @@ func TestTraceToFrame(t *testing.T) {
 
 		require.Equal(t, "HTTP GET - loki_api_v1_query_range", root["operationName"])
 		require.Equal(t, "loki-all", root["serviceName"])
-		require.Equal(t, "[{\"value\":\"loki-all\",\"key\":\"service.name\"},{\"value\":\"Jaeger-Go-2.25.0\",\"key\":\"opencensus.exporterversion\"},{\"value\":\"4d019a031941\",\"key\":\"host.hostname\"},{\"value\":\"172.18.0.6\",\"key\":\"ip\"},{\"value\":\"4b19ace06df8e4de\",\"key\":\"client-uuid\"}]", root["serviceTags"])
+		require.Equal(t, "[{\"key\":\"service.name\",\"value\":\"loki-all\"},{\"key\":\"opencensus.exporterversion\",\"value\":\"Jaeger-Go-2.25.0\"},{\"key\":\"host.hostname\",\"value\":\"4d019a031941\"},{\"key\":\"ip\",\"value\":\"172.18.0.6\"},{\"key\":\"client-uuid\",\"value\":\"4b19ace06df8e4de\"}]", root["serviceTags"])
 		require.Equal(t, 1616072924070.497, root["startTime"])
 		require.Equal(t, 8.421, root["duration"])
 		require.Equal(t, "", root["logs"])
-		require.Equal(t, "[{\"value\":\"const\",\"key\":\"sampler.type\"},{\"value\":true,\"key\":\"sampler.param\"},{\"value\":200,\"key\":\"http.status_code\"},{\"value\":\"GET\",\"key\":\"http.method\"},{\"value\":\"/loki/api/v1/query_range?direction=BACKWARD\\u0026limit=1000\\u0026query=%7Bcompose_project%3D%22devenv%22%7D%20%7C%3D%22traceID%22\\u0026start=1616070921000000000\\u0026end=1616072722000000000\\u0026step=2\",\"key\":\"http.url\"},{\"value\":\"net/http\",\"key\":\"component\"},{\"value\":\"server\",\"key\":\"span.kind\"},{\"value\":0,\"key\":\"status.code\"}]", root["tags"])
+		require.Equal(t, "[{\"key\":\"sampler.type\",\"value\":\"const\"},{\"key\":\"sampler.param\",\"value\":true},{\"key\":\"http.status_code\",\"value\":200},{\"key\":\"http.method\",\"value\":\"GET\"},{\"key\":\"http.url\",\"value\":\"/loki/api/v1/query_range?direction=BACKWARD\\u0026limit=1000\\u0026query=%7Bcompose_project%3D%22devenv%22%7D%20%7C%3D%22traceID%22\\u0026start=1616070921000000000\\u0026end=1616072722000000000\\u0026step=2\"},{\"key\":\"component\",\"value\":\"net/http\"},{\"key\":\"span.kind\",\"value\":\"server\"},{\"key\":\"status.code\",\"value\":0}]", root["tags"])
 
 		span := bFrame.FindRowWithValue("spanID", "7198307df9748606")
 
 		require.Equal(t, "GetParallelChunks", span["operationName"])
 		require.Equal(t, "loki-all", span["serviceName"])
-		require.Equal(t, "[{\"value\":\"loki-all\",\"key\":\"service.name\"},{\"value\":\"Jaeger-Go-2.25.0\",\"key\":\"opencensus.exporterversion\"},{\"value\":\"4d019a031941\",\"key\":\"host.hostname\"},{\"value\":\"172.18.0.6\",\"key\":\"ip\"},{\"value\":\"4b19ace06df8e4de\",\"key\":\"client-uuid\"}]", span["serviceTags"])
+		require.Equal(t, "[{\"key\":\"service.name\",\"value\":\"loki-all\"},{\"key\":\"opencensus.exporterversion\",\"value\":\"Jaeger-Go-2.25.0\"},{\"key\":\"host.hostname\",\"value\":\"4d019a031941\"},{\"key\":\"ip\",\"value\":\"172.18.0.6\"},{\"key\":\"client-uuid\",\"value\":\"4b19ace06df8e4de\"}]", span["serviceTags"])
 		require.Equal(t, 1616072924072.852, span["startTime"])
 		require.Equal(t, 0.094, span["duration"])
-		require.Equal(t, "[{\"timestamp\":1616072924072.856,\"fields\":[{\"value\":1,\"key\":\"chunks requested\"}]},{\"timestamp\":1616072924072.9448,\"fields\":[{\"value\":1,\"key\":\"chunks fetched\"}]}]", span["logs"])
-		require.Equal(t, "[{\"value\":0,\"key\":\"status.code\"}]", span["tags"])
+		require.Equal(t, "[{\"timestamp\":1616072924072.856,\"fields\":[{\"key\":\"chunks requested\",\"value\":1}]},{\"timestamp\":1616072924072.9448,\"fields\":[{\"key\":\"chunks fetched\",\"value\":1}]}]", span["logs"])
+		require.Equal(t, "[{\"key\":\"status.code\",\"value\":0}]", span["tags"])
 	})
 }
 
@@ -107,4 +106,6 @@ var fields = []string{
 	"duration",
 	"logs",
 	"tags",
+	"warnings",
+	"stackTraces",
 }
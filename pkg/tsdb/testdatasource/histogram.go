@@ -0,0 +1,159 @@
+package testdatasource
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/components/simplejson"
+)
+
+// histogramBuckets are the upper bounds of the default buckets of Prometheus
+// client libraries.
+var histogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, math.Inf(1)}
+
+func (s *Service) handlePrometheusHistogramScenario(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	resp := backend.NewQueryDataResponse()
+
+	for _, q := range req.Queries {
+		model, err := simplejson.NewJson(q.JSON)
+		if err != nil {
+			continue
+		}
+
+		respD := resp.Responses[q.RefID]
+		respD.Frames = append(respD.Frames, prometheusHistogram(q, model)...)
+		resp.Responses[q.RefID] = respD
+	}
+
+	return resp, nil
+}
+
+func (s *Service) handleExemplarsScenario(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	resp := backend.NewQueryDataResponse()
+
+	for _, q := range req.Queries {
+		model, err := simplejson.NewJson(q.JSON)
+		if err != nil {
+			continue
+		}
+
+		series := RandomWalk(q, model, 0)
+		respD := resp.Responses[q.RefID]
+		respD.Frames = append(respD.Frames, series, exemplars(series, model))
+		resp.Responses[q.RefID] = respD
+	}
+
+	return resp, nil
+}
+
+// prometheusHistogram returns a series per bucket of a histogram, as returned
+// by Prometheus for the increase of the buckets of a histogram: the values are
+// cumulative, and the upper bound of the bucket is in the `le` label.
+func prometheusHistogram(query backend.DataQuery, model *simplejson.Json) data.Frames {
+	labels := parseLabels(model)
+	timeWalkerMs := query.TimeRange.From.UnixNano() / int64(time.Millisecond)
+	to := query.TimeRange.To.UnixNano() / int64(time.Millisecond)
+
+	timeVec := make([]time.Time, 0)
+	bucketVecs := make([][]float64, len(histogramBuckets))
+	for i := 0; i < 10000 && timeWalkerMs < to; i++ {
+		timeVec = append(timeVec, time.Unix(timeWalkerMs/int64(1e+3), (timeWalkerMs%int64(1e+3))*int64(1e+6)))
+
+		// The observations are latencies of around 100ms.
+		counts := make([]float64, len(histogramBuckets))
+		for n := 0; n < 100; n++ {
+			observation := 0.1 * math.Exp(rand.NormFloat64())
+			for b, bound := range histogramBuckets {
+				if observation <= bound {
+					counts[b]++
+				}
+			}
+		}
+		for b := range histogramBuckets {
+			bucketVecs[b] = append(bucketVecs[b], counts[b])
+		}
+
+		timeWalkerMs += query.Interval.Milliseconds()
+	}
+
+	frames := make(data.Frames, 0, len(histogramBuckets))
+	for b, bound := range histogramBuckets {
+		le := strconv.FormatFloat(bound, 'g', -1, 64)
+		if math.IsInf(bound, 1) {
+			le = "+Inf"
+		}
+
+		bucketLabels := data.Labels{"le": le}
+		for k, v := range labels {
+			bucketLabels[k] = v
+		}
+
+		valueField := data.NewField(data.TimeSeriesValueFieldName, bucketLabels, bucketVecs[b])
+		valueField.Config = &data.FieldConfig{DisplayNameFromDS: le}
+		frame := data.NewFrame(le,
+			data.NewField(data.TimeSeriesTimeFieldName, nil, timeVec),
+			valueField,
+		)
+		frame.Meta = &data.FrameMeta{Custom: map[string]interface{}{"resultType": "matrix"}}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// exemplars returns exemplars of about one in ten points of the series, as
+// returned by Prometheus: a frame with the time and value of the exemplars,
+// followed by a field per label, including their trace ID.
+func exemplars(series *data.Frame, model *simplejson.Json) *data.Frame {
+	labels := parseLabels(model)
+	labelFields := make(map[string]*data.Field, len(labels))
+	for k := range labels {
+		labelFields[k] = data.NewField(k, nil, []string{})
+	}
+
+	timeField := data.NewField(data.TimeSeriesTimeFieldName, nil, []time.Time{})
+	valueField := data.NewField(data.TimeSeriesValueFieldName, nil, []float64{})
+	traceIDField := data.NewField("traceID", nil, []string{})
+	for i := 0; i < series.Rows(); i++ {
+		if rand.Float64() >= 0.1 {
+			continue
+		}
+		t, ok := series.Fields[0].ConcreteAt(i)
+		if !ok {
+			continue
+		}
+		v, ok := series.Fields[1].ConcreteAt(i)
+		if !ok {
+			continue
+		}
+
+		timeField.Append(t.(time.Time))
+		valueField.Append(v.(float64) + rand.NormFloat64())
+		traceIDField.Append(fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64()))
+		for k, field := range labelFields {
+			field.Append(labels[k])
+		}
+	}
+
+	frame := data.NewFrame("exemplar", timeField, valueField, traceIDField)
+	for _, k := range sortedKeys(labels) {
+		frame.Fields = append(frame.Fields, labelFields[k])
+	}
+	frame.Meta = &data.FrameMeta{Custom: map[string]interface{}{"resultType": "exemplar"}}
+	return frame
+}
+
+func sortedKeys(labels data.Labels) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
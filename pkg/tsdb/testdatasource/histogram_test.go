@@ -0,0 +1,66 @@
+package testdatasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusHistogramScenario(t *testing.T) {
+	s := &Service{}
+	now := time.Now()
+	resp, err := s.handlePrometheusHistogramScenario(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			TimeRange: backend.TimeRange{From: now.Add(-time.Minute), To: now},
+			Interval:  time.Second,
+			JSON:      []byte(`{"labels": "job=\"api\""}`),
+		}},
+	})
+	require.NoError(t, err)
+
+	frames := resp.Responses["A"].Frames
+	require.Len(t, frames, len(histogramBuckets))
+	assert.Equal(t, data.Labels{"le": "0.005", "job": "api"}, frames[0].Fields[1].Labels)
+	assert.Equal(t, data.Labels{"le": "+Inf", "job": "api"}, frames[len(frames)-1].Fields[1].Labels)
+
+	for row := 0; row < frames[0].Rows(); row++ {
+		previous := 0.0
+		for _, frame := range frames {
+			count := frame.Fields[1].At(row).(float64)
+			assert.GreaterOrEqual(t, count, previous, "buckets are cumulative")
+			previous = count
+		}
+		assert.Equal(t, 100.0, previous)
+	}
+}
+
+func TestExemplarsScenario(t *testing.T) {
+	s := &Service{}
+	now := time.Now()
+	resp, err := s.handleExemplarsScenario(context.Background(), &backend.QueryDataRequest{
+		Queries: []backend.DataQuery{{
+			RefID:     "A",
+			TimeRange: backend.TimeRange{From: now.Add(-time.Hour), To: now},
+			Interval:  time.Second,
+			JSON:      []byte(`{"labels": "job=\"api\""}`),
+		}},
+	})
+	require.NoError(t, err)
+
+	frames := resp.Responses["A"].Frames
+	require.Len(t, frames, 2)
+	exemplars := frames[1]
+	assert.Equal(t, "exemplar", exemplars.Name)
+	require.Len(t, exemplars.Fields, 4)
+	assert.Equal(t, []string{"Time", "Value", "traceID", "job"},
+		[]string{exemplars.Fields[0].Name, exemplars.Fields[1].Name, exemplars.Fields[2].Name, exemplars.Fields[3].Name})
+	require.Greater(t, exemplars.Rows(), 0)
+	assert.Len(t, exemplars.Fields[2].At(0), 32)
+	assert.Equal(t, "api", exemplars.Fields[3].At(0))
+}
@@ -43,6 +43,8 @@ const (
 	rawFrameQuery                     queryType = "raw_frame"
 	csvFileQueryType                  queryType = "csv_file"
 	csvContentQueryType               queryType = "csv_content"
+	prometheusHistogramQuery          queryType = "prometheus_histogram"
+	exemplarsQuery                    queryType = "exemplars"
 )
 
 type queryType string
@@ -68,6 +70,21 @@ func (s *Service) registerScenarios() {
 		handler: s.handleLinearHeatmapBucketDataScenario,
 	})
 
+	s.registerScenario(&Scenario{
+		ID:      string(prometheusHistogramQuery),
+		Name:    "Prometheus histogram buckets",
+		handler: s.handlePrometheusHistogramScenario,
+		Description: `Prometheus histogram buckets returns a series per bucket of a histogram of latencies, with the
+upper bound of the bucket in the le label, the way Prometheus returns the increase of histogram buckets.`,
+	})
+
+	s.registerScenario(&Scenario{
+		ID:          string(exemplarsQuery),
+		Name:        "Exemplars",
+		handler:     s.handleExemplarsScenario,
+		Description: `Exemplars returns a random walk and a frame of exemplars with trace IDs, the way Prometheus returns exemplars.`,
+	})
+
 	s.registerScenario(&Scenario{
 		ID:      string(randomWalkQuery),
 		Name:    "Random Walk",
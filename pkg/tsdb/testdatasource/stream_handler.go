@@ -3,8 +3,10 @@ package testdatasource
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -58,35 +60,43 @@ func (s *Service) PublishStream(_ context.Context, req *backend.PublishStreamReq
 
 func (s *Service) RunStream(ctx context.Context, request *backend.RunStreamRequest, sender *backend.StreamSender) error {
 	s.logger.Debug("New stream call", "path", request.Path)
+	name, params, err := parseStreamPath(request.Path)
+	if err != nil {
+		return err
+	}
+
 	var conf testStreamConfig
 	switch {
-	case request.Path == "random-2s-stream":
+	case name == "random-2s-stream":
 		conf = testStreamConfig{
 			Interval: 2 * time.Second,
 		}
-	case request.Path == "random-flakey-stream":
+	case name == "random-flakey-stream":
 		conf = testStreamConfig{
 			Interval: 100 * time.Millisecond,
 			Drop:     0.75, // keep 25%
 		}
-	case request.Path == "random-labeled-stream":
+	case name == "random-labeled-stream":
 		conf = testStreamConfig{
 			Interval: 200 * time.Millisecond,
 			Drop:     0.2, // keep 80%
 			Labeled:  true,
 		}
-	case request.Path == "flight-5hz-stream":
+	case name == "flight-5hz-stream":
 		conf = testStreamConfig{
 			Interval: 200 * time.Millisecond,
 			Flight:   newFlightConfig(),
 		}
-	case random20HzStreamRegex.MatchString(request.Path):
+	case random20HzStreamRegex.MatchString(name):
 		conf = testStreamConfig{
 			Interval: 50 * time.Millisecond,
 		}
 	default:
 		return fmt.Errorf("testdata plugin does not support path: %s", request.Path)
 	}
+	if err := conf.apply(params); err != nil {
+		return err
+	}
 	return s.runTestStream(ctx, request.Path, conf, sender)
 }
 
@@ -95,6 +105,78 @@ type testStreamConfig struct {
 	Drop     float64
 	Flight   *flightConfig
 	Labeled  bool
+	// Jitter delays each point by up to this fraction of the interval.
+	Jitter float64
+	// Gap is the probability of a drop-out starting at each point, no points
+	// are sent for the GapLength next intervals.
+	Gap       float64
+	GapLength int
+	// Churn replaces the series of labeled streams every Churn points.
+	Churn int
+	// Growth multiplies the values by this factor at each point.
+	Growth float64
+}
+
+// parseStreamPath splits the path of a stream into the name of the stream and
+// its parameters, which follow the name as key=value path segments, e.g.
+// random-labeled-stream/jitter=0.5/churn=10.
+func parseStreamPath(path string) (string, map[string]float64, error) {
+	segments := strings.Split(path, "/")
+	params := make(map[string]float64, len(segments)-1)
+	for _, segment := range segments[1:] {
+		parts := strings.SplitN(segment, "=", 2)
+		if len(parts) != 2 {
+			return "", nil, fmt.Errorf("invalid stream parameter %q, expected key=value", segment)
+		}
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid value of stream parameter %q: %w", parts[0], err)
+		}
+		params[parts[0]] = value
+	}
+	return segments[0], params, nil
+}
+
+// apply sets the parameters of a stream in the configuration.
+func (c *testStreamConfig) apply(params map[string]float64) error {
+	for key, value := range params {
+		switch key {
+		case "jitter":
+			if value < 0 {
+				return fmt.Errorf("jitter must not be negative")
+			}
+			c.Jitter = value
+		case "drop", "gap":
+			if value < 0 || value > 1 {
+				return fmt.Errorf("%s must be a probability between 0 and 1", key)
+			}
+			if key == "drop" {
+				c.Drop = value
+			} else {
+				c.Gap = value
+			}
+		case "gapLength", "churn":
+			if value < 1 || value != math.Trunc(value) {
+				return fmt.Errorf("%s must be a positive integer", key)
+			}
+			if key == "gapLength" {
+				c.GapLength = int(value)
+			} else {
+				c.Churn = int(value)
+			}
+		case "growth":
+			if value <= 0 {
+				return fmt.Errorf("growth must be positive")
+			}
+			c.Growth = value
+		default:
+			return fmt.Errorf("unknown stream parameter %q", key)
+		}
+	}
+	if c.Gap > 0 && c.GapLength == 0 {
+		c.GapLength = 10
+	}
+	return nil
 }
 
 func (s *Service) runTestStream(ctx context.Context, path string, conf testStreamConfig, sender *backend.StreamSender) error {
@@ -110,6 +192,9 @@ func (s *Service) runTestStream(ctx context.Context, path string, conf testStrea
 		flight.append(conf.Flight.getNextPoint(time.Now()))
 	}
 
+	scale := 1.0
+	var points, gap int
+
 	labelFrame := data.NewFrame("labeled",
 		data.NewField("labels", nil, make([]string, 2)),
 		data.NewField("Time", nil, make([]time.Time, 2)),
@@ -122,10 +207,34 @@ func (s *Service) runTestStream(ctx context.Context, path string, conf testStrea
 			s.logger.Debug("Stop streaming data for path", "path", path)
 			return ctx.Err()
 		case t := <-ticker.C:
+			if gap > 0 {
+				gap--
+				continue
+			}
+			if rand.Float64() < conf.Gap {
+				gap = conf.GapLength - 1
+				continue
+			}
 			if rand.Float64() < conf.Drop {
 				continue
 			}
 
+			if conf.Jitter > 0 {
+				delay := time.Duration(rand.Float64() * conf.Jitter * float64(conf.Interval))
+				select {
+				case <-ctx.Done():
+					s.logger.Debug("Stop streaming data for path", "path", path)
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+				t = t.Add(delay)
+			}
+
+			if conf.Growth > 0 {
+				scale *= conf.Growth
+			}
+			points++
+
 			mode := data.IncludeDataOnly
 			if s.features.IsEnabled(featuremgmt.FlagLivePipeline) {
 				mode = data.IncludeAll
@@ -139,26 +248,32 @@ func (s *Service) runTestStream(ctx context.Context, path string, conf testStrea
 			} else {
 				delta := rand.Float64() - 0.5
 				walker += delta
+				value := walker * scale
 
 				if conf.Labeled {
-					secA := t.Second() / 3
-					secB := t.Second() / 7
+					labelsA := fmt.Sprintf("s=A,s=p%d,x=X", t.Second()/3)
+					labelsB := fmt.Sprintf("s=B,s=p%d,x=X", t.Second()/7)
+					if conf.Churn > 0 {
+						generation := (points - 1) / conf.Churn
+						labelsA = fmt.Sprintf("s=A,gen=%d", generation)
+						labelsB = fmt.Sprintf("s=B,gen=%d", generation)
+					}
 
-					labelFrame.Fields[0].Set(0, fmt.Sprintf("s=A,s=p%d,x=X", secA))
+					labelFrame.Fields[0].Set(0, labelsA)
 					labelFrame.Fields[1].Set(0, t)
-					labelFrame.Fields[2].Set(0, walker)
+					labelFrame.Fields[2].Set(0, value)
 
-					labelFrame.Fields[0].Set(1, fmt.Sprintf("s=B,s=p%d,x=X", secB))
+					labelFrame.Fields[0].Set(1, labelsB)
 					labelFrame.Fields[1].Set(1, t)
-					labelFrame.Fields[2].Set(1, walker+10)
+					labelFrame.Fields[2].Set(1, value+10*scale)
 					if err := sender.SendFrame(labelFrame, mode); err != nil {
 						return err
 					}
 				} else {
 					s.frame.Fields[0].Set(0, t)
-					s.frame.Fields[1].Set(0, walker)                                // Value
-					s.frame.Fields[2].Set(0, walker-((rand.Float64()*spread)+0.01)) // Min
-					s.frame.Fields[3].Set(0, walker+((rand.Float64()*spread)+0.01)) // Max
+					s.frame.Fields[1].Set(0, value)                                      // Value
+					s.frame.Fields[2].Set(0, value-((rand.Float64()*spread)+0.01)*scale) // Min
+					s.frame.Fields[3].Set(0, value+((rand.Float64()*spread)+0.01)*scale) // Max
 					if err := sender.SendFrame(s.frame, mode); err != nil {
 						return err
 					}
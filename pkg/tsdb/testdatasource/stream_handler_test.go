@@ -0,0 +1,96 @@
+package testdatasource
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/services/featuremgmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStreamPath(t *testing.T) {
+	name, params, err := parseStreamPath("random-labeled-stream/jitter=0.5/churn=10")
+	require.NoError(t, err)
+	assert.Equal(t, "random-labeled-stream", name)
+	assert.Equal(t, map[string]float64{"jitter": 0.5, "churn": 10}, params)
+
+	name, params, err = parseStreamPath("random-2s-stream")
+	require.NoError(t, err)
+	assert.Equal(t, "random-2s-stream", name)
+	assert.Empty(t, params)
+
+	_, _, err = parseStreamPath("random-2s-stream/jitter")
+	require.EqualError(t, err, `invalid stream parameter "jitter", expected key=value`)
+}
+
+func TestTestStreamConfigApply(t *testing.T) {
+	conf := testStreamConfig{Drop: 0.75}
+	require.NoError(t, conf.apply(map[string]float64{"jitter": 0.2, "drop": 0, "gap": 0.1, "churn": 5, "growth": 1.1}))
+	assert.Equal(t, testStreamConfig{Jitter: 0.2, Gap: 0.1, GapLength: 10, Churn: 5, Growth: 1.1}, conf)
+
+	for params, expected := range map[string]string{
+		"gap=2":         "gap must be a probability between 0 and 1",
+		"churn=1.5":     "churn must be a positive integer",
+		"gapLength=0":   "gapLength must be a positive integer",
+		"growth=0":      "growth must be positive",
+		"jitter=-1":     "jitter must not be negative",
+		"unknown=1":     `unknown stream parameter "unknown"`,
+		"growth=faster": `invalid value of stream parameter "growth": strconv.ParseFloat: parsing "faster": invalid syntax`,
+	} {
+		_, parsed, err := parseStreamPath("random-2s-stream/" + params)
+		if err == nil {
+			err = (&testStreamConfig{}).apply(parsed)
+		}
+		require.EqualError(t, err, expected, params)
+	}
+}
+
+type fakeStreamPacketSender struct {
+	packets chan *backend.StreamPacket
+}
+
+func (s *fakeStreamPacketSender) Send(packet *backend.StreamPacket) error {
+	s.packets <- packet
+	return nil
+}
+
+func TestRunTestStream(t *testing.T) {
+	s := &Service{logger: log.New("tsdb.testdata"), features: featuremgmt.WithFeatures()}
+	packets := &fakeStreamPacketSender{packets: make(chan *backend.StreamPacket)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		_ = s.runTestStream(ctx, "test", testStreamConfig{
+			Interval: time.Millisecond,
+			Labeled:  true,
+			Churn:    2,
+			Growth:   2,
+		}, backend.NewStreamSender(packets))
+	}()
+
+	for i, generation := range []string{"gen=0", "gen=0", "gen=1", "gen=1"} {
+		packet := <-packets.packets
+		var frame struct {
+			Data struct {
+				Values []json.RawMessage `json:"values"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(packet.Data, &frame))
+		require.Len(t, frame.Data.Values, 3)
+
+		var labels []string
+		require.NoError(t, json.Unmarshal(frame.Data.Values[0], &labels))
+		assert.Equal(t, []string{"s=A," + generation, "s=B," + generation}, labels, i)
+
+		var pointValues []float64
+		require.NoError(t, json.Unmarshal(frame.Data.Values[2], &pointValues))
+		// The series are 10 apart before scaling.
+		assert.InDelta(t, 10*float64(int(1)<<(i+1)), pointValues[1]-pointValues[0], 1e-9)
+	}
+}
@@ -0,0 +1,91 @@
+// Package traceframe builds the trace frames of the tracing data sources, the
+// frames shown by the trace view.
+package traceframe
+
+import (
+	"encoding/json"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// KeyValue is a tag of a span or of its service, or a field of a span log.
+type KeyValue struct {
+	Key   string      `json:"key"`
+	Type  string      `json:"type,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// Log is an event logged by a span.
+type Log struct {
+	// Timestamp is in milliseconds.
+	Timestamp float64    `json:"timestamp"`
+	Fields    []KeyValue `json:"fields"`
+}
+
+// Span is a row of a trace frame.
+type Span struct {
+	TraceID       string
+	SpanID        string
+	ParentSpanID  string
+	OperationName string
+	ServiceName   string
+	ServiceTags   []KeyValue
+	// StartTime and Duration are in milliseconds.
+	StartTime   float64
+	Duration    float64
+	Logs        []Log
+	Tags        []KeyValue
+	Warnings    []string
+	StackTraces []string
+}
+
+// New returns the trace frame of the spans. The format is the format of the
+// trace the spans were converted from, e.g. jaeger, and is kept in the custom
+// metadata of the frame for the inspector to download the trace.
+func New(format string, spans []Span) *data.Frame {
+	frame := data.NewFrame("Trace",
+		data.NewField("traceID", nil, make([]string, 0, len(spans))),
+		data.NewField("spanID", nil, make([]string, 0, len(spans))),
+		data.NewField("parentSpanID", nil, make([]string, 0, len(spans))),
+		data.NewField("operationName", nil, make([]string, 0, len(spans))),
+		data.NewField("serviceName", nil, make([]string, 0, len(spans))),
+		data.NewField("serviceTags", nil, make([]string, 0, len(spans))),
+		data.NewField("startTime", nil, make([]float64, 0, len(spans))),
+		data.NewField("duration", nil, make([]float64, 0, len(spans))),
+		data.NewField("logs", nil, make([]string, 0, len(spans))),
+		data.NewField("tags", nil, make([]string, 0, len(spans))),
+		data.NewField("warnings", nil, make([]string, 0, len(spans))),
+		data.NewField("stackTraces", nil, make([]string, 0, len(spans))),
+	)
+	frame.Meta = &data.FrameMeta{
+		PreferredVisualization: data.VisTypeTrace,
+		Custom:                 map[string]interface{}{"traceFormat": format},
+	}
+
+	for _, s := range spans {
+		frame.AppendRow(
+			s.TraceID,
+			s.SpanID,
+			s.ParentSpanID,
+			s.OperationName,
+			s.ServiceName,
+			toJSONString(s.ServiceTags),
+			s.StartTime,
+			s.Duration,
+			toJSONString(s.Logs),
+			toJSONString(s.Tags),
+			toJSONString(s.Warnings),
+			toJSONString(s.StackTraces),
+		)
+	}
+	return frame
+}
+
+// toJSONString encodes v as JSON, empty values being empty strings.
+func toJSONString(v interface{}) string {
+	encoded, err := json.Marshal(v)
+	if err != nil || string(encoded) == "null" || string(encoded) == "[]" {
+		return ""
+	}
+	return string(encoded)
+}
@@ -0,0 +1,48 @@
+package traceframe
+
+import (
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("it converts the spans to rows", func(t *testing.T) {
+		frame := New("jaeger", []Span{
+			{
+				TraceID:       "abc",
+				SpanID:        "2",
+				ParentSpanID:  "1",
+				OperationName: "query",
+				ServiceName:   "db",
+				ServiceTags:   []KeyValue{{Key: "hostname", Type: "string", Value: "host-1"}},
+				StartTime:     1600000000200,
+				Duration:      300,
+				Logs:          []Log{{Timestamp: 1600000000250, Fields: []KeyValue{{Key: "event", Value: "done"}}}},
+				Tags:          []KeyValue{},
+				Warnings:      []string{"clock skew"},
+			},
+		})
+
+		require.Equal(t, "Trace", frame.Name)
+		require.Equal(t, data.VisTypeTrace, string(frame.Meta.PreferredVisualization))
+		require.Equal(t, map[string]interface{}{"traceFormat": "jaeger"}, frame.Meta.Custom)
+		require.Equal(t, []interface{}{
+			"abc", "2", "1", "query", "db",
+			`[{"key":"hostname","type":"string","value":"host-1"}]`,
+			1600000000200.0, 300.0,
+			`[{"timestamp":1600000000250,"fields":[{"key":"event","value":"done"}]}]`,
+			"",
+			`["clock skew"]`,
+			"",
+		}, frame.RowCopy(0))
+	})
+
+	t.Run("it returns a frame with all the fields without spans", func(t *testing.T) {
+		frame := New("zipkin", nil)
+
+		require.Equal(t, 0, frame.Rows())
+		require.Len(t, frame.Fields, 12)
+	})
+}
@@ -0,0 +1,97 @@
+// Package tracingds has the parts of the Jaeger and Zipkin data sources that
+// query the HTTP API of their tracing backend.
+package tracingds
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana/pkg/infra/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+)
+
+// DatasourceInfo is the instance of a data source.
+type DatasourceInfo struct {
+	HTTPClient *http.Client
+	URL        string
+}
+
+// NewInstanceSettings returns the factory of the instances of the data sources.
+func NewInstanceSettings(httpClientProvider httpclient.Provider) datasource.InstanceFactoryFunc {
+	return func(settings backend.DataSourceInstanceSettings) (instancemgmt.Instance, error) {
+		opts, err := settings.HTTPClientOptions()
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := httpClientProvider.New(opts)
+		if err != nil {
+			return nil, err
+		}
+
+		return &DatasourceInfo{
+			HTTPClient: client,
+			URL:        strings.TrimSuffix(settings.URL, "/"),
+		}, nil
+	}
+}
+
+// GetDSInfo returns the instance of the data source of the plugin context.
+func GetDSInfo(im instancemgmt.InstanceManager, pluginCtx backend.PluginContext) (*DatasourceInfo, error) {
+	i, err := im.Get(pluginCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, ok := i.(*DatasourceInfo)
+	if !ok {
+		return nil, fmt.Errorf("failed to cast datasource info")
+	}
+
+	return instance, nil
+}
+
+// Response is a response of the API, with its body read.
+type Response struct {
+	Status     string
+	StatusCode int
+	Body       []byte
+}
+
+// Get requests the path of the API of the data source with the params. The
+// name of the backend, e.g. Jaeger, is used in logs and errors.
+func Get(ctx context.Context, logger log.Logger, name string, dsInfo *DatasourceInfo, path string, params url.Values) (*Response, error) {
+	u := dsInfo.URL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug(name+" request", "url", req.URL.String())
+	res, err := dsInfo.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", name, err)
+	}
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			logger.Warn("Failed to close response body", "err", err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{Status: res.Status, StatusCode: res.StatusCode, Body: body}, nil
+}
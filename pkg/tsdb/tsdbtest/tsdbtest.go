@@ -0,0 +1,34 @@
+// Package tsdbtest has helpers for the tests of the data sources.
+package tsdbtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+)
+
+// FakeInstanceManager is an instance manager returning Instance for every
+// plugin context.
+type FakeInstanceManager struct {
+	Instance instancemgmt.Instance
+}
+
+func (m *FakeInstanceManager) Get(pluginContext backend.PluginContext) (instancemgmt.Instance, error) {
+	return m.Instance, nil
+}
+
+func (m *FakeInstanceManager) Do(pluginContext backend.PluginContext, fn instancemgmt.InstanceCallbackFunc) error {
+	return nil
+}
+
+// NewServer starts a test server serving the requests of a data source with
+// handler. It is closed at the end of the test.
+func NewServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
@@ -0,0 +1,130 @@
+package zipkin
+
+import (
+	"sort"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/tsdb/traceframe"
+)
+
+type endpoint struct {
+	ServiceName string `json:"serviceName"`
+	IPv4        string `json:"ipv4"`
+	IPv6        string `json:"ipv6"`
+	Port        int    `json:"port"`
+}
+
+type annotation struct {
+	// Timestamp is in microseconds.
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+type span struct {
+	TraceID  string `json:"traceId"`
+	ParentID string `json:"parentId"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	// Timestamp and Duration are in microseconds.
+	Timestamp      int64             `json:"timestamp"`
+	Duration       int64             `json:"duration"`
+	LocalEndpoint  *endpoint         `json:"localEndpoint"`
+	RemoteEndpoint *endpoint         `json:"remoteEndpoint"`
+	Annotations    []annotation      `json:"annotations"`
+	Tags           map[string]string `json:"tags"`
+	Kind           string            `json:"kind"`
+	Shared         bool              `json:"shared"`
+}
+
+// traceFrame converts the spans of a trace into a trace frame.
+func traceFrame(spans []span) *data.Frame {
+	rows := make([]traceframe.Span, 0, len(spans))
+	for _, s := range spans {
+		rows = append(rows, spanToSpanRow(s))
+	}
+	return traceframe.New("zipkin", rows)
+}
+
+func spanToSpanRow(s span) traceframe.Span {
+	serviceName, serviceTags := spanService(s)
+	logs := make([]traceframe.Log, 0, len(s.Annotations))
+	for _, a := range s.Annotations {
+		logs = append(logs, traceframe.Log{
+			Timestamp: float64(a.Timestamp) / 1000,
+			Fields:    []traceframe.KeyValue{{Key: "annotation", Value: a.Value}},
+		})
+	}
+
+	return traceframe.Span{
+		TraceID:       s.TraceID,
+		SpanID:        s.ID,
+		ParentSpanID:  s.ParentID,
+		OperationName: s.Name,
+		ServiceName:   serviceName,
+		ServiceTags:   serviceTags,
+		StartTime:     float64(s.Timestamp) / 1000,
+		Duration:      float64(s.Duration) / 1000,
+		Logs:          logs,
+		Tags:          spanTags(s),
+	}
+}
+
+// spanService returns the service name of the span, from its local endpoint
+// or else its remote endpoint, and the tags of that endpoint.
+func spanService(s span) (string, []traceframe.KeyValue) {
+	serviceName := "unknown"
+	if s.LocalEndpoint != nil && s.LocalEndpoint.ServiceName != "" {
+		serviceName = s.LocalEndpoint.ServiceName
+	} else if s.RemoteEndpoint != nil && s.RemoteEndpoint.ServiceName != "" {
+		serviceName = s.RemoteEndpoint.ServiceName
+	}
+
+	e, endpointType := s.LocalEndpoint, "local"
+	if e == nil {
+		e, endpointType = s.RemoteEndpoint, "remote"
+	}
+	if e == nil {
+		return serviceName, nil
+	}
+
+	var tags []traceframe.KeyValue
+	if e.IPv4 != "" {
+		tags = append(tags, traceframe.KeyValue{Key: "ipv4", Value: e.IPv4})
+	}
+	if e.IPv6 != "" {
+		tags = append(tags, traceframe.KeyValue{Key: "ipv6", Value: e.IPv6})
+	}
+	if e.Port != 0 {
+		tags = append(tags, traceframe.KeyValue{Key: "port", Value: e.Port})
+	}
+	tags = append(tags, traceframe.KeyValue{Key: "endpointType", Value: endpointType})
+	return serviceName, tags
+}
+
+// spanTags returns the tags of the span, with its kind and whether it's shared.
+// The error tag is split into a boolean error tag, for the trace view to flag
+// the span, and the errorValue tag.
+func spanTags(s span) []traceframe.KeyValue {
+	var tags []traceframe.KeyValue
+	if s.Shared {
+		tags = append(tags, traceframe.KeyValue{Key: "shared", Value: true})
+	}
+	if s.Kind != "" {
+		tags = append(tags, traceframe.KeyValue{Key: "kind", Value: s.Kind})
+	}
+
+	keys := make([]string, 0, len(s.Tags))
+	for key := range s.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "error" {
+			tags = append(tags, traceframe.KeyValue{Key: "error", Value: true}, traceframe.KeyValue{Key: "errorValue", Value: s.Tags[key]})
+			continue
+		}
+		tags = append(tags, traceframe.KeyValue{Key: key, Value: s.Tags[key]})
+	}
+	return tags
+}
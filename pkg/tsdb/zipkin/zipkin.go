@@ -0,0 +1,108 @@
+package zipkin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/httpclient"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/tsdb/tracingds"
+)
+
+// apiPrefix is the prefix of the paths of the Zipkin API.
+const apiPrefix = "/api/v2"
+
+// traceIDQueryType is the query type of the queries of a trace by ID, which
+// is also the type of the queries without query type.
+const traceIDQueryType = "traceID"
+
+type Service struct {
+	im     instancemgmt.InstanceManager
+	logger log.Logger
+}
+
+func ProvideService(httpClientProvider httpclient.Provider) *Service {
+	return &Service{
+		logger: log.New("tsdb.zipkin"),
+		im:     datasource.NewInstanceManager(tracingds.NewInstanceSettings(httpClientProvider)),
+	}
+}
+
+type queryModel struct {
+	// TraceID is the ID of the trace to get.
+	TraceID string `json:"query"`
+}
+
+func (s *Service) QueryData(ctx context.Context, req *backend.QueryDataRequest) (*backend.QueryDataResponse, error) {
+	dsInfo, err := tracingds.GetDSInfo(s.im, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	result := backend.NewQueryDataResponse()
+	for _, query := range req.Queries {
+		result.Responses[query.RefID] = s.query(ctx, dsInfo, query)
+	}
+	return result, nil
+}
+
+func (s *Service) query(ctx context.Context, dsInfo *tracingds.DatasourceInfo, query backend.DataQuery) backend.DataResponse {
+	if query.QueryType != "" && query.QueryType != traceIDQueryType {
+		return backend.DataResponse{Error: fmt.Errorf("unsupported query type %q", query.QueryType)}
+	}
+
+	model := &queryModel{}
+	if err := json.Unmarshal(query.JSON, model); err != nil {
+		return backend.DataResponse{Error: fmt.Errorf("failed to unmarshal query: %w", err)}
+	}
+
+	var spans []span
+	if model.TraceID != "" {
+		if err := s.get(ctx, dsInfo, "/trace/"+url.PathEscape(model.TraceID), &spans); err != nil {
+			return backend.DataResponse{Error: fmt.Errorf("failed to get trace with id %s: %w", model.TraceID, err)}
+		}
+	}
+
+	frame := traceFrame(spans)
+	frame.RefID = query.RefID
+	return backend.DataResponse{Frames: data.Frames{frame}}
+}
+
+func (s *Service) CheckHealth(ctx context.Context, req *backend.CheckHealthRequest) (*backend.CheckHealthResult, error) {
+	dsInfo, err := tracingds.GetDSInfo(s.im, req.PluginContext)
+	if err != nil {
+		return nil, err
+	}
+
+	var services []string
+	if err := s.get(ctx, dsInfo, "/services", &services); err != nil {
+		return &backend.CheckHealthResult{
+			Status:  backend.HealthStatusError,
+			Message: "Zipkin: " + err.Error(),
+		}, nil
+	}
+	return &backend.CheckHealthResult{
+		Status:  backend.HealthStatusOk,
+		Message: "Data source is working",
+	}, nil
+}
+
+// get requests the path of the Zipkin API and decodes the response into v.
+func (s *Service) get(ctx context.Context, dsInfo *tracingds.DatasourceInfo, path string, v interface{}) error {
+	res, err := tracingds.Get(ctx, s.logger, "Zipkin", dsInfo, apiPrefix+path, nil)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("request failed with status %s: %s", res.Status, strings.TrimSpace(string(res.Body)))
+	}
+	return json.Unmarshal(res.Body, v)
+}
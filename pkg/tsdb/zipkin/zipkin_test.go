@@ -0,0 +1,113 @@
+package zipkin
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/tsdb/tracingds"
+	"github.com/grafana/grafana/pkg/tsdb/tsdbtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestService(t *testing.T, handler http.HandlerFunc) *Service {
+	srv := tsdbtest.NewServer(t, handler)
+	return &Service{
+		logger: log.New("tsdb.zipkin"),
+		im:     &tsdbtest.FakeInstanceManager{Instance: &tracingds.DatasourceInfo{HTTPClient: srv.Client(), URL: srv.URL}},
+	}
+}
+
+func TestQueryData(t *testing.T) {
+	t.Run("gets the trace of the ID", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/v2/trace/a/b", r.URL.Path)
+			assert.Equal(t, "/api/v2/trace/a%2Fb", r.URL.RawPath)
+			_, _ = w.Write([]byte(`[
+				{
+					"traceId": "trace", "id": "1", "name": "get",
+					"timestamp": 1600000000000000, "duration": 1500,
+					"localEndpoint": {"serviceName": "frontend", "ipv4": "10.0.0.1", "port": 8080},
+					"kind": "SERVER"
+				},
+				{
+					"traceId": "trace", "parentId": "1", "id": "2", "name": "query",
+					"timestamp": 1600000000000500, "duration": 500,
+					"remoteEndpoint": {"serviceName": "db"},
+					"annotations": [{"timestamp": 1600000000000600, "value": "retry"}],
+					"tags": {"error": "timeout", "db.type": "sql"}
+				}
+			]`))
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{"query": "a/b"}`)}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+
+		frame := res.Responses["A"].Frames[0]
+		assert.Equal(t, "A", frame.RefID)
+		assert.Equal(t, data.VisTypeTrace, string(frame.Meta.PreferredVisualization))
+		require.Equal(t, 2, frame.Rows())
+		assert.Equal(t, []interface{}{
+			"trace", "1", "", "get", "frontend",
+			`[{"key":"ipv4","value":"10.0.0.1"},{"key":"port","value":8080},{"key":"endpointType","value":"local"}]`,
+			1600000000000.0, 1.5, "", `[{"key":"kind","value":"SERVER"}]`, "", "",
+		}, frame.RowCopy(0))
+		assert.Equal(t, []interface{}{
+			"trace", "2", "1", "query", "db",
+			`[{"key":"endpointType","value":"remote"}]`,
+			1600000000000.5, 0.5,
+			`[{"timestamp":1600000000000.6,"fields":[{"key":"annotation","value":"retry"}]}]`,
+			`[{"key":"db.type","value":"sql"},{"key":"error","value":true},{"key":"errorValue","value":"timeout"}]`,
+			"", "",
+		}, frame.RowCopy(1))
+	})
+
+	t.Run("returns an empty trace without trace ID", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Fatal("unexpected request")
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{{RefID: "A", JSON: []byte(`{}`)}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Responses["A"].Error)
+		require.Len(t, res.Responses["A"].Frames, 1)
+		assert.Equal(t, 0, res.Responses["A"].Frames[0].Rows())
+	})
+
+	t.Run("returns the errors of the API", func(t *testing.T) {
+		s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "trace not found", http.StatusNotFound)
+		})
+
+		res, err := s.QueryData(context.Background(), &backend.QueryDataRequest{
+			Queries: []backend.DataQuery{
+				{RefID: "A", JSON: []byte(`{"query": "abc"}`)},
+				{RefID: "B", QueryType: "upload", JSON: []byte(`{}`)},
+			},
+		})
+		require.NoError(t, err)
+		require.EqualError(t, res.Responses["A"].Error, "failed to get trace with id abc: request failed with status 404 Not Found: trace not found")
+		require.EqualError(t, res.Responses["B"].Error, `unsupported query type "upload"`)
+	})
+}
+
+func TestCheckHealth(t *testing.T) {
+	s := newTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v2/services", r.URL.Path)
+		_, _ = w.Write([]byte(`["frontend"]`))
+	})
+
+	res, err := s.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, backend.HealthStatusOk, res.Status)
+}
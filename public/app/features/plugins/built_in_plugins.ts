@@ -17,6 +17,8 @@ const jaegerPlugin = async () =>
   await import(/* webpackChunkName: "jaegerPlugin" */ 'app/plugins/datasource/jaeger/module');
 const zipkinPlugin = async () =>
   await import(/* webpackChunkName: "zipkinPlugin" */ 'app/plugins/datasource/zipkin/module');
+const httpDataPlugin = async () =>
+  await import(/* webpackChunkName: "httpDataPlugin" */ 'app/plugins/datasource/httpdata/module');
 const mixedPlugin = async () =>
   await import(/* webpackChunkName: "mixedPlugin" */ 'app/plugins/datasource/mixed/module');
 const mysqlPlugin = async () =>
@@ -85,6 +87,7 @@ const builtInPlugins: any = {
   'app/plugins/datasource/loki/module': lokiPlugin,
   'app/plugins/datasource/jaeger/module': jaegerPlugin,
   'app/plugins/datasource/zipkin/module': zipkinPlugin,
+  'app/plugins/datasource/httpdata/module': httpDataPlugin,
   'app/plugins/datasource/mixed/module': mixedPlugin,
   'app/plugins/datasource/mysql/module': mysqlPlugin,
   'app/plugins/datasource/postgres/module': postgresPlugin,
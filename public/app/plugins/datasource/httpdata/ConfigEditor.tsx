@@ -0,0 +1,17 @@
+import React from 'react';
+import { DataSourcePluginOptionsEditorProps } from '@grafana/data';
+import { DataSourceHttpSettings } from '@grafana/ui';
+import { HTTPDataOptions } from './types';
+
+export type Props = DataSourcePluginOptionsEditorProps<HTTPDataOptions>;
+
+export const ConfigEditor: React.FC<Props> = ({ options, onOptionsChange }) => {
+  return (
+    <DataSourceHttpSettings
+      defaultUrl="http://localhost:8080"
+      dataSourceConfig={options}
+      showAccessOptions={false}
+      onChange={onOptionsChange}
+    />
+  );
+};
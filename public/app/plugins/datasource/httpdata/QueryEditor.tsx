@@ -0,0 +1,154 @@
+import React from 'react';
+import { QueryEditorProps, SelectableValue } from '@grafana/data';
+import { Button, InlineField, InlineFieldRow, Input, RadioButtonGroup, Select, TextArea } from '@grafana/ui';
+import { HTTPDataDatasource } from './datasource';
+import { HTTPDataColumn, HTTPDataColumnType, HTTPDataFormat, HTTPDataOptions, HTTPDataQuery } from './types';
+
+type Props = QueryEditorProps<HTTPDataDatasource, HTTPDataQuery, HTTPDataOptions>;
+
+const labelWidth = 12;
+
+const methods: Array<SelectableValue<'GET' | 'POST'>> = [
+  { label: 'GET', value: 'GET' },
+  { label: 'POST', value: 'POST' },
+];
+
+const formats: Array<SelectableValue<HTTPDataFormat>> = [
+  { label: 'JSON', value: 'json' },
+  { label: 'CSV', value: 'csv' },
+];
+
+const columnTypes: Array<SelectableValue<HTTPDataColumnType>> = [
+  { label: 'String', value: 'string' },
+  { label: 'Number', value: 'number' },
+  { label: 'Time', value: 'time' },
+  { label: 'Boolean', value: 'boolean' },
+];
+
+export const QueryEditor = ({ query, onChange, onRunQuery }: Props) => {
+  const format = query.format ?? 'json';
+  const columns = query.columns ?? [];
+
+  const onColumnsChange = (columns: HTTPDataColumn[]) => {
+    onChange({ ...query, columns });
+    onRunQuery();
+  };
+
+  const onColumnChange = (index: number, column: HTTPDataColumn) => {
+    onColumnsChange(columns.map((c, i) => (i === index ? column : c)));
+  };
+
+  return (
+    <>
+      <InlineFieldRow>
+        <InlineField label="Method" labelWidth={labelWidth}>
+          <Select
+            width={12}
+            menuShouldPortal
+            options={methods}
+            value={query.method ?? 'GET'}
+            onChange={(v) => {
+              onChange({ ...query, method: v.value });
+              onRunQuery();
+            }}
+          />
+        </InlineField>
+        <InlineField label="Path" labelWidth={labelWidth} grow tooltip="Path and query string appended to the URL">
+          <Input
+            placeholder="/api/items?limit=100"
+            value={query.path ?? ''}
+            onChange={(e) => onChange({ ...query, path: e.currentTarget.value })}
+            onBlur={onRunQuery}
+          />
+        </InlineField>
+      </InlineFieldRow>
+      {query.method === 'POST' && (
+        <InlineField label="Body" labelWidth={labelWidth} grow>
+          <TextArea
+            rows={3}
+            value={query.body ?? ''}
+            onChange={(e) => onChange({ ...query, body: e.currentTarget.value })}
+            onBlur={onRunQuery}
+          />
+        </InlineField>
+      )}
+      <InlineFieldRow>
+        <InlineField label="Format" labelWidth={labelWidth}>
+          <RadioButtonGroup
+            options={formats}
+            value={format}
+            onChange={(v) => {
+              onChange({ ...query, format: v });
+              onRunQuery();
+            }}
+          />
+        </InlineField>
+        {format === 'json' && (
+          <InlineField label="Rows" labelWidth={labelWidth} grow tooltip="JSONPath of the rows, for example $.items">
+            <Input
+              placeholder="$"
+              value={query.root ?? ''}
+              onChange={(e) => onChange({ ...query, root: e.currentTarget.value })}
+              onBlur={onRunQuery}
+            />
+          </InlineField>
+        )}
+      </InlineFieldRow>
+      {columns.map((column, index) => (
+        <InlineFieldRow key={index}>
+          <InlineField
+            label="Column"
+            labelWidth={labelWidth}
+            tooltip={format === 'json' ? 'Key or JSONPath of the value in the row' : 'Name of the column in the header'}
+          >
+            <Input
+              width={30}
+              value={column.selector}
+              onChange={(e) => onColumnChange(index, { ...column, selector: e.currentTarget.value })}
+            />
+          </InlineField>
+          <InlineField label="Alias">
+            <Input
+              width={20}
+              value={column.name ?? ''}
+              onChange={(e) => onColumnChange(index, { ...column, name: e.currentTarget.value })}
+            />
+          </InlineField>
+          <InlineField label="Type">
+            <Select
+              width={14}
+              menuShouldPortal
+              isClearable
+              placeholder="Auto"
+              options={columnTypes}
+              value={column.type ?? null}
+              onChange={(v) => onColumnChange(index, { ...column, type: v?.value })}
+            />
+          </InlineField>
+          {column.type === 'time' && (
+            <InlineField label="Layout" tooltip="Go layout of time strings, RFC 3339 by default">
+              <Input
+                width={24}
+                value={column.timeFormat ?? ''}
+                onChange={(e) => onColumnChange(index, { ...column, timeFormat: e.currentTarget.value })}
+              />
+            </InlineField>
+          )}
+          <Button
+            variant="secondary"
+            icon="trash-alt"
+            aria-label="Remove column"
+            onClick={() => onColumnsChange(columns.filter((_, i) => i !== index))}
+          />
+        </InlineFieldRow>
+      ))}
+      <Button
+        variant="secondary"
+        icon="plus"
+        onClick={() => onChange({ ...query, columns: [...columns, { selector: '' }] })}
+      >
+        Add column
+      </Button>
+    </>
+  );
+};
@@ -0,0 +1,28 @@
+import { DataSourceInstanceSettings, ScopedVars } from '@grafana/data';
+import { DataSourceWithBackend, getTemplateSrv } from '@grafana/runtime';
+import { HTTPDataOptions, HTTPDataQuery } from './types';
+
+export class HTTPDataDatasource extends DataSourceWithBackend<HTTPDataQuery, HTTPDataOptions> {
+  constructor(instanceSettings: DataSourceInstanceSettings<HTTPDataOptions>) {
+    super(instanceSettings);
+  }
+
+  applyTemplateVariables(query: HTTPDataQuery, scopedVars: ScopedVars): Record<string, any> {
+    const templateSrv = getTemplateSrv();
+    const params: Record<string, string> = {};
+    for (const [key, value] of Object.entries(query.params ?? {})) {
+      params[key] = templateSrv.replace(value, scopedVars);
+    }
+
+    return {
+      ...query,
+      path: templateSrv.replace(query.path ?? '', scopedVars),
+      body: templateSrv.replace(query.body ?? '', scopedVars),
+      params,
+    };
+  }
+
+  filterQuery(query: HTTPDataQuery): boolean {
+    return !query.hide;
+  }
+}
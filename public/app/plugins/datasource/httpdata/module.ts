@@ -0,0 +1,8 @@
+import { DataSourcePlugin } from '@grafana/data';
+import { HTTPDataDatasource } from './datasource';
+import { QueryEditor } from './QueryEditor';
+import { ConfigEditor } from './ConfigEditor';
+
+export const plugin = new DataSourcePlugin(HTTPDataDatasource)
+  .setQueryEditor(QueryEditor)
+  .setConfigEditor(ConfigEditor);
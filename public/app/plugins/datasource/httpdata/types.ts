@@ -0,0 +1,31 @@
+import { DataQuery, DataSourceJsonData } from '@grafana/data';
+
+export type HTTPDataFormat = 'json' | 'csv';
+
+export type HTTPDataColumnType = 'string' | 'number' | 'time' | 'boolean';
+
+export interface HTTPDataColumn {
+  // JSONPath of the value in the row, or its key, or the CSV header of its column
+  selector: string;
+  name?: string;
+  // Inferred from the values when not set
+  type?: HTTPDataColumnType;
+  // Go layout of time strings, RFC 3339 by default
+  timeFormat?: string;
+}
+
+export interface HTTPDataQuery extends DataQuery {
+  path?: string;
+  method?: 'GET' | 'POST';
+  params?: Record<string, string>;
+  body?: string;
+  format?: HTTPDataFormat;
+  // JSONPath of the rows, the whole response by default
+  root?: string;
+  columns?: HTTPDataColumn[];
+}
+
+export interface HTTPDataOptions extends DataSourceJsonData {
+  // Size of the largest response read, 10 MiB by default
+  maxResponseBytes?: number;
+}
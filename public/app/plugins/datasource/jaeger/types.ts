@@ -63,7 +63,7 @@ export type JaegerQuery = {
   limit?: number;
 } & DataQuery;
 
-export type JaegerQueryType = 'search' | 'upload';
+export type JaegerQueryType = 'search' | 'upload' | 'dependencyGraph';
 
 export type JaegerResponse = {
   data: TraceResponse[];
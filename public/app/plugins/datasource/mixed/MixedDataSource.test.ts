@@ -1,5 +1,7 @@
 import { LoadingState } from '@grafana/data';
-import { lastValueFrom } from 'rxjs';
+import { DataSourceWithBackend } from '@grafana/runtime';
+import { backendSrv } from 'app/core/services/backend_srv'; // will use the version in __mocks__
+import { lastValueFrom, of } from 'rxjs';
 import { getQueryOptions } from 'test/helpers/getQueryOptions';
 import { DatasourceSrvMock, MockObservableDataSourceApi } from 'test/mocks/datasource_srv';
 import { MIXED_DATASOURCE_NAME } from './MixedDataSource';
@@ -13,6 +15,8 @@ const datasourceSrv = new DatasourceSrvMock(defaultDS, {
   C: new MockObservableDataSourceApi('DSC', [{ data: ['CCCC'] }]),
   D: new MockObservableDataSourceApi('DSD', [{ data: [] }], {}, 'syntax error near FROM'),
   E: new MockObservableDataSourceApi('DSE', [{ data: [] }], {}, 'syntax error near WHERE'),
+  Prom: new DataSourceWithBackend({ uid: 'Prom', type: 'prometheus', name: 'Prom', id: 1 } as any),
+  Elastic: new DataSourceWithBackend({ uid: 'Elastic', type: 'elasticsearch', name: 'Elastic', id: 2 } as any),
   Loki: new MockObservableDataSourceApi('Loki', [
     { data: ['A'], key: 'A' },
     { data: ['B'], key: 'B' },
@@ -23,8 +27,15 @@ const getDataSourceSrvMock = jest.fn().mockReturnValue(datasourceSrv);
 jest.mock('@grafana/runtime', () => ({
   ...((jest.requireActual('@grafana/runtime') as unknown) as object),
   getDataSourceSrv: () => getDataSourceSrvMock(),
+  getBackendSrv: () => backendSrv,
 }));
 
+const fetchMock = jest.spyOn(backendSrv, 'fetch');
+
+beforeEach(() => {
+  fetchMock.mockReset();
+});
+
 describe('MixedDatasource', () => {
   describe('with no errors', () => {
     it('direct query should return results', async () => {
@@ -140,4 +151,57 @@ describe('MixedDatasource', () => {
       expect(results[0].data).toHaveLength(0);
     });
   });
+
+  it('should send the queries of backend data sources in a single request', async () => {
+    fetchMock.mockReturnValue(
+      of({
+        data: { results: { A: { frames: [] }, B: { error: 'Elastic: boom' } } },
+      } as any)
+    );
+    const ds = new MixedDatasource({} as any);
+
+    await expect(
+      ds.query(
+        getQueryOptions({
+          targets: [
+            { refId: 'A', datasource: { uid: 'Prom' } },
+            { refId: 'B', datasource: { uid: 'Elastic' } },
+          ],
+        })
+      )
+    ).toEmitValuesWith((results) => {
+      expect(fetchMock).toHaveBeenCalledTimes(1);
+      const { url, data } = fetchMock.mock.calls[0][0];
+      expect(url).toBe('/api/ds/query');
+      expect(data.queries.map((q: any) => q.datasource)).toEqual([
+        { type: 'prometheus', uid: 'Prom' },
+        { type: 'elasticsearch', uid: 'Elastic' },
+      ]);
+
+      expect(results).toHaveLength(1);
+      expect(results[0].state).toBe(LoadingState.Error);
+      expect(results[0].error).toEqual({ message: 'Elastic: boom', refId: 'B' });
+    });
+  });
+
+  it('should query the data sources separately when one is not a backend data source', async () => {
+    fetchMock.mockReturnValue(of({ data: { results: { QB: { frames: [] } } } } as any));
+    const ds = new MixedDatasource({} as any);
+
+    await expect(
+      ds.query(
+        getQueryOptions({
+          targets: [
+            { refId: 'QA', datasource: { uid: 'A' } },
+            { refId: 'QB', datasource: { uid: 'Prom' } },
+          ],
+        })
+      )
+    ).toEmitValuesWith((results) => {
+      expect(fetchMock).toHaveBeenCalledTimes(1);
+      expect(fetchMock.mock.calls[0][0].data.queries.map((q: any) => q.refId)).toEqual(['QB']);
+      expect(results[0].data).toEqual(['AAAA']);
+      expect(results[results.length - 1].state).toBe(LoadingState.Done);
+    });
+  });
 });
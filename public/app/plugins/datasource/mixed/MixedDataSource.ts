@@ -6,7 +6,15 @@ import {
   DataSourceInstanceSettings,
   LoadingState,
 } from '@grafana/data';
-import { getDataSourceSrv, toDataQueryError } from '@grafana/runtime';
+import {
+  BackendDataSourceResponse,
+  config,
+  DataSourceWithBackend,
+  getBackendSrv,
+  getDataSourceSrv,
+  toDataQueryError,
+  toDataQueryResponse,
+} from '@grafana/runtime';
 import { cloneDeep, groupBy } from 'lodash';
 import { forkJoin, from, Observable, of, OperatorFunction } from 'rxjs';
 import { catchError, map, mergeAll, mergeMap, reduce, toArray } from 'rxjs/operators';
@@ -51,7 +59,70 @@ export class MixedDatasource extends DataSourceApi<DataQuery> {
       return of({ data: [] } as DataQueryResponse); // nothing
     }
 
-    return this.batchQueries(mixed, request);
+    if (mixed.length === 1 || config.featureToggles.queryOverLive) {
+      return this.batchQueries(mixed, request);
+    }
+
+    // Data sources only querying the backend are sent in a single request,
+    // the backend queries them concurrently within the data proxy timeout.
+    // Unknown data sources fall back to the separate queries reporting them.
+    const datasources = Promise.all(mixed.map((query) => query.datasource)).catch(() => []);
+    return from(datasources).pipe(
+      mergeMap((apis: DataSourceApi[]) => {
+        if (apis.length && apis.every(isBackendOnly) && !hasDuplicateRefIds(queries)) {
+          return this.backendQueries(mixed, apis as DataSourceWithBackend[], request);
+        }
+        return this.batchQueries(mixed, request);
+      })
+    );
+  }
+
+  backendQueries(
+    mixed: BatchedQueries[],
+    apis: DataSourceWithBackend[],
+    request: DataQueryRequest<DataQuery>
+  ): Observable<DataQueryResponse> {
+    const { intervalMs, maxDataPoints, range, requestId, scopedVars } = request;
+    const queries: DataQuery[] = [];
+
+    mixed.forEach(({ targets }, i) => {
+      const api = apis[i];
+      for (const q of targets) {
+        if (api.filterQuery && !api.filterQuery(q)) {
+          continue;
+        }
+        queries.push({
+          ...api.applyTemplateVariables(q, scopedVars),
+          datasource: api.getRef(),
+          datasourceId: api.id, // deprecated!
+          intervalMs,
+          maxDataPoints,
+        } as DataQuery);
+      }
+    });
+
+    if (!queries.length) {
+      return of({ data: [] } as DataQueryResponse);
+    }
+
+    const body: any = { queries };
+    if (range) {
+      body.range = range;
+      body.from = range.from.valueOf().toString();
+      body.to = range.to.valueOf().toString();
+    }
+
+    return getBackendSrv()
+      .fetch<BackendDataSourceResponse>({
+        url: '/api/ds/query',
+        method: 'POST',
+        data: body,
+        requestId,
+      })
+      .pipe(
+        map((raw) => toDataQueryResponse(raw, queries)),
+        catchError((err) => of(toDataQueryResponse(err)))
+      );
   }
 
   batchQueries(mixed: BatchedQueries[], request: DataQueryRequest<DataQuery>): Observable<DataQueryResponse> {
@@ -119,6 +190,16 @@ export class MixedDatasource extends DataSourceApi<DataQuery> {
   }
 }
 
+// isBackendOnly returns whether the data source sends its queries as they are
+// to the backend, so that they can be sent along with other data sources.
+function isBackendOnly(api: DataSourceApi): boolean {
+  return api instanceof DataSourceWithBackend && api.query === DataSourceWithBackend.prototype.query;
+}
+
+function hasDuplicateRefIds(queries: DataQuery[]): boolean {
+  return new Set(queries.map((q) => q.refId)).size !== queries.length;
+}
+
 function flattenResponses(): OperatorFunction<DataQueryResponse[][], DataQueryResponse[]> {
   return reduce((all: DataQueryResponse[], current) => {
     return current.reduce((innerAll, innerCurrent) => {
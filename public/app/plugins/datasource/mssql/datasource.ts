@@ -130,6 +130,8 @@ export class MssqlDatasource extends DataSourceWithBackend<MssqlQuery, MssqlOpti
       datasource: this.getRef(),
       rawSql: this.templateSrv.replace(query, {}, this.interpolateVariable),
       format: 'table',
+      // The text typed in the variable picker, for the $__searchLike macro.
+      searchFilter: optionalOptions?.searchFilter,
     };
 
     return lastValueFrom(
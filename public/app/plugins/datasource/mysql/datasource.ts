@@ -138,6 +138,8 @@ export class MysqlDatasource extends DataSourceWithBackend<MySQLQuery, MySQLOpti
       datasource: this.getRef(),
       rawSql,
       format: 'table',
+      // The text typed in the variable picker, for the $__searchLike macro.
+      searchFilter: optionalOptions?.searchFilter,
     };
 
     const range = this.timeSrv.timeRange();
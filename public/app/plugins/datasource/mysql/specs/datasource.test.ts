@@ -185,6 +185,7 @@ describe('MySQLDatasource', () => {
       expect(fetchMock.mock.calls[0][0].data.queries[0].rawSql).toBe(
         "select title from atable where title LIKE 'aTit%'"
       );
+      expect(fetchMock.mock.calls[0][0].data.queries[0].searchFilter).toBe('aTit');
       expect(results.length).toBe(6);
     });
   });
@@ -140,6 +140,8 @@ export class PostgresDatasource extends DataSourceWithBackend<PostgresQuery, Pos
       datasource: this.getRef(),
       rawSql,
       format: 'table',
+      // The text typed in the variable picker, for the $__searchLike macro.
+      searchFilter: optionalOptions?.searchFilter,
     };
 
     const range = this.timeSrv.timeRange();
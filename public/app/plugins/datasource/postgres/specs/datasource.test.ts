@@ -402,6 +402,7 @@ describe('PostgreSQLDatasource', () => {
       expect(fetchMock.mock.calls[0][0].data.queries[0].rawSql).toBe(
         "select title from atable where title LIKE 'aTit%'"
       );
+      expect(fetchMock.mock.calls[0][0].data.queries[0].searchFilter).toBe('aTit');
       expect(results).toEqual([
         { text: 'aTitle' },
         { text: 'aTitle2' },
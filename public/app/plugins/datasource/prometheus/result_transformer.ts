@@ -85,13 +85,18 @@ export function transformV2(
   const { exemplarTraceIdDestinations: destinations } = options;
   const processedExemplarFrames = exemplarFrames.map((dataFrame) => {
     if (destinations?.length) {
+      // The backend adds links for the same destinations. The links built here resolve the
+      // name of the trace data source, so they replace the ones of the backend.
+      const linkedFields = new Set<Field>();
       for (const exemplarTraceIdDestination of destinations) {
         const traceIDField = dataFrame.fields.find((field) => field.name === exemplarTraceIdDestination.name);
         if (traceIDField) {
           const links = getDataLinks(exemplarTraceIdDestination);
-          traceIDField.config.links = traceIDField.config.links?.length
-            ? [...traceIDField.config.links, ...links]
-            : links;
+          traceIDField.config.links =
+            linkedFields.has(traceIDField) && traceIDField.config.links?.length
+              ? [...traceIDField.config.links, ...links]
+              : links;
+          linkedFields.add(traceIDField);
         }
       }
     }
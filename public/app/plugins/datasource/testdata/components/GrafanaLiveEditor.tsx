@@ -31,6 +31,11 @@ export const GrafanaLiveEditor = ({ onChange, query }: EditorProps) => {
     onChange({ ...query, channel: value });
   };
 
+  // Channels may have parameters after the stream name, such as random-flakey-stream/jitter=0.5/gap=0.1
+  const channel =
+    liveTestDataChannels.find((f) => f.value === query.channel) ??
+    (query.channel ? { label: query.channel, value: query.channel } : undefined);
+
   return (
     <InlineFieldRow>
       <InlineField label="Channel" labelWidth={14}>
@@ -40,7 +45,8 @@ export const GrafanaLiveEditor = ({ onChange, query }: EditorProps) => {
           onChange={onChannelChange}
           placeholder="Select channel"
           options={liveTestDataChannels}
-          value={liveTestDataChannels.find((f) => f.value === query.channel)}
+          value={channel}
+          allowCustomValue
         />
       </InlineField>
     </InlineFieldRow>
@@ -25,6 +25,14 @@ export class DatasourceSrvMock {
     }
     return Promise.reject(`Unknown Datasource: ${JSON.stringify(ref)}`);
   }
+
+  getInstanceSettings(ref?: DataSourceRef | string): DataSourceInstanceSettings | undefined {
+    const ds = ref ? this.datasources[getDataSourceUID(ref) ?? ''] : this.defaultDS;
+    if (!ds) {
+      return undefined;
+    }
+    return { id: ds.id, uid: ds.uid, type: ds.type, name: ds.name, meta: ds.meta } as DataSourceInstanceSettings;
+  }
 }
 
 export class MockDataSourceApi extends DataSourceApi {